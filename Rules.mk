@@ -151,6 +151,7 @@ help:
 	@echo '  test_go_expensive       - Run all go tests and compile on all platforms'
 	@echo '  test_go_race            - Run go tests with the race detector enabled'
 	@echo '  test_go_megacheck       - Run the `megacheck` vetting tool'
+	@echo '  test_go_wasm            - Check that the browser packages build for js/wasm'
 	@echo '  test_sharness_short     - Run short sharness tests'
 	@echo '  test_sharness_expensive - Run all sharness tests'
 	@echo '  coverage     - Collects coverage info from unit tests and sharness'
@@ -0,0 +1,292 @@
+// Package apiauth implements token based authentication for the HTTP RPC API.
+//
+// Tokens are opaque random secrets. Only a hash of each secret is stored in
+// the node configuration, together with the list of scopes that limit which
+// command namespaces the token may invoke.
+package apiauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Scope names a set of commands a token is allowed to invoke.
+type Scope string
+
+const (
+	// ScopeRead allows commands which only read data from the node.
+	ScopeRead Scope = "read"
+	// ScopePin allows managing pins.
+	ScopePin Scope = "pin"
+	// ScopeAdmin allows every command.
+	ScopeAdmin Scope = "admin"
+)
+
+// scopeCommands lists the command paths each scope grants access to. A path
+// grants access to the command itself and to all of its subcommands.
+var scopeCommands = map[Scope][]string{
+	ScopeRead: {
+		"block/get",
+		"block/stat",
+		"cat",
+		"commands",
+		"dag/get",
+		"dag/resolve",
+		"dht/findpeer",
+		"dht/findprovs",
+		"dht/get",
+		"dns",
+		"events",
+		"files/ls",
+		"files/read",
+		"files/stat",
+		"get",
+		"id",
+		"key/list",
+		"ls",
+		"name/resolve",
+		"object/data",
+		"object/get",
+		"object/links",
+		"object/stat",
+		"pin/ls",
+		"refs",
+		"repo/stat",
+		"resolve",
+		"stats",
+		"swarm/addrs",
+		"swarm/peers",
+		"version",
+	},
+	ScopePin: {
+		"pin",
+	},
+}
+
+// Scopes returns the names of all known scopes.
+func Scopes() []Scope {
+	return []Scope{ScopeRead, ScopePin, ScopeAdmin}
+}
+
+// ParseScope validates a scope name.
+func ParseScope(s string) (Scope, error) {
+	for _, sc := range Scopes() {
+		if string(sc) == s {
+			return sc, nil
+		}
+	}
+	return "", fmt.Errorf("unknown API token scope: %q", s)
+}
+
+// Token is a configured API token.
+type Token struct {
+	Name   string
+	Hash   string
+	Scopes []Scope
+	// Quota is the number of bytes of the DAGs the token may add and pin,
+	// 0 for no limit.
+	Quota uint64
+}
+
+// Allows returns whether the token may invoke the command at the given path
+// (e.g. []string{"pin", "add"}).
+func (t *Token) Allows(cmdPath []string) bool {
+	p := strings.Join(cmdPath, "/")
+	for _, sc := range t.Scopes {
+		if sc == ScopeAdmin {
+			return true
+		}
+		for _, allowed := range scopeCommands[sc] {
+			if MatchCommand(allowed, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchCommand returns whether pattern covers the slash separated command
+// path p. A pattern matches the command itself and all of its subcommands.
+func MatchCommand(pattern, p string) bool {
+	pattern = strings.Trim(pattern, "/")
+	p = strings.Trim(p, "/")
+	if pattern == "" {
+		return true
+	}
+	return p == pattern || strings.HasPrefix(p, pattern+"/")
+}
+
+// GenerateSecret returns a new random token secret.
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashSecret returns the hash of a token secret, as stored in the config.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticator checks secrets presented by API clients against a set of
+// configured tokens.
+type Authenticator struct {
+	tokens []*Token
+}
+
+// NewAuthenticator returns an Authenticator for the given tokens.
+func NewAuthenticator(tokens []Token) (*Authenticator, error) {
+	a := &Authenticator{}
+	for i := range tokens {
+		t := tokens[i]
+		if t.Hash == "" {
+			return nil, fmt.Errorf("API token %q has no hash", t.Name)
+		}
+		for _, sc := range t.Scopes {
+			if _, err := ParseScope(string(sc)); err != nil {
+				return nil, fmt.Errorf("API token %q: %s", t.Name, err)
+			}
+		}
+		a.tokens = append(a.tokens, &t)
+	}
+
+	sort.Slice(a.tokens, func(i, j int) bool {
+		return a.tokens[i].Name < a.tokens[j].Name
+	})
+	return a, nil
+}
+
+// Enabled returns whether any token is configured. When no token is
+// configured the API is left unauthenticated.
+func (a *Authenticator) Enabled() bool {
+	return a != nil && len(a.tokens) > 0
+}
+
+// Authenticate returns the token matching the given secret, if any.
+func (a *Authenticator) Authenticate(secret string) (*Token, bool) {
+	if a == nil || secret == "" {
+		return nil, false
+	}
+
+	h := []byte(HashSecret(secret))
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare(h, []byte(t.Hash)) == 1 {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// SecretFromRequest extracts the bearer secret from an HTTP request.
+func SecretFromRequest(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	const prefix = "bearer "
+	if len(h) > len(prefix) && strings.ToLower(h[:len(prefix)]) == prefix {
+		return strings.TrimSpace(h[len(prefix):])
+	}
+	return ""
+}
+
+// AnyIdentity is the policy identity matching every caller, authenticated or
+// not.
+const AnyIdentity = "*"
+
+// Rule restricts the commands an identity may invoke. Commands are given as
+// slash or space separated paths (e.g. "repo/gc" or "repo gc") and match all
+// of their subcommands.
+type Rule struct {
+	Allow []string
+	Deny  []string
+}
+
+// Permits returns whether the rule allows invoking the command at cmdPath.
+// Denied commands take precedence, an empty Allow list allows everything not
+// denied.
+func (r Rule) Permits(cmdPath []string) bool {
+	p := strings.Join(cmdPath, "/")
+	for _, d := range r.Deny {
+		if MatchCommand(normalizeCommand(d), p) {
+			return false
+		}
+	}
+	if len(r.Allow) == 0 {
+		return true
+	}
+	for _, a := range r.Allow {
+		if MatchCommand(normalizeCommand(a), p) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeCommand(c string) string {
+	return strings.Join(strings.Fields(strings.Replace(c, "/", " ", -1)), "/")
+}
+
+// Policy maps identities (token names, or AnyIdentity) to the rules which
+// apply to them.
+type Policy map[string]Rule
+
+// Permits returns whether identity may invoke the command at cmdPath. Both
+// the AnyIdentity rule and the rule for the identity itself must permit it.
+// An empty identity denotes an unauthenticated caller.
+func (p Policy) Permits(identity string, cmdPath []string) bool {
+	if r, ok := p[AnyIdentity]; ok && !r.Permits(cmdPath) {
+		return false
+	}
+	if identity == "" || identity == AnyIdentity {
+		return true
+	}
+	if r, ok := p[identity]; ok && !r.Permits(cmdPath) {
+		return false
+	}
+	return true
+}
+
+type tokenKey struct{}
+
+// WithToken returns a context carrying the authenticated token.
+func WithToken(ctx context.Context, t *Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, t)
+}
+
+// FromContext returns the token the request was authenticated with, if any.
+func FromContext(ctx context.Context) (*Token, bool) {
+	t, ok := ctx.Value(tokenKey{}).(*Token)
+	return t, ok
+}
+
+// Quota is the storage quota of the identity a request was made by: the
+// number of bytes of the DAGs it may add and pin.
+type Quota struct {
+	Identity string
+	Bytes    uint64
+}
+
+type quotaKey struct{}
+
+// WithQuota returns a context carrying the storage quota of the request.
+func WithQuota(ctx context.Context, q *Quota) context.Context {
+	return context.WithValue(ctx, quotaKey{}, q)
+}
+
+// QuotaFromContext returns the storage quota of the request, if it has one.
+func QuotaFromContext(ctx context.Context) (*Quota, bool) {
+	q, ok := ctx.Value(quotaKey{}).(*Quota)
+	if !ok || q.Bytes == 0 {
+		return nil, false
+	}
+	return q, true
+}
@@ -0,0 +1,139 @@
+package apiauth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewAuthenticator([]Token{
+		{Name: "ro", Hash: HashSecret(secret), Scopes: []Scope{ScopeRead}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Enabled() {
+		t.Fatal("expected authenticator to be enabled")
+	}
+
+	tok, ok := a.Authenticate(secret)
+	if !ok || tok.Name != "ro" {
+		t.Fatalf("expected to authenticate as 'ro', got %v", tok)
+	}
+
+	if _, ok := a.Authenticate(secret + "x"); ok {
+		t.Fatal("authenticated with a wrong secret")
+	}
+	if _, ok := a.Authenticate(""); ok {
+		t.Fatal("authenticated with an empty secret")
+	}
+}
+
+func TestNewAuthenticatorInvalid(t *testing.T) {
+	if _, err := NewAuthenticator([]Token{{Name: "a", Scopes: []Scope{ScopeRead}}}); err == nil {
+		t.Fatal("expected error for token without hash")
+	}
+	if _, err := NewAuthenticator([]Token{{Name: "a", Hash: "x", Scopes: []Scope{"root"}}}); err == nil {
+		t.Fatal("expected error for unknown scope")
+	}
+
+	var a *Authenticator
+	if a.Enabled() {
+		t.Fatal("nil authenticator must be disabled")
+	}
+}
+
+func TestScopes(t *testing.T) {
+	cases := []struct {
+		scopes []Scope
+		path   []string
+		ok     bool
+	}{
+		{[]Scope{ScopeRead}, []string{"cat"}, true},
+		{[]Scope{ScopeRead}, []string{"pin", "ls"}, true},
+		{[]Scope{ScopeRead}, []string{"pin", "add"}, false},
+		{[]Scope{ScopeRead}, []string{"config"}, false},
+		{[]Scope{ScopeRead}, []string{"catalog"}, false},
+		{[]Scope{ScopePin}, []string{"pin", "add"}, true},
+		{[]Scope{ScopePin}, []string{"cat"}, false},
+		{[]Scope{ScopeRead, ScopePin}, []string{"cat"}, true},
+		{[]Scope{ScopeAdmin}, []string{"shutdown"}, true},
+		{nil, []string{"version"}, false},
+	}
+
+	for _, c := range cases {
+		tok := &Token{Scopes: c.scopes}
+		if tok.Allows(c.path) != c.ok {
+			t.Errorf("scopes %v, path %v: expected %t", c.scopes, c.path, c.ok)
+		}
+	}
+}
+
+func TestSecretFromRequest(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/api/v0/cat", nil)
+	if s := SecretFromRequest(r); s != "" {
+		t.Fatalf("expected no secret, got %q", s)
+	}
+
+	r.Header.Set("Authorization", "Bearer abc")
+	if s := SecretFromRequest(r); s != "abc" {
+		t.Fatalf("expected 'abc', got %q", s)
+	}
+
+	r.Header.Set("Authorization", "Basic abc")
+	if s := SecretFromRequest(r); s != "" {
+		t.Fatalf("expected no secret for basic auth, got %q", s)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Fatal("unexpected token in empty context")
+	}
+
+	tok := &Token{Name: "x"}
+	got, ok := FromContext(WithToken(context.Background(), tok))
+	if !ok || got != tok {
+		t.Fatal("expected token from context")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p := Policy{
+		AnyIdentity: {Deny: []string{"shutdown", "repo gc"}},
+		"tenant":    {Allow: []string{"cat", "pin/ls"}, Deny: []string{"cat/secret"}},
+	}
+
+	cases := []struct {
+		identity string
+		path     []string
+		ok       bool
+	}{
+		{"", []string{"shutdown"}, false},
+		{"", []string{"repo", "gc"}, false},
+		{"", []string{"repo", "stat"}, true},
+		{"admin", []string{"config"}, true},
+		{"admin", []string{"repo", "gc"}, false},
+		{"tenant", []string{"cat"}, true},
+		{"tenant", []string{"pin", "ls"}, true},
+		{"tenant", []string{"pin", "add"}, false},
+		{"tenant", []string{"cat", "secret"}, false},
+	}
+
+	for _, c := range cases {
+		if p.Permits(c.identity, c.path) != c.ok {
+			t.Errorf("identity %q, path %v: expected %t", c.identity, c.path, c.ok)
+		}
+	}
+
+	var empty Policy
+	if !empty.Permits("", []string{"shutdown"}) {
+		t.Fatal("empty policy must permit everything")
+	}
+}
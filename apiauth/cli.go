@@ -0,0 +1,32 @@
+package apiauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// CLIHeader is the header in which the ipfs command sends the proof, as
+// returned by CLIProof, that the requests come from it rather than from
+// another client of the API.
+const CLIHeader = "X-Ipfs-Cli"
+
+// CLIProof returns the proof of the requests of the ipfs command to the node
+// whose private key, as found in its config, is privKey. Only the clients
+// which can read the config of the node can compute it.
+func CLIProof(privKey string) string {
+	mac := hmac.New(sha256.New, []byte(privKey))
+	mac.Write([]byte("ipfs cli"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsCLI returns whether r carries the proof that it comes from the ipfs
+// command, for the node of private key privKey.
+func IsCLI(r *http.Request, privKey string) bool {
+	proof := r.Header.Get(CLIHeader)
+	if proof == "" || privKey == "" {
+		return false
+	}
+	return hmac.Equal([]byte(proof), []byte(CLIProof(privKey)))
+}
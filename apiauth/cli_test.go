@@ -0,0 +1,27 @@
+package apiauth
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIsCLI(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/api/v0/pin/add", nil)
+	r.Header.Set("User-Agent", "/go-ipfs/0.4.14/")
+	if IsCLI(r, "key") {
+		t.Fatal("expected the user agent not to be trusted")
+	}
+
+	r.Header.Set(CLIHeader, CLIProof("other key"))
+	if IsCLI(r, "key") {
+		t.Fatal("expected the proof for another key to be rejected")
+	}
+
+	r.Header.Set(CLIHeader, CLIProof("key"))
+	if !IsCLI(r, "key") {
+		t.Fatal("expected the proof to be accepted")
+	}
+	if IsCLI(r, "") {
+		t.Fatal("expected no proof to be accepted without a key")
+	}
+}
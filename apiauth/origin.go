@@ -0,0 +1,85 @@
+package apiauth
+
+import (
+	"net/url"
+	"strings"
+)
+
+// OriginRule allows browser requests from the origins matching Pattern.
+//
+// Patterns are either a full origin ("https://webui.example.com"), "*" for
+// any origin, or contain a single '*' wildcard in the host part, e.g.
+// "chrome-extension://*" or "https://*.example.com".
+type OriginRule struct {
+	Pattern string
+
+	// AllowCredentials allows the browser to send cookies and
+	// authorization headers along with requests from the origin.
+	AllowCredentials bool
+
+	// ReadOnly restricts the origin to commands which don't change the state
+	// of the node.
+	ReadOnly bool
+}
+
+// OriginPolicy is an ordered list of origin rules, the first matching rule
+// applies.
+type OriginPolicy []OriginRule
+
+// Lookup returns the first rule matching origin.
+func (p OriginPolicy) Lookup(origin string) (OriginRule, bool) {
+	for _, r := range p {
+		if MatchOrigin(r.Pattern, origin) {
+			return r, true
+		}
+	}
+	return OriginRule{}, false
+}
+
+// MatchOrigin returns whether origin matches the given pattern.
+func MatchOrigin(pattern, origin string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	origin = strings.TrimSuffix(origin, "/")
+	if pattern == "*" || strings.EqualFold(pattern, origin) {
+		return true
+	}
+
+	i := strings.IndexByte(pattern, '*')
+	if i < 0 || strings.IndexByte(pattern[i+1:], '*') >= 0 {
+		return false
+	}
+	prefix, suffix := strings.ToLower(pattern[:i]), strings.ToLower(pattern[i+1:])
+	origin = strings.ToLower(origin)
+	if len(origin) < len(prefix)+len(suffix) ||
+		!strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+
+	// the wildcard must not match across the scheme separator
+	wild := origin[len(prefix) : len(origin)-len(suffix)]
+	return wild != "" && !strings.Contains(wild, "/")
+}
+
+// RequestOrigin returns the origin of a browser request, taken from the
+// Origin header or, when missing, derived from the Referer header. It
+// returns "" for requests which don't come from a browser page.
+func RequestOrigin(origin, referer string) string {
+	if origin != "" {
+		return origin
+	}
+	if referer == "" {
+		return ""
+	}
+	u, err := url.Parse(referer)
+	if err != nil || u.Scheme == "" {
+		return "null"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// IsReadOnly returns whether the command at cmdPath only reads data from the
+// node, and is therefore safe to be invoked cross-origin.
+func IsReadOnly(cmdPath []string) bool {
+	t := &Token{Scopes: []Scope{ScopeRead}}
+	return t.Allows(cmdPath)
+}
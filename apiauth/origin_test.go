@@ -0,0 +1,73 @@
+package apiauth
+
+import "testing"
+
+func TestMatchOrigin(t *testing.T) {
+	cases := []struct {
+		pattern, origin string
+		ok              bool
+	}{
+		{"*", "https://evil.com", true},
+		{"http://localhost:5001", "http://localhost:5001", true},
+		{"http://localhost:5001", "http://localhost:5002", false},
+		{"chrome-extension://*", "chrome-extension://nibjojkomfdiaoajekhjakgkdhaomnch", true},
+		{"chrome-extension://*", "moz-extension://abc", false},
+		{"chrome-extension://*", "chrome-extension://", false},
+		{"https://*.example.com", "https://webui.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "https://evil.com/.example.com", false},
+		{"https://*.example.com", "http://webui.example.com", false},
+		{"https://*.*.com", "https://a.b.com", false},
+	}
+
+	for _, c := range cases {
+		if MatchOrigin(c.pattern, c.origin) != c.ok {
+			t.Errorf("pattern %q, origin %q: expected %t", c.pattern, c.origin, c.ok)
+		}
+	}
+}
+
+func TestOriginPolicyLookup(t *testing.T) {
+	p := OriginPolicy{
+		{Pattern: "https://webui.example.com", AllowCredentials: true},
+		{Pattern: "https://*.example.com", ReadOnly: true},
+	}
+
+	r, ok := p.Lookup("https://webui.example.com")
+	if !ok || !r.AllowCredentials || r.ReadOnly {
+		t.Fatalf("unexpected rule: %v %t", r, ok)
+	}
+	r, ok = p.Lookup("https://other.example.com")
+	if !ok || !r.ReadOnly {
+		t.Fatalf("unexpected rule: %v %t", r, ok)
+	}
+	if _, ok := p.Lookup("https://evil.com"); ok {
+		t.Fatal("expected no rule for unknown origin")
+	}
+}
+
+func TestRequestOrigin(t *testing.T) {
+	cases := []struct {
+		origin, referer, out string
+	}{
+		{"", "", ""},
+		{"https://a.com", "https://b.com/x", "https://a.com"},
+		{"", "https://b.com/some/page?q=1", "https://b.com"},
+		{"null", "", "null"},
+		{"", "::not a url", "null"},
+	}
+	for _, c := range cases {
+		if o := RequestOrigin(c.origin, c.referer); o != c.out {
+			t.Errorf("RequestOrigin(%q, %q) = %q, expected %q", c.origin, c.referer, o, c.out)
+		}
+	}
+}
+
+func TestIsReadOnly(t *testing.T) {
+	if !IsReadOnly([]string{"cat"}) {
+		t.Fatal("cat must be read-only")
+	}
+	if IsReadOnly([]string{"shutdown"}) {
+		t.Fatal("shutdown must not be read-only")
+	}
+}
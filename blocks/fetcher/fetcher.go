@@ -0,0 +1,109 @@
+// Package fetcher is a minimal interface for getting blocks from anywhere,
+// like a local store, an HTTP gateway or the fetch API of a browser, and
+// for using them as a verified, read-only DAG.
+//
+// It doesn't depend on the network stack of the node, so it compiles under
+// js/wasm along with the packages using the DAG it makes, like the unixfs
+// reader (unixfs/io) and the path resolver (path/resolver):
+//
+//	ng := fetcher.NewNodeGetter(fetcher.Func(get))
+//	r := resolver.NewBasicResolver(dag.NewReadOnlyDagService(ng))
+//	nd, err := r.ResolvePath(ctx, p)
+//	...
+//	rd, err := uio.NewDagReader(ctx, nd, ng)
+package fetcher
+
+import (
+	"context"
+	"fmt"
+
+	// registers the decoders of the node types
+	_ "github.com/ipfs/go-ipfs/merkledag/dagnode"
+	"github.com/ipfs/go-ipfs/thirdparty/verifcid"
+
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// Fetcher gets the data of blocks. It doesn't need to be trusted: the data
+// it returns is checked against the CID before it's used.
+type Fetcher interface {
+	// Fetch returns the data of the block of c.
+	Fetch(ctx context.Context, c *cid.Cid) ([]byte, error)
+}
+
+// Func is a function used as a Fetcher.
+type Func func(ctx context.Context, c *cid.Cid) ([]byte, error)
+
+// Fetch calls f.
+func (f Func) Fetch(ctx context.Context, c *cid.Cid) ([]byte, error) {
+	return f(ctx, c)
+}
+
+// Verify returns the block of data if its hash matches c, and c uses a hash
+// function considered secure.
+func Verify(data []byte, c *cid.Cid) (blocks.Block, error) {
+	if err := verifcid.ValidateCid(c); err != nil {
+		return nil, err
+	}
+
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, fmt.Errorf("hash mismatch: expected %s, got %s", c, sum)
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
+
+// nodeGetter gets nodes from a Fetcher.
+type nodeGetter struct {
+	f Fetcher
+}
+
+// NewNodeGetter returns a NodeGetter getting the blocks of the nodes with f,
+// and verifying them.
+func NewNodeGetter(f Fetcher) ipld.NodeGetter {
+	return &nodeGetter{f: f}
+}
+
+func (ng *nodeGetter) Get(ctx context.Context, c *cid.Cid) (ipld.Node, error) {
+	data, err := ng.f.Fetch(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := Verify(data, c)
+	if err != nil {
+		return nil, err
+	}
+	return ipld.Decode(b)
+}
+
+func (ng *nodeGetter) GetMany(ctx context.Context, keys []*cid.Cid) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption, len(keys))
+	go func() {
+		defer close(out)
+
+		results := make(chan *ipld.NodeOption, len(keys))
+		for _, c := range keys {
+			go func(c *cid.Cid) {
+				nd, err := ng.Get(ctx, c)
+				results <- &ipld.NodeOption{Node: nd, Err: err}
+			}(c)
+		}
+
+		for range keys {
+			select {
+			case res := <-results:
+				out <- res
+			case <-ctx.Done():
+				out <- &ipld.NodeOption{Err: ctx.Err()}
+				return
+			}
+		}
+	}()
+	return out
+}
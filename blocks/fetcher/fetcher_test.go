@@ -0,0 +1,85 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	dag "github.com/ipfs/go-ipfs/merkledag/dagnode"
+	path "github.com/ipfs/go-ipfs/path"
+	resolver "github.com/ipfs/go-ipfs/path/resolver"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+func mapFetcher(nodes ...ipld.Node) map[string][]byte {
+	m := make(map[string][]byte)
+	for _, nd := range nodes {
+		m[nd.Cid().KeyString()] = nd.RawData()
+	}
+	return m
+}
+
+func fetchFrom(m map[string][]byte) Func {
+	return func(ctx context.Context, c *cid.Cid) ([]byte, error) {
+		data, ok := m[c.KeyString()]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return data, nil
+	}
+}
+
+func TestResolveAndRead(t *testing.T) {
+	ctx := context.Background()
+
+	content := []byte("hello from the browser")
+	file := dag.NodeWithData(ft.FilePBData(content, uint64(len(content))))
+	dir := dag.NodeWithData(ft.FolderPBData())
+	if err := dir.AddNodeLink("hello.txt", file); err != nil {
+		t.Fatal(err)
+	}
+
+	ng := NewNodeGetter(fetchFrom(mapFetcher(file, dir)))
+	r := resolver.NewBasicResolver(dag.NewReadOnlyDagService(ng))
+
+	p, err := path.ParsePath("/ipfs/" + dir.Cid().String() + "/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := r.ResolvePath(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(file.Cid()) {
+		t.Fatalf("resolved to %s, expected %s", nd.Cid(), file.Cid())
+	}
+
+	rd, err := uio.NewDagReader(ctx, nd, ng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, content) {
+		t.Fatalf("read %q, expected %q", out, content)
+	}
+}
+
+func TestRejectTamperedBlocks(t *testing.T) {
+	nd := dag.NodeWithData(ft.FilePBData([]byte("genuine"), 7))
+	bad := dag.NodeWithData(ft.FilePBData([]byte("forged!"), 7))
+
+	m := map[string][]byte{nd.Cid().KeyString(): bad.RawData()}
+	ng := NewNodeGetter(fetchFrom(m))
+	if _, err := ng.Get(context.Background(), nd.Cid()); err == nil {
+		t.Fatal("expected a block not matching its CID to be rejected")
+	}
+}
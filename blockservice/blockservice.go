@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync/atomic"
 
 	"github.com/ipfs/go-ipfs/thirdparty/verifcid"
 
@@ -113,18 +114,21 @@ func (s *blockService) Exchange() exchange.Interface {
 // session will be created. Otherwise, the current exchange will be used
 // directly.
 func NewSession(ctx context.Context, bs BlockService) *Session {
+	s := &Session{
+		bs: bs.Blockstore(),
+	}
+
 	exch := bs.Exchange()
-	if sessEx, ok := exch.(exchange.SessionExchange); ok {
-		ses := sessEx.NewSession(ctx)
-		return &Session{
-			ses: ses,
-			bs:  bs.Blockstore(),
-		}
+	if exch == nil {
+		return s
 	}
-	return &Session{
-		ses: exch,
-		bs:  bs.Blockstore(),
+
+	var ses exchange.Fetcher = exch
+	if sessEx, ok := exch.(exchange.SessionExchange); ok {
+		ses = sessEx.NewSession(ctx)
 	}
+	s.ses = &countingFetcher{Fetcher: ses, stat: &s.stat}
+	return s
 }
 
 // AddBlock adds a particular block to the service, Putting it into the datastore.
@@ -216,6 +220,7 @@ func getBlock(ctx context.Context, c *cid.Cid, bs blockstore.Blockstore, f excha
 
 	block, err := bs.Get(c)
 	if err == nil {
+		reportProgress(ctx, block)
 		return block, nil
 	}
 
@@ -231,6 +236,7 @@ func getBlock(ctx context.Context, c *cid.Cid, bs blockstore.Blockstore, f excha
 			return nil, err
 		}
 		log.Event(ctx, "BlockService.BlockFetched", c)
+		reportProgress(ctx, blk)
 		return blk, nil
 	}
 
@@ -274,6 +280,7 @@ func getBlocks(ctx context.Context, ks []*cid.Cid, bs blockstore.Blockstore, f e
 				misses = append(misses, c)
 				continue
 			}
+			reportProgress(ctx, hit)
 			select {
 			case out <- hit:
 			case <-ctx.Done():
@@ -293,6 +300,7 @@ func getBlocks(ctx context.Context, ks []*cid.Cid, bs blockstore.Blockstore, f e
 
 		for b := range rblocks {
 			log.Event(ctx, "BlockService.BlockFetched", b.Cid())
+			reportProgress(ctx, b)
 			select {
 			case out <- b:
 			case <-ctx.Done():
@@ -319,8 +327,64 @@ func (s *blockService) Close() error {
 
 // Session is a helper type to provide higher level access to bitswap sessions
 type Session struct {
-	bs  blockstore.Blockstore
-	ses exchange.Fetcher
+	bs   blockstore.Blockstore
+	ses  exchange.Fetcher
+	stat SessionStat
+}
+
+// SessionStat reports on the blocks a session fetched through the exchange,
+// leaving out the ones it found locally.
+type SessionStat struct {
+	Blocks uint64
+	Bytes  uint64
+}
+
+// Stat returns the blocks fetched through the exchange so far.
+func (s *Session) Stat() SessionStat {
+	return SessionStat{
+		Blocks: atomic.LoadUint64(&s.stat.Blocks),
+		Bytes:  atomic.LoadUint64(&s.stat.Bytes),
+	}
+}
+
+// countingFetcher counts the blocks fetched through an exchange.
+type countingFetcher struct {
+	exchange.Fetcher
+	stat *SessionStat
+}
+
+func (f *countingFetcher) count(b blocks.Block) {
+	atomic.AddUint64(&f.stat.Blocks, 1)
+	atomic.AddUint64(&f.stat.Bytes, uint64(len(b.RawData())))
+}
+
+func (f *countingFetcher) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
+	b, err := f.Fetcher.GetBlock(ctx, c)
+	if err == nil {
+		f.count(b)
+	}
+	return b, err
+}
+
+func (f *countingFetcher) GetBlocks(ctx context.Context, ks []*cid.Cid) (<-chan blocks.Block, error) {
+	in, err := f.Fetcher.GetBlocks(ctx, ks)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		for b := range in {
+			f.count(b)
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
 }
 
 // GetBlock gets a block in the context of a request session
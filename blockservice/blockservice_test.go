@@ -1,6 +1,7 @@
 package blockservice
 
 import (
+	"context"
 	"testing"
 
 	offline "gx/ipfs/QmPf114DXfa6TqGKYhBGR7EtXRho4rCJgwyA1xkuMY5vwF/go-ipfs-exchange-offline"
@@ -35,6 +36,36 @@ func TestWriteThroughWorks(t *testing.T) {
 	}
 }
 
+func TestSessionStat(t *testing.T) {
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	remote := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	bserv := New(bstore, offline.Exchange(remote))
+	bgen := butil.NewBlockGenerator()
+
+	local := bgen.Next()
+	if err := bstore.Put(local); err != nil {
+		t.Fatal(err)
+	}
+	fetched := bgen.Next()
+	if err := remote.Put(fetched); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	ses := NewSession(ctx, bserv)
+	if _, err := ses.GetBlock(ctx, local.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ses.GetBlock(ctx, fetched.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	stat := ses.Stat()
+	if stat.Blocks != 1 || stat.Bytes != uint64(len(fetched.RawData())) {
+		t.Fatalf("expected one fetched block of %d bytes, got %+v", len(fetched.RawData()), stat)
+	}
+}
+
 var _ blockstore.Blockstore = (*PutCountingBlockstore)(nil)
 
 type PutCountingBlockstore struct {
@@ -0,0 +1,23 @@
+package blockservice
+
+import (
+	"context"
+
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+)
+
+type progressKey struct{}
+
+// WithProgress returns a context under which the blocks got by the
+// blockservice, from the blockstore or the exchange, are passed to f. f is
+// called from the goroutines getting the blocks, and must not block.
+func WithProgress(ctx context.Context, f func(blocks.Block)) context.Context {
+	return context.WithValue(ctx, progressKey{}, f)
+}
+
+// reportProgress passes b to the function registered in ctx, if any.
+func reportProgress(ctx context.Context, b blocks.Block) {
+	if f, ok := ctx.Value(progressKey{}).(func(blocks.Block)); ok {
+		f(b)
+	}
+}
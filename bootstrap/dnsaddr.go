@@ -0,0 +1,216 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+)
+
+const (
+	dnsaddrPrefix = "/dnsaddr/"
+	// maxDNSAddrDepth is the number of nested /dnsaddr records followed.
+	maxDNSAddrDepth = 4
+	// resolveTimeout is how long the resolution of an entry may take.
+	resolveTimeout = 10 * time.Second
+)
+
+func lookupTXT(ctx context.Context, name string) ([]string, error) {
+	return net.DefaultResolver.LookupTXT(ctx, name)
+}
+
+// Expand returns pis with their /dnsaddr addresses resolved, concurrently.
+// The addresses resolved are cached for DNSAddrTTL, and kept past it while
+// the lookups fail. The /dnsaddr addresses which can't be resolved are kept
+// as is.
+func (h *Health) Expand(ctx context.Context, pis []pstore.PeerInfo) []pstore.PeerInfo {
+	type entry struct {
+		addr  string
+		p     peer.ID
+		addrs []string
+	}
+	entries := make(map[string]*entry)
+	for _, pi := range pis {
+		for _, a := range pi.Addrs {
+			if s := a.String(); strings.HasPrefix(s, dnsaddrPrefix) {
+				entries[dnsaddrKey(s, pi.ID)] = &entry{addr: s, p: pi.ID}
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		wg.Add(1)
+		go func(e *entry) {
+			defer wg.Done()
+			var err error
+			e.addrs, err = h.resolve(ctx, e.addr, e.p)
+			if err != nil {
+				log.Warningf("failed to resolve the bootstrap address %s: %s", e.addr, err)
+			}
+		}(e)
+	}
+	wg.Wait()
+
+	return expand(pis, func(addr string, p peer.ID) ([]string, bool) {
+		addrs := entries[dnsaddrKey(addr, p)].addrs
+		return addrs, len(addrs) == 0
+	})
+}
+
+// Cached returns pis with their /dnsaddr addresses replaced by the cached
+// addresses they resolve to, without looking them up. The /dnsaddr addresses
+// without an unexpired resolution are kept as is, for the dialer to resolve
+// them.
+func (h *Health) Cached(pis []pstore.PeerInfo) []pstore.PeerInfo {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return expand(pis, func(addr string, p peer.ID) ([]string, bool) {
+		r := h.rec.DNSAddr[dnsaddrKey(addr, p)]
+		if r == nil {
+			return nil, true
+		}
+		return r.Addrs, !h.now().Before(r.Expires)
+	})
+}
+
+// expand replaces the /dnsaddr addresses of pis by the addresses resolved
+// returns for them, keeping them as well when it says so.
+func expand(pis []pstore.PeerInfo, resolved func(addr string, p peer.ID) ([]string, bool)) []pstore.PeerInfo {
+	out := make([]pstore.PeerInfo, 0, len(pis))
+	for _, pi := range pis {
+		exp := pstore.PeerInfo{ID: pi.ID}
+		for _, a := range pi.Addrs {
+			s := a.String()
+			if !strings.HasPrefix(s, dnsaddrPrefix) {
+				exp.Addrs = append(exp.Addrs, a)
+				continue
+			}
+
+			addrs, keep := resolved(s, pi.ID)
+			if keep {
+				exp.Addrs = append(exp.Addrs, a)
+			}
+			for _, r := range addrs {
+				m, err := ma.NewMultiaddr(r)
+				if err != nil {
+					continue
+				}
+				exp.Addrs = append(exp.Addrs, m)
+			}
+		}
+		out = append(out, exp)
+	}
+	return out
+}
+
+// prune drops the cached resolutions of the /dnsaddr addresses which aren't
+// in pis anymore.
+func (h *Health) prune(pis []pstore.PeerInfo) {
+	keep := make(map[string]bool)
+	for _, pi := range pis {
+		for _, a := range pi.Addrs {
+			keep[dnsaddrKey(a.String(), pi.ID)] = true
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for k := range h.rec.DNSAddr {
+		if !keep[k] {
+			delete(h.rec.DNSAddr, k)
+		}
+	}
+}
+
+func dnsaddrKey(addr string, p peer.ID) string {
+	return addr + "/ipfs/" + p.Pretty()
+}
+
+// resolve returns the addresses of p addr resolves to, from the cache when
+// they didn't expire. The expired ones are returned along with the error
+// when the lookup fails.
+func (h *Health) resolve(ctx context.Context, addr string, p peer.ID) ([]string, error) {
+	cacheKey := dnsaddrKey(addr, p)
+
+	h.mu.Lock()
+	cached := h.rec.DNSAddr[cacheKey]
+	h.mu.Unlock()
+	if cached != nil && h.now().Before(cached.Expires) {
+		return cached.Addrs, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	addrs, err := h.resolveDNSAddr(ctx, addr, p, 0)
+	if err == nil && len(addrs) == 0 {
+		err = fmt.Errorf("no address found for %s", p.Pretty())
+	}
+	if err != nil {
+		if cached != nil {
+			return cached.Addrs, err
+		}
+		return nil, err
+	}
+
+	h.mu.Lock()
+	h.rec.DNSAddr[cacheKey] = &resolution{Addrs: addrs, Expires: h.now().Add(h.DNSAddrTTL)}
+	h.mu.Unlock()
+	return addrs, nil
+}
+
+// resolveDNSAddr resolves a /dnsaddr/<domain> address from the
+// dnsaddr=<multiaddr> TXT records of _dnsaddr.<domain>, keeping the
+// addresses of p only.
+func (h *Health) resolveDNSAddr(ctx context.Context, addr string, p peer.ID, depth int) ([]string, error) {
+	if depth >= maxDNSAddrDepth {
+		return nil, fmt.Errorf("too many nested /dnsaddr records at %s", addr)
+	}
+
+	domain := strings.SplitN(strings.TrimPrefix(addr, dnsaddrPrefix), "/", 2)[0]
+	txts, err := h.lookupTXT(ctx, "_dnsaddr."+domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, txt := range txts {
+		if !strings.HasPrefix(txt, "dnsaddr=") {
+			continue
+		}
+		rec, id := splitPeer(strings.TrimPrefix(txt, "dnsaddr="))
+		if id != "" && id != p.Pretty() {
+			// an address of another peer
+			continue
+		}
+
+		if strings.HasPrefix(rec, dnsaddrPrefix) {
+			addrs, err := h.resolveDNSAddr(ctx, rec, p, depth+1)
+			if err != nil {
+				log.Debugf("failed to resolve %s: %s", rec, err)
+				continue
+			}
+			out = append(out, addrs...)
+			continue
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+// splitPeer splits the /ipfs/<id> suffix off addr.
+func splitPeer(addr string) (string, string) {
+	for _, proto := range []string{"/ipfs/", "/p2p/"} {
+		if i := strings.LastIndex(addr, proto); i >= 0 {
+			return addr[:i], addr[i+len(proto):]
+		}
+	}
+	return addr, ""
+}
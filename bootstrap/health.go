@@ -0,0 +1,293 @@
+// Package bootstrap keeps the health of the bootstrap peers of a node: it
+// probes them periodically, keeps the history of their reachability, tells
+// the ones which persistently fail to answer, and caches the addresses their
+// /dnsaddr entries resolve to.
+//
+// The history and the cached addresses are saved as a JSON record under
+// /local/bootstrap in the datastore.
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	goprocess "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+)
+
+var log = logging.Logger("bootstrap")
+
+var key = ds.NewKey("/local/bootstrap")
+
+const (
+	// DefaultInterval is how often the peers are probed by default.
+	DefaultInterval = time.Hour
+	// DefaultDeadAfter is the number of consecutive failed probes after
+	// which a peer is dead, by default.
+	DefaultDeadAfter = 5
+	// DefaultDNSAddrTTL is how long the /dnsaddr resolutions are cached by
+	// default.
+	DefaultDNSAddrTTL = time.Hour
+
+	// MaxChecks is the number of checks kept for each peer.
+	MaxChecks = 24
+	// ProbeTimeout is how long a probe waits for a peer to answer.
+	ProbeTimeout = 15 * time.Second
+
+	// firstCheckDelay leaves time for the node to bootstrap before the
+	// first probes.
+	firstCheckDelay = time.Minute
+)
+
+// Check is the result of a probe of a peer.
+type Check struct {
+	Time    time.Time
+	OK      bool
+	Latency time.Duration `json:",omitempty"`
+	Error   string        `json:",omitempty"`
+}
+
+// History is the reachability history of a peer.
+type History struct {
+	// Checks are the last MaxChecks, oldest first.
+	Checks []Check
+	// Failures is the number of consecutive failed checks.
+	Failures int
+	LastOK   time.Time `json:",omitempty"`
+}
+
+type resolution struct {
+	Addrs   []string
+	Expires time.Time
+}
+
+type record struct {
+	// Peers are keyed by their base58 ID.
+	Peers   map[string]*History
+	DNSAddr map[string]*resolution `json:",omitempty"`
+}
+
+// Status is the health of a bootstrap peer.
+type Status struct {
+	ID string
+	// Addrs are the addresses the peer is dialed at, with the /dnsaddr
+	// ones resolved.
+	Addrs []string
+	// Last is the last check of the peer, if any.
+	Last *Check `json:",omitempty"`
+	// Checks and OK are the number of checks kept and of successful ones.
+	Checks   int
+	OK       int
+	Failures int
+	LastOK   time.Time `json:",omitempty"`
+	Dead     bool
+}
+
+// Health keeps the health of the bootstrap peers of a node.
+type Health struct {
+	ds ds.Datastore
+
+	// Peers returns the bootstrap peers.
+	Peers func() []pstore.PeerInfo
+	// Probe checks whether a peer answers, returning its latency.
+	Probe func(ctx context.Context, pi pstore.PeerInfo) (time.Duration, error)
+	// Interval is how often Run probes the peers.
+	Interval time.Duration
+	// DeadAfter is the number of consecutive failed probes after which a
+	// peer is dead, never when zero.
+	DeadAfter int
+	// DNSAddrTTL is how long the /dnsaddr resolutions are cached.
+	DNSAddrTTL time.Duration
+
+	lookupTXT func(ctx context.Context, name string) ([]string, error)
+	now       func() time.Time
+
+	mu  sync.Mutex
+	rec record
+}
+
+// New returns the Health of the bootstrap peers, loading their history from
+// d.
+func New(d ds.Datastore) (*Health, error) {
+	h := &Health{
+		ds:         d,
+		Interval:   DefaultInterval,
+		DeadAfter:  DefaultDeadAfter,
+		DNSAddrTTL: DefaultDNSAddrTTL,
+		lookupTXT:  lookupTXT,
+		now:        time.Now,
+	}
+
+	b, err := d.Get(key)
+	switch err {
+	case nil:
+		if data, ok := b.([]byte); ok {
+			if err := json.Unmarshal(data, &h.rec); err != nil {
+				log.Warning("ignoring the invalid bootstrap health record: ", err)
+				h.rec = record{}
+			}
+		}
+	case ds.ErrNotFound:
+	default:
+		return nil, err
+	}
+	if h.rec.Peers == nil {
+		h.rec.Peers = make(map[string]*History)
+	}
+	if h.rec.DNSAddr == nil {
+		h.rec.DNSAddr = make(map[string]*resolution)
+	}
+	return h, nil
+}
+
+// Record records the result of a probe of p.
+func (h *Health) Record(p peer.ID, latency time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hist := h.rec.Peers[p.Pretty()]
+	if hist == nil {
+		hist = new(History)
+		h.rec.Peers[p.Pretty()] = hist
+	}
+
+	c := Check{Time: h.now().UTC().Truncate(time.Second), OK: err == nil}
+	if err == nil {
+		c.Latency = latency
+		hist.Failures = 0
+		hist.LastOK = c.Time
+	} else {
+		c.Error = err.Error()
+		hist.Failures++
+	}
+	hist.Checks = append(hist.Checks, c)
+	if len(hist.Checks) > MaxChecks {
+		hist.Checks = hist.Checks[len(hist.Checks)-MaxChecks:]
+	}
+}
+
+// Dead returns whether p persistently failed to answer.
+func (h *Health) Dead(p peer.ID) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.dead(h.rec.Peers[p.Pretty()])
+}
+
+func (h *Health) dead(hist *History) bool {
+	return hist != nil && h.DeadAfter > 0 && hist.Failures >= h.DeadAfter
+}
+
+// Status returns the health of the peers pis.
+func (h *Health) Status(pis []pstore.PeerInfo) []Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make([]Status, 0, len(pis))
+	for _, pi := range pis {
+		st := Status{ID: pi.ID.Pretty()}
+		for _, a := range pi.Addrs {
+			st.Addrs = append(st.Addrs, a.String())
+		}
+		if hist := h.rec.Peers[pi.ID.Pretty()]; hist != nil {
+			if n := len(hist.Checks); n > 0 {
+				last := hist.Checks[n-1]
+				st.Last = &last
+			}
+			st.Checks = len(hist.Checks)
+			for _, c := range hist.Checks {
+				if c.OK {
+					st.OK++
+				}
+			}
+			st.Failures = hist.Failures
+			st.LastOK = hist.LastOK
+			st.Dead = h.dead(hist)
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// CheckAll probes all the bootstrap peers, and returns their health. The
+// /dnsaddr addresses are resolved again when their cached resolutions
+// expired, and the ones removed from the bootstrap list are dropped.
+func (h *Health) CheckAll(ctx context.Context) ([]Status, error) {
+	peers := h.Peers()
+	h.prune(peers)
+	pis := h.Expand(ctx, peers)
+
+	var wg sync.WaitGroup
+	for _, pi := range pis {
+		wg.Add(1)
+		go func(pi pstore.PeerInfo) {
+			defer wg.Done()
+			pctx, cancel := context.WithTimeout(ctx, ProbeTimeout)
+			defer cancel()
+			latency, err := h.Probe(pctx, pi)
+			if ctx.Err() != nil {
+				// aborted, not a failure of the peer
+				return
+			}
+			h.Record(pi.ID, latency, err)
+		}(pi)
+	}
+	wg.Wait()
+
+	if err := h.Flush(); err != nil {
+		return nil, err
+	}
+	return h.Status(pis), ctx.Err()
+}
+
+// Run probes the peers periodically until proc closes.
+func (h *Health) Run(proc goprocess.Process) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-proc.Closing()
+		cancel()
+	}()
+
+	timer := time.NewTimer(firstCheckDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-proc.Closing():
+			return
+		}
+
+		statuses, err := h.CheckAll(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error("failed to check the bootstrap peers: ", err)
+		}
+		for _, st := range statuses {
+			if st.Dead && st.Failures == h.DeadAfter {
+				log.Warningf("bootstrap peer %s failed %d probes in a row, it is only dialed when the other ones aren't enough", st.ID, st.Failures)
+			}
+		}
+		timer.Reset(h.Interval)
+	}
+}
+
+// Flush saves the history and the cached addresses.
+func (h *Health) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	b, err := json.Marshal(&h.rec)
+	if err != nil {
+		return err
+	}
+	return h.ds.Put(key, b)
+}
+
+// Close saves the history and the cached addresses.
+func (h *Health) Close() error {
+	return h.Flush()
+}
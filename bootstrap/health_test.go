@@ -0,0 +1,176 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dssync "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
+)
+
+const (
+	peerA = "QmNnooDu7bfjPFoTZYxMNLWUQJyrVwtbZg5gBMjTezGAJN"
+	peerB = "QmQCU2EcMqAqQPR2i9bChDtGNJchTbq5TbXJJ16u19uLTa"
+)
+
+func decodePeer(t *testing.T, s string) peer.ID {
+	p, err := peer.IDB58Decode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDeadPeers(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	h, err := New(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.DeadAfter = 3
+
+	a := decodePeer(t, peerA)
+	for i := 0; i < 3; i++ {
+		h.Record(a, 0, errors.New("timeout"))
+	}
+	if !h.Dead(a) {
+		t.Fatal("expected the peer to be dead after 3 failed probes")
+	}
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the history is kept across restarts
+	h, err = New(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.DeadAfter = 3
+	if !h.Dead(a) {
+		t.Fatal("expected the peer to be dead after a restart")
+	}
+
+	h.Record(a, 20*time.Millisecond, nil)
+	if h.Dead(a) {
+		t.Fatal("expected the peer to come back after a successful probe")
+	}
+	st := h.Status([]pstore.PeerInfo{{ID: a}})
+	if len(st) != 1 || st[0].Checks != 4 || st[0].OK != 1 || st[0].Failures != 0 || st[0].Last.Latency != 20*time.Millisecond {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+}
+
+// stringAddr is a /dnsaddr address, whose protocol isn't registered
+// without the DNS resolver of libp2p.
+type stringAddr struct {
+	ma.Multiaddr
+	s string
+}
+
+func (a stringAddr) String() string { return a.s }
+
+func TestExpandDNSAddr(t *testing.T) {
+	h, err := New(dssync.MutexWrap(ds.NewMapDatastore()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2018, 6, 21, 12, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+
+	lookups := 0
+	var fail bool
+	h.lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+		lookups++
+		if fail {
+			return nil, errors.New("no DNS")
+		}
+		switch name {
+		case "_dnsaddr.bootstrap.libp2p.io":
+			return []string{
+				"dnsaddr=/dnsaddr/nyc.bootstrap.libp2p.io/ipfs/" + peerA,
+				"dnsaddr=/dnsaddr/sfo.bootstrap.libp2p.io/ipfs/" + peerB,
+			}, nil
+		case "_dnsaddr.nyc.bootstrap.libp2p.io":
+			return []string{
+				"dnsaddr=/ip4/147.75.83.83/tcp/4001/ipfs/" + peerA,
+				"dnsaddr=/ip6/2604:1380:2000:7a00::1/tcp/4001/ipfs/" + peerA,
+			}, nil
+		}
+		return nil, errors.New("unknown name " + name)
+	}
+
+	a := decodePeer(t, peerA)
+	dnsaddr := stringAddr{s: "/dnsaddr/bootstrap.libp2p.io"}
+	pis := []pstore.PeerInfo{{ID: a, Addrs: []ma.Multiaddr{dnsaddr}}}
+
+	check := func() {
+		t.Helper()
+		out := h.Expand(context.Background(), pis)
+		if len(out) != 1 || len(out[0].Addrs) != 2 || out[0].Addrs[0].String() != "/ip4/147.75.83.83/tcp/4001" {
+			t.Fatalf("unexpected expansion: %v", out)
+		}
+	}
+
+	check()
+	if lookups != 2 {
+		t.Fatalf("expected 2 lookups, got %d", lookups)
+	}
+	// cached
+	check()
+	if lookups != 2 {
+		t.Fatalf("expected the resolution to be cached, got %d lookups", lookups)
+	}
+
+	// the expired addresses are kept while the lookups fail
+	now = now.Add(2 * DefaultDNSAddrTTL)
+	fail = true
+	check()
+	if lookups != 3 {
+		t.Fatalf("expected the expired resolution to be looked up again, got %d lookups", lookups)
+	}
+}
+
+func TestCachedDNSAddr(t *testing.T) {
+	h, err := New(dssync.MutexWrap(ds.NewMapDatastore()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2018, 6, 21, 12, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+	h.lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+		return []string{"dnsaddr=/ip4/147.75.83.83/tcp/4001/ipfs/" + peerA}, nil
+	}
+
+	a := decodePeer(t, peerA)
+	dnsaddr := stringAddr{s: "/dnsaddr/bootstrap.libp2p.io"}
+	pis := []pstore.PeerInfo{{ID: a, Addrs: []ma.Multiaddr{dnsaddr}}}
+
+	// not resolved yet, dialed as is
+	out := h.Cached(pis)
+	if len(out[0].Addrs) != 1 || out[0].Addrs[0] != ma.Multiaddr(dnsaddr) {
+		t.Fatalf("expected the unresolved address, got %v", out[0].Addrs)
+	}
+
+	h.Expand(context.Background(), pis)
+	out = h.Cached(pis)
+	if len(out[0].Addrs) != 1 || out[0].Addrs[0].String() != "/ip4/147.75.83.83/tcp/4001" {
+		t.Fatalf("expected the cached address, got %v", out[0].Addrs)
+	}
+
+	// expired, dialed along with the address to resolve
+	now = now.Add(2 * DefaultDNSAddrTTL)
+	if out = h.Cached(pis); len(out[0].Addrs) != 2 {
+		t.Fatalf("expected the expired and unresolved addresses, got %v", out[0].Addrs)
+	}
+
+	// removed from the bootstrap list
+	h.prune(nil)
+	if len(h.rec.DNSAddr) != 0 {
+		t.Fatalf("expected the resolution to be dropped, got %v", h.rec.DNSAddr)
+	}
+}
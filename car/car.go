@@ -0,0 +1,275 @@
+// Package car reads and writes CAR (Content Addressable aRchive) files, which
+// hold the blocks of one or more DAGs, and checks them without importing them.
+//
+// A CAR file (version 1) is a series of sections, each prefixed with its
+// length as an unsigned varint. The first section is the header, a CBOR map
+// of the roots of the DAGs and the version. Each of the next ones is a block:
+// its CID, in binary, followed by its data.
+package car
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-ipfs/blocks/fetcher"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
+	ipldcbor "gx/ipfs/QmSF1Ksgn5d7JCTBt4e1yp4wzs6tpYyweCZ4PcDYp3tNeK/go-ipld-cbor"
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// maxSectionSize is the size of the largest section read. Blocks are much
+// smaller; it only keeps a corrupt length from allocating all the memory.
+const maxSectionSize = 32 << 20
+
+// ErrTruncated is returned when a CAR file ends in the middle of a section.
+var ErrTruncated = errors.New("car file is truncated")
+
+func init() {
+	ipldcbor.RegisterCborType(Header{})
+}
+
+// Header is the header of a CAR file.
+type Header struct {
+	Roots   []*cid.Cid `refmt:"roots"`
+	Version uint64     `refmt:"version"`
+}
+
+// Reader reads the blocks of a CAR file.
+type Reader struct {
+	Header Header
+
+	r      *bufio.Reader
+	offset uint64
+}
+
+// NewReader reads the header of the CAR file of r, and returns a Reader
+// reading its blocks.
+func NewReader(r io.Reader) (*Reader, error) {
+	cr := &Reader{r: bufio.NewReader(r)}
+	data, err := cr.readSection()
+	if err == io.EOF {
+		return nil, errors.New("car file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ipldcbor.DecodeInto(data, &cr.Header); err != nil {
+		return nil, fmt.Errorf("invalid car header: %s", err)
+	}
+	if cr.Header.Version != 1 {
+		return nil, fmt.Errorf("unsupported car version %d", cr.Header.Version)
+	}
+	if len(cr.Header.Roots) == 0 {
+		return nil, errors.New("car file has no roots")
+	}
+	return cr, nil
+}
+
+// Next returns the next block of the file, or io.EOF after the last one. The
+// data of the block isn't checked against its CID.
+func (r *Reader) Next() (blocks.Block, error) {
+	offset := r.offset
+	data, err := r.readSection()
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := cidLen(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cid of the block at offset %d: %s", offset, err)
+	}
+	c, err := cid.Cast(data[:n])
+	if err != nil {
+		return nil, fmt.Errorf("invalid cid of the block at offset %d: %s", offset, err)
+	}
+	return blocks.NewBlockWithCid(data[n:], c)
+}
+
+func (r *Reader) readSection() ([]byte, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, ErrTruncated
+		}
+		return nil, err
+	}
+	var buf [binary.MaxVarintLen64]byte
+	r.offset += uint64(binary.PutUvarint(buf[:], size))
+	if size == 0 || size > maxSectionSize {
+		return nil, fmt.Errorf("invalid section size %d at offset %d", size, r.offset)
+	}
+
+	data := make([]byte, size)
+	n, err := io.ReadFull(r.r, data)
+	r.offset += uint64(n)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrTruncated
+	}
+	return data, err
+}
+
+// cidLen returns the length of the binary CID at the start of data.
+func cidLen(data []byte) (int, error) {
+	// CIDv0 is a bare sha2-256 multihash
+	if len(data) >= 2 && data[0] == mh.SHA2_256 && data[1] == 32 {
+		if len(data) < 34 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return 34, nil
+	}
+
+	n := 0
+	// version, codec and hash function
+	for i := 0; i < 3; i++ {
+		_, l := binary.Uvarint(data[n:])
+		if l <= 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		n += l
+	}
+	size, l := binary.Uvarint(data[n:])
+	if l <= 0 || size > uint64(len(data)-n-l) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return n + l + int(size), nil
+}
+
+// Writer writes CAR files.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter writes the header of a CAR file of the DAGs of roots to w, and
+// returns a Writer writing its blocks.
+func NewWriter(w io.Writer, roots []*cid.Cid) (*Writer, error) {
+	hdr, err := ipldcbor.DumpObject(&Header{Roots: roots, Version: 1})
+	if err != nil {
+		return nil, err
+	}
+	cw := &Writer{w: w}
+	if err := cw.writeSection(hdr); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// Put writes the block b.
+func (w *Writer) Put(b blocks.Block) error {
+	return w.writeSection(b.Cid().Bytes(), b.RawData())
+}
+
+func (w *Writer) writeSection(data ...[]byte) error {
+	size := 0
+	for _, d := range data {
+		size += len(d)
+	}
+	var buf [binary.MaxVarintLen64]byte
+	if _, err := w.w.Write(buf[:binary.PutUvarint(buf[:], uint64(size))]); err != nil {
+		return err
+	}
+	for _, d := range data {
+		if _, err := w.w.Write(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDAG writes the CAR file of the DAG of root to w, getting its nodes
+// from ng. Each block is written once, before the blocks it links to.
+func WriteDAG(ctx context.Context, ng ipld.NodeGetter, root *cid.Cid, w io.Writer) error {
+	cw, err := NewWriter(w, []*cid.Cid{root})
+	if err != nil {
+		return err
+	}
+	getLinks := func(ctx context.Context, c *cid.Cid) ([]*ipld.Link, error) {
+		nd, err := ng.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if err := cw.Put(nd); err != nil {
+			return nil, err
+		}
+		return nd.Links(), nil
+	}
+	return dag.EnumerateChildren(ctx, getLinks, root, cid.NewSet().Visit)
+}
+
+// Checker checks the blocks of CAR files: that their data matches their CID,
+// and that they hold the complete DAGs of the roots.
+type Checker struct {
+	// links of the blocks read, nil for the blocks which couldn't be decoded
+	links map[string][]*cid.Cid
+}
+
+// NewChecker returns a Checker with no blocks.
+func NewChecker() *Checker {
+	return &Checker{links: make(map[string][]*cid.Cid)}
+}
+
+// Add checks the data of b against its CID, and records its links. The
+// blocks which fail the check are counted as present, without links.
+func (c *Checker) Add(b blocks.Block) error {
+	key := b.Cid().KeyString()
+	c.links[key] = nil
+
+	if _, err := fetcher.Verify(b.RawData(), b.Cid()); err != nil {
+		return err
+	}
+	nd, err := ipld.Decode(b)
+	if err != nil {
+		return err
+	}
+	links := make([]*cid.Cid, 0, len(nd.Links()))
+	for _, l := range nd.Links() {
+		links = append(links, l.Cid)
+	}
+	c.links[key] = links
+	return nil
+}
+
+// Has returns whether the block of k was added.
+func (c *Checker) Has(k *cid.Cid) bool {
+	_, ok := c.links[k.KeyString()]
+	return ok
+}
+
+// Links returns the links of the block of k, nil if it wasn't added or
+// couldn't be decoded.
+func (c *Checker) Links(k *cid.Cid) []*cid.Cid {
+	return c.links[k.KeyString()]
+}
+
+// Missing returns the CIDs of the blocks linked from the DAGs of roots which
+// weren't added, in the order they are found walking the DAGs.
+func (c *Checker) Missing(roots []*cid.Cid) []*cid.Cid {
+	var missing []*cid.Cid
+	visited := make(map[string]bool)
+	queue := append([]*cid.Cid(nil), roots...)
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
+		key := k.KeyString()
+		if visited[key] {
+			continue
+		}
+		visited[key] = true
+
+		links, ok := c.links[key]
+		if !ok {
+			missing = append(missing, k)
+			continue
+		}
+		queue = append(queue, links...)
+	}
+	return missing
+}
@@ -0,0 +1,161 @@
+package car
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	dagtest "github.com/ipfs/go-ipfs/merkledag/test"
+
+	ipldcbor "gx/ipfs/QmSF1Ksgn5d7JCTBt4e1yp4wzs6tpYyweCZ4PcDYp3tNeK/go-ipld-cbor"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+func writeSection(buf *bytes.Buffer, data ...[]byte) {
+	size := 0
+	for _, d := range data {
+		size += len(d)
+	}
+	var l [binary.MaxVarintLen64]byte
+	buf.Write(l[:binary.PutUvarint(l[:], uint64(size))])
+	for _, d := range data {
+		buf.Write(d)
+	}
+}
+
+func makeCar(t *testing.T, roots []*cid.Cid, nodes ...ipld.Node) []byte {
+	var buf bytes.Buffer
+	hdr, err := ipldcbor.DumpObject(&Header{Roots: roots, Version: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSection(&buf, hdr)
+	for _, nd := range nodes {
+		writeSection(&buf, nd.Cid().Bytes(), nd.RawData())
+	}
+	return buf.Bytes()
+}
+
+func makeDag(t *testing.T) (*dag.ProtoNode, *dag.RawNode, *dag.RawNode) {
+	a := dag.NewRawNode([]byte("a"))
+	b := dag.NewRawNode([]byte("b"))
+	root := dag.NodeWithData([]byte("root"))
+	if err := root.AddNodeLink("a", a); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.AddNodeLink("b", b); err != nil {
+		t.Fatal(err)
+	}
+	return root, a, b
+}
+
+// check reads the CAR file of data with a Checker, and returns the number of
+// blocks read and of blocks failing the check.
+func check(t *testing.T, data []byte) (*Reader, *Checker, int, int) {
+	cr, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ck := NewChecker()
+	n, invalid := 0, 0
+	for {
+		b, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if ck.Add(b) != nil {
+			invalid++
+		}
+	}
+	return cr, ck, n, invalid
+}
+
+func TestCheckComplete(t *testing.T) {
+	root, a, b := makeDag(t)
+	cr, ck, n, invalid := check(t, makeCar(t, []*cid.Cid{root.Cid()}, root, a, b))
+	if len(cr.Header.Roots) != 1 || !cr.Header.Roots[0].Equals(root.Cid()) {
+		t.Fatalf("unexpected roots %v", cr.Header.Roots)
+	}
+	if n != 3 || invalid != 0 {
+		t.Fatalf("read %d blocks, %d invalid, expected 3 valid ones", n, invalid)
+	}
+	if missing := ck.Missing(cr.Header.Roots); len(missing) != 0 {
+		t.Fatalf("unexpected missing blocks %v", missing)
+	}
+}
+
+func TestCheckMissingAndCorrupt(t *testing.T) {
+	root, a, b := makeDag(t)
+	data := makeCar(t, []*cid.Cid{root.Cid()}, root, a)
+	// corrupt the data of a, the last byte of the file
+	data[len(data)-1] = 'x'
+
+	cr, ck, n, invalid := check(t, data)
+	if n != 2 || invalid != 1 {
+		t.Fatalf("read %d blocks, %d invalid, expected 2, 1 invalid", n, invalid)
+	}
+	if !ck.Has(a.Cid()) {
+		t.Fatal("expected the corrupt block to be counted")
+	}
+	missing := ck.Missing(cr.Header.Roots)
+	if len(missing) != 1 || !missing[0].Equals(b.Cid()) {
+		t.Fatalf("got missing blocks %v, expected %s", missing, b.Cid())
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	root, a, b := makeDag(t)
+	data := makeCar(t, []*cid.Cid{root.Cid()}, root, a, b)
+
+	cr, err := NewReader(bytes.NewReader(data[:len(data)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, err = cr.Next()
+		if err != nil {
+			break
+		}
+	}
+	if err != ErrTruncated {
+		t.Fatalf("expected ErrTruncated, got %v", err)
+	}
+
+	if _, err := NewReader(bytes.NewReader(nil)); err == nil {
+		t.Fatal("expected reading an empty file to fail")
+	}
+}
+
+func TestWriteDAG(t *testing.T) {
+	ctx := context.Background()
+	root, a, b := makeDag(t)
+	// a second link to a, written once
+	if err := root.AddNodeLink("a2", a); err != nil {
+		t.Fatal(err)
+	}
+	ds := dagtest.Mock()
+	if err := ds.AddMany(ctx, []ipld.Node{root, a, b}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDAG(ctx, ds, root.Cid(), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	cr, ck, n, invalid := check(t, buf.Bytes())
+	if n != 3 || invalid != 0 {
+		t.Fatalf("read %d blocks, %d invalid, expected 3 valid ones", n, invalid)
+	}
+	if missing := ck.Missing(cr.Header.Roots); len(missing) != 0 {
+		t.Fatalf("unexpected missing blocks %v", missing)
+	}
+}
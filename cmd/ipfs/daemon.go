@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
 	"errors"
 	_ "expvar"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
 	"sort"
 	"sync"
+	"syscall"
+	"time"
 
 	utilmain "github.com/ipfs/go-ipfs/cmd/ipfs/util"
 	oldcmds "github.com/ipfs/go-ipfs/commands"
@@ -18,12 +23,13 @@ import (
 	corehttp "github.com/ipfs/go-ipfs/core/corehttp"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	nodeMount "github.com/ipfs/go-ipfs/fuse/node"
+	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 	migrate "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
+	"github.com/ipfs/go-ipfs/thirdparty/jsonconf"
+	sdnotify "github.com/ipfs/go-ipfs/thirdparty/sdnotify"
 
-	"gx/ipfs/QmNqRnejxJxjRroz7buhrjfU8i3yNBLa81hFtmf2pXEffN/go-multiaddr-net"
 	mprome "gx/ipfs/QmQ5vvq26w4U7JvyZQPpDePhJGVcBWzm7tdMwFejR7vsmw/go-metrics-prometheus"
-	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
 	"gx/ipfs/QmX3QZ5jHEPidwUrymXV1iSCSUhdGxj15sm2gP4jKMef7B/client_golang/prometheus"
 	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
 	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
@@ -38,7 +44,7 @@ const (
 	ipnsMountKwd              = "mount-ipns"
 	migrateKwd                = "migrate"
 	mountKwd                  = "mount"
-	offlineKwd                = "offline"
+	offlineKwd                = commands.OfflineOption // global option, see 'ipfs --help'
 	routingOptionKwd          = "routing"
 	routingOptionSupernodeKwd = "supernode"
 	routingOptionDHTClientKwd = "dhtclient"
@@ -51,6 +57,10 @@ const (
 	enableFloodSubKwd         = "enable-pubsub-experiment"
 	enableIPNSPubSubKwd       = "enable-namesys-pubsub"
 	enableMultiplexKwd        = "enable-mplex-experiment"
+	waitReadyKwd              = "wait-ready"
+	stealLockKwd              = "steal-lock"
+	supervisedKwd             = "supervised"
+	logFileKwd                = "log-file"
 	// apiAddrKwd    = "address-api"
 	// swarmAddrKwd  = "address-swarm"
 )
@@ -114,7 +124,50 @@ Shutdown
 To shutdown the daemon, send a SIGINT signal to it (e.g. by pressing 'Ctrl-C')
 or send a SIGTERM signal to it (e.g. with 'kill'). It may take a while for the
 daemon to shutdown gracefully, but it can be killed forcibly by sending a
-second signal.
+second signal. Requests in flight are given Shutdown.GracePeriod (30s by
+default) to complete before the repo is closed.
+
+Health checks
+
+The API and gateway servers answer liveness (/livez) and readiness (/readyz)
+probes. The daemon is ready once its repo is open, the swarm is listening
+and the first bootstrap round completed. With --wait-ready, the
+'Daemon is ready' message is only printed once these checks pass.
+
+Running offline
+
+With --offline, the daemon doesn't connect to the rest of the network but
+still provides the local API. Commands sent to a daemon with --offline run on
+an offline view of its node, and only use the blocks of the local repo.
+
+Reloading the config
+
+Sending SIGHUP to the daemon, or running 'ipfs config reload', applies the
+changes made to the config file to the running daemon, for the fields which
+support it. See 'ipfs config reload --help'.
+
+Running under a service manager
+
+The daemon reports its state to systemd through sd_notify when it runs in a
+unit of Type=notify: when it's ready, reloading its config and stopping. It
+also pings the service watchdog when WatchdogSec is set.
+
+With --supervised, the daemon serves a control socket, control.sock in the
+repo, only open to the owner of the repo. Each line sent to it is a command,
+answered by a line of JSON:
+
+  reload        reload the config, as SIGHUP does
+  reopen-logs   reopen the log file, as SIGUSR1 does
+  stats         a snapshot of the statistics of the daemon
+  help          list the commands
+
+For example:
+
+  echo stats | socat - UNIX-CONNECT:$IPFS_PATH/control.sock
+
+With --log-file, the logs are written to a file rather than to stderr, and
+the file is reopened on SIGUSR1 or the reopen-logs command, so that it can be
+rotated by logrotate.
 
 IPFS_PATH environment variable
 
@@ -159,11 +212,14 @@ Headers.
 		cmdkit.BoolOption(unencryptTransportKwd, "Disable transport encryption (for debugging protocols)"),
 		cmdkit.BoolOption(enableGCKwd, "Enable automatic periodic repo garbage collection"),
 		cmdkit.BoolOption(adjustFDLimitKwd, "Check and raise file descriptor limits if needed").WithDefault(true),
-		cmdkit.BoolOption(offlineKwd, "Run offline. Do not connect to the rest of the network but provide local API."),
 		cmdkit.BoolOption(migrateKwd, "If true, assume yes at the migrate prompt. If false, assume no."),
 		cmdkit.BoolOption(enableFloodSubKwd, "Instantiate the ipfs daemon with the experimental pubsub feature enabled."),
 		cmdkit.BoolOption(enableIPNSPubSubKwd, "Enable IPNS record distribution through pubsub; enables pubsub."),
 		cmdkit.BoolOption(enableMultiplexKwd, "Add the experimental 'go-multiplex' stream muxer to libp2p on construction.").WithDefault(true),
+		cmdkit.BoolOption(waitReadyKwd, "Only report the daemon as ready once the /readyz checks pass."),
+		cmdkit.BoolOption(supervisedKwd, "Serve a control socket in the repo, for running under a service manager."),
+		cmdkit.StringOption(logFileKwd, "Write the logs to this file rather than to stderr. SIGUSR1 reopens it."),
+		cmdkit.BoolOption(stealLockKwd, "Take over the repo lock left by a daemon which crashed, once it's known not to run anymore."),
 
 		// TODO: add way to override addresses. tricky part: updating the config if also --init.
 		// cmdkit.StringOption(apiAddrKwd, "Address for the daemon rpc API (overrides config)"),
@@ -203,6 +259,17 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 
 	cctx := env.(*oldcmds.Context)
 
+	var logs *logFile
+	if path, _ := req.Options[logFileKwd].(string); path != "" {
+		logs, err = openLogFile(path)
+		if err != nil {
+			re.SetError(fmt.Errorf("opening the log file: %s", err), cmdkit.ErrNormal)
+			return
+		}
+		stopLogs := handleLogSignal(logs)
+		defer stopLogs()
+	}
+
 	go func() {
 		<-req.Context.Done()
 		fmt.Println("Received interrupt signal, shutting down...")
@@ -233,6 +300,18 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		}
 	}
 
+	if err := checkConfigFile(cctx.ConfigRoot); err != nil {
+		re.SetError(err, cmdkit.ErrNormal)
+		return
+	}
+
+	if steal, _ := req.Options[stealLockKwd].(bool); steal {
+		if err := stealRepoLock(cctx.ConfigRoot); err != nil {
+			re.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+	}
+
 	// acquire the repo lock _before_ constructing a node. we need to make
 	// sure we are permitted to access the resources (datastore, etc.)
 	repo, err := fsrepo.Open(cctx.ConfigRoot)
@@ -343,6 +422,8 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 	printSwarmAddrs(node)
 
 	defer func() {
+		notify(sdnotify.Stopping)
+
 		// We wait for the node to close first, as the node has children
 		// that it will wait for before closing, such as the API server.
 		node.Close()
@@ -358,6 +439,18 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		return node, nil
 	}
 
+	stopReload := handleReloadSignal(req.Context, node)
+	defer stopReload()
+
+	if supervised, _ := req.Options[supervisedKwd].(bool); supervised {
+		ctl, err := serveControlSocket(cctx.ConfigRoot, node, logs)
+		if err != nil {
+			re.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer ctl.Close()
+	}
+
 	// construct api endpoint - every time
 	apiErrc, err := serveHTTPApi(req, cctx)
 	if err != nil {
@@ -386,6 +479,14 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		return
 	}
 
+	// keep the repo from filling its disk - if Datastore.DiskWatchdog is set
+	if w := node.DiskWatchdog; w != nil {
+		w.GC = func(ctx context.Context) error {
+			return corerepo.GarbageCollect(node, ctx)
+		}
+		node.Process().Go(w.Run)
+	}
+
 	// construct http gateway - if it is set in the config
 	var gwErrc <-chan error
 	if len(cfg.Addresses.Gateway) > 0 {
@@ -400,7 +501,19 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 	// initialize metrics collector
 	prometheus.MustRegister(&corehttp.IpfsNodeCollector{Node: node})
 
+	if waitReady, _ := req.Options[waitReadyKwd].(bool); waitReady {
+		if err := waitNodeReady(req.Context, node); err != nil {
+			re.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+	}
+
 	fmt.Printf("Daemon is ready\n")
+	notify(sdnotify.Ready, sdnotify.Status("Daemon is ready"))
+	watchdogDone := make(chan struct{})
+	defer close(watchdogDone)
+	go pingWatchdog(watchdogDone)
+
 	// collect long-running errors and block for shutdown
 	// TODO(cryptix): our fuse currently doesnt follow this pattern for graceful shutdown
 	for err := range merge(apiErrc, gwErrc, gcErrc) {
@@ -422,17 +535,10 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 	if apiAddr == "" {
 		apiAddr = cfg.Addresses.API
 	}
-	apiMaddr, err := ma.NewMultiaddr(apiAddr)
+	apiLis, apiMaddr, err := listenHTTP("api", apiAddr, cfg.Addresses.SocketMode)
 	if err != nil {
-		return nil, fmt.Errorf("serveHTTPApi: invalid API address: %q (err: %s)", apiAddr, err)
+		return nil, fmt.Errorf("serveHTTPApi: %s", err)
 	}
-
-	apiLis, err := manet.Listen(apiMaddr)
-	if err != nil {
-		return nil, fmt.Errorf("serveHTTPApi: manet.Listen(%s) failed: %s", apiMaddr, err)
-	}
-	// we might have listened to /tcp/0 - lets see what we are listing on
-	apiMaddr = apiLis.Multiaddr()
 	fmt.Printf("API server listening on %s\n", apiMaddr)
 
 	// by default, we don't let you load arbitrary ipfs objects through the api,
@@ -447,11 +553,13 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 
 	var opts = []corehttp.ServeOption{
 		corehttp.MetricsCollectionOption("api"),
+		corehttp.MiddlewareOption("api"),
 		corehttp.CheckVersionOption(),
 		corehttp.CommandsOption(*cctx),
 		corehttp.WebUIOption,
 		gatewayOpt,
 		corehttp.VersionOption(),
+		corehttp.HealthOption(),
 		defaultMux("/debug/vars"),
 		defaultMux("/debug/pprof/"),
 		corehttp.MetricsScrapingOption("/debug/metrics/prometheus"),
@@ -473,7 +581,7 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 
 	errc := make(chan error)
 	go func() {
-		errc <- corehttp.Serve(node, manet.NetListener(apiLis), opts...)
+		errc <- corehttp.Serve(node, apiLis, opts...)
 		close(errc)
 	}()
 	return errc, nil
@@ -517,22 +625,15 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 		return nil, fmt.Errorf("serveHTTPGateway: GetConfig() failed: %s", err)
 	}
 
-	gatewayMaddr, err := ma.NewMultiaddr(cfg.Addresses.Gateway)
-	if err != nil {
-		return nil, fmt.Errorf("serveHTTPGateway: invalid gateway address: %q (err: %s)", cfg.Addresses.Gateway, err)
-	}
-
 	writable, writableOptionFound := req.Options[writableKwd].(bool)
 	if !writableOptionFound {
 		writable = cfg.Gateway.Writable
 	}
 
-	gwLis, err := manet.Listen(gatewayMaddr)
+	gwLis, gatewayMaddr, err := listenHTTP("gateway", cfg.Addresses.Gateway, cfg.Addresses.SocketMode)
 	if err != nil {
-		return nil, fmt.Errorf("serveHTTPGateway: manet.Listen(%s) failed: %s", gatewayMaddr, err)
+		return nil, fmt.Errorf("serveHTTPGateway: %s", err)
 	}
-	// we might have listened to /tcp/0 - lets see what we are listing on
-	gatewayMaddr = gwLis.Multiaddr()
 
 	if writable {
 		fmt.Printf("Gateway (writable) server listening on %s\n", gatewayMaddr)
@@ -542,9 +643,11 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 
 	var opts = []corehttp.ServeOption{
 		corehttp.MetricsCollectionOption("gateway"),
+		corehttp.MiddlewareOption("gateway"),
 		corehttp.CheckVersionOption(),
 		corehttp.CommandsROOption(*cctx),
 		corehttp.VersionOption(),
+		corehttp.HealthOption(),
 		corehttp.IPNSHostnameOption(),
 		corehttp.GatewayOption(writable, "/ipfs", "/ipns"),
 	}
@@ -560,7 +663,7 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 
 	errc := make(chan error)
 	go func() {
-		errc <- corehttp.Serve(node, manet.NetListener(gwLis), opts...)
+		errc <- corehttp.Serve(node, gwLis, opts...)
 		close(errc)
 	}()
 	return errc, nil
@@ -659,3 +762,94 @@ func YesNoPrompt(prompt string) bool {
 
 	return false
 }
+
+// checkConfigFile refuses configs with values of the wrong type, pointing at
+// the offending lines. Unknown keys are only logged: they may have been set
+// on purpose with 'ipfs config'.
+func checkConfigFile(configRoot string) error {
+	fname, err := config.Filename(configRoot)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(fname)
+	if os.IsNotExist(err) {
+		// reported when opening the repo
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	switch err := config.Validate(data).(type) {
+	case nil:
+		return nil
+	case jsonconf.Errors:
+		for _, e := range err {
+			if e.UnknownKey {
+				log.Warningf("config %s: %s", fname, e)
+			}
+		}
+		if m := err.Mismatches(); len(m) > 0 {
+			return fmt.Errorf("config %s is invalid:\n%s\n(see 'ipfs config check')", fname, m)
+		}
+		return nil
+	default:
+		return fmt.Errorf("config %s is invalid: %s", fname, err)
+	}
+}
+
+// handleReloadSignal reloads the node config on SIGHUP, which otherwise shuts
+// ipfs down. It returns a function restoring the default behaviour.
+func handleReloadSignal(ctx context.Context, node *core.IpfsNode) func() {
+	signal.Reset(syscall.SIGHUP)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-hup:
+				if _, err := reloadConfig(node); err != nil {
+					log.Error("reloading config: ", err)
+				}
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(hup)
+		close(done)
+	}
+}
+
+// waitNodeReady blocks until the readiness checks of the node pass.
+func waitNodeReady(ctx context.Context, node *core.IpfsNode) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for !core.Ready(node.Readiness()) {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// stealRepoLock takes over the lock of the repo if it's held by a daemon which
+// isn't running anymore. It does nothing when the repo isn't locked.
+func stealRepoLock(repoPath string) error {
+	st, err := fsrepo.GetLockStatus(repoPath)
+	if err != nil {
+		return err
+	}
+	if !st.Locked {
+		return nil
+	}
+	return fsrepo.StealLock(repoPath)
+}
@@ -8,7 +8,6 @@ import (
 	"io"
 	"os"
 	"path"
-	"strings"
 
 	assets "github.com/ipfs/go-ipfs/assets"
 	oldcmds "github.com/ipfs/go-ipfs/commands"
@@ -34,7 +33,12 @@ Initializes ipfs configuration files and generates a new keypair.
 If you are going to run IPFS in server environment, you may want to
 initialize it using 'server' profile.
 
-For the list of available profiles see 'ipfs config profile --help'
+For the list of available profiles see 'ipfs config profile --help'.
+Profiles can be combined, e.g. '--profile=server+badgerds+lowpower', and
+are applied in the given order. A path to a JSON file holding a merge
+patch for the config can be given as a user-defined profile:
+
+    ipfs init --profile=test+./myprofile.json
 
 ipfs uses a repository in the local file system. By default, the repo is
 located at ~/.ipfs. To change the repo location, set the $IPFS_PATH
@@ -49,7 +53,7 @@ environment variable:
 	Options: []cmdkit.Option{
 		cmdkit.IntOption("bits", "b", "Number of bits to use in the generated RSA private key.").WithDefault(nBitsForKeypairDefault),
 		cmdkit.BoolOption("empty-repo", "e", "Don't add and pin help files to the local storage."),
-		cmdkit.StringOption("profile", "p", "Apply profile settings to config. Multiple profiles can be separated by ','"),
+		cmdkit.StringOption("profile", "p", "Apply profile settings to config. Multiple profiles can be separated by ',' or '+'"),
 
 		// TODO need to decide whether to expose the override as a file or a
 		// directory. That is: should we allow the user to also specify the
@@ -100,11 +104,7 @@ environment variable:
 		}
 
 		profile, _ := req.Options["profile"].(string)
-
-		var profiles []string
-		if profile != "" {
-			profiles = strings.Split(profile, ",")
-		}
+		profiles := config.ParseProfiles(profile)
 
 		if err := doInit(os.Stdout, cctx.ConfigRoot, empty, nBitsForKeypair, profiles, conf); err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
@@ -118,12 +118,7 @@ Reinitializing would overwrite your keys.
 `)
 
 func initWithDefaults(out io.Writer, repoRoot string, profile string) error {
-	var profiles []string
-	if profile != "" {
-		profiles = strings.Split(profile, ",")
-	}
-
-	return doInit(out, repoRoot, false, nBitsForKeypairDefault, profiles, nil)
+	return doInit(out, repoRoot, false, nBitsForKeypairDefault, config.ParseProfiles(profile), nil)
 }
 
 func doInit(out io.Writer, repoRoot string, empty bool, nBitsForKeypair int, confProfiles []string, conf *config.Config) error {
@@ -139,6 +134,17 @@ func doInit(out io.Writer, repoRoot string, empty bool, nBitsForKeypair int, con
 		return errRepoExists
 	}
 
+	// resolve all the profiles before generating the keypair so that a
+	// typo or a broken profile file fails early
+	profiles := make([]config.Profile, 0, len(confProfiles))
+	for _, name := range confProfiles {
+		p, err := config.GetProfile(name)
+		if err != nil {
+			return err
+		}
+		profiles = append(profiles, p)
+	}
+
 	if conf == nil {
 		var err error
 		conf, err = config.Init(out, nBitsForKeypair)
@@ -147,13 +153,8 @@ func doInit(out io.Writer, repoRoot string, empty bool, nBitsForKeypair int, con
 		}
 	}
 
-	for _, profile := range confProfiles {
-		transformer, ok := config.Profiles[profile]
-		if !ok {
-			return fmt.Errorf("invalid configuration profile: %s", profile)
-		}
-
-		if err := transformer.Transform(conf); err != nil {
+	for _, profile := range profiles {
+		if err := profile.Transform(conf); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	listeners "github.com/ipfs/go-ipfs/thirdparty/listeners"
+
+	manet "gx/ipfs/QmNqRnejxJxjRroz7buhrjfU8i3yNBLa81hFtmf2pXEffN/go-multiaddr-net"
+	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+)
+
+// listenHTTP opens the listener for one of the daemon HTTP servers and
+// returns it with the multiaddr it listens on. A socket passed by systemd
+// socket activation under the given name takes precedence over the configured
+// address.
+func listenHTTP(name, addr, socketMode string) (net.Listener, ma.Multiaddr, error) {
+	activated, err := listeners.Take(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(activated) > 0 {
+		for _, l := range activated[1:] {
+			log.Warningf("ignoring extra activated socket %q on %s", name, l.Addr())
+			l.Close()
+		}
+		maddr, err := listenerMultiaddr(activated[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		return activated[0], maddr, nil
+	}
+
+	maddr, err := ma.NewMultiaddr(addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid address: %q (err: %s)", addr, err)
+	}
+
+	if sock, ok := unixSocketPath(maddr); ok {
+		mode, err := listeners.ParseMode(socketMode)
+		if err != nil {
+			return nil, nil, err
+		}
+		l, err := listeners.ListenUnix(sock, mode)
+		if err != nil {
+			return nil, nil, fmt.Errorf("listen on %s failed: %s", maddr, err)
+		}
+		return l, maddr, nil
+	}
+
+	l, err := manet.Listen(maddr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("manet.Listen(%s) failed: %s", maddr, err)
+	}
+	// we might have listened to /tcp/0 - lets see what we are listing on
+	return manet.NetListener(l), l.Multiaddr(), nil
+}
+
+// unixSocketPath returns the socket path of a /unix multiaddr.
+func unixSocketPath(addr ma.Multiaddr) (string, bool) {
+	p, err := addr.ValueForProtocol(ma.P_UNIX)
+	if err != nil {
+		return "", false
+	}
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return p, true
+}
+
+func listenerMultiaddr(l net.Listener) (ma.Multiaddr, error) {
+	if ua, ok := l.Addr().(*net.UnixAddr); ok {
+		return ma.NewMultiaddr("/unix" + ua.Name)
+	}
+	return manet.FromNetAddr(l.Addr())
+}
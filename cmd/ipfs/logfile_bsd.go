@@ -0,0 +1,17 @@
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// logReopenSignals are the signals reopening the log file.
+var logReopenSignals = []os.Signal{syscall.SIGUSR1}
+
+// redirectStderr makes f the stderr of the process, including for the
+// runtime, which writes panics to it.
+func redirectStderr(f *os.File) error {
+	return syscall.Dup2(int(f.Fd()), 2)
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// logReopenSignals are the signals reopening the log file.
+var logReopenSignals = []os.Signal{syscall.SIGUSR1}
+
+// redirectStderr makes f the stderr of the process, including for the
+// runtime, which writes panics to it.
+func redirectStderr(f *os.File) error {
+	return syscall.Dup3(int(f.Fd()), 2, 0)
+}
@@ -0,0 +1,15 @@
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// logReopenSignals are the signals reopening the log file.
+var logReopenSignals []os.Signal
+
+func redirectStderr(f *os.File) error {
+	return errors.New("writing the logs to a file isn't supported on this platform")
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
+	nethttp "net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -16,6 +18,7 @@ import (
 	"syscall"
 	"time"
 
+	apiauth "github.com/ipfs/go-ipfs/apiauth"
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
 	coreCmds "github.com/ipfs/go-ipfs/core/commands"
@@ -34,6 +37,7 @@ import (
 	"gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds/http"
 	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
 	loggables "gx/ipfs/QmcBbMF4UyZFRTvH9S2h3rbSRBvvEGLqgt4sdvVugG8rX1/go-libp2p-loggables"
+	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
 )
 
 // log is the command logger
@@ -42,6 +46,7 @@ var log = logging.Logger("cmd/ipfs")
 var errRequestCanceled = errors.New("request canceled")
 
 const (
+	EnvAPIToken        = "IPFS_API_TOKEN"
 	EnvEnableProfiling = "IPFS_PROF"
 	cpuProfile         = "ipfs.cpuprof"
 	heapProfile        = "ipfs.memprof"
@@ -64,8 +69,24 @@ func mainRet() int {
 
 	// we'll call this local helper to output errors.
 	// this is so we control how to print errors in one place.
+	stderr := os.Stderr
+	var quiet *quietErrors
+	if quietErrorsRequested(os.Args) {
+		quiet, err = newQuietErrors(os.Stderr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+			return 1
+		}
+		defer quiet.Close()
+		stderr = quiet.Stderr()
+	}
+
 	printErr := func(err error) {
-		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		if quiet != nil {
+			quiet.Error(err, cmdkit.ErrNormal)
+			return
+		}
+		fmt.Fprintf(stderr, "Error: %s\n", err.Error())
 	}
 
 	stopFunc, err := profileIfEnabled()
@@ -92,6 +113,9 @@ func mainRet() int {
 	os.Args[0] = "ipfs"
 
 	buildEnv := func(ctx context.Context, req *cmds.Request) (cmds.Environment, error) {
+		if quiet != nil {
+			quiet.Started()
+		}
 		checkDebug(req)
 		repoPath, err := getRepoPath(req)
 		if err != nil {
@@ -130,8 +154,22 @@ func mainRet() int {
 		}, nil
 	}
 
-	err = cli.Run(ctx, Root, os.Args, os.Stdin, os.Stdout, os.Stderr, buildEnv, makeExecutor)
+	executor := makeExecutor
+	if quiet != nil {
+		executor = func(req *cmds.Request, env interface{}) (cmds.Executor, error) {
+			exctr, err := makeExecutor(req, env)
+			if err != nil {
+				return nil, err
+			}
+			return &quietExecutor{Executor: exctr, q: quiet}, nil
+		}
+	}
+
+	err = cli.Run(ctx, Root, os.Args, os.Stdin, os.Stdout, stderr, buildEnv, executor)
 	if err != nil {
+		if quiet != nil {
+			quiet.Finish(err)
+		}
 		return 1
 	}
 
@@ -248,12 +286,26 @@ func commandShouldRunOnDaemon(details cmdDetails, req *cmds.Request, cctx *oldcm
 		return nil, err
 	}
 
+	if offline, _ := req.Options[coreCmds.OfflineOption].(bool); offline && client != nil && details.canRunOnClient() {
+		// an api file without a daemon holding the repo lock is left over
+		// from a daemon which didn't shut down cleanly. Offline commands
+		// don't need the daemon, fall back to running them on the repo.
+		if daemonLocked, _ := fsrepo.LockedByOtherProcess(cctx.ConfigRoot); !daemonLocked {
+			log.Debugf("daemon not running, running %s offline on the repo", strings.Join(path, "/"))
+			return nil, nil
+		}
+	}
+
 	if client != nil {
 		if details.cannotRunOnDaemon {
 			// check if daemon locked. legacy error text, for now.
 			log.Debugf("Command cannot run on daemon. Checking if daemon is locked")
 			if daemonLocked, _ := fsrepo.LockedByOtherProcess(cctx.ConfigRoot); daemonLocked {
-				return nil, cmds.ClientError("ipfs daemon is running. please stop it to run this command")
+				// the daemon checks whether the lock is stale before
+				// stealing it
+				if steal, _ := req.Options[stealLockKwd].(bool); !steal || req.Command != daemonCmd {
+					return nil, cmds.ClientError("ipfs daemon is running. please stop it to run this command")
+				}
 			}
 			return nil, nil
 		}
@@ -418,6 +470,7 @@ func getApiClient(repoPath, apiAddrStr string) (http.Client, error) {
 	}
 
 	var addr ma.Multiaddr
+	var proof string
 	var err error
 	if len(apiAddrStr) != 0 {
 		addr, err = ma.NewMultiaddr(apiAddrStr)
@@ -436,18 +489,70 @@ func getApiClient(repoPath, apiAddrStr string) (http.Client, error) {
 		if err != nil {
 			return nil, fmt.Errorf(apiErrorFmt, repoPath, err.Error())
 		}
+
+		// the daemon of the repo is told the requests come from the ipfs
+		// command, with a proof only those who can read its config have
+		if cfg, err := fsrepo.ConfigAt(repoPath); err == nil && cfg.Identity.PrivKey != "" {
+			proof = apiauth.CLIProof(cfg.Identity.PrivKey)
+		}
 	}
 	if len(addr.Protocols()) == 0 {
 		return nil, fmt.Errorf(apiErrorFmt, repoPath, "multiaddr doesn't provide any protocols")
 	}
-	return apiClientForAddr(addr)
+	return apiClientForAddr(addr, proof)
 }
 
-func apiClientForAddr(addr ma.Multiaddr) (http.Client, error) {
-	_, host, err := manet.DialArgs(addr)
-	if err != nil {
-		return nil, err
+// apiClientForAddr returns a client of the API at addr, sending proof, if
+// any, as the proof that the requests come from the ipfs command. It has its
+// own transport, http.DefaultClient is left as it is.
+func apiClientForAddr(addr ma.Multiaddr, proof string) (http.Client, error) {
+	var host string
+	var rt nethttp.RoundTripper = nethttp.DefaultTransport
+	if sock, ok := unixSocketPath(addr); ok {
+		// the host is ignored, all requests are sent over the socket
+		host = "unix"
+		rt = &nethttp.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", sock)
+			},
+		}
+	} else {
+		var err error
+		_, host, err = manet.DialArgs(addr)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return http.NewClient(host, http.ClientWithAPIPrefix(corehttp.APIPath)), nil
+	// authenticate the requests with the token secret, if any
+	if token := os.Getenv(EnvAPIToken); token != "" {
+		rt = &headerTransport{key: "Authorization", value: "Bearer " + token, rt: rt}
+	}
+	if proof != "" {
+		rt = &headerTransport{key: apiauth.CLIHeader, value: proof, rt: rt}
+	}
+
+	return http.NewClient(host,
+		http.ClientWithAPIPrefix(corehttp.APIPath),
+		http.ClientWithHTTPClient(&nethttp.Client{Transport: rt}),
+	), nil
+}
+
+// headerTransport adds a header to outgoing requests.
+type headerTransport struct {
+	key   string
+	value string
+	rt    nethttp.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(r *nethttp.Request) (*nethttp.Response, error) {
+	r2 := new(nethttp.Request)
+	*r2 = *r
+	r2.Header = make(nethttp.Header, len(r.Header)+1)
+	for k, v := range r.Header {
+		r2.Header[k] = v
+	}
+	r2.Header.Set(t.key, t.value)
+	return t.rt.RoundTrip(r2)
 }
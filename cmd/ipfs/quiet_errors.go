@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	coreCmds "github.com/ipfs/go-ipfs/core/commands"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+// quietErrorsRequested reports whether --quiet-errors is on the command line.
+// It has to be known before the command line is parsed, as parse errors
+// are reported with it too.
+func quietErrorsRequested(args []string) bool {
+	for _, arg := range args[1:] {
+		switch arg {
+		case "--":
+			return false
+		case "--" + coreCmds.QuietErrorsOption, "--" + coreCmds.QuietErrorsOption + "=true":
+			return true
+		}
+	}
+	return false
+}
+
+// quietErrors writes the errors of the command line to w as JSON, in the form
+// the HTTP API uses, one per line, from the cmdkit.Error they are set with.
+// What the command line prints to stderr, like usage hints and progress bars,
+// is dropped.
+type quietErrors struct {
+	w       io.Writer
+	devNull *os.File
+
+	mu sync.Mutex
+	// started is whether the command line was parsed, its errors since
+	// not being usage errors, and written whether an error was written
+	started, written bool
+}
+
+func newQuietErrors(w io.Writer) (*quietErrors, error) {
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &quietErrors{w: w, devNull: f}, nil
+}
+
+// Stderr returns the file the command line should print to instead of
+// os.Stderr.
+func (q *quietErrors) Stderr() *os.File {
+	return q.devNull
+}
+
+func (q *quietErrors) Close() error {
+	return q.devNull.Close()
+}
+
+// Started records that the command line was parsed.
+func (q *quietErrors) Started() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.started = true
+}
+
+// Error writes err, with code unless it's a cmdkit.Error.
+func (q *quietErrors) Error(err interface{}, code cmdkit.ErrorType) {
+	e := toCmdkitError(err, code)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.written = true
+	json.NewEncoder(q.w).Encode(e)
+}
+
+// Finish writes err, the error the command line failed with, unless an error
+// was written already. It's a usage error when the command line wasn't parsed.
+func (q *quietErrors) Finish(err error) {
+	q.mu.Lock()
+	written, started := q.written, q.started
+	q.mu.Unlock()
+	if written {
+		return
+	}
+
+	code := cmdkit.ErrNormal
+	if !started {
+		code = cmdkit.ErrClient
+	}
+	q.Error(err, code)
+}
+
+func toCmdkitError(err interface{}, code cmdkit.ErrorType) cmdkit.Error {
+	switch e := err.(type) {
+	case cmdkit.Error:
+		return e
+	case *cmdkit.Error:
+		return *e
+	case error:
+		return cmdkit.Error{Message: e.Error(), Code: code}
+	default:
+		return cmdkit.Error{Message: fmt.Sprint(e), Code: code}
+	}
+}
+
+// quietExecutor executes the commands with their errors written by q.
+type quietExecutor struct {
+	cmds.Executor
+	q *quietErrors
+}
+
+func (e *quietExecutor) Execute(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+	err := e.Executor.Execute(req, &quietEmitter{ResponseEmitter: re, q: e.q}, env)
+	if err != nil {
+		e.q.Error(err, cmdkit.ErrNormal)
+	}
+	return err
+}
+
+// quietEmitter writes the errors set on the emitter it wraps with q.
+type quietEmitter struct {
+	cmds.ResponseEmitter
+	q *quietErrors
+}
+
+func (re *quietEmitter) SetError(err interface{}, code cmdkit.ErrorType) error {
+	re.q.Error(err, code)
+	return re.ResponseEmitter.SetError(err, code)
+}
+
+func (re *quietEmitter) Emit(v interface{}) error {
+	switch e := v.(type) {
+	case cmdkit.Error:
+		re.q.Error(e, e.Code)
+	case *cmdkit.Error:
+		re.q.Error(e, e.Code)
+	}
+	return re.ResponseEmitter.Emit(v)
+}
+
+// Type is the type of the wrapped emitter, for the PostRun of the command to
+// be the one for it.
+func (re *quietEmitter) Type() cmds.PostRunType {
+	if t, ok := re.ResponseEmitter.(interface {
+		Type() cmds.PostRunType
+	}); ok {
+		return t.Type()
+	}
+	return ""
+}
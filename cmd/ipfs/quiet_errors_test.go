@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+// errorEmitter records the error set on it.
+type errorEmitter struct {
+	cmds.ResponseEmitter
+	err interface{}
+}
+
+func (re *errorEmitter) SetError(err interface{}, code cmdkit.ErrorType) error {
+	re.err = err
+	return nil
+}
+
+func decodeQuietErrors(t *testing.T, r io.Reader) []cmdkit.Error {
+	var errs []cmdkit.Error
+	dec := json.NewDecoder(r)
+	for {
+		var e cmdkit.Error
+		if err := dec.Decode(&e); err == io.EOF {
+			return errs
+		} else if err != nil {
+			t.Fatalf("decoding the errors: %s", err)
+		}
+		errs = append(errs, e)
+	}
+}
+
+func TestQuietErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		run  func(q *quietErrors)
+		errs []cmdkit.Error
+	}{
+		{
+			name: "no error",
+			run:  func(q *quietErrors) { q.Started() },
+		},
+		{
+			name: "usage error",
+			run: func(q *quietErrors) {
+				q.Finish(errors.New("Argument 'ipfs-path' is required"))
+			},
+			errs: []cmdkit.Error{{Message: "Argument 'ipfs-path' is required", Code: cmdkit.ErrClient}},
+		},
+		{
+			name: "error of the command",
+			run: func(q *quietErrors) {
+				q.Started()
+				re := &quietEmitter{ResponseEmitter: &errorEmitter{}, q: q}
+				re.SetError(errors.New("merkledag: not found"), cmdkit.ErrNormal)
+				// the command line then fails, the error being written
+				q.Finish(errors.New("exit status 1"))
+			},
+			errs: []cmdkit.Error{{Message: "merkledag: not found", Code: cmdkit.ErrNormal}},
+		},
+		{
+			name: "error of the executor",
+			run: func(q *quietErrors) {
+				q.Started()
+				q.Error(cmdkit.Error{Message: "invalid option", Code: cmdkit.ErrClient}, cmdkit.ErrNormal)
+				q.Finish(errors.New("invalid option"))
+			},
+			errs: []cmdkit.Error{{Message: "invalid option", Code: cmdkit.ErrClient}},
+		},
+		{
+			name: "error after the command line was parsed",
+			run: func(q *quietErrors) {
+				q.Started()
+				q.Finish(errors.New("no repo"))
+			},
+			errs: []cmdkit.Error{{Message: "no repo", Code: cmdkit.ErrNormal}},
+		},
+	} {
+		var out bytes.Buffer
+		q, err := newQuietErrors(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.run(q)
+		q.Close()
+
+		errs := decodeQuietErrors(t, &out)
+		if !reflect.DeepEqual(errs, test.errs) {
+			t.Errorf("%s: got the errors %+v, expected %+v", test.name, errs, test.errs)
+		}
+	}
+}
+
+func TestQuietEmitterForwardsErrors(t *testing.T) {
+	var out bytes.Buffer
+	q, err := newQuietErrors(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	inner := &errorEmitter{}
+	re := &quietEmitter{ResponseEmitter: inner, q: q}
+	re.SetError("failed", cmdkit.ErrNormal)
+	if inner.err != "failed" {
+		t.Fatalf("expected the error to be set on the wrapped emitter, got %v", inner.err)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	listeners "github.com/ipfs/go-ipfs/thirdparty/listeners"
+	sdnotify "github.com/ipfs/go-ipfs/thirdparty/sdnotify"
+)
+
+// controlSocketFile is the file name of the control socket of a supervised
+// daemon, relative to the repo.
+const controlSocketFile = "control.sock"
+
+// reloadConfig reloads the config of the node, telling systemd about it.
+func reloadConfig(node *core.IpfsNode) (*core.ConfigReload, error) {
+	notify(sdnotify.Reloading)
+	defer notify(sdnotify.Ready)
+
+	res, err := node.ReloadConfig()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range res.Applied {
+		fmt.Printf("Config reloaded: applied %s\n", f)
+	}
+	return res, nil
+}
+
+// notify reports states to systemd, when the daemon runs under it.
+func notify(states ...string) {
+	if err := sdnotify.Notify(states...); err != nil {
+		log.Warning(err)
+	}
+}
+
+// pingWatchdog pings the service watchdog of systemd, when it's enabled,
+// until done is closed.
+func pingWatchdog(done <-chan struct{}) {
+	interval, err := sdnotify.WatchdogInterval()
+	if err != nil {
+		log.Warning(err)
+		return
+	}
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			notify(sdnotify.Watchdog)
+		case <-done:
+			return
+		}
+	}
+}
+
+// logFile is the file the logs of the daemon are written to, instead of
+// stderr. It's reopened to rotate the logs.
+type logFile struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+func openLogFile(path string) (*logFile, error) {
+	lf := &logFile{path: path}
+	if err := lf.Reopen(); err != nil {
+		return nil, err
+	}
+	return lf, nil
+}
+
+// Reopen reopens the log file, after it was moved away by log rotation.
+func (lf *logFile) Reopen() error {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+
+	f, err := os.OpenFile(lf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if err := redirectStderr(f); err != nil {
+		f.Close()
+		return err
+	}
+	if lf.f != nil {
+		lf.f.Close()
+	}
+	lf.f = f
+	return nil
+}
+
+// handleLogSignal reopens the log file on the log rotation signal. It returns
+// a function restoring the default behaviour.
+func handleLogSignal(lf *logFile) func() {
+	if len(logReopenSignals) == 0 {
+		return func() {}
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, logReopenSignals...)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigc:
+				if err := lf.Reopen(); err != nil {
+					log.Error("reopening the log file: ", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigc)
+		close(done)
+	}
+}
+
+// DaemonStats is a snapshot of the statistics of a supervised daemon.
+type DaemonStats struct {
+	// Uptime is the number of seconds since the daemon started.
+	Uptime int64
+	Ready  bool
+	Peers  int
+	// RepoSize is the number of bytes stored in the repo.
+	RepoSize uint64
+	// TotalIn and TotalOut are the number of bytes received and sent.
+	TotalIn  int64
+	TotalOut int64
+	// RateIn and RateOut are the current bandwidth, in bytes per second.
+	RateIn  float64
+	RateOut float64
+}
+
+// controlError is the response to a failed command of the control socket.
+type controlError struct {
+	Error string
+}
+
+// controlCommands are the commands of the control socket.
+var controlCommands = map[string]func(s *controlServer) (interface{}, error){
+	"reload": func(s *controlServer) (interface{}, error) {
+		return reloadConfig(s.node)
+	},
+	"reopen-logs": func(s *controlServer) (interface{}, error) {
+		if s.logs == nil {
+			return nil, fmt.Errorf("the daemon doesn't write its logs to a file, see --%s", logFileKwd)
+		}
+		return struct{}{}, s.logs.Reopen()
+	},
+	"stats": func(s *controlServer) (interface{}, error) {
+		return s.stats()
+	},
+}
+
+// controlCommandNames returns the names of the commands of the control
+// socket, for help.
+func controlCommandNames() []string {
+	names := []string{"help"}
+	for name := range controlCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// controlServer serves the control socket of a supervised daemon. Each line
+// read from a connection is a command, answered by a line of JSON.
+type controlServer struct {
+	node    *core.IpfsNode
+	logs    *logFile
+	started time.Time
+	l       net.Listener
+}
+
+// serveControlSocket serves the control socket in the repo at repoPath. Only
+// the owner of the repo may connect to it.
+func serveControlSocket(repoPath string, node *core.IpfsNode, logs *logFile) (*controlServer, error) {
+	path := filepath.Join(repoPath, controlSocketFile)
+	l, err := listeners.ListenUnix(path, listeners.DefaultSocketMode)
+	if err != nil {
+		return nil, fmt.Errorf("serving the control socket: %s", err)
+	}
+
+	s := &controlServer{
+		node:    node,
+		logs:    logs,
+		started: time.Now(),
+		l:       l,
+	}
+	go s.serve()
+	fmt.Printf("Control socket listening on %s\n", path)
+	return s, nil
+}
+
+func (s *controlServer) serve() {
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *controlServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	enc := json.NewEncoder(conn)
+	scan := bufio.NewScanner(conn)
+	for scan.Scan() {
+		name := strings.TrimSpace(scan.Text())
+		if name == "" {
+			continue
+		}
+
+		var res interface{}
+		cmd, ok := controlCommands[name]
+		if name == "help" {
+			res = controlCommandNames()
+		} else if !ok {
+			res = &controlError{fmt.Sprintf("unknown command %q, see help", name)}
+		} else if v, err := cmd(s); err != nil {
+			res = &controlError{err.Error()}
+		} else {
+			res = v
+		}
+
+		if err := enc.Encode(res); err != nil {
+			return
+		}
+	}
+}
+
+func (s *controlServer) stats() (*DaemonStats, error) {
+	st := &DaemonStats{
+		Uptime: int64(time.Since(s.started) / time.Second),
+		Ready:  core.Ready(s.node.Readiness()),
+	}
+	if s.node.PeerHost != nil {
+		st.Peers = len(s.node.PeerHost.Network().Peers())
+	}
+	if s.node.Reporter != nil {
+		bw := s.node.Reporter.GetBandwidthTotals()
+		st.TotalIn, st.TotalOut = bw.TotalIn, bw.TotalOut
+		st.RateIn, st.RateOut = bw.RateIn, bw.RateOut
+	}
+
+	size, err := s.node.Repo.GetStorageUsage()
+	if err != nil {
+		return nil, err
+	}
+	st.RepoSize = size
+	return st, nil
+}
+
+// Close stops serving the control socket.
+func (s *controlServer) Close() error {
+	return s.l.Close()
+}
@@ -52,6 +52,26 @@ func (c *Context) GetNode() (*core.IpfsNode, error) {
 	return c.node, err
 }
 
+// Offline returns a context for running a single command offline. Its node
+// is an offline view of the node of c, see core.IpfsNode.OfflineView.
+func (c *Context) Offline() *Context {
+	return &Context{
+		Online:     false,
+		ConfigRoot: c.ConfigRoot,
+		ReqLog:     c.ReqLog,
+		LoadConfig: func(string) (*config.Config, error) {
+			return c.GetConfig()
+		},
+		ConstructNode: func() (*core.IpfsNode, error) {
+			n, err := c.GetNode()
+			if err != nil {
+				return nil, err
+			}
+			return n.OfflineView(), nil
+		},
+	}
+}
+
 // Context returns the node's context.
 func (c *Context) Context() context.Context {
 	n, err := c.GetNode()
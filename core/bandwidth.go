@@ -0,0 +1,196 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	manet "gx/ipfs/QmNqRnejxJxjRroz7buhrjfU8i3yNBLa81hFtmf2pXEffN/go-multiaddr-net"
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	smux "gx/ipfs/QmY9JXR3FupnYAYJWK9aMr9bCpqWKcToQ1tz8DVGTrHpHw/go-stream-muxer"
+)
+
+// tokenBucket limits a rate in bytes per second, with bursts of up to one
+// second of traffic.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate uint64) *tokenBucket {
+	return &tokenBucket{
+		rate:   float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+// take uses n bytes of the bucket and returns how long to wait until the
+// bucket has refilled them.
+func (b *tokenBucket) take(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// rateLimits are the buckets of a direction of traffic, nil when unlimited.
+type rateLimits struct {
+	in, out *tokenBucket
+}
+
+func newRateLimits(rateIn, rateOut string) (rateLimits, error) {
+	var l rateLimits
+	var err error
+	if l.in, err = parseRate(rateIn); err != nil {
+		return l, fmt.Errorf("RateIn: %s", err)
+	}
+	if l.out, err = parseRate(rateOut); err != nil {
+		return l, fmt.Errorf("RateOut: %s", err)
+	}
+	return l, nil
+}
+
+func parseRate(s string) (*tokenBucket, error) {
+	if s == "" {
+		return nil, nil
+	}
+	rate, err := humanize.ParseBytes(s)
+	if err != nil {
+		return nil, err
+	}
+	if rate == 0 {
+		return nil, fmt.Errorf("rate must be positive, leave it empty for no limit")
+	}
+	return newTokenBucket(rate), nil
+}
+
+func (l rateLimits) bucket(in bool) *tokenBucket {
+	if in {
+		return l.in
+	}
+	return l.out
+}
+
+// bandwidthLimiter limits the traffic of the connections of the node, in
+// total and per transport. Its limits can be replaced while the node runs.
+type bandwidthLimiter struct {
+	mu         sync.RWMutex
+	global     rateLimits
+	transports map[string]rateLimits
+}
+
+func newBandwidthLimiter(conf config.Bandwidth) (*bandwidthLimiter, error) {
+	l := new(bandwidthLimiter)
+	if err := l.SetLimits(conf); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetLimits replaces the limits, for the existing connections as well.
+func (l *bandwidthLimiter) SetLimits(conf config.Bandwidth) error {
+	global, err := newRateLimits(conf.RateIn, conf.RateOut)
+	if err != nil {
+		return fmt.Errorf("Swarm.Bandwidth.%s", err)
+	}
+
+	transports := make(map[string]rateLimits, len(conf.Transports))
+	for name, tc := range conf.Transports {
+		tl, err := newRateLimits(tc.RateIn, tc.RateOut)
+		if err != nil {
+			return fmt.Errorf("Swarm.Bandwidth.Transports.%s.%s", name, err)
+		}
+		transports[name] = tl
+	}
+
+	l.mu.Lock()
+	l.global = global
+	l.transports = transports
+	l.mu.Unlock()
+	return nil
+}
+
+// delay accounts for n bytes sent or received over the transport and returns
+// how long to wait to stay within the limits.
+func (l *bandwidthLimiter) delay(transport string, n int, in bool) time.Duration {
+	l.mu.RLock()
+	global := l.global.bucket(in)
+	tb := l.transports[transport].bucket(in)
+	l.mu.RUnlock()
+
+	var d time.Duration
+	if global != nil {
+		d = global.take(n)
+	}
+	if tb != nil {
+		if td := tb.take(n); td > d {
+			d = td
+		}
+	}
+	return d
+}
+
+// limitedMuxer wraps a stream muxer to limit the traffic of the connections
+// it multiplexes, which carry all the traffic of the node once encrypted.
+type limitedMuxer struct {
+	smux.Transport
+	limiter *bandwidthLimiter
+}
+
+func (m *limitedMuxer) NewConn(c net.Conn, isServer bool) (smux.Conn, error) {
+	return m.Transport.NewConn(&limitedConn{
+		Conn:      c,
+		limiter:   m.limiter,
+		transport: connTransport(c),
+	}, isServer)
+}
+
+type limitedConn struct {
+	net.Conn
+	limiter   *bandwidthLimiter
+	transport string
+}
+
+// Read waits after reading, which slows down the peer once the buffers of
+// the connection are full.
+func (c *limitedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		time.Sleep(c.limiter.delay(c.transport, n, true))
+	}
+	return n, err
+}
+
+func (c *limitedConn) Write(b []byte) (int, error) {
+	time.Sleep(c.limiter.delay(c.transport, len(b), false))
+	return c.Conn.Write(b)
+}
+
+// connTransport names the transport of a connection after the last protocol
+// of its remote multiaddr, e.g. "tcp" or "ws".
+func connTransport(c net.Conn) string {
+	a, err := manet.FromNetAddr(c.RemoteAddr())
+	if err != nil {
+		return c.RemoteAddr().Network()
+	}
+	ps := a.Protocols()
+	if len(ps) == 0 {
+		return c.RemoteAddr().Network()
+	}
+	return ps[len(ps)-1].Name
+}
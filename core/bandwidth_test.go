@@ -0,0 +1,61 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(1000)
+
+	if d := b.take(1000); d != 0 {
+		t.Fatalf("expected the burst to pass without waiting, waited %s", d)
+	}
+
+	d := b.take(500)
+	if d < 490*time.Millisecond || d > 500*time.Millisecond {
+		t.Fatalf("expected to wait about 500ms, waited %s", d)
+	}
+}
+
+func TestBandwidthLimiter(t *testing.T) {
+	l, err := newBandwidthLimiter(config.Bandwidth{
+		RateOut: "1kB",
+		Transports: map[string]config.BandwidthLimit{
+			"ws": {RateOut: "100B"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := l.delay("tcp", 1000, true); d != 0 {
+		t.Fatalf("expected downloads to be unlimited, waited %s", d)
+	}
+	if d := l.delay("tcp", 100, false); d != 0 {
+		t.Fatalf("expected to upload within the limit, waited %s", d)
+	}
+	if d := l.delay("ws", 200, false); d < 900*time.Millisecond {
+		t.Fatalf("expected the ws limit to apply, waited %s", d)
+	}
+
+	if err := l.SetLimits(config.Bandwidth{}); err != nil {
+		t.Fatal(err)
+	}
+	if d := l.delay("ws", 10000, false); d != 0 {
+		t.Fatalf("expected the limits to be removed, waited %s", d)
+	}
+}
+
+func TestBandwidthLimiterInvalidRate(t *testing.T) {
+	_, err := newBandwidthLimiter(config.Bandwidth{
+		Transports: map[string]config.BandwidthLimit{
+			"tcp": {RateIn: "fast"},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an invalid rate to fail")
+	}
+}
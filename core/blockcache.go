@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"sync"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	bstore "gx/ipfs/QmbaPGg81pvQiC5vTXtC9Jo8rdrWUjRaugH71WYNsgi6Ev/go-ipfs-blockstore"
+)
+
+// reloadableBlockstore is the blockstore of the node, behind its caches. The
+// caches are sized from Datastore, and rebuilt when the config is reloaded.
+type reloadableBlockstore struct {
+	ctx       context.Context
+	bs        bstore.Blockstore
+	permanent bool
+
+	mu     sync.RWMutex
+	cached bstore.Blockstore
+	// cancel stops the building of the bloom filter of cached
+	cancel context.CancelFunc
+}
+
+// blockCacheOpts returns the options of the caches of the blockstore. The
+// bloom filter is only worth building for the nodes with a permanent repo.
+func blockCacheOpts(conf config.Datastore, permanent bool) bstore.CacheOpts {
+	opts := bstore.DefaultCacheOpts()
+	opts.HasBloomFilterSize = conf.BloomFilterSize
+	if !permanent {
+		opts.HasBloomFilterSize = 0
+	}
+	return opts
+}
+
+func newReloadableBlockstore(ctx context.Context, bs bstore.Blockstore, conf config.Datastore, permanent bool) (*reloadableBlockstore, error) {
+	r := &reloadableBlockstore{ctx: ctx, bs: bs, permanent: permanent}
+	if err := r.Resize(conf); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Resize replaces the caches by empty ones sized from conf. The blocks are
+// read from the blockstore until they're filled again.
+func (r *reloadableBlockstore) Resize(conf config.Datastore) error {
+	ctx, cancel := context.WithCancel(r.ctx)
+	cached, err := bstore.CachedBlockstore(ctx, r.bs, blockCacheOpts(conf, r.permanent))
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	r.mu.Lock()
+	old := r.cancel
+	r.cached, r.cancel = cached, cancel
+	r.mu.Unlock()
+	if old != nil {
+		old()
+	}
+	return nil
+}
+
+func (r *reloadableBlockstore) current() bstore.Blockstore {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cached
+}
+
+func (r *reloadableBlockstore) DeleteBlock(c *cid.Cid) error {
+	return r.current().DeleteBlock(c)
+}
+
+func (r *reloadableBlockstore) Has(c *cid.Cid) (bool, error) {
+	return r.current().Has(c)
+}
+
+func (r *reloadableBlockstore) Get(c *cid.Cid) (blocks.Block, error) {
+	return r.current().Get(c)
+}
+
+// GetSize keeps telling the size of the blocks without reading them when the
+// caches can.
+func (r *reloadableBlockstore) GetSize(c *cid.Cid) (int, error) {
+	return blockSize(r.current(), c)
+}
+
+func (r *reloadableBlockstore) Put(b blocks.Block) error {
+	return r.current().Put(b)
+}
+
+func (r *reloadableBlockstore) PutMany(blks []blocks.Block) error {
+	return r.current().PutMany(blks)
+}
+
+func (r *reloadableBlockstore) AllKeysChan(ctx context.Context) (<-chan *cid.Cid, error) {
+	return r.current().AllKeysChan(ctx)
+}
+
+// HashOnRead is set on the blockstore itself, which the caches share.
+func (r *reloadableBlockstore) HashOnRead(enabled bool) {
+	r.bs.HashOnRead(enabled)
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	config "github.com/ipfs/go-ipfs/repo/config"
@@ -51,6 +52,10 @@ type BootstrapConfig struct {
 	// for the bootstrap process to use. This makes it possible for clients
 	// to control the peers the process uses at any moment.
 	BootstrapPeers func() []pstore.PeerInfo
+
+	// Dead, if set, tells the bootstrap peers which persistently failed to
+	// answer. They are only dialed when the other ones aren't enough.
+	Dead func(peer.ID) bool
 }
 
 // DefaultBootstrapConfig specifies default sane parameters for bootstrapping.
@@ -86,6 +91,7 @@ func Bootstrap(n *IpfsNode, cfg BootstrapConfig) (io.Closer, error) {
 			log.Event(ctx, "bootstrapError", n.Identity, lgbl.Error(err))
 			log.Debugf("%s bootstrap error: %s", n.Identity, err)
 		}
+		atomic.StoreInt32(&n.bootstrapped, 1)
 
 		<-doneWithRound
 	}
@@ -143,7 +149,7 @@ func bootstrapRound(ctx context.Context, host host.Host, cfg BootstrapConfig) er
 	}
 
 	// connect to a random susbset of bootstrap candidates
-	randSubset := randomSubsetOfPeers(notConnected, numToDial)
+	randSubset := bootstrapCandidates(notConnected, numToDial, cfg.Dead)
 
 	defer log.EventBegin(ctx, "bootstrapStart", id).Done()
 	log.Debugf("%s bootstrapping to %d nodes: %s", id, numToDial, randSubset)
@@ -220,6 +226,24 @@ func toPeerInfos(bpeers []config.BootstrapPeer) []pstore.PeerInfo {
 	return peers
 }
 
+// bootstrapCandidates returns a random subset of max peers, the dead ones
+// only when the others aren't enough.
+func bootstrapCandidates(in []pstore.PeerInfo, max int, isDead func(peer.ID) bool) []pstore.PeerInfo {
+	var live, dead []pstore.PeerInfo
+	for _, p := range in {
+		if isDead != nil && isDead(p.ID) {
+			dead = append(dead, p)
+		} else {
+			live = append(live, p)
+		}
+	}
+	out := randomSubsetOfPeers(live, max)
+	if len(out) < max {
+		out = append(out, randomSubsetOfPeers(dead, max-len(out))...)
+	}
+	return out
+}
+
 func randomSubsetOfPeers(in []pstore.PeerInfo, max int) []pstore.PeerInfo {
 	n := math2.IntMin(max, len(in))
 	var out []pstore.PeerInfo
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	bootstrap "github.com/ipfs/go-ipfs/bootstrap"
+
+	inet "gx/ipfs/QmXdgNhVEgjLxjUoMs5ViQL7pboAt3Y7V7eGHRiE4qrmTE/go-libp2p-net"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+)
+
+// setupBootstrapHealth keeps the health of the bootstrap peers, probing them
+// every BootstrapCheck.Interval.
+func (n *IpfsNode) setupBootstrapHealth() error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+	conf := cfg.BootstrapCheck
+
+	h, err := bootstrap.New(n.Repo.Datastore())
+	if err != nil {
+		return err
+	}
+	h.Peers = func() []pstore.PeerInfo {
+		ps, err := n.loadBootstrapPeers()
+		if err != nil {
+			log.Warning("failed to parse bootstrap peers from config")
+			return nil
+		}
+		return ps
+	}
+	h.Probe = n.probeBootstrapPeer
+	if conf.DeadAfter != 0 {
+		h.DeadAfter = conf.DeadAfter
+	}
+	if conf.DNSAddrTTL != "" {
+		d, err := time.ParseDuration(conf.DNSAddrTTL)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting BootstrapCheck.DNSAddrTTL: %s", err)
+		}
+		h.DNSAddrTTL = d
+	}
+	if conf.Interval != "" {
+		d, err := time.ParseDuration(conf.Interval)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting BootstrapCheck.Interval: %s", err)
+		}
+		h.Interval = d
+	}
+
+	n.BootstrapHealth = h
+	if h.Interval > 0 {
+		n.Process().Go(h.Run)
+	}
+	return nil
+}
+
+// probeBootstrapPeer connects to a bootstrap peer, returning the time it
+// took, or the latency measured when already connected.
+func (n *IpfsNode) probeBootstrapPeer(ctx context.Context, pi pstore.PeerInfo) (time.Duration, error) {
+	if n.PeerHost.Network().Connectedness(pi.ID) == inet.Connected {
+		return n.Peerstore.LatencyEWMA(pi.ID), nil
+	}
+
+	start := time.Now()
+	n.Peerstore.AddAddrs(pi.ID, pi.Addrs, pstore.TempAddrTTL)
+	if err := n.PeerHost.Connect(ctx, pi); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
@@ -6,6 +6,7 @@ import (
 
 	config "github.com/ipfs/go-ipfs/repo/config"
 	testutil "gx/ipfs/QmPdxCaVp4jZ9RbxqZADvKH6kiCR5jHvdR5f2ycjAY6T2a/go-testutil"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
 	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
 )
 
@@ -26,6 +27,38 @@ func TestSubsetWhenMaxIsGreaterThanLengthOfSlice(t *testing.T) {
 	}
 }
 
+func TestDeadPeersDialedLast(t *testing.T) {
+	var ps []pstore.PeerInfo
+	dead := make(map[peer.ID]bool)
+	for i := 0; i < 10; i++ {
+		pid, err := testutil.RandPeerID()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ps = append(ps, pstore.PeerInfo{ID: pid})
+		dead[pid] = i < 6
+	}
+	isDead := func(p peer.ID) bool { return dead[p] }
+
+	for _, p := range bootstrapCandidates(ps, 4, isDead) {
+		if dead[p.ID] {
+			t.Fatal("expected the live peers to be dialed first")
+		}
+	}
+
+	out := bootstrapCandidates(ps, 6, isDead)
+	deadCount := 0
+	for _, p := range out {
+		if dead[p.ID] {
+			deadCount++
+		}
+	}
+	if len(out) != 6 || deadCount != 2 {
+		t.Fatalf("expected 4 live and 2 dead peers, got %d peers, %d dead", len(out), deadCount)
+	}
+}
+
 func TestMultipleAddrsPerPeer(t *testing.T) {
 	var bsps []config.BootstrapPeer
 	for i := 0; i < 10; i++ {
@@ -10,12 +10,17 @@ import (
 	"time"
 
 	bserv "github.com/ipfs/go-ipfs/blockservice"
+	events "github.com/ipfs/go-ipfs/events"
 	filestore "github.com/ipfs/go-ipfs/filestore"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	resolver "github.com/ipfs/go-ipfs/path/resolver"
 	pin "github.com/ipfs/go-ipfs/pin"
 	repo "github.com/ipfs/go-ipfs/repo"
 	cfg "github.com/ipfs/go-ipfs/repo/config"
+	ephemeral "github.com/ipfs/go-ipfs/repo/ephemeral"
+	pininfo "github.com/ipfs/go-ipfs/repo/pininfo"
+	quota "github.com/ipfs/go-ipfs/repo/quota"
+	cidset "github.com/ipfs/go-ipfs/thirdparty/cidset"
 	"github.com/ipfs/go-ipfs/thirdparty/verifbs"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
@@ -51,6 +56,10 @@ type BuildCfg struct {
 	// If NilRepo is set, a repo backed by a nil datastore will be constructed
 	NilRepo bool
 
+	// Sharding, if set, overrides Experimental.ShardingEnabled, so that nodes
+	// like the NilRepo ones build the directories like the node using them.
+	Sharding *bool
+
 	Routing RoutingOption
 	Host    HostOption
 	Repo    repo.Repo
@@ -140,6 +149,7 @@ func NewNode(ctx context.Context, cfg *BuildCfg) (*IpfsNode, error) {
 		Repo:      cfg.Repo,
 		ctx:       ctx,
 		Peerstore: pstore.NewPeerstore(),
+		Events:    events.NewBus(),
 	}
 	if cfg.Online {
 		n.mode = onlineMode
@@ -182,7 +192,6 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 	bs := bstore.NewBlockstore(rds)
 	bs = &verifbs.VerifBS{Blockstore: bs}
 
-	opts := bstore.DefaultCacheOpts()
 	conf, err := n.Repo.Config()
 	if err != nil {
 		return err
@@ -190,17 +199,16 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 
 	// TEMP: setting global sharding switch here
 	uio.UseHAMTSharding = conf.Experimental.ShardingEnabled
-
-	opts.HasBloomFilterSize = conf.Datastore.BloomFilterSize
-	if !cfg.Permanent {
-		opts.HasBloomFilterSize = 0
+	if cfg.Sharding != nil {
+		uio.UseHAMTSharding = *cfg.Sharding
 	}
 
-	cbs, err := bstore.CachedBlockstore(ctx, bs, opts)
+	cbs, err := newReloadableBlockstore(ctx, bs, conf.Datastore, cfg.Permanent)
 	if err != nil {
 		return err
 	}
 
+	n.blockCache = cbs
 	n.BaseBlocks = cbs
 	n.GCLocker = bstore.NewGCLocker()
 	n.Blockstore = bstore.NewGCBlockstore(cbs, n.GCLocker)
@@ -212,6 +220,18 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		n.Blockstore = &verifbs.VerifBSGC{GCBlockstore: n.Blockstore}
 	}
 
+	n.Blockstore = &eventBlockstore{GCBlockstore: n.Blockstore, bus: n.Events}
+	n.setupGrowthStats(cfg.Online)
+	if err := n.setupUsage(); err != nil {
+		return err
+	}
+	if err := n.setupDiskWatchdog(conf.Datastore.DiskWatchdog); err != nil {
+		return err
+	}
+	n.Quotas = quota.NewStore(n.Repo.Datastore())
+	n.Ephemeral = ephemeral.NewStore(n.Repo.Datastore())
+	n.PinInfo = pininfo.NewStore(n.Repo.Datastore())
+
 	rcfg, err := n.Repo.Config()
 	if err != nil {
 		return err
@@ -240,9 +260,24 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		n.Exchange = offline.Exchange(n.Blockstore)
 	}
 
+	if err := n.setAppliedConfig(rcfg); err != nil {
+		return err
+	}
+
+	if err := n.setupDenylist(rcfg); err != nil {
+		return err
+	}
+
 	n.Blocks = bserv.New(n.Blockstore, n.Exchange)
+	n.Blocks = &denylistBlockService{BlockService: n.Blocks, denylist: n.Denylist}
 	n.DAG = dag.NewDAGService(n.Blocks)
 
+	// no walk runs yet, so the disk backed sets left are the ones of walks
+	// interrupted by a crash
+	if err := cidset.RemoveDiskSets(n.Repo.Datastore()); err != nil {
+		return err
+	}
+
 	internalDag := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
 	n.Pinning, err = pin.LoadPinner(n.Repo.Datastore(), n.DAG, internalDag)
 	if err != nil {
@@ -252,6 +287,7 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		// this is kinda sketchy and could cause data loss
 		n.Pinning = pin.NewPinner(n.Repo.Datastore(), n.DAG, internalDag)
 	}
+	n.Pinning = &quotaPinner{Pinner: n.Pinning, quotas: n.Quotas, dag: n.DAG}
 	n.Resolver = resolver.NewBasicResolver(n.DAG)
 
 	if cfg.Online {
@@ -260,5 +296,16 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		}
 	}
 
-	return n.loadFilesRoot()
+	if err := n.loadFilesRoot(); err != nil {
+		return err
+	}
+
+	if cfg.Online {
+		n.Process().Go(n.Usage.Run)
+		if err := n.setupReplication(); err != nil {
+			return err
+		}
+		return n.setupWebhooks()
+	}
+	return nil
 }
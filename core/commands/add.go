@@ -5,16 +5,22 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	blockservice "github.com/ipfs/go-ipfs/blockservice"
 	core "github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/core/corerepo"
 	"github.com/ipfs/go-ipfs/core/coreunix"
+	erasure "github.com/ipfs/go-ipfs/importer/erasure"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	dagtest "github.com/ipfs/go-ipfs/merkledag/test"
 	mfs "github.com/ipfs/go-ipfs/mfs"
+	config "github.com/ipfs/go-ipfs/repo/config"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	offline "gx/ipfs/QmPf114DXfa6TqGKYhBGR7EtXRho4rCJgwyA1xkuMY5vwF/go-ipfs-exchange-offline"
 	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
 	pb "gx/ipfs/QmPtj12fdwuAqj9sBSTNUxBNu8kCGNp8b3o8yUzMm5GHpq/pb"
@@ -43,6 +49,10 @@ const (
 	fstoreCacheOptionName = "fscache"
 	cidVersionOptionName  = "cid-version"
 	hashOptionName        = "hash"
+	mimeTypeOptionName    = "mime-type"
+	ephemeralOptionName   = "ephemeral"
+	erasureOptionName     = "erasure"
+	continueOptionName    = "continue-on-error"
 )
 
 const adderOutChanSize = 8
@@ -96,6 +106,65 @@ You can now check what blocks have been created by:
   QmY6yj1GsermExDXoosVE3aSPxdMNYr6aKuw3nA8LoWPRS 2059
   QmerURi9k4XzKCaaPbsK6BL5pMEjF7PGphjDvkkjDtsVf3 868
   QmQB28iwSriSUSMqG2nXDTLtdPHgWb4rebBrU7Q1j4vxPv 338
+
+With --mime-type, the MIME type of each file is detected, from its extension
+or otherwise from its first bytes, and stored in a UnixFS metadata node
+wrapping the file. The gateway then serves the file with this Content-Type
+rather than detecting it on every request. The metadata nodes change the
+hashes of the files, so it's off by default.
+
+With --ephemeral, the files aren't pinned, but kept from garbage collection
+for the given time, like '1h', for staging pipelines which read them briefly
+and don't want to unpin them afterwards. They are collected by the first
+garbage collection once expired, unless pinned or in MFS since.
+
+With --only-hash, '-n', the files are chunked and hashed without being
+stored, and the hashes are exactly the ones adding them would give with the
+same options, sharding of the directories included. With --manifest, the
+objects added are written to a JSON file once the add completes, for build
+pipelines which need the hashes of their outputs before publishing them:
+
+  > ipfs add -r -n --manifest=site.json site
+
+The manifest has the hash of the last object added as its Root, and the
+Name, Hash and Size of every file and directory added as its Entries, in
+the order of the output. It's written by the command line client, and isn't
+written when the add fails.
+
+With --prev-manifest, the files of a manifest written by a previous add are
+reused rather than chunked again when their size and modification time
+didn't change, and their blocks are still in the repo, which makes adding
+a site again after changing a few of its files nearly free:
+
+  > ipfs add -r --manifest=site.json site
+  > ipfs add -r --prev-manifest=site.json --manifest=site.json site
+
+The files are only reused when the options changing the hashes, like
+--chunker, --raw-leaves or --cid-version, are the ones of the previous add.
+Since their blocks aren't stored, they aren't reused with --only-hash. The
+node reads the files' modification times from its own filesystem, so when
+a daemon runs the add, they're only reused if it shares the filesystem of
+the client. The manifest itself is read by the client, which sends its
+files to the node: over the HTTP API, the option is the JSON of the
+manifest rather than its path.
+
+With --erasure=<data>+<parity>, like '10+4', the files are erasure-coded for
+archives kept across unreliable peers: each file is cut into stripes of
+<data> blocks of 256KiB, from which <parity> parity blocks are computed, and
+the blocks of each position make a shard, a sub-DAG of its own. The hash of
+the file is the one of a manifest linking the shards. Any <data> shards are
+enough to read it back with 'ipfs cat', which uses the parity shards in
+place of the data shards it can't fetch. The storage grows by
+<parity>/<data>. Erasure-coded files are only read by 'ipfs cat', and can't
+be combined with --trickle, --nocopy or --mime-type.
+
+The results are output as the files are added. With --continue-on-error,
+the files which can't be added are output with the error, without stopping
+the add of the next ones; the command fails at the end when there were any,
+and doesn't pin the directory wrapping them. The blocks are written every
+Datastore.ImportBatch.SyncEvery (32MB by default), and waited for before
+reading more, so that over the HTTP API the client is slowed down to the
+pace the node writes at, rather than buffered in its memory.
 `,
 	},
 
@@ -119,8 +188,27 @@ You can now check what blocks have been created by:
 		cmdkit.BoolOption(fstoreCacheOptionName, "Check the filestore for pre-existing blocks. (experimental)"),
 		cmdkit.IntOption(cidVersionOptionName, "CID version. Defaults to 0 unless an option that depends on CIDv1 is passed. (experimental)"),
 		cmdkit.StringOption(hashOptionName, "Hash function to use. Implies CIDv1 if not sha2-256. (experimental)").WithDefault("sha2-256"),
+		cmdkit.BoolOption(mimeTypeOptionName, "Store the MIME types of the files in UnixFS metadata, for the gateway."),
+		cmdkit.BoolOption(continueOptionName, "Output the files which can't be added with their error, and go on with the next ones."),
+		cmdkit.StringOption(erasureOptionName, "Erasure-code the files into <data>+<parity> shards, like '10+4'."),
+		cmdkit.StringOption(ephemeralOptionName, "Don't pin the files, but keep them from garbage collection for this long, like '1h'."),
+		cmdkit.StringOption(manifestOptionName, "Write the hashes and sizes of the objects added to this file, as JSON."),
+		cmdkit.StringOption(prevManifestOptionName, "Reuse the hashes of the files of this manifest which didn't change since, rather than chunking them again."),
 	},
 	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		// the manifest is read by the client, the node getting its files
+		if prev, _ := req.Options[prevManifestOptionName].(string); prev != "" {
+			m, err := loadAddManifest(prev)
+			if err != nil {
+				return err
+			}
+			enc, err := m.encodePrevious()
+			if err != nil {
+				return err
+			}
+			req.Options[prevManifestOptionName] = enc
+		}
+
 		quiet, _ := req.Options[quietOptionName].(bool)
 		quieter, _ := req.Options[quieterOptionName].(bool)
 		quiet = quiet || quieter
@@ -172,6 +260,42 @@ You can now check what blocks have been created by:
 		fscache, _ := req.Options[fstoreCacheOptionName].(bool)
 		cidVer, cidVerSet := req.Options[cidVersionOptionName].(int)
 		hashFunStr, _ := req.Options[hashOptionName].(string)
+		mimeType, _ := req.Options[mimeTypeOptionName].(bool)
+		ephemeral, _ := req.Options[ephemeralOptionName].(string)
+		prevManifest, _ := req.Options[prevManifestOptionName].(string)
+		erasureStr, _ := req.Options[erasureOptionName].(string)
+		continueOnError, _ := req.Options[continueOptionName].(bool)
+
+		var erasureParams *erasure.Params
+		if erasureStr != "" {
+			erasureParams, err = parseErasure(erasureStr)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrClient)
+				return
+			}
+			if trickle || nocopy || mimeType {
+				res.SetError(errors.New("--erasure can't be combined with --trickle, --nocopy or --mime-type"), cmdkit.ErrClient)
+				return
+			}
+		}
+
+		// fail early rather than after chunking when the disk is full
+		if !hash {
+			if err := n.CheckDiskSpace(); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		var ttl time.Duration
+		if ephemeral != "" {
+			ttl, err = time.ParseDuration(ephemeral)
+			if err != nil || ttl <= 0 {
+				res.SetError(fmt.Errorf("invalid ephemeral duration %q", ephemeral), cmdkit.ErrClient)
+				return
+			}
+			dopin = false
+		}
 
 		// The arguments are subject to the following constraints.
 		//
@@ -235,10 +359,13 @@ You can now check what blocks have been created by:
 		prefix.MhLength = -1
 
 		if hash {
+			// the directories are sharded like the ones of this node
+			sharding := cfg.Experimental.ShardingEnabled
 			nilnode, err := core.NewNode(n.Context(), &core.BuildCfg{
 				//TODO: need this to be true or all files
 				// hashed will be stored in memory!
-				NilRepo: true,
+				NilRepo:  true,
+				Sharding: &sharding,
 			})
 			if err != nil {
 				res.SetError(err, cmdkit.ErrNormal)
@@ -279,13 +406,45 @@ You can now check what blocks have been created by:
 		fileAdder.Silent = silent
 		fileAdder.RawLeaves = rawblks
 		fileAdder.NoCopy = nocopy
+		fileAdder.MimeType = mimeType
 		fileAdder.Prefix = &prefix
+		fileAdder.Erasure = erasureParams
+		fileAdder.ContinueOnError = continueOnError
+		if err := setImportBatch(fileAdder, cfg.Datastore.ImportBatch); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if ttl > 0 && !hash {
+			fileAdder.Ephemeral = n.Ephemeral
+			fileAdder.EphemeralTTL = ttl
+		}
+
+		if prevManifest != "" {
+			prev, err := decodePrevManifest(prevManifest)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrClient)
+				return
+			}
+			if settings := addSettings(req.Options); prev.Settings != settings {
+				log.Warningf("not reusing the files of the previous manifest, added with %s rather than %s", prev.Settings, settings)
+			} else {
+				fileAdder.Previous = prev.previous()
+			}
+		}
+
+		var reservation *corerepo.AddReservation
+		if !hash {
+			reservation = corerepo.ReserveAdd(n, req.Context)
+			if reservation != nil {
+				fileAdder.Reserve = reservation.Reserve
+			}
+		}
 
 		if hash {
 			md := dagtest.Mock()
 			emptyDirNode := ft.EmptyDirNode()
 			// Use the same prefix for the "empty" MFS root as for the file adder.
-			emptyDirNode.Prefix = *fileAdder.Prefix
+			emptyDirNode.SetPrefix(fileAdder.Prefix)
 			mr, err := mfs.NewRoot(req.Context, md, emptyDirNode, nil)
 			if err != nil {
 				res.SetError(err, cmdkit.ErrNormal)
@@ -312,17 +471,45 @@ You can now check what blocks have been created by:
 				}
 			}
 
+			failed := fileAdder.Failed()
+			errFailed := fmt.Errorf("%d files couldn't be added", failed)
+
 			// copy intermediary nodes from editor to our actual dagservice
-			_, err := fileAdder.Finalize()
+			root, err := fileAdder.Finalize()
 			if err != nil {
+				if failed > 0 {
+					// there may be nothing left to finalize
+					return errFailed
+				}
 				return err
 			}
 
-			if hash {
-				return nil
+			if failed > 0 {
+				// a partial directory isn't pinned
+				return errFailed
 			}
 
-			return fileAdder.PinRoot()
+			if !hash {
+				if err := fileAdder.PinRoot(); err != nil {
+					return err
+				}
+			}
+
+			if dopin && !hash {
+				size, err := root.Size()
+				if err != nil {
+					return err
+				}
+				if reservation != nil {
+					if err := reservation.Record(root.Cid(), size); err != nil {
+						return err
+					}
+				}
+				if err := corerepo.RecordPin(n, req.Context, root.Cid()); err != nil {
+					log.Errorf("recording the pin of %s: %s", root.Cid(), err)
+				}
+			}
+			return nil
 		}
 
 		errCh := make(chan error)
@@ -330,6 +517,10 @@ You can now check what blocks have been created by:
 			var err error
 			defer func() { errCh <- err }()
 			defer close(outChan)
+			if reservation != nil {
+				// the DAG added is recorded by now, if pinned
+				defer reservation.Release()
+			}
 			err = addAllAndPin(req.Files)
 		}()
 
@@ -371,6 +562,13 @@ You can now check what blocks have been created by:
 				log.Warning("cannot determine size of input file")
 			}
 
+			var manifest *addManifest
+			manifestPath, _ := req.Options[manifestOptionName].(string)
+			if manifestPath != "" {
+				manifest = &addManifest{Settings: addSettings(req.Options)}
+			}
+			var failed bool
+
 			progressBar := func(wait chan struct{}) {
 				defer close(wait)
 
@@ -393,6 +591,9 @@ You can now check what blocks have been created by:
 				lastFile := ""
 				lastHash := ""
 				var totalProgress, prevFiles, lastBytes int64
+				// the data persisted, in the repo, lags behind the one
+				// hashed the bar shows
+				var prevPersisted, lastPersisted int64
 
 			LOOP:
 				for {
@@ -406,7 +607,15 @@ You can now check what blocks have been created by:
 							break LOOP
 						}
 						output := out.(*coreunix.AddedObject)
-						if len(output.Hash) > 0 {
+						if manifest != nil {
+							manifest.add(output)
+						}
+						if output.Error != "" {
+							if progress {
+								fmt.Fprintf(os.Stderr, "\033[2K\r")
+							}
+							fmt.Fprintf(os.Stderr, "error adding %s: %s\n", output.Name, output.Error)
+						} else if len(output.Hash) > 0 {
 							lastHash = output.Hash
 							if quieter {
 								continue
@@ -432,11 +641,14 @@ You can now check what blocks have been created by:
 							}
 							if output.Name != lastFile || output.Bytes < lastBytes {
 								prevFiles += lastBytes
+								prevPersisted += lastPersisted
 								lastFile = output.Name
 							}
 							lastBytes = output.Bytes
+							lastPersisted = output.Persisted
 							delta := prevFiles + lastBytes - totalProgress
 							totalProgress = bar.Add64(delta)
+							bar.Postfix(fmt.Sprintf(" (%s persisted)", humanize.Bytes(uint64(prevPersisted+lastPersisted))))
 						}
 
 						if progress {
@@ -469,18 +681,29 @@ You can now check what blocks have been created by:
 				wait := make(chan struct{})
 				go progressBar(wait)
 
+				// written once the progress bar went through all the outputs
+				defer func() {
+					if manifest == nil || failed {
+						return
+					}
+					if err := manifest.write(manifestPath); err != nil {
+						re.SetError(fmt.Errorf("writing the manifest: %s", err), cmdkit.ErrNormal)
+					}
+				}()
 				defer func() { <-wait }()
 				defer close(outChan)
 
 				for {
 					v, err := res.Next()
 					if !cmds.HandleError(err, res, re) {
+						failed = err != io.EOF
 						break
 					}
 
 					select {
 					case outChan <- v:
 					case <-req.Context.Done():
+						failed = true
 						re.SetError(req.Context.Err(), cmdkit.ErrNormal)
 						return
 					}
@@ -492,3 +715,54 @@ You can now check what blocks have been created by:
 	},
 	Type: coreunix.AddedObject{},
 }
+
+// parseErasure parses the <data>+<parity> shards of --erasure.
+func parseErasure(s string) (*erasure.Params, error) {
+	parts := strings.Split(s, "+")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid erasure coding %q, expected <data>+<parity> shards, like '10+4'", s)
+	}
+	data, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid number of data shards %q", parts[0])
+	}
+	parity, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid number of parity shards %q", parts[1])
+	}
+	p := &erasure.Params{DataShards: data, ParityShards: parity}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// setImportBatch sets the batch limits of Datastore.ImportBatch on adder.
+// defaultAddSyncBytes is how much data is added before the batches are
+// waited for, unless Datastore.ImportBatch.SyncEvery is set.
+const defaultAddSyncBytes = 32 << 20
+
+func setImportBatch(adder *coreunix.Adder, cfg config.ImportBatch) error {
+	if cfg.MaxNodes < 0 {
+		return fmt.Errorf("invalid config setting Datastore.ImportBatch.MaxNodes: %d", cfg.MaxNodes)
+	}
+	adder.BatchMaxNodes = cfg.MaxNodes
+
+	if cfg.MaxSize != "" {
+		v, err := humanize.ParseBytes(cfg.MaxSize)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting Datastore.ImportBatch.MaxSize: %s", err)
+		}
+		adder.BatchMaxSize = int(v)
+	}
+	if cfg.SyncEvery != "" {
+		v, err := humanize.ParseBytes(cfg.SyncEvery)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting Datastore.ImportBatch.SyncEvery: %s", err)
+		}
+		adder.SyncBytes = v
+	} else {
+		adder.SyncBytes = defaultAddSyncBytes
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+const (
+	manifestOptionName     = "manifest"
+	prevManifestOptionName = "prev-manifest"
+)
+
+// addManifest lists the files and directories added by 'ipfs add', for
+// --manifest.
+type addManifest struct {
+	// Root is the hash of the last object added: the directory wrapping the
+	// files with -w, or the last file or directory given.
+	Root string
+	// Settings are the options of the add which change the hashes of the
+	// files, the files being reused by the next adds with the same ones.
+	Settings string
+	Entries  []addManifestEntry
+}
+
+type addManifestEntry struct {
+	Name string
+	Hash string
+	// Size is the size of the blocks of the object, as output by 'ipfs add'.
+	Size uint64
+	// Bytes and ModTime are the size and the modification time of the files
+	// read from disk.
+	Bytes   int64      `json:",omitempty"`
+	ModTime *time.Time `json:",omitempty"`
+}
+
+// add records the object added, ignoring the progress and error outputs.
+func (m *addManifest) add(o *coreunix.AddedObject) {
+	if o.Hash == "" || o.Error != "" {
+		return
+	}
+
+	size, err := strconv.ParseUint(o.Size, 10, 64)
+	if err != nil {
+		log.Warningf("size %q of %s: %s", o.Size, o.Name, err)
+	}
+	m.Entries = append(m.Entries, addManifestEntry{
+		Name:    o.Name,
+		Hash:    o.Hash,
+		Size:    size,
+		Bytes:   o.FileSize,
+		ModTime: o.ModTime,
+	})
+	m.Root = o.Hash
+}
+
+// write writes the manifest to the file at path, as JSON.
+func (m *addManifest) write(path string) error {
+	if m.Entries == nil {
+		m.Entries = []addManifestEntry{}
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// loadAddManifest reads the manifest written to the file at path.
+func loadAddManifest(path string) (*addManifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := new(addManifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %s", path, err)
+	}
+	return m, nil
+}
+
+// encodePrevious returns the manifest as sent to the node with
+// --prev-manifest: its JSON, without the entries which can't be reused.
+func (m *addManifest) encodePrevious() (string, error) {
+	prev := &addManifest{Root: m.Root, Settings: m.Settings}
+	for _, e := range m.Entries {
+		if e.ModTime != nil {
+			prev.Entries = append(prev.Entries, e)
+		}
+	}
+	data, err := json.Marshal(prev)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodePrevManifest parses the manifest sent with --prev-manifest.
+func decodePrevManifest(s string) (*addManifest, error) {
+	m := new(addManifest)
+	if err := json.Unmarshal([]byte(s), m); err != nil {
+		return nil, fmt.Errorf("invalid --%s: %s", prevManifestOptionName, err)
+	}
+	return m, nil
+}
+
+// previous returns the files of the manifest read from disk, by name.
+func (m *addManifest) previous() map[string]coreunix.PreviousFile {
+	prev := make(map[string]coreunix.PreviousFile)
+	for _, e := range m.Entries {
+		if e.ModTime == nil {
+			continue
+		}
+		c, err := cid.Decode(e.Hash)
+		if err != nil {
+			log.Warningf("hash %q of %s: %s", e.Hash, e.Name, err)
+			continue
+		}
+		prev[e.Name] = coreunix.PreviousFile{Cid: c, Size: e.Bytes, ModTime: *e.ModTime}
+	}
+	return prev
+}
+
+// addSettings returns the options of 'ipfs add' which change the hashes of
+// the files, after the ones implied by the others.
+func addSettings(opts cmdkit.OptMap) string {
+	chunker, _ := opts[chunkerOptionName].(string)
+	if chunker == "" {
+		chunker = "size-262144"
+	}
+	hashFunStr, _ := opts[hashOptionName].(string)
+	hashFunStr = strings.ToLower(hashFunStr)
+	if hashFunStr == "" {
+		hashFunStr = "sha2-256"
+	}
+	cidVer, cidVerSet := opts[cidVersionOptionName].(int)
+	rawblks, rbset := opts[rawLeavesOptionName].(bool)
+	nocopy, _ := opts[noCopyOptionName].(bool)
+	trickle, _ := opts[trickleOptionName].(bool)
+	mimeType, _ := opts[mimeTypeOptionName].(bool)
+
+	if nocopy && !rbset {
+		rawblks = true
+	}
+	if hashFunStr != "sha2-256" && !cidVerSet {
+		cidVer = 1
+	}
+	if cidVer > 0 && !rbset {
+		rawblks = true
+	}
+
+	settings := fmt.Sprintf("chunker=%s,hash=%s,cid-version=%d,raw-leaves=%t,trickle=%t,mime-type=%t",
+		chunker, hashFunStr, cidVer, rawblks, trickle, mimeType)
+	// only set when used, for the manifests written before to stay valid
+	if e, _ := opts[erasureOptionName].(string); e != "" {
+		settings += ",erasure=" + e
+	}
+	return settings
+}
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+
+	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+func TestAddSettings(t *testing.T) {
+	same := [][]cmdkit.OptMap{
+		{
+			{},
+			{chunkerOptionName: "size-262144", hashOptionName: "sha2-256"},
+		},
+		{
+			{cidVersionOptionName: 1},
+			{cidVersionOptionName: 1, rawLeavesOptionName: true},
+			{hashOptionName: "sha2-256", cidVersionOptionName: 1},
+		},
+		{
+			{hashOptionName: "blake2b-256"},
+			{hashOptionName: "BLAKE2B-256", cidVersionOptionName: 1, rawLeavesOptionName: true},
+		},
+		{
+			{noCopyOptionName: true},
+			{rawLeavesOptionName: true},
+		},
+		{
+			{erasureOptionName: "10+4"},
+			{erasureOptionName: "10+4", chunkerOptionName: "size-262144"},
+		},
+	}
+
+	seen := make(map[string]int)
+	for i, opts := range same {
+		settings := addSettings(opts[0])
+		for _, o := range opts[1:] {
+			if s := addSettings(o); s != settings {
+				t.Errorf("expected %v to give %s, got %s", o, settings, s)
+			}
+		}
+		if j, ok := seen[settings]; ok {
+			t.Errorf("expected the settings of %v and %v to differ, got %s for both", same[j][0], opts[0], settings)
+		}
+		seen[settings] = i
+	}
+}
+
+func TestAddManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "add-manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mtime := time.Date(2018, 5, 4, 12, 30, 0, 123456789, time.UTC)
+	m := &addManifest{Settings: addSettings(cmdkit.OptMap{})}
+	m.add(&coreunix.AddedObject{Name: "site", Bytes: 512})
+	m.add(&coreunix.AddedObject{
+		Name:     "site/index.html",
+		Hash:     "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH",
+		Size:     "1090",
+		FileSize: 1082,
+		ModTime:  &mtime,
+	})
+	m.add(&coreunix.AddedObject{Name: "site/missing.html", Error: "no such file"})
+	m.add(&coreunix.AddedObject{Name: "site", Hash: "QmaG4FuMqEBnQNn3C8XJ5bpW8kLs7zq2ZXgHptJHbKDDVx", Size: "1146"})
+
+	path := filepath.Join(dir, "manifest.json")
+	if err := m.write(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadAddManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Root != "QmaG4FuMqEBnQNn3C8XJ5bpW8kLs7zq2ZXgHptJHbKDDVx" || loaded.Settings != m.Settings {
+		t.Fatalf("expected the root and settings written, got %+v", loaded)
+	}
+	if len(loaded.Entries) != 2 || loaded.Entries[1].Size != 1146 {
+		t.Fatalf("expected the file and the directory added, got %+v", loaded.Entries)
+	}
+
+	// the client sends the files of the manifest to the node
+	enc, err := loaded.encodePrevious()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = decodePrevManifest(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Settings != m.Settings || len(loaded.Entries) != 1 {
+		t.Fatalf("expected the settings and the file sent, got %+v", loaded)
+	}
+	if _, err := decodePrevManifest(path); err == nil {
+		t.Fatal("expected a path not to be taken as a manifest")
+	}
+
+	prev := loaded.previous()
+	if len(prev) != 1 {
+		t.Fatalf("expected only the file to be reusable, got %v", prev)
+	}
+	f, ok := prev["site/index.html"]
+	if !ok || f.Size != 1082 || !f.ModTime.Equal(mtime) || f.Cid.String() != "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH" {
+		t.Fatalf("expected site/index.html to be reusable, got %+v", f)
+	}
+}
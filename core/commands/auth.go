@@ -0,0 +1,262 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	apiauth "github.com/ipfs/go-ipfs/apiauth"
+	cmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+type AuthToken struct {
+	Name   string
+	Scopes []string
+	Secret string `json:",omitempty"`
+}
+
+type AuthTokenList struct {
+	Tokens []AuthToken
+}
+
+var AuthCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage access to the HTTP API.",
+		ShortDescription: `
+When at least one token is configured, all requests to the HTTP API must
+carry one of them in an 'Authorization: Bearer <secret>' header. The ipfs
+CLI reads the secret from the IPFS_API_TOKEN environment variable.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"token": authTokenCmd,
+	},
+}
+
+var authTokenCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create, list and revoke API tokens.",
+		ShortDescription: `
+Tokens are stored in the API.Tokens config section. Only a hash of each
+secret is stored, the secret itself is printed once when the token is
+created.
+
+Each token has a list of scopes limiting the commands it may invoke:
+
+  read    commands which only read data (cat, ls, pin ls, ...)
+  pin     the 'pin' commands
+  admin   all commands
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"create": authTokenCreateCmd,
+		"ls":     authTokenListCmd,
+		"rm":     authTokenRemoveCmd,
+	},
+}
+
+var authTokenCreateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create a new API token.",
+		ShortDescription: `
+'ipfs auth token create' generates a new token secret, stores its hash in the
+config and prints the secret. The secret can't be recovered afterwards.
+
+  > ipfs auth token create --scope=read,pin pinning-service
+  pinning-service: read,pin
+  8Yx...
+
+A running daemon accepts the new token immediately.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("name", true, false, "Name of the token."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("scope", "s", "Comma-separated list of scopes: read, pin, admin.").WithDefault("read"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		name := req.Arguments()[0]
+		scopeStr, _, err := req.Option("scope").String()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		scopes, err := parseAuthScopes(scopeStr)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer r.Close()
+		cfg, err := r.Config()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if _, ok := cfg.API.Tokens[name]; ok {
+			res.SetError(fmt.Errorf("token %q already exists", name), cmdkit.ErrClient)
+			return
+		}
+
+		secret, err := apiauth.GenerateSecret()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if cfg.API.Tokens == nil {
+			cfg.API.Tokens = make(map[string]config.APIToken)
+		}
+		cfg.API.Tokens[name] = config.APIToken{
+			Hash:   apiauth.HashSecret(secret),
+			Scopes: scopes,
+		}
+
+		if err := r.SetConfig(cfg); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&AuthToken{Name: name, Scopes: scopes, Secret: secret})
+	},
+	Type: AuthToken{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*AuthToken)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "%s: %s\n%s\n", out.Name, strings.Join(out.Scopes, ","), out.Secret)
+			return buf, nil
+		},
+	},
+}
+
+var authTokenListCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List API tokens.",
+	},
+
+	Run: func(req cmds.Request, res cmds.Response) {
+		cfg, err := req.InvocContext().GetConfig()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		out := &AuthTokenList{Tokens: make([]AuthToken, 0, len(cfg.API.Tokens))}
+		for name, t := range cfg.API.Tokens {
+			out.Tokens = append(out.Tokens, AuthToken{Name: name, Scopes: t.Scopes})
+		}
+		sort.Slice(out.Tokens, func(i, j int) bool {
+			return out.Tokens[i].Name < out.Tokens[j].Name
+		})
+
+		res.SetOutput(out)
+	},
+	Type: AuthTokenList{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*AuthTokenList)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, t := range out.Tokens {
+				fmt.Fprintf(buf, "%s: %s\n", t.Name, strings.Join(t.Scopes, ","))
+			}
+			return buf, nil
+		},
+	},
+}
+
+var authTokenRemoveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Revoke an API token.",
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("name", true, false, "Name of the token to revoke."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		name := req.Arguments()[0]
+
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer r.Close()
+		cfg, err := r.Config()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if _, ok := cfg.API.Tokens[name]; !ok {
+			res.SetError(fmt.Errorf("no token named %q", name), cmdkit.ErrClient)
+			return
+		}
+		delete(cfg.API.Tokens, name)
+
+		if err := r.SetConfig(cfg); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&MessageOutput{fmt.Sprintf("removed token %s\n", name)})
+	},
+	Type: MessageOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: MessageTextMarshaler,
+	},
+}
+
+func parseAuthScopes(s string) ([]string, error) {
+	var scopes []string
+	for _, sc := range strings.Split(s, ",") {
+		sc = strings.TrimSpace(sc)
+		if sc == "" {
+			continue
+		}
+		if _, err := apiauth.ParseScope(sc); err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, sc)
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	return scopes, nil
+}
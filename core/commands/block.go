@@ -116,6 +116,16 @@ It outputs to stdout, and <key> is a base58 encoded multihash.
 	},
 }
 
+// The size of the batches of blocks committed by 'block put --stream'.
+const (
+	blockPutBatchBlocks = 128
+	blockPutBatchSize   = 8 << 20
+)
+
+// blockPutMaxSize is the size the blocks 'block put' holds in memory, without
+// --stream, can add up to.
+const blockPutMaxSize = 256 << 20
+
 var blockPutCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Store input as an IPFS block.",
@@ -125,16 +135,25 @@ It reads from stdin, and <key> is a base58 encoded multihash.
 
 By default CIDv0 is going to be generated. Setting 'mhtype' to anything other
 than 'sha2-256' or format to anything other than 'v0' will result in CIDv1.
+
+Several files can be given, each of them stored as a block. Over the HTTP API
+they are the parts of a multipart body. The blocks are all committed to the
+datastore in a single batch once they are read, which is much faster than
+putting them one by one, so they can add up to at most 256MiB. With --stream,
+they are committed in batches as they are read instead, so that the blocks
+don't have to fit in memory, and the keys of the blocks of each batch are
+output once it's committed.
 `,
 	},
 
 	Arguments: []cmdkit.Argument{
-		cmdkit.FileArg("data", true, false, "The data to be stored as an IPFS block.").EnableStdin(),
+		cmdkit.FileArg("data", true, true, "The data to be stored as IPFS blocks.").EnableStdin(),
 	},
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("format", "f", "cid format for blocks to be created with."),
 		cmdkit.StringOption("mhtype", "multihash hash function").WithDefault("sha2-256"),
 		cmdkit.IntOption("mhlen", "multihash hash length").WithDefault(-1),
+		cmdkit.BoolOption("stream", "Commit the blocks in batches as they are read."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
 		n, err := GetNode(env)
@@ -143,24 +162,6 @@ than 'sha2-256' or format to anything other than 'v0' will result in CIDv1.
 			return
 		}
 
-		file, err := req.Files.NextFile()
-		if err != nil {
-			res.SetError(err, cmdkit.ErrNormal)
-			return
-		}
-
-		data, err := ioutil.ReadAll(file)
-		if err != nil {
-			res.SetError(err, cmdkit.ErrNormal)
-			return
-		}
-
-		err = file.Close()
-		if err != nil {
-			res.SetError(err, cmdkit.ErrNormal)
-			return
-		}
-
 		mhtype, _ := req.Options["mhtype"].(string)
 		mhtval, ok := mh.Names[mhtype]
 		if !ok {
@@ -204,30 +205,71 @@ than 'sha2-256' or format to anything other than 'v0' will result in CIDv1.
 		}
 		pref.MhLength = mhlen
 
-		bcid, err := pref.Sum(data)
-		if err != nil {
-			res.SetError(err, cmdkit.ErrNormal)
-			return
-		}
+		stream, _ := req.Options["stream"].(bool)
+
+		out := make(chan interface{})
+		errCh := make(chan error, 1)
+		go func() {
+			defer close(out)
+
+			var batch []blocks.Block
+			size := 0
+			commit := func() error {
+				if len(batch) == 0 {
+					return nil
+				}
+				if err := n.Blocks.AddBlocks(batch); err != nil {
+					return err
+				}
+				for _, b := range batch {
+					select {
+					case out <- &BlockStat{Key: b.Cid().String(), Size: len(b.RawData())}:
+					case <-req.Context.Done():
+						return req.Context.Err()
+					}
+				}
+				batch, size = nil, 0
+				return nil
+			}
 
-		b, err := blocks.NewBlockWithCid(data, bcid)
-		if err != nil {
-			res.SetError(err, cmdkit.ErrNormal)
-			return
-		}
+			for {
+				file, err := req.Files.NextFile()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					errCh <- err
+					return
+				}
+				b, err := readBlock(file, pref)
+				if err != nil {
+					errCh <- err
+					return
+				}
+
+				batch = append(batch, b)
+				size += len(b.RawData())
+				if !stream && size > blockPutMaxSize {
+					errCh <- fmt.Errorf("the blocks add up to more than %d bytes, use --stream to put them in batches", blockPutMaxSize)
+					return
+				}
+				if stream && (len(batch) >= blockPutBatchBlocks || size >= blockPutBatchSize) {
+					if err := commit(); err != nil {
+						errCh <- err
+						return
+					}
+				}
+			}
+			errCh <- commit()
+		}()
 
-		err = n.Blocks.AddBlock(b)
-		if err != nil {
-			res.SetError(err, cmdkit.ErrNormal)
+		defer res.Close()
+		if err := res.Emit(out); err != nil {
+			log.Error(err)
 			return
 		}
-
-		err = cmds.EmitOnce(res, &BlockStat{
-			Key:  b.Cid().String(),
-			Size: len(data),
-		})
-		if err != nil {
-			log.Error(err)
+		if err := <-errCh; err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
 		}
 	},
 	Encoders: cmds.EncoderMap{
@@ -243,6 +285,26 @@ than 'sha2-256' or format to anything other than 'v0' will result in CIDv1.
 	Type: BlockStat{},
 }
 
+// readBlock reads the data of file as a block with a CID of pref.
+func readBlock(file io.ReadCloser, pref cid.Prefix) (blocks.Block, error) {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	bcid, err := pref.Sum(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return blocks.NewBlockWithCid(data, bcid)
+}
+
 func getBlockForKey(ctx context.Context, env cmds.Environment, skey string) (blocks.Block, error) {
 	if len(skey) == 0 {
 		return nil, fmt.Errorf("zero length cid invalid")
@@ -3,8 +3,13 @@ package commands
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"sort"
+	"text/tabwriter"
+	"time"
+
+	bootstrap "github.com/ipfs/go-ipfs/bootstrap"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
@@ -34,9 +39,10 @@ Running 'ipfs bootstrap' with no arguments will run 'ipfs bootstrap list'.
 	Type:       bootstrapListCmd.Type,
 
 	Subcommands: map[string]*cmds.Command{
-		"list": bootstrapListCmd,
-		"add":  bootstrapAddCmd,
-		"rm":   bootstrapRemoveCmd,
+		"list":  bootstrapListCmd,
+		"add":   bootstrapAddCmd,
+		"rm":    bootstrapRemoveCmd,
+		"check": bootstrapCheckCmd,
 	},
 }
 
@@ -344,6 +350,167 @@ var bootstrapListCmd = &cmds.Command{
 	},
 }
 
+// BootstrapCheckOutput is the output of 'ipfs bootstrap check'.
+type BootstrapCheckOutput struct {
+	Peers []bootstrap.Status
+	// Pruned are the entries removed with --prune.
+	Pruned []string `json:",omitempty"`
+}
+
+var bootstrapCheckCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Check the reachability of the bootstrap peers.",
+		ShortDescription: `
+'ipfs bootstrap check' probes the bootstrap peers, and prints the result along
+with their reachability history. It requires a running daemon.
+`,
+		LongDescription: `
+'ipfs bootstrap check' probes the bootstrap peers, and prints the result along
+with their reachability history. It requires a running daemon.
+
+The daemon probes the bootstrap peers every BootstrapCheck.Interval (1 hour by
+default) and keeps their last 24 checks. The peers which failed
+BootstrapCheck.DeadAfter probes in a row (5 by default) are marked as dead:
+they are only dialed when the other peers aren't enough to bootstrap. A dead
+peer comes back to life as soon as a probe succeeds.
+
+The /dnsaddr entries are resolved to the addresses of their peer by the
+probes, which cache them for BootstrapCheck.DNSAddrTTL (1 hour by default).
+The cached addresses are dialed directly when bootstrapping.
+
+With --cached, the peers aren't probed, and the last checks are printed.
+With --prune, the entries of the dead peers are removed from the bootstrap
+list.
+` + bootstrapSecurityWarning,
+	},
+
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("cached", "Print the last checks without probing the peers."),
+		cmdkit.BoolOption("prune", "Remove the dead peers from the bootstrap list."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() || n.BootstrapHealth == nil {
+			res.SetError(errNotOnline, cmdkit.ErrClient)
+			return
+		}
+		h := n.BootstrapHealth
+
+		cached, _, _ := req.Option("cached").Bool()
+		prune, _, _ := req.Option("prune").Bool()
+
+		var out BootstrapCheckOutput
+		if cached {
+			out.Peers = h.Status(h.Cached(h.Peers()))
+		} else {
+			out.Peers, err = h.CheckAll(req.Context())
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		if prune {
+			out.Pruned, err = bootstrapPrune(req.InvocContext().ConfigRoot, out.Peers)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		res.SetOutput(&out)
+	},
+	Type: BootstrapCheckOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*BootstrapCheckOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			tw := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "Peer\tStatus\tLast check\tChecks OK\tLast OK")
+			for _, p := range out.Peers {
+				status, last, lastOK := "unchecked", "-", "-"
+				if p.Last != nil {
+					if p.Last.OK {
+						status = "ok"
+						last = p.Last.Latency.Round(time.Millisecond).String()
+					} else {
+						status = "failed"
+						last = p.Last.Error
+					}
+				}
+				if p.Dead {
+					status = "dead"
+				}
+				if !p.LastOK.IsZero() {
+					lastOK = p.LastOK.Local().Format("2006-01-02 15:04")
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d/%d\t%s\n", p.ID, status, last, p.OK, p.Checks, lastOK)
+			}
+			if err := tw.Flush(); err != nil {
+				return nil, err
+			}
+
+			err = bootstrapWritePeers(buf, "removed ", out.Pruned)
+			return buf, err
+		},
+	},
+}
+
+// bootstrapPrune removes the entries of the dead peers from the bootstrap
+// list.
+func bootstrapPrune(configRoot string, statuses []bootstrap.Status) ([]string, error) {
+	dead := make(map[string]bool)
+	for _, st := range statuses {
+		if st.Dead {
+			dead[st.ID] = true
+		}
+	}
+	if len(dead) == 0 {
+		return nil, nil
+	}
+
+	r, err := fsrepo.Open(configRoot)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	peers, err := cfg.BootstrapPeers()
+	if err != nil {
+		return nil, err
+	}
+	var toRemove []config.BootstrapPeer
+	for _, p := range peers {
+		if dead[p.ID().Pretty()] {
+			toRemove = append(toRemove, p)
+		}
+	}
+
+	removed, err := bootstrapRemove(r, cfg, toRemove)
+	if err != nil {
+		return nil, err
+	}
+	return config.BootstrapPeerStrings(removed), nil
+}
+
 func bootstrapMarshaler(res cmds.Response) (io.Reader, error) {
 	v, err := unwrapOutput(res.Output())
 	if err != nil {
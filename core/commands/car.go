@@ -0,0 +1,286 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	car "github.com/ipfs/go-ipfs/car"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+var CarCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect CAR files.",
+		ShortDescription: `
+'ipfs car' lists and checks the blocks of CAR (Content Addressable aRchive)
+files, without importing them into the repo.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":     carLsCmd,
+		"verify": carVerifyCmd,
+		"stat":   carStatCmd,
+	},
+}
+
+// CarLsEntry is a root or a block of a CAR file, listed by 'ipfs car ls'.
+type CarLsEntry struct {
+	Cid  *cid.Cid
+	Root bool   `json:",omitempty"`
+	Size uint64 `json:",omitempty"`
+}
+
+// CarProblem is a block of a CAR file found invalid or missing by
+// 'ipfs car verify'.
+type CarProblem struct {
+	Cid     *cid.Cid
+	Problem string
+}
+
+// CarStat is the output of 'ipfs car stat'.
+type CarStat struct {
+	Version uint64
+	Roots   []*cid.Cid
+	// Blocks is the number of blocks, and Size the size of their data.
+	Blocks int
+	Size   uint64
+	// Invalid is the number of blocks not matching their CID or not
+	// decodable, and Missing the number of blocks linked from the roots
+	// absent from the file.
+	Invalid int
+	Missing int
+}
+
+var carFileArg = cmdkit.FileArg("file", true, false, "The CAR file.").EnableStdin()
+
+// openCar returns a reader of the CAR file given to the command.
+func openCar(req *cmds.Request) (*car.Reader, error) {
+	f, err := req.Files.NextFile()
+	if err != nil {
+		return nil, err
+	}
+	if f.IsDirectory() {
+		return nil, errors.New("expected a car file, got a directory")
+	}
+	return car.NewReader(f)
+}
+
+// emitCar runs read on the CAR file given to the command, in the background,
+// and emits the values it sends.
+func emitCar(req *cmds.Request, res cmds.ResponseEmitter, read func(cr *car.Reader, emit func(interface{}) error) error) {
+	cr, err := openCar(req)
+	if err != nil {
+		res.SetError(err, cmdkit.ErrNormal)
+		return
+	}
+
+	out := make(chan interface{})
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(out)
+		errCh <- read(cr, func(v interface{}) error {
+			select {
+			case out <- v:
+				return nil
+			case <-req.Context.Done():
+				return req.Context.Err()
+			}
+		})
+	}()
+
+	defer res.Close()
+	if err := res.Emit(out); err != nil {
+		log.Error(err)
+		return
+	}
+	if err := <-errCh; err != nil {
+		res.SetError(err, cmdkit.ErrNormal)
+	}
+}
+
+var carLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the roots and the blocks of a CAR file.",
+		ShortDescription: `
+'ipfs car ls' lists the roots of a CAR file, then its blocks, with the size of
+their data:
+
+  > ipfs car ls site.car
+  root  QmRoot...
+  block QmRoot... 215
+  block QmLeaf... 262158
+
+The blocks aren't checked, see 'ipfs car verify'.
+`,
+	},
+	Arguments: []cmdkit.Argument{carFileArg},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		emitCar(req, res, func(cr *car.Reader, emit func(interface{}) error) error {
+			for _, c := range cr.Header.Roots {
+				if err := emit(&CarLsEntry{Cid: c, Root: true}); err != nil {
+					return err
+				}
+			}
+			for {
+				b, err := cr.Next()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if err := emit(&CarLsEntry{Cid: b.Cid(), Size: uint64(len(b.RawData()))}); err != nil {
+					return err
+				}
+			}
+		})
+	},
+	Type: CarLsEntry{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			entry, ok := v.(*CarLsEntry)
+			if !ok {
+				return e.TypeErr(entry, v)
+			}
+			var err error
+			if entry.Root {
+				_, err = fmt.Fprintf(w, "root  %s\n", entry.Cid)
+			} else {
+				_, err = fmt.Fprintf(w, "block %s %d\n", entry.Cid, entry.Size)
+			}
+			return err
+		}),
+	},
+}
+
+var carVerifyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Check the blocks of a CAR file.",
+		ShortDescription: `
+'ipfs car verify' checks that the data of every block of a CAR file matches
+its CID, and that the file holds the complete DAGs of its roots, and prints
+the blocks failing:
+
+  > ipfs car verify site.car
+  QmLeaf...: hash mismatch: expected QmLeaf..., got QmOther...
+  QmGone...: missing
+  Error: 2 problems found
+
+Nothing is imported into the repo.
+`,
+	},
+	Arguments: []cmdkit.Argument{carFileArg},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		emitCar(req, res, func(cr *car.Reader, emit func(interface{}) error) error {
+			problems := 0
+			found := func(c *cid.Cid, problem string) error {
+				problems++
+				return emit(&CarProblem{Cid: c, Problem: problem})
+			}
+
+			ck := car.NewChecker()
+			for {
+				b, err := cr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				if err := ck.Add(b); err != nil {
+					if err := found(b.Cid(), err.Error()); err != nil {
+						return err
+					}
+				}
+			}
+			for _, c := range ck.Missing(cr.Header.Roots) {
+				if err := found(c, "missing"); err != nil {
+					return err
+				}
+			}
+
+			if problems > 0 {
+				return fmt.Errorf("%d problems found", problems)
+			}
+			return nil
+		})
+	},
+	Type: CarProblem{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			p, ok := v.(*CarProblem)
+			if !ok {
+				return e.TypeErr(p, v)
+			}
+			_, err := fmt.Fprintf(w, "%s: %s\n", p.Cid, p.Problem)
+			return err
+		}),
+	},
+}
+
+var carStatCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print statistics about a CAR file.",
+		ShortDescription: `
+'ipfs car stat' prints the roots of a CAR file, the number of its blocks and
+the size of their data, and the number of blocks invalid, and missing from
+the DAGs of the roots. Nothing is imported into the repo.
+`,
+	},
+	Arguments: []cmdkit.Argument{carFileArg},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		cr, err := openCar(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		stat := &CarStat{
+			Version: cr.Header.Version,
+			Roots:   cr.Header.Roots,
+		}
+		ck := car.NewChecker()
+		for {
+			b, err := cr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			stat.Blocks++
+			stat.Size += uint64(len(b.RawData()))
+			if err := ck.Add(b); err != nil {
+				stat.Invalid++
+			}
+		}
+		stat.Missing = len(ck.Missing(cr.Header.Roots))
+
+		cmds.EmitOnce(res, stat)
+	},
+	Type: CarStat{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			stat, ok := v.(*CarStat)
+			if !ok {
+				return e.TypeErr(stat, v)
+			}
+			fmt.Fprintf(w, "Version: %d\n", stat.Version)
+			fmt.Fprintf(w, "Roots:\n")
+			for _, c := range stat.Roots {
+				fmt.Fprintf(w, "\t%s\n", c)
+			}
+			fmt.Fprintf(w, "Blocks: %d\n", stat.Blocks)
+			fmt.Fprintf(w, "Size: %d\n", stat.Size)
+			fmt.Fprintf(w, "Invalid blocks: %d\n", stat.Invalid)
+			_, err := fmt.Fprintf(w, "Missing blocks: %d\n", stat.Missing)
+			return err
+		}),
+	},
+}
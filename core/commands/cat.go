@@ -27,6 +27,7 @@ var CatCmd = &cmds.Command{
 	Options: []cmdkit.Option{
 		cmdkit.IntOption("offset", "o", "Byte offset to begin reading from."),
 		cmdkit.IntOption("length", "l", "Maximum number of bytes to read."),
+		cmdkit.BoolOption("follow-symlinks", "Follow the unixfs symlinks of the paths to their targets."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
 		node, err := GetNode(env)
@@ -67,7 +68,9 @@ var CatCmd = &cmds.Command{
 			return
 		}
 
-		readers, length, err := cat(req.Context, node, req.Arguments, int64(offset), int64(max))
+		followSymlinks, _ := req.Options["follow-symlinks"].(bool)
+
+		readers, length, err := cat(req.Context, node, req.Arguments, int64(offset), int64(max), followSymlinks)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -133,14 +136,18 @@ var CatCmd = &cmds.Command{
 	},
 }
 
-func cat(ctx context.Context, node *core.IpfsNode, paths []string, offset int64, max int64) ([]io.Reader, uint64, error) {
+func cat(ctx context.Context, node *core.IpfsNode, paths []string, offset int64, max int64, followSymlinks bool) ([]io.Reader, uint64, error) {
 	readers := make([]io.Reader, 0, len(paths))
 	length := uint64(0)
 	if max == 0 {
 		return nil, 0, nil
 	}
+	catPath := coreunix.Cat
+	if followSymlinks {
+		catPath = coreunix.CatFollowingSymlinks
+	}
 	for _, fpath := range paths {
-		read, err := coreunix.Cat(ctx, node, fpath)
+		read, err := catPath(ctx, node, fpath)
 		if err != nil {
 			return nil, 0, err
 		}
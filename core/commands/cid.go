@@ -0,0 +1,480 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+	mbase "gx/ipfs/QmexBtiTTEwwn42Yi6ouKt6VqzpA6wjJgiW1oh9VfaRrup/go-multibase"
+)
+
+var CidCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Convert and discover properties of CIDs.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"format": cidFmtCmd,
+		"base32": base32Cmd,
+		"bases":  basesCmd,
+		"codecs": codecsCmd,
+		"hashes": hashesCmd,
+	},
+}
+
+const (
+	cidFormatOptionName    = "f"
+	cidToVersionOptionName = "v"
+	cidMultibaseOptionName = "b"
+)
+
+var cidFmtCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Format and convert a CID in various useful ways.",
+		LongDescription: `
+Format and converts <cid>'s in various useful ways.
+
+The optional format string is a printf style format string:
+` + cidFormatHelp,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, true, "CIDs to format.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(cidFormatOptionName, "Printf style format string.").WithDefault("%s"),
+		cmdkit.StringOption(cidToVersionOptionName, "CID version to convert to."),
+		cmdkit.StringOption(cidMultibaseOptionName, "Multibase to display CID in."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		fmtStr, _ := req.Options[cidFormatOptionName].(string)
+		verStr, _ := req.Options[cidToVersionOptionName].(string)
+		baseStr, _ := req.Options[cidMultibaseOptionName].(string)
+
+		opts := cidFormatOpts{fmtStr: fmtStr, base: mbase.Base58BTC, version: -1}
+
+		if baseStr != "" {
+			base, err := multibaseByName(baseStr)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrClient)
+				return
+			}
+			opts.base = base
+		}
+
+		switch verStr {
+		case "":
+			// CIDv0 can only be written in base58btc
+			if opts.base != mbase.Base58BTC {
+				opts.version = 1
+			}
+		case "0":
+			if opts.base != mbase.Base58BTC {
+				res.SetError(errors.New("cannot convert to CIDv0 with an explicit multibase"), cmdkit.ErrClient)
+				return
+			}
+			opts.version = 0
+		case "1":
+			opts.version = 1
+		default:
+			res.SetError(fmt.Errorf("invalid cid version: %s", verStr), cmdkit.ErrClient)
+			return
+		}
+
+		emitCids(req, res, opts)
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: printCidErrors,
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			r, ok := v.(*CidFormatRes)
+			if !ok {
+				return e.TypeErr(r, v)
+			}
+			if r.ErrorMsg != "" {
+				return nil
+			}
+			_, err := fmt.Fprintln(w, r.Formatted)
+			return err
+		}),
+	},
+	Type: CidFormatRes{},
+}
+
+// CidFormatRes is the output of 'ipfs cid format' and 'ipfs cid base32' for
+// a single CID.
+type CidFormatRes struct {
+	CidStr    string // Original Cid String passed in
+	Formatted string // Formatted result
+	ErrorMsg  string // Error
+}
+
+var base32Cmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Convert CIDs to Base32 CID version 1.",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, true, "CIDs to convert.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		emitCids(req, res, cidFormatOpts{
+			fmtStr:  "%s",
+			base:    mbase.Base32,
+			version: 1,
+		})
+	},
+	PostRun:  cidFmtCmd.PostRun,
+	Encoders: cidFmtCmd.Encoders,
+	Type:     cidFmtCmd.Type,
+}
+
+type cidFormatOpts struct {
+	fmtStr  string
+	base    mbase.Encoding
+	version int // -1 keeps the version of each CID
+}
+
+func emitCids(req *cmds.Request, res cmds.ResponseEmitter, opts cidFormatOpts) {
+	for _, cidStr := range req.Arguments {
+		out := &CidFormatRes{CidStr: cidStr}
+		formatted, err := convertAndFormatCid(cidStr, opts)
+		if err != nil {
+			out.ErrorMsg = err.Error()
+		} else {
+			out.Formatted = formatted
+		}
+		if err := res.Emit(out); err != nil {
+			log.Error(err)
+			return
+		}
+	}
+}
+
+// printCidErrors prints the CIDs which couldn't be formatted to stderr and
+// makes the command fail when there were any.
+func printCidErrors(req *cmds.Request, re cmds.ResponseEmitter) cmds.ResponseEmitter {
+	reNext, res := cmds.NewChanResponsePair(req)
+
+	go func() {
+		defer re.Close()
+
+		failed := false
+		for {
+			v, err := res.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				re.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+
+			if r, ok := v.(*CidFormatRes); ok && r.ErrorMsg != "" {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", r.CidStr, r.ErrorMsg)
+				failed = true
+			}
+			if err := re.Emit(v); err != nil {
+				return
+			}
+		}
+		if failed {
+			re.SetError(errors.New("errors while formatting CIDs"), cmdkit.ErrNormal)
+		}
+	}()
+
+	return reNext
+}
+
+func convertAndFormatCid(cidStr string, opts cidFormatOpts) (string, error) {
+	c, err := cid.Decode(cidStr)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case opts.version == 0 && c.Version() != 0:
+		if c.Type() != cid.DagProtobuf {
+			return "", errors.New("can't convert a non dag-pb CID to CIDv0")
+		}
+		dec, err := mh.Decode(c.Hash())
+		if err != nil {
+			return "", err
+		}
+		if dec.Code != mh.SHA2_256 || dec.Length != 32 {
+			return "", errors.New("can't convert a CID with a hash other than sha2-256 to CIDv0")
+		}
+		c = cid.NewCidV0(c.Hash())
+	case opts.version == 1 && c.Version() != 1:
+		c = cid.NewCidV1(c.Type(), c.Hash())
+	}
+
+	return formatCid(opts.fmtStr, opts.base, c)
+}
+
+const cidFormatHelp = `
+  %% literal %
+  %b multibase name
+  %B multibase code
+  %v version string
+  %V version number
+  %c codec name
+  %C codec code
+  %h multihash name
+  %H multihash code
+  %L hash digest length
+  %m multihash encoded in the multibase, with the multibase prefix
+  %M multihash encoded in the multibase, without the multibase prefix
+  %d hash digest encoded in the multibase, with the multibase prefix
+  %D hash digest encoded in the multibase, without the multibase prefix
+  %s CID encoded in the multibase
+  %S CID encoded in the multibase, without the multibase prefix
+  %P CID prefix: cidv<version>-<codec>-<hash>-<digest length>
+`
+
+// formatCid formats c according to the format string f, see cidFormatHelp.
+// CIDv0 can only be encoded in base58btc, which has no multibase prefix.
+func formatCid(f string, base mbase.Encoding, c *cid.Cid) (string, error) {
+	dec, err := mh.Decode(c.Hash())
+	if err != nil {
+		return "", err
+	}
+
+	encode := func(data []byte, prefix bool) (string, error) {
+		s, err := mbase.Encode(base, data)
+		if err != nil {
+			return "", err
+		}
+		if !prefix {
+			_, size := utf8.DecodeRuneInString(s)
+			s = s[size:]
+		}
+		return s, nil
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(f); i++ {
+		if f[i] != '%' {
+			out.WriteByte(f[i])
+			continue
+		}
+		i++
+		if i >= len(f) {
+			return "", errors.New("format string ends with a lone %")
+		}
+
+		var s string
+		switch f[i] {
+		case '%':
+			s = "%"
+		case 'b':
+			s = multibaseName(base)
+		case 'B':
+			s = string(rune(base))
+		case 'v':
+			s = fmt.Sprintf("cidv%d", c.Version())
+		case 'V':
+			s = strconv.FormatUint(c.Version(), 10)
+		case 'c':
+			s = codecName(c.Type())
+		case 'C':
+			s = strconv.FormatUint(c.Type(), 10)
+		case 'h':
+			s = dec.Name
+		case 'H':
+			s = strconv.FormatUint(dec.Code, 10)
+		case 'L':
+			s = strconv.Itoa(dec.Length)
+		case 'm', 'M':
+			s, err = encode(c.Hash(), f[i] == 'm')
+		case 'd', 'D':
+			s, err = encode(dec.Digest, f[i] == 'd')
+		case 's', 'S':
+			if c.Version() == 0 {
+				if base != mbase.Base58BTC {
+					return "", errors.New("CIDv0 can only be encoded in base58btc")
+				}
+				s = c.String()
+			} else {
+				s, err = encode(c.Bytes(), f[i] == 's')
+			}
+		case 'P':
+			s = fmt.Sprintf("cidv%d-%s-%s-%d", c.Version(), codecName(c.Type()), dec.Name, dec.Length)
+		default:
+			return "", fmt.Errorf("unrecognized specifier in format string: %%%c", f[i])
+		}
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(s)
+	}
+	return out.String(), nil
+}
+
+func codecName(code uint64) string {
+	if name, ok := cid.CodecToStr[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("codec?%d", code)
+}
+
+// multibases lists the multibases CIDs can be encoded in.
+var multibases = []CodeAndName{
+	{int(mbase.Base16), "base16"},
+	{int(mbase.Base16Upper), "base16upper"},
+	{int(mbase.Base32), "base32"},
+	{int(mbase.Base32Upper), "base32upper"},
+	{int(mbase.Base32pad), "base32pad"},
+	{int(mbase.Base32padUpper), "base32padupper"},
+	{int(mbase.Base32hex), "base32hex"},
+	{int(mbase.Base32hexUpper), "base32hexupper"},
+	{int(mbase.Base32hexPad), "base32hexpad"},
+	{int(mbase.Base32hexPadUpper), "base32hexpadupper"},
+	{int(mbase.Base58BTC), "base58btc"},
+	{int(mbase.Base58Flickr), "base58flickr"},
+	{int(mbase.Base64), "base64"},
+	{int(mbase.Base64url), "base64url"},
+	{int(mbase.Base64pad), "base64pad"},
+	{int(mbase.Base64urlPad), "base64urlpad"},
+}
+
+func multibaseName(base mbase.Encoding) string {
+	for _, b := range multibases {
+		if b.Code == int(base) {
+			return b.Name
+		}
+	}
+	return fmt.Sprintf("base?%c", rune(base))
+}
+
+// multibaseByName looks up a multibase by its name or its single character
+// code.
+func multibaseByName(name string) (mbase.Encoding, error) {
+	for _, b := range multibases {
+		if b.Name == name || string(rune(b.Code)) == name {
+			return mbase.Encoding(b.Code), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown multibase: %s, see 'ipfs cid bases'", name)
+}
+
+// CodeAndName is a multicodec or multibase code and its name.
+type CodeAndName struct {
+	Code int
+	Name string
+}
+
+const (
+	cidPrefixOptionName  = "prefix"
+	cidNumericOptionName = "numeric"
+)
+
+var basesCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List available multibase encodings.",
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(cidPrefixOptionName, "also include the single letter prefixes in addition to the code"),
+		cmdkit.BoolOption(cidNumericOptionName, "also include numeric codes"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		out := multibases
+		cmds.EmitOnce(res, &out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			prefixes, _ := req.Options[cidPrefixOptionName].(bool)
+			numeric, _ := req.Options[cidNumericOptionName].(bool)
+			val, ok := v.(*[]CodeAndName)
+			if !ok {
+				return e.TypeErr(val, v)
+			}
+			for _, v := range *val {
+				switch {
+				case prefixes && numeric:
+					fmt.Fprintf(w, "%c %5d  %s\n", rune(v.Code), v.Code, v.Name)
+				case prefixes:
+					fmt.Fprintf(w, "%c  %s\n", rune(v.Code), v.Name)
+				case numeric:
+					fmt.Fprintf(w, "%5d  %s\n", v.Code, v.Name)
+				default:
+					fmt.Fprintf(w, "%s\n", v.Name)
+				}
+			}
+			return nil
+		}),
+	},
+	Type: []CodeAndName{},
+}
+
+var codecsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List available CID codecs.",
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption(cidNumericOptionName, "also include numeric codes"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		var out []CodeAndName
+		for code, name := range cid.CodecToStr {
+			out = append(out, CodeAndName{int(code), name})
+		}
+		sortCodeAndNames(out)
+		cmds.EmitOnce(res, &out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: numericCodesEncoder,
+	},
+	Type: []CodeAndName{},
+}
+
+var hashesCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List available multihashes.",
+	},
+	Options: codecsCmd.Options,
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		var out []CodeAndName
+		for code, name := range mh.Codes {
+			out = append(out, CodeAndName{int(code), name})
+		}
+		sortCodeAndNames(out)
+		cmds.EmitOnce(res, &out)
+	},
+	Encoders: codecsCmd.Encoders,
+	Type:     codecsCmd.Type,
+}
+
+var numericCodesEncoder = cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+	numeric, _ := req.Options[cidNumericOptionName].(bool)
+	val, ok := v.(*[]CodeAndName)
+	if !ok {
+		return e.TypeErr(val, v)
+	}
+	for _, v := range *val {
+		if numeric {
+			fmt.Fprintf(w, "%5d  %s\n", v.Code, v.Name)
+		} else {
+			fmt.Fprintf(w, "%s\n", v.Name)
+		}
+	}
+	return nil
+})
+
+func sortCodeAndNames(l []CodeAndName) {
+	sort.Slice(l, func(i, j int) bool {
+		if l[i].Code != l[j].Code {
+			return l[i].Code < l[j].Code
+		}
+		return l[i].Name < l[j].Name
+	})
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"testing"
+
+	mbase "gx/ipfs/QmexBtiTTEwwn42Yi6ouKt6VqzpA6wjJgiW1oh9VfaRrup/go-multibase"
+)
+
+func TestCidFormat(t *testing.T) {
+	const (
+		v0 = "QmUJPTFZnR2CPGAzmfdYPghgrFtYFB6pf1BqMvqfiPDam8"
+		v1 = "bafybeicysg23kiwv34eg2d7qweipxwosdo2py4ldv42nbauguluen5v6am"
+	)
+
+	cases := []struct {
+		cid  string
+		opts cidFormatOpts
+		out  string
+	}{
+		{v0, cidFormatOpts{"%s", mbase.Base58BTC, -1}, v0},
+		{v0, cidFormatOpts{"%s", mbase.Base32, 1}, v1},
+		{v1, cidFormatOpts{"%s", mbase.Base58BTC, 0}, v0},
+		{v1, cidFormatOpts{"%S", mbase.Base32, -1}, v1[1:]},
+		{v1, cidFormatOpts{"%s", mbase.Base16, -1}, "f017012205891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"},
+		{v0, cidFormatOpts{"%P", mbase.Base58BTC, -1}, "cidv0-protobuf-sha2-256-32"},
+		{v1, cidFormatOpts{"%b %B %v %V %c %C %h %H %L %%", mbase.Base32, -1}, "base32 b cidv1 1 protobuf 112 sha2-256 18 32 %"},
+		{v1, cidFormatOpts{"%D", mbase.Base32, -1}, "lci3lnjc2xpqq3ip6cyrb66z2in3j7drmoxtjuecq2roqrxwxybq"},
+	}
+
+	for _, c := range cases {
+		out, err := convertAndFormatCid(c.cid, c.opts)
+		if err != nil {
+			t.Errorf("%s %q: %s", c.cid, c.opts.fmtStr, err)
+			continue
+		}
+		if out != c.out {
+			t.Errorf("%s %q: got %q, expected %q", c.cid, c.opts.fmtStr, out, c.out)
+		}
+	}
+
+	for _, f := range []string{"%", "%x"} {
+		if _, err := convertAndFormatCid(v1, cidFormatOpts{f, mbase.Base32, -1}); err == nil {
+			t.Errorf("format %q should fail", f)
+		}
+	}
+	if _, err := convertAndFormatCid(v0, cidFormatOpts{"%s", mbase.Base32, -1}); err == nil {
+		t.Error("encoding a CIDv0 in base32 should fail")
+	}
+}
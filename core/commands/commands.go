@@ -68,6 +68,9 @@ func CommandsCmd(root *cmds.Command) *cmds.Command {
 		Options: []cmdkit.Option{
 			cmdkit.BoolOption(flagsOptionName, "f", "Show command flags"),
 		},
+		Subcommands: map[string]*cmds.Command{
+			"completion": completionCmd(root),
+		},
 		Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
 			rootCmd := cmd2outputCmd("ipfs", root)
 			rootCmd.showOpts, _ = req.Options[flagsOptionName].(bool)
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"runtime"
 	"strings"
 	"testing"
 
@@ -20,11 +21,18 @@ func TestROCommands(t *testing.T) {
 		"/block",
 		"/block/get",
 		"/block/stat",
+		"/car",
+		"/car/ls",
+		"/car/stat",
+		"/car/verify",
 		"/cat",
 		"/commands",
+		"/commands/completion",
 		"/dag",
 		"/dag/get",
 		"/dag/resolve",
+		"/dir",
+		"/dir/lookup",
 		"/dns",
 		"/get",
 		"/ls",
@@ -69,6 +77,11 @@ func TestROCommands(t *testing.T) {
 func TestCommands(t *testing.T) {
 	list := []string{
 		"/add",
+		"/auth",
+		"/auth/token",
+		"/auth/token/create",
+		"/auth/token/ls",
+		"/auth/token/rm",
 		"/bitswap",
 		"/bitswap/ledger",
 		"/bitswap/reprovide",
@@ -83,19 +96,36 @@ func TestCommands(t *testing.T) {
 		"/bootstrap",
 		"/bootstrap/add",
 		"/bootstrap/add/default",
+		"/bootstrap/check",
 		"/bootstrap/list",
 		"/bootstrap/rm",
 		"/bootstrap/rm/all",
 		"/cat",
+		"/cid",
+		"/cid/base32",
+		"/cid/bases",
+		"/cid/codecs",
+		"/cid/format",
+		"/cid/hashes",
 		"/commands",
+		"/commands/completion",
 		"/config",
+		"/config/check",
 		"/config/edit",
 		"/config/replace",
 		"/config/show",
+		"/config/patch",
+		"/config/reload",
 		"/config/profile",
 		"/config/profile/apply",
 		"/dag",
 		"/dag/get",
+		"/dag/patch",
+		"/dag/patch/append-data",
+		"/dag/patch/rm-link",
+		"/dag/patch/set-field",
+		"/dag/patch/set-link",
+		"/dag/push",
 		"/dag/put",
 		"/dag/resolve",
 		"/dht",
@@ -110,17 +140,24 @@ func TestCommands(t *testing.T) {
 		"/diag/cmds/clear",
 		"/diag/cmds/set-time",
 		"/diag/sys",
+		"/dir",
+		"/dir/lookup",
 		"/dns",
+		"/events",
 		"/file",
 		"/file/ls",
 		"/files",
 		"/files/chcid",
 		"/files/cp",
+		"/files/export",
 		"/files/flush",
+		"/files/import",
 		"/files/ls",
 		"/files/mkdir",
 		"/files/mv",
 		"/files/read",
+		"/files/replicas",
+		"/files/sync-watch",
 		"/files/rm",
 		"/files/stat",
 		"/filestore",
@@ -141,12 +178,17 @@ func TestCommands(t *testing.T) {
 		"/log/tail",
 		"/ls",
 		"/mount",
+		"/multibase",
+		"/multibase/decode",
+		"/multibase/encode",
+		"/multibase/transcode",
 		"/name",
 		"/name/publish",
 		"/name/pubsub",
 		"/name/pubsub/state",
 		"/name/pubsub/subs",
 		"/name/pubsub/cancel",
+		"/name/republish-status",
 		"/name/resolve",
 		"/object",
 		"/object/data",
@@ -172,11 +214,15 @@ func TestCommands(t *testing.T) {
 		"/p2p/stream/ls",
 		"/pin",
 		"/pin/add",
+		"/pin/inventory",
 		"/ping",
 		"/pin/ls",
 		"/pin/rm",
 		"/pin/update",
 		"/pin/verify",
+		"/power",
+		"/power/save",
+		"/publish-site",
 		"/pubsub",
 		"/pubsub/ls",
 		"/pubsub/peers",
@@ -185,8 +231,14 @@ func TestCommands(t *testing.T) {
 		"/refs",
 		"/refs/local",
 		"/repo",
+		"/repo/backup",
+		"/repo/export",
 		"/repo/fsck",
 		"/repo/gc",
+		"/repo/import",
+		"/repo/lock",
+		"/repo/lock/status",
+		"/repo/restore",
 		"/repo/stat",
 		"/repo/verify",
 		"/repo/version",
@@ -195,6 +247,8 @@ func TestCommands(t *testing.T) {
 		"/stats",
 		"/stats/bitswap",
 		"/stats/bw",
+		"/stats/dht",
+		"/stats/growth",
 		"/stats/repo",
 		"/swarm",
 		"/swarm/addrs",
@@ -206,10 +260,13 @@ func TestCommands(t *testing.T) {
 		"/swarm/filters/add",
 		"/swarm/filters/rm",
 		"/swarm/peers",
+		"/swarm/peerstore",
+		"/swarm/peerstore/gc",
 		"/tar",
 		"/tar/add",
 		"/tar/cat",
 		"/update",
+		"/verify",
 		"/version",
 	}
 
@@ -239,3 +296,75 @@ func TestCommands(t *testing.T) {
 		}
 	}
 }
+
+// TestOutputTypes makes sure that every command either declares the Type of
+// its output, so that --enc=json gives documented JSON, or is listed in
+// docs/json-output.md as printing raw data or nothing.
+func TestOutputTypes(t *testing.T) {
+	// commands printing raw data, not encoded with --enc
+	raw := []string{
+		"/block/get",
+		"/cat",
+		"/commands/completion",
+		"/config/check",
+		"/config/show",
+		"/dag/get",
+		"/files/export",
+		"/files/read",
+		"/get",
+		"/log/tail",
+		"/multibase/decode",
+		"/multibase/encode",
+		"/multibase/transcode",
+		"/object/data",
+		"/tar/cat",
+		"/update",
+	}
+
+	// commands printing nothing on success
+	none := []string{
+		"/bitswap/reprovide",
+		"/bitswap/unwant",
+		"/config/edit",
+		"/config/patch",
+		"/config/profile/apply",
+		"/config/replace",
+		"/diag/cmds/clear",
+		"/diag/cmds/set-time",
+		"/files/chcid",
+		"/files/cp",
+		"/files/mkdir",
+		"/files/mv",
+		"/files/rm",
+		"/files/write",
+		"/p2p/listener/close",
+		"/p2p/stream/close",
+		"/pubsub/pub",
+		"/shutdown",
+	}
+	if runtime.GOOS == "windows" {
+		// mounting isn't supported, the command only fails
+		none = append(none, "/mount")
+	}
+
+	untyped := make(map[string]bool)
+	for _, path := range append(raw, none...) {
+		untyped[path] = true
+	}
+
+	var check func(path string, cmd *cmds.Command)
+	check = func(path string, cmd *cmds.Command) {
+		if cmd.Run != nil && path != "" {
+			switch {
+			case cmd.Type == nil && !untyped[path]:
+				t.Errorf("%q has no output Type", path)
+			case cmd.Type != nil && untyped[path]:
+				t.Errorf("%q has an output Type but is listed as untyped", path)
+			}
+		}
+		for name, sub := range cmd.Subcommands {
+			check(path+"/"+name, sub)
+		}
+	}
+	check("", Root)
+}
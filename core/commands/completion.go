@@ -0,0 +1,326 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+// Kinds of values the completion scripts know how to list.
+const (
+	completeFile = "file" // local file system paths
+	completeKey  = "key"  // names of the local keys
+	completePin  = "pin"  // recursively pinned CIDs
+	completeMFS  = "mfs"  // MFS paths
+)
+
+// completionArgs lists the commands whose arguments are completed with
+// values queried from ipfs. Commands taking files are completed with local
+// paths.
+var completionArgs = map[string]string{
+	"key rename":  completeKey,
+	"key rm":      completeKey,
+	"pin ls":      completePin,
+	"pin rm":      completePin,
+	"pin update":  completePin,
+	"files chcid": completeMFS,
+	"files cp":    completeMFS,
+	"files flush": completeMFS,
+	"files ls":    completeMFS,
+	"files mkdir": completeMFS,
+	"files mv":    completeMFS,
+	"files read":  completeMFS,
+	"files rm":    completeMFS,
+	"files stat":  completeMFS,
+	"files write": completeMFS,
+}
+
+// completionOptions lists the options whose values are completed with
+// values queried from ipfs.
+var completionOptions = map[string]string{
+	"name publish --key": completeKey,
+	"publish-site --key": completeKey,
+}
+
+type completionOption struct {
+	Long     []string
+	Short    []string
+	TakesArg bool
+	Complete string
+}
+
+type completionCommand struct {
+	Path        string
+	Subcommands []string
+	Options     []completionOption
+	Complete    string
+}
+
+func completionOptionsOf(path string, opts []cmdkit.Option) []completionOption {
+	out := make([]completionOption, 0, len(opts))
+	for _, opt := range opts {
+		var o completionOption
+		for _, name := range opt.Names() {
+			if len(name) == 1 {
+				o.Short = append(o.Short, name)
+			} else {
+				o.Long = append(o.Long, name)
+			}
+		}
+		o.TakesArg = opt.Type() != reflect.Bool
+		for _, name := range o.Long {
+			if kind, ok := completionOptions[path+" --"+name]; ok {
+				o.Complete = kind
+			}
+		}
+		out = append(out, o)
+	}
+	return out
+}
+
+// collectCompletions lists the commands of the tree rooted at cmd, sorted by
+// path. The options of the root command are the global options.
+func collectCompletions(path string, cmd *cmds.Command, out *[]completionCommand) {
+	c := completionCommand{
+		Path:    path,
+		Options: completionOptionsOf(path, cmd.Options),
+	}
+	for name := range cmd.Subcommands {
+		c.Subcommands = append(c.Subcommands, name)
+	}
+	sort.Strings(c.Subcommands)
+
+	if kind, ok := completionArgs[path]; ok {
+		c.Complete = kind
+	} else {
+		for _, arg := range cmd.Arguments {
+			if arg.Type == cmdkit.ArgFile {
+				c.Complete = completeFile
+			}
+		}
+	}
+	*out = append(*out, c)
+
+	for _, name := range c.Subcommands {
+		collectCompletions(strings.TrimSpace(path+" "+name), cmd.Subcommands[name], out)
+	}
+}
+
+var completionTemplates = map[string]*template.Template{
+	"bash": template.Must(template.New("bash").Parse(bashCompletion)),
+	"zsh":  template.Must(template.New("zsh").Parse(zshCompletion)),
+	"fish": template.Must(template.New("fish").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Parse(fishCompletion)),
+}
+
+func completionCmd(root *cmds.Command) *cmds.Command {
+	return &cmds.Command{
+		Helptext: cmdkit.HelpText{
+			Tagline: "Generate shell completion scripts.",
+			ShortDescription: `
+Prints a completion script for bash, zsh or fish. Besides subcommands and
+options, the script completes the names of local keys, pinned CIDs and
+MFS paths by asking ipfs, preferably a running daemon, for them.
+
+To load the completions in the current shell:
+
+  bash: source <(ipfs commands completion bash)
+  zsh:  source <(ipfs commands completion zsh)
+  fish: ipfs commands completion fish | source
+
+See docs/command-completion.md to load them in every shell.
+`,
+		},
+		Arguments: []cmdkit.Argument{
+			cmdkit.StringArg("shell", true, false, "The shell to generate the script for: bash, zsh or fish."),
+		},
+		Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+			tmpl, ok := completionTemplates[req.Arguments[0]]
+			if !ok {
+				res.SetError(errors.New("unsupported shell, use bash, zsh or fish"), cmdkit.ErrClient)
+				return
+			}
+
+			var commands []completionCommand
+			collectCompletions("", root, &commands)
+
+			buf := new(bytes.Buffer)
+			if err := tmpl.Execute(buf, commands); err != nil {
+				res.SetError(fmt.Errorf("failed to generate the script: %s", err), cmdkit.ErrNormal)
+				return
+			}
+			cmds.EmitOnce(res, buf)
+		},
+	}
+}
+
+const bashCompletion = `# bash completion for ipfs, generated by 'ipfs commands completion bash'
+
+_ipfs_subcommands() {
+  case "$1" in
+{{- range .}}{{if .Subcommands}}
+    "{{.Path}}") echo "{{range .Subcommands}}{{.}} {{end}}" ;;
+{{- end}}{{end}}
+  esac
+}
+
+_ipfs_options() {
+  case "$1" in
+{{- range .}}
+    "{{.Path}}") echo "{{range .Options}}{{range .Long}}--{{.}} {{end}}{{range .Short}}-{{.}} {{end}}{{end}}" ;;
+{{- end}}
+  esac
+}
+
+_ipfs_arguments() {
+  case "$1" in
+{{- range .}}{{if .Complete}}
+    "{{.Path}}") echo {{.Complete}} ;;
+{{- end}}{{end}}
+  esac
+}
+
+_ipfs_option_arguments() {
+  case "$1" in
+{{- range .}}{{$path := .Path}}{{range .Options}}{{$kind := .Complete}}{{if $kind}}{{range .Long}}
+    "{{$path}} --{{.}}") echo {{$kind}} ;;
+{{- end}}{{end}}{{end}}{{end}}
+  esac
+}
+
+# _ipfs_query lists values of the given kind for the word being completed.
+_ipfs_query() {
+  case "$1" in
+    key) ipfs --timeout=2s key list 2>/dev/null ;;
+    pin) ipfs --timeout=2s pin ls --type=recursive --quiet 2>/dev/null ;;
+    mfs)
+      local dir=""
+      [[ "$2" == */* ]] && dir="${2%/*}"
+      ipfs --timeout=2s files ls "${dir}/" 2>/dev/null | sed "s|^|${dir}/|"
+      ;;
+  esac
+}
+
+_ipfs_complete_values() {
+  if [[ "$1" == file ]]; then
+    compopt -o filenames
+    COMPREPLY=( $(compgen -f -- "$2") )
+  else
+    local IFS=$'\n'
+    COMPREPLY=( $(compgen -W "$(_ipfs_query "$1" "$2")" -- "$2") )
+  fi
+}
+
+_ipfs() {
+  local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+  local path="" word i
+
+  for (( i = 1; i < COMP_CWORD; i++ )); do
+    word="${COMP_WORDS[i]}"
+    [[ "$word" == -* ]] && continue
+    [[ " $(_ipfs_subcommands "$path") " == *" $word "* ]] || break
+    path="${path:+$path }$word"
+  done
+
+  local kind
+  if [[ "$prev" == -* ]]; then
+    kind="$(_ipfs_option_arguments "$path $prev")"
+    if [[ -n "$kind" ]]; then
+      _ipfs_complete_values "$kind" "$cur"
+      return
+    fi
+  fi
+
+  if [[ "$cur" == -* ]]; then
+    COMPREPLY=( $(compgen -W "$(_ipfs_options "$path") $(_ipfs_options "")" -- "$cur") )
+    return
+  fi
+
+  local subcommands="$(_ipfs_subcommands "$path")"
+  if [[ -n "$subcommands" ]]; then
+    COMPREPLY=( $(compgen -W "$subcommands" -- "$cur") )
+    return
+  fi
+
+  kind="$(_ipfs_arguments "$path")"
+  if [[ -n "$kind" ]]; then
+    _ipfs_complete_values "$kind" "$cur"
+  fi
+}
+
+complete -F _ipfs ipfs
+`
+
+const zshCompletion = `# zsh completion for ipfs, generated by 'ipfs commands completion zsh'
+# It reuses the bash completion through bashcompinit.
+
+autoload -U +X compinit && compinit
+autoload -U +X bashcompinit && bashcompinit
+
+` + bashCompletion
+
+const fishCompletion = `# fish completion for ipfs, generated by 'ipfs commands completion fish'
+
+function __ipfs_subcommands
+    switch "$argv[1]"
+{{- range .}}{{if .Subcommands}}
+        case "{{.Path}}"
+            printf '%s\n' {{join .Subcommands " "}}
+{{- end}}{{end}}
+    end
+end
+
+# __ipfs_path prints the subcommand being completed, e.g. "files ls".
+function __ipfs_path
+    set -l path ""
+    for word in (commandline -opc)[2..-1]
+        string match -q -- '-*' $word; and continue
+        contains -- $word (__ipfs_subcommands "$path"); or break
+        set path (string trim -- "$path $word")
+    end
+    echo $path
+end
+
+function __ipfs_path_is
+    set -l path (__ipfs_path)
+    test "$path" = "$argv[1]"
+end
+
+# __ipfs_query lists values of the given kind.
+function __ipfs_query
+    switch $argv[1]
+        case key
+            ipfs --timeout=2s key list 2>/dev/null
+        case pin
+            ipfs --timeout=2s pin ls --type=recursive --quiet 2>/dev/null
+        case mfs
+            set -l dir ""
+            set -l token (commandline -ct)
+            string match -q -- '*/*' $token; and set dir (string replace -r '/[^/]*$' '' -- $token)
+            ipfs --timeout=2s files ls "$dir/" 2>/dev/null | string replace -r '^' "$dir/"
+    end
+end
+
+complete -c ipfs -f
+{{- range .}}{{$path := .Path}}
+{{- if .Subcommands}}
+complete -c ipfs -n '__ipfs_path_is "{{$path}}"' -a '{{join .Subcommands " "}}'
+{{- end}}
+{{- range .Options}}
+complete -c ipfs{{if $path}} -n '__ipfs_path_is "{{$path}}"'{{end}}{{range .Long}} -l {{.}}{{end}}{{range .Short}} -s {{.}}{{end}}{{if .TakesArg}} -r{{end}}{{if .Complete}} -x -a '(__ipfs_query {{.Complete}})'{{end}}
+{{- end}}
+{{- if eq .Complete "file"}}
+complete -c ipfs -n '__ipfs_path_is "{{$path}}"' -F
+{{- else if .Complete}}
+complete -c ipfs -n '__ipfs_path_is "{{$path}}"' -a '(__ipfs_query {{.Complete}})'
+{{- end}}
+{{- end}}
+`
@@ -12,10 +12,12 @@ import (
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+	"github.com/ipfs/go-ipfs/thirdparty/jsonconf"
 
 	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
 )
@@ -142,6 +144,9 @@ Set the value of the 'Datastore.Path' key:
 		"show":    configShowCmd,
 		"edit":    configEditCmd,
 		"replace": configReplaceCmd,
+		"patch":   configPatchCmd,
+		"check":   configCheckCmd,
+		"reload":  configReloadCmd,
 		"profile": configProfileCmd,
 	},
 }
@@ -293,6 +298,132 @@ can't be undone.
 	},
 }
 
+var configPatchCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Apply a JSON merge patch to the config.",
+		ShortDescription: `
+'ipfs config patch' merges <patch> into the config following RFC 7386:
+objects are merged recursively, null removes a key and any other value
+replaces the current one. The patch is validated against the config
+structure first and rejected when it contains unknown keys or values of the
+wrong type. A backup of the previous config is kept in the repo.
+
+  $ ipfs config patch '{"Gateway": {"Writable": true}, "Mounts": {"FuseAllowOther": null}}'
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("patch", true, false, "The JSON merge patch to apply.").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		// has to be called
+		res.SetOutput(nil)
+
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer r.Close()
+
+		err = patchConfig(r, []byte(req.Arguments()[0]))
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+	},
+}
+
+var configCheckCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Validate the config file.",
+		ShortDescription: `
+'ipfs config check' reads the config file of the repo and reports, with their
+line and column, the keys which are unknown and the values which are of the
+wrong type. It doesn't need the daemon, and can be used to check a config
+edited by hand before starting it: the daemon refuses to start when a value
+has the wrong type, and only warns about unknown keys.
+`,
+	},
+
+	Run: func(req cmds.Request, res cmds.Response) {
+		fname, err := config.Filename(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		data, err := ioutil.ReadFile(fname)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		err = config.Validate(data)
+		if err != nil {
+			res.SetError(fmt.Errorf("%s is invalid:\n%s", fname, err), cmdkit.ErrNormal)
+			return
+		}
+
+		res.SetOutput(strings.NewReader(fname + " is valid\n"))
+	},
+}
+
+var configReloadCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Apply config changes to the running daemon.",
+		ShortDescription: `
+'ipfs config reload' makes the daemon re-read its config file and apply the
+changes to the fields which can be updated while it runs:
+
+  Gateway.HTTPHeaders, Gateway.PathPrefixes, Swarm.ConnMgr,
+  Swarm.Bandwidth, Datastore.HashOnRead, Datastore.BloomFilterSize and
+  Denylist.Files
+
+Other changed fields are listed, they apply once the daemon is restarted.
+Sending SIGHUP to the daemon has the same effect.
+`,
+	},
+
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		out, err := n.ReloadConfig()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.SetOutput(out)
+	},
+	Type: core.ConfigReload{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*core.ConfigReload)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			buf := new(bytes.Buffer)
+			for _, f := range out.Applied {
+				fmt.Fprintf(buf, "applied %s\n", f)
+			}
+			for _, f := range out.Restart {
+				fmt.Fprintf(buf, "restart required for %s\n", f)
+			}
+			return buf, nil
+		},
+	},
+}
+
 var configProfileCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Apply profiles to config.",
@@ -310,18 +441,47 @@ Available profiles:
 var configProfileApplyCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Apply profile to config.",
+		ShortDescription: `
+Applies one or more profiles to the config. Profiles are separated by ','
+or '+' and applied in the given order, e.g. 'server+lowpower'. A path to a
+JSON file holding a merge patch for the config can be given as a
+user-defined profile.
+`,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("profile", true, false, "The profile to apply to the config."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		profile, ok := config.Profiles[req.Arguments()[0]]
-		if !ok {
+		var transforms []config.Transformer
+		names := config.ParseProfiles(req.Arguments()[0])
+		backupName := strings.Join(names, "+")
+		for _, name := range names {
+			profile, err := config.GetProfile(name)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			if _, builtin := config.Profiles[name]; !builtin {
+				// keep paths out of the name of the backup file
+				backupName = "profile"
+			}
+			transforms = append(transforms, profile.Transform)
+		}
+		if len(transforms) == 0 {
 			res.SetError(fmt.Errorf("%s is not a profile", req.Arguments()[0]), cmdkit.ErrNormal)
 			return
 		}
 
-		err := transformConfig(req.InvocContext().ConfigRoot, req.Arguments()[0], profile.Transform)
+		apply := func(c *config.Config) error {
+			for _, t := range transforms {
+				if err := t(c); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		err := transformConfig(req.InvocContext().ConfigRoot, backupName, apply)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -401,8 +561,24 @@ func editConfig(filename string) error {
 }
 
 func replaceConfig(r repo.Repo, file io.Reader) error {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	// unknown keys are preserved, as with 'ipfs config <key> <value>'
+	switch err := config.Validate(data).(type) {
+	case nil:
+	case jsonconf.Errors:
+		if m := err.Mismatches(); len(m) > 0 {
+			return fmt.Errorf("invalid config:\n%s", m)
+		}
+	default:
+		return fmt.Errorf("failed to decode file as config: %s", err)
+	}
+
 	var cfg config.Config
-	if err := json.NewDecoder(file).Decode(&cfg); err != nil {
+	if err := json.Unmarshal(data, &cfg); err != nil {
 		return errors.New("failed to decode file as config")
 	}
 	if len(cfg.Identity.PrivKey) != 0 {
@@ -423,3 +599,41 @@ func replaceConfig(r repo.Repo, file io.Reader) error {
 
 	return r.SetConfig(&cfg)
 }
+
+func patchConfig(r repo.Repo, patch []byte) error {
+	if err := config.Validate(patch); err != nil {
+		return fmt.Errorf("invalid config patch:\n%s", err)
+	}
+
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return err
+	}
+	if _, ok := p.(map[string]interface{}); !ok {
+		return errors.New("config patch must be a JSON object")
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	m, err := config.ToMap(cfg)
+	if err != nil {
+		return err
+	}
+
+	updated, err := config.FromMap(jsonconf.MergeValue(m, p).(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+	if updated.Identity.PrivKey != cfg.Identity.PrivKey {
+		return errors.New("setting private key with API is not supported")
+	}
+
+	_, err = r.BackupConfig("pre-patch-")
+	if err != nil {
+		return err
+	}
+
+	return r.SetConfig(updated)
+}
@@ -8,18 +8,24 @@ import (
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	coredag "github.com/ipfs/go-ipfs/core/coredag"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	path "github.com/ipfs/go-ipfs/path"
+	resolver "github.com/ipfs/go-ipfs/path/resolver"
 	pin "github.com/ipfs/go-ipfs/pin"
 
 	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
 	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
 	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
 	files "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit/files"
 )
 
+var log = logging.Logger("core/commands/dag")
+
 var DagCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Interact with ipld dag objects.",
@@ -34,6 +40,8 @@ to deprecate and replace the existing 'ipfs object' command moving forward.
 		"put":     DagPutCmd,
 		"get":     DagGetCmd,
 		"resolve": DagResolveCmd,
+		"patch":   DagPatchCmd,
+		"push":    DagPushCmd,
 	},
 }
 
@@ -46,6 +54,15 @@ type OutputObject struct {
 type ResolveOutput struct {
 	Cid     *cid.Cid
 	RemPath string
+	// Steps are the nodes the path was resolved through, with --verbose.
+	Steps []ResolveStep `json:",omitempty"`
+}
+
+// ResolveStep is a node a path was resolved through, and the part of the
+// path resolved within it.
+type ResolveStep struct {
+	Cid  *cid.Cid
+	Path string
 }
 
 var DagPutCmd = &cmds.Command{
@@ -143,15 +160,25 @@ into an object of the specified format.
 			if dopin {
 				defer n.Blockstore.PinLock().Unlock()
 
-				cids.ForEach(func(c *cid.Cid) error {
-					n.Pinning.PinWithMode(c, pin.Recursive)
-					return nil
+				pinning := n.PinnerFor(req.Context())
+				err := cids.ForEach(func(c *cid.Cid) error {
+					pinning.PinWithMode(c, pin.Recursive)
+					return pinning.Err()
 				})
+				if err != nil {
+					return err
+				}
 
-				err := n.Pinning.Flush()
+				err = n.Pinning.Flush()
 				if err != nil {
 					return err
 				}
+
+				for _, c := range cids.Keys() {
+					if err := corerepo.RecordPin(n, req.Context(), c); err != nil {
+						log.Errorf("recording the pin of %s: %s", c, err)
+					}
+				}
 			}
 
 			return nil
@@ -167,20 +194,22 @@ into an object of the specified format.
 	},
 	Type: OutputObject{},
 	Marshalers: cmds.MarshalerMap{
-		cmds.Text: func(res cmds.Response) (io.Reader, error) {
-			v, err := unwrapOutput(res.Output())
-			if err != nil {
-				return nil, err
-			}
+		cmds.Text: outputObjectMarshaler,
+	},
+}
 
-			oobj, ok := v.(*OutputObject)
-			if !ok {
-				return nil, e.TypeErr(oobj, v)
-			}
+func outputObjectMarshaler(res cmds.Response) (io.Reader, error) {
+	v, err := unwrapOutput(res.Output())
+	if err != nil {
+		return nil, err
+	}
 
-			return strings.NewReader(oobj.Cid.String() + "\n"), nil
-		},
-	},
+	oobj, ok := v.(*OutputObject)
+	if !ok {
+		return nil, e.TypeErr(oobj, v)
+	}
+
+	return strings.NewReader(oobj.Cid.String() + "\n"), nil
 }
 
 var DagGetCmd = &cmds.Command{
@@ -189,11 +218,27 @@ var DagGetCmd = &cmds.Command{
 		ShortDescription: `
 'ipfs dag get' fetches a dag node from ipfs and prints it out in the specified
 format.
+`,
+		LongDescription: `
+'ipfs dag get' fetches a dag node from ipfs and prints it out in the specified
+format.
+
+The segments of the path within the nodes of codecs other than dag-pb and raw
+are percent-encoded, as with 'ipfs dag resolve'.
+
+With --providers, the peers known to have the data are dialed first and
+the blocks are wanted from them, without looking the providers up in the
+DHT unless they don't have the blocks:
+
+  > ipfs dag get --providers=<peer ID>,/ip4/1.2.3.4/tcp/4001/ipfs/<peer ID> <ref>
 `,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("ref", true, false, "The object to get").EnableStdin(),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("providers", "Comma-separated peer IDs or addresses of peers known to have the data, fetched from first."),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -207,7 +252,22 @@ format.
 			return
 		}
 
-		obj, rem, err := n.Resolver.ResolveToLastNode(req.Context(), p)
+		ctx, r := req.Context(), n.Resolver
+		if hint, _, _ := req.Option("providers").String(); hint != "" {
+			pis, err := core.ParseProviders(hint)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrClient)
+				return
+			}
+			ctx, err = core.WithProviders(ctx, n, pis)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			r = core.SessionResolver(ctx, n)
+		}
+
+		obj, rem, err := escapingResolver(r).ResolveToLastNode(ctx, p)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -227,17 +287,41 @@ format.
 	},
 }
 
+// escapingResolver returns a copy of r unescaping the segments of the paths
+// within the nodes of the codecs escaping them, as given to the dag commands.
+func escapingResolver(r *resolver.Resolver) *resolver.Resolver {
+	er := *r
+	er.EscapedSegments = true
+	return &er
+}
+
 // DagResolveCmd returns address of highest block within a path and a path remainder
 var DagResolveCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Resolve ipld block",
 		ShortDescription: `
 'ipfs dag resolve' fetches a dag node from ipfs, prints it's address and remaining path.
+`,
+		LongDescription: `
+'ipfs dag resolve' fetches a dag node from ipfs, prints it's address and remaining path.
+
+The segments of the path within the nodes of codecs other than dag-pb and raw,
+such as dag-cbor, are percent-encoded: a map key containing a slash or a
+percent sign is addressed with '%2F' for the slash and '%25' for the percent
+sign. For example, the key "a/b" of the node <cid> is resolved with:
+
+  > ipfs dag resolve <cid>/a%2Fb
+
+With --verbose, the nodes the path was resolved through are printed first,
+each with the part of the path resolved within it.
 `,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("ref", true, false, "The path to resolve").EnableStdin(),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("verbose", "v", "Print the nodes the path was resolved through."),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -251,16 +335,27 @@ var DagResolveCmd = &cmds.Command{
 			return
 		}
 
-		obj, rem, err := n.Resolver.ResolveToLastNode(req.Context(), p)
+		obj, rem, steps, err := escapingResolver(n.Resolver).ResolveSteps(req.Context(), p)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
 
-		res.SetOutput(&ResolveOutput{
+		if resolver.EscapesSegments(obj.Cid()) {
+			for i, seg := range rem {
+				rem[i] = path.EscapeSegment(seg)
+			}
+		}
+		out := &ResolveOutput{
 			Cid:     obj.Cid(),
 			RemPath: path.Join(rem),
-		})
+		}
+		if verbose, _, _ := req.Option("verbose").Bool(); verbose {
+			for _, step := range steps {
+				out.Steps = append(out.Steps, ResolveStep{Cid: step.Cid, Path: path.Join(step.Path)})
+			}
+		}
+		res.SetOutput(out)
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
@@ -271,6 +366,10 @@ var DagResolveCmd = &cmds.Command{
 
 			output := v.(*ResolveOutput)
 			buf := new(bytes.Buffer)
+			for _, step := range output.Steps {
+				fmt.Fprintf(buf, "%s\t/%s\n", step.Cid, step.Path)
+			}
+
 			p := output.Cid.String()
 			if output.RemPath != "" {
 				p = path.Join([]string{p, output.RemPath})
@@ -0,0 +1,470 @@
+package dagcmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	path "github.com/ipfs/go-ipfs/path"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	mod "github.com/ipfs/go-ipfs/unixfs/mod"
+
+	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
+	chunker "gx/ipfs/QmR4G4WBNGA5S5pvjFiTkuehstC9769sLAHei8vZernhYR/go-ipfs-chunker"
+	ipldcbor "gx/ipfs/QmSF1Ksgn5d7JCTBt4e1yp4wzs6tpYyweCZ4PcDYp3tNeK/go-ipld-cbor"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+var DagPatchCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create a new dag node based on an existing one.",
+		ShortDescription: `
+'ipfs dag patch' changes a value of a dag node and prints the CID of the
+new root. Unlike 'ipfs object patch', it works on dag-cbor nodes as well as
+dag-pb ones, and the paths it takes cross links from one node to another:
+
+  $ ipfs dag patch set-link $ROOT a/b/c $REF
+
+changes the node linked at a/b under $ROOT, then the nodes at a and at the
+root to link to the new versions.
+
+In dag-pb nodes, each path component is the name of a link. In dag-cbor
+nodes, it is a field of a map, or an index of a list.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("create", "p", "Create the missing maps or unixfs directories along the path."),
+	},
+	Subcommands: map[string]*cmds.Command{
+		"set-link":    patchSetLinkCmd,
+		"rm-link":     patchRmLinkCmd,
+		"set-field":   patchSetFieldCmd,
+		"append-data": patchAppendDataCmd,
+	},
+}
+
+var patchSetLinkCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Set a link to another node.",
+		ShortDescription: `
+Sets the value at the path to a link to the node at ref. An existing link or
+value at the path is replaced.
+
+  $ ipfs dag patch set-link $ROOT config/previous $PREV
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("root", true, false, "The node to modify."),
+		cmdkit.StringArg("path", true, false, "Path of the link, relative to root."),
+		cmdkit.StringArg("ref", true, false, "The node to link to."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		runPatch(req, res, func(ctx context.Context, n *core.IpfsNode) (patchValue, error) {
+			p, err := path.ParsePath(req.Arguments()[2])
+			if err != nil {
+				return patchValue{}, err
+			}
+
+			nd, err := core.Resolve(ctx, n.Namesys, n.Resolver, p)
+			if err != nil {
+				return patchValue{}, err
+			}
+			return patchValue{link: nd}, nil
+		})
+	},
+	Type: OutputObject{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: outputObjectMarshaler,
+	},
+}
+
+var patchRmLinkCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove a link or a field.",
+		ShortDescription: `
+Removes the link or the value at the path. In a dag-cbor list, the following
+items move up.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("root", true, false, "The node to modify."),
+		cmdkit.StringArg("path", true, false, "Path of the link to remove, relative to root."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		runPatch(req, res, func(context.Context, *core.IpfsNode) (patchValue, error) {
+			return patchValue{remove: true}, nil
+		})
+	},
+	Type: OutputObject{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: outputObjectMarshaler,
+	},
+}
+
+var patchSetFieldCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Set a field of a dag-cbor node.",
+		ShortDescription: `
+Sets the field at the path to a JSON value. Links are written as
+{"/": "<cid>"}, as 'ipfs dag get' prints them.
+
+  $ ipfs dag patch set-field $ROOT meta/title '"hello"'
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("root", true, false, "The node to modify."),
+		cmdkit.StringArg("path", true, false, "Path of the field, relative to root."),
+		cmdkit.StringArg("value", true, false, "JSON value of the field."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		runPatch(req, res, func(context.Context, *core.IpfsNode) (patchValue, error) {
+			v, err := parseField(req.Arguments()[2])
+			if err != nil {
+				return patchValue{}, err
+			}
+			return patchValue{field: v, isField: true}, nil
+		})
+	},
+	Type: OutputObject{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: outputObjectMarshaler,
+	},
+}
+
+var patchAppendDataCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Append data to a unixfs file.",
+		ShortDescription: `
+Appends data to the unixfs file at the path, "" for the root itself, as
+'ipfs files write' would:
+
+  $ echo "more" | ipfs dag patch append-data $ROOT docs/notes.txt
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("root", true, false, "The node to modify."),
+		cmdkit.StringArg("path", true, false, "Path of the file, relative to root."),
+		cmdkit.FileArg("data", true, false, "Data to append.").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		runPatch(req, res, func(ctx context.Context, n *core.IpfsNode) (patchValue, error) {
+			fi, err := req.Files().NextFile()
+			if err != nil {
+				return patchValue{}, err
+			}
+			defer fi.Close()
+
+			data, err := ioutil.ReadAll(fi)
+			if err != nil {
+				return patchValue{}, err
+			}
+
+			return patchValue{update: func(nd ipld.Node) (ipld.Node, error) {
+				return appendData(ctx, n.DAG, nd, data)
+			}}, nil
+		})
+	},
+	Type: OutputObject{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: outputObjectMarshaler,
+	},
+}
+
+// runPatch applies the value made by mkValue at the path given as second
+// argument under the root given as first argument.
+func runPatch(req cmds.Request, res cmds.Response, mkValue func(context.Context, *core.IpfsNode) (patchValue, error)) {
+	n, err := req.InvocContext().GetNode()
+	if err != nil {
+		res.SetError(err, cmdkit.ErrNormal)
+		return
+	}
+	ctx := req.Context()
+
+	rootp, err := path.ParsePath(req.Arguments()[0])
+	if err != nil {
+		res.SetError(err, cmdkit.ErrNormal)
+		return
+	}
+
+	root, err := core.Resolve(ctx, n.Namesys, n.Resolver, rootp)
+	if err != nil {
+		res.SetError(err, cmdkit.ErrNormal)
+		return
+	}
+
+	value, err := mkValue(ctx, n)
+	if err != nil {
+		res.SetError(err, cmdkit.ErrNormal)
+		return
+	}
+
+	create, _, _ := req.Option("create").Bool()
+	pt := &patcher{ctx: ctx, dserv: n.DAG, create: create}
+
+	nd, err := pt.patch(root, path.SplitList(strings.Trim(req.Arguments()[1], "/")), value)
+	if err != nil {
+		res.SetError(err, cmdkit.ErrNormal)
+		return
+	}
+
+	res.SetOutput(&OutputObject{Cid: nd.Cid()})
+}
+
+// patchValue is the change made at the end of a path.
+type patchValue struct {
+	// remove removes the value
+	remove bool
+	// link sets the value to a link to the node
+	link ipld.Node
+	// field sets the value, when isField is set
+	field   interface{}
+	isField bool
+	// update replaces the node at the path with the node it returns
+	update func(ipld.Node) (ipld.Node, error)
+}
+
+// patcher changes values in DAGs, updating the nodes along the paths.
+type patcher struct {
+	ctx    context.Context
+	dserv  ipld.DAGService
+	create bool
+}
+
+// patch changes the value at p under nd and returns the new version of nd,
+// added to the DAG service.
+func (pt *patcher) patch(nd ipld.Node, p []string, value patchValue) (ipld.Node, error) {
+	if len(p) == 0 || (len(p) == 1 && p[0] == "") {
+		if value.update == nil {
+			return nil, fmt.Errorf("a path is required")
+		}
+		return value.update(nd)
+	}
+
+	var out ipld.Node
+	var err error
+	switch nd := nd.(type) {
+	case *dag.ProtoNode:
+		out, err = pt.patchProtoNode(nd, p, value)
+	case *ipldcbor.Node:
+		out, err = pt.patchCborNode(nd, p, value)
+	case *dag.RawNode:
+		return nil, fmt.Errorf("cannot patch raw node %s: it has no links", nd.Cid())
+	default:
+		return nil, fmt.Errorf("cannot patch node %s: unsupported format", nd.Cid())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pt.dserv.Add(pt.ctx, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (pt *patcher) patchProtoNode(nd *dag.ProtoNode, p []string, value patchValue) (ipld.Node, error) {
+	nd = nd.Copy().(*dag.ProtoNode)
+	name := p[0]
+
+	if len(p) == 1 {
+		switch {
+		case value.remove:
+			if err := nd.RemoveNodeLink(name); err != nil {
+				return nil, fmt.Errorf("no link named %q under %s", name, nd.Cid())
+			}
+			return nd, nil
+		case value.link != nil:
+			return nd, setProtoLink(nd, name, value.link)
+		case value.isField:
+			return nil, fmt.Errorf("dag-pb nodes have no fields, only links")
+		}
+	}
+
+	child, err := nd.GetLinkedNode(pt.ctx, pt.dserv, name)
+	switch {
+	case err == dag.ErrLinkNotFound && pt.create && value.update == nil:
+		child = ft.EmptyDirNode()
+	case err == dag.ErrLinkNotFound:
+		return nil, fmt.Errorf("no link named %q under %s", name, nd.Cid())
+	case err != nil:
+		return nil, err
+	}
+
+	child, err = pt.patch(child, p[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	return nd, setProtoLink(nd, name, child)
+}
+
+func setProtoLink(nd *dag.ProtoNode, name string, child ipld.Node) error {
+	if err := nd.RemoveNodeLink(name); err != nil && err != ipld.ErrNotFound {
+		return err
+	}
+	return nd.AddNodeLink(name, child)
+}
+
+// patchCborNode changes the value in the object tree of the node, keeping the
+// integers, byte strings and links of the node as they are encoded.
+func (pt *patcher) patchCborNode(nd *ipldcbor.Node, p []string, value patchValue) (ipld.Node, error) {
+	var obj interface{}
+	if err := ipldcbor.DecodeInto(nd.RawData(), &obj); err != nil {
+		return nil, err
+	}
+
+	obj, err := pt.patchObject(obj, p, value)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := nd.Cid().Prefix()
+	return ipldcbor.WrapObject(obj, prefix.MhType, prefix.MhLength)
+}
+
+// patchObject changes the value at p in obj, a value decoded from a dag-cbor
+// node, and returns the new version of obj.
+func (pt *patcher) patchObject(obj interface{}, p []string, value patchValue) (interface{}, error) {
+	if c, ok := cborLink(obj); ok {
+		nd, err := pt.dserv.Get(pt.ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		nd, err = pt.patch(nd, p, value)
+		if err != nil {
+			return nil, err
+		}
+		return nd.Cid(), nil
+	}
+
+	if len(p) == 0 {
+		if value.update == nil {
+			return nil, fmt.Errorf("a path is required")
+		}
+		return nil, fmt.Errorf("the value at the path is not a link")
+	}
+
+	key := p[0]
+	last := len(p) == 1 && value.update == nil
+
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		cur, ok := o[key]
+		if last {
+			if value.remove {
+				if !ok {
+					return nil, fmt.Errorf("no field named %q", key)
+				}
+				delete(o, key)
+				return o, nil
+			}
+			o[key] = value.object()
+			return o, nil
+		}
+
+		if !ok {
+			if !pt.create || value.update != nil {
+				return nil, fmt.Errorf("no field named %q", key)
+			}
+			cur = map[string]interface{}{}
+		}
+
+		v, err := pt.patchObject(cur, p[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		o[key] = v
+		return o, nil
+
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i > len(o) || (i == len(o) && !(last && !value.remove)) {
+			return nil, fmt.Errorf("invalid index %q in a list of %d items", key, len(o))
+		}
+
+		if last {
+			if value.remove {
+				return append(o[:i], o[i+1:]...), nil
+			}
+			if i == len(o) {
+				return append(o, value.object()), nil
+			}
+			o[i] = value.object()
+			return o, nil
+		}
+
+		v, err := pt.patchObject(o[i], p[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		o[i] = v
+		return o, nil
+
+	default:
+		return nil, fmt.Errorf("cannot look up %q in a value which is neither a map nor a list", key)
+	}
+}
+
+// object returns the value to set, as a dag-cbor object.
+func (v patchValue) object() interface{} {
+	if v.link != nil {
+		return v.link.Cid()
+	}
+	return v.field
+}
+
+// cborLink returns the CID of obj if it is a link.
+func cborLink(obj interface{}) (*cid.Cid, bool) {
+	switch c := obj.(type) {
+	case *cid.Cid:
+		return c, true
+	case cid.Cid:
+		return &c, true
+	default:
+		return nil, false
+	}
+}
+
+// parseField parses the JSON value of a field the way 'ipfs dag put' parses
+// its input, links included, into a dag-cbor object.
+func parseField(js string) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(js), &v); err != nil {
+		return nil, fmt.Errorf("invalid JSON value: %s", err)
+	}
+
+	wrapped := `{"value":` + js + `}`
+	nd, err := ipldcbor.FromJson(strings.NewReader(wrapped), mh.SHA2_256, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	var obj map[string]interface{}
+	if err := ipldcbor.DecodeInto(nd.RawData(), &obj); err != nil {
+		return nil, err
+	}
+	return obj["value"], nil
+}
+
+func appendData(ctx context.Context, dserv ipld.DAGService, nd ipld.Node, data []byte) (ipld.Node, error) {
+	dmod, err := mod.NewDagModifier(ctx, nd, dserv, chunker.DefaultSplitter)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dmod.Seek(0, io.SeekEnd); err != nil {
+		return nil, err
+	}
+	if _, err := dmod.Write(data); err != nil {
+		return nil, err
+	}
+	return dmod.GetNode()
+}
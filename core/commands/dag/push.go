@@ -0,0 +1,110 @@
+package dagcmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	path "github.com/ipfs/go-ipfs/path"
+	replication "github.com/ipfs/go-ipfs/replication"
+
+	offline "gx/ipfs/QmPf114DXfa6TqGKYhBGR7EtXRho4rCJgwyA1xkuMY5vwF/go-ipfs-exchange-offline"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+// PushOutput is the output type of 'dag push' command
+type PushOutput struct {
+	Cid    string
+	Peer   string
+	Pinned bool
+}
+
+var DagPushCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Push a DAG to another node.",
+		ShortDescription: `
+'ipfs dag push' streams the DAG of root, as a CAR file, to the node with the
+given peer ID, which checks it and adds it, and pins it with '--pin':
+
+  $ ipfs dag push --pin $ROOT QmPeer...
+
+The receiving node only accepts the pushes of the nodes in its
+'Replication.AllowPush' config. Both nodes must be online. Only the blocks in
+the repo are pushed: the push fails if some are missing.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("root", true, false, "The DAG to push."),
+		cmdkit.StringArg("peer", true, false, "Peer ID of the node to push to."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("pin", "Have the node pin the DAG."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errors.New("this command must be run in online mode. Try running 'ipfs daemon' first"), cmdkit.ErrClient)
+			return
+		}
+
+		p, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		id, err := peer.IDB58Decode(req.Arguments()[1])
+		if err != nil {
+			res.SetError(fmt.Errorf("invalid peer ID: %s", err), cmdkit.ErrClient)
+			return
+		}
+		pin, _, _ := req.Option("pin").Bool()
+
+		nd, err := core.Resolve(req.Context(), n.Namesys, n.Resolver, p)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		// fail on the missing blocks rather than fetch them
+		dagserv := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+
+		if err := replication.PushDAG(req.Context(), n.PeerHost, dagserv, id, nd.Cid(), pin); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&PushOutput{Cid: nd.Cid().String(), Peer: id.Pretty(), Pinned: pin})
+	},
+	Type: PushOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*PushOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			verb := "pushed"
+			if out.Pinned {
+				verb = "pushed and pinned"
+			}
+			return strings.NewReader(fmt.Sprintf("%s %s to %s\n", verb, out.Cid, out.Peer)), nil
+		},
+	},
+}
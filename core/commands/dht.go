@@ -9,17 +9,25 @@ import (
 	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	dag "github.com/ipfs/go-ipfs/merkledag"
+	nspb "github.com/ipfs/go-ipfs/namesys/pb"
 	path "github.com/ipfs/go-ipfs/path"
 
+	record "gx/ipfs/QmPWjVzxHeJdrjp4Jr2R2sPxBrMbBgGPWQtKwCKHHCBF7x/go-libp2p-record"
+	host "gx/ipfs/QmQQGtcp6nVUrQjNsnU53YWV1q8fK1Kd9S7FEkYbRZzxry/go-libp2p-host"
 	routing "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing"
 	notif "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing/notifications"
 	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
 	b58 "gx/ipfs/QmWFAMPqsEyUX7gDUsRVmMWz59FxSpJ1b2v6bJ1yYzo7jY/go-base58-fast/base58"
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	inet "gx/ipfs/QmXdgNhVEgjLxjUoMs5ViQL7pboAt3Y7V7eGHRiE4qrmTE/go-libp2p-net"
+	ggio "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/io"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
 	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
 	ipdht "gx/ipfs/QmagBkuFfySAMouyXeiy8XjV1GyfNAgTCuVYGF9z3Z4Vvc/go-libp2p-kad-dht"
+	dhtpb "gx/ipfs/QmagBkuFfySAMouyXeiy8XjV1GyfNAgTCuVYGF9z3Z4Vvc/go-libp2p-kad-dht/pb"
 	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
 	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
 )
@@ -513,6 +521,17 @@ this context 'best' means the record that is most desirable. There is no one
 metric for 'best': it depends entirely on the key type. For IPNS, 'best' is
 the record that is both valid and has the highest sequence number (freshest).
 Different key types can specify other 'best' rules.
+
+With --validate, the records of the key are fetched from several peers and
+checked with the validator of their namespace, and the command prints, for
+each record, the peer it came from and whether it is accepted or rejected and
+why: a bad signature, an expired record, a lower sequence than the selected
+record, etc. This helps debugging IPNS and custom record namespaces.
+
+  > ipfs dht get --validate /ipns/QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd
+  QmaCpDMG...: accepted, selected (sequence 4, valid until 2018-06-22T10:00:00Z)
+  QmNnooDu...: accepted, not selected: lower sequence than the selected record (sequence 3, valid until 2018-06-21T10:00:00Z)
+  QmQCU2Ec...: rejected: expired record (sequence 1, valid until 2018-06-01T10:00:00Z)
 `,
 	},
 
@@ -521,6 +540,7 @@ Different key types can specify other 'best' rules.
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("verbose", "v", "Print extra information."),
+		cmdkit.BoolOption("validate", "Fetch the records of the key from several peers and explain why each one is accepted or rejected."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -557,6 +577,29 @@ Different key types can specify other 'best' rules.
 			}
 		}()
 
+		validate, _, _ := req.Option("validate").Bool()
+		if validate {
+			go func() {
+				defer close(events)
+				recs, err := getRawRecords(ctx, n.PeerHost, dht, dhtkey, validateRecords)
+				if err != nil {
+					notif.PublishQueryEvent(ctx, &notif.QueryEvent{
+						Type:  notif.QueryError,
+						Extra: err.Error(),
+					})
+					return
+				}
+				for _, c := range checkRecords(n.RecordValidator, dhtkey, recs) {
+					notif.PublishQueryEvent(ctx, &notif.QueryEvent{
+						Type:  notif.Value,
+						ID:    c.From,
+						Extra: c.String(),
+					})
+				}
+			}()
+			return
+		}
+
 		go func() {
 			defer close(events)
 			val, err := dht.GetValue(ctx, dhtkey)
@@ -584,9 +627,15 @@ Different key types can specify other 'best' rules.
 					}
 				},
 			}
+			validatePfm := pfuncMap{
+				notif.Value: func(obj *notif.QueryEvent, out io.Writer, verbose bool) {
+					fmt.Fprintf(out, "%s: %s\n", obj.ID.Pretty(), obj.Extra)
+				},
+			}
 
 			return func(res cmds.Response) (io.Reader, error) {
 				verbose, _, _ := res.Request().Option("v").Bool()
+				validate, _, _ := res.Request().Option("validate").Bool()
 				v, err := unwrapOutput(res.Output())
 				if err != nil {
 					return nil, err
@@ -599,7 +648,11 @@ Different key types can specify other 'best' rules.
 
 				buf := new(bytes.Buffer)
 
-				printEvent(obj, buf, verbose, pfm)
+				if validate {
+					printEvent(obj, buf, verbose, validatePfm)
+				} else {
+					printEvent(obj, buf, verbose, pfm)
+				}
 
 				return buf, nil
 			}
@@ -608,6 +661,194 @@ Different key types can specify other 'best' rules.
 	Type: notif.QueryEvent{},
 }
 
+// validateRecords is the number of records 'dht get --validate' fetches.
+const validateRecords = 16
+
+// getRawRecords asks the peers closest to key for their record of it, at most
+// count of them. Unlike the DHT's GetValues, which blanks the records failing
+// validation, it returns the records as the peers sent them, so that
+// checkRecords can tell why they are rejected.
+func getRawRecords(ctx context.Context, h host.Host, d *ipdht.IpfsDHT, key string, count int) ([]routing.RecvdVal, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	peers, err := d.GetClosestPeers(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var recs []routing.RecvdVal
+	for p := range peers {
+		if len(recs) >= count {
+			break
+		}
+		val, err := getRawRecord(ctx, h, p, key)
+		if err != nil {
+			notif.PublishQueryEvent(ctx, &notif.QueryEvent{
+				Type:  notif.QueryError,
+				ID:    p,
+				Extra: err.Error(),
+			})
+			continue
+		}
+		if val == nil {
+			continue
+		}
+		recs = append(recs, routing.RecvdVal{From: p, Val: val})
+	}
+
+	if len(recs) == 0 {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, routing.ErrNotFound
+	}
+	return recs, nil
+}
+
+// getRawRecord asks p for its record of key, without validating it. It
+// returns nil if p doesn't have one.
+func getRawRecord(ctx context.Context, h host.Host, p peer.ID, key string) ([]byte, error) {
+	s, err := h.NewStream(ctx, p, ipdht.ProtocolDHT)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	w := ggio.NewDelimitedWriter(s)
+	if err := w.WriteMsg(dhtpb.NewMessage(dhtpb.Message_GET_VALUE, key, 0)); err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	resp := new(dhtpb.Message)
+	r := ggio.NewDelimitedReader(s, inet.MessageSizeMax)
+	if err := r.ReadMsg(resp); err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	rec := resp.GetRecord()
+	if rec == nil || rec.GetKey() != key {
+		return nil, nil
+	}
+	return rec.GetValue(), nil
+}
+
+// recordCheck is the result of validating one of the records of a key.
+type recordCheck struct {
+	From     peer.ID
+	Valid    bool
+	Selected bool
+	// Reason is why the record is rejected or not selected.
+	Reason string
+	// Info describes the record, for the namespaces it is known for.
+	Info string
+}
+
+func (c *recordCheck) String() string {
+	var s string
+	switch {
+	case !c.Valid:
+		s = "rejected: " + c.Reason
+	case c.Selected:
+		s = "accepted, selected"
+	default:
+		s = "accepted, not selected: " + c.Reason
+	}
+	if c.Info != "" {
+		s += " (" + c.Info + ")"
+	}
+	return s
+}
+
+// checkRecords validates the records of key received from peers, and finds
+// the one the validator selects among the valid ones.
+func checkRecords(v record.Validator, key string, recs []routing.RecvdVal) []*recordCheck {
+	checks := make([]*recordCheck, len(recs))
+	var valid [][]byte
+	var validIdx []int
+	for i, r := range recs {
+		c := &recordCheck{From: r.From, Valid: true}
+		if v == nil {
+			c.Valid = false
+			c.Reason = "no validator"
+		} else if err := v.Validate(key, r.Val); err != nil {
+			c.Valid = false
+			c.Reason = err.Error()
+		}
+		c.Info = describeRecord(key, r.Val)
+		checks[i] = c
+
+		if c.Valid {
+			valid = append(valid, r.Val)
+			validIdx = append(validIdx, i)
+		}
+	}
+
+	if len(valid) == 0 {
+		return checks
+	}
+	best, err := v.Select(key, valid)
+	if err != nil {
+		for _, i := range validIdx {
+			checks[i].Reason = "selecting the best record failed: " + err.Error()
+		}
+		return checks
+	}
+
+	bestIdx := validIdx[best]
+	checks[bestIdx].Selected = true
+	for _, i := range validIdx {
+		if i == bestIdx {
+			continue
+		}
+		checks[i].Reason = notSelectedReason(key, recs[i].Val, recs[bestIdx].Val)
+	}
+	return checks
+}
+
+// notSelectedReason explains why val wasn't selected over best.
+func notSelectedReason(key string, val, best []byte) string {
+	if bytes.Equal(val, best) {
+		return "same as the selected record"
+	}
+
+	e, be := ipnsRecord(key, val), ipnsRecord(key, best)
+	if e != nil && be != nil {
+		switch {
+		case e.GetSequence() < be.GetSequence():
+			return "lower sequence than the selected record"
+		case e.GetSequence() == be.GetSequence():
+			return "same sequence as the selected record, which expires later"
+		}
+	}
+	return "the validator selected another record"
+}
+
+// ipnsRecord returns the IPNS record of val, if key is an IPNS key.
+func ipnsRecord(key string, val []byte) *nspb.IpnsEntry {
+	ns, _, err := record.SplitKey(key)
+	if err != nil || ns != "ipns" {
+		return nil
+	}
+	e := new(nspb.IpnsEntry)
+	if err := proto.Unmarshal(val, e); err != nil {
+		return nil
+	}
+	return e
+}
+
+// describeRecord returns what is known about the record of a key, for IPNS
+// records.
+func describeRecord(key string, val []byte) string {
+	e := ipnsRecord(key, val)
+	if e == nil {
+		return ""
+	}
+	return fmt.Sprintf("sequence %d, valid until %s", e.GetSequence(), e.GetValidity())
+}
+
 var putValueDhtCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Write a key/value pair to the DHT.",
@@ -774,22 +1015,34 @@ func printEvent(obj *notif.QueryEvent, out io.Writer, verbose bool, override pfu
 	}
 }
 
+// escapeDhtKey turns a key given on the command line into a key of the
+// routing system. The names of the built-in namespaces, "pk" and "ipns", are
+// peer IDs, given in base58 and stored in binary. The names of the namespaces
+// registered by plugins are used as they're given.
 func escapeDhtKey(s string) (string, error) {
 	parts := path.SplitList(s)
-	switch len(parts) {
-	case 1:
+	switch {
+	case len(parts) == 1:
 		k, err := b58.Decode(s)
 		if err != nil {
 			return "", err
 		}
 		return string(k), nil
-	case 3:
+	case len(parts) < 3:
+		return "", errors.New("invalid key")
+	}
+
+	switch parts[1] {
+	case "pk", core.IpnsValidatorTag:
+		if len(parts) != 3 {
+			return "", errors.New("invalid key")
+		}
 		k, err := b58.Decode(parts[2])
 		if err != nil {
 			return "", err
 		}
 		return path.Join(append(parts[:2], string(k))), nil
 	default:
-		return "", errors.New("invalid key")
+		return s, nil
 	}
 }
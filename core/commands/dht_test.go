@@ -1,10 +1,23 @@
 package commands
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/ipfs/go-ipfs/core"
+	mock "github.com/ipfs/go-ipfs/core/mock"
 	"github.com/ipfs/go-ipfs/namesys"
+	path "github.com/ipfs/go-ipfs/path"
+
+	record "gx/ipfs/QmPWjVzxHeJdrjp4Jr2R2sPxBrMbBgGPWQtKwCKHHCBF7x/go-libp2p-record"
 	tu "gx/ipfs/QmPdxCaVp4jZ9RbxqZADvKH6kiCR5jHvdR5f2ycjAY6T2a/go-testutil"
+	mocknet "gx/ipfs/QmUEAR2pS7fP1GPseS3i8MWFyENs7oDp4CZrgn8FCjbsBu/go-libp2p/p2p/net/mock"
+	routing "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	ipdht "gx/ipfs/QmagBkuFfySAMouyXeiy8XjV1GyfNAgTCuVYGF9z3Z4Vvc/go-libp2p-kad-dht"
 )
 
 func TestKeyTranslation(t *testing.T) {
@@ -28,4 +41,144 @@ func TestKeyTranslation(t *testing.T) {
 	if ipnsk != b {
 		t.Fatal("keys didnt match!")
 	}
+
+	// the names of the namespaces of plugins aren't peer IDs
+	for _, k := range []string{"/myapp/0Ol-name", "/myapp/some/name"} {
+		appk, err := escapeDhtKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if appk != k {
+			t.Fatalf("expected %s to be used as it is, got %q", k, appk)
+		}
+	}
+	if _, err := escapeDhtKey("/ipns/" + pid.Pretty() + "/foo"); err == nil {
+		t.Fatal("expected an ipns key with a path to be invalid")
+	}
+}
+
+func TestCheckRecords(t *testing.T) {
+	priv, pub, err := tu.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, key := namesys.IpnsKeysForID(pid)
+
+	ps := pstore.NewPeerstore()
+	ps.AddPubKey(pid, pub)
+	v := record.NamespacedValidator{
+		"ipns": namesys.IpnsValidator{KeyBook: ps},
+	}
+
+	entry := func(seq uint64, eol time.Time, badSig bool) []byte {
+		e, err := namesys.CreateRoutingEntryData(priv, path.Path("/ipfs/foo"), seq, eol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if badSig {
+			e.Signature[0] ^= 0xff
+		}
+		b, err := proto.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	later := time.Now().Add(time.Hour)
+	recs := []routing.RecvdVal{
+		{From: tu.RandPeerIDFatal(t), Val: entry(1, later, false)},
+		{From: tu.RandPeerIDFatal(t), Val: entry(2, later, false)},
+		{From: tu.RandPeerIDFatal(t), Val: entry(3, time.Now().Add(-time.Hour), false)},
+		{From: tu.RandPeerIDFatal(t), Val: entry(5, later, true)},
+	}
+
+	checks := checkRecords(v, key, recs)
+	if len(checks) != len(recs) {
+		t.Fatalf("expected %d checks, got %d", len(recs), len(checks))
+	}
+
+	if c := checks[0]; !c.Valid || c.Selected || c.Reason != "lower sequence than the selected record" {
+		t.Errorf("unexpected check of the older record: %+v", c)
+	}
+	if c := checks[1]; !c.Valid || !c.Selected {
+		t.Errorf("expected the newest record to be selected: %+v", c)
+	}
+	if c := checks[2]; c.Valid || c.Reason != namesys.ErrExpiredRecord.Error() {
+		t.Errorf("expected the expired record to be rejected: %+v", c)
+	}
+	if c := checks[3]; c.Valid {
+		t.Errorf("expected the record with a bad signature to be rejected: %+v", c)
+	}
+	for i, c := range checks {
+		if c.From != recs[i].From {
+			t.Errorf("check %d is from %s, expected %s", i, c.From, recs[i].From)
+		}
+	}
+}
+
+func TestGetRawRecords(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn := mocknet.New(ctx)
+
+	var nodes []*core.IpfsNode
+	for i := 0; i < 5; i++ {
+		nd, err := core.NewNode(ctx, &core.BuildCfg{
+			Online: true,
+			Host:   mock.MockHostOption(mn),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer nd.Close()
+		nodes = append(nodes, nd)
+	}
+
+	mn.LinkAll()
+
+	bsinf := core.BootstrapConfigWithPeers(
+		[]pstore.PeerInfo{
+			nodes[0].Peerstore.PeerInfo(nodes[0].Identity),
+		},
+	)
+	for _, n := range nodes[1:] {
+		if err := n.Bootstrap(bsinf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// publish a record that expires right away: the peers keep it, but the
+	// DHT drops it when getting the values
+	publisher := nodes[1]
+	rp := namesys.NewIpnsPublisher(publisher.Routing, publisher.Repo.Datastore())
+	err := rp.PublishWithEOL(ctx, publisher.PrivateKey, path.FromString("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"), time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	asker := nodes[0]
+	asker.Peerstore.AddPubKey(publisher.Identity, publisher.PrivateKey.GetPublic())
+	_, key := namesys.IpnsKeysForID(publisher.Identity)
+
+	d, ok := asker.Routing.(*ipdht.IpfsDHT)
+	if !ok {
+		t.Fatal("expected the routing of the node to be a DHT")
+	}
+	recs, err := getRawRecords(ctx, asker.PeerHost, d, key, validateRecords)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range checkRecords(asker.RecordValidator, key, recs) {
+		if c.Valid || c.Reason != namesys.ErrExpiredRecord.Error() {
+			t.Errorf("expected the record from %s to be rejected as expired: %+v", c.From, c)
+		}
+	}
 }
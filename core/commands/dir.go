@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+// DirLookupOutput is the link found by 'ipfs dir lookup'.
+type DirLookupOutput struct {
+	Name string
+	Hash string
+	Size uint64
+}
+
+var DirCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Interact with unixfs directories.",
+		ShortDescription: `
+'ipfs dir' looks the entries of unixfs directories up, sharded or not.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"lookup": dirLookupCmd,
+	},
+}
+
+var dirLookupCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Look an entry of a directory up by name.",
+		ShortDescription: `
+Prints the hash, the size and the name of the entry of a directory with the
+given name, without listing the directory.
+
+  $ ipfs dir lookup QmRgutAxd8t7oGkSm4wmeuByG6M51wcTso6cubDdQtuEfL readme
+  QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB	1102	readme
+`,
+		LongDescription: `
+Prints the hash, the size and the name of the entry of a directory with the
+given name, without listing the directory.
+
+  $ ipfs dir lookup QmRgutAxd8t7oGkSm4wmeuByG6M51wcTso6cubDdQtuEfL readme
+  QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB	1102	readme
+
+The entries of sharded directories are spread over a tree of shards, by the
+hash of their names. Only the shards on the path to the entry are fetched,
+which makes looking an entry of an enormous directory up as fast as looking
+it up in a small one. Neither the other entries nor the entry itself are
+fetched.
+`,
+	},
+
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("dir-path", true, false, "The path to the directory."),
+		cmdkit.StringArg("name", true, false, "The name of the entry."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		p, err := coreapi.ParsePath(req.Arguments[0])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		lnk, err := coreapi.NewCoreAPI(n).Unixfs().Lookup(req.Context, p, req.Arguments[1])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		err = cmds.EmitOnce(res, &DirLookupOutput{
+			Name: lnk.Name,
+			Hash: lnk.Cid.String(),
+			Size: lnk.Size,
+		})
+		if err != nil {
+			log.Error(err)
+		}
+	},
+	Type: DirLookupOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*DirLookupOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+			_, err := fmt.Fprintf(w, "%s\t%d\t%s\n", out.Hash, out.Size, out.Name)
+			return err
+		}),
+	},
+}
@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	events "github.com/ipfs/go-ipfs/events"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+const eventsTypeOptionName = "type"
+
+var EventsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Stream node events.",
+		ShortDescription: `
+'ipfs events' streams structured events emitted by the running daemon as
+they happen. Use '--type' to limit the stream to a comma-separated list of
+event types.
+`,
+		LongDescription: `
+'ipfs events' streams structured events emitted by the running daemon as
+they happen. Use '--type' to limit the stream to a comma-separated list of
+event types.
+
+Available event types:
+
+  peer.connected     a connection to a peer was opened
+  peer.disconnected  a connection to a peer was closed
+  block.added        a block was written to the blockstore
+  pin.added          a pin operation completed
+  name.published     an IPNS record was published
+  gc.started         a garbage collection run started
+  gc.finished        a garbage collection run finished
+  config.reloaded    config changes were applied to the running node
+  power.save         the daemon entered or left power save
+  node.suspended     the node was suspended or resumed
+  files.published    a new root of the MFS tree was saved
+  disk.low           the free space of the repo fell below Webhooks.LowDiskSpace
+  peers.low          the connected peers fell below Webhooks.LowPeers
+
+Events are delivered on a best-effort basis: if the client doesn't consume
+them fast enough, some events will be dropped. Use '--enc=json' to get one
+JSON object per event.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(eventsTypeOptionName, "t", "Comma-separated list of event types to stream. Default: all."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		types, err := parseEventTypes(req.Options[eventsTypeOptionName])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		sub := n.Events.Subscribe(0, types...)
+		defer sub.Cancel()
+
+		if f, ok := res.(http.Flusher); ok {
+			f.Flush()
+		}
+
+		for {
+			select {
+			case ev, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				if err := res.Emit(&ev); err != nil {
+					return
+				}
+			case <-req.Context.Done():
+				return
+			}
+		}
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			ev, ok := v.(*events.Event)
+			if !ok {
+				return e.TypeErr(ev, v)
+			}
+
+			keys := make([]string, 0, len(ev.Data))
+			for k := range ev.Data {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			fmt.Fprintf(w, "%s %s", ev.Time.Format(time.RFC3339), ev.Type)
+			for _, k := range keys {
+				fmt.Fprintf(w, " %s=%s", k, ev.Data[k])
+			}
+			_, err := fmt.Fprintln(w)
+			return err
+		}),
+	},
+	Type: events.Event{},
+}
+
+func parseEventTypes(opt interface{}) ([]events.Type, error) {
+	s, _ := opt.(string)
+	if s == "" {
+		return nil, nil
+	}
+
+	var out []events.Type
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if !isEventType(events.Type(t)) {
+			return nil, fmt.Errorf("unknown event type: %q", t)
+		}
+		out = append(out, events.Type(t))
+	}
+	return out, nil
+}
+
+func isEventType(t events.Type) bool {
+	for _, known := range events.Types {
+		if known == t {
+			return true
+		}
+	}
+	return false
+}
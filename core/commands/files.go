@@ -10,6 +10,7 @@ import (
 	gopath "path"
 	"strings"
 
+	apiauth "github.com/ipfs/go-ipfs/apiauth"
 	bservice "github.com/ipfs/go-ipfs/blockservice"
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	lgc "github.com/ipfs/go-ipfs/commands/legacy"
@@ -34,6 +35,19 @@ import (
 
 var flog = logging.Logger("cmds/files")
 
+// errQuotaMfs is returned to the API callers with a storage quota writing to
+// MFS: the data written there isn't pinned by them, and would not count.
+var errQuotaMfs = errors.New("files: API callers with a storage quota can't write to MFS")
+
+// checkQuotaMfs fails with errQuotaMfs when the request of ctx was made by a
+// caller with a storage quota.
+func checkQuotaMfs(ctx context.Context) error {
+	if _, ok := apiauth.QuotaFromContext(ctx); ok {
+		return errQuotaMfs
+	}
+	return nil
+}
+
 // FilesCmd is the 'ipfs files' command
 var FilesCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
@@ -56,16 +70,20 @@ operations.
 		cmdkit.BoolOption("f", "flush", "Flush target and ancestors after write.").WithDefault(true),
 	},
 	Subcommands: map[string]*cmds.Command{
-		"read":  lgc.NewCommand(filesReadCmd),
-		"write": filesWriteCmd,
-		"mv":    lgc.NewCommand(filesMvCmd),
-		"cp":    lgc.NewCommand(filesCpCmd),
-		"ls":    lgc.NewCommand(filesLsCmd),
-		"mkdir": lgc.NewCommand(filesMkdirCmd),
-		"stat":  filesStatCmd,
-		"rm":    lgc.NewCommand(filesRmCmd),
-		"flush": lgc.NewCommand(filesFlushCmd),
-		"chcid": lgc.NewCommand(filesChcidCmd),
+		"read":       lgc.NewCommand(filesReadCmd),
+		"write":      filesWriteCmd,
+		"mv":         lgc.NewCommand(filesMvCmd),
+		"cp":         lgc.NewCommand(filesCpCmd),
+		"ls":         lgc.NewCommand(filesLsCmd),
+		"mkdir":      lgc.NewCommand(filesMkdirCmd),
+		"stat":       filesStatCmd,
+		"rm":         lgc.NewCommand(filesRmCmd),
+		"flush":      lgc.NewCommand(filesFlushCmd),
+		"chcid":      lgc.NewCommand(filesChcidCmd),
+		"export":     filesExportCmd,
+		"import":     filesImportCmd,
+		"replicas":   filesReplicasCmd,
+		"sync-watch": filesSyncWatchCmd,
 	},
 }
 
@@ -302,6 +320,11 @@ var filesCpCmd = &oldcmds.Command{
 		cmdkit.StringArg("dest", true, false, "Destination to copy object to."),
 	},
 	Run: func(req oldcmds.Request, res oldcmds.Response) {
+		if err := checkQuotaMfs(req.Context()); err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
 		node, err := req.InvocContext().GetNode()
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
@@ -685,7 +708,8 @@ WARNING:
 
 Usage of the '--flush=false' option does not guarantee data durability until
 the tree has been flushed. This can be accomplished by running 'ipfs files
-stat' on the file or any of its ancestors.
+flush' on the file or any of its ancestors, which returns once the new root
+is written to the repo.
 `,
 	},
 	Arguments: []cmdkit.Argument{
@@ -702,6 +726,11 @@ stat' on the file or any of its ancestors.
 		hashOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+		if err := checkQuotaMfs(req.Context); err != nil {
+			re.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
 		path, err := checkPath(req.Arguments[0])
 		if err != nil {
 			re.SetError(err, cmdkit.ErrNormal)
@@ -861,6 +890,10 @@ var filesFlushCmd = &oldcmds.Command{
 		ShortDescription: `
 Flush a given path to disk. This is only useful when other commands
 are run with the '--flush=false'.
+
+The changes under the path are propagated up to the root, and the command
+returns the CID of the root once it is written to the repo, so that the
+writes made with '--flush=false' are durable.
 `,
 	},
 	Arguments: []cmdkit.Argument{
@@ -884,8 +917,33 @@ are run with the '--flush=false'.
 			return
 		}
 
-		res.SetOutput(nil)
+		n, err := nd.FilesRoot.GetValue().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&filesFlushOutput{Cid: n.Cid().String()})
+	},
+	Marshalers: oldcmds.MarshalerMap{
+		oldcmds.Text: func(res oldcmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*filesFlushOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+			return strings.NewReader(out.Cid + "\n"), nil
+		},
 	},
+	Type: filesFlushOutput{},
+}
+
+type filesFlushOutput struct {
+	Cid string
 }
 
 var filesChcidCmd = &oldcmds.Command{
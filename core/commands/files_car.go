@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	bservice "github.com/ipfs/go-ipfs/blockservice"
+	car "github.com/ipfs/go-ipfs/car"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+
+	offline "gx/ipfs/QmPf114DXfa6TqGKYhBGR7EtXRho4rCJgwyA1xkuMY5vwF/go-ipfs-exchange-offline"
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+// filesImportBatch is the number of blocks 'ipfs files import' adds at a
+// time.
+const filesImportBatch = 128
+
+var filesExportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Export a path of the MFS tree as a CAR file.",
+		ShortDescription: `
+'ipfs files export' writes the DAG of a path of the MFS tree, by default the
+whole tree, as a CAR file with the path as its single root:
+
+  > ipfs files export / > files.car
+  > ipfs files export /photos > photos.car
+
+The file holds every block of the DAG, so 'ipfs files import' restores it
+on another node without fetching anything. Only the blocks in the repo are
+exported: the export fails if some are missing.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("path", false, false, "Path to export. Default: '/'."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		path := "/"
+		if len(req.Arguments) > 0 {
+			path, err = checkPath(req.Arguments[0])
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		fsn, err := mfs.Lookup(n.FilesRoot, path)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		nd, err := fsn.GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		// fail on the missing blocks rather than fetch them
+		dagserv := dag.NewDAGService(bservice.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(car.WriteDAG(req.Context, dagserv, nd.Cid(), pw))
+		}()
+
+		// the errors writing the DAG are returned by Emit
+		if err := res.Emit(pr); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+		}
+	},
+}
+
+type filesImportOutput struct {
+	Cid    string
+	Blocks int
+}
+
+var filesImportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Import a CAR file into the MFS tree.",
+		ShortDescription: `
+'ipfs files import' adds the blocks of a CAR file, such as written by
+'ipfs files export', to the repo, and puts its root at the given path of the
+MFS tree, which must not exist. When the path is '/', the entries of the root
+directory are added to the MFS root instead, which must not hold any of them:
+
+  > ipfs files import / files.car
+  > ipfs files import /photos photos.car
+
+The CAR file must have a single root, and hold its complete DAG, which is
+checked before changing the tree.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("path", true, false, "Path to import to."),
+		cmdkit.FileArg("file", true, false, "The CAR file.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		path, err := checkPath(req.Arguments[0])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		flush, _ := req.Options["flush"].(bool)
+
+		cr, err := openCar(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if len(cr.Header.Roots) != 1 {
+			res.SetError(fmt.Errorf("expected a car file with a single root, got %d", len(cr.Header.Roots)), cmdkit.ErrNormal)
+			return
+		}
+		root := cr.Header.Roots[0]
+
+		// keep the blocks from GC until they are in the tree
+		defer n.Blockstore.PinLock().Unlock()
+
+		ck := car.NewChecker()
+		count := 0
+		batch := make([]blocks.Block, 0, filesImportBatch)
+		for {
+			b, err := cr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			if err := ck.Add(b); err != nil {
+				res.SetError(fmt.Errorf("invalid block %s: %s", b.Cid(), err), cmdkit.ErrNormal)
+				return
+			}
+			count++
+
+			batch = append(batch, b)
+			if len(batch) == filesImportBatch {
+				if err := n.Blocks.AddBlocks(batch); err != nil {
+					res.SetError(err, cmdkit.ErrNormal)
+					return
+				}
+				batch = make([]blocks.Block, 0, filesImportBatch)
+			}
+		}
+		if err := n.Blocks.AddBlocks(batch); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if missing := ck.Missing(cr.Header.Roots); len(missing) > 0 {
+			res.SetError(fmt.Errorf("car file is missing %d blocks of its DAG, like %s", len(missing), missing[0]), cmdkit.ErrNormal)
+			return
+		}
+
+		nd, err := n.DAG.Get(req.Context, root)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if path == "/" {
+			err = importEntries(req, n.DAG, n.FilesRoot, nd)
+		} else {
+			err = mfs.PutNode(n.FilesRoot, path, nd)
+		}
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if flush {
+			if err := mfs.FlushPath(n.FilesRoot, path); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		cmds.EmitOnce(res, &filesImportOutput{Cid: root.String(), Blocks: count})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*filesImportOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+			_, err := fmt.Fprintf(w, "imported %s (%d blocks)\n", out.Cid, out.Blocks)
+			return err
+		}),
+	},
+	Type: filesImportOutput{},
+}
+
+// importEntries adds the entries of the directory nd to the MFS root, after
+// checking that it holds none of them.
+func importEntries(req *cmds.Request, ng ipld.NodeGetter, r *mfs.Root, nd ipld.Node) error {
+	errNotDir := errors.New("the root of the car file isn't a directory, it can't be imported to '/'")
+	pbnd, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return errNotDir
+	}
+	fsn, err := ft.FromBytes(pbnd.Data())
+	if err != nil || fsn.GetType() != ft.TDirectory {
+		return errNotDir
+	}
+
+	for _, l := range pbnd.Links() {
+		if _, err := mfs.Lookup(r, "/"+l.Name); err == nil {
+			return fmt.Errorf("'/%s' already exists", l.Name)
+		}
+	}
+	for _, l := range pbnd.Links() {
+		child, err := l.GetNode(req.Context, ng)
+		if err != nil {
+			return err
+		}
+		if err := mfs.PutNode(r, "/"+l.Name, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
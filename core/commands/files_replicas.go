@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	replication "github.com/ipfs/go-ipfs/replication"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+type filesReplicasOutput struct {
+	Replicas []replication.Status
+}
+
+var filesReplicasCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the state of the replication of the MFS tree.",
+		ShortDescription: `
+'ipfs files replicas' lists the replicas the MFS tree is copied to, set in
+'Replication.Replicas' in the config, with the last root each one copied,
+the root being pushed to it and why the last push failed, if it did:
+
+  > ipfs files replicas
+  QmReplica...  QmRoot...  2018-06-21T12:00:00Z  -  -
+
+Each time the MFS root changes, it's pushed to the replicas, which fetch its
+DAG from this node and put it at /replicas/<peer ID of this node> in their
+own tree, if this node is in their 'Replication.Primaries'. Failed pushes
+are retried every minute with the latest root.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmdkit.ErrClient)
+			return
+		}
+		if n.Replication == nil {
+			res.SetError(fmt.Errorf("no replicas are set in 'Replication.Replicas'"), cmdkit.ErrNormal)
+			return
+		}
+
+		cmds.EmitOnce(res, &filesReplicasOutput{Replicas: n.Replication.Status()})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*filesReplicasOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			tw := tabwriter.NewWriter(w, 1, 2, 2, ' ', 0)
+			for _, s := range out.Replicas {
+				root, updated := "-", "-"
+				if s.Root != "" {
+					root, updated = s.Root, s.Updated.Format(time.RFC3339)
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Peer, root, updated, orDash(s.Pending), orDash(s.LastError))
+			}
+			return tw.Flush()
+		}),
+	},
+	Type: filesReplicasOutput{},
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
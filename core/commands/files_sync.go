@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+type filesSyncOutput struct {
+	Op   string
+	Path string
+	Hash string `json:",omitempty"`
+}
+
+var filesSyncWatchCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Mirror a local directory into MFS as it changes.",
+		ShortDescription: `
+'ipfs files sync-watch' copies a local directory to a directory of MFS, then
+watches it, mirroring the files and directories created, changed, renamed
+and removed, until it's interrupted:
+
+  > ipfs files sync-watch ./site /site
+  sync   /site  QmRoot...
+  write  /site/index.html  QmFile...
+  sync   /site  QmRoot...
+
+The changes are mirrored once they've settled for the --debounce duration,
+the MFS directory being flushed after each batch. The hash of the directory
+is printed each time, ready for 'ipfs name publish' or a gateway.
+
+Files and directories whose names match one of the comma-separated glob
+patterns of --exclude aren't mirrored, nor is anything under them:
+
+  > ipfs files sync-watch --exclude='.git,*.swp,node_modules' ./site /site
+
+The mirror is recorded each time it's synced. When it's changed in MFS
+rather than through the local directory, by another client or while it isn't
+watched, its changes are kept and aren't overwritten by the local versions,
+unless they changed too. Such conflicts are reported and left as they are:
+
+  conflict /site/index.html  QmMirrorVersion...
+
+until one version is copied over the other, or they're resolved with
+--resolve, by path:
+
+  > ipfs files sync-watch --resolve=/site/index.html=local ./site /site
+
+'local' overwrites the mirror with the local version, and 'mfs' keeps the
+mirror as it is, ignoring the local version until it changes again.
+--on-conflict resolves all the conflicts one way or the other.
+
+The local directory is watched by the daemon, which must run on the same
+machine.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("localdir", true, false, "Local directory to mirror."),
+		cmdkit.StringArg("mfsdir", true, false, "MFS directory to mirror it into."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("exclude", "Comma-separated glob patterns of the names not to mirror."),
+		cmdkit.StringOption("debounce", "How long to wait for the changes to settle, up to 5s.").WithDefault("200ms"),
+		cmdkit.StringOption("resolve", "Comma-separated <mfs-path>=<local|mfs> resolutions of the conflicts."),
+		cmdkit.StringOption("on-conflict", "Resolution of the other conflicts: report, local or mfs.").WithDefault("report"),
+		cidVersionOption,
+		hashOption,
+	},
+	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		// the directory is watched by the node, whose working directory differs
+		abs, err := filepath.Abs(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		req.Arguments[0] = abs
+		return nil
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		local := req.Arguments[0]
+		if fi, err := os.Stat(local); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		} else if !fi.IsDir() {
+			res.SetError(fmt.Errorf("%s is not a directory", local), cmdkit.ErrClient)
+			return
+		}
+		dir, err := checkPath(req.Arguments[1])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		debounceStr, _ := req.Options["debounce"].(string)
+		debounce, err := time.ParseDuration(debounceStr)
+		if err != nil {
+			res.SetError(fmt.Errorf("invalid debounce duration %q: %s", debounceStr, err), cmdkit.ErrClient)
+			return
+		}
+		prefix, err := getPrefixNew(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		s := coreunix.NewSyncer(n, local, dir)
+		s.Debounce = debounce
+		s.Prefix = prefix
+		if exclude, _ := req.Options["exclude"].(string); exclude != "" {
+			for _, pattern := range strings.Split(exclude, ",") {
+				if _, err := filepath.Match(pattern, ""); err != nil {
+					res.SetError(fmt.Errorf("invalid exclude pattern %q: %s", pattern, err), cmdkit.ErrClient)
+					return
+				}
+				s.Exclude = append(s.Exclude, pattern)
+			}
+		}
+		onConflict, _ := req.Options["on-conflict"].(string)
+		switch onConflict {
+		case "report":
+		case coreunix.ResolveLocal, coreunix.ResolveMFS:
+			s.OnConflict = onConflict
+		default:
+			res.SetError(fmt.Errorf("invalid conflict resolution %q", onConflict), cmdkit.ErrClient)
+			return
+		}
+		if resolve, _ := req.Options["resolve"].(string); resolve != "" {
+			s.Resolve, err = parseSyncResolutions(resolve, dir)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrClient)
+				return
+			}
+		}
+		s.Events = func(ev coreunix.SyncEvent) error {
+			out := &filesSyncOutput{Op: ev.Op, Path: ev.Path}
+			if ev.Cid != nil {
+				out.Hash = ev.Cid.String()
+			}
+			return res.Emit(out)
+		}
+
+		if err := s.Watch(req.Context); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+		}
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*filesSyncOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+			if out.Hash == "" {
+				_, err := fmt.Fprintf(w, "%-6s %s\n", out.Op, out.Path)
+				return err
+			}
+			_, err := fmt.Fprintf(w, "%-6s %s  %s\n", out.Op, out.Path, out.Hash)
+			return err
+		}),
+	},
+	Type: filesSyncOutput{},
+}
+
+// parseSyncResolutions parses the resolutions of --resolve, returning them by
+// path relative to the mirror dir.
+func parseSyncResolutions(resolve, dir string) (map[string]string, error) {
+	resolutions := make(map[string]string)
+	for _, r := range strings.Split(resolve, ",") {
+		i := strings.LastIndex(r, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid resolution %q, expected <mfs-path>=<local|mfs>", r)
+		}
+		p, resolution := gopath.Clean(r[:i]), r[i+1:]
+		if resolution != coreunix.ResolveLocal && resolution != coreunix.ResolveMFS {
+			return nil, fmt.Errorf("invalid conflict resolution %q of %s", resolution, p)
+		}
+		rel := strings.TrimPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+		if rel == p || rel == "" {
+			return nil, fmt.Errorf("%s isn't under %s", p, dir)
+		}
+		resolutions[rel] = resolution
+	}
+	return resolutions, nil
+}
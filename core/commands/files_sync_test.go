@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"testing"
+)
+
+func TestParseSyncResolutions(t *testing.T) {
+	r, err := parseSyncResolutions("/site/index.html=local,/site/a=b/c.html=mfs,/site/blog/=mfs", "/site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"index.html": "local",
+		"a=b/c.html": "mfs",
+		"blog":       "mfs",
+	}
+	if len(r) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, r)
+	}
+	for p, res := range expected {
+		if r[p] != res {
+			t.Fatalf("expected %v, got %v", expected, r)
+		}
+	}
+
+	if r, err := parseSyncResolutions("/index.html=mfs", "/"); err != nil || r["index.html"] != "mfs" {
+		t.Fatalf("expected index.html to be resolved under /, got %v, %v", r, err)
+	}
+
+	for _, invalid := range []string{"/site/index.html", "/site/index.html=theirs", "/other/index.html=local", "/site=local"} {
+		if _, err := parseSyncResolutions(invalid, "/site"); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
@@ -16,7 +16,6 @@ import (
 	uarchive "github.com/ipfs/go-ipfs/unixfs/archive"
 
 	"gx/ipfs/QmPtj12fdwuAqj9sBSTNUxBNu8kCGNp8b3o8yUzMm5GHpq/pb"
-	tar "gx/ipfs/QmQine7gvHncNevKtG9QXxf3nXcwSj6aDDmMm52mHofEEp/tar-utils"
 	"gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
 	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
 )
@@ -36,6 +35,12 @@ To output a TAR archive instead of unpacked files, use '--archive' or '-a'.
 
 To compress the output with GZIP compression, use '--compress' or '-C'. You
 may also specify the level of compression by specifying '-l=<1-9>'.
+
+With '--continue', the progress of the download of files is recorded next to
+the output, in '<output>.ipfs-get', until it completes. A download interrupted
+can then be continued into the same output with '--continue' again: the files
+already written are checked against the record and the downloaded data, and
+only written from where they differ.
 `,
 	},
 
@@ -47,10 +52,19 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 		cmdkit.BoolOption("archive", "a", "Output a TAR archive."),
 		cmdkit.BoolOption("compress", "C", "Compress the output with GZIP compression."),
 		cmdkit.IntOption("compression-level", "l", "The level of compression (1-9)."),
+		cmdkit.BoolOption("continue", "Record the progress of the download, continuing the interrupted one into the same output."),
 	},
 	PreRun: func(req *cmds.Request, env cmds.Environment) error {
-		_, err := getCompressOptions(req)
-		return err
+		cmplvl, err := getCompressOptions(req)
+		if err != nil {
+			return err
+		}
+		archive, _ := req.Options["archive"].(bool)
+		resume, _ := req.Options["continue"].(bool)
+		if resume && (archive || cmplvl != gzip.NoCompression) {
+			return errors.New("--continue can't be used with --archive or --compress")
+		}
+		return nil
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
 		cmplvl, err := getCompressOptions(req)
@@ -124,6 +138,7 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 				}
 
 				archive, _ := req.Options["archive"].(bool)
+				resume, _ := req.Options["continue"].(bool)
 
 				gw := getWriter{
 					Out:         os.Stdout,
@@ -131,6 +146,8 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 					Archive:     archive,
 					Compression: cmplvl,
 					Size:        int64(res.Length()),
+					Path:        req.Arguments[0],
+					Resume:      resume,
 				}
 
 				if err := gw.Write(outReader, outPath); err != nil {
@@ -196,6 +213,11 @@ type getWriter struct {
 	Archive     bool
 	Compression int
 	Size        int64
+
+	// Path is the IPFS path downloaded, and Resume whether its download is
+	// continued.
+	Path   string
+	Resume bool
 }
 
 func (gw *getWriter) Write(r io.Reader, fpath string) error {
@@ -237,13 +259,22 @@ func (gw *getWriter) writeArchive(r io.Reader, fpath string) error {
 }
 
 func (gw *getWriter) writeExtracted(r io.Reader, fpath string) error {
-	fmt.Fprintf(gw.Out, "Saving file(s) to %s\n", fpath)
+	extractor, err := newResumingExtractor(fpath, gw.Path, gw.Resume)
+	if err != nil {
+		return err
+	}
+
+	if gw.Resume {
+		fmt.Fprintf(gw.Out, "Continuing to save file(s) to %s\n", fpath)
+	} else {
+		fmt.Fprintf(gw.Out, "Saving file(s) to %s\n", fpath)
+	}
 	bar := makeProgressBar(gw.Err, gw.Size)
 	bar.Start()
 	defer bar.Finish()
 	defer bar.Set64(gw.Size)
 
-	extractor := &tar.Extractor{Path: fpath, Progress: bar.Add64}
+	extractor.Progress = bar.Add64
 	return extractor.Extract(r)
 }
 
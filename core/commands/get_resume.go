@@ -0,0 +1,371 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"strings"
+)
+
+// getProgressSuffix is appended to the output path of 'ipfs get' to name the
+// file recording the progress of the download.
+const getProgressSuffix = ".ipfs-get"
+
+// getCheckpointSize is the number of bytes written to a file between the
+// records of the progress.
+var getCheckpointSize int64 = 4 << 20
+
+// getProgress is the progress of a download by 'ipfs get', recorded so that
+// it can be continued once interrupted. It's recorded as a journal: the
+// getProgress of the download, then a getFileRecord per checkpoint of a file,
+// the last one of each file being its progress.
+type getProgress struct {
+	// Path is the IPFS path downloaded.
+	Path string
+	// Files are the files written, by path relative to the output.
+	Files map[string]*getFileProgress `json:",omitempty"`
+}
+
+// getFileProgress is the progress of the download of a file.
+type getFileProgress struct {
+	// Offset is the number of bytes written.
+	Offset int64
+	// Hash is the hex SHA-256 of the bytes written.
+	Hash string
+}
+
+// getFileRecord is a record of the journal of the progress of a download.
+type getFileRecord struct {
+	// File is the path of the file, relative to the output.
+	File string
+	getFileProgress
+}
+
+// loadGetProgress returns the progress recorded in fpath, or nil when there
+// is none.
+func loadGetProgress(fpath string) (*getProgress, error) {
+	f, err := os.Open(fpath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	var p getProgress
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("invalid progress file %s: %s", fpath, err)
+	}
+	if p.Files == nil {
+		p.Files = make(map[string]*getFileProgress)
+	}
+	for {
+		var rec getFileRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// the last record may be cut by the interruption, the
+			// previous ones of its file still hold
+			log.Debugf("ignoring the end of the progress file %s: %s", fpath, err)
+			break
+		}
+		fp := rec.getFileProgress
+		p.Files[rec.File] = &fp
+	}
+	return &p, nil
+}
+
+// resumingExtractor extracts the tar archives of 'ipfs get' like the
+// Extractor of tar-utils, recording its progress so that an interrupted
+// extraction can be continued. When continuing, the files partially written
+// are checked against the progress recorded and against the archive, and only
+// written from the first byte differing.
+type resumingExtractor struct {
+	Path     string
+	Progress func(int64) int64
+
+	progress     *getProgress
+	progressPath string
+	resume       bool
+	// journal is the file the progress is appended to
+	journal *os.File
+}
+
+// newResumingExtractor returns an extractor of the download of the IPFS path
+// ipath to fpath. With resume, it continues the download recorded there, if
+// any, and records its progress. Without, nothing is recorded.
+func newResumingExtractor(fpath, ipath string, resume bool) (*resumingExtractor, error) {
+	te := &resumingExtractor{
+		Path:         fpath,
+		Progress:     func(n int64) int64 { return n },
+		progressPath: fpath + getProgressSuffix,
+		resume:       resume,
+	}
+
+	if resume {
+		p, err := loadGetProgress(te.progressPath)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil && p.Path != ipath {
+			return nil, fmt.Errorf("the download at %s is of %s, not %s", fpath, p.Path, ipath)
+		}
+		te.progress = p
+	}
+	if te.progress == nil {
+		te.progress = &getProgress{Path: ipath, Files: make(map[string]*getFileProgress)}
+	}
+	return te, nil
+}
+
+// Extract extracts the archive read from reader. The record of the progress
+// is removed once it's complete.
+func (te *resumingExtractor) Extract(reader io.Reader) error {
+	// the output goes into the output path when it's an existing directory
+	rootIsDir := false
+	if stat, err := os.Stat(te.Path); err == nil {
+		rootIsDir = stat.IsDir()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if te.resume {
+		if err := te.openJournal(); err != nil {
+			return err
+		}
+		defer te.journal.Close()
+	}
+
+	tr := tar.NewReader(reader)
+	for i := 0; ; i++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		rel, err := te.relativePath(hdr.Name)
+		if err != nil {
+			return err
+		}
+		fpath := filepath.Join(te.Path, rel)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if i == 0 {
+				te.Path = fpath
+			}
+			err = os.MkdirAll(fpath, 0755)
+		case tar.TypeReg:
+			if i == 0 && rootIsDir {
+				// the only file, put in the output directory
+				rel = gopath.Base(hdr.Name)
+				fpath = filepath.Join(te.Path, rel)
+			}
+			err = te.extractFile(fpath, filepath.ToSlash(rel), tr)
+		case tar.TypeSymlink:
+			if te.resume {
+				if fi, err := os.Lstat(fpath); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+					os.Remove(fpath)
+				}
+			}
+			err = os.Symlink(hdr.Linkname, fpath)
+		default:
+			err = fmt.Errorf("unrecognized tar header type: %d", hdr.Typeflag)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if !te.resume {
+		return nil
+	}
+	te.journal.Close()
+	return os.Remove(te.progressPath)
+}
+
+// openJournal starts the journal of the progress, to append to it the
+// progress of the files. It starts with the progress loaded, if any, which
+// drops the records cut by an interruption.
+func (te *resumingExtractor) openJournal() error {
+	tmp := te.progressPath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	te.journal = f
+	if err := te.appendJournal(te.progress); err != nil {
+		f.Close()
+		return err
+	}
+	return os.Rename(tmp, te.progressPath)
+}
+
+// appendJournal appends v, as a line of JSON, to the journal.
+func (te *resumingExtractor) appendJournal(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = te.journal.Write(append(b, '\n'))
+	return err
+}
+
+// relativePath returns the path of the entry of the archive named name,
+// relative to the output, which is the root of the archive.
+func (te *resumingExtractor) relativePath(name string) (string, error) {
+	elems := strings.Split(name, "/")[1:]
+	for _, e := range elems {
+		if e == ".." {
+			return "", fmt.Errorf("invalid path in the archive: %s", name)
+		}
+	}
+	return filepath.Join(elems...), nil
+}
+
+// extractFile writes the file read from r to fpath, continuing the write
+// recorded for rel, if any.
+func (te *resumingExtractor) extractFile(fpath, rel string, r io.Reader) error {
+	f, err := os.OpenFile(fpath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	offset, err := te.resumeFile(f, rel, r, h)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	fp := &getFileProgress{Offset: offset}
+	te.progress.Files[rel] = fp
+	checkpoint := func() error {
+		if te.journal == nil {
+			return nil
+		}
+		fp.Hash = hex.EncodeToString(h.Sum(nil))
+		return te.appendJournal(&getFileRecord{File: rel, getFileProgress: *fp})
+	}
+
+	buf := make([]byte, 32*1024)
+	written := int64(0)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, err := f.Write(buf[:n]); err != nil {
+				return err
+			}
+			h.Write(buf[:n])
+			fp.Offset += int64(n)
+			te.Progress(int64(n))
+
+			written += int64(n)
+			if written >= getCheckpointSize {
+				written = 0
+				if err := checkpoint(); err != nil {
+					return err
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return checkpoint()
+}
+
+// resumeFile returns the number of bytes of the file f, being continued, to
+// keep: those written before, as recorded, which are the first bytes read from
+// r. These bytes are consumed from r and added to h. It returns 0 when the
+// file isn't continued, or when it was modified since.
+func (te *resumingExtractor) resumeFile(f *os.File, rel string, r io.Reader, h hash.Hash) (int64, error) {
+	fp, ok := te.progress.Files[rel]
+	if !te.resume || !ok || fp.Offset == 0 {
+		return 0, nil
+	}
+
+	// the bytes written must not have changed since
+	written := sha256.New()
+	n, err := io.CopyN(written, f, fp.Offset)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	if n != fp.Offset || hex.EncodeToString(written.Sum(nil)) != fp.Hash {
+		log.Infof("%s changed since it was written, writing it again", rel)
+		return 0, nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	// and must be the first bytes of the file in the archive, which
+	// changes when the path is an IPNS one
+	same := int64(0)
+	buf := make([]byte, 32*1024)
+	disk := make([]byte, 32*1024)
+	for same < fp.Offset {
+		want := int64(len(buf))
+		if rest := fp.Offset - same; rest < want {
+			want = rest
+		}
+		n, err := io.ReadFull(r, buf[:want])
+		if _, err := io.ReadFull(f, disk[:n]); err != nil {
+			return 0, err
+		}
+		if !bytes.Equal(buf[:n], disk[:n]) {
+			// the rest of the file is written from where it differs
+			i := 0
+			for buf[i] == disk[i] {
+				i++
+			}
+			h.Write(buf[:i])
+			same += int64(i)
+			te.Progress(int64(i))
+			if _, err := f.Seek(same, io.SeekStart); err != nil {
+				return 0, err
+			}
+			if _, err := f.Write(buf[i:n]); err != nil {
+				return 0, err
+			}
+			h.Write(buf[i:n])
+			te.Progress(int64(n - i))
+			return same + int64(n-i), nil
+		}
+		h.Write(buf[:n])
+		same += int64(n)
+		te.Progress(int64(n))
+		if err != nil {
+			// the file of the archive is shorter than the one written
+			if err == io.ErrUnexpectedEOF || err == io.EOF {
+				break
+			}
+			return 0, err
+		}
+	}
+	return same, nil
+}
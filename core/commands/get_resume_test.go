@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func makeGetArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "root", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		data := files[name]
+		err := tw.WriteHeader(&tar.Header{Name: "root/" + name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// failingReader fails after reading n bytes.
+type failingReader struct {
+	r io.Reader
+	n int
+}
+
+var errInterrupted = errors.New("interrupted")
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.n <= 0 {
+		return 0, errInterrupted
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	n, err := r.r.Read(p)
+	r.n -= n
+	return n, err
+}
+
+func TestGetResume(t *testing.T) {
+	defer func(size int64) { getCheckpointSize = size }(getCheckpointSize)
+	getCheckpointSize = 1000
+
+	dir, err := ioutil.TempDir("", "get-resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+
+	files := map[string]string{
+		"a": string(bytes.Repeat([]byte("a"), 3000)),
+		"b": string(bytes.Repeat([]byte("b"), 5000)),
+		"c": "c",
+	}
+	archive := makeGetArchive(t, files)
+
+	// nothing is recorded without resume
+	te, err := newResumingExtractor(out, "/ipfs/QmFoo", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = te.Extract(&failingReader{r: bytes.NewReader(archive), n: 6000})
+	if err != errInterrupted {
+		t.Fatalf("expected the extraction to be interrupted, got %v", err)
+	}
+	if _, err := os.Stat(out + getProgressSuffix); !os.IsNotExist(err) {
+		t.Fatal("expected no progress file without resume")
+	}
+	if err := os.RemoveAll(out); err != nil {
+		t.Fatal(err)
+	}
+
+	// interrupted in the middle of b
+	te, err = newResumingExtractor(out, "/ipfs/QmFoo", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = te.Extract(&failingReader{r: bytes.NewReader(archive), n: 6000})
+	if err != errInterrupted {
+		t.Fatalf("expected the extraction to be interrupted, got %v", err)
+	}
+	// with a record cut by the interruption
+	f, err := os.OpenFile(out+getProgressSuffix, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"File":"b","Offs`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	p, err := loadGetProgress(out + getProgressSuffix)
+	if err != nil || p == nil {
+		t.Fatalf("expected the progress to be recorded: %v", err)
+	}
+	if p.Files["a"].Offset != 3000 || p.Files["b"].Offset == 0 {
+		t.Fatalf("unexpected progress: a %d, b %d", p.Files["a"].Offset, p.Files["b"].Offset)
+	}
+
+	if _, err := newResumingExtractor(out, "/ipfs/QmBar", true); err == nil {
+		t.Fatal("expected continuing the download of another path to fail")
+	}
+
+	// the source of b changed within the bytes written
+	changed := bytes.Repeat([]byte("b"), 5000)
+	changed[500] = 'x'
+	files["b"] = string(changed)
+	archive = makeGetArchive(t, files)
+
+	te, err = newResumingExtractor(out, "/ipfs/QmFoo", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var progress int64
+	te.Progress = func(n int64) int64 {
+		progress += n
+		return progress
+	}
+	if err := te.Extract(bytes.NewReader(archive)); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range files {
+		b, err := ioutil.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != data {
+			t.Fatalf("%s: unexpected content after continuing", name)
+		}
+	}
+	if progress != 8001 {
+		t.Fatalf("progress reported %d bytes, expected 8001", progress)
+	}
+	if _, err := os.Stat(out + getProgressSuffix); !os.IsNotExist(err) {
+		t.Fatal("expected the progress file to be removed once complete")
+	}
+}
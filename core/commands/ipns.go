@@ -2,6 +2,7 @@ package commands
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 	"time"
@@ -10,11 +11,24 @@ import (
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	nsopts "github.com/ipfs/go-ipfs/namesys/opts"
+	path "github.com/ipfs/go-ipfs/path"
 
 	offline "gx/ipfs/Qmb1N7zdjG2FexpzWNj8T289u9QnQLEiSsTRadDGQxX32D/go-ipfs-routing/offline"
 	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
 )
 
+// ResolvedName is the value an IPNS name resolves to.
+type ResolvedName struct {
+	Path path.Path
+	// Sequence and Partial are set with --stream: Sequence is the sequence
+	// number of the IPNS record the value comes from, and Partial marks the
+	// values found before the resolution completed.
+	Sequence uint64 `json:",omitempty"`
+	Partial  bool   `json:",omitempty"`
+	// Error is set when the complete resolution failed, with --stream.
+	Error string `json:",omitempty"`
+}
+
 var IpnsCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Resolve IPNS names.",
@@ -50,6 +64,18 @@ Resolve the value of a dnslink:
   > ipfs name resolve ipfs.io
   /ipfs/QmaBvfZooxWkrv7D3r8LS9moNjzD2o525XMZze69hhoxf5
 
+Stream the values found while the resolution completes:
+
+  > ipfs name resolve --stream QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+  /ipfs/QmSiTko9JZyabH56y2fussEt1A5oDqsFXB3CkvAqraFryz (partial, sequence 4)
+  /ipfs/QmXsQDCkNKiAqZ2Mo5vxNLbG5pbNLBxtFtKuW3qfEcn1XR
+
+With --stream, the value of the best IPNS record found so far is output each
+time a better one is found, marked as partial with its sequence number, while
+the query for the number of records set by --dht-record-count completes. The
+last value is the one the complete resolution gives. The names other than
+IPNS keys, and the ones cached, are output at once.
+
 `,
 	},
 
@@ -59,8 +85,9 @@ Resolve the value of a dnslink:
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("recursive", "r", "Resolve until the result is not an IPNS name."),
 		cmdkit.BoolOption("nocache", "n", "Do not use cached entries."),
-		cmdkit.UintOption("dht-record-count", "dhtrc", "Number of records to request for DHT resolution."),
-		cmdkit.StringOption("dht-timeout", "dhtt", "Max time to collect values during DHT resolution eg \"30s\". Pass 0 for no timeout."),
+		cmdkit.UintOption("dht-record-count", "dhtrc", "Number of records to request for DHT resolution. Defaults to Ipns.ResolveRecordCount."),
+		cmdkit.StringOption("dht-timeout", "dhtt", "Max time to collect values during DHT resolution eg \"30s\". Pass 0 for no timeout. Defaults to Ipns.ResolveTimeout."),
+		cmdkit.BoolOption("stream", "s", "Output the values found before the resolution completes."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -94,7 +121,13 @@ Resolve the value of a dnslink:
 		}
 
 		if nocache {
-			resolver = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), 0)
+			policy, err := n.ResolvePolicy()
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			policy.CacheSize = 0
+			resolver = namesys.NewNameSystemWithPolicy(n.Routing, n.Repo.Datastore(), policy)
 		}
 
 		var name string
@@ -136,6 +169,12 @@ Resolve the value of a dnslink:
 			name = "/ipns/" + name
 		}
 
+		stream, _, _ := req.Option("stream").Bool()
+		if pr, ok := resolver.(namesys.ProgressiveResolver); ok && stream {
+			streamResolve(req, res, pr, name, ropts)
+			return
+		}
+
 		output, err := resolver.Resolve(req.Context(), name, ropts...)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
@@ -144,7 +183,7 @@ Resolve the value of a dnslink:
 
 		// TODO: better errors (in the case of not finding the name, we get "failed to find any peer in table")
 
-		res.SetOutput(&ResolvedPath{output})
+		res.SetOutput(&ResolvedName{Path: output})
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
@@ -153,12 +192,53 @@ Resolve the value of a dnslink:
 				return nil, err
 			}
 
-			output, ok := v.(*ResolvedPath)
+			output, ok := v.(*ResolvedName)
 			if !ok {
 				return nil, e.TypeErr(output, v)
 			}
+			switch {
+			case output.Error != "":
+				return strings.NewReader(fmt.Sprintf("Failed to resolve: %s\n", output.Error)), nil
+			case output.Partial:
+				return strings.NewReader(fmt.Sprintf("%s (partial, sequence %d)\n", output.Path, output.Sequence)), nil
+			}
 			return strings.NewReader(output.Path.String() + "\n"), nil
 		},
 	},
-	Type: ResolvedPath{},
+	Type: ResolvedName{},
+}
+
+// streamResolve resolves name with r, outputting the values found before
+// the resolution completes as partial ones.
+func streamResolve(req cmds.Request, res cmds.Response, r namesys.ProgressiveResolver, name string, ropts []nsopts.ResolveOpt) {
+	progress := r.ResolveProgressive(req.Context(), name, ropts...)
+
+	// fail the command when the resolution fails before finding anything
+	pr, ok := <-progress
+	if !ok {
+		res.SetError(req.Context().Err(), cmdkit.ErrNormal)
+		return
+	}
+	if pr.Final && pr.Err != nil {
+		res.SetError(pr.Err, cmdkit.ErrNormal)
+		return
+	}
+
+	out := make(chan interface{})
+	res.SetOutput((<-chan interface{})(out))
+	go func() {
+		defer close(out)
+		for ok {
+			v := &ResolvedName{Path: pr.Path, Sequence: pr.Sequence, Partial: !pr.Final}
+			if pr.Err != nil {
+				v.Error = pr.Err.Error()
+			}
+			select {
+			case out <- v:
+			case <-req.Context().Done():
+				return
+			}
+			pr, ok = <-progress
+		}
+	}()
 }
@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	republisher "github.com/ipfs/go-ipfs/namesys/republisher"
+
+	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+var ipnsRepublishStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the state of the IPNS republisher.",
+		ShortDescription: `
+Show when the IPNS records of the keys were last republished, and the
+republishes which failed.
+`,
+		LongDescription: `
+Show when the IPNS records of the keys were last republished, and the
+republishes which failed.
+
+The daemon republishes the records of the node and of the keys of the keystore
+every Ipns.RepublishPeriod. For each key, the status tells the last republish
+attempted and the last successful one, the error of the last republish if it
+failed, and the number of republishes which failed in a row.
+
+When the daemon isn't running, the status saved by its last run is shown.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if n.IpnsRepub != nil {
+			res.SetOutput(n.IpnsRepub.Status())
+			return
+		}
+
+		status, err := republisher.LoadStatus(n.Repo.Datastore())
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		res.SetOutput(status)
+	},
+	Type: republisher.Status{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			status, ok := v.(*republisher.Status)
+			if !ok {
+				return nil, e.TypeErr(status, v)
+			}
+
+			buf := new(bytes.Buffer)
+			if !status.Next.IsZero() {
+				fmt.Fprintf(buf, "Next republish: %s\n", status.Next.Local().Format(time.RFC3339))
+			}
+			if len(status.Keys) == 0 {
+				fmt.Fprintln(buf, "No record republished.")
+				return buf, nil
+			}
+
+			tw := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tID\tLAST SUCCESS\tFAILURES\tLAST ERROR")
+			for _, k := range status.Keys {
+				success := "never"
+				if !k.LastSuccess.IsZero() {
+					success = k.LastSuccess.Local().Format(time.RFC3339)
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", k.Name, k.ID, success, k.Failures, k.LastError)
+			}
+			tw.Flush()
+			return buf, nil
+		},
+	},
+}
@@ -2,15 +2,19 @@ package commands
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"fmt"
 	"io"
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	pb "github.com/ipfs/go-ipfs/namesys/pb"
 
 	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
 	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
@@ -45,6 +49,19 @@ publish'.
 type KeyOutput struct {
 	Name string
 	Id   string
+	// Published is the IPNS record published with the key, with --usage.
+	Published *KeyPublished `json:",omitempty"`
+}
+
+// KeyPublished is the IPNS record this node published with a key.
+type KeyPublished struct {
+	Value    string
+	Sequence uint64
+	// Time is when the record was last published, empty when unknown.
+	Time string `json:",omitempty"`
+	// EOL is the end of validity of the record.
+	EOL     string `json:",omitempty"`
+	Expired bool
 }
 
 type KeyOutputList struct {
@@ -170,9 +187,16 @@ var keyGenCmd = &cmds.Command{
 var keyListCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "List all local keypairs",
+		ShortDescription: `
+'ipfs key list' lists the available keys. With --usage, it shows the IPNS
+record this node published with each key: its value, its sequence number,
+when it was last published and when it expires. The keys this node never
+published with are shown as not published.
+`,
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("l", "Show extra information about keys."),
+		cmdkit.BoolOption("usage", "u", "Show the IPNS record published with each key."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -211,6 +235,18 @@ var keyListCmd = &cmds.Command{
 			list = append(list, KeyOutput{Name: key, Id: pid.Pretty()})
 		}
 
+		usage, _, _ := req.Option("usage").Bool()
+		if usage {
+			publisher := namesys.NewIpnsPublisher(n.Routing, n.Repo.Datastore())
+			for i := range list {
+				list[i].Published, err = keyPublished(req.Context(), publisher, list[i].Id)
+				if err != nil {
+					res.SetError(err, cmdkit.ErrNormal)
+					return
+				}
+			}
+		}
+
 		res.SetOutput(&KeyOutputList{list})
 	},
 	Marshalers: cmds.MarshalerMap{
@@ -399,15 +435,75 @@ func keyOutputListMarshaler(res cmds.Response) (io.Reader, error) {
 		return nil, e.TypeErr(list, v)
 	}
 
+	usage, _, _ := res.Request().Option("usage").Bool()
+
 	buf := new(bytes.Buffer)
 	w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
 	for _, s := range list.Keys {
-		if withId {
+		switch {
+		case withId && usage:
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.Id, s.Name, keyPublishedString(s.Published))
+		case usage:
+			fmt.Fprintf(w, "%s\t%s\n", s.Name, keyPublishedString(s.Published))
+		case withId:
 			fmt.Fprintf(w, "%s\t%s\t\n", s.Id, s.Name)
-		} else {
+		default:
 			fmt.Fprintf(w, "%s\n", s.Name)
 		}
 	}
 	w.Flush()
 	return buf, nil
 }
+
+// keyPublished returns the IPNS record this node published with the key of
+// the base58 id, nil if there is none.
+func keyPublished(ctx context.Context, publisher *namesys.IpnsPublisher, id string) (*KeyPublished, error) {
+	pid, err := peer.IDB58Decode(id)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := publisher.GetPublished(ctx, pid, false)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	out := &KeyPublished{
+		Value:    string(entry.GetValue()),
+		Sequence: entry.GetSequence(),
+	}
+
+	last, err := publisher.LastPublished(pid)
+	if err != nil {
+		return nil, err
+	}
+	if !last.IsZero() {
+		out.Time = last.Format(time.RFC3339)
+	}
+
+	if entry.GetValidityType() == pb.IpnsEntry_EOL {
+		eol, err := time.Parse(time.RFC3339Nano, string(entry.GetValidity()))
+		if err == nil {
+			out.EOL = eol.UTC().Format(time.RFC3339)
+			out.Expired = time.Now().After(eol)
+		}
+	}
+	return out, nil
+}
+
+// keyPublishedString describes the record published with a key, for the
+// text output.
+func keyPublishedString(p *KeyPublished) string {
+	if p == nil {
+		return "not published\t"
+	}
+
+	published := "-"
+	if p.Time != "" {
+		published = p.Time
+	}
+	expires := "expires " + p.EOL
+	if p.Expired {
+		expires = "expired " + p.EOL
+	}
+	return fmt.Sprintf("%s\tseq %d\tpublished %s\t%s\t", p.Value, p.Sequence, published, expires)
+}
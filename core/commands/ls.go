@@ -32,6 +32,9 @@ type LsLink struct {
 type LsObject struct {
 	Hash  string
 	Links []LsLink
+	// Next is the cursor of the next links, to list with --from, when
+	// --limit links were listed and there are more.
+	Next string `json:",omitempty"`
 }
 
 type LsOutput struct {
@@ -48,6 +51,22 @@ the following format:
   <link base58 hash> <link size in bytes> <link name>
 
 The JSON output contains type information.
+
+The sizes come from the links of the listed objects. Links which have no
+size are resolved to find it out, unless --resolve-size=false is given. To
+find out their types, linked objects are fetched too, unless
+--resolve-type=false is given. With both options set to false, listing a
+directory only needs the blocks of the directory itself, which makes
+listing remote directories much faster.
+
+Large directories are listed page by page with --limit, the number of links
+listed at most, and --from, the cursor the previous page ended with. The
+links of sharded directories are listed in the order of the hashes of their
+names, which lets the listing resume without going through the links before
+the cursor.
+
+With --follow-symlinks, the unixfs symlinks of the paths are followed to
+their targets: a path to a symlink to a directory lists the directory.
 `,
 	},
 
@@ -57,6 +76,10 @@ The JSON output contains type information.
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("headers", "v", "Print table headers (Hash, Size, Name)."),
 		cmdkit.BoolOption("resolve-type", "Resolve linked objects to find out their types.").WithDefault(true),
+		cmdkit.BoolOption("resolve-size", "Resolve linked objects without a size in their link to find it out.").WithDefault(true),
+		cmdkit.BoolOption("follow-symlinks", "Follow the unixfs symlinks of the paths to their targets."),
+		cmdkit.StringOption("from", "List the links following this cursor, from the output of a previous listing."),
+		cmdkit.IntOption("limit", "Maximum number of links listed for each path."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		nd, err := req.InvocContext().GetNode()
@@ -77,6 +100,34 @@ The JSON output contains type information.
 			return
 		}
 
+		resolveSize, _, err := req.Option("resolve-size").Bool()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		followSymlinks, _, err := req.Option("follow-symlinks").Bool()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		from, _, err := req.Option("from").String()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		limit, _, err := req.Option("limit").Int()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if limit < 0 {
+			res.SetError(fmt.Errorf("cannot specify negative limit"), cmdkit.ErrClient)
+			return
+		}
+
 		dserv := nd.DAG
 		if !resolve {
 			offlineexch := offline.Exchange(nd.Blockstore)
@@ -98,6 +149,9 @@ The JSON output contains type information.
 				DAG:         nd.DAG,
 				ResolveOnce: uio.ResolveUnixfsOnce,
 			}
+			if followSymlinks {
+				r.Symlink = uio.SymlinkTarget
+			}
 
 			dagnode, err := core.Resolve(req.Context(), nd.Namesys, r, p)
 			if err != nil {
@@ -116,52 +170,86 @@ The JSON output contains type information.
 				return
 			}
 
+			// one more link is listed to tell whether there are more
+			probe := limit
+			if limit > 0 {
+				probe = limit + 1
+			}
 			var links []*ipld.Link
 			if dir == nil {
-				links = dagnode.Links()
+				links, err = uio.LinksAfter(dagnode.Links(), from, probe)
 			} else {
-				links, err = dir.Links(req.Context())
-				if err != nil {
-					res.SetError(err, cmdkit.ErrNormal)
-					return
-				}
+				links, err = dir.LinksAfter(req.Context(), from, probe)
+			}
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+
+			var next string
+			if limit > 0 && len(links) > limit {
+				links = links[:limit]
+				next = links[limit-1].Name
 			}
 
 			output[i] = LsObject{
 				Hash:  paths[i],
 				Links: make([]LsLink, len(links)),
+				Next:  next,
 			}
 
 			for j, link := range links {
 				t := unixfspb.Data_DataType(-1)
+				size := link.Size
+
+				// the linked node is fetched to find out its unixfs type,
+				// or its size when the link has none
+				needSize := resolveSize && size == 0
+				ds := dserv
+				if needSize {
+					ds = nd.DAG
+				}
 
-				switch link.Cid.Type() {
-				case cid.Raw:
+				var linkNode ipld.Node
+				switch {
+				case link.Cid.Type() == cid.Raw && !needSize:
 					// No need to check with raw leaves
 					t = unixfspb.Data_File
-				case cid.DagProtobuf:
-					linkNode, err := link.GetNode(req.Context(), dserv)
-					if err == ipld.ErrNotFound && !resolve {
+				case link.Cid.Type() == cid.DagProtobuf || needSize:
+					linkNode, err = link.GetNode(req.Context(), ds)
+					if err == ipld.ErrNotFound && !resolve && !needSize {
 						// not an error
 						linkNode = nil
 					} else if err != nil {
 						res.SetError(err, cmdkit.ErrNormal)
 						return
 					}
+				}
 
-					if pn, ok := linkNode.(*merkledag.ProtoNode); ok {
-						d, err := unixfs.FromBytes(pn.Data())
-						if err != nil {
-							res.SetError(err, cmdkit.ErrNormal)
-							return
-						}
-						t = d.GetType()
+				switch linkNode := linkNode.(type) {
+				case *merkledag.ProtoNode:
+					d, err := unixfs.FromBytes(linkNode.Data())
+					if err != nil {
+						res.SetError(err, cmdkit.ErrNormal)
+						return
 					}
+					t = d.GetType()
+				case *merkledag.RawNode:
+					t = unixfspb.Data_File
 				}
+
+				if needSize && linkNode != nil {
+					size, err = linkNode.Size()
+					if err != nil {
+						res.SetError(err, cmdkit.ErrNormal)
+						return
+					}
+				}
+
 				output[i].Links[j] = LsLink{
 					Name: link.Name,
 					Hash: link.Cid.String(),
-					Size: link.Size,
+					Size: size,
 					Type: t,
 				}
 			}
@@ -198,6 +286,9 @@ The JSON output contains type information.
 					}
 					fmt.Fprintf(w, "%s\t%v\t%s\n", link.Hash, link.Size, link.Name)
 				}
+				if object.Next != "" {
+					fmt.Fprintf(w, "(more links follow, list them with --from=%q)\n", object.Next)
+				}
 				if len(output.Objects) > 1 {
 					fmt.Fprintln(w)
 				}
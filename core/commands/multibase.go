@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+	mbase "gx/ipfs/QmexBtiTTEwwn42Yi6ouKt6VqzpA6wjJgiW1oh9VfaRrup/go-multibase"
+)
+
+const multibaseOptionName = "b"
+
+var MultibaseCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Encode and decode data with multibase.",
+		ShortDescription: `
+Multibase prefixes base encoded data with a character telling which base
+it is encoded in. 'ipfs multibase' converts data to and from multibase
+strings, e.g. to prepare the values given to 'ipfs dht put' or to read
+base encoded fields of records.
+
+The available bases are listed by 'ipfs cid bases --prefix'.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"encode":    mbaseEncodeCmd,
+		"decode":    mbaseDecodeCmd,
+		"transcode": mbaseTranscodeCmd,
+	},
+}
+
+var mbaseEncodeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Encode data into a multibase string.",
+		ShortDescription: `
+Reads data from a file or stdin and prints it encoded in the given base,
+with the multibase prefix.
+
+  $ echo -n hello | ipfs multibase encode -b base16
+  f68656c6c6f
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("file", true, false, "Data to encode.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(multibaseOptionName, "Multibase to encode the data in.").WithDefault("base64url"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		base, err := multibaseOption(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		data, err := readFileArg(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		encoded, err := mbase.Encode(base, data)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		cmds.EmitOnce(res, strings.NewReader(encoded+"\n"))
+	},
+}
+
+var mbaseDecodeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Decode a multibase string.",
+		ShortDescription: `
+Reads a multibase string from a file or stdin and prints the data it
+encodes. Leading and trailing whitespace is ignored.
+
+  $ echo f68656c6c6f | ipfs multibase decode
+  hello
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("encoded_file", true, false, "Multibase string to decode.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		data, err := readFileArg(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		_, decoded, err := mbase.Decode(strings.TrimSpace(string(data)))
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		cmds.EmitOnce(res, bytes.NewReader(decoded))
+	},
+}
+
+var mbaseTranscodeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Convert a multibase string to another base.",
+		ShortDescription: `
+Reads a multibase string from a file or stdin and prints the same data
+encoded in the given base.
+
+  $ echo f68656c6c6f | ipfs multibase transcode -b base32
+  bnbswy3dp
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("encoded_file", true, false, "Multibase string to convert.").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption(multibaseOptionName, "Multibase to convert the data to.").WithDefault("base64url"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		base, err := multibaseOption(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		data, err := readFileArg(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		_, decoded, err := mbase.Decode(strings.TrimSpace(string(data)))
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		encoded, err := mbase.Encode(base, decoded)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		cmds.EmitOnce(res, strings.NewReader(encoded+"\n"))
+	},
+}
+
+func multibaseOption(req *cmds.Request) (mbase.Encoding, error) {
+	name, _ := req.Options[multibaseOptionName].(string)
+	return multibaseByName(name)
+}
+
+// readFileArg reads the whole content of the file argument of req.
+func readFileArg(req *cmds.Request) ([]byte, error) {
+	file, err := req.Files.NextFile()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ioutil.ReadAll(file)
+}
@@ -9,6 +9,8 @@ import (
 type IpnsEntry struct {
 	Name  string
 	Value string
+	// Error is set when publishing under one of several keys failed.
+	Error string `json:",omitempty"`
 }
 
 var NameCmd = &cmds.Command{
@@ -64,5 +66,7 @@ Resolve the value of a dnslink:
 		"publish": PublishCmd,
 		"resolve": IpnsCmd,
 		"pubsub":  IpnsPubsubCmd,
+
+		"republish-status": ipnsRepublishStatusCmd,
 	},
 }
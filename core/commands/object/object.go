@@ -17,6 +17,7 @@ import (
 	lgc "github.com/ipfs/go-ipfs/commands/legacy"
 	core "github.com/ipfs/go-ipfs/core"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 	pin "github.com/ipfs/go-ipfs/pin"
@@ -204,12 +205,22 @@ This command outputs data in the following encodings:
   * "protobuf"
   * "json"
   * "xml"
-(Specified by the "--encoding" or "--enc" flag)`,
+(Specified by the "--encoding" or "--enc" flag)
+
+With --providers, the peers known to have the data are dialed first and
+the blocks are wanted from them, without looking the providers up in the
+DHT unless they don't have the blocks:
+
+  > ipfs object get --providers=<peer ID>,/ip4/1.2.3.4/tcp/4001/ipfs/<peer ID> <key>
+`,
 	},
 
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("key", true, false, "Key of the object to retrieve, in base58-encoded multihash format.").EnableStdin(),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("providers", "Comma-separated peer IDs or addresses of peers known to have the data, fetched from first."),
+	},
 	Run: func(req oldcmds.Request, res oldcmds.Response) {
 		n, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -219,7 +230,22 @@ This command outputs data in the following encodings:
 
 		fpath := path.Path(req.Arguments()[0])
 
-		object, err := core.Resolve(req.Context(), n.Namesys, n.Resolver, fpath)
+		ctx, r := req.Context(), n.Resolver
+		if hint, _, _ := req.Option("providers").String(); hint != "" {
+			pis, err := core.ParseProviders(hint)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrClient)
+				return
+			}
+			ctx, err = core.WithProviders(ctx, n, pis)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			r = core.SessionResolver(ctx, n)
+		}
+
+		object, err := core.Resolve(ctx, n.Namesys, r, fpath)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -436,12 +462,20 @@ And then run:
 		}
 
 		if dopin {
-			n.Pinning.PinWithMode(objectCid, pin.Recursive)
+			pinning := n.PinnerFor(req.Context())
+			pinning.PinWithMode(objectCid, pin.Recursive)
+			if err := pinning.Err(); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
 			err = n.Pinning.Flush()
 			if err != nil {
 				res.SetError(err, cmdkit.ErrNormal)
 				return
 			}
+			if err := corerepo.RecordPin(n, req.Context(), objectCid); err != nil {
+				log.Errorf("recording the pin of %s: %s", objectCid, err)
+			}
 		}
 
 		res.SetOutput(&Object{Hash: objectCid.String()})
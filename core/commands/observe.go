@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"time"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+)
+
+// CommandObserver is told when commands start and finish, e.g. to audit
+// them.
+type CommandObserver interface {
+	CommandStarted(req *cmds.Request)
+	CommandFinished(req *cmds.Request, duration time.Duration)
+}
+
+var observers []CommandObserver
+
+// AddCommandObserver registers an observer for every command. Observers must
+// be added before the commands run, usually by plugins.
+func AddCommandObserver(o CommandObserver) {
+	observers = append(observers, o)
+}
+
+// observeRun tells the observers when a command starts and finishes.
+func observeRun(run runFunc) runFunc {
+	return func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+		if len(observers) == 0 {
+			run(req, re, env)
+			return
+		}
+
+		for _, o := range observers {
+			o.CommandStarted(req)
+		}
+		start := time.Now()
+		defer func() {
+			duration := time.Since(start)
+			for _, o := range observers {
+				o.CommandFinished(req, duration)
+			}
+		}()
+
+		run(req, re, env)
+	}
+}
@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+)
+
+type testObserver struct {
+	events []string
+}
+
+func (o *testObserver) CommandStarted(req *cmds.Request) {
+	o.events = append(o.events, "started")
+}
+
+func (o *testObserver) CommandFinished(req *cmds.Request, duration time.Duration) {
+	o.events = append(o.events, "finished")
+}
+
+func TestObserveRun(t *testing.T) {
+	defer func() { observers = nil }()
+
+	o := new(testObserver)
+	AddCommandObserver(o)
+
+	run := observeRun(func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+		o.events = append(o.events, "run")
+	})
+	run(new(cmds.Request), nil, nil)
+
+	if len(o.events) != 3 || o.events[0] != "started" || o.events[1] != "run" || o.events[2] != "finished" {
+		t.Fatalf("unexpected events: %v", o.events)
+	}
+}
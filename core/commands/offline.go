@@ -0,0 +1,22 @@
+package commands
+
+import (
+	oldcmds "github.com/ipfs/go-ipfs/commands"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+)
+
+// allowOffline makes a command honor the global --offline option: when it
+// is set, the command runs against an offline view of the node, which never
+// fetches blocks from the network and has no network services. Commands
+// which need the network fail as they do on an offline node.
+func allowOffline(run runFunc) runFunc {
+	return func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+		if offline, _ := req.Options[OfflineOption].(bool); offline {
+			if cctx, ok := env.(*oldcmds.Context); ok {
+				env = cctx.Offline()
+			}
+		}
+		run(req, re, env)
+	}
+}
@@ -5,13 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
+	p2p "github.com/ipfs/go-ipfs/p2p"
 
 	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
 	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
 )
 
@@ -19,6 +24,12 @@ import (
 type P2PListenerInfoOutput struct {
 	Protocol string
 	Address  string
+
+	Allowed     []string          `json:",omitempty"`
+	MaxStreams  int               `json:",omitempty"`
+	IdleTimeout string            `json:",omitempty"`
+	SNIRoutes   map[string]string `json:",omitempty"`
+	Streams     int               `json:",omitempty"`
 }
 
 // P2PStreamInfoOutput is output type of streams command
@@ -29,6 +40,11 @@ type P2PStreamInfoOutput struct {
 	LocalAddress  string
 	RemotePeer    string
 	RemoteAddress string
+
+	Opened     time.Time
+	LastActive time.Time
+	BytesIn    int64
+	BytesOut   int64
 }
 
 // P2PLsOutput is output type of ls command
@@ -104,10 +120,7 @@ var p2pListenerLsCmd = &cmds.Command{
 		output := &P2PLsOutput{}
 
 		for _, listener := range n.P2P.Listeners.Listeners {
-			output.Listeners = append(output.Listeners, P2PListenerInfoOutput{
-				Protocol: listener.Protocol,
-				Address:  listener.Address.String(),
-			})
+			output.Listeners = append(output.Listeners, listenerInfoOutput(listener))
 		}
 
 		res.SetOutput(output)
@@ -144,6 +157,7 @@ var p2pStreamLsCmd = &cmds.Command{
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("headers", "v", "Print table headers (HagndlerID, Protocol, Local, Remote)."),
+		cmdkit.BoolOption("stats", "s", "Also print the bytes received and sent by each stream, and for how long it's idle."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := getNode(req)
@@ -154,7 +168,7 @@ var p2pStreamLsCmd = &cmds.Command{
 
 		output := &P2PStreamsOutput{}
 
-		for _, s := range n.P2P.Streams.Streams {
+		for _, s := range n.P2P.Streams.List() {
 			output.Streams = append(output.Streams, P2PStreamInfoOutput{
 				HandlerID: strconv.FormatUint(s.HandlerID, 10),
 
@@ -165,6 +179,11 @@ var p2pStreamLsCmd = &cmds.Command{
 
 				RemotePeer:    s.RemotePeer.Pretty(),
 				RemoteAddress: s.RemoteAddr.String(),
+
+				Opened:     s.Opened,
+				LastActive: s.LastActive(),
+				BytesIn:    s.BytesIn(),
+				BytesOut:   s.BytesOut(),
 			})
 		}
 
@@ -179,15 +198,25 @@ var p2pStreamLsCmd = &cmds.Command{
 			}
 
 			headers, _, _ := res.Request().Option("headers").Bool()
+			stats, _, _ := res.Request().Option("stats").Bool()
 			list := v.(*P2PStreamsOutput)
 			buf := new(bytes.Buffer)
 			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
 			for _, stream := range list.Streams {
 				if headers {
-					fmt.Fprintln(w, "HandlerID\tProtocol\tLocal\tRemote")
+					if stats {
+						fmt.Fprintln(w, "HandlerID\tProtocol\tLocal\tRemote\tIn\tOut\tIdle")
+					} else {
+						fmt.Fprintln(w, "HandlerID\tProtocol\tLocal\tRemote")
+					}
 				}
 
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", stream.HandlerID, stream.Protocol, stream.LocalAddress, stream.RemotePeer)
+				if stats {
+					idle := time.Since(stream.LastActive).Round(time.Second)
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", stream.HandlerID, stream.Protocol, stream.LocalAddress, stream.RemotePeer, stream.BytesIn, stream.BytesOut, idle)
+				} else {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", stream.HandlerID, stream.Protocol, stream.LocalAddress, stream.RemotePeer)
+				}
 			}
 			w.Flush()
 
@@ -204,12 +233,36 @@ Register a p2p connection handler and forward the connections to a specified
 address.
 
 Note that the connections originate from the ipfs daemon process.
+
+Use '--allow' to only accept the connections of some peers, '--max-streams'
+to limit the number of connections open at a time, and '--idle-timeout' to
+close the connections which carry no data for a while:
+
+  > ipfs p2p listener open --allow=QmPeerA,QmPeerB --max-streams=10 \
+      --idle-timeout=5m ssh /ip4/127.0.0.1/tcp/22
+
+The address can be a unix socket, like /unix/run/app.sock.
+
+With '--sni', the TLS connections are forwarded by the server name of their
+client hello. TLS isn't terminated: the services get the connections as the
+clients opened them, with their server name. The connections to other names,
+and the ones which aren't TLS, go to the address of the listener:
+
+  > ipfs p2p listener open --sni=a.example.com=/ip4/127.0.0.1/tcp/8443 \
+      https /ip4/127.0.0.1/tcp/443
 		`,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("Protocol", true, false, "Protocol identifier."),
 		cmdkit.StringArg("Address", true, false, "Request handling application address."),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("allow", "Comma-separated peer IDs of the only peers allowed to connect."),
+		cmdkit.IntOption("max-streams", "Maximum number of connections open at a time. Default: unlimited."),
+		cmdkit.StringOption("idle-timeout", "Close the connections carrying no data for this long, like '5m'."),
+		cmdkit.StringOption("sni", "Comma-separated TLS server names and the addresses to forward their connections to, like 'a.example.com=/ip4/127.0.0.1/tcp/8443'."),
+	},
+	Type: P2PListenerInfoOutput{},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := getNode(req)
 		if err != nil {
@@ -229,17 +282,21 @@ Note that the connections originate from the ipfs daemon process.
 			return
 		}
 
-		_, err = n.P2P.NewListener(n.Context(), proto, addr)
+		opts, err := parseListenerOptions(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		listener, err := n.P2P.NewListener(n.Context(), proto, addr, opts)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
 
 		// Successful response.
-		res.SetOutput(&P2PListenerInfoOutput{
-			Protocol: proto,
-			Address:  addr.String(),
-		})
+		output := listenerInfoOutput(listener)
+		res.SetOutput(&output)
 	},
 }
 
@@ -252,7 +309,8 @@ Establish a new connection to a peer service.
 
 When a connection is made to a peer service the ipfs daemon will setup one
 time TCP listener and return it's bind port, this way a dialing application
-can transparently connect to a p2p service.
+can transparently connect to a p2p service. The bind address can also be a
+unix socket, like /unix/tmp/app.sock.
 		`,
 	},
 	Arguments: []cmdkit.Argument{
@@ -260,6 +318,7 @@ can transparently connect to a p2p service.
 		cmdkit.StringArg("Protocol", true, false, "Protocol identifier."),
 		cmdkit.StringArg("BindAddress", false, false, "Address to listen for connection/s (default: /ip4/127.0.0.1/tcp/0)."),
 	},
+	Type: P2PListenerInfoOutput{},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := getNode(req)
 		if err != nil {
@@ -377,7 +436,7 @@ var p2pStreamCloseCmd = &cmds.Command{
 			}
 		}
 
-		for _, stream := range n.P2P.Streams.Streams {
+		for _, stream := range n.P2P.Streams.List() {
 			if !closeAll && handlerID != stream.HandlerID {
 				continue
 			}
@@ -389,6 +448,82 @@ var p2pStreamCloseCmd = &cmds.Command{
 	},
 }
 
+func parseListenerOptions(req cmds.Request) (p2p.ListenerOptions, error) {
+	var opts p2p.ListenerOptions
+
+	allow, _, _ := req.Option("allow").String()
+	for _, s := range strings.Split(allow, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		id, err := peer.IDB58Decode(s)
+		if err != nil {
+			return opts, fmt.Errorf("invalid peer ID %q: %s", s, err)
+		}
+		opts.Allowed = append(opts.Allowed, id)
+	}
+
+	max, _, _ := req.Option("max-streams").Int()
+	if max < 0 {
+		return opts, errors.New("max-streams must not be negative")
+	}
+	opts.MaxStreams = max
+
+	timeout, _, _ := req.Option("idle-timeout").String()
+	if timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			return opts, fmt.Errorf("invalid idle timeout %q", timeout)
+		}
+		opts.IdleTimeout = d
+	}
+
+	sni, _, _ := req.Option("sni").String()
+	for _, s := range strings.Split(sni, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return opts, fmt.Errorf("invalid server name route %q, expected name=address", s)
+		}
+		addr, err := ma.NewMultiaddr(parts[1])
+		if err != nil {
+			return opts, fmt.Errorf("invalid address of %s: %s", parts[0], err)
+		}
+		if opts.SNIRoutes == nil {
+			opts.SNIRoutes = make(map[string]ma.Multiaddr)
+		}
+		opts.SNIRoutes[strings.ToLower(parts[0])] = addr
+	}
+	return opts, nil
+}
+
+func listenerInfoOutput(l *p2p.ListenerInfo) P2PListenerInfoOutput {
+	out := P2PListenerInfoOutput{
+		Protocol:   l.Protocol,
+		Address:    l.Address.String(),
+		MaxStreams: l.MaxStreams,
+		Streams:    l.Streams(),
+	}
+	for p := range l.Allowed {
+		out.Allowed = append(out.Allowed, p.Pretty())
+	}
+	sort.Strings(out.Allowed)
+	if l.IdleTimeout > 0 {
+		out.IdleTimeout = l.IdleTimeout.String()
+	}
+	for name, addr := range l.SNIRoutes {
+		if out.SNIRoutes == nil {
+			out.SNIRoutes = make(map[string]string)
+		}
+		out.SNIRoutes[name] = addr.String()
+	}
+	return out
+}
+
 func getNode(req cmds.Request) (*core.IpfsNode, error) {
 	n, err := req.InvocContext().GetNode()
 	if err != nil {
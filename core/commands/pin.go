@@ -3,8 +3,11 @@ package commands
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	gopath "path"
+	"strings"
 	"time"
 
 	bserv "github.com/ipfs/go-ipfs/blockservice"
@@ -12,15 +15,20 @@ import (
 	core "github.com/ipfs/go-ipfs/core"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 	resolver "github.com/ipfs/go-ipfs/path/resolver"
 	pin "github.com/ipfs/go-ipfs/pin"
+	replication "github.com/ipfs/go-ipfs/replication"
+	pininfo "github.com/ipfs/go-ipfs/repo/pininfo"
 	"github.com/ipfs/go-ipfs/thirdparty/verifcid"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	u "gx/ipfs/QmPdKqUcHGFdeSpvjVoaTRPPstGif9GBZb5Q56RVw9o69A/go-ipfs-util"
 	offline "gx/ipfs/QmPf114DXfa6TqGKYhBGR7EtXRho4rCJgwyA1xkuMY5vwF/go-ipfs-exchange-offline"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
 	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
 	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
 )
@@ -31,11 +39,12 @@ var PinCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"add":    addPinCmd,
-		"rm":     rmPinCmd,
-		"ls":     listPinCmd,
-		"verify": verifyPinCmd,
-		"update": updatePinCmd,
+		"add":       addPinCmd,
+		"rm":        rmPinCmd,
+		"ls":        listPinCmd,
+		"verify":    verifyPinCmd,
+		"update":    updatePinCmd,
+		"inventory": inventoryPinCmd,
 	},
 }
 
@@ -45,13 +54,59 @@ type PinOutput struct {
 
 type AddPinOutput struct {
 	Pins     []string
-	Progress int `json:",omitempty"`
+	Progress int                 `json:",omitempty"`
+	Fetched  *corerepo.FetchStat `json:",omitempty"`
+	// Replicas are the outcomes of asking other nodes to pin too, with
+	// --replicate.
+	Replicas []replication.PinReplica `json:",omitempty"`
+}
+
+// newAddPinOutput returns the output of 'ipfs pin add', which reports what was
+// fetched only when something was.
+func newAddPinOutput(added []*cid.Cid, stat *corerepo.FetchStat) *AddPinOutput {
+	out := &AddPinOutput{Pins: cidsToStrings(added)}
+	if stat != nil && stat.Blocks > 0 {
+		out.Fetched = stat
+	}
+	return out
 }
 
 var addPinCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline:          "Pin objects to local storage.",
 		ShortDescription: "Stores an IPFS object(s) from a given path locally to disk.",
+		LongDescription: `
+Stores an IPFS object(s) from a given path locally to disk.
+
+The blocks missing locally are all fetched through a single bitswap session,
+so that the providers found for one object are asked for the others. When
+some were fetched, the output ends with how many, their size and how long
+pinning took.
+
+With --fetch-priority=background, the blocks are fetched after the ones of
+interactive requests, like the ones of the gateway or of 'ipfs cat', and the
+connections to their providers are the first closed when there are too
+many. This suits pins no one waits on.
+
+--concurrency sets how many blocks are fetched at a time, overriding
+Traversal.Concurrency in the config. Raise it on fast networks, lower it on
+constrained devices.
+
+--replicate=<n> keeps n copies of the pinned objects, this node's included,
+asking the nodes in Replication.PinReplicas in the config to pin them too,
+in order, until n-1 did. They fetch the objects from this node, and only
+accept if this node is in their Replication.AllowPin. The outcome is
+reported for each node asked:
+
+  > ipfs pin add --replicate=3 QmRoot...
+  pinned QmRoot... recursively
+  replicated QmRoot... to QmPeerA...
+  failed to replicate QmRoot... to QmPeerB...: not allowed to ask this node to pin
+  replicated QmRoot... to QmPeerC...
+
+--name names the pins created, for 'ipfs pin ls --long' and 'ipfs pin ls
+--name'. Pinning again what is pinned already doesn't rename it.
+`,
 	},
 
 	Arguments: []cmdkit.Argument{
@@ -60,6 +115,10 @@ var addPinCmd = &cmds.Command{
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("recursive", "r", "Recursively pin the object linked to by the specified object(s).").WithDefault(true),
 		cmdkit.BoolOption("progress", "Show progress"),
+		cmdkit.StringOption("fetch-priority", "Priority of the fetches of the blocks: interactive or background. Default: interactive."),
+		cmdkit.IntOption("concurrency", "Number of blocks fetched at a time. Default: Traversal.Concurrency, or 8."),
+		cmdkit.IntOption("replicate", "Number of copies to keep, asking the nodes in Replication.PinReplicas to pin too. Default: 1."),
+		cmdkit.StringOption("name", "Name of the pins created."),
 	},
 	Type: AddPinOutput{},
 	Run: func(req cmds.Request, res cmds.Response) {
@@ -69,7 +128,10 @@ var addPinCmd = &cmds.Command{
 			return
 		}
 
-		defer n.Blockstore.PinLock().Unlock()
+		if err := n.CheckDiskSpace(); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
 
 		// set recursive flag
 		recursive, _, err := req.Option("recursive").Bool()
@@ -77,31 +139,75 @@ var addPinCmd = &cmds.Command{
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
+		replicas, err := pinReplicas(req, n, recursive)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
 		showProgress, _, _ := req.Option("progress").Bool()
 
+		ctx, err := n.TraversalContext(req.Context())
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		priority, _, _ := req.Option("fetch-priority").String()
+		ctx, err = withFetchPriority(ctx, priority)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+
+		concurrency, found, err := req.Option("concurrency").Int()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+		if found {
+			if concurrency <= 0 {
+				res.SetError(fmt.Errorf("concurrency must be positive"), cmdkit.ErrClient)
+				return
+			}
+			ctx = dag.WithConcurrency(ctx, concurrency)
+		}
+		if name, _, _ := req.Option("name").String(); name != "" {
+			ctx = pininfo.WithName(ctx, name)
+		}
+
+		pinArgs := func() ([]*cid.Cid, *corerepo.FetchStat, error) {
+			// the pins are replicated once the lock is released, not to hold
+			// GC while the other nodes fetch them
+			defer n.Blockstore.PinLock().Unlock()
+			return corerepo.PinWithStat(n, ctx, req.Arguments(), recursive)
+		}
+
 		if !showProgress {
-			added, err := corerepo.Pin(n, req.Context(), req.Arguments(), recursive)
+			added, stat, err := pinArgs()
 			if err != nil {
 				res.SetError(err, cmdkit.ErrNormal)
 				return
 			}
-			res.SetOutput(&AddPinOutput{Pins: cidsToStrings(added)})
+			out := newAddPinOutput(added, stat)
+			out.Replicas = replicatePins(req.Context(), n, replicas, added)
+			res.SetOutput(out)
 			return
 		}
 
 		out := make(chan interface{})
 		res.SetOutput((<-chan interface{})(out))
 		v := new(dag.ProgressTracker)
-		ctx := v.DeriveContext(req.Context())
+		ctx = v.DeriveContext(ctx)
 
 		type pinResult struct {
 			pins []*cid.Cid
+			stat *corerepo.FetchStat
 			err  error
 		}
 		ch := make(chan pinResult, 1)
 		go func() {
-			added, err := corerepo.Pin(n, ctx, req.Arguments(), recursive)
-			ch <- pinResult{pins: added, err: err}
+			added, stat, err := pinArgs()
+			ch <- pinResult{pins: added, stat: stat, err: err}
 		}()
 
 		ticker := time.NewTicker(500 * time.Millisecond)
@@ -118,7 +224,9 @@ var addPinCmd = &cmds.Command{
 				if pv := v.Value(); pv != 0 {
 					out <- &AddPinOutput{Progress: v.Value()}
 				}
-				out <- &AddPinOutput{Pins: cidsToStrings(val.pins)}
+				added := newAddPinOutput(val.pins, val.stat)
+				added.Replicas = replicatePins(req.Context(), n, replicas, val.pins)
+				out <- added
 				return
 			case <-ticker.C:
 				out <- &AddPinOutput{Progress: v.Value()}
@@ -137,11 +245,15 @@ var addPinCmd = &cmds.Command{
 			}
 
 			var added []string
+			var fetched *corerepo.FetchStat
+			var replicas []replication.PinReplica
 
 			switch out := v.(type) {
 			case *AddPinOutput:
 				if out.Pins != nil {
 					added = out.Pins
+					fetched = out.Fetched
+					replicas = out.Replicas
 				} else {
 					// this can only happen if the progress option is set
 					fmt.Fprintf(res.Stderr(), "Fetched/Processed %d nodes\r", out.Progress)
@@ -166,11 +278,90 @@ var addPinCmd = &cmds.Command{
 			for _, k := range added {
 				fmt.Fprintf(buf, "pinned %s %s\n", k, pintype)
 			}
+			if fetched != nil {
+				fmt.Fprintf(buf, "fetched %d blocks (%s) in %s\n", fetched.Blocks, humanize.Bytes(fetched.Bytes), fetched.Duration)
+			}
+			for _, r := range replicas {
+				if r.Error != "" {
+					fmt.Fprintf(buf, "failed to replicate %s to %s: %s\n", r.Cid, r.Peer, r.Error)
+				} else {
+					fmt.Fprintf(buf, "replicated %s to %s\n", r.Cid, r.Peer)
+				}
+			}
 			return buf, nil
 		},
 	},
 }
 
+// withFetchPriority returns a context making the fetches of the blocks of the
+// given priority, as named by the --fetch-priority options.
+func withFetchPriority(ctx context.Context, priority string) (context.Context, error) {
+	switch priority {
+	case "", "interactive":
+		return ctx, nil
+	case "background":
+		return bitswap.WithFetchPriority(ctx, bitswap.BackgroundFetch), nil
+	default:
+		return nil, fmt.Errorf("unknown fetch priority %q, expected interactive or background", priority)
+	}
+}
+
+// pinReplicas returns the nodes to ask to pin the objects pinned by 'ipfs pin
+// add', the ones of Replication.PinReplicas, and how many of them have to,
+// given --replicate. It returns nil when only this node keeps a copy.
+func pinReplicas(req cmds.Request, n *core.IpfsNode, recursive bool) (*pinReplication, error) {
+	copies, found, err := req.Option("replicate").Int()
+	if err != nil {
+		return nil, err
+	}
+	if !found || copies == 1 {
+		return nil, nil
+	}
+	if copies < 1 {
+		return nil, fmt.Errorf("the number of copies must be positive")
+	}
+	if !recursive {
+		return nil, errors.New("only recursive pins can be replicated")
+	}
+	if !n.OnlineMode() {
+		return nil, errNotOnline
+	}
+
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	peers := make([]peer.ID, 0, len(cfg.Replication.PinReplicas))
+	for _, s := range cfg.Replication.PinReplicas {
+		id, err := peer.IDB58Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer ID %q in Replication.PinReplicas: %s", s, err)
+		}
+		peers = append(peers, id)
+	}
+	if len(peers) < copies-1 {
+		return nil, fmt.Errorf("%d copies asked, but only %d nodes are set in Replication.PinReplicas", copies, len(peers))
+	}
+	return &pinReplication{peers: peers, n: copies - 1}, nil
+}
+
+type pinReplication struct {
+	peers []peer.ID
+	n     int
+}
+
+// replicatePins asks the nodes of r to pin the DAGs of pins, if any.
+func replicatePins(ctx context.Context, n *core.IpfsNode, r *pinReplication, pins []*cid.Cid) []replication.PinReplica {
+	if r == nil {
+		return nil
+	}
+	var out []replication.PinReplica
+	for _, c := range pins {
+		out = append(out, replication.ReplicatePin(ctx, n.PeerHost, r.peers, c, r.n)...)
+	}
+	return out
+}
+
 var rmPinCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Remove pinned objects from local storage.",
@@ -271,6 +462,27 @@ Example:
 	QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN direct
 	$ ipfs pin ls QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN
 	QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN direct
+
+With --long, the pins are listed with when they were created, and through
+which interface: "cli" for the ipfs command, "api" for the other clients of the
+HTTP API, followed by the name of the API token and the name of the pin if
+any:
+
+	$ ipfs pin ls --long --type=recursive
+	QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN recursive 2018-06-21T12:00:00Z api token=tenant-a name=site
+
+Pins created by older versions of go-ipfs have no record, listed as "-".
+
+Without arguments, the pins can also be filtered by the node, with
+--cid-prefix for the CIDs starting with a string, --codec for the CIDs of a
+codec, like "dag-pb" or "raw", --name for the pins whose name matches a glob
+pattern, and --created-after for the pins created after a time, as RFC 3339:
+
+	$ ipfs pin ls --type=recursive --codec=raw --cid-prefix=zb2rh
+	$ ipfs pin ls --name='site-*' --created-after=2018-06-01T00:00:00Z
+
+Filtering by name or creation time only lists the direct and recursive pins
+which have a record.
 `,
 	},
 
@@ -280,6 +492,11 @@ Example:
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("type", "t", "The type of pinned keys to list. Can be \"direct\", \"indirect\", \"recursive\", or \"all\".").WithDefault("all"),
 		cmdkit.BoolOption("quiet", "q", "Write just hashes of objects."),
+		cmdkit.StringOption("cid-prefix", "Only list the pins whose CID starts with this string."),
+		cmdkit.StringOption("codec", "Only list the pins whose CID has this codec, like \"dag-pb\" or \"raw\"."),
+		cmdkit.StringOption("name", "Only list the pins whose name matches this glob pattern."),
+		cmdkit.StringOption("created-after", "Only list the pins created after this RFC 3339 time."),
+		cmdkit.BoolOption("long", "l", "Also write when and through which interface the pins were created."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -288,6 +505,16 @@ Example:
 			return
 		}
 
+		filter, err := parsePinFilter(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+		if filter != nil && len(req.Arguments()) > 0 {
+			res.SetError(errors.New("pins can only be filtered when listing all of them"), cmdkit.ErrClient)
+			return
+		}
+
 		typeStr, _, err := req.Option("type").String()
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
@@ -307,14 +534,22 @@ Example:
 		if len(req.Arguments()) > 0 {
 			keys, err = pinLsKeys(req.Context(), req.Arguments(), typeStr, n)
 		} else {
-			keys, err = pinLsAll(req.Context(), typeStr, n)
+			keys, err = pinLsAll(req.Context(), typeStr, filter, n)
 		}
 
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
-		} else {
-			res.SetOutput(&RefKeyList{Keys: keys})
+			return
 		}
+
+		long, _, _ := req.Option("long").Bool()
+		if long {
+			if err := pinLsInfo(n, keys); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+		res.SetOutput(&RefKeyList{Keys: keys})
 	},
 	Type: RefKeyList{},
 	Marshalers: cmds.MarshalerMap{
@@ -333,11 +568,15 @@ Example:
 			if !ok {
 				return nil, e.TypeErr(keys, v)
 			}
+			long, _, _ := res.Request().Option("long").Bool()
 			out := new(bytes.Buffer)
 			for k, v := range keys.Keys {
-				if quiet {
+				switch {
+				case quiet:
 					fmt.Fprintf(out, "%s\n", k)
-				} else {
+				case long:
+					fmt.Fprintf(out, "%s %s %s\n", k, v.Type, formatPinInfo(v))
+				default:
 					fmt.Fprintf(out, "%s %s\n", k, v.Type)
 				}
 			}
@@ -411,6 +650,12 @@ new pin and removing the old one.
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
+		if err := corerepo.RecordPin(n, req.Context(), toc); err != nil {
+			log.Errorf("recording the pin of %s: %s", toc, err)
+		}
+		if unpin {
+			corerepo.ForgetPin(n, fromc)
+		}
 
 		res.SetOutput(&PinOutput{Pins: []string{from.String(), to.String()}})
 	},
@@ -486,6 +731,58 @@ var verifyPinCmd = &cmds.Command{
 
 type RefKeyObject struct {
 	Type string
+	// Created, Origin, Token and Name are the record of the creation of the pin,
+	// listed with --long. They are empty for indirect pins and the pins
+	// created before pins were recorded.
+	Created *time.Time `json:",omitempty"`
+	Origin  string     `json:",omitempty"`
+	Token   string     `json:",omitempty"`
+	Name    string     `json:",omitempty"`
+}
+
+// pinLsInfo fills in the records of the creation of the pins of keys.
+func pinLsInfo(n *core.IpfsNode, keys map[string]RefKeyObject) error {
+	if n.PinInfo == nil {
+		return nil
+	}
+	for k, v := range keys {
+		if v.Type != "direct" && v.Type != "recursive" {
+			continue
+		}
+		c, err := cid.Decode(k)
+		if err != nil {
+			return err
+		}
+		info, err := n.PinInfo.Get(c)
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			continue
+		}
+		v.Created = &info.Created
+		v.Origin = info.Origin
+		v.Token = info.Token
+		v.Name = info.Name
+		keys[k] = v
+	}
+	return nil
+}
+
+// formatPinInfo formats the record of the creation of a pin, like
+// "2018-06-21T12:00:00Z api token=tenant-a name=site", or "-" without one.
+func formatPinInfo(v RefKeyObject) string {
+	if v.Created == nil {
+		return "-"
+	}
+	s := v.Created.Format(time.RFC3339) + " " + v.Origin
+	if v.Token != "" {
+		s += " token=" + v.Token
+	}
+	if v.Name != "" {
+		s += " name=" + v.Name
+	}
+	return s
 }
 
 type RefKeyList struct {
@@ -539,22 +836,129 @@ func pinLsKeys(ctx context.Context, args []string, typeStr string, n *core.IpfsN
 	return keys, nil
 }
 
-func pinLsAll(ctx context.Context, typeStr string, n *core.IpfsNode) (map[string]RefKeyObject, error) {
+// pinFilter selects the pins listed by 'ipfs pin ls'.
+type pinFilter struct {
+	prefix string
+	codec  uint64
+	// hasCodec is set when filtering by codec
+	hasCodec bool
+
+	// name is the glob pattern of the names of the pins, after the time
+	// they were created after, both matched against their records
+	name  string
+	after time.Time
+}
+
+// parsePinFilter returns the filter given with the options of req, or nil
+// when there is none.
+func parsePinFilter(req cmds.Request) (*pinFilter, error) {
+	prefix, _, err := req.Option("cid-prefix").String()
+	if err != nil {
+		return nil, err
+	}
+	codec, _, err := req.Option("codec").String()
+	if err != nil {
+		return nil, err
+	}
+	name, _, err := req.Option("name").String()
+	if err != nil {
+		return nil, err
+	}
+	after, _, err := req.Option("created-after").String()
+	if err != nil {
+		return nil, err
+	}
+	if prefix == "" && codec == "" && name == "" && after == "" {
+		return nil, nil
+	}
+
+	f := &pinFilter{prefix: prefix, name: name}
+	if codec != "" {
+		f.codec, f.hasCodec = cid.Codecs[codec]
+		if !f.hasCodec {
+			return nil, fmt.Errorf("unknown codec '%s'", codec)
+		}
+	}
+	if _, err := gopath.Match(name, ""); err != nil {
+		return nil, fmt.Errorf("invalid name pattern '%s': %s", name, err)
+	}
+	if after != "" {
+		f.after, err = time.Parse(time.RFC3339, after)
+		if err != nil {
+			return nil, fmt.Errorf("invalid creation time '%s': %s", after, err)
+		}
+	}
+	return f, nil
+}
+
+func (f *pinFilter) match(c *cid.Cid) bool {
+	if f == nil {
+		return true
+	}
+	if f.hasCodec && c.Type() != f.codec {
+		return false
+	}
+	return strings.HasPrefix(c.String(), f.prefix)
+}
+
+// byRecord returns whether f matches the records of the pins.
+func (f *pinFilter) byRecord() bool {
+	return f != nil && (f.name != "" || !f.after.IsZero())
+}
+
+// matchRecord returns whether the pin of record info, nil if it has none,
+// matches f.
+func (f *pinFilter) matchRecord(info *pininfo.Info) bool {
+	if !f.byRecord() {
+		return true
+	}
+	if info == nil {
+		return false
+	}
+	if f.name != "" {
+		if ok, _ := gopath.Match(f.name, info.Name); !ok {
+			return false
+		}
+	}
+	return f.after.IsZero() || info.Created.After(f.after)
+}
+
+func pinLsAll(ctx context.Context, typeStr string, filter *pinFilter, n *core.IpfsNode) (map[string]RefKeyObject, error) {
 
 	keys := make(map[string]RefKeyObject)
 
-	AddToResultKeys := func(keyList []*cid.Cid, typeStr string) {
+	AddToResultKeys := func(keyList []*cid.Cid, typeStr string) error {
 		for _, c := range keyList {
+			if !filter.match(c) {
+				continue
+			}
+			if filter.byRecord() {
+				var info *pininfo.Info
+				if n.PinInfo != nil {
+					var err error
+					info, err = n.PinInfo.Get(c)
+					if err != nil {
+						return err
+					}
+				}
+				if !filter.matchRecord(info) {
+					continue
+				}
+			}
 			keys[c.String()] = RefKeyObject{
 				Type: typeStr,
 			}
 		}
+		return nil
 	}
 
 	if typeStr == "direct" || typeStr == "all" {
-		AddToResultKeys(n.Pinning.DirectKeys(), "direct")
+		if err := AddToResultKeys(n.Pinning.DirectKeys(), "direct"); err != nil {
+			return nil, err
+		}
 	}
-	if typeStr == "indirect" || typeStr == "all" {
+	// the indirect pins have no records to match
+	if (typeStr == "indirect" || typeStr == "all") && !filter.byRecord() {
 		set := cid.NewSet()
 		for _, k := range n.Pinning.RecursiveKeys() {
 			err := dag.EnumerateChildren(ctx, dag.GetLinksWithDAG(n.DAG), k, set.Visit)
@@ -562,10 +966,14 @@ func pinLsAll(ctx context.Context, typeStr string, n *core.IpfsNode) (map[string
 				return nil, err
 			}
 		}
-		AddToResultKeys(set.Keys(), "indirect")
+		if err := AddToResultKeys(set.Keys(), "indirect"); err != nil {
+			return nil, err
+		}
 	}
 	if typeStr == "recursive" || typeStr == "all" {
-		AddToResultKeys(n.Pinning.RecursiveKeys(), "recursive")
+		if err := AddToResultKeys(n.Pinning.RecursiveKeys(), "recursive"); err != nil {
+			return nil, err
+		}
 	}
 
 	return keys, nil
@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	replication "github.com/ipfs/go-ipfs/replication"
+
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+// PinInventoryRoot is a root pinned by another node.
+type PinInventoryRoot struct {
+	Cid  string
+	Type string
+	Peer string
+}
+
+// PinInventoryOutput is the output type of 'pin inventory'.
+type PinInventoryOutput struct {
+	Roots []PinInventoryRoot
+}
+
+var inventoryPinCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the roots pinned by trusted nodes.",
+		ShortDescription: `
+'ipfs pin inventory' asks the nodes with the given peer IDs for the roots
+they pin, recursively or directly:
+
+  > ipfs pin inventory QmPeer...
+  QmRoot... recursive QmPeer...
+
+A node only tells the nodes in its 'Replication.Inventory' config, and
+refuses the others. With --missing, only the roots not pinned by this node
+are listed, which gives a follower what it has to replicate:
+
+  > ipfs pin inventory --missing -q QmPeer... | ipfs pin add
+
+Both nodes must be online.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("peer", true, true, "Peer ID of the node to ask."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("missing", "Only list the roots not pinned by this node."),
+		cmdkit.BoolOption("quiet", "q", "Write just hashes of the roots."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		ids := make([]peer.ID, 0, len(req.Arguments()))
+		for _, arg := range req.Arguments() {
+			id, err := peer.IDB58Decode(arg)
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid peer ID %q: %s", arg, err), cmdkit.ErrClient)
+				return
+			}
+			ids = append(ids, id)
+		}
+		missing, _, _ := req.Option("missing").Bool()
+
+		out := &PinInventoryOutput{Roots: []PinInventoryRoot{}}
+		for _, id := range ids {
+			roots, err := replication.FetchInventory(req.Context(), n.PeerHost, id)
+			if err != nil {
+				res.SetError(fmt.Errorf("asking %s for its pinned roots: %s", id.Pretty(), err), cmdkit.ErrNormal)
+				return
+			}
+			for _, r := range roots {
+				if missing {
+					_, pinned, err := n.Pinning.IsPinned(r.Cid)
+					if err != nil {
+						res.SetError(err, cmdkit.ErrNormal)
+						return
+					}
+					if pinned {
+						continue
+					}
+				}
+				out.Roots = append(out.Roots, PinInventoryRoot{Cid: r.Cid.String(), Type: r.Type, Peer: id.Pretty()})
+			}
+		}
+		res.SetOutput(out)
+	},
+	Type: PinInventoryOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			inv, ok := v.(*PinInventoryOutput)
+			if !ok {
+				return nil, e.TypeErr(inv, v)
+			}
+			quiet, _, _ := res.Request().Option("quiet").Bool()
+
+			buf := new(bytes.Buffer)
+			for _, r := range inv.Roots {
+				if quiet {
+					fmt.Fprintln(buf, r.Cid)
+				} else {
+					fmt.Fprintf(buf, "%s %s %s\n", r.Cid, r.Type, r.Peer)
+				}
+			}
+			return buf, nil
+		},
+	},
+}
@@ -3,9 +3,11 @@ package commands
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"strings"
 	"time"
 
@@ -13,18 +15,38 @@ import (
 	core "github.com/ipfs/go-ipfs/core"
 
 	u "gx/ipfs/QmPdKqUcHGFdeSpvjVoaTRPPstGif9GBZb5Q56RVw9o69A/go-ipfs-util"
+	msmux "gx/ipfs/QmTnsezaB1wWNRHeHnYrm8K4d5i9wtyj3GsqjC3Rt5b5v5/go-multistream"
+	ping "gx/ipfs/QmUEAR2pS7fP1GPseS3i8MWFyENs7oDp4CZrgn8FCjbsBu/go-libp2p/p2p/protocol/ping"
 	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
 	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	inet "gx/ipfs/QmXdgNhVEgjLxjUoMs5ViQL7pboAt3Y7V7eGHRiE4qrmTE/go-libp2p-net"
 	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
 	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
 )
 
 const kPingTimeout = 10 * time.Second
 
+// maxPingSize limits the size of ping packets, which are sent whole before
+// the echo is read.
+const maxPingSize = 64 * 1024
+
 type PingResult struct {
 	Success bool
 	Time    time.Duration
 	Text    string
+	Stats   *PingStats `json:",omitempty"`
+}
+
+// PingStats summarizes the pings sent to a peer. It is sent with the last
+// result.
+type PingStats struct {
+	Sent     int
+	Received int
+	Loss     float64 // percentage of the pings which failed
+	Min      time.Duration
+	Avg      time.Duration
+	Max      time.Duration
+	StdDev   time.Duration
 }
 
 // ErrPingSelf is returned when the user attempts to ping themself.
@@ -38,12 +60,29 @@ var PingCmd = &cmds.Command{
 via the routing system, sends pings, waits for pongs, and prints out round-
 trip latency information.
 		`,
+		LongDescription: `
+'ipfs ping' is a tool to test sending data to other nodes. It finds nodes
+via the routing system, sends pings, waits for pongs, and prints out round-
+trip latency information, followed by statistics of the round trip times
+and of the pings lost. With --enc=json, the statistics are in the Stats
+field of the last result.
+
+--size sets the size of the ping packets, a multiple of 32 bytes, to
+measure the time to transfer more data. --protocol pings over a connection
+using the given transport, e.g. "quic" or "tcp", connecting to the peer
+with its addresses using the transport if needed:
+
+  ipfs ping --protocol=quic --size=1024 --interval=500ms $PEERID
+`,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("peer ID", true, true, "ID of peer to be pinged.").EnableStdin(),
 	},
 	Options: []cmdkit.Option{
 		cmdkit.IntOption("count", "n", "Number of ping messages to send.").WithDefault(10),
+		cmdkit.StringOption("interval", "i", "Time to wait between pings.").WithDefault("1s"),
+		cmdkit.IntOption("size", "s", "Size of the ping packets in bytes, a multiple of 32.").WithDefault(ping.PingSize),
+		cmdkit.StringOption("protocol", "Ping over a connection using this transport, e.g. \"tcp\"."),
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
@@ -65,6 +104,14 @@ trip latency information.
 			} else {
 				fmt.Fprintf(buf, "Pong failed\n")
 			}
+			if st := obj.Stats; st != nil {
+				fmt.Fprintf(buf, "%d pings sent, %d received, %.0f%% loss", st.Sent, st.Received, st.Loss)
+				if st.Received > 0 {
+					fmt.Fprintf(buf, ", min/avg/max/stddev = %.2f/%.2f/%.2f/%.2f ms",
+						millis(st.Min), millis(st.Avg), millis(st.Max), millis(st.StdDev))
+				}
+				buf.WriteString("\n")
+			}
 			return buf, nil
 		},
 	},
@@ -105,15 +152,50 @@ trip latency information.
 
 		if numPings <= 0 {
 			res.SetError(fmt.Errorf("error: ping count must be greater than 0, was %d", numPings), cmdkit.ErrNormal)
+			return
 		}
 
-		outChan := pingPeer(ctx, n, peerID, numPings)
+		opts := pingOpts{count: numPings}
+
+		interval, _, _ := req.Option("interval").String()
+		opts.interval, err = time.ParseDuration(interval)
+		if err != nil {
+			res.SetError(fmt.Errorf("error: invalid ping interval: %s", err), cmdkit.ErrClient)
+			return
+		}
+
+		opts.size, _, err = req.Option("size").Int()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if opts.size <= 0 || opts.size%ping.PingSize != 0 || opts.size > maxPingSize {
+			res.SetError(fmt.Errorf("error: ping size must be a multiple of %d up to %d, was %d", ping.PingSize, maxPingSize, opts.size), cmdkit.ErrClient)
+			return
+		}
+
+		if proto, found, _ := req.Option("protocol").String(); found {
+			if ma.ProtocolWithName(proto).Code == 0 {
+				res.SetError(fmt.Errorf("error: unknown protocol %q", proto), cmdkit.ErrClient)
+				return
+			}
+			opts.transport = proto
+		}
+
+		outChan := pingPeer(ctx, n, peerID, opts)
 		res.SetOutput(outChan)
 	},
 	Type: PingResult{},
 }
 
-func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int) <-chan interface{} {
+type pingOpts struct {
+	count     int
+	interval  time.Duration
+	size      int
+	transport string
+}
+
+func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, opts pingOpts) <-chan interface{} {
 	outChan := make(chan interface{})
 	go func() {
 		defer close(outChan)
@@ -135,52 +217,198 @@ func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int)
 			n.Peerstore.AddAddrs(p.ID, p.Addrs, pstore.TempAddrTTL)
 		}
 
+		text := fmt.Sprintf("PING %s.", pid.Pretty())
+		if opts.size != ping.PingSize {
+			text = fmt.Sprintf("PING %s (%d bytes).", pid.Pretty(), opts.size)
+		}
 		outChan <- &PingResult{
-			Text:    fmt.Sprintf("PING %s.", pid.Pretty()),
+			Text:    text,
 			Success: true,
 		}
 
-		ctx, cancel := context.WithTimeout(ctx, kPingTimeout*time.Duration(numPings))
-		defer cancel()
-		pings, err := n.Ping.Ping(ctx, pid)
-		if err != nil {
-			outChan <- &PingResult{
-				Success: false,
-				Text:    fmt.Sprintf("Ping error: %s", err),
+		var sent int
+		var times []time.Duration
+		var s inet.Stream
+		defer func() {
+			if s != nil {
+				s.Close()
 			}
-			return
-		}
+		}()
 
-		var done bool
-		var total time.Duration
-		for i := 0; i < numPings && !done; i++ {
-			select {
-			case <-ctx.Done():
-				done = true
+		for i := 0; i < opts.count; i++ {
+			if i > 0 {
+				select {
+				case <-time.After(opts.interval):
+				case <-ctx.Done():
+				}
+			}
+			if ctx.Err() != nil {
 				break
-			case t, ok := <-pings:
-				if !ok {
-					done = true
-					break
+			}
+			sent++
+
+			var err error
+			if s == nil {
+				s, err = newPingStream(ctx, n, pid, opts.transport)
+			}
+
+			var t time.Duration
+			if err == nil {
+				t, err = pingOnce(s, opts.size)
+				if err != nil {
+					// the stream may be out of sync, use a new one
+					s.Reset()
+					s = nil
 				}
+			}
 
+			if err != nil {
 				outChan <- &PingResult{
-					Success: true,
-					Time:    t,
+					Success: false,
+					Text:    fmt.Sprintf("Ping error: %s", err),
 				}
-				total += t
-				time.Sleep(time.Second)
+				continue
+			}
+
+			n.Peerstore.RecordLatency(pid, t)
+			times = append(times, t)
+			outChan <- &PingResult{
+				Success: true,
+				Time:    t,
 			}
 		}
-		averagems := total.Seconds() * 1000 / float64(numPings)
+
+		stats := pingStats(sent, times)
 		outChan <- &PingResult{
-			Success: true,
-			Text:    fmt.Sprintf("Average latency: %.2fms", averagems),
+			Success: stats.Received > 0,
+			Text:    fmt.Sprintf("Average latency: %.2fms", millis(stats.Avg)),
+			Stats:   stats,
 		}
 	}()
 	return outChan
 }
 
+// newPingStream opens a ping stream to the peer, over a connection using
+// the given transport if any.
+func newPingStream(ctx context.Context, n *core.IpfsNode, pid peer.ID, transport string) (inet.Stream, error) {
+	ctx, cancel := context.WithTimeout(ctx, kPingTimeout)
+	defer cancel()
+
+	if transport == "" {
+		return n.PeerHost.NewStream(ctx, pid, ping.ID)
+	}
+
+	c := connWithTransport(n, pid, transport)
+	if c == nil {
+		var addrs []ma.Multiaddr
+		for _, a := range n.Peerstore.Addrs(pid) {
+			if hasProtocol(a, transport) {
+				addrs = append(addrs, a)
+			}
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("no %s address known for the peer", transport)
+		}
+		if err := n.PeerHost.Connect(ctx, pstore.PeerInfo{ID: pid, Addrs: addrs}); err != nil {
+			return nil, err
+		}
+
+		c = connWithTransport(n, pid, transport)
+		if c == nil {
+			// the host doesn't dial peers it is connected to
+			return nil, fmt.Errorf("the peer is connected over other transports than %s", transport)
+		}
+	}
+
+	s, err := c.NewStream()
+	if err != nil {
+		return nil, err
+	}
+	s.SetDeadline(time.Now().Add(kPingTimeout))
+	if err := msmux.SelectProtoOrFail(string(ping.ID), s); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	s.SetDeadline(time.Time{})
+	s.SetProtocol(ping.ID)
+	return s, nil
+}
+
+func connWithTransport(n *core.IpfsNode, pid peer.ID, transport string) inet.Conn {
+	for _, c := range n.PeerHost.Network().ConnsToPeer(pid) {
+		if hasProtocol(c.RemoteMultiaddr(), transport) {
+			return c
+		}
+	}
+	return nil
+}
+
+// pingOnce sends size random bytes, which the peer echoes ping.PingSize
+// bytes at a time, and returns the time it took to get them back.
+func pingOnce(s inet.Stream, size int) (time.Duration, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return 0, err
+	}
+
+	s.SetDeadline(time.Now().Add(kPingTimeout))
+	defer s.SetDeadline(time.Time{})
+
+	before := time.Now()
+	if _, err := s.Write(buf); err != nil {
+		return 0, err
+	}
+
+	rbuf := make([]byte, size)
+	if _, err := io.ReadFull(s, rbuf); err != nil {
+		return 0, err
+	}
+	t := time.Since(before)
+
+	if !bytes.Equal(buf, rbuf) {
+		return 0, errors.New("ping packet was incorrect")
+	}
+	return t, nil
+}
+
+func pingStats(sent int, times []time.Duration) *PingStats {
+	st := &PingStats{
+		Sent:     sent,
+		Received: len(times),
+	}
+	if sent > 0 {
+		st.Loss = float64(sent-len(times)) * 100 / float64(sent)
+	}
+	if len(times) == 0 {
+		return st
+	}
+
+	var total time.Duration
+	st.Min = times[0]
+	for _, t := range times {
+		total += t
+		if t < st.Min {
+			st.Min = t
+		}
+		if t > st.Max {
+			st.Max = t
+		}
+	}
+	st.Avg = total / time.Duration(len(times))
+
+	var variance float64
+	for _, t := range times {
+		d := float64(t - st.Avg)
+		variance += d * d
+	}
+	st.StdDev = time.Duration(math.Sqrt(variance / float64(len(times))))
+	return st
+}
+
+func millis(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
+
 func ParsePeerParam(text string) (ma.Multiaddr, peer.ID, error) {
 	// to be replaced with just multiaddr parsing, once ptp is a multiaddr protocol
 	idx := strings.LastIndex(text, "/")
@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPingStats(t *testing.T) {
+	st := pingStats(4, []time.Duration{
+		2 * time.Millisecond,
+		4 * time.Millisecond,
+		6 * time.Millisecond,
+	})
+
+	if st.Sent != 4 || st.Received != 3 || st.Loss != 25 {
+		t.Fatalf("wrong counts: %+v", st)
+	}
+	if st.Min != 2*time.Millisecond || st.Avg != 4*time.Millisecond || st.Max != 6*time.Millisecond {
+		t.Fatalf("wrong round trip times: %+v", st)
+	}
+	// sqrt(8/3) ms
+	if st.StdDev < 1632*time.Microsecond || st.StdDev > 1634*time.Microsecond {
+		t.Fatalf("wrong standard deviation: %s", st.StdDev)
+	}
+}
+
+func TestPingStatsAllLost(t *testing.T) {
+	st := pingStats(2, nil)
+	if st.Received != 0 || st.Loss != 100 || st.Avg != 0 {
+		t.Fatalf("wrong stats: %+v", st)
+	}
+}
+
+func TestPingStatsNoneSent(t *testing.T) {
+	st := pingStats(0, nil)
+	if st.Sent != 0 || st.Loss != 0 {
+		t.Fatalf("wrong stats: %+v", st)
+	}
+}
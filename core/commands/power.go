@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	core "github.com/ipfs/go-ipfs/core"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+var PowerCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the power usage of the daemon.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"save": powerSaveCmd,
+	},
+}
+
+var powerSaveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show or set the power save mode of the daemon.",
+		ShortDescription: `
+'ipfs power save' prints whether the daemon is in power save. 'ipfs power
+save on' and 'ipfs power save off' switch it on or off until the daemon
+stops, and 'ipfs power save auto' reverts to the default mode, where the
+daemon is in power save while the machine runs on battery if PowerSave.Auto
+is set in the config.
+`,
+		LongDescription: `
+'ipfs power save' prints whether the daemon is in power save. 'ipfs power
+save on' and 'ipfs power save off' switch it on or off until the daemon
+stops, and 'ipfs power save auto' reverts to the default mode, where the
+daemon is in power save while the machine runs on battery if PowerSave.Auto
+is set in the config.
+
+In power save, the daemon:
+
+  - pauses reproviding; 'ipfs bitswap reprovide' still works
+  - stops serving the DHT to other peers, as with Routing.Type "dhtclient"
+  - keeps at most PowerSave.HighWater connections (default: 20)
+
+The daemon can't tell whether the network is metered: scripts run on
+network changes, like NetworkManager dispatcher scripts, can run
+'ipfs power save on' when switching to a metered network.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("mode", false, false, "The power save mode to set: on, off or auto."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		if len(req.Arguments) > 0 {
+			if err := n.SetPowerSave(req.Arguments[0]); err != nil {
+				res.SetError(err, cmdkit.ErrClient)
+				return
+			}
+		}
+
+		status := n.PowerSave()
+		cmds.EmitOnce(res, &status)
+	},
+	Type: core.PowerSaveStatus{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			status, ok := v.(*core.PowerSaveStatus)
+			if !ok {
+				return e.TypeErr(status, v)
+			}
+
+			state := "off"
+			if status.Enabled {
+				state = "on"
+			}
+			fmt.Fprintf(w, "power save: %s (mode: %s", state, status.Mode)
+			if status.OnBattery {
+				fmt.Fprint(w, ", on battery")
+			}
+			fmt.Fprintln(w, ")")
+			return nil
+		}),
+	},
+}
@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
+	events "github.com/ipfs/go-ipfs/events"
 	keystore "github.com/ipfs/go-ipfs/keystore"
 	path "github.com/ipfs/go-ipfs/path"
 
@@ -58,6 +60,17 @@ Alternatively, publish an <ipfs-path> using a valid PeerID (as listed by
  > ipfs name publish --key=QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
   Published to QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n: /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
 
+Publish an <ipfs-path> under several names at once, by giving their keys
+separated by commas. The path is resolved once, the records of all the names
+get the same lifetime, and they are published in parallel. A key given twice,
+by name or by PeerID, is published to once. A name failing doesn't stop the
+others from being published, but the command fails, reporting the result of
+each name:
+
+  > ipfs name publish --key=self,mykey /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+  Published to QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n: /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+  Published to QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd: /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+
 `,
 	},
 
@@ -71,7 +84,7 @@ Alternatively, publish an <ipfs-path> using a valid PeerID (as listed by
     This accepts durations such as "300s", "1.5h" or "2h45m". Valid time units are
     "ns", "us" (or "µs"), "ms", "s", "m", "h".`).WithDefault("24h"),
 		cmdkit.StringOption("ttl", "Time duration this record should be cached for (caution: experimental)."),
-		cmdkit.StringOption("key", "k", "Name of the key to be used or a valid PeerID, as listed by 'ipfs key list -l'. Default: <<default>>.").WithDefault("self"),
+		cmdkit.StringOption("key", "k", "Name of the key to be used or a valid PeerID, as listed by 'ipfs key list -l'. Several keys can be given, separated by commas. Default: <<default>>.").WithDefault("self"),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -124,25 +137,64 @@ Alternatively, publish an <ipfs-path> using a valid PeerID (as listed by
 			ctx = context.WithValue(ctx, "ipns-publish-ttl", d)
 		}
 
-		kname, _, _ := req.Option("key").String()
-		k, err := keylookup(n, kname)
+		pth, err := path.ParsePath(pstr)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
 
-		pth, err := path.ParsePath(pstr)
-		if err != nil {
-			res.SetError(err, cmdkit.ErrNormal)
+		// all the keys are looked up before publishing to any of them
+		kname, _, _ := req.Option("key").String()
+		names := strings.Split(kname, ",")
+		var keys []crypto.PrivKey
+		seen := make(map[peer.ID]bool)
+		for _, name := range names {
+			k, err := keylookup(n, strings.TrimSpace(name))
+			if err != nil {
+				if len(names) > 1 {
+					err = fmt.Errorf("key %q: %s", name, err)
+				}
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			pid, err := peer.IDFromPrivateKey(k)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			if seen[pid] {
+				continue
+			}
+			seen[pid] = true
+			keys = append(keys, k)
+		}
+
+		if len(keys) == 1 {
+			output, err := publish(ctx, n, keys[0], pth, popts)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			res.SetOutput(output)
 			return
 		}
 
-		output, err := publish(ctx, n, k, pth, popts)
+		entries, err := publishMany(ctx, n, keys, pth, popts)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
-		res.SetOutput(output)
+		if err := publishManyError(entries); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		outChan := make(chan interface{}, len(entries))
+		for _, entry := range entries {
+			outChan <- entry
+		}
+		close(outChan)
+		res.SetOutput((<-chan interface{})(outChan))
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
@@ -188,12 +240,79 @@ func publish(ctx context.Context, n *core.IpfsNode, k crypto.PrivKey, ref path.P
 		return nil, err
 	}
 
+	n.Events.Emit(events.NamePublished, map[string]string{
+		"name":  pid.Pretty(),
+		"value": ref.String(),
+	})
+
 	return &IpnsEntry{
 		Name:  pid.Pretty(),
 		Value: ref.String(),
 	}, nil
 }
 
+// publishMany publishes ref under each of keys in parallel, and returns the
+// result of each key, in the order of keys. The path is resolved once, and all
+// the records get the same EOL.
+func publishMany(ctx context.Context, n *core.IpfsNode, keys []crypto.PrivKey, ref path.Path, opts *publishOpts) ([]*IpnsEntry, error) {
+	if opts.verifyExists {
+		_, err := core.Resolve(ctx, n.Namesys, n.Resolver, ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	eol := time.Now().Add(opts.pubValidTime)
+	entries := make([]*IpnsEntry, len(keys))
+	var wg sync.WaitGroup
+	for i, k := range keys {
+		wg.Add(1)
+		go func(i int, k crypto.PrivKey) {
+			defer wg.Done()
+
+			pid, err := peer.IDFromPrivateKey(k)
+			if err != nil {
+				entries[i] = &IpnsEntry{Error: err.Error()}
+				return
+			}
+			entry := &IpnsEntry{Name: pid.Pretty()}
+			entries[i] = entry
+
+			if err := n.Namesys.PublishWithEOL(ctx, k, ref, eol); err != nil {
+				entry.Error = err.Error()
+				return
+			}
+			entry.Value = ref.String()
+
+			n.Events.Emit(events.NamePublished, map[string]string{
+				"name":  entry.Name,
+				"value": entry.Value,
+			})
+		}(i, k)
+	}
+	wg.Wait()
+	return entries, nil
+}
+
+// publishManyError returns the error reporting the result of each name, if
+// publishing to any of them failed.
+func publishManyError(entries []*IpnsEntry) error {
+	var failed int
+	var results []string
+	for _, entry := range entries {
+		if entry.Error != "" {
+			failed++
+			results = append(results, fmt.Sprintf("failed to publish to %s: %s", entry.Name, entry.Error))
+		} else {
+			results = append(results, fmt.Sprintf("published to %s: %s", entry.Name, entry.Value))
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("publishing to %d of %d names failed:\n%s", failed, len(entries), strings.Join(results, "\n"))
+}
+
 func keylookup(n *core.IpfsNode, k string) (crypto.PrivKey, error) {
 
 	res, err := n.GetKey(k)
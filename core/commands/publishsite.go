@@ -0,0 +1,382 @@
+package commands
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	gopath "path"
+	"strings"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	"github.com/ipfs/go-ipfs/core/coreunix"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	path "github.com/ipfs/go-ipfs/path"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+	files "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit/files"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+)
+
+// siteMfsDir is the MFS directory holding the sites published with
+// 'ipfs publish-site', one entry per key.
+const siteMfsDir = "/sites"
+
+type PublishSiteOutput struct {
+	Name     string
+	Value    string
+	Previous string `json:",omitempty"`
+}
+
+var PublishSiteCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Publish a directory as a website under an IPNS name.",
+		ShortDescription: `
+'ipfs publish-site' adds a directory, pins it, and publishes it under the
+IPNS name of the given key. It keeps the published version in MFS, by
+default at /sites/<key>, and unpins the previous version once the new one is
+published.
+
+  > ipfs key gen --type=ed25519 blog
+  > ipfs publish-site -r ./public --key=blog
+  Published /ipfs/QmRZ...Tg to QmSr...BJd
+
+Running it again after changing the files publishes the new version and
+unpins the old one.
+`,
+		LongDescription: `
+'ipfs publish-site' adds a directory, pins it, and publishes it under the
+IPNS name of the given key. It keeps the published version in MFS, by
+default at /sites/<key>, and unpins the previous version once the new one is
+published.
+
+  > ipfs key gen --type=ed25519 blog
+  > ipfs publish-site -r ./public --key=blog
+  Published /ipfs/QmRZ...Tg to QmSr...BJd
+
+Running it again after changing the files publishes the new version and
+unpins the old one.
+
+The steps are the ones of:
+
+  > NEW=$(ipfs add -r -Q ./public)
+  > OLD=$(ipfs files stat --hash /sites/blog)
+  > ipfs files rm -r /sites/blog
+  > ipfs files cp /ipfs/$NEW /sites/blog
+  > ipfs name publish --key=blog /ipfs/$NEW
+  > ipfs pin rm $OLD
+
+The previous version is recorded in the repo before it's replaced in MFS. If
+publishing fails, the new version stays pinned and in MFS, and the previous
+one stays pinned; running the command again completes the update, and
+unpins it. The previous versions are unpinned even if they were pinned by
+other means.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("dir", true, false, "The directory of the site.").EnableRecursive(),
+	},
+	Options: []cmdkit.Option{
+		cmds.OptionRecursivePath, // a builtin option that allows recursive paths (-r, --recursive)
+		cmdkit.StringOption("key", "k", "Name of the key to publish the site with, as listed by 'ipfs key list'. Default: <<default>>.").WithDefault("self"),
+		cmdkit.StringOption("mfs-path", "MFS path to keep the published version at. Default: /sites/<key>."),
+		cmdkit.StringOption("lifetime", "t", "Time duration that the record will be valid for. Default: <<default>>.").WithDefault("24h"),
+		cmdkit.BoolOption(hiddenOptionName, "H", "Include files that are hidden. Only takes effect on recursive add."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		ctx := req.Context
+
+		if !n.OnlineMode() {
+			if err := n.SetupOfflineRouting(); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		if n.Mounts.Ipns != nil && n.Mounts.Ipns.IsActive() {
+			res.SetError(errors.New("cannot manually publish while IPNS is mounted"), cmdkit.ErrNormal)
+			return
+		}
+
+		kname, _ := req.Options["key"].(string)
+		k, err := keylookup(n, kname)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		lifetime, _ := req.Options["lifetime"].(string)
+		validTime, err := time.ParseDuration(lifetime)
+		if err != nil {
+			res.SetError(fmt.Errorf("error parsing lifetime option: %s", err), cmdkit.ErrClient)
+			return
+		}
+
+		mfsPath, ok := req.Options["mfs-path"].(string)
+		if !ok {
+			mfsPath = gopath.Join(siteMfsDir, kname)
+		}
+		mfsPath, err = checkPath(mfsPath)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrClient)
+			return
+		}
+		mfsPath = strings.TrimRight(mfsPath, "/")
+		if mfsPath == "" {
+			res.SetError(errors.New("cannot publish a site at the MFS root"), cmdkit.ErrClient)
+			return
+		}
+
+		dir, err := req.Files.NextFile()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if !dir.IsDirectory() {
+			res.SetError(fmt.Errorf("%s is not a directory", dir.FileName()), cmdkit.ErrClient)
+			return
+		}
+
+		hidden, _ := req.Options[hiddenOptionName].(bool)
+		root, err := addSite(ctx, n, dir, hidden)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		// the previous version is recorded before it's replaced, to be
+		// unpinned once a new version is published, by this run or the
+		// next one
+		prev, err := mfsNodeCid(n.FilesRoot, mfsPath)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		d := n.Repo.Datastore()
+		prevs, err := loadSitePrevious(d, mfsPath)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if prev != nil && !prev.Equals(root.Cid()) {
+			prevs = appendCid(prevs, prev)
+			if err := saveSitePrevious(d, mfsPath, prevs); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		if err := replaceMfsNode(n.FilesRoot, mfsPath, root); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		entry, err := publish(ctx, n, k, path.FromCid(root.Cid()), &publishOpts{pubValidTime: validTime})
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		out := &PublishSiteOutput{
+			Name:  entry.Name,
+			Value: entry.Value,
+		}
+
+		for len(prevs) > 0 {
+			c := prevs[0]
+			if !c.Equals(root.Cid()) {
+				err := n.Pinning.Unpin(ctx, c, true)
+				switch err {
+				case nil:
+					err = n.Pinning.Flush()
+				case pin.ErrNotPinned:
+					err = nil
+				}
+				if err != nil {
+					res.SetError(fmt.Errorf("published, but failed to unpin the previous version %s: %s", c, err), cmdkit.ErrNormal)
+					return
+				}
+				if prev != nil && c.Equals(prev) {
+					out.Previous = path.FromCid(c).String()
+				}
+			}
+
+			prevs = prevs[1:]
+			if err := saveSitePrevious(d, mfsPath, prevs); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		cmds.EmitOnce(res, out)
+	},
+	Type: PublishSiteOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*PublishSiteOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			fmt.Fprintf(w, "Published %s to %s\n", out.Value, out.Name)
+			if out.Previous != "" {
+				fmt.Fprintf(w, "Unpinned previous version %s\n", out.Previous)
+			}
+			return nil
+		}),
+	},
+}
+
+// addSite adds the directory and pins it recursively.
+func addSite(ctx context.Context, n *core.IpfsNode, dir files.File, hidden bool) (ipld.Node, error) {
+	adder, err := coreunix.NewAdder(ctx, n.Pinning, n.Blockstore, n.DAG)
+	if err != nil {
+		return nil, err
+	}
+	adder.Hidden = hidden
+	adder.Silent = true
+
+	if err := adder.AddFile(dir); err != nil {
+		return nil, err
+	}
+
+	root, err := adder.Finalize()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := adder.PinRoot(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// sitePreviousKey is the datastore key of the previous versions of the site
+// kept at the MFS path p, left to unpin.
+func sitePreviousKey(p string) ds.Key {
+	return ds.NewKey("/local/publish-site").ChildString(hex.EncodeToString([]byte(p)))
+}
+
+// loadSitePrevious returns the previous versions of the site kept at p left
+// to unpin.
+func loadSitePrevious(d ds.Datastore, p string) ([]*cid.Cid, error) {
+	v, err := d.Get(sitePreviousKey(p))
+	if err == ds.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	b, _ := v.([]byte)
+	var strs []string
+	if err := json.Unmarshal(b, &strs); err != nil {
+		return nil, fmt.Errorf("invalid record of the previous versions of %s: %s", p, err)
+	}
+	cids := make([]*cid.Cid, 0, len(strs))
+	for _, s := range strs {
+		c, err := cid.Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid record of the previous versions of %s: %s", p, err)
+		}
+		cids = append(cids, c)
+	}
+	return cids, nil
+}
+
+// saveSitePrevious records the previous versions of the site kept at p left
+// to unpin, removing the record when there are none.
+func saveSitePrevious(d ds.Datastore, p string, cids []*cid.Cid) error {
+	if len(cids) == 0 {
+		err := d.Delete(sitePreviousKey(p))
+		if err == ds.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	strs := make([]string, len(cids))
+	for i, c := range cids {
+		strs[i] = c.String()
+	}
+	b, err := json.Marshal(strs)
+	if err != nil {
+		return err
+	}
+	return d.Put(sitePreviousKey(p), b)
+}
+
+// appendCid appends c to cids, unless it's in already.
+func appendCid(cids []*cid.Cid, c *cid.Cid) []*cid.Cid {
+	for _, o := range cids {
+		if o.Equals(c) {
+			return cids
+		}
+	}
+	return append(cids, c)
+}
+
+// mfsNodeCid returns the CID of the node at p in MFS, nil if there is none.
+func mfsNodeCid(r *mfs.Root, p string) (*cid.Cid, error) {
+	fsn, err := mfs.Lookup(r, p)
+	if err == os.ErrNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	nd, err := fsn.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	return nd.Cid(), nil
+}
+
+// replaceMfsNode puts nd at p in MFS, creating the parent directories as
+// needed, in place of the node there, if any.
+func replaceMfsNode(r *mfs.Root, p string, nd ipld.Node) error {
+	dirp, name := gopath.Dir(p), gopath.Base(p)
+
+	parent, err := mfs.Lookup(r, dirp)
+	if err == os.ErrNotExist {
+		err = mfs.Mkdir(r, dirp, mfs.MkdirOpts{Mkparents: true})
+		if err == nil {
+			parent, err = mfs.Lookup(r, dirp)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	pdir, ok := parent.(*mfs.Directory)
+	if !ok {
+		return fmt.Errorf("%s is not a directory", dirp)
+	}
+
+	switch _, err := pdir.Child(name); err {
+	case nil:
+		if err := pdir.Unlink(name); err != nil {
+			return err
+		}
+	case os.ErrNotExist:
+	default:
+		return err
+	}
+
+	if err := pdir.AddChild(name, nd); err != nil {
+		return err
+	}
+	return mfs.FlushPath(r, p)
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"testing"
+
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+)
+
+func TestSitePrevious(t *testing.T) {
+	d := ds.NewMapDatastore()
+
+	prevs, err := loadSitePrevious(d, "/sites/blog")
+	if err != nil || prevs != nil {
+		t.Fatalf("expected no previous version, got %v, %v", prevs, err)
+	}
+
+	var cids []*cid.Cid
+	for _, s := range []string{
+		"QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB",
+		"QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG",
+	} {
+		c, err := cid.Decode(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cids = appendCid(cids, c)
+	}
+	cids = appendCid(cids, cids[0])
+	if len(cids) != 2 {
+		t.Fatalf("expected the versions to be recorded once, got %v", cids)
+	}
+
+	if err := saveSitePrevious(d, "/sites/blog", cids); err != nil {
+		t.Fatal(err)
+	}
+	prevs, err = loadSitePrevious(d, "/sites/blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prevs) != 2 || !prevs[0].Equals(cids[0]) || !prevs[1].Equals(cids[1]) {
+		t.Fatalf("expected %v, got %v", cids, prevs)
+	}
+	// the sites kept at other paths have their own versions
+	if prevs, err := loadSitePrevious(d, "/www/blog"); err != nil || prevs != nil {
+		t.Fatalf("expected no previous version at another path, got %v, %v", prevs, err)
+	}
+
+	if err := saveSitePrevious(d, "/sites/blog", nil); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := d.Has(sitePreviousKey("/sites/blog")); has {
+		t.Fatal("expected the record to be removed once the versions are unpinned")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/ipfs/go-ipfs/core"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
 	path "github.com/ipfs/go-ipfs/path"
+	cidset "github.com/ipfs/go-ipfs/thirdparty/cidset"
 
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
 	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
@@ -106,6 +107,12 @@ NOTE: List all references recursively by using the flag '-r'.
 			format = "<src> -> <dst>"
 		}
 
+		ctx, err = n.TraversalContext(ctx)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
 		objs, err := objectsForPaths(ctx, n, req.Arguments())
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
@@ -126,6 +133,7 @@ NOTE: List all references recursively by using the flag '-r'.
 				PrintFmt:  format,
 				Recursive: recursive,
 			}
+			defer rw.Close()
 
 			for _, o := range objs {
 				if _, err := rw.WriteRefs(o); err != nil {
@@ -235,7 +243,15 @@ type RefWriter struct {
 	Recursive bool
 	PrintFmt  string
 
-	seen *cid.Set
+	seen cidset.Set
+}
+
+// Close releases the set of the refs seen, with --unique.
+func (rw *RefWriter) Close() error {
+	if rw.seen == nil {
+		return nil
+	}
+	return rw.seen.Close()
 }
 
 // WriteRefs writes refs of the given object to the underlying writer.
@@ -252,7 +268,11 @@ func (rw *RefWriter) writeRefsRecursive(n ipld.Node) (int, error) {
 	var count int
 	for i, ng := range ipld.GetDAG(rw.Ctx, rw.DAG, n) {
 		lc := n.Links()[i].Cid
-		if rw.skip(lc) {
+		skip, err := rw.skip(lc)
+		if err != nil {
+			return count, err
+		}
+		if skip {
 			continue
 		}
 
@@ -277,14 +297,19 @@ func (rw *RefWriter) writeRefsRecursive(n ipld.Node) (int, error) {
 func (rw *RefWriter) writeRefsSingle(n ipld.Node) (int, error) {
 	c := n.Cid()
 
-	if rw.skip(c) {
-		return 0, nil
+	skip, err := rw.skip(c)
+	if err != nil || skip {
+		return 0, err
 	}
 
 	count := 0
 	for _, l := range n.Links() {
 		lc := l.Cid
-		if rw.skip(lc) {
+		skip, err := rw.skip(lc)
+		if err != nil {
+			return count, err
+		}
+		if skip {
 			continue
 		}
 
@@ -297,20 +322,27 @@ func (rw *RefWriter) writeRefsSingle(n ipld.Node) (int, error) {
 }
 
 // skip returns whether to skip a cid
-func (rw *RefWriter) skip(c *cid.Cid) bool {
+func (rw *RefWriter) skip(c *cid.Cid) (bool, error) {
 	if !rw.Unique {
-		return false
+		return false, nil
 	}
 
 	if rw.seen == nil {
-		rw.seen = cid.NewSet()
+		ctx := rw.Ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		seen, err := cidset.New(ctx)
+		if err != nil {
+			return false, err
+		}
+		rw.seen = seen
 	}
 
-	has := rw.seen.Has(c)
-	if !has {
-		rw.seen.Add(c)
+	if rw.seen.Visit(c) {
+		return false, rw.seen.Err()
 	}
-	return has
+	return true, nil
 }
 
 // Write one edge
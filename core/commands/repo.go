@@ -16,12 +16,16 @@ import (
 	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
 	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
 	bstore "gx/ipfs/QmbaPGg81pvQiC5vTXtC9Jo8rdrWUjRaugH71WYNsgi6Ev/go-ipfs-blockstore"
 	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
 )
 
+const repoBreakdownOptionName = "breakdown"
+
 type RepoVersion struct {
 	Version string
 }
@@ -40,6 +44,11 @@ var RepoCmd = &cmds.Command{
 		"fsck":    lgc.NewCommand(RepoFsckCmd),
 		"version": lgc.NewCommand(repoVersionCmd),
 		"verify":  lgc.NewCommand(repoVerifyCmd),
+		"backup":  repoBackupCmd,
+		"restore": repoRestoreCmd,
+		"export":  repoExportCmd,
+		"import":  repoImportCmd,
+		"lock":    repoLockCmd,
 	},
 }
 
@@ -156,6 +165,19 @@ NumObjects      int Number of objects in the local repo.
 RepoPath        string The path to the repo being currently used.
 RepoSize        int Size in bytes that the repo is currently taking.
 Version         string The repo version.
+
+With --breakdown, it also breaks the size of the repo down by category:
+Pinned          the blocks of the pinned DAGs.
+MFS             the other blocks of the MFS tree ('ipfs files').
+Cache           the other blocks, removed by garbage collection.
+Filestore       the data of the files referenced by the filestore.
+Overhead        the rest of RepoSize, taken by the datastore itself.
+
+The blocks are counted as they are added and removed, and the daemon counts
+the pinned and MFS blocks in the background when they change, at most every
+10 minutes, so the breakdown returns quickly even on large repos. It's marked
+as stale when the pins or the MFS root changed since. Without a running
+daemon, they are counted first when they changed.
 `,
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
@@ -171,10 +193,19 @@ Version         string The repo version.
 			return
 		}
 
+		if breakdown, _ := req.Options[repoBreakdownOptionName].(bool); breakdown {
+			stat.Usage, err = corerepo.RepoUsage(n, req.Context)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
 		cmds.EmitOnce(res, stat)
 	},
 	Options: []cmdkit.Option{
 		cmdkit.BoolOption("human", "Output RepoSize in MiB."),
+		cmdkit.BoolOption(repoBreakdownOptionName, "b", "Break the size of the repo down by category."),
 	},
 	Type: corerepo.Stat{},
 	Encoders: cmds.EncoderMap{
@@ -205,6 +236,36 @@ Version         string The repo version.
 			}
 			fmt.Fprintf(wtr, "RepoPath:\t%s\n", stat.RepoPath)
 			fmt.Fprintf(wtr, "Version:\t%s\n", stat.Version)
+			if u := stat.Usage; u != nil {
+				switch {
+				case u.Counting:
+					fmt.Fprintf(wtr, "Breakdown:\tcounting the blocks\n")
+				case u.Updated.IsZero():
+					fmt.Fprintf(wtr, "Breakdown:\tnot counted yet\n")
+				default:
+					updated := u.Updated.Local().Format("2006-01-02 15:04:05")
+					if u.Stale {
+						updated += " (stale)"
+					}
+					fmt.Fprintf(wtr, "Breakdown:\tas of %s\n", updated)
+					for _, c := range []struct {
+						name string
+						size uint64
+					}{
+						{"Pinned", u.Pinned},
+						{"MFS", u.MFS},
+						{"Cache", u.Cache},
+						{"Filestore", u.Filestore},
+						{"Overhead", u.Overhead},
+					} {
+						if human {
+							fmt.Fprintf(wtr, "  %s:\t%s\n", c.name, humanize.IBytes(c.size))
+						} else {
+							fmt.Fprintf(wtr, "  %s:\t%d\n", c.name, c.size)
+						}
+					}
+				}
+			}
 			wtr.Flush()
 
 			return nil
@@ -244,6 +305,11 @@ daemons are running.
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
+		err = os.Remove(filepath.Join(configRoot, fsrepo.LockOwnerFile))
+		if err != nil && !os.IsNotExist(err) {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
 		err = os.Remove(dsLockFile)
 		if err != nil && !os.IsNotExist(err) {
 			res.SetError(err, cmdkit.ErrNormal)
@@ -263,6 +329,71 @@ daemons are running.
 	},
 }
 
+var repoLockCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect the repo lock.",
+		ShortDescription: `
+The repo lock keeps several processes, like two daemons, from using the repo
+at once. 'ipfs repo lock' inspects it.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"status": lgc.NewCommand(repoLockStatusCmd),
+	},
+}
+
+var repoLockStatusCmd = &oldcmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show whether the repo is locked, and by which process.",
+		ShortDescription: `
+'ipfs repo lock status' shows whether a process holds the repo lock, and the
+process which last held it: its PID, its host and when it took the lock.
+
+A lock left by a process of this host which isn't running anymore is stale.
+'ipfs daemon --steal-lock' takes a stale lock over, rather than deleting the
+lock file by hand. Locks held by processes of other hosts, on shared
+filesystems, are never stale: stop the process holding them instead.
+`,
+	},
+	Run: func(req oldcmds.Request, res oldcmds.Response) {
+		st, err := fsrepo.GetLockStatus(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		res.SetOutput(st)
+	},
+	Type: fsrepo.LockStatus{},
+	Marshalers: oldcmds.MarshalerMap{
+		oldcmds.Text: func(res oldcmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			st, ok := v.(*fsrepo.LockStatus)
+			if !ok {
+				return nil, e.TypeErr(st, v)
+			}
+
+			buf := new(bytes.Buffer)
+			if st.Locked {
+				fmt.Fprintln(buf, "Locked: yes")
+			} else {
+				fmt.Fprintln(buf, "Locked: no")
+			}
+			if st.Owner != nil {
+				fmt.Fprintf(buf, "Owner: %s\n", st.Owner)
+			}
+			if st.Locked && st.Stale {
+				fmt.Fprintln(buf, "The owner isn't running anymore: the lock is stale.")
+			}
+			return buf, nil
+		},
+	},
+}
+
 type VerifyProgress struct {
 	Msg      string
 	Progress int
@@ -401,3 +532,261 @@ var repoVersionCmd = &oldcmds.Command{
 		},
 	},
 }
+
+var repoBackupCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Save a snapshot of the repo to a file.",
+		ShortDescription: `
+'ipfs repo backup' saves a consistent snapshot of the repo to a file: its
+config and its datastore, with the blocks and the pins. It can run while the
+daemon runs, unlike copying the repo directory. Garbage collections and
+block removals wait for the whole backup to complete.
+
+The private keys of the node, its identity and the keys of its keystore, are
+only saved with --keys. Keep such backups safe.
+
+With --pinned-only, only the blocks of the pins and of the files root (see
+'ipfs files') are saved, rather than all the blocks of the repo.
+
+Backups are restored with 'ipfs repo restore'.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("file", true, false, "The file to save the backup to."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("keys", "Save the private keys of the node."),
+		cmdkit.BoolOption("pinned-only", "Only save the pinned blocks."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		keys, _ := req.Options["keys"].(bool)
+		pinnedOnly, _ := req.Options["pinned-only"].(bool)
+		opts := corerepo.BackupOptions{Keys: keys, PinnedOnly: pinnedOnly}
+
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(corerepo.Backup(req.Context, n, pw, opts))
+		}()
+		res.Emit(pr)
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: saveArchive("Saved the backup to %s\n"),
+	},
+}
+
+// writeArchive writes the archive read from r to fpath. The file is only
+// created once the archive is complete.
+func writeArchive(fpath string, r io.Reader) error {
+	tmp := fpath + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, fpath)
+}
+
+var repoRestoreCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Restore a backup made with 'ipfs repo backup'.",
+		ShortDescription: `
+'ipfs repo restore' restores a backup made with 'ipfs repo backup' into the
+repo: its config, its datastore entries and the keys it holds. The daemon
+must not be running. Restore into a new repo, made with 'ipfs init'; the
+entries and keys of the repo with the same names are replaced.
+
+When the backup doesn't hold the private keys of the node, the repo keeps its
+own identity. The repo also keeps its own datastore config, as the entries are
+restored into its datastore.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("file", true, false, "The backup file to restore."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		f, err := os.Open(req.Arguments[0])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer f.Close()
+
+		stat, err := corerepo.Restore(n, f)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		cmds.EmitOnce(res, stat)
+	},
+	Type: corerepo.RestoreStat{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			stat, ok := v.(*corerepo.RestoreStat)
+			if !ok {
+				return e.TypeErr(stat, v)
+			}
+
+			fmt.Fprintf(w, "Restored %d datastore entries and %d keys\n", stat.Entries, stat.Keys)
+			if stat.Identity {
+				fmt.Fprintln(w, "Restored the identity of the node")
+			}
+			return nil
+		}),
+	},
+}
+
+// saveArchive is the PostRun of the commands emitting an archive, which
+// saves it to the file of their first argument and prints msg with its path.
+func saveArchive(msg string) func(*cmds.Request, cmds.ResponseEmitter) cmds.ResponseEmitter {
+	return func(req *cmds.Request, re cmds.ResponseEmitter) cmds.ResponseEmitter {
+		reNext, res := cmds.NewChanResponsePair(req)
+
+		go func() {
+			defer re.Close()
+
+			v, err := res.Next()
+			if !cmds.HandleError(err, res, re) {
+				return
+			}
+
+			r, ok := v.(io.Reader)
+			if !ok {
+				re.SetError(e.TypeErr(r, v), cmdkit.ErrNormal)
+				return
+			}
+
+			fpath := req.Arguments[0]
+			if err := writeArchive(fpath, r); err != nil {
+				re.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			fmt.Fprintf(os.Stdout, msg, fpath)
+		}()
+
+		return reNext
+	}
+}
+
+var repoExportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Export the datastore entries under some prefixes to a file.",
+		ShortDescription: `
+'ipfs repo export' saves the datastore entries whose keys are under the given
+prefixes to a file, which 'ipfs repo import' imports into another repo. It
+moves parts of the state of a node, like its pins or its IPNS records, rather
+than the whole repo as 'ipfs repo backup' does.
+
+Some of the prefixes of the datastore are:
+
+  /local/pins   the pins
+  /ipns         the IPNS records
+  /local        the local state of the node, including the pins
+  /blocks       the blocks
+
+When the pins are exported, the blocks of the pin sets are exported with them.
+The blocks of the pinned DAGs aren't: export /blocks too, or fetch them on the
+new node, for example with 'ipfs refs -r'.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("file", true, false, "The file to save the export to."),
+		cmdkit.StringArg("prefix", true, true, "The prefixes of the keys of the entries to export."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		var prefixes []ds.Key
+		for _, p := range req.Arguments[1:] {
+			if !strings.HasPrefix(p, "/") {
+				res.SetError(fmt.Errorf("invalid prefix %q: prefixes start with /", p), cmdkit.ErrNormal)
+				return
+			}
+			prefixes = append(prefixes, ds.NewKey(p))
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			_, err := corerepo.Export(req.Context, n, pw, prefixes)
+			pw.CloseWithError(err)
+		}()
+		res.Emit(pr)
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: saveArchive("Saved the export to %s\n"),
+	},
+}
+
+var repoImportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Import the datastore entries of a file made with 'ipfs repo export'.",
+		ShortDescription: `
+'ipfs repo import' puts the datastore entries of a file made with
+'ipfs repo export' into the repo. The entries of the repo with the same keys
+are replaced: importing the pins replaces all the pins of the repo. The daemon
+must not be running.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("file", true, false, "The export file to import."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		f, err := os.Open(req.Arguments[0])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		defer f.Close()
+
+		stat, err := corerepo.Import(n, f)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		cmds.EmitOnce(res, stat)
+	},
+	Type: corerepo.ExportStat{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			stat, ok := v.(*corerepo.ExportStat)
+			if !ok {
+				return e.TypeErr(stat, v)
+			}
+
+			fmt.Fprintf(w, "Imported %d datastore entries\n", stat.Entries)
+			return nil
+		}),
+	},
+}
@@ -19,13 +19,15 @@ import (
 var log = logging.Logger("core/commands")
 
 const (
-	ApiOption = "api"
+	ApiOption         = "api"
+	OfflineOption     = "offline"
+	QuietErrorsOption = "quiet-errors"
 )
 
 var Root = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline:  "Global p2p merkle-dag filesystem.",
-		Synopsis: "ipfs [--config=<config> | -c] [--debug=<debug> | -D] [--help=<help>] [-h=<h>] [--local=<local> | -L] [--api=<api>] <command> ...",
+		Synopsis: "ipfs [--config=<config> | -c] [--debug=<debug> | -D] [--help=<help>] [-h=<h>] [--local=<local> | -L] [--offline] [--timeout=<timeout>] [--quiet-errors] [--api=<api>] <command> ...",
 		Subcommands: `
 BASIC COMMANDS
   init          Initialize ipfs local configuration
@@ -39,7 +41,9 @@ DATA STRUCTURE COMMANDS
   block         Interact with raw blocks in the datastore
   object        Interact with raw dag nodes
   files         Interact with objects as if they were a unix filesystem
+  dir           Interact with unixfs directories
   dag           Interact with IPLD documents (experimental)
+  car           List and check the blocks of CAR files
 
 ADVANCED COMMANDS
   daemon        Start a long-running daemon process
@@ -48,6 +52,7 @@ ADVANCED COMMANDS
   name          Publish and resolve IPNS names
   key           Create and list IPNS name keypairs
   dns           Resolve DNS links
+  events        Stream node events
   pin           Pin objects to local storage
   repo          Manipulate the IPFS repository
   stats         Various operational stats
@@ -63,7 +68,11 @@ NETWORK COMMANDS
   diag          Print diagnostics
 
 TOOL COMMANDS
+  cid           Convert and discover properties of CIDs
+  multibase     Encode and decode data with multibase
+  verify        Compare files with a UnixFS DAG
   config        Manage configuration
+  auth          Manage access to the HTTP API
   version       Show ipfs version information
   update        Download and apply go-ipfs updates
   commands      List all available commands
@@ -76,6 +85,23 @@ environment variable:
 
   export IPFS_PATH=/path/to/ipfsrepo
 
+Commands which don't need the network can be run with --offline. They
+then only use the blocks of the local repo. When the daemon is running,
+it runs them on an offline view of its node; when the daemon is not
+running, they run directly against the repo.
+
+Any command can be given a deadline with --timeout, e.g. --timeout=30s.
+Once it passes, the command stops resolving paths and fetching blocks
+and fails. The deadline also applies when the command runs on the daemon.
+
+MACHINE OUTPUT
+
+With --enc=json, commands print their results as JSON. Commands which
+stream results print one JSON document per line. Commands which output
+raw data, like 'ipfs cat', print it as-is. With --quiet-errors, errors
+are printed to stderr as JSON objects, one per line, and nothing else
+is printed to stderr. See docs/json-output.md.
+
 EXIT STATUS
 
 The CLI will exit with one of the following values:
@@ -90,6 +116,8 @@ The CLI will exit with one of the following values:
 		cmdkit.BoolOption("help", "Show the full command help text."),
 		cmdkit.BoolOption("h", "Show a short version of the command help text."),
 		cmdkit.BoolOption("local", "L", "Run the command locally, instead of using the daemon."),
+		cmdkit.BoolOption(OfflineOption, "Run the command offline, using only the local repo."),
+		cmdkit.BoolOption(QuietErrorsOption, "Print errors as JSON to stderr, and nothing else."),
 		cmdkit.StringOption(ApiOption, "Use a specific API instance (defaults to /ip4/127.0.0.1/tcp/5001)"),
 
 		// global options, added to every command
@@ -103,41 +131,50 @@ The CLI will exit with one of the following values:
 var CommandsDaemonCmd = CommandsCmd(Root)
 
 var rootSubcommands = map[string]*cmds.Command{
-	"add":       AddCmd,
-	"bitswap":   BitswapCmd,
-	"block":     BlockCmd,
-	"cat":       CatCmd,
-	"commands":  CommandsDaemonCmd,
-	"files":     FilesCmd,
-	"filestore": FileStoreCmd,
-	"get":       GetCmd,
-	"pubsub":    PubsubCmd,
-	"repo":      RepoCmd,
-	"stats":     StatsCmd,
-	"bootstrap": lgc.NewCommand(BootstrapCmd),
-	"config":    lgc.NewCommand(ConfigCmd),
-	"dag":       lgc.NewCommand(dag.DagCmd),
-	"dht":       lgc.NewCommand(DhtCmd),
-	"diag":      lgc.NewCommand(DiagCmd),
-	"dns":       lgc.NewCommand(DNSCmd),
-	"id":        lgc.NewCommand(IDCmd),
-	"key":       lgc.NewCommand(KeyCmd),
-	"log":       lgc.NewCommand(LogCmd),
-	"ls":        lgc.NewCommand(LsCmd),
-	"mount":     lgc.NewCommand(MountCmd),
-	"name":      lgc.NewCommand(NameCmd),
-	"object":    ocmd.ObjectCmd,
-	"pin":       lgc.NewCommand(PinCmd),
-	"ping":      lgc.NewCommand(PingCmd),
-	"p2p":       lgc.NewCommand(P2PCmd),
-	"refs":      lgc.NewCommand(RefsCmd),
-	"resolve":   lgc.NewCommand(ResolveCmd),
-	"swarm":     lgc.NewCommand(SwarmCmd),
-	"tar":       lgc.NewCommand(TarCmd),
-	"file":      lgc.NewCommand(unixfs.UnixFSCmd),
-	"update":    lgc.NewCommand(ExternalBinary()),
-	"version":   lgc.NewCommand(VersionCmd),
-	"shutdown":  lgc.NewCommand(daemonShutdownCmd),
+	"add":          AddCmd,
+	"bitswap":      BitswapCmd,
+	"block":        BlockCmd,
+	"cat":          CatCmd,
+	"cid":          CidCmd,
+	"commands":     CommandsDaemonCmd,
+	"dir":          DirCmd,
+	"events":       EventsCmd,
+	"files":        FilesCmd,
+	"filestore":    FileStoreCmd,
+	"get":          GetCmd,
+	"pubsub":       PubsubCmd,
+	"publish-site": PublishSiteCmd,
+	"repo":         RepoCmd,
+	"stats":        StatsCmd,
+	"verify":       VerifyCmd,
+	"car":          CarCmd,
+	"auth":         lgc.NewCommand(AuthCmd),
+	"bootstrap":    lgc.NewCommand(BootstrapCmd),
+	"config":       lgc.NewCommand(ConfigCmd),
+	"dag":          lgc.NewCommand(dag.DagCmd),
+	"dht":          lgc.NewCommand(DhtCmd),
+	"diag":         lgc.NewCommand(DiagCmd),
+	"dns":          lgc.NewCommand(DNSCmd),
+	"id":           lgc.NewCommand(IDCmd),
+	"key":          lgc.NewCommand(KeyCmd),
+	"log":          lgc.NewCommand(LogCmd),
+	"ls":           lgc.NewCommand(LsCmd),
+	"mount":        lgc.NewCommand(MountCmd),
+	"multibase":    MultibaseCmd,
+	"power":        PowerCmd,
+	"name":         lgc.NewCommand(NameCmd),
+	"object":       ocmd.ObjectCmd,
+	"pin":          lgc.NewCommand(PinCmd),
+	"ping":         lgc.NewCommand(PingCmd),
+	"p2p":          lgc.NewCommand(P2PCmd),
+	"refs":         lgc.NewCommand(RefsCmd),
+	"resolve":      lgc.NewCommand(ResolveCmd),
+	"swarm":        lgc.NewCommand(SwarmCmd),
+	"tar":          lgc.NewCommand(TarCmd),
+	"file":         lgc.NewCommand(unixfs.UnixFSCmd),
+	"update":       lgc.NewCommand(ExternalBinary()),
+	"version":      lgc.NewCommand(VersionCmd),
+	"shutdown":     lgc.NewCommand(daemonShutdownCmd),
 }
 
 // RootRO is the readonly version of Root
@@ -156,6 +193,7 @@ var rootROSubcommands = map[string]*cmds.Command{
 			"get":  blockGetCmd,
 		},
 	},
+	"dir": DirCmd,
 	"get": GetCmd,
 	"dns": lgc.NewCommand(DNSCmd),
 	"ls":  lgc.NewCommand(LsCmd),
@@ -198,6 +236,14 @@ func init() {
 	Root.Subcommands = rootSubcommands
 
 	RootRO.Subcommands = rootROSubcommands
+
+	// honor the global options which change how every command runs, and
+	// tell the observers about it
+	for _, wrap := range []func(runFunc) runFunc{allowOffline, applyTimeout, observeRun} {
+		seen := make(map[*cmds.Command]bool)
+		wrapRuns(Root, seen, wrap)
+		wrapRuns(RootRO, seen, wrap)
+	}
 }
 
 type MessageOutput struct {
@@ -24,9 +24,13 @@ var daemonShutdownCmd = &cmds.Command{
 			return
 		}
 
-		if err := nd.Process().Close(); err != nil {
-			log.Error("error while shutting down ipfs daemon:", err)
-		}
+		// closed in the background: the HTTP servers wait for the requests
+		// in flight, including this one, before the node is torn down
+		go func() {
+			if err := nd.Process().Close(); err != nil {
+				log.Error("error while shutting down ipfs daemon:", err)
+			}
+		}()
 
 		res.SetOutput(nil)
 	},
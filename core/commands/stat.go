@@ -5,8 +5,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"text/tabwriter"
 	"time"
 
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	dhtstats "github.com/ipfs/go-ipfs/dhtstats"
+	growth "github.com/ipfs/go-ipfs/repo/growth"
+
 	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
 	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
@@ -29,6 +34,8 @@ for your IPFS node.`,
 		"bw":      statBwCmd,
 		"repo":    repoStatCmd,
 		"bitswap": bitswapStatCmd,
+		"growth":  statGrowthCmd,
+		"dht":     statDHTCmd,
 	},
 }
 
@@ -189,3 +196,189 @@ func printStats(out io.Writer, bs *metrics.Stats) {
 	fmt.Fprintf(out, "RateIn: %s/s\n", humanize.Bytes(uint64(bs.RateIn)))
 	fmt.Fprintf(out, "RateOut: %s/s\n", humanize.Bytes(uint64(bs.RateOut)))
 }
+
+// GrowthOutput is the output of 'ipfs stats growth'.
+type GrowthOutput struct {
+	Days []*growth.Day
+}
+
+var statGrowthCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print the growth of the repo over the last days.",
+		ShortDescription: `
+'ipfs stats growth' prints, for each of the last days, the number of blocks
+added to and removed from the repo, the size of the blocks added, and the
+number of pins and size of the repo sampled once that day.
+`,
+		LongDescription: `
+'ipfs stats growth' prints, for each of the last days, the number of blocks
+added to and removed from the repo, the size of the blocks added, and the
+number of pins and size of the repo sampled once that day.
+
+The blocks are counted by every node using the repo, the daemon as well as
+commands run without it. The pins and repo size are sampled by the daemon,
+and are missing for the days it didn't run. Days are in UTC, and the ones
+without activity are omitted. The last 366 days are kept.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.IntOption("days", "n", "Number of days to print.").WithDefault(30),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		days, _ := req.Options["days"].(int)
+		if days <= 0 {
+			res.SetError(fmt.Errorf("days must be positive"), cmdkit.ErrClient)
+			return
+		}
+
+		out, err := n.Growth.Days(days)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		cmds.EmitOnce(res, &GrowthOutput{Days: out})
+	},
+	Type: GrowthOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*GrowthOutput)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "Date\tAdded\tAddedSize\tRemoved\tRecursivePins\tDirectPins\tRepoSize")
+
+			var added, addedSize, removed uint64
+			for _, d := range out.Days {
+				added += d.BlocksAdded
+				addedSize += d.BytesAdded
+				removed += d.BlocksRemoved
+
+				rpins, dpins, size := "-", "-", "-"
+				if s := d.Sample; s != nil {
+					rpins = fmt.Sprint(s.RecursivePins)
+					dpins = fmt.Sprint(s.DirectPins)
+					size = humanize.Bytes(s.RepoSize)
+				}
+				fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\t%s\t%s\n", d.Date, d.BlocksAdded,
+					humanize.Bytes(d.BytesAdded), d.BlocksRemoved, rpins, dpins, size)
+			}
+			if len(out.Days) > 1 {
+				fmt.Fprintf(tw, "Total\t%d\t%s\t%d\t\t\t\n", added, humanize.Bytes(addedSize), removed)
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+var statDHTCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Print the health of the DHT routing table and lookups.",
+		ShortDescription: `
+'ipfs stats dht' prints the number of peers in each bucket of the DHT routing
+table, how many peers joined and left it over the last hour, and the success
+ratio, average latency and hops of the lookups made by the node.
+`,
+		LongDescription: `
+'ipfs stats dht' prints the number of peers in each bucket of the DHT routing
+table, how many peers joined and left it over the last hour, and the success
+ratio, average latency and hops of the lookups made by the node, to tell
+whether poor content discovery comes from the connectivity of the node.
+
+The buckets are numbered by the number of leading bits the IDs of their peers
+share with the ID of the node. Each keeps 20 peers at most, and is marked as
+full once it has them. The routing table is sampled every 10 seconds.
+
+The lookups are the ones made by the services of the node (bitswap, IPNS and
+peer routing) since the daemon started; the ones aborted before completing
+aren't counted. The latency of FindProviders is the time until the first
+provider was found. The hops of a lookup go from the peers of the routing
+table, at one hop, to the furthest peer which answered, each peer being one
+hop further than the one which returned it. The latency is averaged over the
+successful lookups only.
+
+Warnings are printed when the routing table is small or churns quickly, or
+when most lookups fail.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmdkit.ErrClient)
+			return
+		}
+
+		if n.DHTStats == nil {
+			res.SetError(errors.New("the node doesn't route with the DHT"), cmdkit.ErrNormal)
+			return
+		}
+
+		cmds.EmitOnce(res, n.DHTStats.Report())
+	},
+	Type: dhtstats.Report{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			out, ok := v.(*dhtstats.Report)
+			if !ok {
+				return e.TypeErr(out, v)
+			}
+
+			fmt.Fprintf(w, "Routing table: %d peers\n", out.Peers)
+			tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "  Bucket\tPeers")
+			for _, b := range out.Buckets {
+				full := ""
+				if b.Full {
+					full = " (full)"
+				}
+				fmt.Fprintf(tw, "  %d\t%d%s\n", b.CommonPrefix, b.Peers, full)
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(w, "Churn: %d joined, %d left in the last %s (%.1f peers/h)\n\n",
+				out.Joined, out.Left, out.Window.Round(time.Second), out.ChurnRate)
+
+			fmt.Fprintf(w, "Lookups since %s:\n", out.Since.Local().Format("2006-01-02 15:04:05"))
+			tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "  Type\tCount\tSuccess\tAvgLatency\tAvgHops")
+			for _, l := range out.Lookups {
+				if l.Count == 0 {
+					fmt.Fprintf(tw, "  %s\t0\t-\t-\t-\n", l.Type)
+					continue
+				}
+				latency := "-"
+				if l.Succeeded > 0 {
+					latency = l.AvgLatency.Round(time.Millisecond).String()
+				}
+				fmt.Fprintf(tw, "  %s\t%d\t%.1f%%\t%s\t%.1f\n", l.Type, l.Count,
+					100*l.SuccessRatio(), latency, l.AvgHops)
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+
+			if len(out.Warnings) > 0 {
+				fmt.Fprintln(w, "\nWarnings:")
+				for _, warning := range out.Warnings {
+					fmt.Fprintf(w, "  %s\n", warning)
+				}
+			}
+			return nil
+		}),
+	},
+}
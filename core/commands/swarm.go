@@ -2,12 +2,14 @@ package commands
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"path"
 	"sort"
 	"strings"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
@@ -15,9 +17,11 @@ import (
 	config "github.com/ipfs/go-ipfs/repo/config"
 	"github.com/ipfs/go-ipfs/repo/fsrepo"
 
+	host "gx/ipfs/QmQQGtcp6nVUrQjNsnU53YWV1q8fK1Kd9S7FEkYbRZzxry/go-libp2p-host"
 	mafilter "gx/ipfs/QmSMZwvs3n4GBikZ7hKzT17c3bk65FmyZo2JqtJ16swqCv/multiaddr-filter"
 	swarm "gx/ipfs/QmSvhbgtjQJKdT5avEeb7cvjYs7YrhebJyM1K6GAnkKgfd/go-libp2p-swarm"
 	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
 	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
 	iaddr "gx/ipfs/QmckPUj15AbTcLh6MpDEsQpfVCx34tmP2Xg1aNwLb5fiRF/go-ipfs-addr"
 	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
@@ -46,6 +50,7 @@ ipfs peers in the internet.
 		"disconnect": swarmDisconnectCmd,
 		"filters":    swarmFiltersCmd,
 		"peers":      swarmPeersCmd,
+		"peerstore":  swarmPeerstoreCmd,
 	},
 }
 
@@ -362,11 +367,38 @@ var swarmConnectCmd = &cmds.Command{
 The address format is an IPFS multiaddr:
 
 ipfs swarm connect /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+`,
+		LongDescription: `
+'ipfs swarm connect' opens a new direct connection to a peer address.
+
+The address format is an IPFS multiaddr:
+
+ipfs swarm connect /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+
+Several addresses of the same peer can be given; they are dialed together.
+With --transport, only the addresses using the given transports are
+dialed, one transport after the other in the given order:
+
+ipfs swarm connect --transport=quic,tcp $QUIC_ADDR $TCP_ADDR
+
+The addresses the node already knows for the peer are left as they are, and
+may be dialed too: connecting fails when the connection made, or the one the
+peer already has, is over another transport.
+
+--dial-timeout limits each attempt, i.e. each transport with --transport.
+When connecting fails, --dial-errors dials each address on its own and
+prints why each of them failed, e.g. because the address is filtered, the
+peer doesn't listen on it, or another peer answered.
 `,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("address", true, true, "Address of peer to connect to.").EnableStdin(),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("dial-timeout", "Time to wait for each connection attempt, e.g. \"10s\"."),
+		cmdkit.StringOption("transport", "Comma separated transports to dial, in order of preference, e.g. \"quic,tcp\"."),
+		cmdkit.BoolOption("dial-errors", "Report why each address failed when connecting fails."),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		ctx := req.Context()
 
@@ -390,21 +422,52 @@ ipfs swarm connect /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3
 			return
 		}
 
+		var timeout time.Duration
+		if s, found, _ := req.Option("dial-timeout").String(); found {
+			timeout, err = time.ParseDuration(s)
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid dial timeout: %s", err), cmdkit.ErrClient)
+				return
+			}
+		}
+
+		var transports []string
+		if s, found, _ := req.Option("transport").String(); found {
+			transports = strings.Split(s, ",")
+		}
+
+		dialErrors, _, _ := req.Option("dial-errors").Bool()
+
 		pis, err := peersWithAddresses(addrs)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
+		pis = mergePeerInfos(pis)
 
 		output := make([]string, len(pis))
 		for i, pi := range pis {
-			swrm.Backoff().Clear(pi.ID)
-
 			output[i] = "connect " + pi.ID.Pretty()
 
-			err := n.PeerHost.Connect(ctx, pi)
+			attempts, err := dialAttempts(pi, transports)
 			if err != nil {
-				res.SetError(fmt.Errorf("%s failure: %s", output[i], err), cmdkit.ErrNormal)
+				res.SetError(fmt.Errorf("%s failure: %s", output[i], err), cmdkit.ErrClient)
+				return
+			}
+
+			for _, attempt := range attempts {
+				swrm.Backoff().Clear(pi.ID)
+				err = connectOver(ctx, n.PeerHost, attempt, timeout)
+				if err == nil {
+					break
+				}
+			}
+			if err != nil {
+				msg := fmt.Sprintf("%s failure: %s", output[i], err)
+				if dialErrors {
+					msg += dialErrorReport(ctx, swrm, pi, timeout)
+				}
+				res.SetError(errors.New(msg), cmdkit.ErrNormal)
 				return
 			}
 			output[i] += " success"
@@ -418,6 +481,138 @@ ipfs swarm connect /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3
 	Type: stringList{},
 }
 
+// mergePeerInfos merges the infos of the same peers, keeping the order in
+// which the peers first appear.
+func mergePeerInfos(pis []pstore.PeerInfo) []pstore.PeerInfo {
+	var out []pstore.PeerInfo
+	index := make(map[peer.ID]int)
+	for _, pi := range pis {
+		i, ok := index[pi.ID]
+		if !ok {
+			index[pi.ID] = len(out)
+			out = append(out, pstore.PeerInfo{ID: pi.ID})
+			i = len(out) - 1
+		}
+		out[i].Addrs = append(out[i].Addrs, pi.Addrs...)
+	}
+	return out
+}
+
+// dialAttempt is the addresses of a peer dialed together. When transport is
+// set, they all use it and the connection must too.
+type dialAttempt struct {
+	pstore.PeerInfo
+	transport string
+}
+
+// dialAttempts splits the addresses of pi by transport, in the order of
+// transports. Without transports, all addresses are dialed at once.
+func dialAttempts(pi pstore.PeerInfo, transports []string) ([]dialAttempt, error) {
+	if len(transports) == 0 {
+		return []dialAttempt{{PeerInfo: pi}}, nil
+	}
+
+	var attempts []dialAttempt
+	for _, t := range transports {
+		t = strings.TrimSpace(t)
+		if ma.ProtocolWithName(t).Code == 0 {
+			return nil, fmt.Errorf("unknown transport %q", t)
+		}
+
+		attempt := dialAttempt{PeerInfo: pstore.PeerInfo{ID: pi.ID}, transport: t}
+		for _, addr := range pi.Addrs {
+			if hasProtocol(addr, t) {
+				attempt.Addrs = append(attempt.Addrs, addr)
+			}
+		}
+		if len(attempt.Addrs) > 0 {
+			attempts = append(attempts, attempt)
+		}
+	}
+
+	if len(attempts) == 0 {
+		return nil, fmt.Errorf("no address using the transports %s", strings.Join(transports, ", "))
+	}
+	return attempts, nil
+}
+
+func hasProtocol(addr ma.Multiaddr, name string) bool {
+	for _, p := range addr.Protocols() {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// connectOver connects to the peer of a with its addresses, which all use the
+// transport of a, if any. The peerstore, shared with the other dials, is left
+// as it is: the swarm may dial the addresses it knows for the peer too, so
+// the connection is checked to use the transport.
+func connectOver(ctx context.Context, h host.Host, a dialAttempt, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err := h.Connect(ctx, a.PeerInfo); err != nil {
+		return err
+	}
+	if a.transport == "" {
+		return nil
+	}
+
+	var other ma.Multiaddr
+	for _, c := range h.Network().ConnsToPeer(a.ID) {
+		if hasProtocol(c.RemoteMultiaddr(), a.transport) {
+			return nil
+		}
+		other = c.RemoteMultiaddr()
+	}
+	if other == nil {
+		return fmt.Errorf("no connection over %s", a.transport)
+	}
+	return fmt.Errorf("already connected over %s, not %s", other, a.transport)
+}
+
+// dialErrorReport dials each address of pi on its own, without adding the
+// connections to the swarm, and lists the errors.
+func dialErrorReport(ctx context.Context, swrm *swarm.Swarm, pi pstore.PeerInfo, timeout time.Duration) string {
+	buf := new(bytes.Buffer)
+	for _, addr := range pi.Addrs {
+		err := probeAddr(ctx, swrm, pi.ID, addr, timeout)
+		if err == nil {
+			fmt.Fprintf(buf, "\n  %s: dialing on its own succeeded", addr)
+			continue
+		}
+		fmt.Fprintf(buf, "\n  %s: %s", addr, err)
+	}
+	return buf.String()
+}
+
+func probeAddr(ctx context.Context, swrm *swarm.Swarm, p peer.ID, addr ma.Multiaddr, timeout time.Duration) error {
+	if swrm.Filters.AddrBlocked(addr) {
+		return errors.New("address blocked by the swarm filters")
+	}
+
+	tpt := swrm.TransportForDialing(addr)
+	if tpt == nil {
+		return errors.New("no transport for this address")
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	c, err := tpt.Dial(ctx, addr, p)
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
+
 var swarmDisconnectCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Close connection to a given address.",
@@ -827,3 +1022,63 @@ func filtersRemove(r repo.Repo, cfg *config.Config, toRemoveFilters []string) ([
 
 	return removed, nil
 }
+
+var swarmPeerstoreCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the saved peerstore.",
+		ShortDescription: `
+The node saves the addresses and protocols of the peers it knows in its
+datastore, and restores them when it starts. Saved peers expire after
+Swarm.Peerstore.AddrTTL; see docs/config.md.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"gc": swarmPeerstoreGCCmd,
+	},
+}
+
+type PeerstoreGCOutput struct {
+	Removed int
+}
+
+var swarmPeerstoreGCCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove the saved peers which expired.",
+		ShortDescription: `
+'ipfs swarm peerstore gc' removes the peers saved more than
+Swarm.Peerstore.AddrTTL ago from the datastore. The node also does so each
+time it saves the peerstore.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		removed, err := n.PeerstoreGC()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&PeerstoreGCOutput{Removed: removed})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			out, ok := v.(*PeerstoreGCOutput)
+			if !ok {
+				return nil, e.TypeErr(out, v)
+			}
+
+			return strings.NewReader(fmt.Sprintf("removed %d expired peers\n", out.Removed)), nil
+		},
+	},
+	Type: PeerstoreGCOutput{},
+}
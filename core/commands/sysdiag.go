@@ -20,6 +20,7 @@ var sysDiagCmd = &cmds.Command{
 Prints out information about your computer to aid in easier debugging.
 `,
 	},
+	Type: map[string]interface{}{},
 	Run: func(req cmds.Request, res cmds.Response) {
 		info := make(map[string]interface{})
 		err := runtimeInfo(info)
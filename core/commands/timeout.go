@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+type runFunc = func(*cmds.Request, cmds.ResponseEmitter, cmds.Environment)
+
+// wrapRuns replaces the Run function of every command of the tree rooted at
+// cmd with wrap(Run).
+func wrapRuns(cmd *cmds.Command, seen map[*cmds.Command]bool, wrap func(runFunc) runFunc) {
+	if cmd == nil || seen[cmd] {
+		return
+	}
+	seen[cmd] = true
+
+	if cmd.Run != nil {
+		cmd.Run = wrap(cmd.Run)
+	}
+
+	for _, sub := range cmd.Subcommands {
+		wrapRuns(sub, seen, wrap)
+	}
+}
+
+// applyTimeout makes a command honor the global --timeout option: the
+// context of the request gets a deadline, which every operation started
+// with it, from resolving paths to fetching blocks, gives up at.
+//
+// The option is sent along with the other options when the command runs on
+// the daemon, so the deadline applies there too.
+func applyTimeout(run runFunc) runFunc {
+	return func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) {
+		timeoutStr, _ := req.Options[cmds.TimeoutOpt].(string)
+		if timeoutStr == "" {
+			run(req, re, env)
+			return
+		}
+
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil || timeout <= 0 {
+			re.SetError(fmt.Errorf("invalid timeout %q, use a positive duration like 30s or 5m", timeoutStr), cmdkit.ErrClient)
+			return
+		}
+
+		var cancel context.CancelFunc
+		req.Context, cancel = context.WithTimeout(req.Context, timeout)
+		defer cancel()
+
+		run(req, re, env)
+	}
+}
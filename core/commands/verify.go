@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	core "github.com/ipfs/go-ipfs/core"
+	e "github.com/ipfs/go-ipfs/core/commands/e"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	path "github.com/ipfs/go-ipfs/path"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+	cmdkit "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+var VerifyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Compare files with a UnixFS DAG.",
+		ShortDescription: `
+'ipfs verify' compares a file or a directory with the UnixFS DAG at an IPFS
+path, to check a restore or a mirror, and prints the paths differing:
+
+  > ipfs get -o site /ipns/example.com
+  > ipfs verify -r /ipns/example.com ./site
+  content  css/style.css
+  missing  img/logo.png
+  Error: 2 paths differ
+
+The paths are missing from the files, extra files not in the DAG, of another
+type, or of another size or content. The content of the files is hashed as
+the blocks of the DAG are, so that only the blocks holding the structure of
+the DAG are fetched when the files match. Hidden files are skipped, unless
+--hidden is given.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("ipfs-path", true, false, "The path of the DAG to compare with."),
+		cmdkit.FileArg("path", true, false, "The file or directory to compare.").EnableRecursive(),
+	},
+	Options: []cmdkit.Option{
+		cmds.OptionRecursivePath, // a builtin option that allows recursive paths (-r, --recursive)
+		cmdkit.BoolOption(hiddenOptionName, "H", "Include files that are hidden."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) {
+		n, err := GetNode(env)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		p, err := path.ParsePath(req.Arguments[0])
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		nd, err := core.Resolve(req.Context, n.Namesys, n.Resolver, p)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		f, err := req.Files.NextFile()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		hidden, _ := req.Options[hiddenOptionName].(bool)
+
+		out := make(chan interface{})
+		errCh := make(chan error, 1)
+		mismatches := 0
+		go func() {
+			defer close(out)
+			errCh <- coreunix.Verify(req.Context, n.DAG, nd, f, hidden, func(m coreunix.Mismatch) error {
+				mismatches++
+				select {
+				case out <- &m:
+					return nil
+				case <-req.Context.Done():
+					return req.Context.Err()
+				}
+			})
+		}()
+
+		defer res.Close()
+		if err := res.Emit(out); err != nil {
+			log.Error(err)
+			return
+		}
+		if err := <-errCh; err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if mismatches > 0 {
+			res.SetError(fmt.Errorf("%d paths differ", mismatches), cmdkit.ErrNormal)
+		}
+	},
+	Type: coreunix.Mismatch{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			m, ok := v.(*coreunix.Mismatch)
+			if !ok {
+				return e.TypeErr(m, v)
+			}
+			_, err := fmt.Fprintf(w, "%-8s %s\n", m.Reason, m.Path)
+			return err
+		}),
+	},
+}
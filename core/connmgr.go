@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"sync"
+
+	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	inet "gx/ipfs/QmXdgNhVEgjLxjUoMs5ViQL7pboAt3Y7V7eGHRiE4qrmTE/go-libp2p-net"
+	ifconnmgr "gx/ipfs/Qmav3fJzdn43FDvHyGkPdbQ5JVqqiDPmNdnuGa3vatpmwj/go-libp2p-interface-connmgr"
+)
+
+// reloadableConnMgr is the connection manager given to the host. It forwards
+// to the manager built from Swarm.ConnMgr, which can be replaced when the
+// config is reloaded.
+type reloadableConnMgr struct {
+	mu sync.RWMutex
+	cm ifconnmgr.ConnManager
+}
+
+func newReloadableConnMgr(cm ifconnmgr.ConnManager) *reloadableConnMgr {
+	r := &reloadableConnMgr{}
+	r.set(cm)
+	return r
+}
+
+func (r *reloadableConnMgr) set(cm ifconnmgr.ConnManager) {
+	if cm == nil {
+		cm = ifconnmgr.NullConnMgr{}
+	}
+	r.mu.Lock()
+	r.cm = cm
+	r.mu.Unlock()
+}
+
+func (r *reloadableConnMgr) current() ifconnmgr.ConnManager {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cm
+}
+
+// Replace switches to cm. The tags and the open connections of the network
+// are handed over to the new manager so that it can trim them.
+func (r *reloadableConnMgr) Replace(cm ifconnmgr.ConnManager, net inet.Network) {
+	old := r.current()
+	r.set(cm)
+	cm = r.current()
+
+	for _, p := range net.Peers() {
+		if info := old.GetTagInfo(p); info != nil {
+			for tag, v := range info.Tags {
+				cm.TagPeer(p, tag, v)
+			}
+		}
+	}
+	nf := cm.Notifee()
+	for _, c := range net.Conns() {
+		nf.Connected(net, c)
+	}
+}
+
+func (r *reloadableConnMgr) TagPeer(p peer.ID, tag string, v int) {
+	r.current().TagPeer(p, tag, v)
+}
+
+func (r *reloadableConnMgr) UntagPeer(p peer.ID, tag string) {
+	r.current().UntagPeer(p, tag)
+}
+
+func (r *reloadableConnMgr) GetTagInfo(p peer.ID) *ifconnmgr.TagInfo {
+	return r.current().GetTagInfo(p)
+}
+
+func (r *reloadableConnMgr) TrimOpenConns(ctx context.Context) {
+	r.current().TrimOpenConns(ctx)
+}
+
+func (r *reloadableConnMgr) Notifee() inet.Notifiee {
+	return &inet.NotifyBundle{
+		ListenF: func(n inet.Network, a ma.Multiaddr) {
+			r.current().Notifee().Listen(n, a)
+		},
+		ListenCloseF: func(n inet.Network, a ma.Multiaddr) {
+			r.current().Notifee().ListenClose(n, a)
+		},
+		ConnectedF: func(n inet.Network, c inet.Conn) {
+			r.current().Notifee().Connected(n, c)
+		},
+		DisconnectedF: func(n inet.Network, c inet.Conn) {
+			r.current().Notifee().Disconnected(n, c)
+		},
+		OpenedStreamF: func(n inet.Network, s inet.Stream) {
+			r.current().Notifee().OpenedStream(n, s)
+		},
+		ClosedStreamF: func(n inet.Network, s inet.Stream) {
+			r.current().Notifee().ClosedStream(n, s)
+		},
+	}
+}
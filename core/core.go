@@ -18,23 +18,38 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	bserv "github.com/ipfs/go-ipfs/blockservice"
+	bootstrap "github.com/ipfs/go-ipfs/bootstrap"
+	denylist "github.com/ipfs/go-ipfs/denylist"
+	dhtstats "github.com/ipfs/go-ipfs/dhtstats"
+	events "github.com/ipfs/go-ipfs/events"
 	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
 	bsnet "github.com/ipfs/go-ipfs/exchange/bitswap/network"
+	httpfetch "github.com/ipfs/go-ipfs/exchange/httpfetch"
 	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
 	filestore "github.com/ipfs/go-ipfs/filestore"
 	mount "github.com/ipfs/go-ipfs/fuse/mount"
 	merkledag "github.com/ipfs/go-ipfs/merkledag"
 	mfs "github.com/ipfs/go-ipfs/mfs"
 	namesys "github.com/ipfs/go-ipfs/namesys"
+	nsopts "github.com/ipfs/go-ipfs/namesys/opts"
 	ipnsrp "github.com/ipfs/go-ipfs/namesys/republisher"
 	p2p "github.com/ipfs/go-ipfs/p2p"
 	"github.com/ipfs/go-ipfs/path/resolver"
+	peerstore "github.com/ipfs/go-ipfs/peerstore"
 	pin "github.com/ipfs/go-ipfs/pin"
+	replication "github.com/ipfs/go-ipfs/replication"
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
+	diskwatch "github.com/ipfs/go-ipfs/repo/diskwatch"
+	ephemeral "github.com/ipfs/go-ipfs/repo/ephemeral"
+	growth "github.com/ipfs/go-ipfs/repo/growth"
+	pininfo "github.com/ipfs/go-ipfs/repo/pininfo"
+	quota "github.com/ipfs/go-ipfs/repo/quota"
+	usage "github.com/ipfs/go-ipfs/repo/usage"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 
 	mafilter "gx/ipfs/QmNey9DW3QjsNh7tLfroFhk3994k99PC5Ta6aqCNA6hwYZ/go-maddr-filter"
@@ -123,6 +138,15 @@ type IpfsNode struct {
 	Reporter   metrics.Reporter
 	Discovery  discovery.Service
 	FilesRoot  *mfs.Root
+	Events     *events.Bus      // the node event bus
+	Denylist   *denylist.Set    // the content denylist
+	Growth     *growth.Tracker  // counts the blocks added and removed each day
+	Quotas     *quota.Store     // the DAGs pinned by each API token, for their quotas
+	Ephemeral  *ephemeral.Store // the DAGs kept from GC until they expire
+	PinInfo    *pininfo.Store   // when and how each pin was created
+	Usage      *usage.Tracker   // the usage of the repo by category
+
+	DiskWatchdog *diskwatch.Watchdog // keeps the repo from filling its disk, if configured
 
 	// Online
 	PeerHost     p2phost.Host        // the network host (server+client)
@@ -134,13 +158,30 @@ type IpfsNode struct {
 	Reprovider   *rp.Reprovider // the value reprovider system
 	IpnsRepub    *ipnsrp.Republisher
 
+	RecordValidator record.NamespacedValidator // validates the records of the routing system
+	DHTStats        *dhtstats.Stats            // the routing table and lookup statistics, with the DHT
+
+	PeerstorePersister *peerstore.Persister // saves the peerstore, unless disabled
+	BootstrapHealth    *bootstrap.Health    // the reachability of the bootstrap peers
+
 	Floodsub *floodsub.PubSub
 	PSRouter *psrouter.PubsubValueStore
 	P2P      *p2p.P2P
 
+	Replication *replication.Pusher // pushes the MFS root to the replicas, if any
+
 	proc goprocess.Process
 	ctx  context.Context
 
+	connMgr       *reloadableConnMgr
+	blockCache    *reloadableBlockstore
+	bwLimiter     *bandwidthLimiter
+	dhtHost       *dhtHost               // the host of the DHT, to stop serving it in power save
+	power         powerSaver             // the power save state
+	reloadMu      sync.Mutex             // serializes config reloads
+	appliedConfig map[string]interface{} // the config the node runs with
+	bootstrapped  int32                  // set once the first bootstrap round ran
+
 	mode         mode
 	localModeSet bool
 }
@@ -231,9 +272,15 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 	if err != nil {
 		return err
 	}
-	libp2pOpts = append(libp2pOpts, libp2p.ConnectionManager(connm))
+	n.connMgr = newReloadableConnMgr(connm)
+	libp2pOpts = append(libp2pOpts, libp2p.ConnectionManager(n.connMgr))
 
-	libp2pOpts = append(libp2pOpts, makeSmuxTransportOption(mplex))
+	n.bwLimiter, err = newBandwidthLimiter(cfg.Swarm.Bandwidth)
+	if err != nil {
+		return err
+	}
+
+	libp2pOpts = append(libp2pOpts, makeSmuxTransportOption(mplex, n.bwLimiter))
 
 	if !cfg.Swarm.DisableNatPortMap {
 		libp2pOpts = append(libp2pOpts, libp2p.NATPortMap())
@@ -246,6 +293,11 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		libp2pOpts = append(libp2pOpts, libp2p.EnableRelay(opts...))
 	}
 
+	restored, err := n.setupPeerstorePersistence(cfg.Swarm.Peerstore)
+	if err != nil {
+		return err
+	}
+
 	peerhost, err := hostOption(ctx, n.Identity, n.Peerstore, libp2pOpts...)
 
 	if err != nil {
@@ -256,6 +308,8 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		return err
 	}
 
+	n.PeerHost.Network().Notify(connEventNotifee(n.Events))
+
 	// Ok, now we're ready to listen.
 	if err := startListening(n.PeerHost, cfg); err != nil {
 		return err
@@ -263,6 +317,8 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 
 	n.P2P = p2p.NewP2P(n.Identity, n.PeerHost, n.Peerstore)
 
+	n.dialRestoredPeers(restored)
+
 	// setup local discovery
 	if do != nil {
 		service, err := do(ctx, n.PeerHost)
@@ -274,6 +330,9 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		}
 	}
 
+	if err := n.setupBootstrapHealth(); err != nil {
+		return err
+	}
 	return n.Bootstrap(DefaultBootstrapConfig)
 }
 
@@ -316,7 +375,21 @@ func (n *IpfsNode) startLateOnlineServices(ctx context.Context) error {
 	default:
 		return fmt.Errorf("unknown reprovider strategy '%s'", cfg.Reprovider.Strategy)
 	}
-	n.Reprovider = rp.NewReprovider(ctx, n.Routing, keyProvider)
+	// the blocks the reprovider walks are fetched in the background, after
+	// the ones users wait on
+	rpctx := bitswap.WithFetchPriority(ctx, bitswap.BackgroundFetch)
+	n.Reprovider = rp.NewReprovider(rpctx, n.Routing, keyProvider)
+
+	switch cfg.Reprovider.Schedule {
+	case "", "spread":
+		n.Reprovider.Spread = true
+	case "burst":
+	default:
+		return fmt.Errorf("unknown reprovider schedule '%s'", cfg.Reprovider.Schedule)
+	}
+	if bs, ok := n.Exchange.(*bitswap.Bitswap); ok {
+		bs.SetServeObserver(n.Reprovider.Requested)
+	}
 
 	reproviderInterval := kReprovideFrequency
 	if cfg.Reprovider.Interval != "" {
@@ -330,7 +403,7 @@ func (n *IpfsNode) startLateOnlineServices(ctx context.Context) error {
 
 	go n.Reprovider.Run(reproviderInterval)
 
-	return nil
+	return n.setupPowerSave(cfg)
 }
 
 func makeAddrsFactory(cfg config.Addresses) (p2pbhost.AddrsFactory, error) {
@@ -379,7 +452,7 @@ func makeAddrsFactory(cfg config.Addresses) (p2pbhost.AddrsFactory, error) {
 	}, nil
 }
 
-func makeSmuxTransportOption(mplexExp bool) libp2p.Option {
+func makeSmuxTransportOption(mplexExp bool, limiter *bandwidthLimiter) libp2p.Option {
 	const yamuxID = "/yamux/1.0.0"
 	const mplexID = "/mplex/6.7.0"
 
@@ -415,7 +488,7 @@ func makeSmuxTransportOption(mplexExp bool) libp2p.Option {
 			continue
 		}
 		delete(muxers, id)
-		opts = append(opts, libp2p.Muxer(id, tpt))
+		opts = append(opts, libp2p.Muxer(id, &limitedMuxer{tpt, limiter}))
 	}
 
 	return libp2p.ChainOptions(opts...)
@@ -458,18 +531,20 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 		n.Floodsub = service
 	}
 
-	validator := record.NamespacedValidator{
-		"pk":   record.PublicKeyValidator{},
-		"ipns": namesys.IpnsValidator{KeyBook: host.Peerstore()},
-	}
+	validator := newRecordValidator(host.Peerstore())
+	n.RecordValidator = validator
 
 	// setup routing service
-	r, err := routingOption(ctx, host, n.Repo.Datastore(), validator)
+	n.dhtHost = newDHTHost(host)
+	r, err := routingOption(ctx, n.dhtHost, n.Repo.Datastore(), validator)
 	if err != nil {
 		return err
 	}
 	n.Routing = r
 
+	// the services of the node look up through lookups, measuring them
+	lookups := n.setupDHTStats(host, r)
+
 	if ipnsps {
 		n.PSRouter = psrouter.NewPubsubValueStore(
 			ctx,
@@ -486,44 +561,91 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 					Namespaces: []string{"ipns"},
 				},
 			},
-			n.Routing,
+			lookups,
 		}
+		lookups = n.Routing
 	}
 
 	// Wrap standard peer host with routing system to allow unknown peer lookups
-	n.PeerHost = rhost.Wrap(host, n.Routing)
+	n.PeerHost = rhost.Wrap(host, lookups)
 
 	// setup exchange service
-	bitswapNetwork := bsnet.NewFromIpfsHost(n.PeerHost, n.Routing)
+	bitswapNetwork := bsnet.NewFromIpfsHost(n.PeerHost, lookups)
 	n.Exchange = bitswap.New(ctx, bitswapNetwork, n.Blockstore)
 
-	size, err := n.getCacheSize()
+	if err := n.setupHTTPProviders(); err != nil {
+		return err
+	}
+
+	policy, err := n.ResolvePolicy()
 	if err != nil {
 		return err
 	}
 
 	// setup name system
-	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), size)
+	n.Namesys = namesys.NewNameSystemWithPolicy(lookups, n.Repo.Datastore(), policy)
 
 	// setup ipns republishing
 	return n.setupIpnsRepublisher()
 }
 
-// getCacheSize returns cache life and cache size
-func (n *IpfsNode) getCacheSize() (int, error) {
+// setupHTTPProviders makes bitswap fetch blocks from the providers with an
+// HTTP multiaddr as well, when enabled.
+func (n *IpfsNode) setupHTTPProviders() error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+	if !cfg.Experimental.HTTPProvidersEnabled {
+		return nil
+	}
+
+	bs, ok := n.Exchange.(*bitswap.Bitswap)
+	if !ok {
+		return nil
+	}
+	bs.SetFetcher(httpfetch.New(n.Routing, bs.HasBlock))
+	return nil
+}
+
+// ResolvePolicy returns how the node resolves names by default, from the
+// Ipns config.
+func (n *IpfsNode) ResolvePolicy() (namesys.ResolvePolicy, error) {
+	var policy namesys.ResolvePolicy
 	cfg, err := n.Repo.Config()
 	if err != nil {
-		return 0, err
+		return policy, err
+	}
+
+	policy.CacheSize = cfg.Ipns.ResolveCacheSize
+	if policy.CacheSize == 0 {
+		policy.CacheSize = 128
+	}
+	if policy.CacheSize < 0 {
+		return policy, fmt.Errorf("cannot specify negative resolve cache size")
 	}
 
-	cs := cfg.Ipns.ResolveCacheSize
-	if cs == 0 {
-		cs = 128
+	if cfg.Ipns.ResolveCacheTTL != "" {
+		ttl, err := time.ParseDuration(cfg.Ipns.ResolveCacheTTL)
+		if err != nil {
+			return policy, fmt.Errorf("failure to parse config setting Ipns.ResolveCacheTTL: %s", err)
+		}
+		policy.Options = append(policy.Options, nsopts.CacheTTL(ttl))
 	}
-	if cs < 0 {
-		return 0, fmt.Errorf("cannot specify negative resolve cache size")
+	if cfg.Ipns.ResolveRecordCount < 0 {
+		return policy, fmt.Errorf("cannot specify negative resolve record count")
 	}
-	return cs, nil
+	if cfg.Ipns.ResolveRecordCount > 0 {
+		policy.Options = append(policy.Options, nsopts.DhtRecordCount(uint(cfg.Ipns.ResolveRecordCount)))
+	}
+	if cfg.Ipns.ResolveTimeout != "" {
+		timeout, err := time.ParseDuration(cfg.Ipns.ResolveTimeout)
+		if err != nil {
+			return policy, fmt.Errorf("failure to parse config setting Ipns.ResolveTimeout: %s", err)
+		}
+		policy.Options = append(policy.Options, nsopts.DhtTimeout(timeout))
+	}
+	return policy, nil
 }
 
 func (n *IpfsNode) setupIpnsRepublisher() error {
@@ -579,6 +701,26 @@ func (n *IpfsNode) Context() context.Context {
 	return n.ctx
 }
 
+// Checkpoint saves the state kept in memory: the changes to the MFS tree,
+// published as its root, and the pins. The daemon calls it on shutdown, once
+// the requests in flight are done.
+func (n *IpfsNode) Checkpoint(ctx context.Context) error {
+	if n.FilesRoot != nil {
+		if dir, ok := n.FilesRoot.GetValue().(*mfs.Directory); ok {
+			if err := dir.Flush(); err != nil {
+				return err
+			}
+		}
+		if err := n.FilesRoot.Sync(ctx); err != nil {
+			return err
+		}
+	}
+	if n.Pinning != nil {
+		return n.Pinning.Flush()
+	}
+	return nil
+}
+
 // teardown closes owned children. If any errors occur, this function returns
 // the first error.
 func (n *IpfsNode) teardown() error {
@@ -618,10 +760,30 @@ func (n *IpfsNode) teardown() error {
 		closers = append(closers, n.Bootstrapper)
 	}
 
+	if n.BootstrapHealth != nil {
+		closers = append(closers, n.BootstrapHealth)
+	}
+
 	if n.PeerHost != nil {
 		closers = append(closers, n.PeerHost)
 	}
 
+	if n.Events != nil {
+		closers = append(closers, n.Events)
+	}
+
+	if n.Denylist != nil {
+		closers = append(closers, n.Denylist)
+	}
+
+	if n.Growth != nil {
+		closers = append(closers, n.Growth)
+	}
+
+	if n.Usage != nil {
+		closers = append(closers, n.Usage)
+	}
+
 	// Repo closed last, most things need to preserve state here
 	closers = append(closers, n.Repo)
 
@@ -679,9 +841,17 @@ func (n *IpfsNode) Bootstrap(cfg BootstrapConfig) error {
 				log.Warning("failed to parse bootstrap peers from config")
 				return nil
 			}
+			if n.BootstrapHealth != nil {
+				// dial the cached addresses of the /dnsaddr entries,
+				// resolved by the health checks
+				ps = n.BootstrapHealth.Cached(ps)
+			}
 			return ps
 		}
 	}
+	if cfg.Dead == nil && n.BootstrapHealth != nil {
+		cfg.Dead = n.BootstrapHealth.Dead
+	}
 
 	var err error
 	n.Bootstrapper, err = Bootstrap(n, cfg)
@@ -765,7 +935,11 @@ func (n *IpfsNode) loadBootstrapPeers() ([]pstore.PeerInfo, error) {
 func (n *IpfsNode) loadFilesRoot() error {
 	dsk := ds.NewKey("/local/filesroot")
 	pf := func(ctx context.Context, c *cid.Cid) error {
-		return n.Repo.Datastore().Put(dsk, c.Bytes())
+		if err := n.Repo.Datastore().Put(dsk, c.Bytes()); err != nil {
+			return err
+		}
+		n.Events.Emit(events.FilesPublished, map[string]string{"cid": c.String()})
+		return nil
 	}
 
 	var nd *merkledag.ProtoNode
@@ -823,12 +997,12 @@ func (n *IpfsNode) SetupOfflineRouting() error {
 
 	n.Routing = offroute.NewOfflineRouter(n.Repo.Datastore(), n.PrivateKey)
 
-	size, err := n.getCacheSize()
+	policy, err := n.ResolvePolicy()
 	if err != nil {
 		return err
 	}
 
-	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), size)
+	n.Namesys = namesys.NewNameSystemWithPolicy(n.Routing, n.Repo.Datastore(), policy)
 
 	return nil
 }
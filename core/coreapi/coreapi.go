@@ -5,6 +5,7 @@ import (
 
 	core "github.com/ipfs/go-ipfs/core"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	ipfspath "github.com/ipfs/go-ipfs/path"
 	resolver "github.com/ipfs/go-ipfs/path/resolver"
@@ -59,14 +60,27 @@ func (api *CoreAPI) Pin() coreiface.PinAPI {
 	return (*PinAPI)(api)
 }
 
+// Swarm returns the SwarmAPI interface implementation backed by the go-ipfs node
+func (api *CoreAPI) Swarm() coreiface.SwarmAPI {
+	return (*SwarmAPI)(api)
+}
+
+// Routing returns the RoutingAPI interface implementation backed by the go-ipfs node
+func (api *CoreAPI) Routing() coreiface.RoutingAPI {
+	return (*RoutingAPI)(api)
+}
+
 // ResolveNode resolves the path `p` using Unixfx resolver, gets and returns the
 // resolved Node.
 func (api *CoreAPI) ResolveNode(ctx context.Context, p coreiface.Path) (ipld.Node, error) {
+	if err := api.node.Denylist.Check(p.String()); err != nil {
+		return nil, err
+	}
 	return resolveNode(ctx, api.node.DAG, api.node.Namesys, p)
 }
 
 func resolveNode(ctx context.Context, ng ipld.NodeGetter, nsys namesys.NameSystem, p coreiface.Path) (ipld.Node, error) {
-	p, err := resolvePath(ctx, ng, nsys, p)
+	p, err := resolvePath(ctx, ng, nsys, p, false)
 	if err != nil {
 		return nil, err
 	}
@@ -81,11 +95,18 @@ func resolveNode(ctx context.Context, ng ipld.NodeGetter, nsys namesys.NameSyste
 // ResolvePath resolves the path `p` using Unixfs resolver, returns the
 // resolved path.
 // TODO: store all of ipfspath.Resolver.ResolvePathComponents() in Path
-func (api *CoreAPI) ResolvePath(ctx context.Context, p coreiface.Path) (coreiface.Path, error) {
-	return resolvePath(ctx, api.node.DAG, api.node.Namesys, p)
+func (api *CoreAPI) ResolvePath(ctx context.Context, p coreiface.Path, opts ...caopts.ResolveOption) (coreiface.Path, error) {
+	settings, err := caopts.ResolveOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.node.Denylist.Check(p.String()); err != nil {
+		return nil, err
+	}
+	return resolvePath(ctx, api.node.DAG, api.node.Namesys, p, settings.FollowSymlinks)
 }
 
-func resolvePath(ctx context.Context, ng ipld.NodeGetter, nsys namesys.NameSystem, p coreiface.Path) (coreiface.Path, error) {
+func resolvePath(ctx context.Context, ng ipld.NodeGetter, nsys namesys.NameSystem, p coreiface.Path, followSymlinks bool) (coreiface.Path, error) {
 	if p.Resolved() {
 		return p, nil
 	}
@@ -94,6 +115,9 @@ func resolvePath(ctx context.Context, ng ipld.NodeGetter, nsys namesys.NameSyste
 		DAG:         ng,
 		ResolveOnce: uio.ResolveUnixfsOnce,
 	}
+	if followSymlinks {
+		r.Symlink = uio.SymlinkTarget
+	}
 
 	p2 := ipfspath.FromString(p.String())
 	node, err := core.Resolve(ctx, nsys, r, p2)
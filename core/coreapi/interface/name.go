@@ -14,6 +14,16 @@ type IpnsEntry interface {
 	Value() Path
 }
 
+// IpnsPublishResult is the result of publishing under one of several keys.
+type IpnsPublishResult struct {
+	// Key is the key, as given to PublishMany.
+	Key string
+	// Entry is the published entry, or nil if publishing failed.
+	Entry IpnsEntry
+	// Err is the error publishing under the key, if any.
+	Err error
+}
+
 // NameAPI specifies the interface to IPNS.
 //
 // IPNS is a PKI namespace, where names are the hashes of public keys, and the
@@ -26,6 +36,11 @@ type NameAPI interface {
 	// Publish announces new IPNS name
 	Publish(ctx context.Context, path Path, opts ...options.NamePublishOption) (IpnsEntry, error)
 
+	// PublishMany publishes the same path under each of keys in parallel,
+	// resolving it once, and returns the result of each key in the order of
+	// keys. The Key option is ignored.
+	PublishMany(ctx context.Context, path Path, keys []string, opts ...options.NamePublishOption) ([]IpnsPublishResult, error)
+
 	// Resolve attempts to resolve the newest version of the specified name
 	Resolve(ctx context.Context, name string, opts ...options.NameResolveOption) (Path, error)
 }
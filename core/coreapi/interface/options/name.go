@@ -11,12 +11,17 @@ const (
 type NamePublishSettings struct {
 	ValidTime time.Duration
 	Key       string
+
+	TTL *time.Duration
 }
 
 type NameResolveSettings struct {
 	Recursive bool
 	Local     bool
 	Cache     bool
+
+	DhtRecordCount uint
+	DhtTimeout     *time.Duration
 }
 
 type NamePublishOption func(*NamePublishSettings) error
@@ -68,6 +73,17 @@ func (nameOpts) ValidTime(validTime time.Duration) NamePublishOption {
 	}
 }
 
+// TTL is an option for Name.Publish which specifies for how long resolvers
+// may cache the entry. By default, the TTL of the namesys is used.
+//
+// Note: experimental, as 'ipfs name publish --ttl'
+func (nameOpts) TTL(ttl time.Duration) NamePublishOption {
+	return func(settings *NamePublishSettings) error {
+		settings.TTL = &ttl
+		return nil
+	}
+}
+
 // Key is an option for Name.Publish which specifies the key to use for
 // publishing. Default value is "self" which is the node's own PeerID.
 // The key parameter must be either PeerID or keystore key alias.
@@ -106,3 +122,23 @@ func (nameOpts) Cache(cache bool) NameResolveOption {
 		return nil
 	}
 }
+
+// DhtRecordCount is an option for Name.Resolve which specifies the number of
+// IPNS records to wait for before selecting the best one. By default, the
+// Ipns.ResolveRecordCount of the node is used.
+func (nameOpts) DhtRecordCount(count uint) NameResolveOption {
+	return func(settings *NameResolveSettings) error {
+		settings.DhtRecordCount = count
+		return nil
+	}
+}
+
+// DhtTimeout is an option for Name.Resolve which specifies the longest time
+// to wait for the IPNS records, no limit when 0. By default, the
+// Ipns.ResolveTimeout of the node is used.
+func (nameOpts) DhtTimeout(timeout time.Duration) NameResolveOption {
+	return func(settings *NameResolveSettings) error {
+		settings.DhtTimeout = &timeout
+		return nil
+	}
+}
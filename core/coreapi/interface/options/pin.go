@@ -2,6 +2,7 @@ package options
 
 type PinAddSettings struct {
 	Recursive bool
+	Progress  chan<- ProgressEvent
 }
 
 type PinLsSettings struct {
@@ -102,6 +103,16 @@ func (pinOpts) Recursive(recursive bool) PinAddOption {
 	}
 }
 
+// Progress is an option for Pin.Add which sends the number of nodes fetched
+// to the channel, twice a second and once done. The call waits for the
+// events to be received, and doesn't close the channel.
+func (pinOpts) Progress(ch chan<- ProgressEvent) PinAddOption {
+	return func(settings *PinAddSettings) error {
+		settings.Progress = ch
+		return nil
+	}
+}
+
 // Type is an option for Pin.Ls which allows to specify which pin types should
 // be returned
 //
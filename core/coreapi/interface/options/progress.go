@@ -0,0 +1,15 @@
+package options
+
+// ProgressEvent reports the progress of a long running call made with a
+// Progress option, like Unixfs.Progress or Pin.Progress.
+type ProgressEvent struct {
+	// Name is the path of the file being imported, relative to the
+	// directory given to Unixfs.AddMany. It is empty for the other calls.
+	Name string
+
+	// Bytes is the number of bytes of the file imported so far.
+	Bytes int64
+
+	// Nodes is the number of nodes fetched or processed so far.
+	Nodes int
+}
@@ -0,0 +1,35 @@
+package options
+
+type ResolveSettings struct {
+	FollowSymlinks bool
+}
+
+type ResolveOption func(*ResolveSettings) error
+
+func ResolveOptions(opts ...ResolveOption) (*ResolveSettings, error) {
+	options := &ResolveSettings{
+		FollowSymlinks: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type resolveOpts struct{}
+
+var Resolve resolveOpts
+
+// FollowSymlinks is an option for ResolvePath which specifies whether the
+// unixfs symlinks of the path are followed to their targets, at most
+// resolver.MaxSymlinks of them. Default: false
+func (resolveOpts) FollowSymlinks(follow bool) ResolveOption {
+	return func(settings *ResolveSettings) error {
+		settings.FollowSymlinks = follow
+		return nil
+	}
+}
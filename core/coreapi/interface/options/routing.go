@@ -0,0 +1,127 @@
+package options
+
+import "fmt"
+
+// RecordValidator checks a routing record. An error rejects the record.
+type RecordValidator func(key string, value []byte) error
+
+type RoutingGetSettings struct {
+	Validators []RecordValidator
+}
+
+type RoutingPutSettings struct {
+	Validators []RecordValidator
+}
+
+type RoutingProvideSettings struct {
+	Recursive bool
+}
+
+type RoutingFindProvidersSettings struct {
+	NumProviders int
+}
+
+type RoutingGetOption func(*RoutingGetSettings) error
+type RoutingPutOption func(*RoutingPutSettings) error
+type RoutingProvideOption func(*RoutingProvideSettings) error
+type RoutingFindProvidersOption func(*RoutingFindProvidersSettings) error
+
+func RoutingGetOptions(opts ...RoutingGetOption) (*RoutingGetSettings, error) {
+	options := &RoutingGetSettings{}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
+func RoutingPutOptions(opts ...RoutingPutOption) (*RoutingPutSettings, error) {
+	options := &RoutingPutSettings{}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
+func RoutingProvideOptions(opts ...RoutingProvideOption) (*RoutingProvideSettings, error) {
+	options := &RoutingProvideSettings{
+		Recursive: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
+func RoutingFindProvidersOptions(opts ...RoutingFindProvidersOption) (*RoutingFindProvidersSettings, error) {
+	options := &RoutingFindProvidersSettings{
+		NumProviders: 20,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
+type routingOpts struct{}
+
+var Routing routingOpts
+
+// GetValidator is an option for Routing.Get which checks the record found
+// before returning it. It is called after the validation done by the
+// routing system, and can be given several times.
+func (routingOpts) GetValidator(v RecordValidator) RoutingGetOption {
+	return func(settings *RoutingGetSettings) error {
+		settings.Validators = append(settings.Validators, v)
+		return nil
+	}
+}
+
+// PutValidator is an option for Routing.Put which checks the record before
+// it is put. It can be given several times.
+func (routingOpts) PutValidator(v RecordValidator) RoutingPutOption {
+	return func(settings *RoutingPutSettings) error {
+		settings.Validators = append(settings.Validators, v)
+		return nil
+	}
+}
+
+// Recursive is an option for Routing.Provide which makes it announce every
+// block of the DAG, not only the root. Default is false.
+func (routingOpts) Recursive(recursive bool) RoutingProvideOption {
+	return func(settings *RoutingProvideSettings) error {
+		settings.Recursive = recursive
+		return nil
+	}
+}
+
+// NumProviders is an option for Routing.FindProviders which specifies how
+// many providers to look for at most. Default is 20.
+func (routingOpts) NumProviders(n int) RoutingFindProvidersOption {
+	return func(settings *RoutingFindProvidersSettings) error {
+		if n <= 0 {
+			return fmt.Errorf("number of providers must be positive, got %d", n)
+		}
+		settings.NumProviders = n
+		return nil
+	}
+}
@@ -0,0 +1,255 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// Layout is the shape of the DAG files are imported into.
+type Layout int
+
+const (
+	BalancedLayout Layout = iota
+	TrickleLayout
+)
+
+type UnixfsAddSettings struct {
+	CidVersion int
+	MhType     uint64
+
+	RawLeaves    bool
+	RawLeavesSet bool
+
+	Chunker string
+	Layout  Layout
+
+	Pin      bool
+	OnlyHash bool
+	Local    bool
+
+	Workers int
+
+	Progress chan<- ProgressEvent
+}
+
+type UnixfsAddOption func(*UnixfsAddSettings) error
+
+type UnixfsLsSettings struct {
+	After string
+	Limit int
+}
+
+type UnixfsLsOption func(*UnixfsLsSettings) error
+
+func UnixfsLsOptions(opts ...UnixfsLsOption) (*UnixfsLsSettings, error) {
+	options := &UnixfsLsSettings{
+		After: "",
+		Limit: 0,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
+// UnixfsAddOptions applies the options and resolves them the way 'ipfs add'
+// does: a hash function other than sha2-256 implies CIDv1, and CIDv1 implies
+// raw leaves unless they were set explicitly. It returns the settings and
+// the CID prefix of the nodes to create.
+func UnixfsAddOptions(opts ...UnixfsAddOption) (*UnixfsAddSettings, cid.Prefix, error) {
+	options := &UnixfsAddSettings{
+		CidVersion: -1,
+		MhType:     mh.SHA2_256,
+
+		RawLeaves:    false,
+		RawLeavesSet: false,
+
+		Chunker: "size-262144",
+		Layout:  BalancedLayout,
+
+		Pin:      false,
+		OnlyHash: false,
+		Local:    false,
+
+		Workers: 4,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, cid.Prefix{}, err
+		}
+	}
+
+	// (hash != "sha2-256") -> CIDv1
+	if options.MhType != mh.SHA2_256 {
+		switch options.CidVersion {
+		case 0:
+			return nil, cid.Prefix{}, errors.New("CIDv0 only supports sha2-256")
+		case 1, -1:
+			options.CidVersion = 1
+		default:
+			return nil, cid.Prefix{}, fmt.Errorf("unknown CID version: %d", options.CidVersion)
+		}
+	} else if options.CidVersion < 0 {
+		// Default to CIDv0
+		options.CidVersion = 0
+	}
+
+	// cidV1 -> raw blocks (by default)
+	if options.CidVersion > 0 && !options.RawLeavesSet {
+		options.RawLeaves = true
+	}
+
+	prefix, err := dag.PrefixForCidVersion(options.CidVersion)
+	if err != nil {
+		return nil, cid.Prefix{}, err
+	}
+
+	prefix.MhType = options.MhType
+	prefix.MhLength = -1
+
+	return options, prefix, nil
+}
+
+type unixfsOpts struct{}
+
+var Unixfs unixfsOpts
+
+// CidVersion is an option for Unixfs.Add which specifies which CID version
+// to use. Default is 0, unless a hash function other than sha2-256 is used.
+func (unixfsOpts) CidVersion(version int) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.CidVersion = version
+		return nil
+	}
+}
+
+// Hash is an option for Unixfs.Add which specifies which hash function to
+// use, e.g. mh.SHA2_256 (0x12), the default. Using any other hash function
+// implies CIDv1.
+func (unixfsOpts) Hash(mhtype uint64) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.MhType = mhtype
+		return nil
+	}
+}
+
+// RawLeaves is an option for Unixfs.Add which specifies whether to use raw
+// blocks for the leaves (data nodes). Default is false with CIDv0 and true
+// with CIDv1.
+func (unixfsOpts) RawLeaves(enable bool) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.RawLeaves = enable
+		settings.RawLeavesSet = true
+		return nil
+	}
+}
+
+// Chunker is an option for Unixfs.Add which specifies how the data is split
+// into blocks, as with 'ipfs add --chunker': "size-<bytes>" or
+// "rabin-<min>-<avg>-<max>". Default is "size-262144".
+func (unixfsOpts) Chunker(chunker string) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Chunker = chunker
+		return nil
+	}
+}
+
+// Layout is an option for Unixfs.Add which specifies the shape of the DAG
+// the data is imported into. Default is BalancedLayout; TrickleLayout is
+// better suited to data read sequentially, like videos.
+func (unixfsOpts) Layout(layout Layout) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		switch layout {
+		case BalancedLayout, TrickleLayout:
+		default:
+			return fmt.Errorf("unknown layout: %d", layout)
+		}
+		settings.Layout = layout
+		return nil
+	}
+}
+
+// Pin is an option for Unixfs.Add which specifies whether to pin the added
+// data recursively. Default is false.
+func (unixfsOpts) Pin(pin bool) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Pin = pin
+		return nil
+	}
+}
+
+// HashOnly is an option for Unixfs.Add which makes it only compute the
+// path of the data, without storing it. Default is false.
+func (unixfsOpts) HashOnly(hashOnly bool) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.OnlyHash = hashOnly
+		return nil
+	}
+}
+
+// Local is an option for Unixfs.Add which makes it store the data without
+// announcing it to the network. Default is false.
+func (unixfsOpts) Local(local bool) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Local = local
+		return nil
+	}
+}
+
+// Workers is an option for Unixfs.AddMany which specifies how many files
+// are imported at the same time. Default is 4.
+func (unixfsOpts) Workers(n int) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		if n < 1 {
+			return fmt.Errorf("number of workers must be positive, got %d", n)
+		}
+		settings.Workers = n
+		return nil
+	}
+}
+
+// Progress is an option for Unixfs.Add and Unixfs.AddMany which sends the
+// number of bytes imported to the channel, every 256KiB and at the end of
+// each file. The call waits for the events to be received, and doesn't
+// close the channel.
+func (unixfsOpts) Progress(ch chan<- ProgressEvent) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.Progress = ch
+		return nil
+	}
+}
+
+// After is an option for Unixfs.Ls which lists the links following the one
+// named after: the last link of the previous page, to page through large
+// directories. The links of sharded directories are listed in the order of
+// the hashes of their names. Default is "", listing from the first link.
+func (unixfsOpts) After(after string) UnixfsLsOption {
+	return func(settings *UnixfsLsSettings) error {
+		settings.After = after
+		return nil
+	}
+}
+
+// Limit is an option for Unixfs.Ls which specifies the number of links
+// listed at most. Default is 0, listing all of them.
+func (unixfsOpts) Limit(limit int) UnixfsLsOption {
+	return func(settings *UnixfsLsSettings) error {
+		if limit < 0 {
+			return fmt.Errorf("limit must not be negative, got %d", limit)
+		}
+		settings.Limit = limit
+		return nil
+	}
+}
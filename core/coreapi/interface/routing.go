@@ -0,0 +1,33 @@
+package iface
+
+import (
+	"context"
+
+	options "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+)
+
+// RoutingAPI specifies the interface to the routing system of the node,
+// usually the DHT
+type RoutingAPI interface {
+	// Get returns the value of the record stored under the key, like
+	// /ipns/<peer id>. The routing system only accepts keys in the
+	// namespaces it has validators for
+	Get(ctx context.Context, key string, opts ...options.RoutingGetOption) ([]byte, error)
+
+	// Put stores the record under the key
+	Put(ctx context.Context, key string, value []byte, opts ...options.RoutingPutOption) error
+
+	// Provide announces to the network that the node can provide the data
+	// at the path
+	Provide(context.Context, Path, ...options.RoutingProvideOption) error
+
+	// FindProviders looks for peers providing the data at the path. The
+	// channel is closed when the search is done or ctx is canceled
+	FindProviders(context.Context, Path, ...options.RoutingFindProvidersOption) (<-chan pstore.PeerInfo, error)
+
+	// FindPeer looks for the addresses of the peer
+	FindPeer(context.Context, peer.ID) (pstore.PeerInfo, error)
+}
@@ -0,0 +1,64 @@
+package iface
+
+import (
+	"context"
+	"time"
+
+	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	net "gx/ipfs/QmXdgNhVEgjLxjUoMs5ViQL7pboAt3Y7V7eGHRiE4qrmTE/go-libp2p-net"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+)
+
+// ConnectionInfo contains information about a peer
+type ConnectionInfo interface {
+	// ID returns PeerID
+	ID() peer.ID
+
+	// Address returns the multiaddress via which we are connected with the peer
+	Address() ma.Multiaddr
+
+	// Direction returns which way the connection was established
+	Direction() net.Direction
+
+	// Latency returns the last known round trip time to the peer, or 0 if it
+	// wasn't measured yet
+	Latency() time.Duration
+
+	// Streams returns the protocols of the streams opened with the peer
+	Streams() []protocol.ID
+}
+
+// SwarmAPI specifies the interface to libp2p swarm
+type SwarmAPI interface {
+	// Connect to a given peer
+	Connect(context.Context, pstore.PeerInfo) error
+
+	// Disconnect from a given address. The address must contain the ID of
+	// the peer, as in /ip4/1.2.3.4/tcp/4001/ipfs/Qm...
+	Disconnect(context.Context, ma.Multiaddr) error
+
+	// Peers returns the list of peers we are connected to
+	Peers(context.Context) ([]ConnectionInfo, error)
+
+	// KnownAddrs returns the addresses of all the peers in the peerstore
+	KnownAddrs(context.Context) (map[peer.ID][]ma.Multiaddr, error)
+
+	// LocalAddrs returns the addresses the node announces
+	LocalAddrs(context.Context) ([]ma.Multiaddr, error)
+
+	// ListenAddrs returns the addresses the node listens on
+	ListenAddrs(context.Context) ([]ma.Multiaddr, error)
+
+	// Filters returns the address filters of the swarm, as multiaddr masks
+	// like /ip4/10.0.0.0/ipcidr/8
+	Filters(context.Context) ([]ma.Multiaddr, error)
+
+	// AddFilter stops the node from dialing addresses matching the mask. The
+	// filter isn't saved to the config
+	AddFilter(context.Context, ma.Multiaddr) error
+
+	// RemoveFilter removes a filter added with AddFilter or from the config
+	RemoveFilter(context.Context, ma.Multiaddr) error
+}
@@ -4,17 +4,47 @@ import (
 	"context"
 	"io"
 
+	options "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	files "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit/files"
 )
 
+// AddEvent reports a file or directory imported by UnixfsAPI.AddMany
+type AddEvent interface {
+	// Name returns the path of the file relative to the imported directory,
+	// or "" for the imported directory itself
+	Name() string
+
+	// Path returns the path of the imported file
+	Path() Path
+
+	// Err returns the error which stopped the import. Only the last event
+	// can have an error
+	Err() error
+}
+
 // UnixfsAPI is the basic interface to immutable files in IPFS
 type UnixfsAPI interface {
 	// Add imports the data from the reader into merkledag file
-	Add(context.Context, io.Reader) (Path, error)
+	Add(context.Context, io.Reader, ...options.UnixfsAddOption) (Path, error)
+
+	// AddMany imports the files of a directory, several at a time, and the
+	// directory itself. An event is sent for each file when it is imported,
+	// and for each directory once all of its files are; the event of the
+	// imported directory is the last one. The directory must allow reading
+	// its files concurrently, like files.NewSerialFile does
+	AddMany(context.Context, files.File, ...options.UnixfsAddOption) (<-chan AddEvent, error)
 
 	// Cat returns a reader for the file
 	Cat(context.Context, Path) (Reader, error)
 
-	// Ls returns the list of links in a directory
-	Ls(context.Context, Path) ([]*ipld.Link, error)
+	// Ls returns the list of links in a directory. The next page of links
+	// is listed with options.Unixfs.After and the name of the last link
+	// listed.
+	Ls(context.Context, Path, ...options.UnixfsLsOption) ([]*ipld.Link, error)
+
+	// Lookup returns the link named name in a directory, fetching only the
+	// shards on the path to the link when the directory is sharded
+	Lookup(ctx context.Context, dir Path, name string) (*ipld.Link, error)
 }
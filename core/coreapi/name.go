@@ -5,11 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	core "github.com/ipfs/go-ipfs/core"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
 	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+	events "github.com/ipfs/go-ipfs/events"
 	keystore "github.com/ipfs/go-ipfs/keystore"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	nsopts "github.com/ipfs/go-ipfs/namesys/opts"
@@ -43,31 +45,90 @@ func (api *NameAPI) Publish(ctx context.Context, p coreiface.Path, opts ...caopt
 	if err != nil {
 		return nil, err
 	}
-	n := api.node
 
-	if !n.OnlineMode() {
-		err := n.SetupOfflineRouting()
-		if err != nil {
-			return nil, err
-		}
+	pth, err := api.preparePublish(p)
+	if err != nil {
+		return nil, err
 	}
 
-	if n.Mounts.Ipns != nil && n.Mounts.Ipns.IsActive() {
-		return nil, errors.New("cannot manually publish while IPNS is mounted")
+	k, err := keylookup(api.node, options.Key)
+	if err != nil {
+		return nil, err
 	}
 
-	pth, err := ipath.ParsePath(p.String())
+	if options.TTL != nil {
+		ctx = context.WithValue(ctx, "ipns-publish-ttl", *options.TTL)
+	}
+
+	eol := time.Now().Add(options.ValidTime)
+	return api.publish(ctx, k, p, pth, eol)
+}
+
+// PublishMany publishes the same path under each of keys in parallel, and
+// returns the result of each key. All the keys are looked up before
+// publishing under any of them.
+func (api *NameAPI) PublishMany(ctx context.Context, p coreiface.Path, keys []string, opts ...caopts.NamePublishOption) ([]coreiface.IpnsPublishResult, error) {
+	options, err := caopts.NamePublishOptions(opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	k, err := keylookup(n, options.Key)
+	pth, err := api.preparePublish(p)
 	if err != nil {
 		return nil, err
 	}
 
+	privs := make([]crypto.PrivKey, len(keys))
+	for i, name := range keys {
+		k, err := keylookup(api.node, name)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %s", name, err)
+		}
+		privs[i] = k
+	}
+
+	if options.TTL != nil {
+		ctx = context.WithValue(ctx, "ipns-publish-ttl", *options.TTL)
+	}
+
 	eol := time.Now().Add(options.ValidTime)
-	err = n.Namesys.PublishWithEOL(ctx, k, pth, eol)
+	results := make([]coreiface.IpnsPublishResult, len(keys))
+	var wg sync.WaitGroup
+	for i := range keys {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			e, err := api.publish(ctx, privs[i], p, pth, eol)
+			results[i] = coreiface.IpnsPublishResult{Key: keys[i], Entry: e, Err: err}
+		}(i)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// preparePublish checks that the node can publish, and parses p.
+func (api *NameAPI) preparePublish(p coreiface.Path) (ipath.Path, error) {
+	n := api.node
+
+	if !n.OnlineMode() {
+		err := n.SetupOfflineRouting()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if n.Mounts.Ipns != nil && n.Mounts.Ipns.IsActive() {
+		return "", errors.New("cannot manually publish while IPNS is mounted")
+	}
+
+	return ipath.ParsePath(p.String())
+}
+
+// publish publishes pth under k.
+func (api *NameAPI) publish(ctx context.Context, k crypto.PrivKey, p coreiface.Path, pth ipath.Path, eol time.Time) (coreiface.IpnsEntry, error) {
+	n := api.node
+
+	err := n.Namesys.PublishWithEOL(ctx, k, pth, eol)
 	if err != nil {
 		return nil, err
 	}
@@ -77,6 +138,11 @@ func (api *NameAPI) Publish(ctx context.Context, p coreiface.Path, opts ...caopt
 		return nil, err
 	}
 
+	n.Events.Emit(events.NamePublished, map[string]string{
+		"name":  pid.Pretty(),
+		"value": pth.String(),
+	})
+
 	return &ipnsEntry{
 		name:  pid.Pretty(),
 		value: p,
@@ -112,7 +178,12 @@ func (api *NameAPI) Resolve(ctx context.Context, name string, opts ...caopts.Nam
 	}
 
 	if !options.Cache {
-		resolver = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), 0)
+		policy, err := n.ResolvePolicy()
+		if err != nil {
+			return nil, err
+		}
+		policy.CacheSize = 0
+		resolver = namesys.NewNameSystemWithPolicy(n.Routing, n.Repo.Datastore(), policy)
 	}
 
 	if !strings.HasPrefix(name, "/ipns/") {
@@ -123,6 +194,12 @@ func (api *NameAPI) Resolve(ctx context.Context, name string, opts ...caopts.Nam
 	if !options.Recursive {
 		ropts = append(ropts, nsopts.Depth(1))
 	}
+	if options.DhtRecordCount > 0 {
+		ropts = append(ropts, nsopts.DhtRecordCount(options.DhtRecordCount))
+	}
+	if options.DhtTimeout != nil {
+		ropts = append(ropts, nsopts.DhtTimeout(*options.DhtTimeout))
+	}
 
 	output, err := resolver.Resolve(ctx, name, ropts...)
 	if err != nil {
@@ -103,6 +103,54 @@ func TestBasicPublishResolveKey(t *testing.T) {
 	}
 }
 
+func TestPublishMany(t *testing.T) {
+	ctx := context.Background()
+	n, api, err := makeAPIIdent(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := api.Key().Generate(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := addTestObject(ctx, api)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := api.Name().PublishMany(ctx, p, []string{"self", "missing"}); err == nil {
+		t.Fatal("expected an unknown key to fail")
+	}
+
+	results, err := api.Name().PublishMany(ctx, p, []string{"self", k.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+
+	names := []string{"/ipns/" + n.Identity.Pretty(), k.Path().String()}
+	for i, r := range results {
+		if r.Err != nil {
+			t.Fatalf("publishing under %s failed: %s", r.Key, r.Err)
+		}
+		if name := ipath.Join([]string{"/ipns", r.Entry.Name()}); name != names[i] {
+			t.Errorf("expected name %s, got %s", names[i], name)
+		}
+
+		resPath, err := api.Name().Resolve(ctx, r.Entry.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resPath.String() != p.String() {
+			t.Errorf("expected paths to match, '%s'!='%s'", resPath.String(), p.String())
+		}
+	}
+}
+
 func TestBasicPublishResolveTimeout(t *testing.T) {
 	t.Skip("ValidTime doesn't appear to work at this time resolution")
 
@@ -3,6 +3,7 @@ package coreapi
 import (
 	"context"
 	"fmt"
+	"time"
 
 	bserv "github.com/ipfs/go-ipfs/blockservice"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
@@ -26,6 +27,10 @@ func (api *PinAPI) Add(ctx context.Context, p coreiface.Path, opts ...caopts.Pin
 
 	defer api.node.Blockstore.PinLock().Unlock()
 
+	if settings.Progress != nil {
+		return api.pinWithProgress(ctx, p, settings)
+	}
+
 	_, err = corerepo.Pin(api.node, ctx, []string{p.String()}, settings.Recursive)
 	if err != nil {
 		return err
@@ -34,6 +39,45 @@ func (api *PinAPI) Add(ctx context.Context, p coreiface.Path, opts ...caopts.Pin
 	return nil
 }
 
+// pinWithProgress pins p, sending the number of nodes fetched twice a
+// second, as 'ipfs pin add --progress' does.
+func (api *PinAPI) pinWithProgress(ctx context.Context, p coreiface.Path, settings *caopts.PinAddSettings) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tracker := new(merkledag.ProgressTracker)
+	done := make(chan error, 1)
+	go func() {
+		_, err := corerepo.Pin(api.node, tracker.DeriveContext(ctx), []string{p.String()}, settings.Recursive)
+		done <- err
+	}()
+
+	send := func() error {
+		select {
+		case settings.Progress <- caopts.ProgressEvent{Nodes: tracker.Value()}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+			return send()
+		case <-ticker.C:
+			if err := send(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
 func (api *PinAPI) Ls(ctx context.Context, opts ...caopts.PinLsOption) ([]coreiface.Pin, error) {
 	settings, err := caopts.PinLsOptions(opts...)
 	if err != nil {
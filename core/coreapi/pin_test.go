@@ -26,6 +26,44 @@ func TestPinAdd(t *testing.T) {
 	}
 }
 
+func TestPinAddProgress(t *testing.T) {
+	ctx := context.Background()
+	_, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := api.Unixfs().Add(ctx, strings.NewReader(strings.Repeat("foo", 1000000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	progress := make(chan opt.ProgressEvent)
+	done := make(chan error, 1)
+	go func() {
+		done <- api.Pin().Add(ctx, p, opt.Pin.Progress(progress))
+	}()
+
+	var last opt.ProgressEvent
+	for {
+		select {
+		case ev := <-progress:
+			last = ev
+			continue
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		break
+	}
+
+	// at least the 12 leaves of the file
+	if last.Nodes < 12 {
+		t.Errorf("expected at least 12 nodes, got %d", last.Nodes)
+	}
+}
+
 func TestPinSimple(t *testing.T) {
 	ctx := context.Background()
 	_, api, err := makeAPI(ctx)
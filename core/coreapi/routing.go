@@ -0,0 +1,128 @@
+package coreapi
+
+import (
+	"context"
+
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+type RoutingAPI CoreAPI
+
+// Get returns the record stored under the key, once the routing system and
+// the validators given as options accepted it.
+func (api *RoutingAPI) Get(ctx context.Context, key string, opts ...caopts.RoutingGetOption) ([]byte, error) {
+	settings, err := caopts.RoutingGetOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if api.node.Routing == nil {
+		return nil, coreiface.ErrOffline
+	}
+
+	value, err := api.node.Routing.GetValue(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, validate := range settings.Validators {
+		if err := validate(key, value); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// Put checks the record with the validators given as options and stores it
+// under the key.
+func (api *RoutingAPI) Put(ctx context.Context, key string, value []byte, opts ...caopts.RoutingPutOption) error {
+	settings, err := caopts.RoutingPutOptions(opts...)
+	if err != nil {
+		return err
+	}
+
+	if api.node.Routing == nil {
+		return coreiface.ErrOffline
+	}
+
+	for _, validate := range settings.Validators {
+		if err := validate(key, value); err != nil {
+			return err
+		}
+	}
+	return api.node.Routing.PutValue(ctx, key, value)
+}
+
+// Provide announces the node as a provider of the data at the path.
+func (api *RoutingAPI) Provide(ctx context.Context, p coreiface.Path, opts ...caopts.RoutingProvideOption) error {
+	settings, err := caopts.RoutingProvideOptions(opts...)
+	if err != nil {
+		return err
+	}
+
+	if !api.node.OnlineMode() {
+		return coreiface.ErrOffline
+	}
+
+	rp, err := api.core().ResolvePath(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	c := rp.Cid()
+	if !settings.Recursive {
+		return api.node.Routing.Provide(ctx, c, true)
+	}
+
+	keys := cid.NewSet()
+	err = dag.EnumerateChildrenAsync(ctx, dag.GetLinksDirect(api.node.DAG), c, keys.Visit)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys.Keys() {
+		if err := api.node.Routing.Provide(ctx, k, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindProviders streams the providers of the data at the path as they are
+// found.
+func (api *RoutingAPI) FindProviders(ctx context.Context, p coreiface.Path, opts ...caopts.RoutingFindProvidersOption) (<-chan pstore.PeerInfo, error) {
+	settings, err := caopts.RoutingFindProvidersOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !api.node.OnlineMode() {
+		return nil, coreiface.ErrOffline
+	}
+
+	rp, err := api.core().ResolvePath(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return api.node.Routing.FindProvidersAsync(ctx, rp.Cid(), settings.NumProviders), nil
+}
+
+// FindPeer looks for the addresses of the peer.
+func (api *RoutingAPI) FindPeer(ctx context.Context, p peer.ID) (pstore.PeerInfo, error) {
+	if !api.node.OnlineMode() {
+		return pstore.PeerInfo{}, coreiface.ErrOffline
+	}
+
+	return api.node.Routing.FindPeer(ctx, p)
+}
+
+func (api *RoutingAPI) core() coreiface.CoreAPI {
+	return (*CoreAPI)(api)
+}
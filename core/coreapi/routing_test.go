@@ -0,0 +1,63 @@
+package coreapi_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	opt "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+)
+
+func TestRoutingPutValidator(t *testing.T) {
+	ctx := context.Background()
+	nd, api, err := makeAPIIdent(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nd.SetupOfflineRouting(); err != nil {
+		t.Fatal(err)
+	}
+
+	errRejected := errors.New("rejected")
+	reject := func(key string, value []byte) error {
+		if key != "/foo/bar" || string(value) != "baz" {
+			t.Errorf("unexpected record %q: %q", key, value)
+		}
+		return errRejected
+	}
+
+	err = api.Routing().Put(ctx, "/foo/bar", []byte("baz"), opt.Routing.PutValidator(reject))
+	if err != errRejected {
+		t.Fatalf("expected the validator error, got %v", err)
+	}
+}
+
+func TestRoutingOffline(t *testing.T) {
+	ctx := context.Background()
+	nd, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := api.Routing().Get(ctx, "/ipns/"+testPeerID); err != coreiface.ErrOffline {
+		t.Errorf("expected ErrOffline from Get, got %v", err)
+	}
+
+	if _, err := api.Routing().FindPeer(ctx, nd.Identity); err != coreiface.ErrOffline {
+		t.Errorf("expected ErrOffline from FindPeer, got %v", err)
+	}
+
+	p, err := api.Unixfs().Add(ctx, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Routing().Provide(ctx, p); err != coreiface.ErrOffline {
+		t.Errorf("expected ErrOffline from Provide, got %v", err)
+	}
+
+	if _, err := api.Routing().FindProviders(ctx, p, opt.Routing.NumProviders(0)); err == nil {
+		t.Error("expected an error for 0 providers")
+	}
+}
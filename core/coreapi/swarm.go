@@ -0,0 +1,203 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+
+	mafilter "gx/ipfs/QmSMZwvs3n4GBikZ7hKzT17c3bk65FmyZo2JqtJ16swqCv/multiaddr-filter"
+	swarm "gx/ipfs/QmSvhbgtjQJKdT5avEeb7cvjYs7YrhebJyM1K6GAnkKgfd/go-libp2p-swarm"
+	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	net "gx/ipfs/QmXdgNhVEgjLxjUoMs5ViQL7pboAt3Y7V7eGHRiE4qrmTE/go-libp2p-net"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	iaddr "gx/ipfs/QmckPUj15AbTcLh6MpDEsQpfVCx34tmP2Xg1aNwLb5fiRF/go-ipfs-addr"
+)
+
+type SwarmAPI CoreAPI
+
+type connInfo struct {
+	peer      peer.ID
+	addr      ma.Multiaddr
+	direction net.Direction
+	latency   time.Duration
+	streams   []protocol.ID
+}
+
+func (ci *connInfo) ID() peer.ID {
+	return ci.peer
+}
+
+func (ci *connInfo) Address() ma.Multiaddr {
+	return ci.addr
+}
+
+func (ci *connInfo) Direction() net.Direction {
+	return ci.direction
+}
+
+func (ci *connInfo) Latency() time.Duration {
+	return ci.latency
+}
+
+func (ci *connInfo) Streams() []protocol.ID {
+	return ci.streams
+}
+
+// Connect connects to the given peer, clearing the dial backoff of the peer
+// first so that a recently failed peer can be retried right away.
+func (api *SwarmAPI) Connect(ctx context.Context, pi pstore.PeerInfo) error {
+	swrm, err := api.swarm()
+	if err != nil {
+		return err
+	}
+
+	swrm.Backoff().Clear(pi.ID)
+	return api.node.PeerHost.Connect(ctx, pi)
+}
+
+// Disconnect closes the connection to the peer at the given address.
+func (api *SwarmAPI) Disconnect(ctx context.Context, addr ma.Multiaddr) error {
+	if api.node.PeerHost == nil {
+		return coreiface.ErrOffline
+	}
+
+	ia, err := iaddr.ParseMultiaddr(addr)
+	if err != nil {
+		return err
+	}
+
+	taddr := ia.Transport()
+	for _, conn := range api.node.PeerHost.Network().ConnsToPeer(ia.ID()) {
+		if conn.RemoteMultiaddr().Equal(taddr) {
+			return conn.Close()
+		}
+	}
+	return errors.New("conn not found")
+}
+
+// Peers returns the peers the node is connected to, with one entry per
+// connection.
+func (api *SwarmAPI) Peers(ctx context.Context) ([]coreiface.ConnectionInfo, error) {
+	if api.node.PeerHost == nil {
+		return nil, coreiface.ErrOffline
+	}
+
+	conns := api.node.PeerHost.Network().Conns()
+	out := make([]coreiface.ConnectionInfo, 0, len(conns))
+	for _, c := range conns {
+		pid := c.RemotePeer()
+		ci := &connInfo{
+			peer:      pid,
+			addr:      c.RemoteMultiaddr(),
+			direction: c.Stat().Direction,
+			latency:   api.node.Peerstore.LatencyEWMA(pid),
+		}
+		for _, s := range c.GetStreams() {
+			ci.streams = append(ci.streams, s.Protocol())
+		}
+		out = append(out, ci)
+	}
+	return out, nil
+}
+
+// KnownAddrs returns the addresses of the peers in the peerstore.
+func (api *SwarmAPI) KnownAddrs(ctx context.Context) (map[peer.ID][]ma.Multiaddr, error) {
+	if api.node.PeerHost == nil {
+		return nil, coreiface.ErrOffline
+	}
+
+	addrs := make(map[peer.ID][]ma.Multiaddr)
+	ps := api.node.PeerHost.Network().Peerstore()
+	for _, p := range ps.Peers() {
+		addrs[p] = append(addrs[p], ps.Addrs(p)...)
+	}
+	return addrs, nil
+}
+
+// LocalAddrs returns the addresses the node announces to its peers.
+func (api *SwarmAPI) LocalAddrs(ctx context.Context) ([]ma.Multiaddr, error) {
+	if api.node.PeerHost == nil {
+		return nil, coreiface.ErrOffline
+	}
+
+	return api.node.PeerHost.Addrs(), nil
+}
+
+// ListenAddrs returns the addresses the node listens on, with unspecified
+// addresses like /ip4/0.0.0.0 expanded to the addresses of the interfaces.
+func (api *SwarmAPI) ListenAddrs(ctx context.Context) ([]ma.Multiaddr, error) {
+	if api.node.PeerHost == nil {
+		return nil, coreiface.ErrOffline
+	}
+
+	return api.node.PeerHost.Network().InterfaceListenAddresses()
+}
+
+// Filters returns the address filters of the swarm.
+func (api *SwarmAPI) Filters(ctx context.Context) ([]ma.Multiaddr, error) {
+	swrm, err := api.swarm()
+	if err != nil {
+		return nil, err
+	}
+
+	fs := swrm.Filters.Filters()
+	out := make([]ma.Multiaddr, 0, len(fs))
+	for _, f := range fs {
+		s, err := mafilter.ConvertIPNet(f)
+		if err != nil {
+			return nil, err
+		}
+		m, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// AddFilter adds a dial filter to the swarm.
+func (api *SwarmAPI) AddFilter(ctx context.Context, mask ma.Multiaddr) error {
+	swrm, err := api.swarm()
+	if err != nil {
+		return err
+	}
+
+	ipnet, err := mafilter.NewMask(mask.String())
+	if err != nil {
+		return err
+	}
+	swrm.Filters.AddDialFilter(ipnet)
+	return nil
+}
+
+// RemoveFilter removes a filter from the swarm.
+func (api *SwarmAPI) RemoveFilter(ctx context.Context, mask ma.Multiaddr) error {
+	swrm, err := api.swarm()
+	if err != nil {
+		return err
+	}
+
+	ipnet, err := mafilter.NewMask(mask.String())
+	if err != nil {
+		return err
+	}
+	swrm.Filters.Remove(ipnet)
+	return nil
+}
+
+func (api *SwarmAPI) swarm() (*swarm.Swarm, error) {
+	if api.node.PeerHost == nil {
+		return nil, coreiface.ErrOffline
+	}
+
+	swrm, ok := api.node.PeerHost.Network().(*swarm.Swarm)
+	if !ok {
+		return nil, errors.New("peerhost network was not swarm")
+	}
+	return swrm, nil
+}
@@ -0,0 +1,34 @@
+package coreapi_test
+
+import (
+	"context"
+	"testing"
+
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+
+	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+)
+
+func TestSwarmOffline(t *testing.T) {
+	ctx := context.Background()
+	_, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := api.Swarm().Peers(ctx); err != coreiface.ErrOffline {
+		t.Errorf("expected ErrOffline from Peers, got %v", err)
+	}
+
+	if _, err := api.Swarm().ListenAddrs(ctx); err != coreiface.ErrOffline {
+		t.Errorf("expected ErrOffline from ListenAddrs, got %v", err)
+	}
+
+	mask, err := ma.NewMultiaddr("/ip4/10.0.0.0/ipcidr/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Swarm().AddFilter(ctx, mask); err != coreiface.ErrOffline {
+		t.Errorf("expected ErrOffline from AddFilter, got %v", err)
+	}
+}
@@ -2,36 +2,381 @@ package coreapi
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"os"
+	gopath "path"
+	"sync"
 
+	blockservice "github.com/ipfs/go-ipfs/blockservice"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
 	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	unixfs "github.com/ipfs/go-ipfs/unixfs"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
+	offline "gx/ipfs/QmPf114DXfa6TqGKYhBGR7EtXRho4rCJgwyA1xkuMY5vwF/go-ipfs-exchange-offline"
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
 	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	bstore "gx/ipfs/QmbaPGg81pvQiC5vTXtC9Jo8rdrWUjRaugH71WYNsgi6Ev/go-ipfs-blockstore"
+	files "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit/files"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dsync "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
 )
 
 type UnixfsAPI CoreAPI
 
 // Add builds a merkledag node from a reader, adds it to the blockstore,
 // and returns the key representing that node.
-func (api *UnixfsAPI) Add(ctx context.Context, r io.Reader) (coreiface.Path, error) {
-	k, err := coreunix.AddWithContext(ctx, api.node, r)
+func (api *UnixfsAPI) Add(ctx context.Context, r io.Reader, opts ...caopts.UnixfsAddOption) (coreiface.Path, error) {
+	settings, prefix, err := caopts.UnixfsAddOptions(opts...)
 	if err != nil {
 		return nil, err
 	}
-	c, err := cid.Decode(k)
+
+	fileAdder, _, err := api.newAdder(ctx, settings, prefix)
+	if err != nil {
+		return nil, err
+	}
+	fileAdder.Pin = settings.Pin && !settings.OnlyHash
+
+	if settings.Progress != nil {
+		r = &progressReader{ctx: ctx, r: r, out: settings.Progress}
+	}
+
+	nd, err := fileAdder.AddReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCid(nd.Cid()), nil
+}
+
+// newAdder returns an adder set up with the settings and the DAG service it
+// adds to. The adder pins nothing, set its Pin field to pin the files.
+func (api *UnixfsAPI) newAdder(ctx context.Context, settings *caopts.UnixfsAddSettings, prefix cid.Prefix) (*coreunix.Adder, ipld.DAGService, error) {
+	n := api.node
+
+	var dserv ipld.DAGService
+	if settings.OnlyHash {
+		// hash into a blockstore which doesn't keep anything
+		nilstore := bstore.NewBlockstore(dsync.MutexWrap(ds.NewNullDatastore()))
+		dserv = dag.NewDAGService(blockservice.New(nilstore, offline.Exchange(nilstore)))
+	} else {
+		addblockstore := bstore.NewGCBlockstore(n.BaseBlocks, n.GCLocker)
+		exch := n.Exchange
+		if settings.Local {
+			exch = offline.Exchange(addblockstore)
+		}
+		dserv = dag.NewDAGService(blockservice.New(addblockstore, exch))
+	}
+
+	fileAdder, err := coreunix.NewAdder(ctx, n.Pinning, n.Blockstore, dserv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fileAdder.Chunker = settings.Chunker
+	fileAdder.Pin = false
+	fileAdder.RawLeaves = settings.RawLeaves
+	fileAdder.Trickle = settings.Layout == caopts.TrickleLayout
+	fileAdder.Prefix = &prefix
+	return fileAdder, dserv, nil
+}
+
+type addEvent struct {
+	name string
+	path coreiface.Path
+	err  error
+}
+
+func (e *addEvent) Name() string {
+	return e.name
+}
+
+func (e *addEvent) Path() coreiface.Path {
+	return e.path
+}
+
+func (e *addEvent) Err() error {
+	return e.err
+}
+
+// AddMany imports the files of dir with settings.Workers workers and builds
+// the directories once their files are imported.
+func (api *UnixfsAPI) AddMany(ctx context.Context, dir files.File, opts ...caopts.UnixfsAddOption) (<-chan coreiface.AddEvent, error) {
+	settings, prefix, err := caopts.UnixfsAddOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !dir.IsDirectory() {
+		return nil, errors.New("AddMany: expected a directory")
+	}
+
+	out := make(chan coreiface.AddEvent)
+	go func() {
+		defer close(out)
+
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		m := &manyAdder{
+			api:      api,
+			settings: settings,
+			prefix:   prefix,
+			out:      out,
+			workers:  make(chan struct{}, settings.Workers),
+			cancel:   cancel,
+		}
+
+		path, err := m.run(runCtx, dir)
+		if err != nil {
+			m.send(ctx, &addEvent{err: err})
+			return
+		}
+		m.send(ctx, &addEvent{path: path})
+	}()
+	return out, nil
+}
+
+// manyAdder imports a directory for UnixfsAPI.AddMany.
+type manyAdder struct {
+	api      *UnixfsAPI
+	settings *caopts.UnixfsAddSettings
+	prefix   cid.Prefix
+	dserv    ipld.DAGService
+	out      chan<- coreiface.AddEvent
+
+	workers chan struct{}
+	wg      sync.WaitGroup
+	cancel  context.CancelFunc
+
+	lk  sync.Mutex
+	err error
+}
+
+// addEntry is a file or directory being imported.
+type addEntry struct {
+	name     string
+	path     string
+	nd       ipld.Node
+	isDir    bool
+	children []*addEntry
+}
+
+func (m *manyAdder) run(ctx context.Context, dir files.File) (coreiface.Path, error) {
+	// keep the garbage collector away until the root is pinned
+	if m.settings.Pin && !m.settings.OnlyHash {
+		defer m.api.node.Blockstore.PinLock().Unlock()
+	}
+
+	_, dserv, err := m.api.newAdder(ctx, m.settings, m.prefix)
+	if err != nil {
+		return nil, err
+	}
+	m.dserv = dserv
+
+	children, err := m.walk(ctx, "", dir)
+	if err != nil {
+		m.fail(err)
+	}
+	m.wg.Wait()
+	if err := m.firstErr(); err != nil {
+		return nil, err
+	}
+
+	root := &addEntry{isDir: true, children: children}
+	if err := m.buildDir(ctx, root); err != nil {
+		return nil, err
+	}
+
+	if m.settings.Pin && !m.settings.OnlyHash {
+		pinning := m.api.node.Pinning
+		if err := pinning.Pin(ctx, root.nd, true); err != nil {
+			return nil, err
+		}
+		if err := pinning.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return ParseCid(root.nd.Cid()), nil
+}
+
+// walk lists the entries of dir and starts importing its files. It returns
+// once all the files were handed to workers.
+func (m *manyAdder) walk(ctx context.Context, dirPath string, dir files.File) ([]*addEntry, error) {
+	var entries []*addEntry
+	for {
+		f, err := dir.NextFile()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := gopath.Base(f.FileName())
+		e := &addEntry{name: name, path: gopath.Join(dirPath, name)}
+		entries = append(entries, e)
+
+		if f.IsDirectory() {
+			e.isDir = true
+			e.children, err = m.walk(ctx, e.path, f)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		select {
+		case m.workers <- struct{}{}:
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		}
+
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			defer func() { <-m.workers }()
+			defer f.Close()
+
+			nd, err := m.importFile(ctx, e.path, f)
+			if err != nil {
+				m.fail(fmt.Errorf("%s: %s", e.path, err))
+				return
+			}
+			e.nd = nd
+			m.send(ctx, &addEvent{name: e.path, path: ParseCid(nd.Cid())})
+		}()
+	}
+}
+
+func (m *manyAdder) importFile(ctx context.Context, name string, f files.File) (ipld.Node, error) {
+	if s, ok := f.(*files.Symlink); ok {
+		sdata, err := unixfs.SymlinkData(s.Target)
+		if err != nil {
+			return nil, err
+		}
+
+		nd := dag.NodeWithData(sdata)
+		nd.SetPrefix(&m.prefix)
+		if err := m.dserv.Add(ctx, nd); err != nil {
+			return nil, err
+		}
+		return nd, nil
+	}
+
+	fileAdder, _, err := m.api.newAdder(ctx, m.settings, m.prefix)
 	if err != nil {
 		return nil, err
 	}
-	return ParseCid(c), nil
+
+	var r io.Reader = f
+	if m.settings.Progress != nil {
+		r = &progressReader{ctx: ctx, r: f, name: name, out: m.settings.Progress}
+	}
+	return fileAdder.ImportReader(r)
+}
+
+// buildDir builds the node of the directory e and of its subdirectories,
+// sending an event for each.
+func (m *manyAdder) buildDir(ctx context.Context, e *addEntry) error {
+	dir := uio.NewDirectory(m.dserv)
+	dir.SetPrefix(&m.prefix)
+	for _, c := range e.children {
+		if c.isDir {
+			if err := m.buildDir(ctx, c); err != nil {
+				return err
+			}
+		}
+		if err := dir.AddChild(ctx, c.name, c.nd); err != nil {
+			return err
+		}
+	}
+
+	nd, err := dir.GetNode()
+	if err != nil {
+		return err
+	}
+	if err := m.dserv.Add(ctx, nd); err != nil {
+		return err
+	}
+	e.nd = nd
+
+	if e.path != "" {
+		m.send(ctx, &addEvent{name: e.path, path: ParseCid(nd.Cid())})
+	}
+	return nil
+}
+
+func (m *manyAdder) send(ctx context.Context, ev coreiface.AddEvent) {
+	select {
+	case m.out <- ev:
+	case <-ctx.Done():
+	}
+}
+
+// fail records the first error and stops the import.
+func (m *manyAdder) fail(err error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	if m.err == nil {
+		m.err = err
+		m.cancel()
+	}
+}
+
+func (m *manyAdder) firstErr() error {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.err
+}
+
+// progressReaderIncrement is the number of bytes read between two progress
+// events, as with 'ipfs add --progress'.
+const progressReaderIncrement = 1024 * 256
+
+// progressReader sends the number of bytes read from r to out.
+type progressReader struct {
+	ctx  context.Context
+	r    io.Reader
+	name string
+	out  chan<- caopts.ProgressEvent
+
+	bytes int64
+	last  int64
+	done  bool
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+
+	p.bytes += int64(n)
+	if p.bytes-p.last >= progressReaderIncrement || (err == io.EOF && !p.done) {
+		p.last = p.bytes
+		p.done = err == io.EOF
+
+		select {
+		case p.out <- caopts.ProgressEvent{Name: p.name, Bytes: p.bytes}:
+		case <-p.ctx.Done():
+			return n, p.ctx.Err()
+		}
+	}
+
+	return n, err
 }
 
 // Cat returns the data contained by an IPFS or IPNS object(s) at path `p`.
 func (api *UnixfsAPI) Cat(ctx context.Context, p coreiface.Path) (coreiface.Reader, error) {
 	dget := api.node.DAG // TODO: use a session here once routing perf issues are resolved
 
+	if err := api.node.Denylist.Check(p.String()); err != nil {
+		return nil, err
+	}
+
 	dagnode, err := resolveNode(ctx, dget, api.node.Namesys, p)
 	if err != nil {
 		return nil, err
@@ -48,7 +393,12 @@ func (api *UnixfsAPI) Cat(ctx context.Context, p coreiface.Path) (coreiface.Read
 
 // Ls returns the contents of an IPFS or IPNS object(s) at path p, with the format:
 // `<link base58 hash> <link size in bytes> <link name>`
-func (api *UnixfsAPI) Ls(ctx context.Context, p coreiface.Path) ([]*ipld.Link, error) {
+func (api *UnixfsAPI) Ls(ctx context.Context, p coreiface.Path, opts ...caopts.UnixfsLsOption) ([]*ipld.Link, error) {
+	settings, err := caopts.UnixfsLsOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	dagnode, err := api.core().ResolveNode(ctx, p)
 	if err != nil {
 		return nil, err
@@ -58,13 +408,16 @@ func (api *UnixfsAPI) Ls(ctx context.Context, p coreiface.Path) ([]*ipld.Link, e
 	dir, err := uio.NewDirectoryFromNode(api.node.DAG, dagnode)
 	switch err {
 	case nil:
-		l, err := dir.Links(ctx)
+		l, err := dir.LinksAfter(ctx, settings.After, settings.Limit)
 		if err != nil {
 			return nil, err
 		}
 		ndlinks = l
 	case uio.ErrNotADir:
-		ndlinks = dagnode.Links()
+		ndlinks, err = uio.LinksAfter(dagnode.Links(), settings.After, settings.Limit)
+		if err != nil {
+			return nil, err
+		}
 	default:
 		return nil, err
 	}
@@ -76,6 +429,29 @@ func (api *UnixfsAPI) Ls(ctx context.Context, p coreiface.Path) ([]*ipld.Link, e
 	return links, nil
 }
 
+// Lookup returns the link named name in the directory at p, without listing
+// the directory.
+func (api *UnixfsAPI) Lookup(ctx context.Context, p coreiface.Path, name string) (*ipld.Link, error) {
+	dagnode, err := api.core().ResolveNode(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := uio.NewDirectoryFromNode(api.node.DAG, dagnode)
+	if err != nil {
+		return nil, err
+	}
+
+	lnk, err := dir.FindLink(ctx, name)
+	if err == os.ErrNotExist {
+		return nil, fmt.Errorf("no entry named %q in %s", name, p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ipld.Link{Name: lnk.Name, Size: lnk.Size, Cid: lnk.Cid}, nil
+}
+
 func (api *UnixfsAPI) core() coreiface.CoreAPI {
 	return (*CoreAPI)(api)
 }
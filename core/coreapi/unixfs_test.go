@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"errors"
 	"io"
+	"io/ioutil"
 	"math"
 	"strings"
 	"testing"
@@ -12,6 +14,7 @@ import (
 	core "github.com/ipfs/go-ipfs/core"
 	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	options "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
 	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
 	keystore "github.com/ipfs/go-ipfs/keystore"
 	mdag "github.com/ipfs/go-ipfs/merkledag"
@@ -19,8 +22,10 @@ import (
 	config "github.com/ipfs/go-ipfs/repo/config"
 	unixfs "github.com/ipfs/go-ipfs/unixfs"
 
+	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
 	cbor "gx/ipfs/QmSF1Ksgn5d7JCTBt4e1yp4wzs6tpYyweCZ4PcDYp3tNeK/go-ipld-cbor"
 	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	files "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit/files"
 	ci "gx/ipfs/Qme1knMqwt1hKZbc1BmQFmnm9f36nyQGwXxPGVpVJ9rMK5/go-libp2p-crypto"
 	datastore "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
 	syncds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
@@ -135,6 +140,251 @@ func TestAddEmptyFile(t *testing.T) {
 	}
 }
 
+func TestAddOptions(t *testing.T) {
+	ctx := context.Background()
+	_, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		opts []options.UnixfsAddOption
+		path string
+	}{
+		{"default", nil, hello.String()},
+		{"cidv0", []options.UnixfsAddOption{options.Unixfs.CidVersion(0)}, hello.String()},
+		// `echo -n 'hello, world!' | ipfs add --cid-version=1`
+		{"cidv1", []options.UnixfsAddOption{options.Unixfs.CidVersion(1)}, "/ipfs/zb2rhdhmJjJZs9qkhQCpCQ7VREFkqWw3h1r8utjVvQugwHPFd"},
+		// `echo -n 'hello, world!' | ipfs add --hash=sha3-256`
+		{"sha3", []options.UnixfsAddOption{options.Unixfs.Hash(mh.SHA3_256)}, "/ipfs/zb2wwnYtXBxpndNABjtYxWAPt3cwWNRnc11iT63fvkYV78iRb"},
+	}
+
+	for _, c := range cases {
+		p, err := api.Unixfs().Add(ctx, strings.NewReader(helloStr), c.opts...)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if p.String() != c.path {
+			t.Errorf("%s: expected path %s, got: %s", c.name, c.path, p)
+		}
+	}
+
+	if _, err := api.Unixfs().Add(ctx, strings.NewReader(helloStr), options.Unixfs.CidVersion(0), options.Unixfs.Hash(mh.SHA3_256)); err == nil {
+		t.Error("CIDv0 with sha3-256 should fail")
+	}
+}
+
+func TestAddLayouts(t *testing.T) {
+	ctx := context.Background()
+	_, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// more chunks than fit in a node, so that the layouts differ
+	data := strings.Repeat("0123456789", 300)
+	chunker := options.Unixfs.Chunker("size-10")
+
+	balanced, err := api.Unixfs().Add(ctx, strings.NewReader(data), chunker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trickle, err := api.Unixfs().Add(ctx, strings.NewReader(data), chunker, options.Unixfs.Layout(options.TrickleLayout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balanced.String() == trickle.String() {
+		t.Fatal("balanced and trickle layouts should give different DAGs")
+	}
+
+	for _, p := range []coreiface.Path{balanced, trickle} {
+		r, err := api.Unixfs().Cat(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != data {
+			t.Fatalf("%s: unexpected content", p)
+		}
+	}
+}
+
+func TestAddPinAndHashOnly(t *testing.T) {
+	ctx := context.Background()
+	node, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := api.Unixfs().Add(ctx, strings.NewReader("only hashed"), options.Unixfs.HashOnly(true), options.Unixfs.Pin(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	has, err := node.Blockstore.Has(p.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has {
+		t.Fatal("HashOnly shouldn't store the data")
+	}
+
+	p, err = api.Unixfs().Add(ctx, strings.NewReader("pinned"), options.Unixfs.Pin(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pins, err := api.Pin().Ls(ctx, options.Pin.Type.Recursive())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 || pins[0].Path().String() != p.String() {
+		t.Fatalf("expected %s to be pinned, got %v", p, pins)
+	}
+}
+
+func TestAddProgress(t *testing.T) {
+	ctx := context.Background()
+	_, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	progress := make(chan options.ProgressEvent)
+	done := make(chan error, 1)
+	go func() {
+		_, err := api.Unixfs().Add(ctx, strings.NewReader(strings.Repeat("a", 1000000)), options.Unixfs.Progress(progress))
+		done <- err
+	}()
+
+	var events []options.ProgressEvent
+	for {
+		select {
+		case ev := <-progress:
+			events = append(events, ev)
+			continue
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		break
+	}
+
+	if len(events) < 2 {
+		t.Fatalf("expected several events, got %d", len(events))
+	}
+	if last := events[len(events)-1]; last.Bytes != 1000000 {
+		t.Errorf("expected 1000000 bytes in the last event, got %d", last.Bytes)
+	}
+}
+
+func TestAddMany(t *testing.T) {
+	ctx := context.Background()
+	node, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := map[string]string{
+		"a":     helloStr,
+		"sub/b": "b",
+		"sub/c": strings.Repeat("c", 1000000),
+	}
+	dir := files.NewSliceFile("dir", "dir", []files.File{
+		files.NewReaderFile("dir/a", "dir/a", ioutil.NopCloser(strings.NewReader(contents["a"])), nil),
+		files.NewSliceFile("dir/sub", "dir/sub", []files.File{
+			files.NewReaderFile("dir/sub/b", "dir/sub/b", ioutil.NopCloser(strings.NewReader(contents["sub/b"])), nil),
+			files.NewReaderFile("dir/sub/c", "dir/sub/c", ioutil.NopCloser(strings.NewReader(contents["sub/c"])), nil),
+		}),
+	})
+
+	events, err := api.Unixfs().AddMany(ctx, dir, options.Unixfs.Workers(2), options.Unixfs.Pin(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := make(map[string]coreiface.Path)
+	var last coreiface.AddEvent
+	for ev := range events {
+		if ev.Err() != nil {
+			t.Fatal(ev.Err())
+		}
+		if _, ok := paths[ev.Name()]; ok {
+			t.Errorf("duplicate event for %q", ev.Name())
+		}
+		paths[ev.Name()] = ev.Path()
+		last = ev
+	}
+
+	if last == nil || last.Name() != "" {
+		t.Fatal("expected the event of the directory last")
+	}
+	if len(paths) != 5 {
+		t.Fatalf("expected 5 events, got %d", len(paths))
+	}
+
+	for name, data := range contents {
+		p, err := api.Unixfs().Add(ctx, strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if paths[name].String() != p.String() {
+			t.Errorf("%s: expected %s, got %s", name, p, paths[name])
+		}
+	}
+
+	links, err := api.Unixfs().Ls(ctx, paths[""])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 || links[0].Name != "a" || links[1].Name != "sub" {
+		t.Fatalf("unexpected links: %v", links)
+	}
+	if links[1].Cid.String() != paths["sub"].Cid().String() {
+		t.Errorf("expected sub to be %s, got %s", paths["sub"].Cid(), links[1].Cid)
+	}
+
+	_, pinned, err := node.Pinning.IsPinned(paths[""].Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pinned {
+		t.Error("the directory should be pinned")
+	}
+}
+
+func TestAddManyError(t *testing.T) {
+	ctx := context.Background()
+	_, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr, pw := io.Pipe()
+	pw.CloseWithError(errors.New("read failed"))
+	dir := files.NewSliceFile("dir", "dir", []files.File{
+		files.NewReaderFile("dir/a", "dir/a", ioutil.NopCloser(strings.NewReader(helloStr)), nil),
+		files.NewReaderFile("dir/b", "dir/b", pr, nil),
+	})
+
+	events, err := api.Unixfs().AddMany(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last coreiface.AddEvent
+	for ev := range events {
+		last = ev
+	}
+	if last == nil || last.Err() == nil || !strings.Contains(last.Err().Error(), "read failed") {
+		t.Fatalf("expected the read error last, got %v", last)
+	}
+}
+
 func TestCatBasic(t *testing.T) {
 	ctx := context.Background()
 	node, api, err := makeAPI(ctx)
@@ -309,6 +559,76 @@ func TestLsEmptyDir(t *testing.T) {
 	}
 }
 
+func TestLsPaging(t *testing.T) {
+	ctx := context.Background()
+	node, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := unixfs.EmptyDirNode()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		err = dir.AddNodeLink(name, unixfs.EmptyDirNode())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = node.DAG.Add(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := coreapi.ResolvedPath("/ipfs/"+dir.Cid().String(), nil, nil)
+
+	links, err := api.Unixfs().Ls(ctx, p, options.Unixfs.After("a"), options.Unixfs.Limit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 || links[0].Name != "b" || links[1].Name != "c" {
+		t.Fatalf("expected links b and c, got %v", links)
+	}
+
+	links, err = api.Unixfs().Ls(ctx, p, options.Unixfs.After("d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 0 {
+		t.Fatalf("expected no links after the last one, got %d", len(links))
+	}
+
+	_, err = api.Unixfs().Ls(ctx, p, options.Unixfs.After("e"))
+	if err == nil {
+		t.Fatal("expected an error for a cursor which isn't a link")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	ctx := context.Background()
+	node, api, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := strings.NewReader("content-of-file")
+	k, _, err := coreunix.AddWrapped(node, r, "name-of-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := coreapi.ResolvedPath("/ipfs/"+strings.Split(k, "/")[0], nil, nil)
+
+	lnk, err := api.Unixfs().Lookup(ctx, p, "name-of-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lnk.Name != "name-of-file" || lnk.Cid.String() != "QmX3qQVKxDGz3URVC3861Z3CKtQKGBn6ffXRBBWGMFz9Lr" {
+		t.Fatalf("expected the link to name-of-file, got %s %s", lnk.Name, lnk.Cid)
+	}
+
+	_, err = api.Unixfs().Lookup(ctx, p, "other-file")
+	if err == nil {
+		t.Fatal("expected an error for a missing link")
+	}
+}
+
 // TODO(lgierth) this should test properly, with len(links) > 0
 func TestLsNonUnixfs(t *testing.T) {
 	ctx := context.Background()
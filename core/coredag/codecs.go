@@ -0,0 +1,24 @@
+package coredag
+
+import (
+	"fmt"
+
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// RegisterCodec names a multicodec go-cid doesn't know, like the codec of a
+// format added by a plugin, so that it can be given by name to commands like
+// 'ipfs block put --format' and shows up in 'ipfs cid codecs'. Registering
+// the same name and code again does nothing.
+func RegisterCodec(name string, code uint64) error {
+	if c, ok := cid.Codecs[name]; ok && c != code {
+		return fmt.Errorf("codec name %q is already used by codec 0x%x", name, c)
+	}
+	if n, ok := cid.CodecToStr[code]; ok && n != name {
+		return fmt.Errorf("codec 0x%x is already named %q", code, n)
+	}
+
+	cid.Codecs[name] = code
+	cid.CodecToStr[code] = name
+	return nil
+}
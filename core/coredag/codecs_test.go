@@ -0,0 +1,35 @@
+package coredag
+
+import (
+	"testing"
+
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+func TestRegisterCodec(t *testing.T) {
+	const code = 0x300001
+	defer func() {
+		delete(cid.Codecs, "test-codec")
+		delete(cid.CodecToStr, code)
+	}()
+
+	if err := RegisterCodec("test-codec", code); err != nil {
+		t.Fatal(err)
+	}
+	if cid.Codecs["test-codec"] != code || cid.CodecToStr[code] != "test-codec" {
+		t.Fatal("codec wasn't registered")
+	}
+
+	if err := RegisterCodec("test-codec", code); err != nil {
+		t.Fatalf("registering the same codec again should work: %s", err)
+	}
+	if err := RegisterCodec("test-codec", code+1); err == nil {
+		t.Fatal("expected an error for a name already used")
+	}
+	if err := RegisterCodec("dag-cbor", code); err == nil {
+		t.Fatal("expected an error for a name already used by go-cid")
+	}
+	if err := RegisterCodec("other-codec", cid.DagCBOR); err == nil {
+		t.Fatal("expected an error for a code already named")
+	}
+}
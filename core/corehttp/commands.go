@@ -1,6 +1,7 @@
 package corehttp
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -9,12 +10,15 @@ import (
 	"strconv"
 	"strings"
 
+	apiauth "github.com/ipfs/go-ipfs/apiauth"
 	oldcmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
 	corecommands "github.com/ipfs/go-ipfs/core/commands"
 	path "github.com/ipfs/go-ipfs/path"
 	config "github.com/ipfs/go-ipfs/repo/config"
+	pininfo "github.com/ipfs/go-ipfs/repo/pininfo"
 
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
 	cmdsHttp "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds/http"
 )
@@ -133,11 +137,262 @@ func commandsOption(cctx oldcmds.Context, command *cmds.Command) ServeOption {
 		patchCORSVars(cfg, l.Addr())
 
 		cmdHandler := cmdsHttp.NewHandler(&cctx, command, cfg)
-		mux.Handle(APIPath+"/", cmdHandler)
+		legacy := legacyOriginPolicy(cfg, rcfg)
+		mux.Handle(APIPath+"/", originHandler(n, legacy, authHandler(n, shutdownHandler(n, cmdHandler))))
 		return mux, nil
 	}
 }
 
+// legacyOriginPolicy turns the origins allowed through API.HTTPHeaders, the
+// API_ORIGIN env variable and the localhost defaults into origin rules. A
+// wildcard origin only grants access to read-only commands, state-changing
+// commands require an explicit API.Origins entry.
+func legacyOriginPolicy(c *cmdsHttp.ServerConfig, nc *config.Config) apiauth.OriginPolicy {
+	creds := false
+	for _, v := range nc.API.HTTPHeaders[cmdsHttp.ACACredentials] {
+		creds = strings.ToLower(v) == "true"
+	}
+
+	var p apiauth.OriginPolicy
+	for _, o := range c.AllowedOrigins() {
+		r := apiauth.OriginRule{Pattern: o, AllowCredentials: creds}
+		if o == "*" {
+			log.Warning("API.HTTPHeaders allows any origin, restricting cross-origin requests to read-only commands. Use API.Origins to allow more.")
+			r.ReadOnly = true
+		}
+		p = append(p, r)
+	}
+	return p
+}
+
+func apiOriginPolicy(cfg *config.Config) apiauth.OriginPolicy {
+	p := make(apiauth.OriginPolicy, 0, len(cfg.API.Origins))
+	for _, o := range cfg.API.Origins {
+		p = append(p, apiauth.OriginRule{
+			Pattern:          o.Origin,
+			AllowCredentials: o.AllowCredentials,
+			ReadOnly:         o.ReadOnly,
+		})
+	}
+	return p
+}
+
+// originHandler verifies the origin of browser requests to the API. Requests
+// from origins without a matching rule are rejected, as are state-changing
+// commands from origins restricted to read-only access. Allowed requests get
+// the CORS headers for their origin; the commands handler then treats them
+// like any non-browser request.
+func originHandler(n *core.IpfsNode, legacy apiauth.OriginPolicy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := apiauth.RequestOrigin(r.Header.Get("Origin"), r.Header.Get("Referer"))
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rcfg, err := n.Repo.Config()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rule, ok := append(apiOriginPolicy(rcfg), legacy...).Lookup(origin)
+		if !ok {
+			http.Error(w, fmt.Sprintf("cross-origin request from %s rejected", origin), http.StatusForbidden)
+			return
+		}
+
+		cmdPath := apiCommandPath(r)
+		if rule.ReadOnly && r.Method != "OPTIONS" && !apiauth.IsReadOnly(cmdPath) {
+			http.Error(w, fmt.Sprintf("origin %s may only run read-only commands", origin), http.StatusForbidden)
+			return
+		}
+
+		h := w.Header()
+		h.Set(cmdsHttp.ACAOrigin, origin)
+		if rule.AllowCredentials {
+			h.Set(cmdsHttp.ACACredentials, "true")
+		}
+
+		if r.Method == "OPTIONS" {
+			h.Set(cmdsHttp.ACAMethods, "GET, POST, PUT")
+			if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", reqHeaders)
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		r.Header.Del("Origin")
+		r.Header.Del("Referer")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authHandler checks that requests to the API carry a token allowed to
+// invoke the requested command, and that the API.Authorizations policy
+// permits it. Tokens and policy are read from the config on every request so
+// that they can be changed while the daemon runs. The context of the requests
+// tells whether they come from the ipfs command, which proves it with the
+// private key of the node, for the records of the pins.
+func authHandler(n *core.IpfsNode, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// CORS preflight requests never carry credentials.
+		if r.Method == "OPTIONS" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rcfg, err := n.Repo.Config()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		auth, err := apiAuthenticator(rcfg)
+		if err != nil {
+			log.Error("invalid API.Tokens config: ", err)
+			http.Error(w, "invalid API token configuration", http.StatusInternalServerError)
+			return
+		}
+
+		origin := pininfo.OriginAPI
+		if apiauth.IsCLI(r, rcfg.Identity.PrivKey) {
+			origin = pininfo.OriginCLI
+		}
+		r = r.WithContext(pininfo.WithOrigin(r.Context(), origin))
+
+		cmdPath := apiCommandPath(r)
+		identity := ""
+		if auth.Enabled() {
+			tok, ok := auth.Authenticate(apiauth.SecretFromRequest(r))
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="ipfs"`)
+				http.Error(w, "missing or invalid API token", http.StatusUnauthorized)
+				return
+			}
+
+			if !tok.Allows(cmdPath) {
+				http.Error(w, fmt.Sprintf("API token %q is not allowed to run this command", tok.Name), http.StatusForbidden)
+				return
+			}
+
+			identity = tok.Name
+			r = r.WithContext(apiauth.WithToken(r.Context(), tok))
+		}
+
+		if !apiPolicy(rcfg).Permits(identity, cmdPath) {
+			http.Error(w, fmt.Sprintf("command %q is forbidden by API.Authorizations", strings.Join(cmdPath, " ")), http.StatusForbidden)
+			return
+		}
+
+		q, err := apiQuota(rcfg, r)
+		if err != nil {
+			log.Error("invalid API.Quotas config: ", err)
+			http.Error(w, "invalid API quota configuration", http.StatusInternalServerError)
+			return
+		}
+		if q != nil {
+			r = r.WithContext(apiauth.WithQuota(r.Context(), q))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func apiPolicy(cfg *config.Config) apiauth.Policy {
+	p := make(apiauth.Policy, len(cfg.API.Authorizations))
+	for id, a := range cfg.API.Authorizations {
+		p[id] = apiauth.Rule{Allow: a.Allow, Deny: a.Deny}
+	}
+	return p
+}
+
+// apiQuota returns the storage quota of the caller of r: the quota of its
+// token, else the one of API.Quotas for its identity, else the "*" one. The
+// callers without a token are identified by the host of their address.
+func apiQuota(cfg *config.Config, r *http.Request) (*apiauth.Quota, error) {
+	var identity string
+	if tok, ok := apiauth.FromContext(r.Context()); ok {
+		if tok.Quota != 0 {
+			return &apiauth.Quota{Identity: tok.Name, Bytes: tok.Quota}, nil
+		}
+		identity = tok.Name
+	} else {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		identity = "addr:" + host
+	}
+
+	s, ok := cfg.API.Quotas[identity]
+	if !ok {
+		s, ok = cfg.API.Quotas[apiauth.AnyIdentity]
+	}
+	if !ok || s == "" {
+		return nil, nil
+	}
+	b, err := humanize.ParseBytes(s)
+	if err != nil {
+		return nil, fmt.Errorf("quota of %q: %s", identity, err)
+	}
+	return &apiauth.Quota{Identity: identity, Bytes: b}, nil
+}
+
+func apiAuthenticator(cfg *config.Config) (*apiauth.Authenticator, error) {
+	tokens := make([]apiauth.Token, 0, len(cfg.API.Tokens))
+	for name, t := range cfg.API.Tokens {
+		scopes := make([]apiauth.Scope, len(t.Scopes))
+		for i, s := range t.Scopes {
+			scopes[i] = apiauth.Scope(s)
+		}
+		var quota uint64
+		if t.Quota != "" {
+			q, err := humanize.ParseBytes(t.Quota)
+			if err != nil {
+				return nil, fmt.Errorf("API token %q: invalid quota: %s", name, err)
+			}
+			quota = q
+		}
+		tokens = append(tokens, apiauth.Token{Name: name, Hash: t.Hash, Scopes: scopes, Quota: quota})
+	}
+	return apiauth.NewAuthenticator(tokens)
+}
+
+// shutdownHandler cancels read-only commands, which include streams like
+// 'ipfs events', as soon as the node starts shutting down. Other commands are
+// left to complete within the shutdown grace period.
+func shutdownHandler(n *core.IpfsNode, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !apiauth.IsReadOnly(apiCommandPath(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		go func() {
+			select {
+			case <-n.Process().Closing():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// apiCommandPath returns the command path of an API request, e.g.
+// /api/v0/pin/add -> [pin add].
+func apiCommandPath(r *http.Request) []string {
+	p := strings.Trim(strings.TrimPrefix(r.URL.Path, APIPath), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
 // CommandsOption constructs a ServerOption for hooking the commands into the
 // HTTP server.
 func CommandsOption(cctx oldcmds.Context) ServeOption {
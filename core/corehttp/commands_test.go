@@ -0,0 +1,54 @@
+package corehttp
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	apiauth "github.com/ipfs/go-ipfs/apiauth"
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+func TestAPIQuota(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.API.Quotas = map[string]string{
+		"*":        "1kB",
+		"tenant-b": "2kB",
+	}
+
+	cases := []struct {
+		tok      *apiauth.Token
+		remote   string
+		identity string
+		bytes    uint64
+	}{
+		{nil, "10.0.0.1:4001", "addr:10.0.0.1", 1000},
+		{nil, "10.0.0.2:4001", "addr:10.0.0.2", 1000},
+		{&apiauth.Token{Name: "tenant-a"}, "10.0.0.1:4001", "tenant-a", 1000},
+		{&apiauth.Token{Name: "tenant-b"}, "10.0.0.1:4001", "tenant-b", 2000},
+		{&apiauth.Token{Name: "tenant-b", Quota: 3000}, "10.0.0.1:4001", "tenant-b", 3000},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "/api/v0/add", nil)
+		r.RemoteAddr = c.remote
+		if c.tok != nil {
+			r = r.WithContext(apiauth.WithToken(r.Context(), c.tok))
+		}
+
+		q, err := apiQuota(cfg, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q == nil || q.Identity != c.identity || q.Bytes != c.bytes {
+			t.Errorf("expected a quota of %d bytes for %q, got %+v", c.bytes, c.identity, q)
+		}
+	}
+
+	cfg.API.Quotas = nil
+	q, err := apiQuota(cfg, httptest.NewRequest("POST", "/api/v0/add", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != nil {
+		t.Errorf("expected no quota, got %+v", q)
+	}
+}
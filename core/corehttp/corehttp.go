@@ -5,12 +5,16 @@ high-level HTTP interfaces to IPFS.
 package corehttp
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	core "github.com/ipfs/go-ipfs/core"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
 	manet "gx/ipfs/QmNqRnejxJxjRroz7buhrjfU8i3yNBLa81hFtmf2pXEffN/go-multiaddr-net"
 	"gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
 	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
@@ -70,7 +74,12 @@ func Serve(node *core.IpfsNode, lis net.Listener, options ...ServeOption) error
 		return err
 	}
 
-	addr, err := manet.FromNetAddr(lis.Addr())
+	var addr fmt.Stringer = lis.Addr()
+	if maddr, err := manet.FromNetAddr(lis.Addr()); err == nil {
+		addr = maddr
+	}
+
+	grace, err := shutdownGracePeriod(node)
 	if err != nil {
 		return err
 	}
@@ -78,6 +87,7 @@ func Serve(node *core.IpfsNode, lis net.Listener, options ...ServeOption) error
 	// if the server exits beforehand
 	var serverError error
 	serverExited := make(chan struct{})
+	drained := make(chan struct{})
 
 	select {
 	case <-node.Process().Closing():
@@ -85,9 +95,21 @@ func Serve(node *core.IpfsNode, lis net.Listener, options ...ServeOption) error
 	default:
 	}
 
+	// the handlers of the requests in flight, which outlive Close
+	var inflight sync.WaitGroup
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inflight.Add(1)
+		defer inflight.Done()
+		handler.ServeHTTP(w, r)
+	})}
 	node.Process().Go(func(p goprocess.Process) {
-		serverError = http.Serve(lis, handler)
+		serverError = srv.Serve(lis)
 		close(serverExited)
+		if serverError == http.ErrServerClosed {
+			// keep the node from tearing down until the requests in
+			// flight are done with it
+			<-drained
+		}
 	})
 
 	// wait for server to exit.
@@ -96,24 +118,65 @@ func Serve(node *core.IpfsNode, lis net.Listener, options ...ServeOption) error
 
 	// if node being closed before server exits, close server
 	case <-node.Process().Closing():
-		log.Infof("server at %s terminating...", addr)
-
-		lis.Close()
-
-	outer:
-		for {
-			// wait until server exits
-			select {
-			case <-serverExited:
-				// if the server exited as we are closing, we really dont care about errors
-				serverError = nil
-				break outer
-			case <-time.After(5 * time.Second):
-				log.Infof("waiting for server at %s to terminate...", addr)
-			}
+		log.Infof("server at %s terminating, waiting up to %s for requests in flight...", addr, grace)
+
+		ctx, cancel := context.WithTimeout(context.Background(), grace)
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Warningf("server at %s: requests still in flight after %s, closing them", addr, grace)
+			srv.Close()
+			waitHandlers(&inflight, handlerExitTimeout)
 		}
+		cancel()
+
+		// the requests may have left changes to MFS and the pins in
+		// memory
+		ctx, cancel = context.WithTimeout(context.Background(), handlerExitTimeout)
+		if err := node.Checkpoint(ctx); err != nil {
+			log.Errorf("server at %s: failed to save the state of the node: %s", addr, err)
+		}
+		cancel()
+		close(drained)
+
+		<-serverExited
+		// the server exited as we are closing, we really dont care about errors
+		serverError = nil
 	}
 
 	log.Infof("server at %s terminated", addr)
 	return serverError
 }
+
+// handlerExitTimeout is how long the handlers of the requests canceled on
+// shutdown are given to return.
+const handlerExitTimeout = 10 * time.Second
+
+// waitHandlers waits for the handlers of wg to return, at most timeout.
+func waitHandlers(wg *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Warningf("request handlers still running after %s", timeout)
+	}
+}
+
+// shutdownGracePeriod returns how long requests in flight are given to
+// complete when the node shuts down.
+func shutdownGracePeriod(node *core.IpfsNode) (time.Duration, error) {
+	cfg, err := node.Repo.Config()
+	if err != nil {
+		return 0, err
+	}
+	if cfg.Shutdown.GracePeriod == "" {
+		return config.DefaultShutdownGracePeriod, nil
+	}
+	grace, err := time.ParseDuration(cfg.Shutdown.GracePeriod)
+	if err != nil {
+		return 0, fmt.Errorf("parsing Shutdown.GracePeriod: %s", err)
+	}
+	return grace, nil
+}
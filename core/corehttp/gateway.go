@@ -1,35 +1,68 @@
 package corehttp
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
 
 	core "github.com/ipfs/go-ipfs/core"
 	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	path "github.com/ipfs/go-ipfs/path"
 	config "github.com/ipfs/go-ipfs/repo/config"
 
 	id "gx/ipfs/QmUEAR2pS7fP1GPseS3i8MWFyENs7oDp4CZrgn8FCjbsBu/go-libp2p/p2p/protocol/identify"
 )
 
 type GatewayConfig struct {
-	Headers      map[string][]string
-	Writable     bool
-	PathPrefixes []string
+	Headers       map[string][]string
+	Writable      bool
+	PathPrefixes  []string
+	Precompressed map[string]bool
+	SignResponses bool
+	// RetrievalBudget bounds the time the GET and HEAD requests wait for
+	// their content.
+	RetrievalBudget config.GatewayRetrievalBudget
+	// StaleWhileRevalidate maps the hostnames to how the last known values
+	// of the IPNS names are served for them.
+	StaleWhileRevalidate map[string]config.GatewayStaleWhileRevalidate
+	// FollowSymlinks follows the unixfs symlinks of the paths requested.
+	FollowSymlinks bool
 }
 
 func GatewayOption(writable bool, paths ...string) ServeOption {
 	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
-		cfg, err := n.Repo.Config()
-		if err != nil {
-			return nil, err
-		}
+		api := coreapi.NewCoreAPI(n)
+		comp := new(compressor)
+		stale := newStaleValues(n.Context(), func(ctx context.Context, name string) (path.Path, error) {
+			return n.Namesys.Resolve(ctx, name)
+		})
+
+		// the config is read on every request so that changes to the
+		// gateway config apply when the config is reloaded
+		gateway := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg, err := n.Repo.Config()
+			if err != nil {
+				webErrorWithCode(w, "could not read config", err, http.StatusInternalServerError)
+				return
+			}
 
-		gateway := newGatewayHandler(n, GatewayConfig{
-			Headers:      cfg.Gateway.HTTPHeaders,
-			Writable:     writable,
-			PathPrefixes: cfg.Gateway.PathPrefixes,
-		}, coreapi.NewCoreAPI(n))
+			w, done := comp.wrap(w, r, cfg.Gateway.Compression)
+			defer done()
+
+			h := newGatewayHandler(n, GatewayConfig{
+				Headers:              cfg.Gateway.HTTPHeaders,
+				Writable:             writable,
+				PathPrefixes:         cfg.Gateway.PathPrefixes,
+				Precompressed:        cfg.Gateway.Precompressed,
+				SignResponses:        cfg.Gateway.SignResponses,
+				RetrievalBudget:      cfg.Gateway.RetrievalBudget,
+				StaleWhileRevalidate: cfg.Gateway.StaleWhileRevalidate,
+				FollowSymlinks:       cfg.Gateway.FollowSymlinks,
+			}, api)
+			h.stale = stale
+			h.ServeHTTP(w, r)
+		})
 
 		for _, p := range paths {
 			mux.Handle(p+"/", gateway)
@@ -0,0 +1,272 @@
+package corehttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	blockservice "github.com/ipfs/go-ipfs/blockservice"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	notif "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing/notifications"
+)
+
+// The defaults of the retrieval budget of the gateway requests.
+const (
+	defaultRoutingBudget    = time.Minute
+	defaultNoProgressBudget = 2 * time.Minute
+)
+
+// budgetCheckInterval is how often the budget of a request is checked.
+var budgetCheckInterval = time.Second
+
+// retrievalBudget bounds the time a request waits for its content, the
+// bounds disabled when zero.
+type retrievalBudget struct {
+	// Routing is the longest time spent routing without receiving a block.
+	Routing time.Duration
+	// NoProgress is the longest time without receiving a block, the time
+	// spent writing the response to the client excluded.
+	NoProgress time.Duration
+}
+
+func parseRetrievalBudget(cfg config.GatewayRetrievalBudget) retrievalBudget {
+	return retrievalBudget{
+		Routing:    parseGatewayDuration("Gateway.RetrievalBudget.Routing", cfg.Routing, defaultRoutingBudget),
+		NoProgress: parseGatewayDuration("Gateway.RetrievalBudget.NoProgress", cfg.NoProgress, defaultNoProgressBudget),
+	}
+}
+
+// parseGatewayDuration parses the duration s of the config setting name,
+// def when it's unset or invalid.
+func parseGatewayDuration(name, s string, def time.Duration) time.Duration {
+	if s == "" {
+		return def
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d < 0 {
+		err = fmt.Errorf("negative duration %q", s)
+	}
+	if err != nil {
+		log.Warningf("failure to parse config setting %s: %s", name, err)
+		return def
+	}
+	return d
+}
+
+// budgetWatch watches the retrieval of the content of a request, from the
+// blocks it gets and the events of its routing queries, and cancels it when
+// it exceeds its budget.
+type budgetWatch struct {
+	budget retrievalBudget
+	now    func() time.Time
+
+	mu        sync.Mutex
+	start     time.Time
+	blocks    int
+	lastBlock time.Time
+	// routingStart and routingLast are the times of the first and the last
+	// routing events since the last block, zero when there are none.
+	routingStart time.Time
+	routingLast  time.Time
+	// peers is the number of peers which answered the routing queries.
+	peers int
+	// writing is the time spent writing the response since the last
+	// block, not counted as waiting for blocks, and writeStart the start of
+	// the write in progress, zero when there is none.
+	writing    time.Duration
+	writeStart time.Time
+	// exceeded tells the budget exceeded, empty while there is none.
+	exceeded string
+	elapsed  time.Duration
+}
+
+// watchBudget returns ctx canceled when the retrieval exceeds b, and the
+// function to call once the request is served.
+func watchBudget(ctx context.Context, b retrievalBudget) (context.Context, *budgetWatch, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &budgetWatch{budget: b, now: time.Now}
+	w.start = w.now()
+
+	events := make(chan *notif.QueryEvent)
+	ctx = notif.RegisterForQueryEvents(ctx, events)
+	ctx = blockservice.WithProgress(ctx, w.blockReceived)
+
+	go func() {
+		ticker := time.NewTicker(budgetCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case ev := <-events:
+				w.routingEvent(ev)
+			case <-ticker.C:
+				if w.check() {
+					cancel()
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ctx, w, cancel
+}
+
+func (w *budgetWatch) blockReceived(blocks.Block) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.blocks++
+	w.lastBlock = w.now()
+	w.routingStart = time.Time{}
+	w.routingLast = time.Time{}
+	w.writing = 0
+	if !w.writeStart.IsZero() {
+		w.writeStart = w.lastBlock
+	}
+}
+
+// startWrite and endWrite bracket the writes of the response, the time a
+// slow client takes to read it not being spent waiting for blocks.
+func (w *budgetWatch) startWrite() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writeStart = w.now()
+}
+
+func (w *budgetWatch) endWrite() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writing += w.now().Sub(w.writeStart)
+	w.writeStart = time.Time{}
+}
+
+func (w *budgetWatch) routingEvent(ev *notif.QueryEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := w.now()
+	if w.routingStart.IsZero() {
+		w.routingStart = now
+	}
+	w.routingLast = now
+	if ev.Type == notif.PeerResponse {
+		w.peers++
+	}
+}
+
+// check returns whether the budget was exceeded, recording which one.
+func (w *budgetWatch) check() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.exceeded != "" {
+		return true
+	}
+
+	now := w.now()
+	routing := w.routingLast.Sub(w.routingStart)
+	last := w.lastBlock
+	if last.IsZero() {
+		last = w.start
+	}
+	waited := now.Sub(last) - w.writing
+	if !w.writeStart.IsZero() {
+		waited -= now.Sub(w.writeStart)
+	}
+
+	switch {
+	case w.budget.Routing > 0 && routing >= w.budget.Routing:
+		w.exceeded = fmt.Sprintf("spent %s routing without receiving a block (Gateway.RetrievalBudget.Routing)", w.budget.Routing)
+	case w.budget.NoProgress > 0 && waited >= w.budget.NoProgress:
+		w.exceeded = fmt.Sprintf("received no block for %s (Gateway.RetrievalBudget.NoProgress)", w.budget.NoProgress)
+	default:
+		return false
+	}
+	w.elapsed = now.Sub(w.start)
+	return true
+}
+
+// exhausted returns whether the budget was exceeded.
+func (w *budgetWatch) exhausted() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.exceeded != ""
+}
+
+// diagnostic describes the budget exceeded by the request for path.
+func (w *budgetWatch) diagnostic(path string) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	phase := "fetching"
+	if !w.routingStart.IsZero() {
+		phase = "routing"
+	}
+	lastBlock := "never"
+	if !w.lastBlock.IsZero() {
+		lastBlock = fmt.Sprintf("%s after the start", w.lastBlock.Sub(w.start).Round(time.Millisecond))
+	}
+	return fmt.Sprintf("%s: retrieval budget exceeded: %s\n"+
+		"phase: %s\nelapsed: %s\nblocks received: %d\nlast block: %s\nrouting peers answered: %d\n",
+		path, w.exceeded, phase, w.elapsed.Round(time.Millisecond), w.blocks, lastBlock, w.peers)
+}
+
+// budgetWriter is a ResponseWriter replacing the error response of a request
+// which exceeded its budget with a 504 Gateway Timeout telling why.
+type budgetWriter struct {
+	http.ResponseWriter
+	watch *budgetWatch
+	path  string
+
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (w *budgetWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if code < 400 || !w.watch.exhausted() {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.timeout()
+}
+
+func (w *budgetWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.timedOut {
+		// the error the request failed with, replaced by the diagnostic
+		return len(b), nil
+	}
+	w.watch.startWrite()
+	defer w.watch.endWrite()
+	return w.ResponseWriter.Write(b)
+}
+
+// finish sends the 504 when the request exceeded its budget without
+// responding.
+func (w *budgetWriter) finish() {
+	if !w.wroteHeader && w.watch.exhausted() {
+		w.wroteHeader = true
+		w.timeout()
+	}
+}
+
+func (w *budgetWriter) timeout() {
+	w.timedOut = true
+	msg := w.watch.diagnostic(w.path)
+	log.Warningf("gateway request for %s exceeded its retrieval budget", w.path)
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Del("Etag")
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	h.Set("Cache-Control", "no-cache")
+	w.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+	fmt.Fprint(w.ResponseWriter, msg)
+}
@@ -0,0 +1,155 @@
+package corehttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	notif "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing/notifications"
+)
+
+func TestRetrievalBudget(t *testing.T) {
+	defer func(d time.Duration) { budgetCheckInterval = d }(budgetCheckInterval)
+	budgetCheckInterval = 5 * time.Millisecond
+
+	t.Run("routing", func(t *testing.T) {
+		ctx, watch, stop := watchBudget(context.Background(), retrievalBudget{Routing: 50 * time.Millisecond})
+		defer stop()
+
+		// a lookup which never ends
+		go func() {
+			for ctx.Err() == nil {
+				notif.PublishQueryEvent(ctx, &notif.QueryEvent{Type: notif.PeerResponse})
+				time.Sleep(time.Millisecond)
+			}
+		}()
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("the request wasn't canceled")
+		}
+
+		rec := httptest.NewRecorder()
+		bw := &budgetWriter{ResponseWriter: rec, watch: watch, path: "/ipns/example.com"}
+		webError(bw, "ipfs resolve -r /ipns/example.com", ctx.Err(), http.StatusNotFound)
+		bw.finish()
+
+		if rec.Code != http.StatusGatewayTimeout {
+			t.Fatalf("expected a 504, got %d", rec.Code)
+		}
+		body := rec.Body.String()
+		for _, s := range []string{"Gateway.RetrievalBudget.Routing", "phase: routing", "blocks received: 0"} {
+			if !strings.Contains(body, s) {
+				t.Errorf("expected %q in the diagnostic, got:\n%s", s, body)
+			}
+		}
+		if strings.Contains(body, "context canceled") {
+			t.Errorf("expected the error to be replaced by the diagnostic, got:\n%s", body)
+		}
+	})
+
+	t.Run("no progress", func(t *testing.T) {
+		ctx, watch, stop := watchBudget(context.Background(), retrievalBudget{NoProgress: 100 * time.Millisecond})
+		defer stop()
+
+		// the blocks keep the request alive
+		for i := 0; i < 10; i++ {
+			watch.blockReceived(nil)
+			time.Sleep(10 * time.Millisecond)
+		}
+		if ctx.Err() != nil {
+			t.Fatal("the request was canceled while receiving blocks")
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("the request wasn't canceled")
+		}
+
+		// the handler gave up without responding
+		rec := httptest.NewRecorder()
+		bw := &budgetWriter{ResponseWriter: rec, watch: watch, path: "/ipfs/QmFoo"}
+		bw.finish()
+
+		if rec.Code != http.StatusGatewayTimeout {
+			t.Fatalf("expected a 504, got %d", rec.Code)
+		}
+		body := rec.Body.String()
+		for _, s := range []string{"Gateway.RetrievalBudget.NoProgress", "phase: fetching", "blocks received: 10"} {
+			if !strings.Contains(body, s) {
+				t.Errorf("expected %q in the diagnostic, got:\n%s", s, body)
+			}
+		}
+	})
+
+	t.Run("slow client", func(t *testing.T) {
+		ctx, watch, stop := watchBudget(context.Background(), retrievalBudget{NoProgress: 50 * time.Millisecond})
+		defer stop()
+
+		// a client reading the response slower than the budget
+		bw := &budgetWriter{ResponseWriter: &slowWriter{httptest.NewRecorder(), 100 * time.Millisecond}, watch: watch, path: "/ipfs/QmFoo"}
+		for i := 0; i < 3; i++ {
+			watch.blockReceived(nil)
+			if _, err := bw.Write([]byte("block")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if ctx.Err() != nil {
+			t.Fatal("the request was canceled while writing to a slow client")
+		}
+	})
+
+	t.Run("within budget", func(t *testing.T) {
+		ctx, watch, stop := watchBudget(context.Background(), retrievalBudget{Routing: time.Hour, NoProgress: time.Hour})
+		defer stop()
+
+		notif.PublishQueryEvent(ctx, &notif.QueryEvent{Type: notif.PeerResponse})
+		rec := httptest.NewRecorder()
+		bw := &budgetWriter{ResponseWriter: rec, watch: watch, path: "/ipfs/QmFoo"}
+		webError(bw, "ipfs cat /ipfs/QmFoo", errors.New("no link named foo"), http.StatusNotFound)
+		bw.finish()
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected the error of the handler, got %d", rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "no link named foo") {
+			t.Fatalf("expected the error of the handler, got:\n%s", rec.Body.String())
+		}
+	})
+}
+
+func TestParseRetrievalBudget(t *testing.T) {
+	b := parseRetrievalBudget(config.GatewayRetrievalBudget{})
+	if b.Routing != defaultRoutingBudget || b.NoProgress != defaultNoProgressBudget {
+		t.Fatalf("expected the defaults, got %+v", b)
+	}
+
+	b = parseRetrievalBudget(config.GatewayRetrievalBudget{Routing: "0", NoProgress: "30s"})
+	if b.Routing != 0 || b.NoProgress != 30*time.Second {
+		t.Fatalf("expected no routing budget and 30s without progress, got %+v", b)
+	}
+
+	b = parseRetrievalBudget(config.GatewayRetrievalBudget{Routing: "soon", NoProgress: "-1s"})
+	if b.Routing != defaultRoutingBudget || b.NoProgress != defaultNoProgressBudget {
+		t.Fatalf("expected the defaults for invalid durations, got %+v", b)
+	}
+}
+
+// slowWriter is a ResponseWriter of a client slow to read the response.
+type slowWriter struct {
+	http.ResponseWriter
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(b []byte) (int, error) {
+	time.Sleep(w.delay)
+	return w.ResponseWriter.Write(b)
+}
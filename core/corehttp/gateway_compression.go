@@ -0,0 +1,256 @@
+package corehttp
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+	zstd "github.com/ipfs/go-ipfs/thirdparty/zstd"
+)
+
+// The defaults of the compression of the gateway responses.
+const (
+	defaultCompressionMinSize = 1024
+	defaultCompressionLevel   = 5
+)
+
+// defaultCompressionEncodings are the content encodings used by default, in
+// order of preference: gzip compresses more, zstd takes less CPU.
+var defaultCompressionEncodings = []string{"gzip", "zstd"}
+
+// defaultCompressionTypes are the content types compressed by default.
+var defaultCompressionTypes = []string{
+	"text/*",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+// compressor compresses the gateway responses on the fly, with gzip or zstd.
+type compressor struct {
+	// active is the number of responses being compressed.
+	active int32
+}
+
+// acquire reserves the compression of a response, unless max responses are
+// already being compressed.
+func (c *compressor) acquire(max int) bool {
+	if int(atomic.AddInt32(&c.active, 1)) > max {
+		atomic.AddInt32(&c.active, -1)
+		return false
+	}
+	return true
+}
+
+func (c *compressor) release() {
+	atomic.AddInt32(&c.active, -1)
+}
+
+// wrap returns the ResponseWriter to write the response to r with, which
+// compresses it when cfg allows it and the client accepts it, and the
+// function to call once the response is written.
+func (c *compressor) wrap(w http.ResponseWriter, r *http.Request, cfg config.GatewayCompression) (http.ResponseWriter, func()) {
+	if !cfg.Enabled || (r.Method != "GET" && r.Method != "HEAD") {
+		return w, func() {}
+	}
+
+	// the compressed responses vary with the Accept-Encoding header,
+	// whether this one is compressed or not
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	// the ranges are of the uncompressed content, and the HEAD responses
+	// have the length of the uncompressed content
+	if r.Method != "GET" || r.Header.Get("Range") != "" {
+		return w, func() {}
+	}
+	enc := compressionEncoding(r, cfg)
+	if enc == "" {
+		return w, func() {}
+	}
+
+	cw := &compressWriter{ResponseWriter: w, c: c, cfg: cfg, enc: enc}
+	return cw, cw.close
+}
+
+// compressionEncoding returns the first of the encodings of cfg which r
+// accepts, or "" if it accepts none.
+func compressionEncoding(r *http.Request, cfg config.GatewayCompression) string {
+	encs := cfg.Encodings
+	if len(encs) == 0 {
+		encs = defaultCompressionEncodings
+	}
+	for _, enc := range encs {
+		enc = strings.ToLower(enc)
+		switch enc {
+		case "gzip", "zstd":
+		default:
+			log.Warningf("unknown Gateway.Compression.Encodings %q", enc)
+			continue
+		}
+		if acceptsEncoding(r, enc) {
+			return enc
+		}
+	}
+	return ""
+}
+
+// encoder is a compressing writer.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compressWriter is a ResponseWriter compressing the response, when its
+// status, content type and size allow it.
+type compressWriter struct {
+	http.ResponseWriter
+	c   *compressor
+	cfg config.GatewayCompression
+	enc string
+
+	wroteHeader bool
+	zw          encoder
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if code == http.StatusOK && w.compressible() {
+		max := w.cfg.MaxConcurrent
+		if max <= 0 {
+			max = runtime.NumCPU()
+		}
+		if w.c.acquire(max) {
+			w.zw = w.newEncoder()
+
+			h := w.Header()
+			h.Set("Content-Encoding", w.enc)
+			h.Del("Content-Length")
+			// the compressed content isn't byte for byte the one the
+			// ETag names
+			if etag := h.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("Etag", "W/"+etag)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// newEncoder returns the writer compressing the response with its encoding.
+func (w *compressWriter) newEncoder() encoder {
+	if w.enc == "zstd" {
+		return zstd.NewWriter(w.ResponseWriter)
+	}
+
+	level := w.cfg.Level
+	if level == 0 {
+		level = defaultCompressionLevel
+	}
+	zw, err := gzip.NewWriterLevel(w.ResponseWriter, level)
+	if err != nil {
+		log.Warningf("invalid Gateway.Compression.Level %d: %s", level, err)
+		zw = gzip.NewWriter(w.ResponseWriter)
+	}
+	return zw
+}
+
+// compressible returns whether the response can be compressed, from its
+// headers.
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		// pre-compressed
+		return false
+	}
+
+	if cl := h.Get("Content-Length"); cl != "" {
+		size, err := strconv.ParseInt(cl, 10, 64)
+		if err != nil {
+			return false
+		}
+		min := w.cfg.MinSize
+		if min == 0 {
+			min = defaultCompressionMinSize
+		}
+		if size < min || (w.cfg.MaxSize > 0 && size > w.cfg.MaxSize) {
+			return false
+		}
+	}
+
+	types := w.cfg.Types
+	if len(types) == 0 {
+		types = defaultCompressionTypes
+	}
+	return matchesContentType(h.Get("Content-Type"), types)
+}
+
+// matchesContentType returns whether the content type ctype is one of types,
+// where "text/*" matches all the text types.
+func matchesContentType(ctype string, types []string) bool {
+	ctype = strings.ToLower(strings.TrimSpace(strings.SplitN(ctype, ";", 2)[0]))
+	if ctype == "" {
+		return false
+	}
+	for _, t := range types {
+		t = strings.ToLower(t)
+		if t == ctype {
+			return true
+		}
+		if strings.HasSuffix(t, "/*") && strings.HasPrefix(ctype, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		// sniffed here as it can't be once compressed
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.zw != nil {
+		return w.zw.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *compressWriter) Flush() {
+	if w.zw != nil {
+		w.zw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return nil
+}
+
+// close finishes the compressed response.
+func (w *compressWriter) close() {
+	if w.zw == nil {
+		return
+	}
+	if err := w.zw.Close(); err != nil {
+		log.Debugf("compressing the gateway response: %s", err)
+	}
+	w.zw = nil
+	w.c.release()
+}
@@ -0,0 +1,161 @@
+package corehttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+func TestGatewayCompression(t *testing.T) {
+	text := strings.Repeat("hello gateway ", 200)
+
+	serve := func(ctype, encoding, body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Etag", `"etag"`)
+			if ctype != "" {
+				w.Header().Set("Content-Type", ctype)
+			}
+			if encoding != "" {
+				w.Header().Set("Content-Encoding", encoding)
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write([]byte(body))
+		}
+	}
+
+	enabled := config.GatewayCompression{Enabled: true}
+	for _, test := range []struct {
+		name     string
+		cfg      config.GatewayCompression
+		header   map[string]string
+		handler  http.HandlerFunc
+		compress bool
+	}{
+		{"text", enabled, nil, serve("text/html; charset=utf-8", "", text), true},
+		{"disabled", config.GatewayCompression{}, nil, serve("text/html", "", text), false},
+		{"small", enabled, nil, serve("text/html", "", "hello"), false},
+		{"too large", config.GatewayCompression{Enabled: true, MaxSize: 100}, nil, serve("text/html", "", text), false},
+		{"image", enabled, nil, serve("image/png", "", text), false},
+		{"custom types", config.GatewayCompression{Enabled: true, Types: []string{"image/*"}}, nil, serve("image/png", "", text), true},
+		{"precompressed", enabled, nil, serve("text/html", "br", text), false},
+		{"range", enabled, map[string]string{"Range": "bytes=0-10"}, serve("text/html", "", text), false},
+		{"not accepted", enabled, map[string]string{"Accept-Encoding": "br"}, serve("text/html", "", text), false},
+		{"sniffed", enabled, nil, serve("", "", "<html>"+text), true},
+	} {
+		r := httptest.NewRequest("GET", "/ipfs/foo", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		for k, v := range test.header {
+			r.Header.Set(k, v)
+		}
+
+		rec := httptest.NewRecorder()
+		w, done := new(compressor).wrap(rec, r, test.cfg)
+		test.handler(w, r)
+		done()
+
+		res := rec.Result()
+		compressed := res.Header.Get("Content-Encoding") == "gzip"
+		if compressed != test.compress {
+			t.Errorf("%s: compressed: got %t, expected %t", test.name, compressed, test.compress)
+			continue
+		}
+		if test.cfg.Enabled && res.Header.Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: missing Vary header", test.name)
+		}
+		if !compressed {
+			continue
+		}
+
+		if res.Header.Get("Content-Length") != "" {
+			t.Errorf("%s: the Content-Length of the uncompressed content was sent", test.name)
+		}
+		if etag := res.Header.Get("Etag"); etag != `W/"etag"` {
+			t.Errorf("%s: got ETag %s, expected a weak one", test.name, etag)
+		}
+		zr, err := gzip.NewReader(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := ioutil.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasSuffix(out, []byte(text)) {
+			t.Errorf("%s: the uncompressed content differs", test.name)
+		}
+	}
+}
+
+func TestGatewayCompressionMaxConcurrent(t *testing.T) {
+	c := new(compressor)
+	cfg := config.GatewayCompression{Enabled: true, MaxConcurrent: 1}
+	text := strings.Repeat("a", 2048)
+
+	start := func() (*httptest.ResponseRecorder, func()) {
+		r := httptest.NewRequest("GET", "/ipfs/foo", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		w, done := c.wrap(rec, r, cfg)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(text))
+		return rec, done
+	}
+
+	first, done := start()
+	second, done2 := start()
+	done2()
+	done()
+	if first.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("expected the first response to be compressed")
+	}
+	if second.Header().Get("Content-Encoding") != "" {
+		t.Fatal("expected the second response not to be compressed while the first is")
+	}
+
+	third, done3 := start()
+	done3()
+	if third.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("expected the responses to be compressed again once the first is done")
+	}
+}
+
+func TestGatewayCompressionEncodings(t *testing.T) {
+	text := strings.Repeat("hello gateway ", 200)
+
+	for _, test := range []struct {
+		accept    string
+		encodings []string
+		expected  string
+	}{
+		{"gzip, zstd", nil, "gzip"},
+		{"zstd", nil, "zstd"},
+		{"gzip, zstd", []string{"zstd", "gzip"}, "zstd"},
+		{"gzip;q=0, zstd", nil, "zstd"},
+		{"br", nil, ""},
+		{"gzip", []string{"zstd"}, ""},
+	} {
+		r := httptest.NewRequest("GET", "/ipfs/foo", nil)
+		r.Header.Set("Accept-Encoding", test.accept)
+		rec := httptest.NewRecorder()
+		w, done := new(compressor).wrap(rec, r, config.GatewayCompression{Enabled: true, Encodings: test.encodings})
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(text))
+		done()
+
+		if enc := rec.Header().Get("Content-Encoding"); enc != test.expected {
+			t.Errorf("accepting %q with %v: got encoding %q, expected %q", test.accept, test.encodings, enc, test.expected)
+			continue
+		}
+		// the zstd frames start with their magic number
+		if test.expected == "zstd" && !bytes.HasPrefix(rec.Body.Bytes(), []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+			t.Errorf("accepting %q with %v: expected a zstd frame", test.accept, test.encodings)
+		}
+	}
+}
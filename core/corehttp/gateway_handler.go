@@ -2,6 +2,7 @@ package corehttp
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -12,9 +13,12 @@ import (
 	"strings"
 	"time"
 
+	car "github.com/ipfs/go-ipfs/car"
 	core "github.com/ipfs/go-ipfs/core"
 	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	caopts "github.com/ipfs/go-ipfs/core/coreapi/interface/options"
+	denylist "github.com/ipfs/go-ipfs/denylist"
 	"github.com/ipfs/go-ipfs/importer"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	dagutils "github.com/ipfs/go-ipfs/merkledag/utils"
@@ -42,6 +46,9 @@ type gatewayHandler struct {
 	node   *core.IpfsNode
 	config GatewayConfig
 	api    coreiface.CoreAPI
+	// stale keeps the last known values of the IPNS names, nil when they
+	// aren't served.
+	stale *staleValues
 }
 
 func newGatewayHandler(n *core.IpfsNode, c GatewayConfig, api coreiface.CoreAPI) *gatewayHandler {
@@ -102,7 +109,11 @@ func (i *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method == "GET" || r.Method == "HEAD" {
-		i.getOrHeadHandler(ctx, w, r)
+		ctx, watch, stop := watchBudget(ctx, parseRetrievalBudget(i.config.RetrievalBudget))
+		defer stop()
+		bw := &budgetWriter{ResponseWriter: w, watch: watch, path: r.URL.Path}
+		defer bw.finish()
+		i.getOrHeadHandler(ctx, bw, r)
 		return
 	}
 
@@ -122,6 +133,11 @@ func (i *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, errmsg)
 }
 
+// resolvePath resolves p, following its symlinks if enabled.
+func (i *gatewayHandler) resolvePath(ctx context.Context, p coreiface.Path) (coreiface.Path, error) {
+	return i.api.ResolvePath(ctx, p, caopts.Resolve.FollowSymlinks(i.config.FollowSymlinks))
+}
+
 func (i *gatewayHandler) optionsHandler(w http.ResponseWriter, r *http.Request) {
 	/*
 		OPTIONS is a noop request that is used by the browsers to check
@@ -169,7 +185,15 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 	}
 
 	// Resolve path to the final DAG node for the ETag
-	resolvedPath, err := i.api.ResolvePath(ctx, parsedPath)
+	parsedPath, stale, err := i.resolveStale(ctx, r, urlPath, parsedPath)
+	var root *cid.Cid
+	if err == nil {
+		parsedPath, root, err = i.resolveRoot(ctx, parsedPath)
+	}
+	var resolvedPath coreiface.Path
+	if err == nil {
+		resolvedPath, err = i.resolvePath(ctx, parsedPath)
+	}
 	switch err {
 	case nil:
 	case coreiface.ErrOffline:
@@ -183,6 +207,19 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		return
 	}
 
+	if r.URL.Query().Get("format") == "raw" {
+		i.serveRawBlock(ctx, w, r, resolvedPath, urlPath, root)
+		return
+	}
+	if r.URL.Query().Get("format") == "car" {
+		i.serveCar(ctx, w, r, resolvedPath, urlPath, root)
+		return
+	}
+
+	if wantsPreview(r) && i.servePreview(ctx, w, r, resolvedPath, urlPath, originalUrlPath) {
+		return
+	}
+
 	dr, err := i.api.Unixfs().Cat(ctx, resolvedPath)
 	dir := false
 	switch err {
@@ -192,12 +229,27 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 	case coreiface.ErrIsDir:
 		dir = true
 	default:
+		if i.serveDagNode(ctx, w, r, resolvedPath, urlPath, root) {
+			return
+		}
 		webError(w, "ipfs cat "+escapedURLPath, err, http.StatusNotFound)
 		return
 	}
 
+	// serve the pre-compressed variant of the file the client accepts, if
+	// there's one
+	precompressed := !dir && i.config.servesPrecompressed(r.Host)
+	servedPath := resolvedPath
+	var encoding string
+	if precompressed && !strings.HasSuffix(urlPath, "/") {
+		if vr, vpath, enc := i.precompressedVariant(ctx, r, parsedPath); vr != nil {
+			defer vr.Close()
+			dr, servedPath, encoding = vr, vpath, enc
+		}
+	}
+
 	// Check etag send back to us
-	etag := "\"" + resolvedPath.Cid().String() + "\""
+	etag := "\"" + servedPath.Cid().String() + "\""
 	if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-None-Match") == "W/"+etag {
 		w.WriteHeader(http.StatusNotModified)
 		return
@@ -206,6 +258,13 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 	i.addUserHeaders(w) // ok, _now_ write user's headers.
 	w.Header().Set("X-IPFS-Path", urlPath)
 	w.Header().Set("Etag", etag)
+	i.signResponse(w, urlPath, root, servedPath.Cid())
+	if stale != nil {
+		stale.setHeaders(w)
+	}
+	if precompressed {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
 
 	// set 'allowed' headers
 	// & expose those headers
@@ -214,6 +273,9 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		"X-Chunked-Output",
 		"X-Stream-Output",
 	}
+	if i.config.SignResponses {
+		allowedHeadersArr = append(allowedHeadersArr, SignatureHeader)
+	}
 
 	var allowedHeaders = strings.Join(allowedHeadersArr, ", ")
 
@@ -268,6 +330,17 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 
 	if !dir {
 		name := gopath.Base(urlPath)
+
+		// files added with 'ipfs add --mime-type' carry their Content-Type
+		if ctype := i.metadataContentType(ctx, resolvedPath); ctype != "" {
+			w.Header().Set("Content-Type", ctype)
+		} else if encoding != "" {
+			w.Header().Set("Content-Type", precompressedContentType(name))
+		}
+		if encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+
 		i.serveFile(w, r, name, modtime, dr)
 		return
 	}
@@ -387,6 +460,116 @@ func (s *sizeSeeker) Seek(offset int64, whence int) (int64, error) {
 	return s.sizeReadSeeker.Seek(offset, whence)
 }
 
+// serveDagNode renders the nodes of IPLD formats other than unixfs, like
+// dag-cbor or the formats added by plugins, as JSON, the way 'ipfs dag get'
+// prints them. It returns false, having written nothing, for unixfs nodes.
+func (i *gatewayHandler) serveDagNode(ctx context.Context, w http.ResponseWriter, r *http.Request, resolvedPath coreiface.Path, urlPath string, root *cid.Cid) bool {
+	nd, err := i.api.ResolveNode(ctx, resolvedPath)
+	if err != nil {
+		return false
+	}
+	switch nd.(type) {
+	case *dag.ProtoNode, *dag.RawNode:
+		return false
+	}
+
+	data, err := json.Marshal(nd)
+	if err != nil {
+		webError(w, "ipfs dag get "+r.URL.EscapedPath(), err, http.StatusInternalServerError)
+		return true
+	}
+
+	etag := "\"" + resolvedPath.Cid().String() + "\""
+	if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-None-Match") == "W/"+etag {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	i.addUserHeaders(w) // ok, _now_ write user's headers.
+	w.Header().Set("X-IPFS-Path", urlPath)
+	w.Header().Set("Etag", etag)
+	i.signResponse(w, urlPath, root, resolvedPath.Cid())
+	if strings.HasPrefix(urlPath, ipfsPathPrefix) {
+		w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(data)
+	return true
+}
+
+// serveRawBlock writes the block of the node as is, the way 'ipfs block get'
+// does, for the clients which verify the data they fetch, like the nodes
+// fetching blocks from HTTP providers.
+func (i *gatewayHandler) serveRawBlock(ctx context.Context, w http.ResponseWriter, r *http.Request, resolvedPath coreiface.Path, urlPath string, root *cid.Cid) {
+	etag := "\"" + resolvedPath.Cid().String() + ".raw\""
+	if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-None-Match") == "W/"+etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, err := i.api.Block().Get(ctx, resolvedPath)
+	if err != nil {
+		webError(w, "ipfs block get "+r.URL.EscapedPath(), err, http.StatusNotFound)
+		return
+	}
+
+	i.addUserHeaders(w) // ok, _now_ write user's headers.
+	w.Header().Set("X-IPFS-Path", urlPath)
+	w.Header().Set("Etag", etag)
+	i.signResponse(w, urlPath, root, resolvedPath.Cid())
+	if strings.HasPrefix(urlPath, ipfsPathPrefix) {
+		w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+	}
+	w.Header().Set("Content-Type", "application/vnd.ipld.raw")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.Copy(w, data)
+}
+
+// serveCar serves the DAG of resolvedPath as a CAR file, the blocks of each
+// node before the ones it links to.
+func (i *gatewayHandler) serveCar(ctx context.Context, w http.ResponseWriter, r *http.Request, resolvedPath coreiface.Path, urlPath string, root *cid.Cid) {
+	etag := "\"" + resolvedPath.Cid().String() + ".car\""
+	if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-None-Match") == "W/"+etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	i.addUserHeaders(w) // ok, _now_ write user's headers.
+	w.Header().Set("X-IPFS-Path", urlPath)
+	w.Header().Set("Etag", etag)
+	i.signResponse(w, urlPath, root, resolvedPath.Cid())
+	if strings.HasPrefix(urlPath, ipfsPathPrefix) {
+		w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+	}
+	w.Header().Set("Content-Type", "application/vnd.ipld.car")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// the status is sent with the first block: the client notices a
+	// truncated DAG by the blocks missing from it
+	if err := car.WriteDAG(ctx, i.node.DAG, resolvedPath.Cid(), w); err != nil {
+		log.Debugf("failed to write the car of %s: %s", urlPath, err)
+	}
+}
+
+// metadataContentType returns the MIME type stored in the UnixFS metadata node
+// at resolvedPath, if it's one.
+func (i *gatewayHandler) metadataContentType(ctx context.Context, resolvedPath coreiface.Path) string {
+	nd, err := i.api.ResolveNode(ctx, resolvedPath)
+	if err != nil {
+		return ""
+	}
+	pbnd, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return ""
+	}
+	md, err := ft.MetadataFromBytes(pbnd.Data())
+	if err != nil {
+		return ""
+	}
+	return md.MimeType
+}
+
 func (i *gatewayHandler) serveFile(w http.ResponseWriter, req *http.Request, name string, modtime time.Time, content io.ReadSeeker) {
 	if sp, ok := content.(sizeReadSeeker); ok {
 		content = &sizeSeeker{
@@ -602,6 +785,8 @@ func (i *gatewayHandler) addUserHeaders(w http.ResponseWriter) {
 func webError(w http.ResponseWriter, message string, err error, defaultCode int) {
 	if _, ok := err.(resolver.ErrNoLink); ok {
 		webErrorWithCode(w, message, err, http.StatusNotFound)
+	} else if err == denylist.ErrBlocked {
+		webErrorWithCode(w, message, err, http.StatusGone)
 	} else if err == routing.ErrNotFound {
 		webErrorWithCode(w, message, err, http.StatusNotFound)
 	} else if err == context.DeadlineExceeded {
@@ -24,6 +24,12 @@ type directoryItem struct {
 
 var listingTemplate *template.Template
 
+// custom template-escaping function to escape a full path, including '#' and '?'
+func urlEscape(rawUrl string) string {
+	pathUrl := url.URL{Path: rawUrl}
+	return pathUrl.String()
+}
+
 func init() {
 	knownIconsBytes, err := assets.Asset("dir-index-html/knownIcons.txt")
 	if err != nil {
@@ -45,12 +51,6 @@ func init() {
 		return "ipfs-" + ext[1:] // slice of the first dot
 	}
 
-	// custom template-escaping function to escape a full path, including '#' and '?'
-	urlEscape := func(rawUrl string) string {
-		pathUrl := url.URL{Path: rawUrl}
-		return pathUrl.String()
-	}
-
 	// Directory listing template
 	dirIndexBytes, err := assets.Asset("dir-index-html/dir-index.html")
 	if err != nil {
@@ -0,0 +1,101 @@
+package corehttp
+
+import (
+	"context"
+	"mime"
+	"net"
+	"net/http"
+	gopath "path"
+	"strconv"
+	"strings"
+
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+)
+
+// precompressedEncodings are the content encodings of the pre-compressed
+// variants of files, by order of preference, with the extensions of their
+// files.
+var precompressedEncodings = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// servesPrecompressed returns whether the gateway serves the pre-compressed
+// variants of files for requests to host.
+func (c GatewayConfig) servesPrecompressed(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if on, ok := c.Precompressed[strings.ToLower(host)]; ok {
+		return on
+	}
+	return c.Precompressed["*"]
+}
+
+// acceptsEncoding returns whether the Accept-Encoding header of r accepts
+// the content encoding enc.
+func acceptsEncoding(r *http.Request, enc string) bool {
+	wildcard := false
+	for _, h := range r.Header["Accept-Encoding"] {
+		for _, v := range strings.Split(h, ",") {
+			parts := strings.Split(v, ";")
+			name := strings.ToLower(strings.TrimSpace(parts[0]))
+
+			accepted := true
+			for _, param := range parts[1:] {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					q, err := strconv.ParseFloat(param[2:], 64)
+					accepted = err == nil && q > 0
+				}
+			}
+
+			switch name {
+			case enc:
+				return accepted
+			case "*":
+				wildcard = accepted
+			}
+		}
+	}
+	return wildcard
+}
+
+// precompressedVariant returns the pre-compressed variant of the file at p,
+// foo.js.br or foo.js.gz for foo.js, which r accepts, with its path and its
+// content encoding. It returns a nil reader when there's none.
+func (i *gatewayHandler) precompressedVariant(ctx context.Context, r *http.Request, p coreiface.Path) (coreiface.Reader, coreiface.Path, string) {
+	for _, v := range precompressedEncodings {
+		if !acceptsEncoding(r, v.encoding) {
+			continue
+		}
+
+		vp, err := coreapi.ParsePath(p.String() + v.ext)
+		if err != nil {
+			continue
+		}
+		resolved, err := i.resolvePath(ctx, vp)
+		if err != nil {
+			continue
+		}
+		vr, err := i.api.Unixfs().Cat(ctx, resolved)
+		if err != nil {
+			continue
+		}
+		return vr, resolved, v.encoding
+	}
+	return nil, nil, ""
+}
+
+// precompressedContentType returns the Content-Type of a pre-compressed
+// variant of the file name, which can't be detected from its content.
+func precompressedContentType(name string) string {
+	if t := mime.TypeByExtension(gopath.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,117 @@
+package corehttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"testing"
+
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+)
+
+func TestAcceptsEncoding(t *testing.T) {
+	for _, test := range []struct {
+		header string
+		enc    string
+		exp    bool
+	}{
+		{"", "gzip", false},
+		{"gzip, deflate", "gzip", true},
+		{"deflate, br", "gzip", false},
+		{"gzip;q=0", "gzip", false},
+		{"br;q=1.0, gzip;q=0.5", "gzip", true},
+		{"*", "br", true},
+		{"*, br;q=0", "br", false},
+	} {
+		r, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.header != "" {
+			r.Header.Set("Accept-Encoding", test.header)
+		}
+		if got := acceptsEncoding(r, test.enc); got != test.exp {
+			t.Errorf("acceptsEncoding(%q, %q): got %t, expected %t", test.header, test.enc, got, test.exp)
+		}
+	}
+}
+
+func TestGatewayPrecompressed(t *testing.T) {
+	ts, n := newTestServerAndNode(t, nil)
+	defer ts.Close()
+
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Gateway.Precompressed = map[string]bool{"*": true, "plain.example.com": false}
+
+	js := "console.log('hello')"
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(js))
+	zw.Close()
+
+	_, dir, err := coreunix.AddWrapped(n, strings.NewReader(js), "foo.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, gzDir, err := coreunix.AddWrapped(n, bytes.NewReader(gz.Bytes()), "foo.js.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzFile, err := gzDir.Links()[0].GetNode(n.Context(), n.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.(*dag.ProtoNode).AddNodeLink("foo.js.gz", gzFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.DAG.Add(n.Context(), dir); err != nil {
+		t.Fatal(err)
+	}
+	url := ts.URL + "/ipfs/" + dir.Cid().String() + "/foo.js"
+
+	for _, test := range []struct {
+		host     string
+		accept   string
+		encoding string
+		body     string
+	}{
+		{"localhost", "gzip, deflate", "gzip", gz.String()},
+		{"localhost", "identity", "", js},
+		{"localhost", "br", "", js},
+		{"plain.example.com", "gzip", "", js},
+	} {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = test.host
+		req.Header.Set("Accept-Encoding", test.accept)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if enc := res.Header.Get("Content-Encoding"); enc != test.encoding {
+			t.Errorf("%s, Accept-Encoding %q: got Content-Encoding %q, expected %q", test.host, test.accept, enc, test.encoding)
+		}
+		if string(body) != test.body {
+			t.Errorf("%s, Accept-Encoding %q: unexpected body %q", test.host, test.accept, body)
+		}
+		if ctype := res.Header.Get("Content-Type"); ctype != mime.TypeByExtension(".js") {
+			t.Errorf("%s, Accept-Encoding %q: got Content-Type %q", test.host, test.accept, ctype)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package corehttp
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	gopath "path"
+	"strings"
+	"unicode/utf8"
+
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+)
+
+// previewMediaType is the media type of the previews, which clients can ask
+// for in their Accept header rather than with ?preview=1.
+const previewMediaType = "application/vnd.ipfs.preview+html"
+
+// maxPreviewSize is the size of the largest file previewed; larger files are
+// served as they are.
+const maxPreviewSize = 1 << 20
+
+// previewCSP is the Content-Security-Policy of the previews, which only load
+// the images of the gateway and run no scripts.
+const previewCSP = "default-src 'none'; img-src 'self'; style-src 'unsafe-inline'"
+
+// wantsPreview returns whether r asks for an HTML preview of the file or the
+// directory, rather than its content.
+func wantsPreview(r *http.Request) bool {
+	if r.URL.Query().Get("preview") == "1" {
+		return true
+	}
+	for _, h := range r.Header["Accept"] {
+		for _, v := range strings.Split(h, ",") {
+			if strings.TrimSpace(strings.SplitN(v, ";", 2)[0]) == previewMediaType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type previewTemplateData struct {
+	Path string
+	// Raw is the link to the content of the file or to the listing of the
+	// directory.
+	Raw string
+	// Content is the rendered file.
+	Content template.HTML
+	// Images and Entries are the entries of the directory which are images,
+	// shown as a gallery, and the others.
+	Images  []directoryItem
+	Entries []directoryItem
+}
+
+var previewTemplate = template.Must(template.New("preview").Funcs(template.FuncMap{
+	"urlEscape": urlEscape,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Path}}</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 0 auto; padding: 1em; color: #222; }
+header { border-bottom: 1px solid #ddd; margin-bottom: 1em; padding-bottom: .5em; word-break: break-all; }
+header a { float: right; margin-left: 1em; }
+pre { background: #f6f8fa; padding: 1em; overflow: auto; }
+code { font-family: monospace; }
+blockquote { border-left: 4px solid #ddd; margin-left: 0; padding-left: 1em; color: #555; }
+img { max-width: 100%; }
+.c { color: #6a737d; } .s { color: #032f62; } .n { color: #005cc5; } .k { color: #d73a49; }
+.gallery { display: flex; flex-wrap: wrap; gap: .5em; }
+.gallery figure { margin: 0; width: 12em; text-align: center; word-break: break-all; }
+.gallery img { width: 12em; height: 12em; object-fit: cover; }
+</style>
+</head>
+<body>
+<header><a href="{{urlEscape .Raw}}">raw</a>{{.Path}}</header>
+{{.Content}}
+{{- if .Images}}
+<div class="gallery">
+{{- range .Images}}
+<figure><a href="{{urlEscape .Path}}"><img src="{{urlEscape .Path}}" alt="{{.Name}}"></a><figcaption>{{.Name}}</figcaption></figure>
+{{- end}}
+</div>
+{{- end}}
+{{- if .Entries}}
+<ul>
+{{- range .Entries}}
+<li><a href="{{urlEscape .Path}}?preview=1">{{.Name}}</a> {{.Size}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</body>
+</html>
+`))
+
+// isImage returns whether the file name is an image, from its extension.
+func isImage(name string) bool {
+	return strings.HasPrefix(mime.TypeByExtension(gopath.Ext(name)), "image/")
+}
+
+// servePreview writes an HTML preview of the file or directory at
+// resolvedPath: markdown files are rendered, text files shown with their
+// syntax highlighted, and directories listed with their images in a gallery.
+// It returns false, having written nothing, for the files it can't preview,
+// which are served as they are.
+func (i *gatewayHandler) servePreview(ctx context.Context, w http.ResponseWriter, r *http.Request, resolvedPath coreiface.Path, urlPath, originalUrlPath string) bool {
+	data := previewTemplateData{
+		Path: originalUrlPath,
+		Raw:  originalUrlPath,
+	}
+
+	dr, err := i.api.Unixfs().Cat(ctx, resolvedPath)
+	switch err {
+	case nil:
+		content, ok := previewFile(gopath.Base(urlPath), dr)
+		dr.Close()
+		if !ok {
+			return false
+		}
+		data.Content = content
+	case coreiface.ErrIsDir:
+		if !i.previewDirectory(ctx, resolvedPath, originalUrlPath, &data) {
+			return false
+		}
+	default:
+		return false
+	}
+
+	etag := "\"" + resolvedPath.Cid().String() + ".preview\""
+	if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-None-Match") == "W/"+etag {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	var buf bytes.Buffer
+	if err := previewTemplate.Execute(&buf, data); err != nil {
+		internalWebError(w, err)
+		return true
+	}
+
+	i.addUserHeaders(w) // ok, _now_ write user's headers.
+	w.Header().Set("X-IPFS-Path", urlPath)
+	w.Header().Set("Etag", etag)
+	w.Header().Add("Vary", "Accept")
+	if strings.HasPrefix(urlPath, ipfsPathPrefix) {
+		w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", previewCSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if r.Method != "HEAD" {
+		w.Write(buf.Bytes())
+	}
+	return true
+}
+
+// previewFile returns the HTML preview of the file name read from r, and
+// whether it can be previewed: it must be a text file no larger than
+// maxPreviewSize.
+func previewFile(name string, r io.Reader) (template.HTML, bool) {
+	if isImage(name) {
+		return "", false
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r, maxPreviewSize+1))
+	if err != nil || len(b) > maxPreviewSize || !utf8.Valid(b) {
+		return "", false
+	}
+
+	switch strings.ToLower(gopath.Ext(name)) {
+	case ".md", ".markdown":
+		return template.HTML(renderMarkdown(string(b))), true
+	}
+
+	if lang, ok := languageOf(name); ok {
+		return template.HTML("<pre><code>" + highlight(string(b), lang) + "</code></pre>"), true
+	}
+	if strings.HasPrefix(http.DetectContentType(b), "text/") {
+		return template.HTML("<pre>" + template.HTMLEscapeString(string(b)) + "</pre>"), true
+	}
+	return "", false
+}
+
+// previewDirectory lists the directory at resolvedPath in data, the images
+// apart.
+func (i *gatewayHandler) previewDirectory(ctx context.Context, resolvedPath coreiface.Path, originalUrlPath string, data *previewTemplateData) bool {
+	nd, err := i.api.ResolveNode(ctx, resolvedPath)
+	if err != nil {
+		return false
+	}
+	dir, err := uio.NewDirectoryFromNode(i.node.DAG, nd)
+	if err != nil {
+		return false
+	}
+
+	err = dir.ForEachLink(ctx, func(link *ipld.Link) error {
+		di := directoryItem{humanize.Bytes(link.Size), link.Name, gopath.Join(originalUrlPath, link.Name)}
+		if isImage(link.Name) {
+			data.Images = append(data.Images, di)
+		} else {
+			data.Entries = append(data.Entries, di)
+		}
+		return nil
+	})
+	return err == nil
+}
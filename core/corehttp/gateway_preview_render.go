@@ -0,0 +1,339 @@
+package corehttp
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// language describes the syntax of a programming language, enough to
+// highlight its comments, strings, numbers and keywords.
+type language struct {
+	// lineComments are the prefixes of the comments ending with the line.
+	lineComments []string
+	// blockComments is whether /* */ comments are used.
+	blockComments bool
+}
+
+var (
+	cLike    = language{lineComments: []string{"//"}, blockComments: true}
+	hashLike = language{lineComments: []string{"#"}}
+	dashLike = language{lineComments: []string{"--"}}
+)
+
+// languages are the languages highlighted, by file extension and by name, as
+// used to tag the fenced code blocks of markdown.
+var languages = map[string]language{
+	"go": cLike, "c": cLike, "h": cLike, "cc": cLike, "cpp": cLike,
+	"hpp": cLike, "java": cLike, "kt": cLike, "scala": cLike, "cs": cLike,
+	"js": cLike, "javascript": cLike, "mjs": cLike, "ts": cLike,
+	"typescript": cLike, "jsx": cLike, "tsx": cLike, "json": cLike,
+	"rs": cLike, "rust": cLike, "swift": cLike, "proto": cLike,
+	"css": cLike, "scss": cLike, "sol": cLike,
+
+	"py": hashLike, "python": hashLike, "rb": hashLike, "ruby": hashLike,
+	"sh": hashLike, "bash": hashLike, "shell": hashLike, "zsh": hashLike,
+	"pl": hashLike, "perl": hashLike, "r": hashLike, "yaml": hashLike,
+	"yml": hashLike, "toml": hashLike, "mk": hashLike, "makefile": hashLike,
+	"dockerfile": hashLike, "nix": hashLike, "ex": hashLike, "exs": hashLike,
+
+	"sql": dashLike, "lua": dashLike, "hs": dashLike, "haskell": dashLike,
+	"elm": dashLike,
+}
+
+// keywords are the keywords highlighted, those of the common languages.
+var keywords = make(map[string]bool)
+
+func init() {
+	for _, k := range strings.Fields(`
+		break case catch class const continue def default defer do elif else
+		enum except export extends false finally fn for from func function go
+		if impl import in interface let match module mut new nil none null
+		package pub raise return select self static struct super switch this
+		throw trait true try type use var where while with yield
+		True False None and or not lambda async await
+		SELECT FROM WHERE INSERT UPDATE DELETE CREATE TABLE JOIN AND OR NOT`) {
+		keywords[k] = true
+	}
+}
+
+// languageOf returns the language of the file name, or of the language name
+// of a fenced code block, and whether it's known.
+func languageOf(name string) (language, bool) {
+	name = strings.ToLower(name)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	lang, ok := languages[name]
+	return lang, ok
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// highlight returns the HTML of the source code src of the language lang, with
+// its comments, strings, numbers and keywords in spans of the classes c, s, n
+// and k.
+func highlight(src string, lang language) string {
+	var b strings.Builder
+	span := func(class, text string) {
+		b.WriteString(`<span class="` + class + `">`)
+		b.WriteString(html.EscapeString(text))
+		b.WriteString("</span>")
+	}
+
+	plain := 0 // start of the text not highlighted yet
+	for i := 0; i < len(src); {
+		end, class := i, ""
+		c := src[i]
+		switch {
+		case lang.blockComments && strings.HasPrefix(src[i:], "/*"):
+			end = strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i + 4
+			}
+			class = "c"
+		case hasAnyPrefix(src[i:], lang.lineComments):
+			end = strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i
+			}
+			class = "c"
+		case c == '"' || c == '\'' || c == '`':
+			end = i + 1
+			for end < len(src) && src[end] != c && (src[end] != '\n' || c == '`') {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end < len(src) {
+				end++
+			} else {
+				end = len(src)
+			}
+			class = "s"
+		case c >= '0' && c <= '9' && (i == 0 || !isIdentChar(src[i-1])):
+			end = i + 1
+			for end < len(src) && (isIdentChar(src[end]) || src[end] == '.') {
+				end++
+			}
+			class = "n"
+		case isIdentStart(c) && (i == 0 || !isIdentChar(src[i-1])):
+			end = i + 1
+			for end < len(src) && isIdentChar(src[end]) {
+				end++
+			}
+			if keywords[src[i:end]] {
+				class = "k"
+			}
+		default:
+			end = i + 1
+		}
+
+		if class != "" {
+			b.WriteString(html.EscapeString(src[plain:i]))
+			span(class, src[i:end])
+			plain = end
+		}
+		i = end
+	}
+	b.WriteString(html.EscapeString(src[plain:]))
+	return b.String()
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	headingRe     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	ruleRe        = regexp.MustCompile(`^([-*_])(\s*[-*_]){2,}$`)
+	bulletRe      = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	orderedRe     = regexp.MustCompile(`^\d+[.)]\s+(.*)$`)
+	linkRe        = regexp.MustCompile(`^(!?)\[([^\]]*)\]\(\s*((?:[^()\s]|\([^()\s]*\))*)(?:\s+"[^"]*")?\s*\)`)
+	strongRe      = regexp.MustCompile(`^(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	emphasisRe    = regexp.MustCompile(`^([*_])(\S(?:.*?\S)?)([*_])`)
+	inlineCodeRe  = regexp.MustCompile("^(`+)(.+?)(`+)")
+	escapedCharRe = regexp.MustCompile("^\\\\([\\\\`*_{}\\[\\]()#+\\-.!>])")
+)
+
+// renderMarkdown returns the HTML of the markdown document src. It handles the
+// common subset of markdown: headings, paragraphs, lists, block quotes, code
+// blocks, rules, links, images and emphasis. The HTML of the document is
+// escaped rather than passed through, and the links are kept only when they
+// are relative or use http, https or mailto.
+func renderMarkdown(src string) string {
+	var b strings.Builder
+	lines := strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n")
+
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>" + renderInline(strings.Join(para, "\n")) + "</p>\n")
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case line == "":
+			flush()
+
+		case strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~"):
+			flush()
+			fence := line[:3]
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			text := strings.Join(code, "\n")
+			if lang, ok := languageOf(strings.TrimSpace(line[3:])); ok {
+				text = highlight(text, lang)
+			} else {
+				text = html.EscapeString(text)
+			}
+			b.WriteString("<pre><code>" + text + "</code></pre>\n")
+
+		case headingRe.MatchString(line):
+			flush()
+			m := headingRe.FindStringSubmatch(line)
+			tag := "h" + strconv.Itoa(len(m[1]))
+			b.WriteString("<" + tag + ">" + renderInline(m[2]) + "</" + tag + ">\n")
+
+		case ruleRe.MatchString(line):
+			flush()
+			b.WriteString("<hr>\n")
+
+		case strings.HasPrefix(line, ">"):
+			flush()
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(q, " "))
+			}
+			i--
+			b.WriteString("<blockquote>\n" + renderMarkdown(strings.Join(quoted, "\n")) + "</blockquote>\n")
+
+		case bulletRe.MatchString(line) || orderedRe.MatchString(line):
+			flush()
+			re, tag := bulletRe, "ul"
+			if !bulletRe.MatchString(line) {
+				re, tag = orderedRe, "ol"
+			}
+			var items []string
+			for ; i < len(lines); i++ {
+				l := strings.TrimSpace(lines[i])
+				if m := re.FindStringSubmatch(l); m != nil {
+					items = append(items, m[1])
+				} else if l != "" && strings.HasPrefix(lines[i], " ") {
+					// continuation of the item
+					items[len(items)-1] += "\n" + l
+				} else {
+					break
+				}
+			}
+			i--
+			b.WriteString("<" + tag + ">\n")
+			for _, it := range items {
+				b.WriteString("<li>" + renderInline(it) + "</li>\n")
+			}
+			b.WriteString("</" + tag + ">\n")
+
+		default:
+			para = append(para, line)
+		}
+	}
+	flush()
+	return b.String()
+}
+
+// renderInline returns the HTML of the markdown text of a block.
+func renderInline(s string) string {
+	var b strings.Builder
+	plain := 0
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		var out string
+		var n int
+
+		switch s[i] {
+		case '\\':
+			if m := escapedCharRe.FindStringSubmatch(rest); m != nil {
+				out, n = html.EscapeString(m[1]), len(m[0])
+			}
+		case '`':
+			if m := inlineCodeRe.FindStringSubmatch(rest); m != nil && m[1] == m[3] {
+				out, n = "<code>"+html.EscapeString(strings.TrimSpace(m[2]))+"</code>", len(m[0])
+			}
+		case '!', '[':
+			if m := linkRe.FindStringSubmatch(rest); m != nil {
+				out, n = renderLink(m[1] == "!", m[2], m[3]), len(m[0])
+			}
+		case '*', '_':
+			// '_' only emphasizes at the start of words, not in snake_case
+			if s[i] == '_' && i > 0 && isIdentChar(s[i-1]) {
+				break
+			}
+			if m := strongRe.FindStringSubmatch(rest); m != nil && m[1] == m[3] {
+				out, n = "<strong>"+renderInline(m[2])+"</strong>", len(m[0])
+			} else if m := emphasisRe.FindStringSubmatch(rest); m != nil && m[1] == m[3] {
+				out, n = "<em>"+renderInline(m[2])+"</em>", len(m[0])
+			}
+		}
+
+		if n == 0 {
+			i++
+			continue
+		}
+		b.WriteString(html.EscapeString(s[plain:i]))
+		b.WriteString(out)
+		i += n
+		plain = i
+	}
+	b.WriteString(html.EscapeString(s[plain:]))
+	return strings.Replace(b.String(), "\n", " ", -1)
+}
+
+// renderLink returns the HTML of a markdown link or image, or its text when
+// its URL isn't safe.
+func renderLink(image bool, text, target string) string {
+	if !safeURL(target) {
+		return html.EscapeString(text)
+	}
+	if image {
+		return `<img src="` + html.EscapeString(target) + `" alt="` + html.EscapeString(text) + `">`
+	}
+	return `<a href="` + html.EscapeString(target) + `">` + renderInline(text) + `</a>`
+}
+
+// safeURL returns whether the URL u of a link is relative or uses http,
+// https or mailto, rather than schemes like javascript.
+func safeURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "", "http", "https", "mailto":
+		return true
+	}
+	return false
+}
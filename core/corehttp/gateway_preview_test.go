@@ -0,0 +1,170 @@
+package corehttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	for _, test := range []struct {
+		md   string
+		html string
+	}{
+		{"# Title", "<h1>Title</h1>\n"},
+		{"### Sub ###", "<h3>Sub</h3>\n"},
+		{"some *em* and **strong**\ntext", "<p>some <em>em</em> and <strong>strong</strong> text</p>\n"},
+		{"snake_case_name", "<p>snake_case_name</p>\n"},
+		{"`a < b`", "<p><code>a &lt; b</code></p>\n"},
+		{"<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"[ipfs](https://ipfs.io)", `<p><a href="https://ipfs.io">ipfs</a></p>` + "\n"},
+		{"[x](javascript:alert(1))", "<p>x</p>\n"},
+		{"![cat](cat.png)", `<p><img src="cat.png" alt="cat"></p>` + "\n"},
+		{"- a\n- b\n  c", "<ul>\n<li>a</li>\n<li>b c</li>\n</ul>\n"},
+		{"1. a\n2. b", "<ol>\n<li>a</li>\n<li>b</li>\n</ol>\n"},
+		{"> quoted", "<blockquote>\n<p>quoted</p>\n</blockquote>\n"},
+		{"---", "<hr>\n"},
+		{"```\n<b>\n```", "<pre><code>&lt;b&gt;</code></pre>\n"},
+		{"```go\nreturn 1\n```", `<pre><code><span class="k">return</span> <span class="n">1</span></code></pre>` + "\n"},
+		{`\*not em\*`, "<p>*not em*</p>\n"},
+	} {
+		if out := renderMarkdown(test.md); out != test.html {
+			t.Errorf("renderMarkdown(%q):\ngot      %q\nexpected %q", test.md, out, test.html)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	lang, ok := languageOf("main.go")
+	if !ok {
+		t.Fatal("expected .go files to be highlighted")
+	}
+	out := highlight("x := \"a<b\" // c\n/* d */ if y2 {}", lang)
+	expected := `x := <span class="s">&#34;a&lt;b&#34;</span> <span class="c">// c</span>` + "\n" +
+		`<span class="c">/* d */</span> <span class="k">if</span> y2 {}`
+	if out != expected {
+		t.Fatalf("got      %q\nexpected %q", out, expected)
+	}
+
+	if _, ok := languageOf("notes.txt"); ok {
+		t.Fatal("expected .txt files not to be highlighted")
+	}
+}
+
+func TestWantsPreview(t *testing.T) {
+	for _, test := range []struct {
+		url    string
+		accept string
+		exp    bool
+	}{
+		{"/ipfs/foo", "", false},
+		{"/ipfs/foo", "text/html,application/xhtml+xml,*/*;q=0.8", false},
+		{"/ipfs/foo?preview=1", "", true},
+		{"/ipfs/foo?preview=0", "", false},
+		{"/ipfs/foo", previewMediaType + ";q=0.9, */*", true},
+	} {
+		r, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		if got := wantsPreview(r); got != test.exp {
+			t.Errorf("wantsPreview(%s, Accept %q): got %t, expected %t", test.url, test.accept, got, test.exp)
+		}
+	}
+}
+
+func TestGatewayPreview(t *testing.T) {
+	ts, n := newTestServerAndNode(t, nil)
+	defer ts.Close()
+
+	md := "# Hello\n\nfrom *ipfs*"
+	_, dir, err := coreunix.AddWrapped(n, strings.NewReader(md), "README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"cat.png", "data.bin"} {
+		content := "\x89PNG\r\n\x1a\n"
+		if name == "data.bin" {
+			content = "\x00\x01\x02"
+		}
+		_, wrapped, err := coreunix.AddWrapped(n, strings.NewReader(content), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := wrapped.Links()[0].GetNode(n.Context(), n.DAG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dir.(*dag.ProtoNode).AddNodeLink(name, file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := n.DAG.Add(n.Context(), dir); err != nil {
+		t.Fatal(err)
+	}
+	base := ts.URL + "/ipfs/" + dir.Cid().String()
+
+	get := func(url, accept string) (*http.Response, string) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, string(body)
+	}
+
+	// raw bytes stay the default
+	_, body := get(base+"/README.md", "")
+	if body != md {
+		t.Fatalf("expected the raw markdown, got %q", body)
+	}
+
+	for _, accept := range []string{"", previewMediaType} {
+		url := base + "/README.md"
+		if accept == "" {
+			url += "?preview=1"
+		}
+		res, body := get(url, accept)
+		if ctype := res.Header.Get("Content-Type"); ctype != "text/html; charset=utf-8" {
+			t.Fatalf("got Content-Type %q", ctype)
+		}
+		if !strings.Contains(body, "<h1>Hello</h1>") || !strings.Contains(body, "<em>ipfs</em>") {
+			t.Fatalf("markdown not rendered: %s", body)
+		}
+		if res.Header.Get("Content-Security-Policy") != previewCSP {
+			t.Fatal("missing Content-Security-Policy")
+		}
+	}
+
+	// binary files are served as they are
+	_, body = get(base+"/data.bin?preview=1", "")
+	if body != "\x00\x01\x02" {
+		t.Fatalf("expected the raw binary file, got %q", body)
+	}
+
+	_, body = get(base+"/?preview=1", "")
+	if !strings.Contains(body, `class="gallery"`) || !strings.Contains(body, `<img src="/ipfs/`+dir.Cid().String()+`/cat.png"`) {
+		t.Fatalf("expected a gallery with cat.png: %s", body)
+	}
+	if !strings.Contains(body, `/README.md?preview=1"`) {
+		t.Fatalf("expected a preview link to README.md: %s", body)
+	}
+}
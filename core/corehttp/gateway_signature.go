@@ -0,0 +1,190 @@
+package corehttp
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	ic "gx/ipfs/Qme1knMqwt1hKZbc1BmQFmnm9f36nyQGwXxPGVpVJ9rMK5/go-libp2p-crypto"
+)
+
+// SignatureHeader is the header of the responses signed by the gateway, when
+// Gateway.SignResponses is set. It holds the parameters of a GatewayClaim
+// and the signature of the claim by the key of the node:
+//
+//	peer=<peer ID>; key=<base64 public key>; root=<CID>; resolved=<CID>;
+//	time=<RFC 3339 time>; sig=<base64 signature>
+//
+// It's verified with VerifySignature and the X-IPFS-Path of the response.
+const SignatureHeader = "X-Ipfs-Signature"
+
+// GatewayClaim is what a gateway claims to have served: Path, whose root,
+// the CID of its /ipfs/ or /ipns/ name, was Root, resolved to Resolved.
+type GatewayClaim struct {
+	Peer     peer.ID
+	Path     string
+	Root     *cid.Cid
+	Resolved *cid.Cid
+	Time     time.Time
+}
+
+// payload returns the bytes signed for the claim.
+func (c *GatewayClaim) payload() []byte {
+	return []byte(strings.Join([]string{
+		"ipfs-gateway-claim/1",
+		c.Path,
+		c.Root.String(),
+		c.Resolved.String(),
+		c.Time.UTC().Format(time.RFC3339),
+	}, "\n"))
+}
+
+// signClaim returns the value of the SignatureHeader of c, signed with sk.
+func signClaim(sk ic.PrivKey, c *GatewayClaim) (string, error) {
+	key, err := sk.GetPublic().Bytes()
+	if err != nil {
+		return "", err
+	}
+	sig, err := sk.Sign(c.payload())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("peer=%s; key=%s; root=%s; resolved=%s; time=%s; sig=%s",
+		c.Peer.Pretty(),
+		base64.StdEncoding.EncodeToString(key),
+		c.Root,
+		c.Resolved,
+		c.Time.UTC().Format(time.RFC3339),
+		base64.StdEncoding.EncodeToString(sig),
+	), nil
+}
+
+// VerifySignature checks the SignatureHeader header of a response of a
+// gateway to a request of path, its X-IPFS-Path, and returns the claim of
+// the gateway it proves.
+func VerifySignature(header, path string) (*GatewayClaim, error) {
+	params := make(map[string]string)
+	for _, p := range strings.Split(header, ";") {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid parameter %q", p)
+		}
+		params[kv[0]] = kv[1]
+	}
+	for _, k := range []string{"peer", "key", "root", "resolved", "time", "sig"} {
+		if params[k] == "" {
+			return nil, fmt.Errorf("missing the %s parameter", k)
+		}
+	}
+
+	c := &GatewayClaim{Path: path}
+	var err error
+	if c.Peer, err = peer.IDB58Decode(params["peer"]); err != nil {
+		return nil, err
+	}
+	if c.Root, err = cid.Decode(params["root"]); err != nil {
+		return nil, err
+	}
+	if c.Resolved, err = cid.Decode(params["resolved"]); err != nil {
+		return nil, err
+	}
+	if c.Time, err = time.Parse(time.RFC3339, params["time"]); err != nil {
+		return nil, err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(params["key"])
+	if err != nil {
+		return nil, err
+	}
+	pk, err := ic.UnmarshalPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !c.Peer.MatchesPublicKey(pk) {
+		return nil, errors.New("the key doesn't match the peer ID")
+	}
+	sig, err := base64.StdEncoding.DecodeString(params["sig"])
+	if err != nil {
+		return nil, err
+	}
+	ok, err := pk.Verify(c.payload(), sig)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("invalid signature")
+	}
+	return c, nil
+}
+
+// signResponse sets the SignatureHeader of the response serving resolved for
+// urlPath, when the gateway signs its responses. root is the CID the name of
+// urlPath resolved to when serving it.
+func (i *gatewayHandler) signResponse(w http.ResponseWriter, urlPath string, root, resolved *cid.Cid) {
+	if !i.config.SignResponses {
+		return
+	}
+	if i.node.PrivateKey == nil {
+		log.Warning("can't sign the gateway response: the node has no private key")
+		return
+	}
+
+	sig, err := signClaim(i.node.PrivateKey, &GatewayClaim{
+		Peer:     i.node.Identity,
+		Path:     urlPath,
+		Root:     root,
+		Resolved: resolved,
+		Time:     time.Now(),
+	})
+	if err != nil {
+		log.Errorf("failed to sign the gateway response for %s: %s", urlPath, err)
+		return
+	}
+	w.Header().Set(SignatureHeader, sig)
+}
+
+// resolveRoot resolves the /ipns/ name of p, if any, and returns the /ipfs/
+// path p then is, with the CID it starts with. The response is served from
+// that path, so that it is the root the response is signed with.
+func (i *gatewayHandler) resolveRoot(ctx context.Context, p coreiface.Path) (coreiface.Path, *cid.Cid, error) {
+	segments := strings.SplitN(p.String(), "/", 4)
+	if len(segments) < 3 {
+		return nil, nil, fmt.Errorf("invalid path %q", p)
+	}
+
+	if segments[1] == "ipns" {
+		if i.node.Namesys == nil {
+			return nil, nil, coreiface.ErrOffline
+		}
+		value, err := i.node.Namesys.Resolve(ctx, ipnsPathPrefix+segments[2])
+		if err != nil {
+			return nil, nil, err
+		}
+		resolved := value.String()
+		if len(segments) > 3 {
+			resolved = strings.TrimRight(resolved, "/") + "/" + segments[3]
+		}
+		if p, err = coreapi.ParsePath(resolved); err != nil {
+			return nil, nil, err
+		}
+		segments = strings.SplitN(p.String(), "/", 4)
+		if len(segments) < 3 {
+			return nil, nil, fmt.Errorf("invalid path %q", p)
+		}
+	}
+
+	root, err := cid.Decode(segments[2])
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, root, nil
+}
@@ -0,0 +1,183 @@
+package corehttp
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	nsopts "github.com/ipfs/go-ipfs/namesys/opts"
+	path "github.com/ipfs/go-ipfs/path"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	ic "gx/ipfs/Qme1knMqwt1hKZbc1BmQFmnm9f36nyQGwXxPGVpVJ9rMK5/go-libp2p-crypto"
+)
+
+func TestGatewaySignResponses(t *testing.T) {
+	ns := mockNamesys{}
+	ts, n := newTestServerAndNode(t, ns)
+	defer ts.Close()
+
+	sk, _, err := ic.GenerateEd25519Key(rand.New(rand.NewSource(42)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.PrivateKey = sk
+	n.Identity, err = peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, dir, err := coreunix.AddWrapped(n, strings.NewReader("fnord"), "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := dir.Cid()
+	file := dir.Links()[0].Cid
+	ns["/ipns/example.com"] = path.FromCid(root)
+
+	get := func(p string) *http.Response {
+		res, err := http.Get(ts.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: got status %d", p, res.StatusCode)
+		}
+		return res
+	}
+
+	// not signed by default
+	if h := get("/ipfs/" + root.String() + "/file").Header.Get(SignatureHeader); h != "" {
+		t.Fatalf("unexpected signature %q", h)
+	}
+
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Gateway.SignResponses = true
+
+	for _, p := range []string{
+		"/ipfs/" + root.String() + "/file",
+		"/ipns/example.com/file",
+	} {
+		res := get(p)
+		claim, err := VerifySignature(res.Header.Get(SignatureHeader), res.Header.Get("X-IPFS-Path"))
+		if err != nil {
+			t.Fatalf("%s: %s", p, err)
+		}
+		if claim.Peer != n.Identity || claim.Path != p || !claim.Root.Equals(root) || !claim.Resolved.Equals(file) {
+			t.Fatalf("%s: unexpected claim %+v", p, claim)
+		}
+
+		// the signature doesn't hold for another path
+		if _, err := VerifySignature(res.Header.Get(SignatureHeader), p+"/other"); err == nil {
+			t.Fatalf("%s: the signature was verified for another path", p)
+		}
+	}
+}
+
+// slowNamesys resolves the names of mockNamesys after a delay.
+type slowNamesys struct {
+	mockNamesys
+
+	mu    sync.Mutex
+	delay time.Duration
+}
+
+func (s *slowNamesys) set(name string, value path.Path, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mockNamesys[name] = value
+	s.delay = delay
+}
+
+func (s *slowNamesys) Resolve(ctx context.Context, name string, opts ...nsopts.ResolveOpt) (path.Path, error) {
+	s.mu.Lock()
+	delay := s.delay
+	s.mu.Unlock()
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mockNamesys.Resolve(ctx, name, opts...)
+}
+
+func TestGatewaySignStaleResponses(t *testing.T) {
+	ts, n := newTestServerAndNode(t, mockNamesys{})
+	defer ts.Close()
+	ns := &slowNamesys{mockNamesys: mockNamesys{}}
+	n.Namesys = ns
+
+	sk, _, err := ic.GenerateEd25519Key(rand.New(rand.NewSource(42)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.PrivateKey = sk
+	n.Identity, err = peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Gateway.SignResponses = true
+	cfg.Gateway.StaleWhileRevalidate = map[string]config.GatewayStaleWhileRevalidate{
+		"*": {Enabled: true, Wait: "20ms", MaxStale: "1h"},
+	}
+
+	_, dir1, err := coreunix.AddWrapped(n, strings.NewReader("fnord"), "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, dir2, err := coreunix.AddWrapped(n, strings.NewReader("other fnord"), "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const p = "/ipns/example.com/file"
+	check := func(root, file *cid.Cid, stale bool) {
+		res, err := http.Get(ts.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("got status %d", res.StatusCode)
+		}
+		if (res.Header.Get("Warning") != "") != stale {
+			t.Fatalf("expected the response to be stale: %t", stale)
+		}
+
+		claim, err := VerifySignature(res.Header.Get(SignatureHeader), res.Header.Get("X-IPFS-Path"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !claim.Root.Equals(root) || !claim.Resolved.Equals(file) {
+			t.Fatalf("expected the claim of %s, got %+v", root, claim)
+		}
+	}
+
+	ns.set("/ipns/example.com", path.FromCid(dir1.Cid()), 0)
+	check(dir1.Cid(), dir1.Links()[0].Cid, false)
+
+	// the name now resolves slowly to another value: the last known value
+	// is served, and is the root signed
+	ns.set("/ipns/example.com", path.FromCid(dir2.Cid()), 200*time.Millisecond)
+	check(dir1.Cid(), dir1.Links()[0].Cid, true)
+}
@@ -0,0 +1,208 @@
+package corehttp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	path "github.com/ipfs/go-ipfs/path"
+
+	lru "gx/ipfs/QmVYxfoJQiZijTgPNHCHgHELvQpbsJNTg6Crmc3dQkj3yy/golang-lru"
+)
+
+// The defaults of the serving of the last known values of the IPNS names.
+const (
+	defaultStaleWait = 500 * time.Millisecond
+	defaultMaxStale  = 24 * time.Hour
+)
+
+const (
+	// staleRefreshTimeout bounds the resolutions done in the background.
+	staleRefreshTimeout = time.Minute
+	// maxStaleNames is the number of names whose last known value is kept.
+	maxStaleNames = 1024
+)
+
+// staleConfig configures the serving of the last known values of the IPNS
+// names for the requests to a host.
+type staleConfig struct {
+	// Wait is how long the resolution is waited for before serving the last
+	// known value.
+	Wait time.Duration
+	// MaxStale is the age beyond which the last known value isn't served.
+	MaxStale time.Duration
+}
+
+// staleWhileRevalidate returns how the last known values of the IPNS names
+// are served for the requests to host, and whether they are.
+func (c GatewayConfig) staleWhileRevalidate(host string) (staleConfig, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	cfg, ok := c.StaleWhileRevalidate[strings.ToLower(host)]
+	if !ok {
+		cfg = c.StaleWhileRevalidate["*"]
+	}
+	if !cfg.Enabled {
+		return staleConfig{}, false
+	}
+	return staleConfig{
+		Wait:     parseGatewayDuration("Gateway.StaleWhileRevalidate.Wait", cfg.Wait, defaultStaleWait),
+		MaxStale: parseGatewayDuration("Gateway.StaleWhileRevalidate.MaxStale", cfg.MaxStale, defaultMaxStale),
+	}, true
+}
+
+// staleValues keeps the last known values of the IPNS names resolved by the
+// gateway, and resolves them again in the background.
+type staleValues struct {
+	ctx     context.Context
+	resolve func(ctx context.Context, name string) (path.Path, error)
+
+	mu        sync.Mutex
+	values    *lru.Cache // of staleValue, by name
+	refreshes map[string]*staleRefresh
+}
+
+type staleValue struct {
+	path     path.Path
+	resolved time.Time
+}
+
+// staleRefresh is a resolution running in the background, path and err set
+// once done is closed.
+type staleRefresh struct {
+	done chan struct{}
+	path path.Path
+	err  error
+}
+
+// newStaleValues returns the staleValues of the names resolved with resolve,
+// the resolutions in the background stopping when ctx is done.
+func newStaleValues(ctx context.Context, resolve func(context.Context, string) (path.Path, error)) *staleValues {
+	values, _ := lru.New(maxStaleNames)
+	return &staleValues{
+		ctx:       ctx,
+		resolve:   resolve,
+		values:    values,
+		refreshes: make(map[string]*staleRefresh),
+	}
+}
+
+// lookup resolves name, an /ipns/ path. When the resolution takes longer than
+// cfg.Wait or fails, the last known value of name is returned instead, with
+// its age, while it keeps being resolved in the background. The age is zero
+// for the values just resolved.
+func (s *staleValues) lookup(ctx context.Context, name string, cfg staleConfig) (path.Path, time.Duration, error) {
+	last, ok := s.last(name)
+	age := time.Since(last.resolved)
+	if !ok || age > cfg.MaxStale {
+		p, err := s.resolve(ctx, name)
+		if err == nil {
+			s.values.Add(name, staleValue{path: p, resolved: time.Now()})
+		}
+		return p, 0, err
+	}
+
+	ref := s.refresh(name)
+	timer := time.NewTimer(cfg.Wait)
+	defer timer.Stop()
+	select {
+	case <-ref.done:
+		if ref.err == nil {
+			return ref.path, 0, nil
+		}
+		log.Debugf("serving the last known value of %s: %s", name, ref.err)
+	case <-timer.C:
+	case <-ctx.Done():
+		return "", 0, ctx.Err()
+	}
+	return last.path, age, nil
+}
+
+func (s *staleValues) last(name string) (staleValue, bool) {
+	v, ok := s.values.Get(name)
+	if !ok {
+		return staleValue{}, false
+	}
+	return v.(staleValue), true
+}
+
+// refresh resolves name in the background, unless it already is.
+func (s *staleValues) refresh(name string) *staleRefresh {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ref, ok := s.refreshes[name]; ok {
+		return ref
+	}
+
+	ref := &staleRefresh{done: make(chan struct{})}
+	s.refreshes[name] = ref
+	go func() {
+		ctx, cancel := context.WithTimeout(s.ctx, staleRefreshTimeout)
+		defer cancel()
+		ref.path, ref.err = s.resolve(ctx, name)
+		if ref.err == nil {
+			s.values.Add(name, staleValue{path: ref.path, resolved: time.Now()})
+		}
+
+		s.mu.Lock()
+		delete(s.refreshes, name)
+		s.mu.Unlock()
+		close(ref.done)
+	}()
+	return ref
+}
+
+// staleResponse tells a response served from the last known value of its
+// IPNS name.
+type staleResponse struct {
+	age      time.Duration
+	maxStale time.Duration
+}
+
+// setHeaders marks the response as stale, to be revalidated by the caches.
+func (s *staleResponse) setHeaders(w http.ResponseWriter) {
+	h := w.Header()
+	h.Set("Cache-Control", fmt.Sprintf("public, max-age=0, stale-while-revalidate=%d", int64(s.maxStale/time.Second)))
+	h.Set("Age", fmt.Sprint(int64(s.age/time.Second)))
+	h.Set("Warning", `110 - "Response is Stale"`)
+}
+
+// resolveStale resolves the IPNS name of urlPath with its last known value
+// when the resolution is slow, if enabled for the host of r. It returns p
+// with the name resolved, and the staleResponse when the value served is
+// stale. p is returned unchanged when it's not enabled.
+func (i *gatewayHandler) resolveStale(ctx context.Context, r *http.Request, urlPath string, p coreiface.Path) (coreiface.Path, *staleResponse, error) {
+	if i.stale == nil || !strings.HasPrefix(urlPath, ipnsPathPrefix) {
+		return p, nil, nil
+	}
+	cfg, ok := i.config.staleWhileRevalidate(r.Host)
+	if !ok {
+		return p, nil, nil
+	}
+
+	segments := strings.SplitN(urlPath, "/", 4)
+	value, age, err := i.stale.lookup(ctx, ipnsPathPrefix+segments[2], cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resolved := value.String()
+	if len(segments) > 3 {
+		resolved = strings.TrimRight(resolved, "/") + "/" + segments[3]
+	}
+	p, err = coreapi.ParsePath(resolved)
+	if err != nil {
+		return nil, nil, err
+	}
+	if age == 0 {
+		return p, nil, nil
+	}
+	return p, &staleResponse{age: age, maxStale: cfg.MaxStale}, nil
+}
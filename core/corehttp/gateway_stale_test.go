@@ -0,0 +1,125 @@
+package corehttp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	path "github.com/ipfs/go-ipfs/path"
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+// fakeNames resolves names to their value, after the delay set.
+type fakeNames struct {
+	mu    sync.Mutex
+	value path.Path
+	err   error
+	delay time.Duration
+	calls int
+}
+
+func (f *fakeNames) set(value path.Path, err error, delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.value, f.err, f.delay = value, err, delay
+}
+
+func (f *fakeNames) resolve(ctx context.Context, name string) (path.Path, error) {
+	f.mu.Lock()
+	value, err, delay := f.value, f.err, f.delay
+	f.calls++
+	f.mu.Unlock()
+
+	select {
+	case <-time.After(delay):
+		return value, err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestStaleValues(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	names := new(fakeNames)
+	s := newStaleValues(ctx, names.resolve)
+	cfg := staleConfig{Wait: 20 * time.Millisecond, MaxStale: time.Hour}
+	const name = "/ipns/example.com"
+	v1 := path.Path("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+	v2 := path.Path("/ipfs/QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG")
+
+	// without a last known value, the resolution is waited for
+	names.set(v1, nil, 50*time.Millisecond)
+	p, age, err := s.lookup(ctx, name, cfg)
+	if err != nil || p != v1 || age != 0 {
+		t.Fatalf("expected %s resolved, got %s (age %s, err %v)", v1, p, age, err)
+	}
+
+	// a slow resolution serves the last known value
+	names.set(v2, nil, 200*time.Millisecond)
+	p, age, err = s.lookup(ctx, name, cfg)
+	if err != nil || p != v1 || age == 0 {
+		t.Fatalf("expected the stale %s, got %s (age %s, err %v)", v1, p, age, err)
+	}
+
+	// the resolutions running in the background aren't started again
+	p, _, _ = s.lookup(ctx, name, cfg)
+	if p != v1 {
+		t.Fatalf("expected the stale %s, got %s", v1, p)
+	}
+	names.mu.Lock()
+	calls := names.calls
+	names.mu.Unlock()
+	if calls != 2 {
+		t.Fatalf("expected one resolution in the background, got %d", calls-1)
+	}
+
+	// once resolved in the background, the new value is served
+	time.Sleep(300 * time.Millisecond)
+	names.set(v2, errors.New("not found"), 0)
+	p, _, err = s.lookup(ctx, name, cfg)
+	if err != nil || p != v2 {
+		t.Fatalf("expected the stale %s on failure, got %s (err %v)", v2, p, err)
+	}
+
+	// a fast resolution is served
+	names.set(v1, nil, 0)
+	p, age, err = s.lookup(ctx, name, cfg)
+	if err != nil || p != v1 || age != 0 {
+		t.Fatalf("expected %s resolved, got %s (age %s, err %v)", v1, p, age, err)
+	}
+
+	// a value older than MaxStale isn't served
+	time.Sleep(10 * time.Millisecond)
+	names.set("", errors.New("not found"), 0)
+	_, _, err = s.lookup(ctx, name, staleConfig{Wait: time.Hour, MaxStale: time.Millisecond})
+	if err == nil {
+		t.Fatal("expected the resolution to fail")
+	}
+}
+
+func TestStaleWhileRevalidateConfig(t *testing.T) {
+	c := GatewayConfig{StaleWhileRevalidate: map[string]config.GatewayStaleWhileRevalidate{
+		"example.com": {Enabled: true, Wait: "1s"},
+		"*":           {Enabled: true, MaxStale: "1h"},
+		"localhost":   {Enabled: false},
+	}}
+
+	cfg, ok := c.staleWhileRevalidate("Example.com:8080")
+	if !ok || cfg.Wait != time.Second || cfg.MaxStale != defaultMaxStale {
+		t.Fatalf("expected the settings of example.com, got %+v (%t)", cfg, ok)
+	}
+	cfg, ok = c.staleWhileRevalidate("example.net")
+	if !ok || cfg.Wait != defaultStaleWait || cfg.MaxStale != time.Hour {
+		t.Fatalf("expected the settings of *, got %+v (%t)", cfg, ok)
+	}
+	if _, ok := c.staleWhileRevalidate("localhost:8080"); ok {
+		t.Fatal("expected it to be disabled for localhost")
+	}
+	if _, ok := (GatewayConfig{}).staleWhileRevalidate("example.com"); ok {
+		t.Fatal("expected it to be disabled by default")
+	}
+}
@@ -0,0 +1,59 @@
+package corehttp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+
+	core "github.com/ipfs/go-ipfs/core"
+)
+
+// HealthOption serves the liveness (/livez) and readiness (/readyz) probes
+// of the node, meant for orchestration systems. Both answer 200 when the
+// check passes and 503 otherwise, with a plain text summary of the checks.
+func HealthOption() ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		mux.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) {
+			if !n.Live() {
+				writeHealth(w, "livez", []core.HealthCheck{{Name: "node", Info: "shutting down"}})
+				return
+			}
+			writeHealth(w, "livez", []core.HealthCheck{{Name: "node", OK: true}})
+		})
+		mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+			checks := n.Readiness()
+			if !n.Live() {
+				checks = append(checks, core.HealthCheck{Name: "node", Info: "shutting down"})
+			}
+			writeHealth(w, "readyz", checks)
+		})
+		return mux, nil
+	}
+}
+
+func writeHealth(w http.ResponseWriter, name string, checks []core.HealthCheck) {
+	buf := new(bytes.Buffer)
+	for _, c := range checks {
+		mark, state := "+", "ok"
+		if !c.OK {
+			mark, state = "-", "failed"
+		}
+		fmt.Fprintf(buf, "[%s]%s %s", mark, c.Name, state)
+		if c.Info != "" {
+			fmt.Fprintf(buf, ": %s", c.Info)
+		}
+		buf.WriteByte('\n')
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if core.Ready(checks) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(buf, "%s check passed\n", name)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(buf, "%s check failed\n", name)
+	}
+	buf.WriteTo(w)
+}
@@ -30,6 +30,13 @@ var (
 	peersTotalMetric = prometheus.NewDesc(
 		prometheus.BuildFQName("ipfs", "p2p", "peers_total"),
 		"Number of connected peers", []string{"transport"}, nil)
+
+	diskFreeMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "disk_free_bytes"),
+		"Free space of the filesystem of the repo", nil, nil)
+	diskLevelMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "disk_level"),
+		"How low the free space of the repo is: 0 ok, 1 warn, 2 gc, 3 full", nil, nil)
 )
 
 type IpfsNodeCollector struct {
@@ -38,6 +45,8 @@ type IpfsNodeCollector struct {
 
 func (_ IpfsNodeCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- peersTotalMetric
+	ch <- diskFreeMetric
+	ch <- diskLevelMetric
 }
 
 func (c IpfsNodeCollector) Collect(ch chan<- prometheus.Metric) {
@@ -49,6 +58,13 @@ func (c IpfsNodeCollector) Collect(ch chan<- prometheus.Metric) {
 			tr,
 		)
 	}
+
+	if w := c.Node.DiskWatchdog; w != nil {
+		if free, ok := w.Free(); ok {
+			ch <- prometheus.MustNewConstMetric(diskFreeMetric, prometheus.GaugeValue, float64(free))
+			ch <- prometheus.MustNewConstMetric(diskLevelMetric, prometheus.GaugeValue, float64(w.Level()))
+		}
+	}
 }
 
 func (c IpfsNodeCollector) PeersTotalValues() map[string]float64 {
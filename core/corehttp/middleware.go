@@ -0,0 +1,45 @@
+package corehttp
+
+import (
+	"net"
+	"net/http"
+
+	core "github.com/ipfs/go-ipfs/core"
+)
+
+// Middleware wraps the handler of an HTTP server of the daemon, "api" or
+// "gateway", e.g. to authenticate or log the requests.
+type Middleware func(server string, next http.Handler) (http.Handler, error)
+
+var middlewares []Middleware
+
+// AddMiddleware registers a middleware for the servers built with
+// MiddlewareOption. The middlewares added first see the requests first.
+// Middlewares must be added before the servers start, usually by plugins.
+func AddMiddleware(m Middleware) {
+	middlewares = append(middlewares, m)
+}
+
+// MiddlewareOption passes the requests through the registered middlewares
+// before the handlers of the following options. It should be the first
+// option of the server.
+func MiddlewareOption(server string) ServeOption {
+	return func(n *core.IpfsNode, l net.Listener, parent *http.ServeMux) (*http.ServeMux, error) {
+		if len(middlewares) == 0 {
+			return parent, nil
+		}
+
+		mux := http.NewServeMux()
+		var handler http.Handler = mux
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			var err error
+			handler, err = middlewares[i](server, handler)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		parent.Handle("/", handler)
+		return mux, nil
+	}
+}
@@ -0,0 +1,57 @@
+package corehttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareOption(t *testing.T) {
+	defer func() { middlewares = nil }()
+
+	var order []string
+	for _, name := range []string{"first", "second"} {
+		name := name
+		AddMiddleware(func(server string, next http.Handler) (http.Handler, error) {
+			if server != "api" {
+				t.Errorf("expected the api server, got %q", server)
+			}
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				if r.Header.Get("Authorization") == "" {
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r)
+			}), nil
+		})
+	}
+
+	root := http.NewServeMux()
+	mux, err := MiddlewareOption("api")(nil, nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	root.ServeHTTP(w, httptest.NewRequest("GET", "/api/v0/id", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the request to be rejected, got %d", w.Code)
+	}
+
+	order = nil
+	r := httptest.NewRequest("GET", "/api/v0/id", nil)
+	r.Header.Set("Authorization", "yes")
+	w = httptest.NewRecorder()
+	root.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("expected the request to be served, got %d %q", w.Code, w.Body.String())
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("middlewares ran in the wrong order: %v", order)
+	}
+}
@@ -0,0 +1,395 @@
+package corerepo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	archive "github.com/ipfs/go-ipfs/repo/archive"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+	cidset "github.com/ipfs/go-ipfs/thirdparty/cidset"
+
+	dshelp "gx/ipfs/QmNP2u7bofwUQptHQGPfabGWtTCbxhNLSZKqbf1uzsup9V/go-ipfs-ds-help"
+	offline "gx/ipfs/QmPf114DXfa6TqGKYhBGR7EtXRho4rCJgwyA1xkuMY5vwF/go-ipfs-exchange-offline"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	bstore "gx/ipfs/QmbaPGg81pvQiC5vTXtC9Jo8rdrWUjRaugH71WYNsgi6Ev/go-ipfs-blockstore"
+	crypto "gx/ipfs/Qme1knMqwt1hKZbc1BmQFmnm9f36nyQGwXxPGVpVJ9rMK5/go-libp2p-crypto"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+)
+
+// The files of a backup, besides the datastore entries.
+const (
+	backupManifestFile = "backup.json"
+	backupConfigFile   = "config"
+	backupKeystoreDir  = "keystore/"
+)
+
+// backupVersion is the version of the backup format.
+const backupVersion = 1
+
+// blocksKey is the key under which the blocks are stored in the datastore.
+var blocksKey = ds.NewKey("/blocks")
+
+// BackupManifest describes a backup.
+type BackupManifest struct {
+	Version     int
+	RepoVersion int
+	Created     time.Time
+	// Keys is whether the backup holds the private keys of the node.
+	Keys bool
+	// PinnedOnly is whether the backup only holds the pinned blocks.
+	PinnedOnly bool
+}
+
+// BackupOptions are the options of Backup.
+type BackupOptions struct {
+	// Keys includes the private keys: the identity of the node and the keys
+	// of its keystore.
+	Keys bool
+	// PinnedOnly only includes the blocks of the pinned DAGs and of the files
+	// root, rather than all the blocks.
+	PinnedOnly bool
+}
+
+// Backup writes a consistent snapshot of the repo of the node to w: its
+// config, its datastore and optionally its keys. It can run while the node
+// runs: the blocks can still be added, but garbage collections and block
+// removals wait for the backup to complete, for the blocks of the DAGs pinned
+// to stay until they're saved.
+func Backup(ctx context.Context, n *core.IpfsNode, w io.Writer, opts BackupOptions) error {
+	aw := archive.NewWriter(w)
+
+	manifest, err := json.Marshal(&BackupManifest{
+		Version:     backupVersion,
+		RepoVersion: fsrepo.RepoVersion,
+		Created:     time.Now().UTC(),
+		Keys:        opts.Keys,
+		PinnedOnly:  opts.PinnedOnly,
+	})
+	if err != nil {
+		return err
+	}
+	if err := aw.WriteFile(backupManifestFile, manifest); err != nil {
+		return err
+	}
+
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+	conf := *cfg
+	if !opts.Keys {
+		conf.Identity.PrivKey = ""
+	}
+	b, err := json.MarshalIndent(&conf, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := aw.WriteFile(backupConfigFile, b); err != nil {
+		return err
+	}
+
+	if opts.Keys {
+		if err := backupKeys(n, aw); err != nil {
+			return err
+		}
+	}
+
+	defer n.Blockstore.PinLock().Unlock()
+
+	roots, err := backupState(n, aw)
+	if err != nil {
+		return err
+	}
+	if opts.PinnedOnly {
+		err = backupPinnedBlocks(ctx, n, aw, roots)
+	} else {
+		_, err = aw.WriteDatastore(n.Repo.Datastore(), blocksKey)
+	}
+	if err != nil {
+		return err
+	}
+
+	return aw.Close()
+}
+
+// backupRoots are the roots of the DAGs whose blocks are saved by a backup
+// of the pinned blocks.
+type backupRoots struct {
+	// recursive are the roots of the recursive and internal pins
+	recursive []*cid.Cid
+	direct    []*cid.Cid
+	// bestEffort are the roots of the DAGs saved as far as they are in the
+	// repo: the files root
+	bestEffort []*cid.Cid
+}
+
+// backupState saves the entries of the datastore besides the blocks, the pins
+// among them, and returns the roots of the DAGs pinned at the same time. The
+// pin lock must be held, until the blocks are saved too.
+func backupState(n *core.IpfsNode, aw *archive.Writer) (*backupRoots, error) {
+	if _, err := aw.WriteDatastore(n.Repo.Datastore(), ds.NewKey("/"), blocksKey); err != nil {
+		return nil, err
+	}
+
+	roots := &backupRoots{direct: n.Pinning.DirectKeys()}
+	roots.recursive = append(roots.recursive, n.Pinning.RecursiveKeys()...)
+	roots.recursive = append(roots.recursive, n.Pinning.InternalPins()...)
+
+	bestEffort, err := BestEffortRoots(n.FilesRoot)
+	if err != nil {
+		return nil, err
+	}
+	roots.bestEffort = bestEffort
+	return roots, nil
+}
+
+func backupKeys(n *core.IpfsNode, aw *archive.Writer) error {
+	ks := n.Repo.Keystore()
+	names, err := ks.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		k, err := ks.Get(name)
+		if err != nil {
+			return err
+		}
+		b, err := crypto.MarshalPrivateKey(k)
+		if err != nil {
+			return err
+		}
+		if err := aw.WriteFile(backupKeystoreDir+name, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dagWriter writes the blocks of DAGs in the blockstore of a node to an
+// archive, each once.
+type dagWriter struct {
+	ctx      context.Context
+	n        *core.IpfsNode
+	aw       *archive.Writer
+	set      cidset.Set
+	getLinks dag.GetLinks
+	err      error
+}
+
+func newDAGWriter(ctx context.Context, n *core.IpfsNode, aw *archive.Writer) (*dagWriter, error) {
+	set, err := cidset.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the blocks in the repo are written
+	offlineDAG := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+	return &dagWriter{
+		ctx:      ctx,
+		n:        n,
+		aw:       aw,
+		set:      set,
+		getLinks: dag.GetLinksWithDAG(offlineDAG),
+	}, nil
+}
+
+// visit writes the block of c if it wasn't yet, and returns whether it was
+// written.
+func (w *dagWriter) visit(c *cid.Cid) bool {
+	if w.err != nil || !w.set.Visit(c) {
+		return false
+	}
+	b, err := w.n.Blockstore.Get(c)
+	if err != nil {
+		w.err = err
+		return false
+	}
+	w.err = w.aw.WriteEntry(blocksKey.Child(dshelp.CidToDsKey(c)), b.RawData())
+	return w.err == nil
+}
+
+// writeBlock writes the block of c.
+func (w *dagWriter) writeBlock(c *cid.Cid) error {
+	w.visit(c)
+	return w.err
+}
+
+// writeDAG writes the blocks of the DAG of c. With bestEffort, the blocks
+// missing from the repo are skipped.
+func (w *dagWriter) writeDAG(c *cid.Cid, bestEffort bool) error {
+	if bestEffort {
+		has, err := w.n.Blockstore.Has(c)
+		if err != nil || !has {
+			return err
+		}
+	}
+	if w.visit(c) {
+		err := dag.EnumerateChildren(w.ctx, w.getLinks, c, w.visit)
+		if err != nil && !(bestEffort && err == ipld.ErrNotFound) {
+			return err
+		}
+	}
+	return w.err
+}
+
+func (w *dagWriter) Close() error {
+	defer w.set.Close()
+	return w.set.Err()
+}
+
+// backupPinnedBlocks saves the blocks of the pinned DAGs of roots, and those
+// of the files root which are in the repo.
+func backupPinnedBlocks(ctx context.Context, n *core.IpfsNode, aw *archive.Writer, roots *backupRoots) error {
+	w, err := newDAGWriter(ctx, n, aw)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range roots.recursive {
+		if err := w.writeDAG(c, false); err != nil {
+			w.Close()
+			return pinnedBlockError(c, err)
+		}
+	}
+	for _, c := range roots.direct {
+		if err := w.writeBlock(c); err != nil {
+			w.Close()
+			return pinnedBlockError(c, err)
+		}
+	}
+	for _, c := range roots.bestEffort {
+		if err := w.writeDAG(c, true); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// pinnedBlockError explains err, the error of saving the blocks of the pin of
+// c, when a block is missing.
+func pinnedBlockError(c *cid.Cid, err error) error {
+	if err == ipld.ErrNotFound || err == bstore.ErrNotFound {
+		return fmt.Errorf("a block of the pin %s is missing from the repo: %s", c, err)
+	}
+	return err
+}
+
+// RestoreStat is the result of Restore.
+type RestoreStat struct {
+	// Entries is the number of datastore entries restored.
+	Entries int
+	// Keys is the number of keystore keys restored.
+	Keys int
+	// Identity is whether the identity of the node was restored.
+	Identity bool
+}
+
+// Restore restores the backup read from r into the repo of the node, which
+// must not be running: its config, its datastore entries and the keys it
+// holds. The node keeps its identity when the backup doesn't hold it. The
+// entries and keys of the repo with the same names are replaced.
+func Restore(n *core.IpfsNode, r io.Reader) (*RestoreStat, error) {
+	ar := archive.NewReader(r)
+
+	it, err := ar.Next()
+	if err != nil {
+		return nil, fmt.Errorf("reading the backup: %s", err)
+	}
+	if it.Name != backupManifestFile {
+		return nil, fmt.Errorf("not a backup: missing %s", backupManifestFile)
+	}
+	var manifest BackupManifest
+	if err := json.Unmarshal(it.Data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest: %s", err)
+	}
+	if manifest.Version != backupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", manifest.Version)
+	}
+	if manifest.RepoVersion != fsrepo.RepoVersion {
+		return nil, fmt.Errorf("the backup is of a repo of version %d, expected version %d: migrate it first", manifest.RepoVersion, fsrepo.RepoVersion)
+	}
+
+	stat := new(RestoreStat)
+	d := n.Repo.Datastore()
+	for {
+		it, err := ar.Next()
+		if err == io.EOF {
+			return stat, nil
+		}
+		if err != nil {
+			return stat, fmt.Errorf("reading the backup: %s", err)
+		}
+
+		switch {
+		case it.Key != nil:
+			if err := d.Put(*it.Key, it.Data); err != nil {
+				return stat, err
+			}
+			stat.Entries++
+		case it.Name == backupConfigFile:
+			restored, err := restoreConfig(n, it.Data)
+			if err != nil {
+				return stat, err
+			}
+			stat.Identity = restored
+		case strings.HasPrefix(it.Name, backupKeystoreDir):
+			if err := restoreKey(n, strings.TrimPrefix(it.Name, backupKeystoreDir), it.Data); err != nil {
+				return stat, err
+			}
+			stat.Keys++
+		default:
+			log.Warningf("ignoring unknown file %s of the backup", it.Name)
+		}
+	}
+}
+
+// restoreConfig sets the config of the backup, but for the datastore spec of
+// the repo, and returns whether it holds the identity of the node.
+func restoreConfig(n *core.IpfsNode, data []byte) (bool, error) {
+	var cfg config.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return false, fmt.Errorf("invalid config in the backup: %s", err)
+	}
+
+	cur, err := n.Repo.Config()
+	if err != nil {
+		return false, err
+	}
+	// the entries are restored into the datastore of the repo as it is
+	cfg.Datastore = cur.Datastore
+
+	restored := cfg.Identity.PrivKey != ""
+	if !restored {
+		cfg.Identity = cur.Identity
+	}
+	return restored, n.Repo.SetConfig(&cfg)
+}
+
+func restoreKey(n *core.IpfsNode, name string, data []byte) error {
+	k, err := crypto.UnmarshalPrivateKey(data)
+	if err != nil {
+		return fmt.Errorf("invalid key %s in the backup: %s", name, err)
+	}
+
+	ks := n.Repo.Keystore()
+	has, err := ks.Has(name)
+	if err != nil {
+		return err
+	}
+	if has {
+		if err := ks.Delete(name); err != nil {
+			return err
+		}
+	}
+	return ks.Put(name, k)
+}
@@ -0,0 +1,186 @@
+package corerepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ipfs/go-ipfs/core"
+	archive "github.com/ipfs/go-ipfs/repo/archive"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+)
+
+// exportManifestFile is the file describing an export, first in its archive.
+const exportManifestFile = "export.json"
+
+// exportVersion is the version of the export format.
+const exportVersion = 1
+
+// pinsKey is the key of the root of the pin sets in the datastore.
+var pinsKey = ds.NewKey("/local/pins")
+
+// ExportManifest describes an export.
+type ExportManifest struct {
+	Version     int
+	RepoVersion int
+	Created     time.Time
+	// Prefixes are the prefixes of the keys of the exported entries.
+	Prefixes []string
+}
+
+// ExportStat is the result of Export and Import.
+type ExportStat struct {
+	// Entries is the number of datastore entries exported or imported.
+	Entries int
+}
+
+// Export writes the datastore entries of the node under prefixes to w. When
+// the pins are exported, the blocks of the pin sets are exported with them,
+// so that they can be imported; the blocks of the pinned DAGs aren't.
+func Export(ctx context.Context, n *core.IpfsNode, w io.Writer, prefixes []ds.Key) (*ExportStat, error) {
+	if len(prefixes) == 0 {
+		return nil, errors.New("no prefix to export")
+	}
+	prefixes = outermost(prefixes)
+
+	defer n.Blockstore.PinLock().Unlock()
+
+	aw := archive.NewWriter(w)
+
+	manifest := &ExportManifest{
+		Version:     exportVersion,
+		RepoVersion: fsrepo.RepoVersion,
+		Created:     time.Now().UTC(),
+	}
+	for _, p := range prefixes {
+		manifest.Prefixes = append(manifest.Prefixes, p.String())
+	}
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := aw.WriteFile(exportManifestFile, b); err != nil {
+		return nil, err
+	}
+
+	stat := new(ExportStat)
+	d := n.Repo.Datastore()
+	pins := false
+	for _, p := range prefixes {
+		if p.Equal(pinsKey) || p.IsAncestorOf(pinsKey) {
+			pins = true
+		}
+
+		// the blocks are in their own mount, which is queried apart
+		if p.IsAncestorOf(blocksKey) {
+			count, err := aw.WriteDatastore(d, p, blocksKey)
+			stat.Entries += count
+			if err != nil {
+				return stat, err
+			}
+			p = blocksKey
+		}
+		count, err := aw.WriteDatastore(d, p)
+		stat.Entries += count
+		if err != nil {
+			return stat, err
+		}
+	}
+
+	if pins && !under(blocksKey, prefixes) {
+		dw, err := newDAGWriter(ctx, n, aw)
+		if err != nil {
+			return stat, err
+		}
+		for _, c := range n.Pinning.InternalPins() {
+			if err := dw.writeDAG(c, false); err != nil {
+				dw.Close()
+				return stat, err
+			}
+		}
+		if err := dw.Close(); err != nil {
+			return stat, err
+		}
+	}
+
+	return stat, aw.Close()
+}
+
+// outermost returns the keys which aren't under another one of keys.
+func outermost(keys []ds.Key) []ds.Key {
+	var out []ds.Key
+	for i, k := range keys {
+		covered := false
+		for j, o := range keys {
+			if o.IsAncestorOf(k) || (o.Equal(k) && j < i) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// under returns whether k is one of keys or under one of them.
+func under(k ds.Key, keys []ds.Key) bool {
+	for _, p := range keys {
+		if k.Equal(p) || p.IsAncestorOf(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Import puts the datastore entries of an export read from r into the repo of
+// the node, which must not be running. The entries of the repo with the same
+// keys are replaced.
+func Import(n *core.IpfsNode, r io.Reader) (*ExportStat, error) {
+	ar := archive.NewReader(r)
+
+	it, err := ar.Next()
+	if err != nil {
+		return nil, fmt.Errorf("reading the export: %s", err)
+	}
+	if it.Name != exportManifestFile {
+		return nil, fmt.Errorf("not an export: missing %s", exportManifestFile)
+	}
+	var manifest ExportManifest
+	if err := json.Unmarshal(it.Data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid export manifest: %s", err)
+	}
+	if manifest.Version != exportVersion {
+		return nil, fmt.Errorf("unsupported export version %d", manifest.Version)
+	}
+	if manifest.RepoVersion != fsrepo.RepoVersion {
+		return nil, fmt.Errorf("the export is of a repo of version %d, expected version %d", manifest.RepoVersion, fsrepo.RepoVersion)
+	}
+
+	stat := new(ExportStat)
+	d := n.Repo.Datastore()
+	for {
+		it, err := ar.Next()
+		if err == io.EOF {
+			return stat, nil
+		}
+		if err != nil {
+			return stat, fmt.Errorf("reading the export: %s", err)
+		}
+		if it.Key == nil {
+			log.Warningf("ignoring unknown file %s of the export", it.Name)
+			continue
+		}
+
+		if err := d.Put(*it.Key, it.Data); err != nil {
+			return stat, err
+		}
+		stat.Entries++
+	}
+}
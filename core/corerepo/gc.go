@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/ipfs/go-ipfs/core"
+	events "github.com/ipfs/go-ipfs/events"
 	mfs "github.com/ipfs/go-ipfs/mfs"
 	gc "github.com/ipfs/go-ipfs/pin/gc"
 	repo "github.com/ipfs/go-ipfs/repo"
@@ -79,14 +81,31 @@ func BestEffortRoots(filesRoot *mfs.Root) ([]*cid.Cid, error) {
 	return []*cid.Cid{rootDag.Cid()}, nil
 }
 
+// gcRoots returns the roots of the DAGs kept by the garbage collection
+// besides the pinned ones: the MFS root and the ephemeral DAGs not expired.
+func gcRoots(n *core.IpfsNode) ([]*cid.Cid, error) {
+	roots, err := BestEffortRoots(n.FilesRoot)
+	if err != nil {
+		return nil, err
+	}
+	if n.Ephemeral != nil {
+		eroots, err := n.Ephemeral.Roots()
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, eroots...)
+	}
+	return roots, nil
+}
+
 func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel() // in case error occurs during operation
-	roots, err := BestEffortRoots(n.FilesRoot)
+	roots, err := gcRoots(n)
 	if err != nil {
 		return err
 	}
-	rmed := gc.GC(ctx, n.Blockstore, n.Repo.Datastore(), n.Pinning, roots)
+	rmed := runGC(ctx, n, roots)
 
 	return CollectResult(ctx, rmed, nil)
 }
@@ -146,15 +165,55 @@ func (e *MultiError) Error() string {
 }
 
 func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) <-chan gc.Result {
-	roots, err := BestEffortRoots(n.FilesRoot)
+	roots, err := gcRoots(n)
+	if err != nil {
+		out := make(chan gc.Result, 1)
+		out <- gc.Result{Error: err}
+		close(out)
+		return out
+	}
+
+	return runGC(ctx, n, roots)
+}
+
+// runGC starts a garbage collection run and reports its start and completion
+// on the node's event bus.
+func runGC(ctx context.Context, n *core.IpfsNode, roots []*cid.Cid) <-chan gc.Result {
+	ctx, err := n.TraversalContext(ctx)
 	if err != nil {
-		out := make(chan gc.Result)
+		out := make(chan gc.Result, 1)
 		out <- gc.Result{Error: err}
 		close(out)
 		return out
 	}
 
-	return gc.GC(ctx, n.Blockstore, n.Repo.Datastore(), n.Pinning, roots)
+	n.Events.Emit(events.GCStarted, nil)
+
+	rmed := gc.GC(ctx, n.Blockstore, n.Repo.Datastore(), n.Pinning, roots)
+	out := make(chan gc.Result)
+	go func() {
+		defer close(out)
+
+		var removed, failed int
+		for res := range rmed {
+			if res.Error != nil {
+				failed++
+			} else if res.KeyRemoved != nil {
+				removed++
+			}
+
+			select {
+			case out <- res:
+			case <-ctx.Done():
+			}
+		}
+
+		n.Events.Emit(events.GCFinished, map[string]string{
+			"removed": strconv.Itoa(removed),
+			"errors":  strconv.Itoa(failed),
+		})
+	}()
+	return out
 }
 
 func PeriodicGC(ctx context.Context, node *core.IpfsNode) error {
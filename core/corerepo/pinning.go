@@ -16,20 +16,65 @@ package corerepo
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
+	apiauth "github.com/ipfs/go-ipfs/apiauth"
 	"github.com/ipfs/go-ipfs/core"
+	events "github.com/ipfs/go-ipfs/events"
+	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 	resolver "github.com/ipfs/go-ipfs/path/resolver"
+	pininfo "github.com/ipfs/go-ipfs/repo/pininfo"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
 	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
 )
 
+// FetchStat reports on the blocks an operation fetched from the network.
+type FetchStat struct {
+	Blocks   uint64
+	Bytes    uint64
+	Duration time.Duration
+}
+
 func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]*cid.Cid, error) {
+	ctx, err := n.TraversalContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out, _, err := PinWithStat(n, ctx, paths, recursive)
+	return out, err
+}
+
+// PinWithStat pins the paths like Pin, fetching all their blocks through a
+// single session so that the providers found for one path are asked for
+// the others, and returns what it fetched. Unlike Pin, it walks the DAGs
+// with ctx as given, which callers usually derive from n.TraversalContext.
+func PinWithStat(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]*cid.Cid, *FetchStat, error) {
+	start := time.Now()
+	ses := dag.NewSession(ctx, n.DAG)
+	ctx = dag.WithSession(ctx, ses)
+
+	out, err := pinPaths(n, ctx, ses, paths, recursive)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stat := &FetchStat{Duration: time.Since(start)}
+	if ss, ok := dag.SessionStat(ses); ok {
+		stat.Blocks = ss.Blocks
+		stat.Bytes = ss.Bytes
+	}
+	return out, stat, nil
+}
+
+func pinPaths(n *core.IpfsNode, ctx context.Context, ses ipld.NodeGetter, paths []string, recursive bool) ([]*cid.Cid, error) {
 	out := make([]*cid.Cid, len(paths))
 
 	r := &resolver.Resolver{
-		DAG:         n.DAG,
+		DAG:         ses,
 		ResolveOnce: uio.ResolveUnixfsOnce,
 	}
 
@@ -55,9 +100,46 @@ func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool)
 		return nil, err
 	}
 
+	for _, c := range out {
+		if err := RecordPin(n, ctx, c); err != nil {
+			log.Errorf("recording the pin of %s: %s", c, err)
+		}
+		n.Events.Emit(events.PinAdded, map[string]string{
+			"cid":       c.String(),
+			"recursive": strconv.FormatBool(recursive),
+		})
+	}
+
 	return out, nil
 }
 
+// RecordPin records that c was pinned, through the interface, with the API
+// token and the pin name of the request of ctx, unless it was pinned already.
+func RecordPin(n *core.IpfsNode, ctx context.Context, c *cid.Cid) error {
+	if n.PinInfo == nil {
+		return nil
+	}
+	var token string
+	if tok, ok := apiauth.FromContext(ctx); ok {
+		token = tok.Name
+	}
+	return n.PinInfo.Record(c, pininfo.Info{
+		Origin: pininfo.OriginFromContext(ctx),
+		Token:  token,
+		Name:   pininfo.NameFromContext(ctx),
+	})
+}
+
+// ForgetPin forgets the record of the pin of c, once unpinned.
+func ForgetPin(n *core.IpfsNode, c *cid.Cid) {
+	if n.PinInfo == nil {
+		return
+	}
+	if err := n.PinInfo.Forget(c); err != nil {
+		log.Errorf("forgetting the pin of %s: %s", c, err)
+	}
+}
+
 func Unpin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]*cid.Cid, error) {
 	unpinned := make([]*cid.Cid, len(paths))
 
@@ -81,6 +163,7 @@ func Unpin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool
 		if err != nil {
 			return nil, err
 		}
+		ForgetPin(n, k)
 		unpinned[i] = k
 	}
 
@@ -0,0 +1,83 @@
+package corerepo
+
+import (
+	"context"
+
+	apiauth "github.com/ipfs/go-ipfs/apiauth"
+	"github.com/ipfs/go-ipfs/core"
+	quota "github.com/ipfs/go-ipfs/repo/quota"
+
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// quotaReserveIncrement is the number of bytes reserved at once for the data
+// added, not to compute the usage of the identity for each block read.
+const quotaReserveIncrement = 4 << 20
+
+// requestQuota returns the storage quota of the request of ctx, if it has
+// one.
+func requestQuota(n *core.IpfsNode, ctx context.Context) (*apiauth.Quota, bool) {
+	if n.Quotas == nil {
+		return nil, false
+	}
+	return apiauth.QuotaFromContext(ctx)
+}
+
+// AddReservation reserves the quota of an identity for the data of an add,
+// as it's read, for the adds made at once not to exceed it together.
+type AddReservation struct {
+	n *core.IpfsNode
+	q *apiauth.Quota
+
+	// reserved bytes, read of which were used
+	reserved, read uint64
+}
+
+// ReserveAdd returns the AddReservation of the identity the request of ctx
+// was made by, nil when it has no quota.
+func ReserveAdd(n *core.IpfsNode, ctx context.Context) *AddReservation {
+	q, ok := requestQuota(n, ctx)
+	if !ok {
+		return nil
+	}
+	return &AddReservation{n: n, q: q}
+}
+
+// Reserve reserves size more bytes read, failing with a
+// *quota.ExceededError when they would exceed the quota.
+func (r *AddReservation) Reserve(size uint64) error {
+	r.read += size
+	if r.read <= r.reserved {
+		return nil
+	}
+	need := r.read - r.reserved
+	more := need
+	if more < quotaReserveIncrement {
+		more = quotaReserveIncrement
+	}
+	pinned := quota.Pinned(r.n.Pinning)
+	err := r.n.Quotas.ReserveBytes(r.q.Identity, r.q.Bytes, more, pinned)
+	if _, ok := err.(*quota.ExceededError); ok && more > need {
+		// the increment doesn't fit, but the bytes read may
+		more = need
+		err = r.n.Quotas.ReserveBytes(r.q.Identity, r.q.Bytes, more, pinned)
+	}
+	if err != nil {
+		return err
+	}
+	r.reserved += more
+	return nil
+}
+
+// Record records that the identity pinned c, of size bytes, the DAG of the data
+// added.
+func (r *AddReservation) Record(c *cid.Cid, size uint64) error {
+	return r.n.Quotas.Record(r.q.Identity, c, size)
+}
+
+// Release releases the bytes reserved, once the DAG of the data is recorded
+// or the add failed.
+func (r *AddReservation) Release() {
+	r.n.Quotas.ReleaseBytes(r.q.Identity, r.reserved)
+	r.reserved, r.read = 0, 0
+}
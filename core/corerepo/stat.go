@@ -7,6 +7,7 @@ import (
 	context "context"
 	"github.com/ipfs/go-ipfs/core"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+	usage "github.com/ipfs/go-ipfs/repo/usage"
 
 	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 )
@@ -17,6 +18,9 @@ type Stat struct {
 	RepoPath   string
 	Version    string
 	StorageMax uint64 // size in bytes
+
+	// Usage is the usage of the repo by category, if asked for.
+	Usage *usage.Report `json:",omitempty"`
 }
 
 // NoLimit represents the value for unlimited storage
@@ -30,14 +34,19 @@ func RepoStat(n *core.IpfsNode, ctx context.Context) (*Stat, error) {
 		return nil, err
 	}
 
-	allKeys, err := n.Blockstore.AllKeysChan(ctx)
-	if err != nil {
-		return nil, err
-	}
+	// the blocks are counted as they are added and removed, the blockstore
+	// is only scanned when they weren't counted yet
+	count, ok := n.Usage.Blocks()
+	if !ok {
+		allKeys, err := n.Blockstore.AllKeysChan(ctx)
+		if err != nil {
+			return nil, err
+		}
 
-	count := uint64(0)
-	for range allKeys {
-		count++
+		count = 0
+		for range allKeys {
+			count++
+		}
 	}
 
 	path, err := fsrepo.BestKnownPath()
@@ -66,3 +75,20 @@ func RepoStat(n *core.IpfsNode, ctx context.Context) (*Stat, error) {
 		StorageMax: storageMax,
 	}, nil
 }
+
+// RepoUsage returns the usage of the repo by category. The daemon keeps it up
+// to date in the background; without it, the blocks are counted first if the
+// pins or the MFS root changed since the last count.
+func RepoUsage(n *core.IpfsNode, ctx context.Context) (*usage.Report, error) {
+	if !n.OnlineMode() {
+		if err := n.Usage.Update(ctx, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	size, err := n.Repo.GetStorageUsage()
+	if err != nil {
+		return nil, err
+	}
+	return n.Usage.Report(size)
+}
@@ -7,15 +7,19 @@ import (
 	"io/ioutil"
 	"os"
 	gopath "path"
+	"runtime"
 	"strconv"
+	"time"
 
 	core "github.com/ipfs/go-ipfs/core"
 	balanced "github.com/ipfs/go-ipfs/importer/balanced"
+	erasure "github.com/ipfs/go-ipfs/importer/erasure"
 	ihelper "github.com/ipfs/go-ipfs/importer/helpers"
 	trickle "github.com/ipfs/go-ipfs/importer/trickle"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	mfs "github.com/ipfs/go-ipfs/mfs"
 	"github.com/ipfs/go-ipfs/pin"
+	ephemeral "github.com/ipfs/go-ipfs/repo/ephemeral"
 	unixfs "github.com/ipfs/go-ipfs/unixfs"
 
 	chunker "gx/ipfs/QmR4G4WBNGA5S5pvjFiTkuehstC9769sLAHei8vZernhYR/go-ipfs-chunker"
@@ -46,10 +50,19 @@ type Object struct {
 }
 
 type AddedObject struct {
-	Name  string
-	Hash  string `json:",omitempty"`
-	Bytes int64  `json:",omitempty"`
-	Size  string `json:",omitempty"`
+	Name string
+	Hash string `json:",omitempty"`
+	// Bytes and Persisted are, in the progress outputs, the size of the data
+	// of the file hashed so far, and of the one written to the repo.
+	Bytes     int64  `json:",omitempty"`
+	Persisted int64  `json:",omitempty"`
+	Size      string `json:",omitempty"`
+	// Error is why the file couldn't be added, with ContinueOnError.
+	Error string `json:",omitempty"`
+	// FileSize and ModTime are the size and the modification time of the
+	// file added, when read from disk.
+	FileSize int64      `json:",omitempty"`
+	ModTime  *time.Time `json:",omitempty"`
 }
 
 // NewAdder Returns a new Adder used for a file add operation.
@@ -65,6 +78,7 @@ func NewAdder(ctx context.Context, p pin.Pinner, bs bstore.GCBlockstore, ds ipld
 		Trickle:    false,
 		Wrap:       false,
 		Chunker:    "",
+		Workers:    runtime.NumCPU(),
 	}, nil
 }
 
@@ -84,12 +98,47 @@ type Adder struct {
 	Wrap       bool
 	NoCopy     bool
 	Chunker    string
+	MimeType   bool
 	root       ipld.Node
 	mroot      *mfs.Root
 	unlocker   bstore.Unlocker
 	tempRoot   *cid.Cid
 	Prefix     *cid.Prefix
 	liveNodes  uint64
+
+	// ContinueOnError sends the errors adding files to Out and goes on with
+	// the next files, instead of returning them.
+	ContinueOnError bool
+	failed          int
+
+	// Reserve, when set, is called with the number of bytes of the files
+	// read before they're added, the add failing with its error.
+	Reserve    func(size uint64) error
+	reserveErr error
+
+	// Ephemeral, when set, keeps the files added from garbage collection
+	// for EphemeralTTL instead of pinning them.
+	Ephemeral    *ephemeral.Store
+	EphemeralTTL time.Duration
+
+	// Previous are the files added before, by name, which are reused
+	// rather than chunked again when they didn't change.
+	Previous map[string]PreviousFile
+
+	// Erasure, when set, imports the files as erasure-coded shards rather
+	// than as unixfs files. Cat reads them back.
+	Erasure *erasure.Params
+
+	// Workers is the number of leaves of each file encoded and hashed
+	// concurrently, one per CPU by default.
+	Workers int
+
+	// BatchMaxNodes, BatchMaxSize and SyncBytes bound the nodes of each
+	// file buffered before they're written, see the DagBuilderParams of
+	// importer/helpers.
+	BatchMaxNodes int
+	BatchMaxSize  int
+	SyncBytes     uint64
 }
 
 func (adder *Adder) mfsRoot() (*mfs.Root, error) {
@@ -111,8 +160,16 @@ func (adder *Adder) SetMfsRoot(r *mfs.Root) {
 	adder.mroot = r
 }
 
-// Constructs a node from reader's data, and adds it. Doesn't pin.
-func (adder *Adder) add(reader io.Reader) (ipld.Node, error) {
+// Constructs a node from reader's data, and adds it. Doesn't pin. The
+// progress of the building is reported to progress, when set.
+func (adder *Adder) add(reader io.Reader, progress func(ihelper.Progress)) (ipld.Node, error) {
+	if adder.Erasure != nil {
+		p := *adder.Erasure
+		p.Prefix = adder.Prefix
+		p.Progress = progress
+		return erasure.Build(adder.ctx, reader, adder.dagService, p)
+	}
+
 	chnk, err := chunker.FromString(reader, adder.Chunker)
 	if err != nil {
 		return nil, err
@@ -124,6 +181,13 @@ func (adder *Adder) add(reader io.Reader) (ipld.Node, error) {
 		Maxlinks:  ihelper.DefaultLinksPerBlock,
 		NoCopy:    adder.NoCopy,
 		Prefix:    adder.Prefix,
+		Ctx:       adder.ctx,
+		Progress:  progress,
+		Workers:   adder.Workers,
+
+		BatchMaxNodes: adder.BatchMaxNodes,
+		BatchMaxSize:  adder.BatchMaxSize,
+		SyncBytes:     adder.SyncBytes,
 	}
 
 	if adder.Trickle {
@@ -133,6 +197,31 @@ func (adder *Adder) add(reader io.Reader) (ipld.Node, error) {
 	return balanced.Layout(params.New(chnk))
 }
 
+// AddReader imports the data of r as a single file and pins it when Pin is
+// set. Unlike AddFile, it doesn't go through the MFS root of the adder, the
+// returned node is the file itself.
+func (adder *Adder) AddReader(r io.Reader) (ipld.Node, error) {
+	defer adder.blockstore.PinLock().Unlock()
+
+	nd, err := adder.ImportReader(r)
+	if err != nil {
+		return nil, err
+	}
+	adder.root = nd
+
+	if err := adder.PinRoot(); err != nil {
+		return nil, err
+	}
+	return nd, nil
+}
+
+// ImportReader imports the data of r as a single file, without pinning it.
+// Callers which pin the file afterwards must hold the pin lock of the
+// blockstore until they do, so that the garbage collector doesn't remove it.
+func (adder *Adder) ImportReader(r io.Reader) (ipld.Node, error) {
+	return adder.add(r, nil)
+}
+
 // RootNode returns the root node of the Added.
 func (adder *Adder) RootNode() (ipld.Node, error) {
 	// for memoizing
@@ -170,7 +259,7 @@ func (adder *Adder) PinRoot() error {
 	if err != nil {
 		return err
 	}
-	if !adder.Pin {
+	if !adder.Pin && adder.Ephemeral == nil {
 		return nil
 	}
 
@@ -181,6 +270,10 @@ func (adder *Adder) PinRoot() error {
 		return err
 	}
 
+	if !adder.Pin {
+		return adder.Ephemeral.Keep(rnk, adder.EphemeralTTL)
+	}
+
 	if adder.tempRoot != nil {
 		err := adder.pinning.Unpin(adder.ctx, adder.tempRoot, true)
 		if err != nil {
@@ -277,7 +370,7 @@ func (adder *Adder) outputDirs(path string, fsn mfs.FSNode) error {
 			return err
 		}
 
-		return outputDagnode(adder.Out, path, nd)
+		return outputDagnode(adder.Out, path, nd, nil)
 	default:
 		return fmt.Errorf("unrecognized fsn type: %#v", fsn)
 	}
@@ -299,7 +392,7 @@ func AddWithContext(ctx context.Context, n *core.IpfsNode, r io.Reader) (string,
 		return "", err
 	}
 
-	node, err := fileAdder.add(r)
+	node, err := fileAdder.add(r, nil)
 	if err != nil {
 		return "", err
 	}
@@ -368,7 +461,9 @@ func AddWrapped(n *core.IpfsNode, r io.Reader, filename string) (string, ipld.No
 	return gopath.Join(c.String(), filename), dagnode, nil
 }
 
-func (adder *Adder) addNode(node ipld.Node, path string) error {
+// addNode puts node at path in the root, st being the stat of the file it was
+// added from, if any.
+func (adder *Adder) addNode(node ipld.Node, path string, st os.FileInfo) error {
 	// patch it into the root
 	if path == "" {
 		path = node.Cid().String()
@@ -399,14 +494,14 @@ func (adder *Adder) addNode(node ipld.Node, path string) error {
 	}
 
 	if !adder.Silent {
-		return outputDagnode(adder.Out, path, node)
+		return outputDagnode(adder.Out, path, node, st)
 	}
 	return nil
 }
 
 // AddFile adds the given file while respecting the adder.
 func (adder *Adder) AddFile(file files.File) error {
-	if adder.Pin {
+	if adder.Pin || adder.Ephemeral != nil {
 		adder.unlocker = adder.blockstore.PinLock()
 	}
 	defer func() {
@@ -415,7 +510,33 @@ func (adder *Adder) AddFile(file files.File) error {
 		}
 	}()
 
-	return adder.addFile(file)
+	return adder.addFileOrReport(file)
+}
+
+// Failed returns the number of files which couldn't be added, with
+// ContinueOnError.
+func (adder *Adder) Failed() int {
+	return adder.failed
+}
+
+// addFileOrReport adds file, and with ContinueOnError, sends the error if it
+// can't be added rather than returning it.
+func (adder *Adder) addFileOrReport(file files.File) error {
+	err := adder.addFile(file)
+	if err == nil || !adder.ContinueOnError || err == adder.reserveErr || adder.ctx.Err() != nil {
+		return err
+	}
+
+	log.Infof("%s couldn't be added: %s", file.FileName(), err)
+	adder.failed++
+	if adder.Out != nil {
+		select {
+		case adder.Out <- &AddedObject{Name: file.FileName(), Error: err.Error()}:
+		case <-adder.ctx.Done():
+			return adder.ctx.Err()
+		}
+	}
+	return nil
 }
 
 func (adder *Adder) addFile(file files.File) error {
@@ -456,29 +577,68 @@ func (adder *Adder) addFile(file files.File) error {
 			return err
 		}
 
-		return adder.addNode(dagnode, s.FileName())
+		return adder.addNode(dagnode, s.FileName(), nil)
+	}
+
+	st := fileStat(file)
+	if dagnode, ok := adder.reusable(file.FileName(), st); ok {
+		log.Infof("%s didn't change, reusing %s", file.FileName(), dagnode.Cid())
+		// counted like the bytes of the files read
+		if adder.Reserve != nil {
+			if err := adder.Reserve(uint64(st.Size())); err != nil {
+				adder.reserveErr = err
+				return err
+			}
+		}
+		if adder.Progress {
+			adder.Out <- &AddedObject{Name: file.FileName(), Bytes: st.Size(), Persisted: st.Size()}
+		}
+		return adder.addNode(dagnode, file.FileName(), st)
 	}
 
 	// case for regular file
-	// if the progress flag was specified, wrap the file so that we can send
-	// progress updates to the client (over the output channel)
 	var reader io.Reader = file
-	if adder.Progress {
-		rdr := &progressReader{file: file, out: adder.Out}
+
+	if adder.Reserve != nil {
+		rr := &reserveReader{r: reader, adder: adder}
 		if fi, ok := file.(files.FileInfo); ok {
-			reader = &progressReader2{rdr, fi}
+			reader = &reserveFileInfoReader{rr, fi}
 		} else {
-			reader = rdr
+			reader = rr
 		}
 	}
 
-	dagnode, err := adder.add(reader)
+	var sniff *sniffReader
+	if adder.MimeType {
+		reader, sniff = newSniffReader(reader)
+	}
+
+	// if the progress flag was specified, send the progress of the building
+	// of the file to the client (over the output channel)
+	var progress *progressReporter
+	var reportProgress func(ihelper.Progress)
+	if adder.Progress {
+		progress = &progressReporter{name: file.FileName(), out: adder.Out}
+		reportProgress = progress.report
+	}
+
+	dagnode, err := adder.add(reader, reportProgress)
 	if err != nil {
 		return err
 	}
+	if progress != nil {
+		progress.send()
+	}
+
+	if sniff != nil {
+		dagnode, err = adder.withMetadata(dagnode, MimeType(file.FileName(), sniff.head))
+		if err != nil {
+			return err
+		}
+	}
 
 	// patch it into the root
-	return adder.addNode(dagnode, file.FileName())
+	return adder.addNode(dagnode, file.FileName(), st)
 }
 
 func (adder *Adder) addDir(dir files.File) error {
@@ -511,7 +671,7 @@ func (adder *Adder) addDir(dir files.File) error {
 			log.Infof("%s is hidden, skipping", file.FileName())
 			continue
 		}
-		err = adder.addFile(file)
+		err = adder.addFileOrReport(file)
 		if err != nil {
 			return err
 		}
@@ -533,8 +693,9 @@ func (adder *Adder) maybePauseForGC() error {
 	return nil
 }
 
-// outputDagnode sends dagnode info over the output channel
-func outputDagnode(out chan interface{}, name string, dn ipld.Node) error {
+// outputDagnode sends dagnode info over the output channel, with the size and
+// modification time of st if not nil.
+func outputDagnode(out chan interface{}, name string, dn ipld.Node, st os.FileInfo) error {
 	if out == nil {
 		return nil
 	}
@@ -544,11 +705,17 @@ func outputDagnode(out chan interface{}, name string, dn ipld.Node) error {
 		return err
 	}
 
-	out <- &AddedObject{
+	added := &AddedObject{
 		Hash: o.Hash,
 		Name: name,
 		Size: o.Size,
 	}
+	if st != nil {
+		mtime := st.ModTime()
+		added.FileSize = st.Size()
+		added.ModTime = &mtime
+	}
+	out <- added
 
 	return nil
 }
@@ -577,29 +744,61 @@ func getOutput(dagnode ipld.Node) (*Object, error) {
 	return output, nil
 }
 
-type progressReader struct {
-	file         files.File
-	out          chan interface{}
-	bytes        int64
-	lastProgress int64
+// reserveReader calls the Reserve hook of the adder with the bytes read,
+// failing with its error.
+type reserveReader struct {
+	r     io.Reader
+	adder *Adder
 }
 
-func (i *progressReader) Read(p []byte) (int, error) {
-	n, err := i.file.Read(p)
-
-	i.bytes += int64(n)
-	if i.bytes-i.lastProgress >= progressReaderIncrement || err == io.EOF {
-		i.lastProgress = i.bytes
-		i.out <- &AddedObject{
-			Name:  i.file.FileName(),
-			Bytes: i.bytes,
+func (r *reserveReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if rerr := r.adder.Reserve(uint64(n)); rerr != nil {
+			r.adder.reserveErr = rerr
+			return n, rerr
 		}
 	}
-
 	return n, err
 }
 
-type progressReader2 struct {
-	*progressReader
+type reserveFileInfoReader struct {
+	*reserveReader
 	files.FileInfo
 }
+
+// progressReporter sends the progress of the building of the file named name
+// to out, every progressReaderIncrement bytes hashed or persisted.
+type progressReporter struct {
+	name      string
+	out       chan interface{}
+	last, cur ihelper.Progress
+}
+
+// report is called by the DAG builder, which it mustn't hold: the progress
+// is dropped when out isn't ready, the next one including it.
+func (r *progressReporter) report(p ihelper.Progress) {
+	r.cur = p
+	if p.Bytes-r.last.Bytes < progressReaderIncrement && p.PersistedBytes-r.last.PersistedBytes < progressReaderIncrement {
+		return
+	}
+	select {
+	case r.out <- r.object():
+		r.last = r.cur
+	default:
+	}
+}
+
+// send sends the progress so far, once the file is built.
+func (r *progressReporter) send() {
+	r.last = r.cur
+	r.out <- r.object()
+}
+
+func (r *progressReporter) object() *AddedObject {
+	return &AddedObject{
+		Name:      r.name,
+		Bytes:     int64(r.cur.Bytes),
+		Persisted: int64(r.cur.PersistedBytes),
+	}
+}
@@ -3,6 +3,7 @@ package coreunix
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"math/rand"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/ipfs/go-ipfs/blockservice"
 	"github.com/ipfs/go-ipfs/core"
+	erasure "github.com/ipfs/go-ipfs/importer/erasure"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	"github.com/ipfs/go-ipfs/pin/gc"
 	"github.com/ipfs/go-ipfs/repo"
@@ -157,6 +159,60 @@ func TestAddGCLive(t *testing.T) {
 	}
 }
 
+func TestAddContinueOnError(t *testing.T) {
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := make(chan interface{}, 16)
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Out = out
+	adder.ContinueOnError = true
+
+	piper, pipew := io.Pipe()
+	pipew.CloseWithError(errors.New("broken"))
+	slf := files.NewSliceFile("files", "files", []files.File{
+		files.NewReaderFile("files/a", "a", ioutil.NopCloser(bytes.NewBufferString("testfileA")), nil),
+		files.NewReaderFile("files/b", "b", piper, nil),
+		files.NewReaderFile("files/d", "d", ioutil.NopCloser(bytes.NewBufferString("testfileD")), nil),
+	})
+	if err := adder.AddFile(slf); err != nil {
+		t.Fatal(err)
+	}
+	if adder.Failed() != 1 {
+		t.Fatalf("expected 1 file to fail, got %d", adder.Failed())
+	}
+
+	nd, err := adder.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+
+	added := make(map[string]string)
+	for o := range out {
+		o := o.(*AddedObject)
+		if o.Error != "" {
+			added[o.Name] = "error: " + o.Error
+		} else if o.Hash != "" {
+			added[o.Name] = o.Hash
+		}
+	}
+	if added["files/b"] != "error: broken" {
+		t.Fatalf("expected the error of files/b to be output, got %q", added["files/b"])
+	}
+	if added["files/a"] == "" || added["files/d"] == "" || added["files"] != nd.Cid().String() {
+		t.Fatalf("expected the other files to be added, got %v", added)
+	}
+	if len(nd.Links()) != 2 {
+		t.Fatalf("expected 2 files in the directory, got %d", len(nd.Links()))
+	}
+}
+
 func testAddWPosInfo(t *testing.T, rawLeaves bool) {
 	r := &repo.Mock{
 		C: config.Config{
@@ -214,6 +270,104 @@ func testAddWPosInfo(t *testing.T, rawLeaves bool) {
 	}
 }
 
+func TestAddProgress(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: testPeerID, // required by offline node
+			},
+		},
+		D: syncds.MutexWrap(datastore.NewMapDatastore()),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Out = make(chan interface{})
+	adder.Progress = true
+
+	data := make([]byte, 5*1024*1024)
+	rand.New(rand.NewSource(2)).Read(data)
+	file := files.NewReaderFile("foo.txt", "", ioutil.NopCloser(bytes.NewReader(data)), nil)
+
+	go func() {
+		defer close(adder.Out)
+		if err := adder.AddFile(file); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var last *AddedObject
+	for o := range adder.Out {
+		o := o.(*AddedObject)
+		if o.Hash != "" {
+			continue
+		}
+		if last != nil && (o.Bytes < last.Bytes || o.Persisted < last.Persisted) {
+			t.Fatalf("expected the progress to only grow, got %+v after %+v", o, last)
+		}
+		if o.Persisted > o.Bytes {
+			t.Fatalf("expected no more data persisted than hashed, got %+v", o)
+		}
+		last = o
+	}
+	if last == nil || last.Bytes != int64(len(data)) || last.Persisted != int64(len(data)) {
+		t.Fatalf("expected the whole file to be hashed and persisted, got %+v", last)
+	}
+}
+
+func TestAddErasure(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: testPeerID, // required by offline node
+			},
+		},
+		D: syncds.MutexWrap(datastore.NewMapDatastore()),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Erasure = &erasure.Params{DataShards: 3, ParityShards: 2}
+
+	data := make([]byte, 3*1024*1024+1000)
+	rand.New(rand.NewSource(3)).Read(data)
+	nd, err := adder.AddReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !erasure.IsManifest(nd) {
+		t.Fatal("expected the file to be erasure-coded")
+	}
+
+	// a data shard lost
+	if err := node.DAG.Remove(context.Background(), nd.Links()[0].Cid); err != nil {
+		t.Fatal(err)
+	}
+	dr, err := Cat(context.Background(), node, "/ipfs/"+nd.Cid().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(dr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatal("the data read back differs")
+	}
+}
+
 func TestAddWPosInfo(t *testing.T) {
 	testAddWPosInfo(t, false)
 }
@@ -270,3 +424,62 @@ func (fi *dummyFileInfo) Mode() os.FileMode  { return 0 }
 func (fi *dummyFileInfo) ModTime() time.Time { return fi.modTime }
 func (fi *dummyFileInfo) IsDir() bool        { return false }
 func (fi *dummyFileInfo) Sys() interface{}   { return nil }
+
+func TestReusableNeedsWholeDAG(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: testPeerID, // required by offline node
+			},
+		},
+		D: syncds.MutexWrap(datastore.NewMapDatastore()),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 1024*1024)
+	rand.New(rand.NewSource(4)).Read(data)
+	nd, err := adder.AddReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nd.Links()) == 0 {
+		t.Fatal("expected the file to be chunked")
+	}
+
+	f, err := ioutil.TempFile("", "reuse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	st, err := os.Stat(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adder.Previous = map[string]PreviousFile{
+		"file": {Cid: nd.Cid(), Size: st.Size(), ModTime: st.ModTime()},
+	}
+	if _, ok := adder.reusable("file", st); !ok {
+		t.Fatal("expected the file to be reusable")
+	}
+
+	// a leaf lost
+	if err := node.Blockstore.DeleteBlock(nd.Links()[0].Cid); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := adder.reusable("file", st); ok {
+		t.Fatal("expected the file with a missing block not to be reusable")
+	}
+}
@@ -4,21 +4,42 @@ import (
 	"context"
 
 	core "github.com/ipfs/go-ipfs/core"
+	erasure "github.com/ipfs/go-ipfs/importer/erasure"
 	path "github.com/ipfs/go-ipfs/path"
 	resolver "github.com/ipfs/go-ipfs/path/resolver"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 )
 
 func Cat(ctx context.Context, n *core.IpfsNode, pstr string) (uio.DagReader, error) {
+	return cat(ctx, n, pstr, false)
+}
+
+// CatFollowingSymlinks is Cat following the unixfs symlinks of pstr to their
+// targets.
+func CatFollowingSymlinks(ctx context.Context, n *core.IpfsNode, pstr string) (uio.DagReader, error) {
+	return cat(ctx, n, pstr, true)
+}
+
+func cat(ctx context.Context, n *core.IpfsNode, pstr string, followSymlinks bool) (uio.DagReader, error) {
 	r := &resolver.Resolver{
 		DAG:         n.DAG,
 		ResolveOnce: uio.ResolveUnixfsOnce,
 	}
+	if followSymlinks {
+		r.Symlink = uio.SymlinkTarget
+	}
 
 	dagNode, err := core.Resolve(ctx, n.Namesys, r, path.Path(pstr))
 	if err != nil {
 		return nil, err
 	}
 
+	if erasure.IsManifest(dagNode) {
+		r, err := erasure.NewReader(ctx, dagNode, n.DAG)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
 	return uio.NewDagReader(ctx, dagNode, n.DAG)
 }
@@ -0,0 +1,121 @@
+package coreunix
+
+import (
+	"io"
+	"net/http"
+	gopath "path"
+	"strings"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	unixfs "github.com/ipfs/go-ipfs/unixfs"
+
+	posinfo "gx/ipfs/QmUWsXLvYYDAaoAt9TPZpFX4ffHHMg46AHrz1ZLTN5ABbe/go-ipfs-posinfo"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	files "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit/files"
+)
+
+// sniffLen is the number of bytes used to detect the MIME type of a file, as
+// in http.DetectContentType.
+const sniffLen = 512
+
+// sniffReader keeps the first bytes read from a file, to detect its MIME
+// type once it's added.
+type sniffReader struct {
+	r    io.Reader
+	head []byte
+}
+
+func (s *sniffReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if missing := sniffLen - len(s.head); missing > 0 {
+		if missing > n {
+			missing = n
+		}
+		s.head = append(s.head, p[:missing]...)
+	}
+	return n, err
+}
+
+// sniffFileReader is a sniffReader for files of the filestore, which need
+// their FileInfo.
+type sniffFileReader struct {
+	*sniffReader
+	files.FileInfo
+}
+
+// newSniffReader returns a sniffReader reading r, keeping its FileInfo.
+func newSniffReader(r io.Reader) (io.Reader, *sniffReader) {
+	s := &sniffReader{r: r}
+	if fi, ok := r.(files.FileInfo); ok {
+		return &sniffFileReader{s, fi}, s
+	}
+	return s, s
+}
+
+// mimeTypes are the MIME types of the file extensions. They're built in,
+// rather than taken from the MIME tables of the system, for a file to be
+// added with the same metadata, and CID, everywhere.
+var mimeTypes = map[string]string{
+	".css":   "text/css; charset=utf-8",
+	".csv":   "text/csv; charset=utf-8",
+	".gif":   "image/gif",
+	".gz":    "application/gzip",
+	".htm":   "text/html; charset=utf-8",
+	".html":  "text/html; charset=utf-8",
+	".ico":   "image/vnd.microsoft.icon",
+	".jpeg":  "image/jpeg",
+	".jpg":   "image/jpeg",
+	".js":    "application/javascript",
+	".json":  "application/json",
+	".md":    "text/markdown; charset=utf-8",
+	".mp3":   "audio/mpeg",
+	".mp4":   "video/mp4",
+	".ogg":   "audio/ogg",
+	".otf":   "font/otf",
+	".pdf":   "application/pdf",
+	".png":   "image/png",
+	".svg":   "image/svg+xml",
+	".tar":   "application/x-tar",
+	".ttf":   "font/ttf",
+	".txt":   "text/plain; charset=utf-8",
+	".wasm":  "application/wasm",
+	".webm":  "video/webm",
+	".webp":  "image/webp",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".xml":   "text/xml; charset=utf-8",
+	".zip":   "application/zip",
+}
+
+// MimeType returns the MIME type of the file named name. It's guessed from
+// the extension of name, and otherwise detected from head, the first bytes of
+// the file.
+func MimeType(name string, head []byte) string {
+	if t, ok := mimeTypes[strings.ToLower(gopath.Ext(name))]; ok {
+		return t
+	}
+	return http.DetectContentType(head)
+}
+
+// withMetadata returns a metadata node giving the MIME type of the file nd,
+// and adds it.
+func (adder *Adder) withMetadata(nd ipld.Node, mimeType string) (ipld.Node, error) {
+	if pi, ok := nd.(*posinfo.FilestoreNode); ok {
+		nd = pi.Node
+	}
+
+	data, err := unixfs.BytesForMetadata(&unixfs.Metadata{MimeType: mimeType})
+	if err != nil {
+		return nil, err
+	}
+
+	mdnode := dag.NodeWithData(data)
+	mdnode.SetPrefix(adder.Prefix)
+	if err := mdnode.AddNodeLink("file", nd); err != nil {
+		return nil, err
+	}
+	if err := adder.dagService.Add(adder.ctx, mdnode); err != nil {
+		return nil, err
+	}
+	return mdnode, nil
+}
@@ -0,0 +1,137 @@
+package coreunix
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	"github.com/ipfs/go-ipfs/repo"
+	"github.com/ipfs/go-ipfs/repo/config"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+
+	files "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit/files"
+	datastore "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	syncds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
+)
+
+func TestMimeType(t *testing.T) {
+	cases := []struct {
+		name string
+		head string
+		exp  string
+	}{
+		{"style.css", "body {}", "text/css; charset=utf-8"},
+		{"STYLE.CSS", "body {}", "text/css; charset=utf-8"},
+		{"app.wasm", "\x00asm", "application/wasm"},
+		{"noext", "<html><body></body></html>", "text/html; charset=utf-8"},
+		{"noext", "\x89PNG\x0D\x0A\x1A\x0A", "image/png"},
+	}
+	for _, c := range cases {
+		if got := MimeType(c.name, []byte(c.head)); got != c.exp {
+			t.Errorf("MimeType(%q): got %q, expected %q", c.name, got, c.exp)
+		}
+	}
+}
+
+func TestAddMimeType(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: testPeerID, // required by offline node
+			},
+		},
+		D: syncds.MutexWrap(datastore.NewMapDatastore()),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.MimeType = true
+
+	content := []byte("<html><body>hello</body></html>")
+	f := files.NewReaderFile("page", "page", ioutil.NopCloser(bytes.NewReader(content)), nil)
+	if err := adder.AddFile(f); err != nil {
+		t.Fatal(err)
+	}
+	root, err := adder.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, err := ft.MetadataFromBytes(root.(*dag.ProtoNode).Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.MimeType != "text/html; charset=utf-8" {
+		t.Fatalf("stored MIME type %q", md.MimeType)
+	}
+
+	dr, err := uio.NewDagReader(context.Background(), root, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(dr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, content) {
+		t.Fatalf("read %q through the metadata node, expected %q", out, content)
+	}
+}
+
+func TestAddMimeTypeWrapped(t *testing.T) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: testPeerID, // required by offline node
+			},
+		},
+		D: syncds.MutexWrap(datastore.NewMapDatastore()),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.MimeType = true
+	adder.Wrap = true
+
+	content := []byte("body { color: red; }")
+	f := files.NewReaderFile("style.css", "style.css", ioutil.NopCloser(bytes.NewReader(content)), nil)
+	if err := adder.AddFile(f); err != nil {
+		t.Fatal(err)
+	}
+	root, err := adder.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lnk, _, err := root.ResolveLink([]string{"style.css"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := lnk.GetNode(context.Background(), node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := ft.MetadataFromBytes(nd.(*dag.ProtoNode).Data())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.MimeType != "text/css; charset=utf-8" {
+		t.Fatalf("stored MIME type %q", md.MimeType)
+	}
+}
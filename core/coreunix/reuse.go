@@ -0,0 +1,74 @@
+package coreunix
+
+import (
+	"os"
+	"time"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	offline "gx/ipfs/QmPf114DXfa6TqGKYhBGR7EtXRho4rCJgwyA1xkuMY5vwF/go-ipfs-exchange-offline"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	files "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit/files"
+)
+
+// PreviousFile is a file added before, which is added again without being
+// chunked while it keeps its size and modification time.
+type PreviousFile struct {
+	Cid     *cid.Cid
+	Size    int64
+	ModTime time.Time
+}
+
+// fileStat returns the stat of the file on disk, nil when unknown. When the
+// file isn't read from the local filesystem, its absolute path is the one
+// on the filesystem the client shares with the node, as for the filestore.
+func fileStat(f files.File) os.FileInfo {
+	fi, ok := f.(files.FileInfo)
+	if !ok {
+		return nil
+	}
+
+	st := fi.Stat()
+	if st == nil {
+		if fi.AbsPath() == "" {
+			return nil
+		}
+		var err error
+		st, err = os.Stat(fi.AbsPath())
+		if err != nil {
+			return nil
+		}
+	}
+	if !st.Mode().IsRegular() {
+		return nil
+	}
+	return st
+}
+
+// reusable returns the node of the file named name added before, if it
+// didn't change since and all the blocks of its DAG are still stored. The
+// blocks are only looked up in the blockstore, never fetched.
+func (adder *Adder) reusable(name string, st os.FileInfo) (ipld.Node, bool) {
+	prev, ok := adder.Previous[name]
+	if !ok || st == nil || prev.Size != st.Size() || !prev.ModTime.Equal(st.ModTime()) {
+		return nil, false
+	}
+	if p := adder.Prefix; p != nil && (prev.Cid.Prefix().Version != p.Version || prev.Cid.Prefix().MhType != p.MhType) {
+		return nil, false
+	}
+
+	offlineDAG := dag.NewDAGService(bserv.New(adder.blockstore, offline.Exchange(adder.blockstore)))
+	nd, err := offlineDAG.Get(adder.ctx, prev.Cid)
+	if err != nil {
+		log.Debugf("reusing %s for %s: %s", prev.Cid, name, err)
+		return nil, false
+	}
+	err = dag.EnumerateChildren(adder.ctx, dag.GetLinksWithDAG(offlineDAG), prev.Cid, cid.NewSet().Visit)
+	if err != nil {
+		log.Debugf("reusing %s for %s: %s", prev.Cid, name, err)
+		return nil, false
+	}
+	return nd, true
+}
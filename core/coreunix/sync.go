@@ -0,0 +1,513 @@
+package coreunix
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	unixfs "github.com/ipfs/go-ipfs/unixfs"
+
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	fsnotify "gx/ipfs/QmfNjggF4Pt6erqg3NDafD3MdvDHk1qqCVr8pL5hnPucS8/fsnotify"
+)
+
+// DefaultSyncDebounce is how long a Syncer waits for the changes to settle
+// before mirroring them.
+const DefaultSyncDebounce = 200 * time.Millisecond
+
+// DefaultSyncMaxWait is how long a Syncer waits at most before mirroring the
+// changes, even if they don't settle.
+const DefaultSyncMaxWait = 5 * time.Second
+
+// The operations of the SyncEvents.
+const (
+	SyncWrite    = "write"
+	SyncMkdir    = "mkdir"
+	SyncRemove   = "remove"
+	SyncFlush    = "sync"
+	SyncConflict = "conflict"
+)
+
+// SyncEvent is a change mirrored by a Syncer.
+type SyncEvent struct {
+	// Op is SyncWrite, SyncMkdir or SyncRemove for the changes of Path,
+	// SyncConflict when Path changed both locally and in MFS since the last
+	// sync, or SyncFlush once the changes of a batch are written to the repo.
+	Op   string
+	Path string
+	// Cid is the one of the file written, of the directory synced, or of the
+	// version of Path in MFS, in conflict.
+	Cid *cid.Cid
+}
+
+// Syncer mirrors a local directory into a directory of the MFS tree of a
+// node.
+type Syncer struct {
+	// Local is the path of the local directory.
+	Local string
+	// Dir is the MFS path of the mirror.
+	Dir string
+	// Exclude are the patterns, as matched by filepath.Match, of the names of
+	// the files and directories which aren't mirrored. Their mirrors are left
+	// as they are.
+	Exclude []string
+	// Prefix is the CID prefix of the files and directories created.
+	Prefix *cid.Prefix
+	// Debounce is how long Watch waits for the changes to settle, MaxWait
+	// how long it waits at most after the first change of a batch.
+	Debounce time.Duration
+	MaxWait  time.Duration
+	// Events, if set, is called with each change mirrored, once the batch
+	// is written and the pin lock released.
+	Events func(SyncEvent) error
+
+	// Resolve are the resolutions, ResolveLocal or ResolveMFS, of the
+	// conflicts by path relative to Dir, OnConflict the one of the others.
+	// The conflicts without resolution are reported and left as they are.
+	Resolve    map[string]string
+	OnConflict string
+
+	n *core.IpfsNode
+
+	// queued are the events of the batch being mirrored
+	queued []SyncEvent
+
+	// last is the hash of the mirror when it was last synced, nil if it
+	// never was, bases, diverged and conflicts are as in syncRecord
+	last      *cid.Cid
+	bases     map[string]string
+	diverged  map[string]string
+	conflicts map[string]string
+}
+
+// NewSyncer returns a Syncer mirroring the local directory local into the
+// MFS directory dir of n.
+func NewSyncer(n *core.IpfsNode, local, dir string) *Syncer {
+	return &Syncer{
+		Local:    local,
+		Dir:      dir,
+		Debounce: DefaultSyncDebounce,
+		MaxWait:  DefaultSyncMaxWait,
+		n:        n,
+	}
+}
+
+// Sync mirrors the whole local directory. The paths of the mirror changed
+// since it was last synced aren't overwritten, unless their conflicts with
+// the local directory are resolved.
+func (s *Syncer) Sync(ctx context.Context) error {
+	return s.locked(func() error {
+		if err := s.loadRecord(); err != nil {
+			return err
+		}
+		if err := s.syncPath(ctx, ""); err != nil {
+			return err
+		}
+		return s.flush(ctx)
+	})
+}
+
+// Watch mirrors the whole local directory, then its changes as they happen,
+// until ctx is done.
+func (s *Syncer) Watch(ctx context.Context) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	// watched first, not to miss the changes made while syncing
+	if err := s.watchTree(w, ""); err != nil {
+		return err
+	}
+	if err := s.Sync(ctx); err != nil {
+		return err
+	}
+
+	pending := make(map[string]struct{})
+	var first time.Time
+	timer := time.NewTimer(s.Debounce)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			rel, err := filepath.Rel(s.Local, ev.Name)
+			if err != nil {
+				continue
+			}
+			rel = filepath.ToSlash(rel)
+			if rel == "." {
+				rel = ""
+			}
+			if s.excluded(rel) {
+				continue
+			}
+			if len(pending) == 0 {
+				first = time.Now()
+			}
+			pending[rel] = struct{}{}
+
+			// the directories created are watched at once, not to miss
+			// the files created in them
+			if ev.Op&fsnotify.Create != 0 {
+				if fi, err := os.Lstat(ev.Name); err == nil && fi.IsDir() {
+					if err := s.watchTree(w, rel); err != nil {
+						log.Warningf("watching %s: %s", ev.Name, err)
+					}
+				}
+			}
+			timer.Reset(s.wait(first))
+		case err := <-w.Errors:
+			if err != fsnotify.ErrEventOverflow {
+				log.Warningf("watching %s: %s", s.Local, err)
+				continue
+			}
+
+			// changes were missed: the whole directory is mirrored again
+			log.Warningf("watching %s: %s, syncing the whole directory", s.Local, err)
+			timer.Stop()
+			pending = make(map[string]struct{})
+			if err := s.watchTree(w, ""); err != nil {
+				return err
+			}
+			if err := s.Sync(ctx); err != nil {
+				return err
+			}
+		case <-timer.C:
+			if len(pending) == 0 {
+				continue
+			}
+			if err := s.apply(ctx, pending); err != nil {
+				return err
+			}
+			pending = make(map[string]struct{})
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// wait returns how long Watch waits for the changes to settle, the first of
+// them being made at first.
+func (s *Syncer) wait(first time.Time) time.Duration {
+	if s.MaxWait <= 0 {
+		return s.Debounce
+	}
+	left := s.MaxWait - time.Since(first)
+	if left < 0 {
+		return 0
+	}
+	if left < s.Debounce {
+		return left
+	}
+	return s.Debounce
+}
+
+// apply mirrors the changes of the paths changed, relative to Local.
+func (s *Syncer) apply(ctx context.Context, changed map[string]struct{}) error {
+	paths := make([]string, 0, len(changed))
+	for rel := range changed {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	return s.locked(func() error {
+		for _, rel := range paths {
+			if err := s.syncPath(ctx, rel); err != nil {
+				return err
+			}
+		}
+		return s.flush(ctx)
+	})
+}
+
+// locked calls f holding the pin lock, not to have the blocks of the mirror
+// collected before they're pinned by the MFS root, then calls Events with the
+// events of f, the lock released not to block the GC on a slow consumer.
+func (s *Syncer) locked(f func() error) error {
+	err := func() error {
+		defer s.n.Blockstore.PinLock().Unlock()
+		return f()
+	}()
+
+	events := s.queued
+	s.queued = nil
+	for _, ev := range events {
+		if err := s.Events(ev); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// watchTree watches the directory rel, relative to Local, and the ones under
+// it.
+func (s *Syncer) watchTree(w *fsnotify.Watcher, rel string) error {
+	return filepath.Walk(s.localPath(rel), func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// removed since
+			return nil
+		}
+		if !fi.IsDir() {
+			return nil
+		}
+		r, err := filepath.Rel(s.Local, p)
+		if err != nil {
+			return err
+		}
+		if r != "." && s.excluded(filepath.ToSlash(r)) {
+			return filepath.SkipDir
+		}
+		return w.Add(p)
+	})
+}
+
+// excluded returns whether the path rel, relative to Local, or one of its
+// parents matches one of the Exclude patterns.
+func (s *Syncer) excluded(rel string) bool {
+	for _, name := range strings.Split(rel, "/") {
+		for _, pattern := range s.Exclude {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Syncer) localPath(rel string) string {
+	return filepath.Join(s.Local, filepath.FromSlash(rel))
+}
+
+func (s *Syncer) mfsPath(rel string) string {
+	return gopath.Join(s.Dir, rel)
+}
+
+// syncPath mirrors the local path rel, relative to Local, whatever it is now.
+func (s *Syncer) syncPath(ctx context.Context, rel string) error {
+	fi, err := os.Lstat(s.localPath(rel))
+	switch {
+	case os.IsNotExist(err) && rel != "":
+		return s.remove(ctx, rel)
+	case err != nil:
+		return err
+	case fi.IsDir():
+		return s.syncDir(ctx, rel)
+	case fi.Mode().IsRegular():
+		return s.writeFile(ctx, rel)
+	case fi.Mode()&os.ModeSymlink != 0:
+		return s.writeSymlink(ctx, rel)
+	default:
+		log.Infof("not mirroring %s, of mode %s", s.localPath(rel), fi.Mode())
+		return nil
+	}
+}
+
+// syncDir mirrors the directory rel and its entries, removing the mirrors of
+// the entries which don't exist anymore.
+func (s *Syncer) syncDir(ctx context.Context, rel string) error {
+	mpath := s.mfsPath(rel)
+	fsn, err := mfs.Lookup(s.n.FilesRoot, mpath)
+	switch {
+	case err == os.ErrNotExist:
+		if ok, err := s.mayOverwrite(ctx, rel, nil, true); !ok || err != nil {
+			return err
+		}
+		err := mfs.Mkdir(s.n.FilesRoot, mpath, mfs.MkdirOpts{Mkparents: true, Prefix: s.Prefix})
+		if err != nil {
+			return err
+		}
+		s.emit(SyncMkdir, mpath, nil)
+	case err != nil:
+		return err
+	case fsn.Type() != mfs.TDir:
+		// was a file
+		if ok, err := s.mayOverwrite(ctx, rel, nil, true); !ok || err != nil {
+			return err
+		}
+		if err := s.unlink(mpath); err != nil {
+			return err
+		}
+		return s.syncDir(ctx, rel)
+	}
+
+	entries, err := ioutil.ReadDir(s.localPath(rel))
+	if err != nil {
+		return err
+	}
+	local := make(map[string]bool, len(entries))
+	for _, fi := range entries {
+		child := gopath.Join(rel, fi.Name())
+		if s.excluded(child) {
+			continue
+		}
+		local[fi.Name()] = true
+		if err := s.syncPath(ctx, child); err != nil {
+			return err
+		}
+	}
+
+	fsn, err = mfs.Lookup(s.n.FilesRoot, mpath)
+	if err != nil {
+		return err
+	}
+	names, err := fsn.(*mfs.Directory).ListNames(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		child := gopath.Join(rel, name)
+		if local[name] || s.excluded(child) {
+			continue
+		}
+		if err := s.remove(ctx, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile mirrors the regular file rel, unless its mirror is the same.
+func (s *Syncer) writeFile(ctx context.Context, rel string) error {
+	f, err := os.Open(s.localPath(rel))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s.remove(ctx, rel)
+		}
+		return err
+	}
+	defer f.Close()
+
+	adder, err := NewAdder(s.n.Context(), s.n.Pinning, s.n.Blockstore, s.n.DAG)
+	if err != nil {
+		return err
+	}
+	adder.Prefix = s.Prefix
+	adder.RawLeaves = s.Prefix != nil && s.Prefix.Version > 0
+	nd, err := adder.ImportReader(f)
+	if err != nil {
+		return err
+	}
+	return s.put(ctx, rel, nd)
+}
+
+// writeSymlink mirrors the symlink rel as a unixfs symlink.
+func (s *Syncer) writeSymlink(ctx context.Context, rel string) error {
+	target, err := os.Readlink(s.localPath(rel))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s.remove(ctx, rel)
+		}
+		return err
+	}
+	data, err := unixfs.SymlinkData(target)
+	if err != nil {
+		return err
+	}
+	nd := dag.NodeWithData(data)
+	nd.SetPrefix(s.Prefix)
+	if err := s.n.DAG.Add(s.n.Context(), nd); err != nil {
+		return err
+	}
+	return s.put(ctx, rel, nd)
+}
+
+// put puts nd at the mirror of rel, replacing what's there.
+func (s *Syncer) put(ctx context.Context, rel string, nd ipld.Node) error {
+	mpath := s.mfsPath(rel)
+	old, err := s.mirrorCid(rel)
+	if err != nil {
+		return err
+	}
+	if sameCid(old, nd.Cid()) {
+		s.synced(rel)
+		return nil
+	}
+	if ok, err := s.mayOverwrite(ctx, rel, nd.Cid(), false); !ok || err != nil {
+		return err
+	}
+	if old != nil {
+		if err := s.unlink(mpath); err != nil {
+			return err
+		}
+	}
+
+	if err := mfs.PutNode(s.n.FilesRoot, mpath, nd); err != nil {
+		return err
+	}
+	s.emit(SyncWrite, mpath, nd.Cid())
+	return nil
+}
+
+// remove removes the mirror of rel, if any.
+func (s *Syncer) remove(ctx context.Context, rel string) error {
+	mpath := s.mfsPath(rel)
+	if _, err := mfs.Lookup(s.n.FilesRoot, mpath); err != nil {
+		s.synced(rel)
+		return nil
+	}
+	if ok, err := s.mayOverwrite(ctx, rel, nil, false); !ok || err != nil {
+		return err
+	}
+	if err := s.unlink(mpath); err != nil {
+		return err
+	}
+	s.emit(SyncRemove, mpath, nil)
+	return nil
+}
+
+func (s *Syncer) unlink(mpath string) error {
+	dir, name := gopath.Split(mpath)
+	fsn, err := mfs.Lookup(s.n.FilesRoot, dir)
+	if err != nil {
+		return err
+	}
+	pdir, ok := fsn.(*mfs.Directory)
+	if !ok {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return pdir.Unlink(name)
+}
+
+// flush writes the mirror to the repo, and records it as synced.
+func (s *Syncer) flush(ctx context.Context) error {
+	if err := mfs.FlushPath(s.n.FilesRoot, s.Dir); err != nil {
+		return err
+	}
+	fsn, err := mfs.Lookup(s.n.FilesRoot, s.Dir)
+	if err != nil {
+		return err
+	}
+	nd, err := fsn.GetNode()
+	if err != nil {
+		return err
+	}
+	if err := s.saveRecord(ctx, nd.Cid()); err != nil {
+		return err
+	}
+	s.emit(SyncFlush, s.Dir, nd.Cid())
+	return nil
+}
+
+// emit queues the event of a change, passed to Events once the batch is
+// mirrored.
+func (s *Syncer) emit(op, mpath string, c *cid.Cid) {
+	if s.Events == nil {
+		return
+	}
+	s.queued = append(s.queued, SyncEvent{Op: op, Path: mpath, Cid: c})
+}
@@ -0,0 +1,276 @@
+package coreunix
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	gopath "path"
+
+	bservice "github.com/ipfs/go-ipfs/blockservice"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+
+	offline "gx/ipfs/QmPf114DXfa6TqGKYhBGR7EtXRho4rCJgwyA1xkuMY5vwF/go-ipfs-exchange-offline"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	base32 "gx/ipfs/QmfVj3x4D6Jkq9SEoi5n2NmoUomLwoeiwnYz2KQa15wRw6/base32"
+)
+
+// The resolutions of the conflicts between the local directory and its
+// mirror.
+const (
+	// ResolveLocal overwrites the mirror with the local version.
+	ResolveLocal = "local"
+	// ResolveMFS keeps the version of the mirror.
+	ResolveMFS = "mfs"
+)
+
+// syncRecord is the state of a mirror when it was last synced, kept under
+// /local/filesync/<MFS directory> in the datastore.
+type syncRecord struct {
+	Local string
+	Root  string
+	// Bases are the hashes of the paths of the mirror under Root, by path
+	// relative to it, "" for Root itself. They're kept in the record, rather
+	// than looked up under Root, as Root isn't pinned: the mirror changed
+	// since may have been garbage collected.
+	Bases map[string]string `json:",omitempty"`
+	// Diverged are the hashes of the local versions of the paths whose
+	// mirror was kept as it was, and Conflicts the hashes of the mirror of
+	// the paths in conflict, as last synced, in place of the ones under Root.
+	// They're by path relative to the mirror, "" for the versions which
+	// didn't exist.
+	Diverged  map[string]string `json:",omitempty"`
+	Conflicts map[string]string `json:",omitempty"`
+}
+
+func syncRecordKey(dir string) ds.Key {
+	return ds.NewKey("/local/filesync/" + base32.RawStdEncoding.EncodeToString([]byte(dir)))
+}
+
+// loadRecord loads the record of the last sync of the mirror, if it was of
+// the same local directory.
+func (s *Syncer) loadRecord() error {
+	s.diverged = make(map[string]string)
+	s.conflicts = make(map[string]string)
+	s.last = nil
+	s.bases = nil
+
+	data, err := s.n.Repo.Datastore().Get(syncRecordKey(s.Dir))
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var rec syncRecord
+	if err := json.Unmarshal(data.([]byte), &rec); err != nil {
+		return fmt.Errorf("invalid sync record of %s: %s", s.Dir, err)
+	}
+	if rec.Local != s.Local {
+		log.Infof("%s was last synced with %s, not %s", s.Dir, rec.Local, s.Local)
+		return nil
+	}
+
+	root, err := cid.Decode(rec.Root)
+	if err != nil {
+		return fmt.Errorf("invalid sync record of %s: %s", s.Dir, err)
+	}
+	bases := rec.Bases
+	if bases == nil {
+		// recorded without its bases, which are looked up under the root
+		// while it's still stored
+		if bases, err = s.mirrorBases(context.TODO(), root); err != nil {
+			log.Warningf("the version of %s last synced, %s, isn't stored anymore: %s", s.Dir, root, err)
+			return nil
+		}
+	}
+	s.last = root
+	s.bases = bases
+	for rel, h := range rec.Diverged {
+		s.diverged[rel] = h
+	}
+	for rel, h := range rec.Conflicts {
+		s.conflicts[rel] = h
+	}
+	return nil
+}
+
+// saveRecord records the mirror, whose hash is now root, as synced.
+func (s *Syncer) saveRecord(ctx context.Context, root *cid.Cid) error {
+	bases, err := s.mirrorBases(ctx, root)
+	if err != nil {
+		return err
+	}
+	rec := syncRecord{Local: s.Local, Root: root.String(), Bases: bases}
+	if len(s.diverged) > 0 {
+		rec.Diverged = s.diverged
+	}
+	if len(s.conflicts) > 0 {
+		rec.Conflicts = s.conflicts
+	}
+	data, err := json.Marshal(&rec)
+	if err != nil {
+		return err
+	}
+	if err := s.n.Repo.Datastore().Put(syncRecordKey(s.Dir), data); err != nil {
+		return err
+	}
+	s.last = root
+	s.bases = bases
+	return nil
+}
+
+// mirrorBases returns the hashes of the paths of the mirror whose hash is
+// root, as in syncRecord. The mirror is only looked up locally, not to hang
+// on the blocks garbage collected.
+func (s *Syncer) mirrorBases(ctx context.Context, root *cid.Cid) (map[string]string, error) {
+	dserv := dag.NewDAGService(bservice.New(s.n.Blockstore, offline.Exchange(s.n.Blockstore)))
+	bases := make(map[string]string)
+	var walk func(rel string, c *cid.Cid) error
+	walk = func(rel string, c *cid.Cid) error {
+		bases[rel] = c.String()
+		nd, err := dserv.Get(ctx, c)
+		if err != nil {
+			return err
+		}
+		dir, err := uio.NewDirectoryFromNode(dserv, nd)
+		if err == uio.ErrNotADir {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		links, err := dir.Links(ctx)
+		if err != nil {
+			return err
+		}
+		for _, l := range links {
+			if err := walk(gopath.Join(rel, l.Name), l.Cid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk("", root); err != nil {
+		return nil, err
+	}
+	return bases, nil
+}
+
+// mayOverwrite returns whether the mirror of rel may be overwritten by its
+// local version, whose hash is mine, nil if removed. Directories replacing
+// files have no hash yet, which isDir tells. The mirror is overwritten when
+// only the local version changed since they were last synced, and kept when
+// only the mirror did. When both did, the conflict is resolved by Resolve or
+// OnConflict, or reported and left as it is.
+func (s *Syncer) mayOverwrite(ctx context.Context, rel string, mine *cid.Cid, isDir bool) (bool, error) {
+	if s.last == nil {
+		// never synced: the local directory is the reference
+		return true, nil
+	}
+
+	theirs, err := s.mirrorCid(rel)
+	if err != nil {
+		return false, err
+	}
+	mirrorBase, err := s.baseCid(rel)
+	if err != nil {
+		return false, err
+	}
+	localBase := mirrorBase
+	if h, ok := s.diverged[rel]; ok {
+		if localBase, err = decodeSyncCid(h); err != nil {
+			return false, err
+		}
+	}
+
+	switch {
+	case !isDir && sameCid(mine, theirs):
+		s.synced(rel)
+		return false, nil
+	case !isDir && sameCid(mine, localBase):
+		// only the mirror changed
+		delete(s.conflicts, rel)
+		s.diverged[rel] = encodeSyncCid(mine)
+		return false, nil
+	case sameCid(theirs, mirrorBase):
+		s.synced(rel)
+		return true, nil
+	}
+
+	resolution, ok := s.Resolve[rel]
+	if !ok {
+		resolution = s.OnConflict
+	}
+	switch resolution {
+	case ResolveLocal:
+		s.synced(rel)
+		return true, nil
+	case ResolveMFS:
+		delete(s.conflicts, rel)
+		s.diverged[rel] = encodeSyncCid(mine)
+		return false, nil
+	}
+
+	if _, ok := s.conflicts[rel]; !ok {
+		s.conflicts[rel] = encodeSyncCid(mirrorBase)
+		s.diverged[rel] = encodeSyncCid(localBase)
+	}
+	s.emit(SyncConflict, s.mfsPath(rel), theirs)
+	return false, nil
+}
+
+// synced forgets the differences of rel and its mirror.
+func (s *Syncer) synced(rel string) {
+	delete(s.diverged, rel)
+	delete(s.conflicts, rel)
+}
+
+// mirrorCid returns the hash of the mirror of rel, nil if it doesn't exist.
+func (s *Syncer) mirrorCid(rel string) (*cid.Cid, error) {
+	fsn, err := mfs.Lookup(s.n.FilesRoot, s.mfsPath(rel))
+	if err == os.ErrNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	nd, err := fsn.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	return nd.Cid(), nil
+}
+
+// baseCid returns the hash the mirror of rel had when it was last synced,
+// nil if it didn't exist.
+func (s *Syncer) baseCid(rel string) (*cid.Cid, error) {
+	if h, ok := s.conflicts[rel]; ok {
+		return decodeSyncCid(h)
+	}
+	return decodeSyncCid(s.bases[rel])
+}
+
+func sameCid(a, b *cid.Cid) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equals(b)
+}
+
+func encodeSyncCid(c *cid.Cid) string {
+	if c == nil {
+		return ""
+	}
+	return c.String()
+}
+
+func decodeSyncCid(h string) (*cid.Cid, error) {
+	if h == "" {
+		return nil, nil
+	}
+	return cid.Decode(h)
+}
@@ -0,0 +1,446 @@
+package coreunix
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	"github.com/ipfs/go-ipfs/pin/gc"
+	"github.com/ipfs/go-ipfs/repo"
+	"github.com/ipfs/go-ipfs/repo/config"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	datastore "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	syncds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
+)
+
+func newSyncTest(t *testing.T) (*core.IpfsNode, string) {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: testPeerID, // required by offline node
+			},
+		},
+		D: syncds.MutexWrap(datastore.NewMapDatastore()),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "sync-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node, dir
+}
+
+func writeSyncFile(t *testing.T, dir, name, data string) {
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mfsTree returns the paths under dir in the MFS tree of n, with the
+// contents of the files.
+func mfsTree(t *testing.T, n *core.IpfsNode, dir string) map[string]string {
+	tree := make(map[string]string)
+	var walk func(p string)
+	walk = func(p string) {
+		fsn, err := mfs.Lookup(n.FilesRoot, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch fsn := fsn.(type) {
+		case *mfs.Directory:
+			names, err := fsn.ListNames(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p != dir {
+				tree[p] = "/"
+			}
+			for _, name := range names {
+				walk(p + "/" + name)
+			}
+		case *mfs.File:
+			fd, err := fsn.Open(mfs.OpenReadOnly, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := ioutil.ReadAll(fd)
+			fd.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tree[p] = string(data)
+		}
+	}
+	walk(dir)
+	return tree
+}
+
+func checkTree(t *testing.T, tree, expected map[string]string) {
+	t.Helper()
+	if len(tree) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, tree)
+	}
+	for p, data := range expected {
+		if tree[p] != data {
+			t.Fatalf("expected %v, got %v", expected, tree)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	n, local := newSyncTest(t)
+	defer os.RemoveAll(local)
+
+	writeSyncFile(t, local, "index.html", "<h1>hello</h1>")
+	writeSyncFile(t, local, "css/site.css", "h1 {}")
+	writeSyncFile(t, local, "index.html.swp", "swap")
+	writeSyncFile(t, local, ".git/HEAD", "ref: refs/heads/master")
+
+	var ops []string
+	s := NewSyncer(n, local, "/site")
+	s.Exclude = []string{"*.swp", ".git"}
+	s.Events = func(ev SyncEvent) error {
+		ops = append(ops, ev.Op+" "+ev.Path)
+		return nil
+	}
+	if err := s.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, mfsTree(t, n, "/site"), map[string]string{
+		"/site/index.html":   "<h1>hello</h1>",
+		"/site/css":          "/",
+		"/site/css/site.css": "h1 {}",
+	})
+	if ops[len(ops)-1] != "sync /site" {
+		t.Fatalf("expected the mirror to be flushed last, got %v", ops)
+	}
+
+	// unchanged files aren't written again
+	ops = nil
+	if err := s.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 {
+		t.Fatalf("expected nothing to change, got %v", ops)
+	}
+
+	// the excluded entries of the mirror are left as they are
+	if err := mfs.Mkdir(n.FilesRoot, "/site/.git", mfs.MkdirOpts{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ops = nil
+	writeSyncFile(t, local, "index.html", "<h1>hello world</h1>")
+	if err := os.RemoveAll(filepath.Join(local, "css")); err != nil {
+		t.Fatal(err)
+	}
+	writeSyncFile(t, local, "css", "now a file")
+	if err := s.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, mfsTree(t, n, "/site"), map[string]string{
+		"/site/index.html": "<h1>hello world</h1>",
+		"/site/css":        "now a file",
+		"/site/.git":       "/",
+	})
+	sort.Strings(ops)
+	expected := []string{"sync /site", "write /site/css", "write /site/index.html"}
+	if len(ops) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, ops)
+	}
+	for i := range ops {
+		if ops[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, ops)
+		}
+	}
+}
+
+// putMFSFile writes data to the MFS file at p, as another client would.
+func putMFSFile(t *testing.T, n *core.IpfsNode, p, data string) {
+	nd := dag.NodeWithData(ft.FilePBData([]byte(data), uint64(len(data))))
+	if err := n.DAG.Add(context.Background(), nd); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.PutNode(n.FilesRoot, p, nd); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncConflicts(t *testing.T) {
+	n, local := newSyncTest(t)
+	defer os.RemoveAll(local)
+
+	writeSyncFile(t, local, "a.html", "a")
+	writeSyncFile(t, local, "b.html", "b")
+	writeSyncFile(t, local, "c.html", "c")
+
+	var conflicts []string
+	s := NewSyncer(n, local, "/site")
+	s.Events = func(ev SyncEvent) error {
+		if ev.Op == SyncConflict {
+			conflicts = append(conflicts, ev.Path)
+		}
+		return nil
+	}
+	if err := s.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// changed in MFS
+	rm := func(name string) {
+		fsn, err := mfs.Lookup(n.FilesRoot, "/site")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fsn.(*mfs.Directory).Unlink(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rm("a.html")
+	putMFSFile(t, n, "/site/a.html", "a from mfs")
+	rm("b.html")
+	putMFSFile(t, n, "/site/extra.html", "extra")
+
+	// and locally
+	writeSyncFile(t, local, "a.html", "a from disk")
+	writeSyncFile(t, local, "c.html", "c from disk")
+
+	// a new syncer, as after a restart
+	s2 := NewSyncer(n, local, "/site")
+	s2.Events = s.Events
+	for i := 0; i < 2; i++ {
+		conflicts = nil
+		if err := s2.Sync(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(conflicts) != 1 || conflicts[0] != "/site/a.html" {
+			t.Fatalf("expected /site/a.html to be in conflict, got %v", conflicts)
+		}
+		checkTree(t, mfsTree(t, n, "/site"), map[string]string{
+			"/site/a.html":     "a from mfs",
+			"/site/c.html":     "c from disk",
+			"/site/extra.html": "extra",
+		})
+	}
+
+	conflicts = nil
+	s2.Resolve = map[string]string{"a.html": ResolveLocal}
+	if err := s2.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected the conflict to be resolved, got %v", conflicts)
+	}
+	checkTree(t, mfsTree(t, n, "/site"), map[string]string{
+		"/site/a.html":     "a from disk",
+		"/site/c.html":     "c from disk",
+		"/site/extra.html": "extra",
+	})
+
+	// the local changes overwrite the mirror again
+	writeSyncFile(t, local, "a.html", "a from disk again")
+	s2.Resolve = nil
+	if err := s2.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 0 || mfsTree(t, n, "/site")["/site/a.html"] != "a from disk again" {
+		t.Fatalf("expected a.html to be written, got %v and %v", conflicts, mfsTree(t, n, "/site"))
+	}
+}
+
+func TestSyncConflictsAfterGC(t *testing.T) {
+	n, local := newSyncTest(t)
+	defer os.RemoveAll(local)
+
+	writeSyncFile(t, local, "dir/a.html", "a")
+	writeSyncFile(t, local, "b.html", "b")
+
+	var conflicts []string
+	s := NewSyncer(n, local, "/site")
+	s.Events = func(ev SyncEvent) error {
+		if ev.Op == SyncConflict {
+			conflicts = append(conflicts, ev.Path)
+		}
+		return nil
+	}
+	if err := s.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// changed in MFS, the version last synced being garbage collected
+	fsn, err := mfs.Lookup(n.FilesRoot, "/site/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fsn.(*mfs.Directory).Unlink("a.html"); err != nil {
+		t.Fatal(err)
+	}
+	putMFSFile(t, n, "/site/dir/a.html", "a from mfs")
+	if err := mfs.FlushPath(n.FilesRoot, "/"); err != nil {
+		t.Fatal(err)
+	}
+	root, err := n.FilesRoot.GetValue().GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for res := range gc.GC(context.Background(), n.Blockstore, n.Repo.Datastore(), n.Pinning, []*cid.Cid{root.Cid()}) {
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+	}
+
+	// and locally
+	writeSyncFile(t, local, "dir/a.html", "a from disk")
+
+	s2 := NewSyncer(n, local, "/site")
+	s2.Events = s.Events
+	if err := s2.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0] != "/site/dir/a.html" {
+		t.Fatalf("expected /site/dir/a.html to be in conflict, got %v", conflicts)
+	}
+	checkTree(t, mfsTree(t, n, "/site"), map[string]string{
+		"/site/dir":        "/",
+		"/site/dir/a.html": "a from mfs",
+		"/site/b.html":     "b",
+	})
+}
+
+func TestSyncEventsUnlocked(t *testing.T) {
+	n, local := newSyncTest(t)
+	defer os.RemoveAll(local)
+
+	writeSyncFile(t, local, "index.html", "<h1>hello</h1>")
+
+	s := NewSyncer(n, local, "/site")
+	s.Events = func(ev SyncEvent) error {
+		// the GC lock can't be taken while the pin lock is held
+		locked := make(chan struct{})
+		go func() {
+			n.Blockstore.GCLock().Unlock()
+			close(locked)
+		}()
+		select {
+		case <-locked:
+			return nil
+		case <-time.After(time.Second):
+			return fmt.Errorf("%s %s emitted holding the pin lock", ev.Op, ev.Path)
+		}
+	}
+	if err := s.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncWatchMaxWait(t *testing.T) {
+	s := &Syncer{Debounce: time.Second, MaxWait: 2 * time.Second}
+	if d := s.wait(time.Now()); d != time.Second {
+		t.Fatalf("expected to wait for the debounce, got %s", d)
+	}
+	if d := s.wait(time.Now().Add(-1500 * time.Millisecond)); d > 500*time.Millisecond {
+		t.Fatalf("expected to wait until the max wait, got %s", d)
+	}
+	if d := s.wait(time.Now().Add(-time.Minute)); d != 0 {
+		t.Fatalf("expected not to wait past the max wait, got %s", d)
+	}
+}
+
+func TestSyncWatch(t *testing.T) {
+	n, local := newSyncTest(t)
+	defer os.RemoveAll(local)
+
+	writeSyncFile(t, local, "index.html", "<h1>hello</h1>")
+	writeSyncFile(t, local, "old.html", "old")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	synced := make(chan struct{}, 10)
+	s := NewSyncer(n, local, "/site")
+	s.Exclude = []string{"*~"}
+	s.Debounce = 50 * time.Millisecond
+	s.Events = func(ev SyncEvent) error {
+		if ev.Op == SyncFlush {
+			select {
+			case synced <- struct{}{}:
+			default:
+			}
+		}
+		return nil
+	}
+	done := make(chan error)
+	go func() {
+		done <- s.Watch(ctx)
+	}()
+
+	waitSync := func(expected map[string]string) {
+		for {
+			select {
+			case <-synced:
+				tree := mfsTree(t, n, "/site")
+				if len(tree) != len(expected) {
+					continue
+				}
+				same := true
+				for p, data := range expected {
+					same = same && tree[p] == data
+				}
+				if same {
+					return
+				}
+			case <-ctx.Done():
+				t.Fatalf("expected %v, got %v", expected, mfsTree(t, n, "/site"))
+			}
+		}
+	}
+	waitSync(map[string]string{
+		"/site/index.html": "<h1>hello</h1>",
+		"/site/old.html":   "old",
+	})
+
+	writeSyncFile(t, local, "index.html~", "backup")
+	writeSyncFile(t, local, "index.html", "<h1>hello world</h1>")
+	writeSyncFile(t, local, "blog/2018/post.html", "post")
+	if err := os.Rename(filepath.Join(local, "old.html"), filepath.Join(local, "new.html")); err != nil {
+		t.Fatal(err)
+	}
+	waitSync(map[string]string{
+		"/site/index.html":          "<h1>hello world</h1>",
+		"/site/new.html":            "old",
+		"/site/blog":                "/",
+		"/site/blog/2018":           "/",
+		"/site/blog/2018/post.html": "post",
+	})
+
+	if err := os.RemoveAll(filepath.Join(local, "blog")); err != nil {
+		t.Fatal(err)
+	}
+	waitSync(map[string]string{
+		"/site/index.html": "<h1>hello world</h1>",
+		"/site/new.html":   "old",
+	})
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,325 @@
+package coreunix
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	gopath "path"
+	"sort"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	pb "github.com/ipfs/go-ipfs/unixfs/pb"
+
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	files "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit/files"
+)
+
+// The reasons of the mismatches found by Verify.
+const (
+	// MismatchMissing is a path of the DAG missing from the files.
+	MismatchMissing = "missing"
+	// MismatchExtra is a file which isn't in the DAG.
+	MismatchExtra = "extra"
+	// MismatchType is a path which is a file on a side, and a directory or
+	// a symlink on the other.
+	MismatchType = "type"
+	// MismatchSize is a file of a different size than in the DAG.
+	MismatchSize = "size"
+	// MismatchContent is a file or a symlink of different content than in
+	// the DAG.
+	MismatchContent = "content"
+)
+
+// maxLeafSize is the size of the largest chunks of files hashed to be
+// compared with the leaves of their DAG. Larger ones are compared with the
+// nodes of the DAG below them.
+const maxLeafSize = 1 << 20
+
+// Mismatch is a difference between a UnixFS DAG and files.
+type Mismatch struct {
+	// Path is the path differing, relative to the root of the DAG.
+	Path string
+	// Reason is the way it differs, one of the Mismatch constants.
+	Reason string
+}
+
+// Verify compares the file or directory f against the UnixFS DAG of nd, and
+// calls found with each path differing. Hidden files are only compared with
+// hidden.
+//
+// The content of the files is compared block by block: the bytes of the
+// files are hashed as the leaves of the DAG are, so that the leaves don't have
+// to be fetched, and compared with the leaves themselves when the hashes
+// differ, which they also do for the leaves encoded differently.
+func Verify(ctx context.Context, ng ipld.NodeGetter, nd ipld.Node, f files.File, hidden bool, found func(Mismatch) error) error {
+	v := &verifier{
+		ctx:      ctx,
+		ng:       ng,
+		hidden:   hidden,
+		found:    found,
+		rootName: gopath.Base(f.FileName()),
+	}
+	return v.verify(nd, f, "")
+}
+
+type verifier struct {
+	ctx    context.Context
+	ng     ipld.NodeGetter
+	hidden bool
+	found  func(Mismatch) error
+	// rootName is the path of the mismatches of the root
+	rootName string
+}
+
+func (v *verifier) mismatch(rel, reason string) error {
+	if rel == "" {
+		rel = v.rootName
+	}
+	return v.found(Mismatch{Path: rel, Reason: reason})
+}
+
+func (v *verifier) verify(nd ipld.Node, f files.File, rel string) error {
+	switch nd := nd.(type) {
+	case *dag.RawNode:
+		return v.verifyFile(nd, f, rel)
+	case *dag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return err
+		}
+
+		switch fsn.Type {
+		case ft.TDirectory, ft.THAMTShard:
+			return v.verifyDir(nd, f, rel)
+		case ft.TFile, ft.TRaw:
+			return v.verifyFile(nd, f, rel)
+		case ft.TSymlink:
+			s, ok := f.(*files.Symlink)
+			if !ok {
+				return v.mismatch(rel, MismatchType)
+			}
+			if s.Target != string(fsn.Data) {
+				return v.mismatch(rel, MismatchContent)
+			}
+			return nil
+		case ft.TMetadata:
+			// the file it describes
+			if len(nd.Links()) == 0 {
+				return ft.ErrMalformedFileFormat
+			}
+			child, err := nd.Links()[0].GetNode(v.ctx, v.ng)
+			if err != nil {
+				return err
+			}
+			return v.verify(child, f, rel)
+		default:
+			return ft.ErrUnrecognizedType
+		}
+	default:
+		return fmt.Errorf("%s is not a unixfs node", nd.Cid())
+	}
+}
+
+func (v *verifier) verifyDir(nd ipld.Node, f files.File, rel string) error {
+	if !f.IsDirectory() {
+		return v.mismatch(rel, MismatchType)
+	}
+
+	dir, err := uio.NewDirectoryFromNode(dag.NewReadOnlyDagService(v.ng), nd)
+	if err != nil {
+		return err
+	}
+	links := make(map[string]*ipld.Link)
+	err = dir.ForEachLink(v.ctx, func(l *ipld.Link) error {
+		if v.hidden || l.Name == "" || l.Name[0] != '.' {
+			links[l.Name] = l
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		child, err := f.NextFile()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if files.IsHidden(child) && !v.hidden {
+			continue
+		}
+
+		name := gopath.Base(child.FileName())
+		crel := gopath.Join(rel, name)
+		l, ok := links[name]
+		if !ok {
+			if err := v.mismatch(crel, MismatchExtra); err != nil {
+				return err
+			}
+			continue
+		}
+		delete(links, name)
+
+		cnd, err := l.GetNode(v.ctx, v.ng)
+		if err != nil {
+			return err
+		}
+		if err := v.verify(cnd, child, crel); err != nil {
+			return err
+		}
+	}
+
+	missing := make([]string, 0, len(links))
+	for name := range links {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		if err := v.mismatch(gopath.Join(rel, name), MismatchMissing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *verifier) verifyFile(nd ipld.Node, f files.File, rel string) error {
+	if f.IsDirectory() {
+		return v.mismatch(rel, MismatchType)
+	}
+	if _, ok := f.(*files.Symlink); ok {
+		return v.mismatch(rel, MismatchType)
+	}
+
+	reason, err := v.compareContent(nd, f)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		// the file must not be longer than in the DAG
+		n, err := f.Read(make([]byte, 1))
+		if n > 0 {
+			reason = MismatchSize
+		} else if err != io.EOF && err != nil {
+			return err
+		}
+	}
+	if reason != "" {
+		return v.mismatch(rel, reason)
+	}
+	return nil
+}
+
+// compareContent compares the content of the file of nd with the bytes read
+// from r, and returns how they differ, if they do.
+func (v *verifier) compareContent(nd ipld.Node, r io.Reader) (string, error) {
+	switch nd := nd.(type) {
+	case *dag.RawNode:
+		return compareBytes(nd.RawData(), r)
+	case *dag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return "", err
+		}
+		if reason, err := compareBytes(fsn.Data, r); reason != "" || err != nil {
+			return reason, err
+		}
+
+		links := nd.Links()
+		if len(links) != fsn.NumChildren() {
+			return "", ft.ErrMalformedFileFormat
+		}
+		for i, l := range links {
+			reason, err := v.compareChild(l, fsn.BlockSize(i), r)
+			if reason != "" || err != nil {
+				return reason, err
+			}
+		}
+		return "", nil
+	default:
+		return "", fmt.Errorf("%s is not a unixfs file node", nd.Cid())
+	}
+}
+
+// compareChild compares the size bytes read from r with the child l of a
+// file node.
+func (v *verifier) compareChild(l *ipld.Link, size uint64, r io.Reader) (string, error) {
+	if size > maxLeafSize {
+		child, err := l.GetNode(v.ctx, v.ng)
+		if err != nil {
+			return "", err
+		}
+		return v.compareContent(child, io.LimitReader(r, int64(size)))
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return MismatchSize, nil
+		}
+		return "", err
+	}
+	if leafMatches(l.Cid, data) {
+		return "", nil
+	}
+
+	child, err := l.GetNode(v.ctx, v.ng)
+	if err != nil {
+		return "", err
+	}
+	br := bytes.NewReader(data)
+	reason, err := v.compareContent(child, br)
+	if reason == "" && err == nil && br.Len() > 0 {
+		reason = MismatchContent
+	}
+	return reason, err
+}
+
+// leafMatches returns whether data is the content of the leaf of c, hashing
+// it as the importer does.
+func leafMatches(c *cid.Cid, data []byte) bool {
+	prefix := c.Prefix()
+	switch prefix.Codec {
+	case cid.Raw:
+		sum, err := prefix.Sum(data)
+		return err == nil && sum.Equals(c)
+	case cid.DagProtobuf:
+		for _, typ := range []pb.Data_DataType{ft.TRaw, ft.TFile} {
+			fsn := &ft.FSNode{Type: typ, Data: data}
+			b, err := fsn.GetBytes()
+			if err != nil {
+				return false
+			}
+			nd := dag.NodeWithData(b)
+			nd.SetPrefix(&prefix)
+			if nd.Cid().Equals(c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compareBytes compares want with the bytes read from r.
+func compareBytes(want []byte, r io.Reader) (string, error) {
+	if len(want) == 0 {
+		return "", nil
+	}
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(r, got); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return MismatchSize, nil
+		}
+		return "", err
+	}
+	if !bytes.Equal(got, want) {
+		return MismatchContent, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,130 @@
+package coreunix
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/core"
+
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	files "gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit/files"
+)
+
+func serialFile(t *testing.T, fpath string) files.File {
+	stat, err := os.Lstat(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := files.NewSerialFile(filepath.Base(fpath), fpath, false, stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func addDir(t *testing.T, n *core.IpfsNode, dir string, rawLeaves bool) ipld.Node {
+	adder, err := NewAdder(n.Context(), n.Pinning, n.Blockstore, n.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Chunker = "size-1000"
+	adder.RawLeaves = rawLeaves
+	if err := adder.AddFile(serialFile(t, dir)); err != nil {
+		t.Fatal(err)
+	}
+	nd, err := adder.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+func verifyDir(t *testing.T, n *core.IpfsNode, nd ipld.Node, dir string) []Mismatch {
+	var found []Mismatch
+	err := Verify(context.Background(), n.DAG, nd, serialFile(t, dir), false, func(m Mismatch) error {
+		found = append(found, m)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func TestVerify(t *testing.T) {
+	n, err := core.NewNode(context.Background(), &core.BuildCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmp, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	dir := filepath.Join(tmp, "dir")
+
+	big := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(big)
+	for name, data := range map[string][]byte{
+		"big":       big,
+		"small":     []byte("small"),
+		"empty":     nil,
+		"sub/file":  []byte("file"),
+		"sub/other": []byte("other"),
+	} {
+		fpath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fpath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, rawLeaves := range []bool{false, true} {
+		nd := addDir(t, n, dir, rawLeaves)
+		if found := verifyDir(t, n, nd, dir); len(found) != 0 {
+			t.Fatalf("raw leaves %t: unexpected mismatches: %v", rawLeaves, found)
+		}
+	}
+	nd := addDir(t, n, dir, false)
+
+	changed := append([]byte(nil), big...)
+	changed[5000]++
+	writes := map[string][]byte{
+		"big":   changed,
+		"small": []byte("smaller"),
+		"extra": []byte("extra"),
+	}
+	for name, data := range writes {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove(filepath.Join(dir, "sub", "other")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "empty")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Mismatch{
+		{"big", MismatchContent},
+		{"empty", MismatchType},
+		{"extra", MismatchExtra},
+		{"small", MismatchSize},
+		{"sub/other", MismatchMissing},
+	}
+	if found := verifyDir(t, n, nd, dir); !reflect.DeepEqual(found, expected) {
+		t.Fatalf("got mismatches %v, expected %v", found, expected)
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	denylist "github.com/ipfs/go-ipfs/denylist"
+	cfg "github.com/ipfs/go-ipfs/repo/config"
+
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	multibase "gx/ipfs/QmexBtiTTEwwn42Yi6ouKt6VqzpA6wjJgiW1oh9VfaRrup/go-multibase"
+)
+
+// DefaultDenylistReloadInterval is how often denylist files are checked for
+// changes when Denylist.ReloadInterval isn't set.
+const DefaultDenylistReloadInterval = 10 * time.Second
+
+// normalizeCid converts a CID string to CIDv1 in base32, the form used to
+// match denylist entries.
+func normalizeCid(s string) (string, error) {
+	c, err := cid.Decode(s)
+	if err != nil {
+		return "", err
+	}
+	return multibase.Encode(multibase.Base32, cid.NewCidV1(c.Type(), c.Hash()).Bytes())
+}
+
+// denylistFiles returns the denylist files of the config, relative paths
+// being resolved against the repo.
+func (n *IpfsNode) denylistFiles(conf *cfg.Config) []string {
+	files := make([]string, len(conf.Denylist.Files))
+	for i, f := range conf.Denylist.Files {
+		if r, ok := n.Repo.(interface{ Path() string }); ok && !filepath.IsAbs(f) {
+			f = filepath.Join(r.Path(), f)
+		}
+		files[i] = f
+	}
+	return files
+}
+
+func (n *IpfsNode) setupDenylist(conf *cfg.Config) error {
+	dl, err := denylist.NewSet(n.denylistFiles(conf), normalizeCid)
+	if err != nil {
+		return err
+	}
+	n.Denylist = dl
+
+	// watched even when empty, files may be added when reloading the config
+	interval := DefaultDenylistReloadInterval
+	if conf.Denylist.ReloadInterval != "" {
+		interval, err = time.ParseDuration(conf.Denylist.ReloadInterval)
+		if err != nil {
+			return err
+		}
+	}
+	go dl.Watch(interval, func(err error) {
+		log.Error("reloading denylist: ", err)
+	})
+	return nil
+}
+
+// denylistBlockService refuses to return blocks whose CIDs are on the node
+// denylist.
+type denylistBlockService struct {
+	bserv.BlockService
+	denylist *denylist.Set
+}
+
+func (s *denylistBlockService) GetBlock(ctx context.Context, c *cid.Cid) (blocks.Block, error) {
+	if s.denylist.IsBlocked(c.String()) {
+		return nil, denylist.ErrBlocked
+	}
+	return s.BlockService.GetBlock(ctx, c)
+}
+
+func (s *denylistBlockService) GetBlocks(ctx context.Context, ks []*cid.Cid) <-chan blocks.Block {
+	allowed := make([]*cid.Cid, 0, len(ks))
+	for _, c := range ks {
+		if !s.denylist.IsBlocked(c.String()) {
+			allowed = append(allowed, c)
+		}
+	}
+	return s.BlockService.GetBlocks(ctx, allowed)
+}
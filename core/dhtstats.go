@@ -0,0 +1,47 @@
+package core
+
+import (
+	"time"
+
+	dhtstats "github.com/ipfs/go-ipfs/dhtstats"
+
+	p2phost "gx/ipfs/QmQQGtcp6nVUrQjNsnU53YWV1q8fK1Kd9S7FEkYbRZzxry/go-libp2p-host"
+	routing "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	kb "gx/ipfs/QmaLVuEDhVAf26sTUPdZdqUfe1nBwEQNrAsKFkkXJTq6Ga/go-libp2p-kbucket"
+	dht "gx/ipfs/QmagBkuFfySAMouyXeiy8XjV1GyfNAgTCuVYGF9z3Z4Vvc/go-libp2p-kad-dht"
+)
+
+// setupDHTStats collects the statistics of the DHT for 'ipfs stats dht',
+// when the node routes with the DHT, and returns r measuring its lookups.
+func (n *IpfsNode) setupDHTStats(host p2phost.Host, r routing.IpfsRouting) routing.IpfsRouting {
+	if _, ok := r.(*dht.IpfsDHT); !ok {
+		return r
+	}
+
+	s := dhtstats.New(host.ID())
+	// the DHT doesn't expose its routing table, so it's mirrored: the DHT
+	// adds the connected peers speaking its protocol to the table, with the
+	// same bucket size and evictions, and removes them once disconnected
+	rt := kb.NewRoutingTable(dhtstats.BucketSize, kb.ConvertPeerID(host.ID()), time.Minute, host.Peerstore())
+	s.Peers = func() []peer.ID {
+		connected := make(map[peer.ID]bool)
+		for _, p := range host.Network().Peers() {
+			protos, err := host.Peerstore().SupportsProtocols(p, string(dht.ProtocolDHT), string(dht.ProtocolDHTOld))
+			if err == nil && len(protos) > 0 {
+				connected[p] = true
+				rt.Update(p)
+			}
+		}
+		for _, p := range rt.ListPeers() {
+			if !connected[p] {
+				rt.Remove(p)
+			}
+		}
+		return rt.ListPeers()
+	}
+
+	n.DHTStats = s
+	n.Process().Go(s.Run)
+	return s.Wrap(r)
+}
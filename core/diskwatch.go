@@ -0,0 +1,127 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+	diskwatch "github.com/ipfs/go-ipfs/repo/diskwatch"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	bstore "gx/ipfs/QmbaPGg81pvQiC5vTXtC9Jo8rdrWUjRaugH71WYNsgi6Ev/go-ipfs-blockstore"
+)
+
+// diskBlockstore refuses new blocks when the filesystem of the repo is
+// almost full. The blocks already stored, which aren't written again, don't
+// count in the estimate of the free space.
+type diskBlockstore struct {
+	bstore.GCBlockstore
+	watchdog *diskwatch.Watchdog
+}
+
+func (bs *diskBlockstore) Put(b blocks.Block) error {
+	if err := bs.watchdog.Check(); err != nil {
+		return err
+	}
+	has, err := bs.GCBlockstore.Has(b.Cid())
+	if err != nil {
+		return err
+	}
+	if err := bs.GCBlockstore.Put(b); err != nil {
+		return err
+	}
+	if !has {
+		bs.watchdog.Wrote(len(b.RawData()))
+	}
+	return nil
+}
+
+func (bs *diskBlockstore) PutMany(blks []blocks.Block) error {
+	if err := bs.watchdog.Check(); err != nil {
+		return err
+	}
+	var size int
+	for _, b := range blks {
+		has, err := bs.GCBlockstore.Has(b.Cid())
+		if err != nil {
+			return err
+		}
+		if !has {
+			size += len(b.RawData())
+		}
+	}
+	if err := bs.GCBlockstore.PutMany(blks); err != nil {
+		return err
+	}
+	bs.watchdog.Wrote(size)
+	return nil
+}
+
+// CheckDiskSpace returns a *diskwatch.LowSpaceError when the filesystem of
+// the repo is too full for new data, for the commands to fail early.
+func (n *IpfsNode) CheckDiskSpace() error {
+	return n.DiskWatchdog.Check()
+}
+
+// repoPath returns the path of the repo, or "" when it isn't on a
+// filesystem.
+func (n *IpfsNode) repoPath() string {
+	if r, ok := n.Repo.(interface{ Path() string }); ok {
+		return r.Path()
+	}
+	return ""
+}
+
+// setupDiskWatchdog starts watching the free space of the repo when
+// Datastore.DiskWatchdog sets thresholds. The daemon checks it periodically
+// with DiskWatchdog.Run; other commands check it once.
+func (n *IpfsNode) setupDiskWatchdog(cfg config.DiskWatchdog) error {
+	var th diskwatch.Thresholds
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *uint64
+	}{
+		{"WarnFreeSpace", cfg.WarnFreeSpace, &th.Warn},
+		{"GCFreeSpace", cfg.GCFreeSpace, &th.GC},
+		{"MinFreeSpace", cfg.MinFreeSpace, &th.Min},
+	} {
+		if f.value == "" {
+			continue
+		}
+		v, err := humanize.ParseBytes(f.value)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting Datastore.DiskWatchdog.%s: %s", f.name, err)
+		}
+		*f.dst = v
+	}
+	if th == (diskwatch.Thresholds{}) {
+		return nil
+	}
+
+	path := n.repoPath()
+	if path == "" {
+		log.Warning("Datastore.DiskWatchdog is ignored: the repo isn't on a filesystem")
+		return nil
+	}
+
+	w := diskwatch.New(path, th)
+	if cfg.CheckInterval != "" {
+		d, err := time.ParseDuration(cfg.CheckInterval)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting Datastore.DiskWatchdog.CheckInterval: %s", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("config setting Datastore.DiskWatchdog.CheckInterval must be positive: %s", d)
+		}
+		w.Interval = d
+	}
+	if err := w.Update(); err != nil {
+		log.Warning("failed to check the free disk space: ", err)
+	}
+
+	n.DiskWatchdog = w
+	n.Blockstore = &diskBlockstore{GCBlockstore: n.Blockstore, watchdog: w}
+	return nil
+}
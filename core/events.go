@@ -0,0 +1,59 @@
+package core
+
+import (
+	events "github.com/ipfs/go-ipfs/events"
+
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	inet "gx/ipfs/QmXdgNhVEgjLxjUoMs5ViQL7pboAt3Y7V7eGHRiE4qrmTE/go-libp2p-net"
+	bstore "gx/ipfs/QmbaPGg81pvQiC5vTXtC9Jo8rdrWUjRaugH71WYNsgi6Ev/go-ipfs-blockstore"
+)
+
+// eventBlockstore emits a BlockAdded event for every block written through it.
+type eventBlockstore struct {
+	bstore.GCBlockstore
+	bus *events.Bus
+}
+
+func (bs *eventBlockstore) Put(b blocks.Block) error {
+	if err := bs.GCBlockstore.Put(b); err != nil {
+		return err
+	}
+	bs.emit(b)
+	return nil
+}
+
+func (bs *eventBlockstore) PutMany(blks []blocks.Block) error {
+	if err := bs.GCBlockstore.PutMany(blks); err != nil {
+		return err
+	}
+	for _, b := range blks {
+		bs.emit(b)
+	}
+	return nil
+}
+
+func (bs *eventBlockstore) emit(b blocks.Block) {
+	bs.bus.Emit(events.BlockAdded, map[string]string{
+		"cid": b.Cid().String(),
+	})
+}
+
+// connEventNotifee returns a network notifiee that reports peer connections
+// on the node's event bus.
+func connEventNotifee(bus *events.Bus) inet.Notifiee {
+	emit := func(t events.Type, c inet.Conn) {
+		bus.Emit(t, map[string]string{
+			"peer": c.RemotePeer().Pretty(),
+			"addr": c.RemoteMultiaddr().String(),
+		})
+	}
+
+	return &inet.NotifyBundle{
+		ConnectedF: func(_ inet.Network, c inet.Conn) {
+			emit(events.PeerConnected, c)
+		},
+		DisconnectedF: func(_ inet.Network, c inet.Conn) {
+			emit(events.PeerDisconnected, c)
+		},
+	}
+}
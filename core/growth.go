@@ -0,0 +1,86 @@
+package core
+
+import (
+	growth "github.com/ipfs/go-ipfs/repo/growth"
+
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	bstore "gx/ipfs/QmbaPGg81pvQiC5vTXtC9Jo8rdrWUjRaugH71WYNsgi6Ev/go-ipfs-blockstore"
+)
+
+// growthBlockstore counts the blocks added to and removed from the
+// blockstore. Blocks which were already stored aren't counted as added.
+type growthBlockstore struct {
+	bstore.GCBlockstore
+	growth *growth.Tracker
+}
+
+func (bs *growthBlockstore) Put(b blocks.Block) error {
+	has, err := bs.GCBlockstore.Has(b.Cid())
+	if err != nil {
+		return err
+	}
+	if err := bs.GCBlockstore.Put(b); err != nil {
+		return err
+	}
+	if !has {
+		bs.growth.Added(len(b.RawData()))
+	}
+	return nil
+}
+
+func (bs *growthBlockstore) PutMany(blks []blocks.Block) error {
+	var added []blocks.Block
+	for _, b := range blks {
+		has, err := bs.GCBlockstore.Has(b.Cid())
+		if err != nil {
+			return err
+		}
+		if !has {
+			added = append(added, b)
+		}
+	}
+	if err := bs.GCBlockstore.PutMany(blks); err != nil {
+		return err
+	}
+	for _, b := range added {
+		bs.growth.Added(len(b.RawData()))
+	}
+	return nil
+}
+
+func (bs *growthBlockstore) DeleteBlock(c *cid.Cid) error {
+	if err := bs.GCBlockstore.DeleteBlock(c); err != nil {
+		return err
+	}
+	bs.growth.Removed()
+	return nil
+}
+
+// setupGrowthStats counts the blocks added to and removed from the
+// blockstore. Online nodes also save the counts periodically and sample
+// their pins and repo size once a day.
+func (n *IpfsNode) setupGrowthStats(online bool) {
+	n.Growth = growth.NewTracker(n.Repo.Datastore())
+	n.Blockstore = &growthBlockstore{GCBlockstore: n.Blockstore, growth: n.Growth}
+
+	if online {
+		n.Growth.Sampler = n.sampleRepo
+		n.Process().Go(n.Growth.Run)
+	}
+}
+
+func (n *IpfsNode) sampleRepo() (growth.Sample, error) {
+	var s growth.Sample
+	if n.Pinning != nil {
+		s.RecursivePins = uint64(len(n.Pinning.RecursiveKeys()))
+		s.DirectPins = uint64(len(n.Pinning.DirectKeys()))
+	}
+
+	size, err := n.Repo.GetStorageUsage()
+	if err != nil {
+		return s, err
+	}
+	s.RepoSize = size
+	return s, nil
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// HealthCheck is the result of one of the readiness checks of a node.
+type HealthCheck struct {
+	Name string
+	OK   bool
+	Info string `json:",omitempty"`
+}
+
+// Live returns whether the node is running, i.e. it hasn't started shutting
+// down.
+func (n *IpfsNode) Live() bool {
+	if n.proc == nil {
+		return false
+	}
+	select {
+	case <-n.proc.Closing():
+		return false
+	default:
+		return true
+	}
+}
+
+// Readiness runs the checks telling whether the node is ready to serve
+// requests: its repo is open (which implies that migrations were applied),
+// the swarm is listening and the first bootstrap round completed. The network
+// checks pass trivially for offline nodes.
+func (n *IpfsNode) Readiness() []HealthCheck {
+	checks := []HealthCheck{{Name: "repo", OK: true}}
+	if n.Repo == nil {
+		checks[0].OK, checks[0].Info = false, "no repo"
+	} else if _, err := n.Repo.Config(); err != nil {
+		checks[0].OK, checks[0].Info = false, err.Error()
+	}
+
+	if !n.OnlineMode() {
+		return append(checks,
+			HealthCheck{Name: "swarm", OK: true, Info: "offline"},
+			HealthCheck{Name: "bootstrap", OK: true, Info: "offline"})
+	}
+
+	swarm := HealthCheck{Name: "swarm"}
+	if n.PeerHost == nil {
+		swarm.Info = "not started"
+	} else if addrs := n.PeerHost.Network().ListenAddresses(); len(addrs) == 0 {
+		swarm.Info = "not listening"
+	} else {
+		swarm.OK, swarm.Info = true, fmt.Sprintf("listening on %d addresses", len(addrs))
+	}
+
+	bootstrap := HealthCheck{Name: "bootstrap", OK: atomic.LoadInt32(&n.bootstrapped) != 0}
+	if !bootstrap.OK {
+		bootstrap.Info = "first round in progress"
+	}
+
+	return append(checks, swarm, bootstrap)
+}
+
+// Ready returns whether all the checks passed.
+func Ready(checks []HealthCheck) bool {
+	for _, c := range checks {
+		if !c.OK {
+			return false
+		}
+	}
+	return true
+}
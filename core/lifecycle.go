@@ -0,0 +1,150 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	events "github.com/ipfs/go-ipfs/events"
+
+	mamask "gx/ipfs/QmSMZwvs3n4GBikZ7hKzT17c3bk65FmyZo2JqtJ16swqCv/multiaddr-filter"
+	swarm "gx/ipfs/QmSvhbgtjQJKdT5avEeb7cvjYs7YrhebJyM1K6GAnkKgfd/go-libp2p-swarm"
+)
+
+// suspendSyncTimeout bounds the time Suspend spends persisting the state of
+// the node, as mobile OSes give apps little time once in the background.
+const suspendSyncTimeout = 5 * time.Second
+
+var (
+	errSuspended    = errors.New("node is already suspended")
+	errNotSuspended = errors.New("node is not suspended")
+)
+
+// suspendMasks block all the addresses while the node is suspended.
+var suspendMasks = []string{
+	"/ip4/0.0.0.0/ipcidr/0",
+	"/ip6/::/ipcidr/0",
+}
+
+// Suspend quiesces the node and persists its state, for mobile apps going to
+// the background: it stops bootstrapping, reproviding and serving the DHT,
+// closes all the connections and refuses new ones, and saves the peerstore,
+// the repo growth counters and the files root. The repo stays open, and
+// Resume brings the node back online.
+func (n *IpfsNode) Suspend() error {
+	n.power.mu.Lock()
+	defer n.power.mu.Unlock()
+
+	if n.power.suspended {
+		return errSuspended
+	}
+	n.power.suspended = true
+	n.applyQuiet()
+
+	if n.PeerHost != nil {
+		n.power.rebootstrap = n.Bootstrapper != nil
+		if n.Bootstrapper != nil {
+			n.Bootstrapper.Close()
+			n.Bootstrapper = nil
+		}
+
+		if err := n.setSuspendFilters(true); err != nil {
+			return err
+		}
+		nw := n.PeerHost.Network()
+		for _, p := range nw.Peers() {
+			if err := nw.ClosePeer(p); err != nil {
+				log.Debugf("failed to close the connections to %s: %s", p, err)
+			}
+		}
+	}
+
+	err := n.persist()
+	n.Events.Emit(events.NodeSuspended, map[string]string{
+		"suspended": "true",
+	})
+	return err
+}
+
+// Resume brings a node suspended with Suspend back online.
+func (n *IpfsNode) Resume() error {
+	n.power.mu.Lock()
+	defer n.power.mu.Unlock()
+
+	if !n.power.suspended {
+		return errNotSuspended
+	}
+
+	if n.PeerHost != nil {
+		if err := n.setSuspendFilters(false); err != nil {
+			return err
+		}
+		if n.power.rebootstrap {
+			if err := n.Bootstrap(DefaultBootstrapConfig); err != nil {
+				return err
+			}
+		}
+	}
+
+	n.power.suspended = false
+	n.applyQuiet()
+
+	n.Events.Emit(events.NodeSuspended, map[string]string{
+		"suspended": "false",
+	})
+	return nil
+}
+
+// Suspended returns whether the node is suspended.
+func (n *IpfsNode) Suspended() bool {
+	n.power.mu.Lock()
+	defer n.power.mu.Unlock()
+	return n.power.suspended
+}
+
+// setSuspendFilters adds or removes the filters blocking all the addresses.
+func (n *IpfsNode) setSuspendFilters(block bool) error {
+	swrm, ok := n.PeerHost.Network().(*swarm.Swarm)
+	if !ok {
+		return errors.New("peerhost network was not swarm")
+	}
+
+	for _, m := range suspendMasks {
+		ipnet, err := mamask.NewMask(m)
+		if err != nil {
+			return err
+		}
+		if block {
+			swrm.Filters.AddDialFilter(ipnet)
+		} else {
+			swrm.Filters.Remove(ipnet)
+		}
+	}
+	return nil
+}
+
+// persist saves the state the node otherwise saves in the background. It
+// returns the first error, after trying to save everything.
+func (n *IpfsNode) persist() error {
+	ctx, cancel := context.WithTimeout(n.Context(), suspendSyncTimeout)
+	defer cancel()
+
+	var first error
+	keep := func(err error) {
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+
+	if n.PeerstorePersister != nil {
+		_, err := n.PeerstorePersister.Save()
+		keep(err)
+	}
+	if n.Growth != nil {
+		keep(n.Growth.Flush())
+	}
+	if n.FilesRoot != nil {
+		keep(n.FilesRoot.Sync(ctx))
+	}
+	return first
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	events "github.com/ipfs/go-ipfs/events"
+	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
+	repo "github.com/ipfs/go-ipfs/repo"
+)
+
+func TestSuspendResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n := &IpfsNode{
+		Repo:       &repo.Mock{},
+		Events:     events.NewBus(),
+		Reprovider: rp.NewReprovider(ctx, nil, nil),
+	}
+	sub := n.Events.Subscribe(2, events.NodeSuspended)
+	defer sub.Cancel()
+
+	if err := n.Resume(); err != errNotSuspended {
+		t.Fatalf("expected resuming a running node to fail, got %v", err)
+	}
+
+	if err := n.Suspend(); err != nil {
+		t.Fatal(err)
+	}
+	if !n.Suspended() || !n.Reprovider.Paused() {
+		t.Fatal("the node wasn't suspended")
+	}
+	if err := n.Suspend(); err != errSuspended {
+		t.Fatalf("expected suspending twice to fail, got %v", err)
+	}
+
+	// leaving power save keeps a suspended node quiet
+	if err := n.SetPowerSave(PowerSaveOn); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.SetPowerSave(PowerSaveOff); err != nil {
+		t.Fatal(err)
+	}
+	if !n.Reprovider.Paused() {
+		t.Fatal("leaving power save resumed reproviding")
+	}
+
+	if err := n.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if n.Suspended() || n.Reprovider.Paused() {
+		t.Fatal("the node wasn't resumed")
+	}
+
+	for _, want := range []string{"true", "false"} {
+		if ev := <-sub.Out(); ev.Data["suspended"] != want {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package coremock
+
+import (
+	"context"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+
+	mocknet "gx/ipfs/QmUEAR2pS7fP1GPseS3i8MWFyENs7oDp4CZrgn8FCjbsBu/go-libp2p/p2p/net/mock"
+)
+
+// Network is a virtual network of ipfs nodes kept in memory, for testing
+// applications built on the CoreAPI. The nodes have their own in-memory
+// repo and identity, run the DHT and pubsub, and talk to each other through
+// a mocknet, without touching the disk or the real network.
+type Network struct {
+	ctx   context.Context
+	mn    mocknet.Mocknet
+	nodes []*core.IpfsNode
+}
+
+// NewNetwork returns an empty network. The nodes are stopped when ctx is
+// canceled or the network is closed.
+func NewNetwork(ctx context.Context) *Network {
+	return &Network{
+		ctx: ctx,
+		mn:  mocknet.New(ctx),
+	}
+}
+
+// NewConnectedNetwork returns a network of count nodes all connected to each
+// other, and their CoreAPIs.
+func NewConnectedNetwork(ctx context.Context, count int) (*Network, []coreiface.CoreAPI, error) {
+	n := NewNetwork(ctx)
+
+	apis := make([]coreiface.CoreAPI, count)
+	for i := range apis {
+		_, api, err := n.AddNode()
+		if err != nil {
+			n.Close()
+			return nil, nil, err
+		}
+		apis[i] = api
+	}
+
+	if err := n.ConnectAll(); err != nil {
+		n.Close()
+		return nil, nil, err
+	}
+	return n, apis, nil
+}
+
+// Mocknet returns the mocknet of the network, e.g. to set the latency of
+// the links or to unlink peers.
+func (n *Network) Mocknet() mocknet.Mocknet {
+	return n.mn
+}
+
+// Nodes returns the nodes of the network, in the order they were added.
+func (n *Network) Nodes() []*core.IpfsNode {
+	return n.nodes
+}
+
+// AddNode adds a node to the network. It isn't linked to the other nodes
+// until ConnectAll is called.
+func (n *Network) AddNode() (*core.IpfsNode, coreiface.CoreAPI, error) {
+	nd, err := core.NewNode(n.ctx, &core.BuildCfg{
+		Online: true,
+		Host:   MockHostOption(n.mn),
+		ExtraOpts: map[string]bool{
+			"pubsub": true,
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n.nodes = append(n.nodes, nd)
+	return nd, coreapi.NewCoreAPI(nd), nil
+}
+
+// ConnectAll links all the nodes of the network and connects each of them
+// to all the others.
+func (n *Network) ConnectAll() error {
+	if err := n.mn.LinkAll(); err != nil {
+		return err
+	}
+	return n.mn.ConnectAllButSelf()
+}
+
+// Close stops all the nodes of the network.
+func (n *Network) Close() error {
+	var firstErr error
+	for _, nd := range n.nodes {
+		if err := nd.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,40 @@
+package coremock
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestNetworkAddCat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n, apis, err := NewConnectedNetwork(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	if len(n.Nodes()) != 2 {
+		t.Fatalf("expected 2 nodes, got %d", len(n.Nodes()))
+	}
+
+	p, err := apis[0].Unixfs().Add(ctx, strings.NewReader("hello mocknet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := apis[1].Unixfs().Cat(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello mocknet" {
+		t.Fatalf("unexpected data: %q", data)
+	}
+}
@@ -0,0 +1,387 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+	common "github.com/ipfs/go-ipfs/repo/common"
+	cfg "github.com/ipfs/go-ipfs/repo/config"
+
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dsync "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
+)
+
+// NodeBuilder builds IpfsNodes for applications embedding ipfs. By default
+// it builds an offline node backed by an in-memory repo with a new identity:
+//
+//	n, err := core.NewNodeBuilder(
+//		core.WithOnline(true),
+//		core.WithProfile("randomports"),
+//		core.WithoutSubsystems(core.SubsystemMDNS),
+//	).Build(ctx)
+//
+// The node is stopped by closing it.
+type NodeBuilder struct {
+	cfg        BuildCfg
+	transforms []cfg.Transformer
+	err        error
+}
+
+// NodeOption configures the node built by a NodeBuilder.
+type NodeOption func(*NodeBuilder) error
+
+// NewNodeBuilder returns a NodeBuilder configured with the given options.
+func NewNodeBuilder(opts ...NodeOption) *NodeBuilder {
+	return new(NodeBuilder).With(opts...)
+}
+
+// With applies more options to the builder. The first option to fail makes
+// Build return its error.
+func (b *NodeBuilder) With(opts ...NodeOption) *NodeBuilder {
+	for _, opt := range opts {
+		if b.err != nil {
+			break
+		}
+		b.err = opt(b)
+	}
+	return b
+}
+
+// Build constructs a node. A builder can build several nodes; each gets its
+// own in-memory repo unless a repo or a datastore was given.
+func (b *NodeBuilder) Build(ctx context.Context) (*IpfsNode, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	bcfg := b.cfg
+	if err := bcfg.fillDefaults(); err != nil {
+		return nil, err
+	}
+
+	if len(b.transforms) > 0 {
+		r, err := newOverlayConfigRepo(bcfg.Repo, b.transforms)
+		if err != nil {
+			return nil, err
+		}
+		bcfg.Repo = r
+	}
+
+	return NewNode(ctx, &bcfg)
+}
+
+// WithOnline sets whether the node connects to the network.
+func WithOnline(online bool) NodeOption {
+	return func(b *NodeBuilder) error {
+		b.cfg.Online = online
+		return nil
+	}
+}
+
+// WithPermanent sets whether the node runs the expensive processes which
+// pay off for long running nodes, like the blockstore bloom filter.
+func WithPermanent(permanent bool) NodeOption {
+	return func(b *NodeBuilder) error {
+		b.cfg.Permanent = permanent
+		return nil
+	}
+}
+
+// WithRepo makes the node use the given repo, e.g. one opened with
+// fsrepo.Open. The caller keeps the ownership of the repo and closes it
+// after the node.
+func WithRepo(r repo.Repo) NodeOption {
+	return func(b *NodeBuilder) error {
+		if r == nil {
+			return errors.New("WithRepo: nil repo")
+		}
+		b.cfg.Repo = r
+		b.cfg.NilRepo = false
+		return nil
+	}
+}
+
+// WithInMemoryRepo makes the node use a repo kept in memory, with a new
+// identity. This is the default.
+func WithInMemoryRepo() NodeOption {
+	return func(b *NodeBuilder) error {
+		b.cfg.Repo = nil
+		b.cfg.NilRepo = false
+		return nil
+	}
+}
+
+// WithDatastore makes the node keep its data in the given datastore. The
+// config and identity of the node are kept in memory, as with
+// WithInMemoryRepo.
+func WithDatastore(d ds.Datastore) NodeOption {
+	return func(b *NodeBuilder) error {
+		if d == nil {
+			return errors.New("WithDatastore: nil datastore")
+		}
+		r, err := defaultRepo(dsync.MutexWrap(d))
+		if err != nil {
+			return err
+		}
+		b.cfg.Repo = r
+		b.cfg.NilRepo = false
+		return nil
+	}
+}
+
+// WithHost makes the node use the given constructor for its libp2p host.
+func WithHost(host HostOption) NodeOption {
+	return func(b *NodeBuilder) error {
+		b.cfg.Host = host
+		return nil
+	}
+}
+
+// WithRouting makes the node use the given constructor for its content
+// routing, e.g. NilRouterOption to disable it.
+func WithRouting(routing RoutingOption) NodeOption {
+	return func(b *NodeBuilder) error {
+		b.cfg.Routing = routing
+		return nil
+	}
+}
+
+// WithExperiments enables experimental features of the node, by the names
+// the daemon uses: "pubsub", "ipnsps" and "mplex".
+func WithExperiments(names ...string) NodeOption {
+	return func(b *NodeBuilder) error {
+		extra := make(map[string]bool, len(b.cfg.ExtraOpts)+len(names))
+		for k, v := range b.cfg.ExtraOpts {
+			extra[k] = v
+		}
+		for _, name := range names {
+			switch name {
+			case "pubsub", "ipnsps", "mplex":
+				extra[name] = true
+			default:
+				return fmt.Errorf("WithExperiments: unknown experiment %q", name)
+			}
+		}
+		b.cfg.ExtraOpts = extra
+		return nil
+	}
+}
+
+// WithConfig changes the config the node is built with. The changes are
+// made on a copy of the config of the repo and never saved to it.
+func WithConfig(transform cfg.Transformer) NodeOption {
+	return func(b *NodeBuilder) error {
+		b.transforms = append(b.transforms, transform)
+		return nil
+	}
+}
+
+// WithProfile applies config profiles, as 'ipfs init --profile' does, to
+// the config the node is built with. See WithConfig.
+func WithProfile(names ...string) NodeOption {
+	return func(b *NodeBuilder) error {
+		for _, name := range names {
+			p, err := cfg.GetProfile(name)
+			if err != nil {
+				return err
+			}
+			b.transforms = append(b.transforms, p.Transform)
+		}
+		return nil
+	}
+}
+
+// Subsystem is a part of the node which can be disabled with
+// WithoutSubsystems.
+type Subsystem string
+
+const (
+	// SubsystemMDNS discovers peers on the local network.
+	SubsystemMDNS Subsystem = "mdns"
+	// SubsystemNATPortMap opens ports on the router with UPnP or NAT-PMP.
+	SubsystemNATPortMap Subsystem = "natportmap"
+	// SubsystemReprovider announces the content of the node periodically.
+	SubsystemReprovider Subsystem = "reprovider"
+	// SubsystemBandwidthMetrics records the bandwidth used by the node.
+	SubsystemBandwidthMetrics Subsystem = "bandwidthmetrics"
+	// SubsystemBootstrap connects to the bootstrap peers. The builder
+	// doesn't bootstrap the node, disabling it empties the bootstrap list
+	// of the config.
+	SubsystemBootstrap Subsystem = "bootstrap"
+)
+
+var subsystemTransforms = map[Subsystem]cfg.Transformer{
+	SubsystemMDNS: func(c *cfg.Config) error {
+		c.Discovery.MDNS.Enabled = false
+		return nil
+	},
+	SubsystemNATPortMap: func(c *cfg.Config) error {
+		c.Swarm.DisableNatPortMap = true
+		return nil
+	},
+	SubsystemReprovider: func(c *cfg.Config) error {
+		c.Reprovider.Interval = "0"
+		return nil
+	},
+	SubsystemBandwidthMetrics: func(c *cfg.Config) error {
+		c.Swarm.DisableBandwidthMetrics = true
+		return nil
+	},
+	SubsystemBootstrap: func(c *cfg.Config) error {
+		c.Bootstrap = []string{}
+		return nil
+	},
+}
+
+// WithoutSubsystems disables parts of the node through its config. See
+// WithConfig.
+func WithoutSubsystems(subsystems ...Subsystem) NodeOption {
+	return func(b *NodeBuilder) error {
+		for _, s := range subsystems {
+			transform, ok := subsystemTransforms[s]
+			if !ok {
+				return fmt.Errorf("WithoutSubsystems: unknown subsystem %q", s)
+			}
+			b.transforms = append(b.transforms, transform)
+		}
+		return nil
+	}
+}
+
+// overlayConfigRepo applies config transforms to the config of a repo,
+// without saving them. The transforms run once, so that profiles like
+// randomports pick their ports only once.
+type overlayConfigRepo struct {
+	repo.Repo
+	transforms []cfg.Transformer
+	// base is the config of the repo, and conf base with the transforms
+	// applied
+	base, conf *cfg.Config
+}
+
+func newOverlayConfigRepo(r repo.Repo, transforms []cfg.Transformer) (*overlayConfigRepo, error) {
+	o := &overlayConfigRepo{Repo: r, transforms: transforms}
+	c, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+	if err := o.apply(c); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (r *overlayConfigRepo) apply(base *cfg.Config) error {
+	base, err := copyConfig(base)
+	if err != nil {
+		return err
+	}
+	c, err := copyConfig(base)
+	if err != nil {
+		return err
+	}
+	for _, transform := range r.transforms {
+		if err := transform(c); err != nil {
+			return err
+		}
+	}
+	r.base, r.conf = base, c
+	return nil
+}
+
+func (r *overlayConfigRepo) Config() (*cfg.Config, error) {
+	return copyConfig(r.conf)
+}
+
+// SetConfig saves the config to the repo and applies the transforms to it
+// again. The values c got from the transforms are saved as they were in the
+// repo, so that only the changes made to c are saved.
+func (r *overlayConfigRepo) SetConfig(c *cfg.Config) error {
+	m, err := cfg.ToMap(c)
+	if err != nil {
+		return err
+	}
+	overlaid, err := cfg.ToMap(r.conf)
+	if err != nil {
+		return err
+	}
+	base, err := cfg.ToMap(r.base)
+	if err != nil {
+		return err
+	}
+	stripOverlay(m, overlaid, base)
+
+	c, err = cfg.FromMap(m)
+	if err != nil {
+		return err
+	}
+	if err := r.Repo.SetConfig(c); err != nil {
+		return err
+	}
+	return r.apply(c)
+}
+
+// GetConfigKey returns the value of key in the config with the transforms
+// applied, like Config.
+func (r *overlayConfigRepo) GetConfigKey(key string) (interface{}, error) {
+	m, err := cfg.ToMap(r.conf)
+	if err != nil {
+		return nil, err
+	}
+	return common.MapGetKV(m, key)
+}
+
+// SetConfigKey saves the value of key to the repo and applies the
+// transforms to its config again.
+func (r *overlayConfigRepo) SetConfigKey(key string, value interface{}) error {
+	if err := r.Repo.SetConfigKey(key, value); err != nil {
+		return err
+	}
+	c, err := r.Repo.Config()
+	if err != nil {
+		return err
+	}
+	return r.apply(c)
+}
+
+// stripOverlay sets back the values of c which are the values the transforms
+// gave in overlaid to the values they have in base, the config map they were
+// applied to.
+func stripOverlay(c, overlaid, base map[string]interface{}) {
+	for k, ov := range overlaid {
+		cv, ok := c[k]
+		bv, inBase := base[k]
+		if !ok || reflect.DeepEqual(ov, bv) {
+			continue
+		}
+
+		cm, cok := cv.(map[string]interface{})
+		om, ook := ov.(map[string]interface{})
+		bm, bok := bv.(map[string]interface{})
+		if cok && ook && bok {
+			stripOverlay(cm, om, bm)
+			continue
+		}
+
+		if !reflect.DeepEqual(cv, ov) {
+			// changed since, it's saved
+			continue
+		}
+		if inBase {
+			c[k] = bv
+		} else {
+			delete(c, k)
+		}
+	}
+}
+
+func copyConfig(c *cfg.Config) (*cfg.Config, error) {
+	m, err := cfg.ToMap(c)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.FromMap(m)
+}
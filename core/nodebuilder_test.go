@@ -0,0 +1,163 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	repo "github.com/ipfs/go-ipfs/repo"
+	cfg "github.com/ipfs/go-ipfs/repo/config"
+
+	datastore "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dsq "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/query"
+)
+
+func TestNodeBuilderDefaults(t *testing.T) {
+	ctx := context.Background()
+
+	n, err := NewNodeBuilder().Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	if n.OnlineMode() {
+		t.Fatal("nodes should be offline by default")
+	}
+
+	other, err := NewNodeBuilder().Build(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	if n.Identity == other.Identity {
+		t.Fatal("nodes should get their own identity")
+	}
+}
+
+func TestNodeBuilderConfig(t *testing.T) {
+	n, err := NewNodeBuilder(
+		WithProfile("randomports"),
+		WithoutSubsystems(SubsystemMDNS, SubsystemReprovider, SubsystemBootstrap),
+		WithConfig(func(c *cfg.Config) error {
+			c.Gateway.Writable = true
+			return nil
+		}),
+	).Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	c1, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1.Discovery.MDNS.Enabled || c1.Reprovider.Interval != "0" || len(c1.Bootstrap) != 0 {
+		t.Fatal("subsystems weren't disabled")
+	}
+	if !c1.Gateway.Writable {
+		t.Fatal("config transform wasn't applied")
+	}
+
+	c2, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1.Addresses.Swarm[0] != c2.Addresses.Swarm[0] {
+		t.Fatal("the config should be transformed only once")
+	}
+
+	c2.Gateway.Writable = false
+	c3, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c3.Gateway.Writable {
+		t.Fatal("changing a returned config shouldn't change the repo config")
+	}
+}
+
+func TestOverlayConfigRepoSetConfig(t *testing.T) {
+	r := &repo.Mock{C: cfg.Config{Bootstrap: []string{"/ip4/1.2.3.4/tcp/4001"}}}
+	o, err := newOverlayConfigRepo(r, []cfg.Transformer{
+		func(c *cfg.Config) error {
+			c.Bootstrap = nil
+			c.Gateway.Writable = true
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := o.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Gateway.RootRedirect = "/ipns/example.com"
+	if err := o.SetConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.C.Bootstrap) != 1 || r.C.Gateway.Writable {
+		t.Fatalf("the transforms shouldn't be saved, got %+v", r.C)
+	}
+	if r.C.Gateway.RootRedirect != "/ipns/example.com" {
+		t.Fatal("the change to the config wasn't saved")
+	}
+
+	c, err = o.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Bootstrap) != 0 || !c.Gateway.Writable || c.Gateway.RootRedirect != "/ipns/example.com" {
+		t.Fatalf("the transforms should apply to the saved config, got %+v", c)
+	}
+
+	v, err := o.GetConfigKey("Gateway.Writable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != true {
+		t.Fatalf("GetConfigKey should return the transformed value, got %v", v)
+	}
+}
+
+func TestNodeBuilderDatastore(t *testing.T) {
+	d := datastore.NewMapDatastore()
+
+	n, err := NewNodeBuilder(WithDatastore(d)).Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	res, err := d.Query(dsq.Query{KeysOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 {
+		t.Fatal("the node should keep its data in the given datastore")
+	}
+}
+
+func TestNodeBuilderErrors(t *testing.T) {
+	bad := []NodeOption{
+		WithProfile("nope"),
+		WithoutSubsystems(Subsystem("nope")),
+		WithExperiments("nope"),
+		WithRepo(nil),
+		WithDatastore(nil),
+	}
+
+	for i, opt := range bad {
+		if _, err := NewNodeBuilder(opt).Build(context.Background()); err == nil {
+			t.Errorf("option %d should have failed", i)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	resolver "github.com/ipfs/go-ipfs/path/resolver"
+
+	offline "gx/ipfs/QmPf114DXfa6TqGKYhBGR7EtXRho4rCJgwyA1xkuMY5vwF/go-ipfs-exchange-offline"
+)
+
+// OfflineView returns a node which shares the repo and the local services of
+// n, but whose block service and DAG only read blocks from the local
+// blockstore and which has no network services. It lets commands run with
+// --offline on a daemon which holds the repo.
+//
+// The view shares its lifetime with n, closing it closes n. The pinner and
+// the MFS root are shared with n as well and keep using its DAG.
+func (n *IpfsNode) OfflineView() *IpfsNode {
+	if !n.OnlineMode() {
+		return n
+	}
+
+	v := &IpfsNode{
+		Identity:        n.Identity,
+		Repo:            n.Repo,
+		Pinning:         n.Pinning,
+		Mounts:          n.Mounts,
+		PrivateKey:      n.PrivateKey,
+		PNetFingerprint: n.PNetFingerprint,
+		Peerstore:       n.Peerstore,
+		Blockstore:      n.Blockstore,
+		Filestore:       n.Filestore,
+		BaseBlocks:      n.BaseBlocks,
+		GCLocker:        n.GCLocker,
+		Reporter:        n.Reporter,
+		FilesRoot:       n.FilesRoot,
+		Events:          n.Events,
+		Denylist:        n.Denylist,
+
+		Exchange: offline.Exchange(n.Blockstore),
+
+		proc:         n.proc,
+		ctx:          n.ctx,
+		mode:         offlineMode,
+		localModeSet: n.localModeSet,
+	}
+	v.Blocks = &denylistBlockService{
+		BlockService: bserv.New(v.Blockstore, v.Exchange),
+		denylist:     v.Denylist,
+	}
+	v.DAG = dag.NewDAGService(v.Blocks)
+	v.Resolver = resolver.NewBasicResolver(v.DAG)
+	return v
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	peerstore "github.com/ipfs/go-ipfs/peerstore"
+	cfg "github.com/ipfs/go-ipfs/repo/config"
+
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+)
+
+// restoredPeersDialed is how many of the restored peers are dialed once the
+// node is online, the most recently saved first.
+const restoredPeersDialed = 32
+
+// restoredPeerDialTimeout bounds each dial of a restored peer.
+const restoredPeerDialTimeout = 30 * time.Second
+
+// setupPeerstorePersistence restores the peers saved by the previous runs of
+// the node, and saves them periodically until the node stops. It returns the
+// restored peers, for dialRestoredPeers to dial once the host is up.
+func (n *IpfsNode) setupPeerstorePersistence(conf cfg.Peerstore) ([]pstore.PeerInfo, error) {
+	p, err := newPeerstorePersister(n, conf)
+	if err != nil {
+		return nil, err
+	}
+	if conf.DisablePersistence {
+		return nil, nil
+	}
+
+	pis, err := p.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore the peerstore: %s", err)
+	}
+	log.Debugf("restored %d peers from the datastore", len(pis))
+
+	n.PeerstorePersister = p
+	n.Process().Go(p.Run)
+	return pis, nil
+}
+
+// dialRestoredPeers connects, in the background, to the first
+// restoredPeersDialed peers of pis, so that the node is back in touch with
+// the network without waiting for the routing system to find peers.
+func (n *IpfsNode) dialRestoredPeers(pis []pstore.PeerInfo) {
+	if len(pis) > restoredPeersDialed {
+		pis = pis[:restoredPeersDialed]
+	}
+	for _, pi := range pis {
+		go func(pi pstore.PeerInfo) {
+			ctx, cancel := context.WithTimeout(n.Context(), restoredPeerDialTimeout)
+			defer cancel()
+			if err := n.PeerHost.Connect(ctx, pi); err != nil {
+				log.Debugf("failed to dial the restored peer %s: %s", pi.ID, err)
+				return
+			}
+			log.Debugf("connected to the restored peer %s", pi.ID)
+		}(pi)
+	}
+}
+
+func newPeerstorePersister(n *IpfsNode, conf cfg.Peerstore) (*peerstore.Persister, error) {
+	p := peerstore.NewPersister(n.Peerstore, n.Repo.Datastore(), n.Identity)
+
+	if conf.SaveInterval != "" {
+		d, err := time.ParseDuration(conf.SaveInterval)
+		if err != nil {
+			return nil, fmt.Errorf("failure to parse config setting Swarm.Peerstore.SaveInterval: %s", err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("config setting Swarm.Peerstore.SaveInterval must be positive: %s", d)
+		}
+		p.Interval = d
+	}
+
+	if conf.AddrTTL != "" {
+		d, err := time.ParseDuration(conf.AddrTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failure to parse config setting Swarm.Peerstore.AddrTTL: %s", err)
+		}
+		p.TTL = d
+	}
+	return p, nil
+}
+
+// PeerstoreGC removes the saved peers which expired, and returns how many it
+// removed. It works whether or not the node saves its peerstore.
+func (n *IpfsNode) PeerstoreGC() (int, error) {
+	if n.PeerstorePersister != nil {
+		return n.PeerstorePersister.GC()
+	}
+
+	conf, err := n.Repo.Config()
+	if err != nil {
+		return 0, err
+	}
+	p, err := newPeerstorePersister(n, conf.Swarm.Peerstore)
+	if err != nil {
+		return 0, err
+	}
+	return p.GC()
+}
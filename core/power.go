@@ -0,0 +1,268 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	events "github.com/ipfs/go-ipfs/events"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	p2phost "gx/ipfs/QmQQGtcp6nVUrQjNsnU53YWV1q8fK1Kd9S7FEkYbRZzxry/go-libp2p-host"
+	goprocess "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
+	inet "gx/ipfs/QmXdgNhVEgjLxjUoMs5ViQL7pboAt3Y7V7eGHRiE4qrmTE/go-libp2p-net"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+)
+
+const (
+	defaultPowerCheckInterval = time.Minute
+	defaultPowerSaveLowWater  = 10
+	defaultPowerSaveHighWater = 20
+)
+
+// The power save modes: always on, always off, or following the power
+// source when PowerSave.Auto is set.
+const (
+	PowerSaveOn   = "on"
+	PowerSaveOff  = "off"
+	PowerSaveAuto = "auto"
+)
+
+var errPowerSourceUnknown = errors.New("the power source can't be checked on this platform")
+
+// PowerSaveStatus describes the power save state of the node.
+type PowerSaveStatus struct {
+	// Enabled is whether the node is in power save.
+	Enabled bool
+	// Mode is the mode set with SetPowerSave, "auto" by default.
+	Mode string
+	// OnBattery is whether the machine ran on battery when last checked, in
+	// auto mode.
+	OnBattery bool
+}
+
+// powerSaver holds the power save state of a node.
+type powerSaver struct {
+	mu        sync.Mutex
+	mode      string
+	onBattery bool
+	enabled   bool
+	// suspended is set between Suspend and Resume
+	suspended bool
+	// rebootstrap is whether Resume restarts the bootstrapper
+	rebootstrap bool
+}
+
+// PowerSave returns the power save state of the node.
+func (n *IpfsNode) PowerSave() PowerSaveStatus {
+	n.power.mu.Lock()
+	defer n.power.mu.Unlock()
+	return n.powerSaveStatus()
+}
+
+// powerSaveStatus must be called with the lock held.
+func (n *IpfsNode) powerSaveStatus() PowerSaveStatus {
+	mode := n.power.mode
+	if mode == "" {
+		mode = PowerSaveAuto
+	}
+	return PowerSaveStatus{
+		Enabled:   n.power.enabled,
+		Mode:      mode,
+		OnBattery: n.power.onBattery,
+	}
+}
+
+// SetPowerSave switches power save on or off, or back to auto mode, where
+// the node is in power save while the machine runs on battery if
+// PowerSave.Auto is set in the config. In power save, the node pauses
+// reproviding, stops serving the DHT to other peers and keeps fewer
+// connections.
+func (n *IpfsNode) SetPowerSave(mode string) error {
+	switch mode {
+	case PowerSaveOn, PowerSaveOff, PowerSaveAuto:
+	default:
+		return fmt.Errorf("unknown power save mode %q, expected on, off or auto", mode)
+	}
+
+	n.power.mu.Lock()
+	defer n.power.mu.Unlock()
+	n.power.mode = mode
+	return n.applyPowerSave()
+}
+
+// setOnBattery records the power source found in auto mode.
+func (n *IpfsNode) setOnBattery(onBattery bool) error {
+	n.power.mu.Lock()
+	defer n.power.mu.Unlock()
+	n.power.onBattery = onBattery
+	return n.applyPowerSave()
+}
+
+// applyPowerSave enters or leaves power save as the state requires. It must
+// be called with the lock held.
+func (n *IpfsNode) applyPowerSave() error {
+	p := &n.power
+	enabled := p.mode == PowerSaveOn || (p.mode != PowerSaveOff && p.onBattery)
+	if enabled == p.enabled {
+		return nil
+	}
+
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+
+	if n.connMgr != nil && n.PeerHost != nil {
+		conf := cfg.Swarm.ConnMgr
+		if enabled {
+			conf = powerSaveConnMgr(cfg)
+		}
+		cm, err := constructConnMgr(conf)
+		if err != nil {
+			return err
+		}
+		n.connMgr.Replace(cm, n.PeerHost.Network())
+	}
+	p.enabled = enabled
+	n.applyQuiet()
+
+	log.Infof("power save enabled: %t", enabled)
+	n.Events.Emit(events.PowerSaveChanged, map[string]string{
+		"enabled": strconv.FormatBool(enabled),
+		"mode":    n.powerSaveStatus().Mode,
+	})
+	return nil
+}
+
+// applyQuiet pauses reproviding and serving the DHT while the node is in
+// power save or suspended, and resumes them otherwise. It must be called with
+// the lock held.
+func (n *IpfsNode) applyQuiet() {
+	quiet := n.power.enabled || n.power.suspended
+	if n.Reprovider != nil {
+		n.Reprovider.SetPaused(quiet)
+	}
+	if n.dhtHost != nil {
+		n.dhtHost.setServing(!quiet)
+	}
+}
+
+// powerSaveConnMgr returns the config of the connection manager in power
+// save.
+func powerSaveConnMgr(cfg *config.Config) config.ConnMgr {
+	conf := config.ConnMgr{
+		Type:        "basic",
+		LowWater:    cfg.PowerSave.LowWater,
+		HighWater:   cfg.PowerSave.HighWater,
+		GracePeriod: cfg.Swarm.ConnMgr.GracePeriod,
+	}
+	if conf.LowWater <= 0 {
+		conf.LowWater = defaultPowerSaveLowWater
+	}
+	if conf.HighWater <= 0 {
+		conf.HighWater = defaultPowerSaveHighWater
+	}
+	if conf.GracePeriod == "" {
+		conf.GracePeriod = config.DefaultConnMgrGracePeriod.String()
+	}
+	return conf
+}
+
+// setupPowerSave makes the node follow the power source, when
+// PowerSave.Auto is set.
+func (n *IpfsNode) setupPowerSave(cfg *config.Config) error {
+	if !cfg.PowerSave.Auto {
+		return nil
+	}
+
+	interval := defaultPowerCheckInterval
+	if cfg.PowerSave.CheckInterval != "" {
+		d, err := time.ParseDuration(cfg.PowerSave.CheckInterval)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting PowerSave.CheckInterval: %s", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("config setting PowerSave.CheckInterval must be positive: %s", d)
+		}
+		interval = d
+	}
+
+	n.Process().Go(func(proc goprocess.Process) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			onBattery, err := onBattery()
+			if err != nil {
+				log.Warning("power save won't follow the power source: ", err)
+				return
+			}
+			if err := n.setOnBattery(onBattery); err != nil {
+				log.Error("failed to apply power save: ", err)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-proc.Closing():
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// dhtHost is the host given to the DHT. It keeps the stream handlers the
+// DHT sets, so that serving the DHT to other peers can be stopped and
+// resumed while the node runs.
+type dhtHost struct {
+	p2phost.Host
+
+	mu       sync.Mutex
+	handlers map[protocol.ID]inet.StreamHandler
+	stopped  bool
+}
+
+func newDHTHost(h p2phost.Host) *dhtHost {
+	return &dhtHost{
+		Host:     h,
+		handlers: make(map[protocol.ID]inet.StreamHandler),
+	}
+}
+
+func (h *dhtHost) SetStreamHandler(pid protocol.ID, handler inet.StreamHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handlers[pid] = handler
+	if !h.stopped {
+		h.Host.SetStreamHandler(pid, handler)
+	}
+}
+
+func (h *dhtHost) RemoveStreamHandler(pid protocol.ID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.handlers, pid)
+	h.Host.RemoveStreamHandler(pid)
+}
+
+// setServing removes the handlers of the DHT from the host, which makes the
+// node a DHT client, or sets them back.
+func (h *dhtHost) setServing(serving bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped == !serving {
+		return
+	}
+	h.stopped = !serving
+
+	for pid, handler := range h.handlers {
+		if serving {
+			h.Host.SetStreamHandler(pid, handler)
+		} else {
+			h.Host.RemoveStreamHandler(pid)
+		}
+	}
+}
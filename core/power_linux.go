@@ -0,0 +1,39 @@
+package core
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// onBattery returns whether the machine runs on battery, from the power
+// supplies listed in sysfs. Machines without a battery never do.
+func onBattery() (bool, error) {
+	supplies, err := filepath.Glob("/sys/class/power_supply/*")
+	if err != nil {
+		return false, err
+	}
+
+	discharging := false
+	for _, s := range supplies {
+		switch readPowerSupply(s, "type") {
+		case "Mains", "USB":
+			if readPowerSupply(s, "online") == "1" {
+				return false, nil
+			}
+		case "Battery":
+			if readPowerSupply(s, "status") == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return discharging, nil
+}
+
+func readPowerSupply(dir, attr string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, attr))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
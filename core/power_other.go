@@ -0,0 +1,8 @@
+// +build !linux
+
+package core
+
+// onBattery can't tell the power source on this platform.
+func onBattery() (bool, error) {
+	return false, errPowerSourceUnknown
+}
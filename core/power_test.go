@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	events "github.com/ipfs/go-ipfs/events"
+	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	p2phost "gx/ipfs/QmQQGtcp6nVUrQjNsnU53YWV1q8fK1Kd9S7FEkYbRZzxry/go-libp2p-host"
+	inet "gx/ipfs/QmXdgNhVEgjLxjUoMs5ViQL7pboAt3Y7V7eGHRiE4qrmTE/go-libp2p-net"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+)
+
+type handlersHost struct {
+	p2phost.Host
+	handlers map[protocol.ID]inet.StreamHandler
+}
+
+func (h *handlersHost) SetStreamHandler(pid protocol.ID, handler inet.StreamHandler) {
+	h.handlers[pid] = handler
+}
+
+func (h *handlersHost) RemoveStreamHandler(pid protocol.ID) {
+	delete(h.handlers, pid)
+}
+
+func TestDHTHostServing(t *testing.T) {
+	inner := &handlersHost{handlers: make(map[protocol.ID]inet.StreamHandler)}
+	h := newDHTHost(inner)
+
+	h.SetStreamHandler("/ipfs/kad/1.0.0", func(inet.Stream) {})
+	if len(inner.handlers) != 1 {
+		t.Fatal("the handler wasn't set")
+	}
+
+	h.setServing(false)
+	if len(inner.handlers) != 0 {
+		t.Fatal("the handler wasn't removed")
+	}
+
+	// handlers set while not serving are set once serving again
+	h.SetStreamHandler("/ipfs/dht", func(inet.Stream) {})
+	if len(inner.handlers) != 0 {
+		t.Fatal("a handler was set while not serving")
+	}
+
+	h.setServing(true)
+	if len(inner.handlers) != 2 {
+		t.Fatalf("expected 2 handlers, got %d", len(inner.handlers))
+	}
+}
+
+func TestSetPowerSave(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n := &IpfsNode{
+		Repo:       &repo.Mock{},
+		Events:     events.NewBus(),
+		Reprovider: rp.NewReprovider(ctx, nil, nil),
+	}
+	sub := n.Events.Subscribe(1, events.PowerSaveChanged)
+	defer sub.Cancel()
+
+	if s := n.PowerSave(); s.Enabled || s.Mode != PowerSaveAuto {
+		t.Fatalf("unexpected initial state: %+v", s)
+	}
+
+	if err := n.SetPowerSave("sometimes"); err == nil {
+		t.Fatal("expected an unknown mode to fail")
+	}
+
+	if err := n.SetPowerSave(PowerSaveOn); err != nil {
+		t.Fatal(err)
+	}
+	if !n.PowerSave().Enabled || !n.Reprovider.Paused() {
+		t.Fatal("power save wasn't enabled")
+	}
+	if ev := <-sub.Out(); ev.Data["enabled"] != "true" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+
+	// auto mode follows the power source
+	if err := n.SetPowerSave(PowerSaveAuto); err != nil {
+		t.Fatal(err)
+	}
+	if n.PowerSave().Enabled || n.Reprovider.Paused() {
+		t.Fatal("power save wasn't disabled")
+	}
+	if err := n.setOnBattery(true); err != nil {
+		t.Fatal(err)
+	}
+	if !n.PowerSave().Enabled {
+		t.Fatal("power save wasn't enabled on battery")
+	}
+
+	if err := n.SetPowerSave(PowerSaveOff); err != nil {
+		t.Fatal(err)
+	}
+	if n.PowerSave().Enabled {
+		t.Fatal("power save wasn't disabled")
+	}
+}
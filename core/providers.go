@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	resolver "github.com/ipfs/go-ipfs/path/resolver"
+
+	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	iaddr "gx/ipfs/QmckPUj15AbTcLh6MpDEsQpfVCx34tmP2Xg1aNwLb5fiRF/go-ipfs-addr"
+)
+
+// providerDialTimeout bounds the dial of each provider hinted.
+const providerDialTimeout = 15 * time.Second
+
+// ErrProvidersOffline is returned when providers are hinted to a node which
+// isn't online.
+var ErrProvidersOffline = errors.New("providers can't be dialed while offline")
+
+// ParseProviders parses comma-separated provider hints, each a peer ID or an
+// address ending with /ipfs/<peer ID>.
+func ParseProviders(s string) ([]pstore.PeerInfo, error) {
+	var pis []pstore.PeerInfo
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		if strings.HasPrefix(p, "/") {
+			a, err := iaddr.ParseString(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid provider address %q: %s", p, err)
+			}
+			pis = append(pis, pstore.PeerInfo{ID: a.ID(), Addrs: []ma.Multiaddr{a.Transport()}})
+			continue
+		}
+		id, err := peer.IDB58Decode(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid provider peer ID %q: %s", p, err)
+		}
+		pis = append(pis, pstore.PeerInfo{ID: id})
+	}
+	return pis, nil
+}
+
+// WithProviders connects n to the peers pis, hinted to have the blocks about
+// to be fetched, and returns a context making the sessions created with it
+// want their blocks from them first, without looking for their providers
+// unless the blocks don't come. The peers n can't connect to are skipped.
+func WithProviders(ctx context.Context, n *IpfsNode, pis []pstore.PeerInfo) (context.Context, error) {
+	if len(pis) == 0 {
+		return ctx, nil
+	}
+	if !n.OnlineMode() {
+		return nil, ErrProvidersOffline
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		connected []peer.ID
+	)
+	for _, pi := range pis {
+		wg.Add(1)
+		go func(pi pstore.PeerInfo) {
+			defer wg.Done()
+
+			dctx, cancel := context.WithTimeout(ctx, providerDialTimeout)
+			defer cancel()
+			if err := n.PeerHost.Connect(dctx, pi); err != nil {
+				log.Warningf("dialing the provider hinted %s: %s", pi.ID, err)
+				return
+			}
+			mu.Lock()
+			connected = append(connected, pi.ID)
+			mu.Unlock()
+		}(pi)
+	}
+	wg.Wait()
+
+	if len(connected) == 0 {
+		log.Warning("none of the providers hinted could be dialed, looking for others")
+		return ctx, nil
+	}
+	return bitswap.WithProviders(ctx, connected), nil
+}
+
+// SessionResolver returns a resolver like the one of n, fetching the nodes
+// through a session created with ctx, as for the providers hinted to
+// WithProviders.
+func SessionResolver(ctx context.Context, n *IpfsNode) *resolver.Resolver {
+	r := *n.Resolver
+	r.DAG = dag.NewSession(ctx, n.DAG)
+	return &r
+}
@@ -0,0 +1,29 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestParseProviders(t *testing.T) {
+	const id = "QmTFauExutTsy4XP6JbMFcw2Wa9645HJt2bTqL6qYDCKfe"
+
+	pis, err := ParseProviders(id + ", /ip4/1.2.3.4/tcp/4001/ipfs/" + id + ",")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pis) != 2 {
+		t.Fatalf("expected 2 providers, got %v", pis)
+	}
+	if pis[0].ID.Pretty() != id || len(pis[0].Addrs) != 0 {
+		t.Fatalf("expected the peer ID alone, got %v", pis[0])
+	}
+	if pis[1].ID.Pretty() != id || len(pis[1].Addrs) != 1 || pis[1].Addrs[0].String() != "/ip4/1.2.3.4/tcp/4001" {
+		t.Fatalf("expected the peer ID and its address, got %v", pis[1])
+	}
+
+	for _, invalid := range []string{"notapeer", "/ip4/1.2.3.4/tcp/4001"} {
+		if _, err := ParseProviders(invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
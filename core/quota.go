@@ -0,0 +1,134 @@
+package core
+
+import (
+	"context"
+
+	apiauth "github.com/ipfs/go-ipfs/apiauth"
+	pin "github.com/ipfs/go-ipfs/pin"
+	quota "github.com/ipfs/go-ipfs/repo/quota"
+
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// quotaPinner enforces the storage quotas of the API callers on the pins made
+// for them, whichever command makes them: the contexts of Pin and Update
+// carry their quotas. PinWithMode, which takes no context, enforces the quota
+// of ctx, for the pinners returned by PinnerFor.
+type quotaPinner struct {
+	pin.Pinner
+	quotas *quota.Store
+	dag    ipld.DAGService
+
+	// ctx is the context of the request the pinner is for, err the error
+	// of the first pin PinWithMode refused
+	ctx context.Context
+	err error
+}
+
+// RequestPinner is the pinner of a request, which also enforces its storage
+// quota on PinWithMode. As PinWithMode can't fail, the pins exceeding the
+// quota are not made, and Err returns why.
+type RequestPinner interface {
+	pin.Pinner
+
+	// Err returns the error of the first pin PinWithMode refused.
+	Err() error
+}
+
+// PinnerFor returns the pinner of n for the request of ctx. It's meant for
+// the pins of that request only.
+func (n *IpfsNode) PinnerFor(ctx context.Context) RequestPinner {
+	p := &quotaPinner{Pinner: n.Pinning, quotas: n.Quotas, dag: n.DAG, ctx: ctx}
+	if qp, ok := n.Pinning.(*quotaPinner); ok {
+		p.Pinner = qp.Pinner
+	}
+	return p
+}
+
+func (p *quotaPinner) Pin(ctx context.Context, nd ipld.Node, recursive bool) error {
+	release, err := p.reserve(ctx, nd, recursive)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return p.Pinner.Pin(ctx, nd, recursive)
+}
+
+func (p *quotaPinner) Update(ctx context.Context, from, to *cid.Cid, unpin bool) error {
+	if _, ok := apiauth.QuotaFromContext(ctx); ok {
+		nd, err := p.dag.Get(ctx, to)
+		if err != nil {
+			return err
+		}
+		release, err := p.reserve(ctx, nd, true)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	return p.Pinner.Update(ctx, from, to, unpin)
+}
+
+func (p *quotaPinner) PinWithMode(c *cid.Cid, mode pin.Mode) {
+	if p.ctx == nil || (mode != pin.Recursive && mode != pin.Direct) {
+		p.Pinner.PinWithMode(c, mode)
+		return
+	}
+	if _, ok := apiauth.QuotaFromContext(p.ctx); !ok {
+		p.Pinner.PinWithMode(c, mode)
+		return
+	}
+
+	nd, err := p.dag.Get(p.ctx, c)
+	if err != nil {
+		p.fail(err)
+		return
+	}
+	release, err := p.reserve(p.ctx, nd, mode == pin.Recursive)
+	if err != nil {
+		p.fail(err)
+		return
+	}
+	defer release()
+	p.Pinner.PinWithMode(c, mode)
+}
+
+func (p *quotaPinner) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *quotaPinner) Err() error {
+	return p.err
+}
+
+// reserve records that the identity of the quota of ctx pins nd, failing if
+// it would exceed its quota. The returned function is called once
+// nd is pinned, or failed to.
+func (p *quotaPinner) reserve(ctx context.Context, nd ipld.Node, recursive bool) (func(), error) {
+	q, ok := apiauth.QuotaFromContext(ctx)
+	if !ok || p.quotas == nil {
+		return func() {}, nil
+	}
+
+	size := uint64(len(nd.RawData()))
+	if recursive {
+		s, err := nd.Size()
+		if err != nil {
+			return nil, err
+		}
+		size = s
+	}
+
+	pinned := quota.Pinned(p.Pinner)
+	if err := p.quotas.Reserve(q.Identity, q.Bytes, nd.Cid(), size, pinned); err != nil {
+		return nil, err
+	}
+	return func() {
+		if err := p.quotas.Release(q.Identity, nd.Cid(), pinned); err != nil {
+			log.Error(err)
+		}
+	}, nil
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	events "github.com/ipfs/go-ipfs/events"
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+// ConfigReload describes the outcome of IpfsNode.ReloadConfig. Fields are
+// named after their path in the config, e.g. "Swarm.ConnMgr".
+type ConfigReload struct {
+	// Applied lists the changed fields which now apply to the running node.
+	Applied []string
+
+	// Restart lists the changed fields which are only read when the node
+	// starts.
+	Restart []string
+}
+
+// reloader applies a config field to the running node.
+type reloader func(n *IpfsNode, conf *config.Config) error
+
+// reloaders lists the config fields which can be changed while the node runs.
+var reloaders = map[string]reloader{
+	// read by the gateway on every request
+	"Gateway.HTTPHeaders":   nil,
+	"Gateway.PathPrefixes":  nil,
+	"Gateway.Precompressed": nil,
+	"Gateway.Compression":   nil,
+
+	"Swarm.ConnMgr": func(n *IpfsNode, conf *config.Config) error {
+		// applied when leaving power save
+		if n.connMgr == nil || n.PeerHost == nil || n.PowerSave().Enabled {
+			return nil
+		}
+		cm, err := constructConnMgr(conf.Swarm.ConnMgr)
+		if err != nil {
+			return err
+		}
+		n.connMgr.Replace(cm, n.PeerHost.Network())
+		return nil
+	},
+
+	"Swarm.Bandwidth": func(n *IpfsNode, conf *config.Config) error {
+		if n.bwLimiter == nil {
+			return nil
+		}
+		return n.bwLimiter.SetLimits(conf.Swarm.Bandwidth)
+	},
+
+	"Datastore.HashOnRead": func(n *IpfsNode, conf *config.Config) error {
+		if n.BaseBlocks != nil {
+			n.BaseBlocks.HashOnRead(conf.Datastore.HashOnRead)
+		}
+		return nil
+	},
+
+	"Datastore.BloomFilterSize": func(n *IpfsNode, conf *config.Config) error {
+		if n.blockCache == nil {
+			return nil
+		}
+		return n.blockCache.Resize(conf.Datastore)
+	},
+
+	"Denylist.Files": func(n *IpfsNode, conf *config.Config) error {
+		_, err := n.Denylist.SetFiles(n.denylistFiles(conf))
+		return err
+	},
+}
+
+// ReloadConfig re-reads the config file of the repo and applies the changes
+// made to it since the node started, or since the last reload, to the fields
+// which support it. Changes to other fields are reported in Restart.
+func (n *IpfsNode) ReloadConfig() (*ConfigReload, error) {
+	n.reloadMu.Lock()
+	defer n.reloadMu.Unlock()
+
+	r, ok := n.Repo.(interface{ ReloadConfig() error })
+	if !ok {
+		return nil, errors.New("the repo doesn't support reloading its config")
+	}
+	if err := r.ReloadConfig(); err != nil {
+		return nil, err
+	}
+
+	conf, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	m, err := config.ToMap(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ConfigReload{}
+	for _, field := range changedConfigFields(n.appliedConfig, m) {
+		apply, ok := reloaders[field]
+		if !ok {
+			res.Restart = append(res.Restart, field)
+			continue
+		}
+		if apply != nil {
+			if err := apply(n, conf); err != nil {
+				return res, fmt.Errorf("%s: %s", field, err)
+			}
+		}
+		res.Applied = append(res.Applied, field)
+	}
+	n.appliedConfig = m
+
+	if len(res.Applied) > 0 {
+		n.Events.Emit(events.ConfigReloaded, map[string]string{
+			"fields": strings.Join(res.Applied, ","),
+		})
+	}
+	for _, field := range res.Restart {
+		log.Warningf("config field %s changed, restart the daemon to apply it", field)
+	}
+	return res, nil
+}
+
+// setAppliedConfig records the config the node runs with, to tell which
+// fields changed when the config is reloaded.
+func (n *IpfsNode) setAppliedConfig(conf *config.Config) error {
+	m, err := config.ToMap(conf)
+	if err != nil {
+		return err
+	}
+	n.appliedConfig = m
+	return nil
+}
+
+// changedConfigFields compares two configs in their map form and returns the
+// sorted list of fields which differ. Sections are compared field by field,
+// deeper levels as a whole.
+func changedConfigFields(old, cur map[string]interface{}) []string {
+	var out []string
+	for k := range unionKeys(old, cur) {
+		o, c := old[k], cur[k]
+		if reflect.DeepEqual(o, c) {
+			continue
+		}
+
+		om, ok1 := o.(map[string]interface{})
+		cm, ok2 := c.(map[string]interface{})
+		if !ok1 || !ok2 {
+			out = append(out, k)
+			continue
+		}
+		for f := range unionKeys(om, cm) {
+			if !reflect.DeepEqual(om[f], cm[f]) {
+				out = append(out, k+"."+f)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func unionKeys(a, b map[string]interface{}) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
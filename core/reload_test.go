@@ -0,0 +1,35 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangedConfigFields(t *testing.T) {
+	old := map[string]interface{}{
+		"Bootstrap": []interface{}{"a"},
+		"Gateway": map[string]interface{}{
+			"HTTPHeaders":  map[string]interface{}{"X": []interface{}{"1"}},
+			"RootRedirect": "",
+		},
+		"Swarm": map[string]interface{}{
+			"ConnMgr": map[string]interface{}{"HighWater": 900.0},
+		},
+	}
+	cur := map[string]interface{}{
+		"Bootstrap": []interface{}{"a", "b"},
+		"Gateway": map[string]interface{}{
+			"HTTPHeaders":  map[string]interface{}{"X": []interface{}{"2"}},
+			"RootRedirect": "",
+			"PathPrefixes": []interface{}{"/blog"},
+		},
+		"Swarm": map[string]interface{}{
+			"ConnMgr": map[string]interface{}{"HighWater": 900.0},
+		},
+	}
+
+	expected := []string{"Bootstrap", "Gateway.HTTPHeaders", "Gateway.PathPrefixes"}
+	if changed := changedConfigFields(old, cur); !reflect.DeepEqual(changed, expected) {
+		t.Fatalf("expected %v, got %v", expected, changed)
+	}
+}
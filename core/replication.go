@@ -0,0 +1,114 @@
+package core
+
+import (
+	"fmt"
+
+	events "github.com/ipfs/go-ipfs/events"
+	replication "github.com/ipfs/go-ipfs/replication"
+
+	goprocess "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// setupReplication starts pushing the MFS root to the replicas of the node,
+// accepting the roots of its primaries, the pushed DAGs and the pins to
+// replicate, and sending its pinned roots to the nodes it trusts, as
+// configured.
+func (n *IpfsNode) setupReplication() error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+
+	primaries, err := parsePeerIDs("Replication.Primaries", cfg.Replication.Primaries)
+	if err != nil {
+		return err
+	}
+	if len(primaries) > 0 {
+		rcv := replication.NewReceiver(n.Context(), primaries, n.DAG, n.FilesRoot, n.Blockstore)
+		n.PeerHost.SetStreamHandler(replication.ProtocolID, rcv.HandleStream)
+	}
+
+	allowPush, err := parsePeerIDs("Replication.AllowPush", cfg.Replication.AllowPush)
+	if err != nil {
+		return err
+	}
+	if len(allowPush) > 0 {
+		rcv := replication.NewDAGReceiver(n.Context(), allowPush, n.Blocks, n.DAG, n.Pinning, n.PinInfo, n.Blockstore)
+		n.PeerHost.SetStreamHandler(replication.DAGProtocolID, rcv.HandleStream)
+	}
+
+	allowPin, err := parsePeerIDs("Replication.AllowPin", cfg.Replication.AllowPin)
+	if err != nil {
+		return err
+	}
+	if len(allowPin) > 0 {
+		rcv := replication.NewPinReceiver(n.Context(), allowPin, n.DAG, n.Pinning, n.PinInfo, n.Blockstore)
+		n.PeerHost.SetStreamHandler(replication.PinProtocolID, rcv.HandleStream)
+	}
+
+	trusted, err := parsePeerIDs("Replication.Inventory", cfg.Replication.Inventory)
+	if err != nil {
+		return err
+	}
+	if len(trusted) > 0 {
+		inv := replication.NewInventoryServer(trusted, n.Pinning)
+		n.PeerHost.SetStreamHandler(replication.InventoryProtocolID, inv.HandleStream)
+	}
+
+	replicas, err := parsePeerIDs("Replication.Replicas", cfg.Replication.Replicas)
+	if err != nil {
+		return err
+	}
+	if len(replicas) == 0 {
+		return nil
+	}
+
+	n.Replication = replication.NewPusher(n.PeerHost, replicas)
+	sub := n.Events.Subscribe(0, events.FilesPublished)
+	n.Process().Go(func(proc goprocess.Process) {
+		defer sub.Cancel()
+		for {
+			select {
+			case ev, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				n.pushFilesRoot(ev.Data["cid"])
+			case <-proc.Closing():
+				return
+			}
+		}
+	})
+	n.Process().Go(n.Replication.Run)
+
+	// bring the replicas up to date with the current root
+	nd, err := n.FilesRoot.GetValue().GetNode()
+	if err != nil {
+		return err
+	}
+	n.Replication.Push(nd.Cid())
+	return nil
+}
+
+func (n *IpfsNode) pushFilesRoot(s string) {
+	c, err := cid.Decode(s)
+	if err != nil {
+		log.Error("invalid MFS root in the files.published event: ", err)
+		return
+	}
+	n.Replication.Push(c)
+}
+
+func parsePeerIDs(field string, ss []string) ([]peer.ID, error) {
+	ids := make([]peer.ID, 0, len(ss))
+	for _, s := range ss {
+		id, err := peer.IDB58Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer ID %q in %s: %s", s, field, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
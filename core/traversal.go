@@ -0,0 +1,27 @@
+package core
+
+import (
+	"context"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	cidset "github.com/ipfs/go-ipfs/thirdparty/cidset"
+)
+
+// TraversalContext returns a context making the DAG walks made with it, like
+// the ones of pinning or garbage collection, follow the Traversal section of
+// the config.
+func (n *IpfsNode) TraversalContext(ctx context.Context) (context.Context, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Traversal.DiskBackedSets {
+		f := cidset.OnDisk(n.Repo.Datastore(), cfg.Traversal.BloomFilterSize)
+		ctx = cidset.WithFactory(ctx, f)
+	}
+	if cfg.Traversal.Concurrency > 0 {
+		ctx = dag.WithConcurrency(ctx, cfg.Traversal.Concurrency)
+	}
+	return ctx, nil
+}
@@ -0,0 +1,280 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	filestore "github.com/ipfs/go-ipfs/filestore"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
+	usage "github.com/ipfs/go-ipfs/repo/usage"
+	cidset "github.com/ipfs/go-ipfs/thirdparty/cidset"
+
+	offline "gx/ipfs/QmPf114DXfa6TqGKYhBGR7EtXRho4rCJgwyA1xkuMY5vwF/go-ipfs-exchange-offline"
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	bstore "gx/ipfs/QmbaPGg81pvQiC5vTXtC9Jo8rdrWUjRaugH71WYNsgi6Ev/go-ipfs-blockstore"
+)
+
+// usageLockStripes is the number of locks of a usageBlockstore.
+const usageLockStripes = 256
+
+// usageBlockstore counts the blocks added to and removed from the
+// blockstore, and their size. Blocks which were already stored aren't
+// counted as added. The writes of a block are serialized, for two puts of it
+// at once not to both count it: each block is locked by one of the stripes of
+// locks, by its hash, for the writes of other blocks not to wait.
+type usageBlockstore struct {
+	bstore.GCBlockstore
+	usage *usage.Tracker
+	locks [usageLockStripes]sync.Mutex
+}
+
+// lock locks the stripes of cs, in order, and returns the function unlocking
+// them.
+func (bs *usageBlockstore) lock(cs ...*cid.Cid) func() {
+	var stripes []int
+	seen := make(map[int]bool, len(cs))
+	for _, c := range cs {
+		h := fnv.New32a()
+		h.Write(c.Bytes())
+		i := int(h.Sum32() % usageLockStripes)
+		if !seen[i] {
+			seen[i] = true
+			stripes = append(stripes, i)
+		}
+	}
+	sort.Ints(stripes)
+	for _, i := range stripes {
+		bs.locks[i].Lock()
+	}
+	return func() {
+		for _, i := range stripes {
+			bs.locks[i].Unlock()
+		}
+	}
+}
+
+func (bs *usageBlockstore) Put(b blocks.Block) error {
+	defer bs.lock(b.Cid())()
+	has, err := bs.GCBlockstore.Has(b.Cid())
+	if err != nil {
+		return err
+	}
+	if err := bs.GCBlockstore.Put(b); err != nil {
+		return err
+	}
+	if !has {
+		bs.usage.Added(len(b.RawData()))
+	}
+	return nil
+}
+
+func (bs *usageBlockstore) PutMany(blks []blocks.Block) error {
+	cs := make([]*cid.Cid, len(blks))
+	for i, b := range blks {
+		cs[i] = b.Cid()
+	}
+	defer bs.lock(cs...)()
+
+	var added []blocks.Block
+	for _, b := range blks {
+		has, err := bs.GCBlockstore.Has(b.Cid())
+		if err != nil {
+			return err
+		}
+		if !has {
+			added = append(added, b)
+		}
+	}
+	if err := bs.GCBlockstore.PutMany(blks); err != nil {
+		return err
+	}
+	for _, b := range added {
+		bs.usage.Added(len(b.RawData()))
+	}
+	return nil
+}
+
+func (bs *usageBlockstore) DeleteBlock(c *cid.Cid) error {
+	defer bs.lock(c)()
+	size, err := blockSize(bs.GCBlockstore, c)
+	if err != nil {
+		return err
+	}
+	if err := bs.GCBlockstore.DeleteBlock(c); err != nil {
+		return err
+	}
+	bs.usage.Removed(size)
+	return nil
+}
+
+// blockSize returns the size of the block c of bs, without reading it when
+// bs can tell it.
+func blockSize(bs bstore.Blockstore, c *cid.Cid) (int, error) {
+	if s, ok := bs.(interface {
+		GetSize(*cid.Cid) (int, error)
+	}); ok {
+		return s.GetSize(c)
+	}
+	b, err := bs.Get(c)
+	if err != nil {
+		return 0, err
+	}
+	return len(b.RawData()), nil
+}
+
+// setupUsage counts the blocks of the repo, for the usage by category of
+// 'ipfs repo stat'. Online nodes keep the count of the pinned and MFS blocks
+// up to date with Usage.Run.
+func (n *IpfsNode) setupUsage() error {
+	t, err := usage.NewTracker(n.Repo.Datastore())
+	if err != nil {
+		return err
+	}
+	t.Roots = n.usageRoots
+	t.Walk = n.walkUsage
+	t.Scan = n.scanUsage
+
+	n.Usage = t
+	n.Blockstore = &usageBlockstore{GCBlockstore: n.Blockstore, usage: t}
+	return nil
+}
+
+// usageRoots returns a hash of the pins and the MFS root.
+func (n *IpfsNode) usageRoots() (string, error) {
+	h := sha256.New()
+	if n.Pinning != nil {
+		for _, keys := range [][]*cid.Cid{n.Pinning.RecursiveKeys(), n.Pinning.DirectKeys()} {
+			for _, c := range keys {
+				h.Write(c.Bytes())
+			}
+			h.Write([]byte{0})
+		}
+	}
+	if n.FilesRoot != nil {
+		nd, err := n.FilesRoot.GetValue().GetNode()
+		if err != nil {
+			return "", err
+		}
+		h.Write(nd.Cid().Bytes())
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// scanUsage counts all the blocks of the blockstore.
+func (n *IpfsNode) scanUsage(ctx context.Context) (uint64, uint64, error) {
+	keys, err := n.Blockstore.AllKeysChan(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var count, size uint64
+	for c := range keys {
+		s, err := blockSize(n.Blockstore, c)
+		if err == bstore.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		count++
+		size += uint64(s)
+	}
+	return count, size, ctx.Err()
+}
+
+// inBaseBlocks returns whether the block c is stored in the blockstore, not
+// only referenced by the filestore, whose data is counted apart.
+func (n *IpfsNode) inBaseBlocks(c *cid.Cid) bool {
+	if n.Filestore == nil || c.Type() != cid.Raw {
+		return true
+	}
+	has, err := n.BaseBlocks.Has(c)
+	return err == nil && has
+}
+
+// walkUsage counts the blocks of the pinned DAGs, then the other blocks of
+// the MFS tree, and the data referenced by the filestore.
+func (n *IpfsNode) walkUsage(ctx context.Context) (*usage.Walk, error) {
+	ctx, err := n.TraversalContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	set, err := cidset.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer set.Close()
+
+	w := new(usage.Walk)
+	total := &w.Pinned
+
+	// only the local blocks are counted, each read once, for its links and
+	// its size
+	ng := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+	getLinks := func(ctx context.Context, c *cid.Cid) ([]*ipld.Link, error) {
+		nd, err := ng.Get(ctx, c)
+		if err == ipld.ErrNotFound {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n.inBaseBlocks(c) {
+			atomic.AddUint64(total, uint64(len(nd.RawData())))
+		}
+		return nd.Links(), nil
+	}
+
+	if n.Pinning != nil {
+		if err := gc.Descendants(ctx, getLinks, set, n.Pinning.RecursiveKeys()); err != nil {
+			return nil, err
+		}
+		if err := gc.Descendants(ctx, getLinks, set, n.Pinning.InternalPins()); err != nil {
+			return nil, err
+		}
+		for _, c := range n.Pinning.DirectKeys() {
+			if !set.Visit(c) || !n.inBaseBlocks(c) {
+				continue
+			}
+			if size, err := blockSize(n.BaseBlocks, c); err == nil {
+				w.Pinned += uint64(size)
+			}
+		}
+	}
+
+	if n.FilesRoot != nil {
+		nd, err := n.FilesRoot.GetValue().GetNode()
+		if err != nil {
+			return nil, err
+		}
+		total = &w.MFS
+		if err := gc.Descendants(ctx, getLinks, set, []*cid.Cid{nd.Cid()}); err != nil {
+			return nil, err
+		}
+	}
+	if err := set.Err(); err != nil {
+		return nil, err
+	}
+
+	if n.Filestore != nil {
+		next, err := filestore.ListAll(n.Filestore, false)
+		if err != nil {
+			return nil, err
+		}
+		for r := next(); r != nil; r = next() {
+			if r.Status == filestore.StatusOk {
+				w.Filestore += r.Size
+			}
+		}
+	}
+	return w, nil
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	usage "github.com/ipfs/go-ipfs/repo/usage"
+
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	bstore "gx/ipfs/QmbaPGg81pvQiC5vTXtC9Jo8rdrWUjRaugH71WYNsgi6Ev/go-ipfs-blockstore"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dssync "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
+)
+
+func TestUsageBlockstoreConcurrentPuts(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	tr, err := usage.NewTracker(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := &usageBlockstore{
+		GCBlockstore: bstore.NewGCBlockstore(bstore.NewBlockstore(d), bstore.NewGCLocker()),
+		usage:        tr,
+	}
+
+	var blks []blocks.Block
+	for i := 0; i < 50; i++ {
+		blks = append(blks, blocks.NewBlock([]byte(fmt.Sprintf("block %d", i))))
+	}
+
+	// each block put at once by several writers, alone and in batches
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for _, b := range blks {
+				if err := bs.Put(b); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := bs.PutMany(blks); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n, _ := tr.Blocks(); n != uint64(len(blks)) {
+		t.Fatalf("expected %d blocks counted, got %d", len(blks), n)
+	}
+
+	for _, b := range blks[:10] {
+		if err := bs.DeleteBlock(b.Cid()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, _ := tr.Blocks(); n != uint64(len(blks)-10) {
+		t.Fatalf("expected %d blocks counted, got %d", len(blks)-10, n)
+	}
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"fmt"
+
+	namesys "github.com/ipfs/go-ipfs/namesys"
+
+	record "gx/ipfs/QmPWjVzxHeJdrjp4Jr2R2sPxBrMbBgGPWQtKwCKHHCBF7x/go-libp2p-record"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+)
+
+// validators are the validators of the record namespaces registered with
+// RegisterValidator.
+var validators = make(map[string]record.Validator)
+
+// RegisterValidator registers the validator of the records of a namespace of
+// the routing system, like "myapp" for the keys /myapp/<name>, so that the
+// node stores and serves them. Validators must be registered before the nodes
+// are built, usually by plugins. The built-in namespaces, "pk" and "ipns",
+// can't be replaced.
+func RegisterValidator(ns string, v record.Validator) error {
+	switch ns {
+	case "":
+		return fmt.Errorf("empty record namespace")
+	case "pk", IpnsValidatorTag:
+		return fmt.Errorf("the validator of the record namespace %q can't be replaced", ns)
+	}
+	if _, ok := validators[ns]; ok {
+		return fmt.Errorf("the record namespace %q already has a validator", ns)
+	}
+	validators[ns] = v
+	return nil
+}
+
+// newRecordValidator returns the validator of the records of the routing
+// system: the built-in ones and the registered ones.
+func newRecordValidator(kb pstore.KeyBook) record.NamespacedValidator {
+	v := record.NamespacedValidator{
+		"pk":             record.PublicKeyValidator{},
+		IpnsValidatorTag: namesys.IpnsValidator{KeyBook: kb},
+	}
+	for ns, nv := range validators {
+		v[ns] = nv
+	}
+	return v
+}
@@ -0,0 +1,30 @@
+package core
+
+import (
+	"testing"
+
+	record "gx/ipfs/QmPWjVzxHeJdrjp4Jr2R2sPxBrMbBgGPWQtKwCKHHCBF7x/go-libp2p-record"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+)
+
+func TestRegisterValidator(t *testing.T) {
+	defer delete(validators, "myapp")
+
+	if err := RegisterValidator("ipns", record.PublicKeyValidator{}); err == nil {
+		t.Fatal("expected replacing the ipns validator to fail")
+	}
+
+	if err := RegisterValidator("myapp", record.PublicKeyValidator{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterValidator("myapp", record.PublicKeyValidator{}); err == nil {
+		t.Fatal("expected registering a namespace twice to fail")
+	}
+
+	v := newRecordValidator(pstore.NewPeerstore())
+	for _, ns := range []string{"pk", "ipns", "myapp"} {
+		if _, ok := v[ns]; !ok {
+			t.Errorf("the validator of %s is missing", ns)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	events "github.com/ipfs/go-ipfs/events"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	webhooks "github.com/ipfs/go-ipfs/webhooks"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	goprocess "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
+	sysi "gx/ipfs/QmZRjKbHa6DenStpQJFiaPcEwkZqrx7TH6xTf342LDU3qM/go-sysinfo"
+)
+
+const defaultThresholdCheckInterval = time.Minute
+
+// setupWebhooks starts posting the events of the node to the configured
+// hooks, and checking the thresholds of the disk.low and peers.low events.
+func (n *IpfsNode) setupWebhooks() error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+
+	hooks, err := parseWebhooks(cfg.Webhooks.Hooks)
+	if err != nil {
+		return err
+	}
+	if len(hooks) > 0 {
+		d := webhooks.NewDispatcher(n.Identity.Pretty(), n.Events, hooks)
+		n.Process().Go(d.Run)
+	}
+	return n.watchThresholds(cfg.Webhooks)
+}
+
+func parseWebhooks(hs []config.Webhook) ([]webhooks.Hook, error) {
+	known := make(map[events.Type]bool, len(events.Types))
+	for _, t := range events.Types {
+		known[t] = true
+	}
+
+	hooks := make([]webhooks.Hook, 0, len(hs))
+	for i, h := range hs {
+		u, err := url.Parse(h.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL in Webhooks.Hooks[%d]: %s", i, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("the URL in Webhooks.Hooks[%d] must be http or https, got %q", i, h.URL)
+		}
+
+		hook := webhooks.Hook{URL: h.URL, Secret: h.Secret, Retries: h.Retries}
+		for _, e := range h.Events {
+			t := events.Type(e)
+			if !known[t] {
+				return nil, fmt.Errorf("unknown event type %q in Webhooks.Hooks[%d]", e, i)
+			}
+			hook.Types = append(hook.Types, t)
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// watchThresholds emits the disk.low and peers.low events when the free space
+// of the repo or the connected peers fall below their thresholds. They are
+// emitted again once the values went back above them.
+func (n *IpfsNode) watchThresholds(cfg config.Webhooks) error {
+	var minFree uint64
+	if cfg.LowDiskSpace != "" {
+		v, err := humanize.ParseBytes(cfg.LowDiskSpace)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting Webhooks.LowDiskSpace: %s", err)
+		}
+		minFree = v
+	}
+	repoPath := n.repoPath()
+	if minFree > 0 && repoPath == "" {
+		log.Warning("Webhooks.LowDiskSpace is ignored: the repo isn't on a filesystem")
+		minFree = 0
+	}
+	minPeers := cfg.LowPeers
+	if minFree == 0 && minPeers <= 0 {
+		return nil
+	}
+
+	interval := defaultThresholdCheckInterval
+	if cfg.CheckInterval != "" {
+		d, err := time.ParseDuration(cfg.CheckInterval)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting Webhooks.CheckInterval: %s", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("config setting Webhooks.CheckInterval must be positive: %s", d)
+		}
+		interval = d
+	}
+
+	n.Process().Go(func(proc goprocess.Process) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		diskLow, peersLow := false, false
+		for {
+			// the first check waits an interval, for the node to connect
+			// to its peers
+			select {
+			case <-ticker.C:
+			case <-proc.Closing():
+				return
+			}
+
+			if minFree > 0 {
+				du, err := sysi.DiskUsage(repoPath)
+				if err != nil {
+					log.Warning("failed to check the free disk space: ", err)
+				} else {
+					low := du.Free < minFree
+					if low && !diskLow {
+						n.Events.Emit(events.DiskSpaceLow, map[string]string{
+							"free":      strconv.FormatUint(du.Free, 10),
+							"threshold": strconv.FormatUint(minFree, 10),
+						})
+					}
+					diskLow = low
+				}
+			}
+
+			if minPeers > 0 {
+				peers := len(n.PeerHost.Network().Peers())
+				low := peers < minPeers
+				if low && !peersLow {
+					n.Events.Emit(events.PeersLow, map[string]string{
+						"peers":     strconv.Itoa(peers),
+						"threshold": strconv.Itoa(minPeers),
+					})
+				}
+				peersLow = low
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,404 @@
+// Package denylist implements content blocking based on denylist files.
+//
+// A denylist file contains one entry per line. Empty lines and lines starting
+// with '#' are ignored. Entries take one of the following forms:
+//
+//	<cid>                    block a CID, and every path below it
+//	/ipfs/<cid>              same as above
+//	/ipfs/<cid>/some/path    block a path, and every path below it
+//	/ipns/<name>[/path]      block an IPNS name or a path below it
+//	//<sha256-hex>           double-hashed entry, see below
+//
+// Double-hashed entries allow publishing denylists without revealing the
+// blocked content. The hash is the hex encoded sha256 of "<cid>", or of
+// "<cid>/<path>" for a path below a CID, where <cid> is in the normalized form
+// returned by the NormalizeFunc in use (CIDv1, base32 for go-ipfs). IPNS
+// names are hashed the same way, as "<name>" or "<name>/<path>".
+package denylist
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBlocked is returned when requested content is on a denylist.
+var ErrBlocked error = blockedError{}
+
+type blockedError struct{}
+
+func (blockedError) Error() string { return "content is blocked by a denylist" }
+
+// Refused marks the error as a refusal to return the content, which the
+// layers below core, like merkledag, return as it is rather than wrapped.
+func (blockedError) Refused() {}
+
+// NormalizeFunc converts a CID to its canonical string form, so that entries
+// match regardless of the CID version and encoding used.
+type NormalizeFunc func(c string) (string, error)
+
+// List is a parsed denylist.
+type List struct {
+	cids   map[string]struct{}
+	paths  map[string]struct{}
+	hashes map[string]struct{}
+}
+
+// Parse reads a denylist. norm may be nil, in which case CIDs are matched
+// verbatim.
+func Parse(r io.Reader, norm NormalizeFunc) (*List, error) {
+	l := &List{
+		cids:   make(map[string]struct{}),
+		paths:  make(map[string]struct{}),
+		hashes: make(map[string]struct{}),
+	}
+
+	scan := bufio.NewScanner(r)
+	for lnum := 1; scan.Scan(); lnum++ {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if strings.HasPrefix(line, "//") {
+			h := strings.ToLower(line[2:])
+			if b, err := hex.DecodeString(h); err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("line %d: invalid double-hashed entry %q", lnum, line)
+			}
+			l.hashes[h] = struct{}{}
+			continue
+		}
+
+		ns, root, rest, err := splitPath(line, norm)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lnum, err)
+		}
+		if ns == "ipfs" && rest == "" {
+			l.cids[root] = struct{}{}
+			continue
+		}
+		l.paths[joinPath(ns, root, rest)] = struct{}{}
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Len returns the number of entries in the list.
+func (l *List) Len() int {
+	return len(l.cids) + len(l.paths) + len(l.hashes)
+}
+
+// IsBlocked returns whether the given path is blocked by the list. p may be
+// a bare CID, an /ipfs/ or an /ipns/ path.
+func (l *List) IsBlocked(p string, norm NormalizeFunc) bool {
+	ns, root, rest, err := splitPath(p, norm)
+	if err != nil {
+		return false
+	}
+
+	if ns == "ipfs" {
+		if _, ok := l.cids[root]; ok {
+			return true
+		}
+	}
+
+	// check the path itself and every parent, down to the root
+	sub := rest
+	for {
+		if _, ok := l.paths[joinPath(ns, root, sub)]; ok {
+			return true
+		}
+		if _, ok := l.hashes[doubleHash(root, sub)]; ok {
+			return true
+		}
+		if sub == "" {
+			return false
+		}
+		if i := strings.LastIndexByte(sub, '/'); i >= 0 {
+			sub = sub[:i]
+		} else {
+			sub = ""
+		}
+	}
+}
+
+// DoubleHash returns the double-hashed denylist entry for a (normalized) CID
+// or IPNS name and an optional path below it.
+func DoubleHash(root, p string) string {
+	return "//" + doubleHash(root, strings.Trim(p, "/"))
+}
+
+func doubleHash(root, rest string) string {
+	s := root
+	if rest != "" {
+		s += "/" + rest
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// splitPath splits p into its namespace, root and the remaining path.
+func splitPath(p string, norm NormalizeFunc) (ns, root, rest string, err error) {
+	ns = "ipfs"
+	if strings.HasPrefix(p, "/") {
+		segs := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2)
+		ns = segs[0]
+		if ns != "ipfs" && ns != "ipns" {
+			return "", "", "", fmt.Errorf("unsupported path namespace: %q", p)
+		}
+		p = ""
+		if len(segs) > 1 {
+			p = segs[1]
+		}
+	}
+
+	segs := strings.SplitN(p, "/", 2)
+	root = segs[0]
+	if root == "" {
+		return "", "", "", fmt.Errorf("invalid path: %q", p)
+	}
+	if len(segs) > 1 {
+		rest = cleanPath(segs[1])
+	}
+
+	if ns == "ipfs" && norm != nil {
+		root, err = norm(root)
+		if err != nil {
+			return "", "", "", err
+		}
+	}
+	return ns, root, rest, nil
+}
+
+// cleanPath removes empty segments from a slash separated path.
+func cleanPath(p string) string {
+	segs := strings.Split(p, "/")
+	out := segs[:0]
+	for _, s := range segs {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+func joinPath(ns, root, rest string) string {
+	p := "/" + ns + "/" + root
+	if rest != "" {
+		p += "/" + rest
+	}
+	return p
+}
+
+type file struct {
+	path    string
+	modTime time.Time
+	size    int64
+	list    *List
+}
+
+// Set is a collection of denylist files. It keeps track of changes to the
+// files and reloads them when they are modified.
+type Set struct {
+	norm NormalizeFunc
+
+	// reloadMu serializes reloads, mu protects the loaded lists
+	reloadMu sync.Mutex
+	mu       sync.RWMutex
+	files    []*file
+
+	closeOnce sync.Once
+	closing   chan struct{}
+}
+
+// NewSet loads the given denylist files. Missing files are treated as empty
+// and picked up once they are created.
+func NewSet(paths []string, norm NormalizeFunc) (*Set, error) {
+	s := &Set{
+		norm:    norm,
+		closing: make(chan struct{}),
+	}
+	for _, p := range paths {
+		s.files = append(s.files, &file{path: p})
+	}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// IsBlocked returns whether p is blocked by any of the denylists. A nil Set
+// blocks nothing.
+func (s *Set) IsBlocked(p string) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, f := range s.files {
+		if f.list != nil && f.list.IsBlocked(p, s.norm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns ErrBlocked if p is blocked.
+func (s *Set) Check(p string) error {
+	if s.IsBlocked(p) {
+		return ErrBlocked
+	}
+	return nil
+}
+
+// Files returns the paths of the denylist files in the set.
+func (s *Set) Files() []string {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]string, len(s.files))
+	for i, f := range s.files {
+		out[i] = f.path
+	}
+	return out
+}
+
+// Reload re-reads the denylist files which changed since they were last
+// loaded. It returns whether anything changed. When a file fails to parse,
+// its previous version is kept and the error is returned.
+func (s *Set) Reload() (bool, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	return s.reload()
+}
+
+// SetFiles replaces the denylist files of the set with paths and loads the
+// new ones. Files which were already part of the set are kept as loaded.
+func (s *Set) SetFiles(paths []string) (bool, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	s.mu.RLock()
+	known := make(map[string]*file, len(s.files))
+	for _, f := range s.files {
+		known[f.path] = f
+	}
+	s.mu.RUnlock()
+
+	files := make([]*file, 0, len(paths))
+	changed := len(paths) != len(known)
+	for _, p := range paths {
+		f, ok := known[p]
+		if !ok {
+			f = &file{path: p}
+			changed = true
+		}
+		files = append(files, f)
+	}
+
+	s.mu.Lock()
+	s.files = files
+	s.mu.Unlock()
+
+	reloaded, err := s.reload()
+	return changed || reloaded, err
+}
+
+func (s *Set) reload() (bool, error) {
+	s.mu.RLock()
+	files := s.files
+	s.mu.RUnlock()
+
+	type update struct {
+		f       *file
+		modTime time.Time
+		size    int64
+		list    *List
+	}
+
+	var updates []update
+	var firstErr error
+	for _, f := range files {
+		st, err := os.Stat(f.path)
+		if os.IsNotExist(err) {
+			if f.list != nil {
+				updates = append(updates, update{f: f})
+			}
+			continue
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if f.list != nil && st.ModTime().Equal(f.modTime) && st.Size() == f.size {
+			continue
+		}
+
+		l, err := parseFile(f.path, s.norm)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("denylist %s: %s", f.path, err)
+			}
+			continue
+		}
+		updates = append(updates, update{f, st.ModTime(), st.Size(), l})
+	}
+
+	if len(updates) > 0 {
+		s.mu.Lock()
+		for _, u := range updates {
+			u.f.modTime, u.f.size, u.f.list = u.modTime, u.size, u.list
+		}
+		s.mu.Unlock()
+	}
+	return len(updates) > 0, firstErr
+}
+
+func parseFile(p string, norm NormalizeFunc) (*List, error) {
+	fi, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+	return Parse(fi, norm)
+}
+
+// Watch polls the denylist files for changes every interval until the set is
+// closed. Errors are passed to onErr, which may be nil.
+func (s *Set) Watch(interval time.Duration, onErr func(error)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if _, err := s.Reload(); err != nil && onErr != nil {
+				onErr(err)
+			}
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+// Close stops watching the denylist files.
+func (s *Set) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.closeOnce.Do(func() { close(s.closing) })
+	return nil
+}
@@ -0,0 +1,166 @@
+package denylist
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testList = `
+# comment
+QmBlockedCid
+/ipfs/QmParent/secret/dir
+/ipns/bad.example.com
+/ipns/example.com/private
+`
+
+func TestParseAndMatch(t *testing.T) {
+	hashed := DoubleHash("QmHashed", "a/b")
+	l, err := Parse(strings.NewReader(testList+hashed+"\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Len() != 5 {
+		t.Fatalf("expected 5 entries, got %d", l.Len())
+	}
+
+	cases := map[string]bool{
+		"QmBlockedCid":                       true,
+		"/ipfs/QmBlockedCid":                 true,
+		"/ipfs/QmBlockedCid/any/path":        true,
+		"/ipfs/QmParent":                     false,
+		"/ipfs/QmParent/secret":              false,
+		"/ipfs/QmParent/secret/dir":          true,
+		"/ipfs/QmParent//secret/dir/":        true,
+		"/ipfs/QmParent/secret/dir/file.txt": true,
+		"/ipfs/QmParent/secret/directory":    false,
+		"/ipns/bad.example.com/index.html":   true,
+		"/ipns/example.com":                  false,
+		"/ipns/example.com/private/x":        true,
+		"/ipfs/QmHashed/a":                   false,
+		"/ipfs/QmHashed/a/b":                 true,
+		"/ipfs/QmHashed/a/b/c":               true,
+		"/ipfs/QmOther":                      false,
+		"/foo/bar":                           false,
+	}
+	for p, blocked := range cases {
+		if l.IsBlocked(p, nil) != blocked {
+			t.Errorf("%s: expected blocked=%t", p, blocked)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{"//nothex", "//abcd", "/foo/bar"} {
+		if _, err := Parse(strings.NewReader(in), nil); err == nil {
+			t.Errorf("expected error parsing %q", in)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	norm := func(c string) (string, error) { return strings.ToLower(c), nil }
+	l, err := Parse(strings.NewReader("QmABC\n"), norm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.IsBlocked("/ipfs/qMaBc", norm) {
+		t.Fatal("expected normalized cid to be blocked")
+	}
+}
+
+func TestSetReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "denylist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "deny.txt")
+	s, err := NewSet([]string{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if s.IsBlocked("QmA") {
+		t.Fatal("nothing should be blocked without the file")
+	}
+
+	if err := ioutil.WriteFile(p, []byte("QmA\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := s.Reload()
+	if err != nil || !changed {
+		t.Fatalf("expected reload to pick up the new file: %t %v", changed, err)
+	}
+	if s.Check("/ipfs/QmA") != ErrBlocked {
+		t.Fatal("expected QmA to be blocked")
+	}
+
+	// a broken file keeps the previous version
+	if err := ioutil.WriteFile(p, []byte("QmA\n//broken\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(p, time.Now(), time.Now().Add(time.Second))
+	if _, err := s.Reload(); err == nil {
+		t.Fatal("expected parse error")
+	}
+	if !s.IsBlocked("QmA") {
+		t.Fatal("expected previous list to be kept")
+	}
+
+	os.Remove(p)
+	if _, err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if s.IsBlocked("QmA") {
+		t.Fatal("expected removed file to unblock content")
+	}
+
+	var nilSet *Set
+	if nilSet.IsBlocked("QmA") {
+		t.Fatal("nil set must not block anything")
+	}
+}
+
+func TestSetFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "denylist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	ioutil.WriteFile(a, []byte("QmA\n"), 0644)
+	ioutil.WriteFile(b, []byte("QmB\n"), 0644)
+
+	s, err := NewSet([]string{a}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	changed, err := s.SetFiles([]string{a, b})
+	if err != nil || !changed {
+		t.Fatalf("expected new file to be loaded: %t %v", changed, err)
+	}
+	if !s.IsBlocked("QmA") || !s.IsBlocked("QmB") {
+		t.Fatal("expected both lists to apply")
+	}
+
+	changed, err = s.SetFiles([]string{a, b})
+	if err != nil || changed {
+		t.Fatalf("expected no change: %t %v", changed, err)
+	}
+
+	if _, err := s.SetFiles([]string{b}); err != nil {
+		t.Fatal(err)
+	}
+	if s.IsBlocked("QmA") || !s.IsBlocked("QmB") {
+		t.Fatal("expected only the remaining list to apply")
+	}
+}
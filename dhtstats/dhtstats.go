@@ -0,0 +1,286 @@
+// Package dhtstats keeps statistics on the DHT of a node, to tell whether
+// poor content discovery comes from the connectivity of the node: the size
+// of its routing table by bucket, how fast the peers of the table change,
+// and how the lookups made by the node fare.
+//
+// The routing table is sampled with the Peers func. The lookups are measured
+// by wrapping the routing of the node with Wrap.
+package dhtstats
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+
+	goprocess "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	kb "gx/ipfs/QmaLVuEDhVAf26sTUPdZdqUfe1nBwEQNrAsKFkkXJTq6Ga/go-libp2p-kbucket"
+)
+
+// BucketSize is the number of peers a bucket of the routing table holds.
+const BucketSize = 20
+
+// DefaultInterval is how often the routing table is sampled by default.
+const DefaultInterval = 10 * time.Second
+
+// ChurnWindow is the period over which the churn of the routing table is
+// measured.
+const ChurnWindow = time.Hour
+
+// The lookups measured.
+const (
+	FindPeer      = "FindPeer"
+	FindProviders = "FindProviders"
+	GetValue      = "GetValue"
+	PutValue      = "PutValue"
+	Provide       = "Provide"
+)
+
+var lookups = []string{FindPeer, FindProviders, GetValue, PutValue, Provide}
+
+// Bucket is the number of peers of the routing table sharing a prefix of
+// CommonPrefix bits with the node. The table keeps BucketSize of them at
+// most, Full being set when it's reached.
+type Bucket struct {
+	CommonPrefix int
+	Peers        int
+	Full         bool
+}
+
+// Lookup holds the statistics of a kind of lookup.
+type Lookup struct {
+	Type      string
+	Count     uint64
+	Succeeded uint64
+	// AvgLatency is the average time the lookups took, until the first
+	// provider was found for FindProviders.
+	AvgLatency time.Duration
+	// AvgHops is the average number of hops of the lookups, from the peers
+	// of the routing table to the furthest peer which answered.
+	AvgHops float64
+}
+
+// SuccessRatio returns the share of the lookups which succeeded.
+func (l *Lookup) SuccessRatio() float64 {
+	if l.Count == 0 {
+		return 0
+	}
+	return float64(l.Succeeded) / float64(l.Count)
+}
+
+// Report is the state of the DHT of a node.
+type Report struct {
+	// Peers is the number of peers of the routing table.
+	Peers   int
+	Buckets []Bucket
+	// Joined and Left are the number of peers which joined and left the
+	// routing table over Window, ChurnRate being their sum per hour.
+	Joined    uint64
+	Left      uint64
+	Window    time.Duration
+	ChurnRate float64
+	// Lookups are counted since Since.
+	Since   time.Time
+	Lookups []Lookup
+	// Warnings point out what may hinder content discovery.
+	Warnings []string `json:",omitempty"`
+}
+
+type churn struct {
+	time   time.Time
+	joined uint64
+	left   uint64
+}
+
+type lookupStats struct {
+	count     uint64
+	succeeded uint64
+	latency   time.Duration // of the successful lookups
+	hops      uint64
+}
+
+// Stats collects the statistics of the DHT of a node.
+type Stats struct {
+	self kb.ID
+
+	// Peers returns the peers of the routing table.
+	Peers func() []peer.ID
+	// Interval is how often Run samples the routing table.
+	Interval time.Duration
+
+	now   func() time.Time
+	start time.Time
+
+	mu      sync.Mutex
+	table   map[peer.ID]struct{} // at the last sample, nil before
+	churn   []churn              // over the last ChurnWindow
+	lookups map[string]*lookupStats
+}
+
+// New returns the Stats of the DHT of the node self.
+func New(self peer.ID) *Stats {
+	s := &Stats{
+		self:     kb.ConvertPeerID(self),
+		Interval: DefaultInterval,
+		now:      time.Now,
+		lookups:  make(map[string]*lookupStats),
+	}
+	s.start = s.now()
+	return s
+}
+
+// Run samples the routing table until proc closes.
+func (s *Stats) Run(proc goprocess.Process) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		s.sample()
+
+		select {
+		case <-ticker.C:
+		case <-proc.Closing():
+			return
+		}
+	}
+}
+
+// sample counts the peers which joined and left the routing table since the
+// last sample.
+func (s *Stats) sample() {
+	peers := s.Peers()
+	now := s.now()
+
+	table := make(map[peer.ID]struct{}, len(peers))
+	for _, p := range peers {
+		table[p] = struct{}{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.table != nil {
+		c := churn{time: now}
+		for p := range table {
+			if _, ok := s.table[p]; !ok {
+				c.joined++
+			}
+		}
+		for p := range s.table {
+			if _, ok := table[p]; !ok {
+				c.left++
+			}
+		}
+		s.churn = append(s.churn, c)
+	}
+	s.table = table
+
+	i := 0
+	for i < len(s.churn) && now.Sub(s.churn[i].time) > ChurnWindow {
+		i++
+	}
+	s.churn = s.churn[i:]
+}
+
+// record counts a lookup.
+func (s *Stats) record(typ string, ok bool, latency time.Duration, hops int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := s.lookups[typ]
+	if l == nil {
+		l = new(lookupStats)
+		s.lookups[typ] = l
+	}
+	l.count++
+	l.hops += uint64(hops)
+	if ok {
+		l.succeeded++
+		l.latency += latency
+	}
+}
+
+// Report returns the statistics of the DHT.
+func (s *Stats) Report() *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := &Report{
+		Peers:  len(s.table),
+		Window: ChurnWindow,
+		Since:  s.start,
+	}
+
+	counts := make(map[int]int)
+	for p := range s.table {
+		counts[commonPrefixLen(s.self, kb.ConvertPeerID(p))]++
+	}
+	for cpl, n := range counts {
+		r.Buckets = append(r.Buckets, Bucket{CommonPrefix: cpl, Peers: n, Full: n >= BucketSize})
+	}
+	sort.Slice(r.Buckets, func(i, j int) bool {
+		return r.Buckets[i].CommonPrefix < r.Buckets[j].CommonPrefix
+	})
+
+	for _, c := range s.churn {
+		r.Joined += c.joined
+		r.Left += c.left
+	}
+	// the churn is measured since the node started if it didn't run for a
+	// whole window yet
+	if elapsed := s.now().Sub(s.start); elapsed < r.Window {
+		r.Window = elapsed
+	}
+	if r.Window > 0 {
+		r.ChurnRate = float64(r.Joined+r.Left) / r.Window.Hours()
+	}
+
+	for _, typ := range lookups {
+		l := Lookup{Type: typ}
+		if st := s.lookups[typ]; st != nil {
+			l.Count = st.count
+			l.Succeeded = st.succeeded
+			l.AvgHops = float64(st.hops) / float64(st.count)
+			if st.succeeded > 0 {
+				l.AvgLatency = st.latency / time.Duration(st.succeeded)
+			}
+		}
+		r.Lookups = append(r.Lookups, l)
+	}
+
+	r.Warnings = warnings(r)
+	return r
+}
+
+// minLookups is the number of lookups from which a low success ratio is
+// pointed out.
+const minLookups = 10
+
+func warnings(r *Report) []string {
+	var w []string
+	switch {
+	case r.Peers == 0:
+		w = append(w, "the routing table is empty: the node isn't connected to any DHT peer, check its connectivity and bootstrap peers")
+	case r.Peers < BucketSize:
+		w = append(w, fmt.Sprintf("only %d peers in the routing table: lookups may fail, check the connectivity of the node (NAT, firewall, bootstrap peers)", r.Peers))
+	}
+	if r.Peers > 0 && r.ChurnRate > float64(r.Peers) {
+		w = append(w, fmt.Sprintf("the routing table churns quickly (%.0f peers/h for %d peers): connections may be dropped too early, check Swarm.ConnMgr", r.ChurnRate, r.Peers))
+	}
+	for _, l := range r.Lookups {
+		if l.Count >= minLookups && l.SuccessRatio() < 0.5 {
+			w = append(w, fmt.Sprintf("%.0f%% of the %d %s lookups failed", 100*(1-l.SuccessRatio()), l.Count, l.Type))
+		}
+	}
+	return w
+}
+
+func commonPrefixLen(a, b kb.ID) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if x := a[i] ^ b[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+	return len(a) * 8
+}
@@ -0,0 +1,106 @@
+package dhtstats
+
+import (
+	"testing"
+	"time"
+
+	notif "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing/notifications"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+)
+
+func newTestStats(now *time.Time, peers *[]peer.ID) *Stats {
+	s := New(peer.ID("self"))
+	s.now = func() time.Time { return *now }
+	s.start = *now
+	s.Peers = func() []peer.ID { return *peers }
+	return s
+}
+
+func TestChurn(t *testing.T) {
+	now := time.Date(2018, 6, 21, 12, 0, 0, 0, time.UTC)
+	peers := []peer.ID{"a", "b", "c"}
+	s := newTestStats(&now, &peers)
+
+	s.sample()
+	now = now.Add(30 * time.Minute)
+	peers = []peer.ID{"a", "d", "e"}
+	s.sample()
+
+	r := s.Report()
+	if r.Peers != 3 {
+		t.Fatalf("expected 3 peers, got %d", r.Peers)
+	}
+	if r.Joined != 2 || r.Left != 2 {
+		t.Fatalf("expected 2 peers joined and 2 left, got %d and %d", r.Joined, r.Left)
+	}
+	if r.Window != 30*time.Minute || r.ChurnRate != 8 {
+		t.Fatalf("expected 8 peers/h over 30m, got %f over %s", r.ChurnRate, r.Window)
+	}
+
+	n := 0
+	for _, b := range r.Buckets {
+		n += b.Peers
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 peers in the buckets, got %d", n)
+	}
+
+	// the churn older than the window is dropped
+	now = now.Add(2 * time.Hour)
+	s.sample()
+	r = s.Report()
+	if r.Joined != 0 || r.Left != 0 || r.Window != ChurnWindow {
+		t.Fatalf("expected no churn over %s, got %d joined and %d left over %s", ChurnWindow, r.Joined, r.Left, r.Window)
+	}
+}
+
+func TestLookups(t *testing.T) {
+	now := time.Date(2018, 6, 21, 12, 0, 0, 0, time.UTC)
+	var peers []peer.ID
+	s := newTestStats(&now, &peers)
+
+	s.record(FindPeer, true, time.Second, 4)
+	s.record(FindPeer, true, 3*time.Second, 6)
+	for i := 0; i < 10; i++ {
+		s.record(GetValue, false, 0, 2)
+	}
+
+	r := s.Report()
+	if len(r.Lookups) != len(lookups) {
+		t.Fatalf("expected %d lookup types, got %d", len(lookups), len(r.Lookups))
+	}
+	l := r.Lookups[0]
+	if l.Type != FindPeer || l.Count != 2 || l.SuccessRatio() != 1 || l.AvgLatency != 2*time.Second || l.AvgHops != 5 {
+		t.Fatalf("unexpected FindPeer statistics: %+v", l)
+	}
+
+	// the empty routing table and the failed lookups are pointed out
+	if len(r.Warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %q", r.Warnings)
+	}
+}
+
+func TestHops(t *testing.T) {
+	response := func(p peer.ID, closer ...peer.ID) *notif.QueryEvent {
+		ev := &notif.QueryEvent{Type: notif.PeerResponse, ID: p}
+		for _, c := range closer {
+			ev.Responses = append(ev.Responses, &pstore.PeerInfo{ID: c})
+		}
+		return ev
+	}
+
+	// a and b come from the routing table, c from a, and d from c and b
+	var h hopCounter
+	h.response(response("a", "c"))
+	h.response(response("b", "d"))
+	h.response(response("c", "d", "e"))
+	h.response(response("d"))
+	if h.max != 2 {
+		t.Fatalf("expected 2 hops, got %d", h.max)
+	}
+	h.response(response("e"))
+	if h.max != 3 {
+		t.Fatalf("expected 3 hops, got %d", h.max)
+	}
+}
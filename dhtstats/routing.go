@@ -0,0 +1,209 @@
+package dhtstats
+
+import (
+	"context"
+	"time"
+
+	routing "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing"
+	notif "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing/notifications"
+	ropts "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing/options"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// Wrap returns r, measuring its lookups. The lookups aborted by their caller
+// aren't counted. The values searches of the routing systems streaming them,
+// such as the DHT, are passed through as GetValue lookups.
+func (s *Stats) Wrap(r routing.IpfsRouting) routing.IpfsRouting {
+	tr := &trackedRouting{IpfsRouting: r, stats: s}
+	if _, ok := r.(valueSearcher); ok {
+		return &searchingRouting{tr}
+	}
+	return tr
+}
+
+// valueSearcher is a routing system streaming the values it finds for a key.
+type valueSearcher interface {
+	SearchValue(ctx context.Context, key string, opts ...ropts.Option) (<-chan []byte, error)
+}
+
+type trackedRouting struct {
+	routing.IpfsRouting
+	stats *Stats
+}
+
+func (r *trackedRouting) FindPeer(ctx context.Context, p peer.ID) (pstore.PeerInfo, error) {
+	qctx, q := r.stats.track(ctx, FindPeer)
+	pi, err := r.IpfsRouting.FindPeer(qctx, p)
+	q.done(err == nil)
+	return pi, err
+}
+
+func (r *trackedRouting) FindProvidersAsync(ctx context.Context, c *cid.Cid, count int) <-chan pstore.PeerInfo {
+	qctx, q := r.stats.track(ctx, FindProviders)
+	in := r.IpfsRouting.FindProvidersAsync(qctx, c, count)
+
+	out := make(chan pstore.PeerInfo)
+	go func() {
+		defer close(out)
+		found := false
+		defer func() { q.done(found) }()
+
+		for pi := range in {
+			q.found()
+			found = true
+			select {
+			case out <- pi:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (r *trackedRouting) GetValue(ctx context.Context, key string, opts ...ropts.Option) ([]byte, error) {
+	qctx, q := r.stats.track(ctx, GetValue)
+	val, err := r.IpfsRouting.GetValue(qctx, key, opts...)
+	q.done(err == nil)
+	return val, err
+}
+
+func (r *trackedRouting) PutValue(ctx context.Context, key string, val []byte, opts ...ropts.Option) error {
+	qctx, q := r.stats.track(ctx, PutValue)
+	err := r.IpfsRouting.PutValue(qctx, key, val, opts...)
+	q.done(err == nil)
+	return err
+}
+
+func (r *trackedRouting) Provide(ctx context.Context, c *cid.Cid, announce bool) error {
+	qctx, q := r.stats.track(ctx, Provide)
+	err := r.IpfsRouting.Provide(qctx, c, announce)
+	q.done(err == nil)
+	return err
+}
+
+// searchingRouting is a trackedRouting of a valueSearcher.
+type searchingRouting struct {
+	*trackedRouting
+}
+
+func (r *searchingRouting) SearchValue(ctx context.Context, key string, opts ...ropts.Option) (<-chan []byte, error) {
+	qctx, q := r.stats.track(ctx, GetValue)
+	in, err := r.IpfsRouting.(valueSearcher).SearchValue(qctx, key, opts...)
+	if err != nil {
+		q.done(false)
+		return nil, err
+	}
+
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		found := false
+		defer func() { q.done(found) }()
+
+		for val := range in {
+			q.found()
+			found = true
+			select {
+			case out <- val:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// query measures a lookup.
+type query struct {
+	stats   *Stats
+	typ     string
+	ctx     context.Context // of the caller
+	cancel  func()
+	start   time.Time
+	latency time.Duration
+	hops    chan int
+}
+
+// track starts measuring a lookup, counting its hops from the query events
+// of the DHT. The events are passed on to the caller, if it
+// registered for them too.
+func (s *Stats) track(ctx context.Context, typ string) (context.Context, *query) {
+	q := &query{
+		stats: s,
+		typ:   typ,
+		ctx:   ctx,
+		start: s.now(),
+		hops:  make(chan int, 1),
+	}
+
+	events := make(chan *notif.QueryEvent)
+	qctx, cancel := context.WithCancel(ctx)
+	q.cancel = cancel
+	qctx = notif.RegisterForQueryEvents(qctx, events)
+
+	go func() {
+		var hops hopCounter
+		for {
+			select {
+			case ev := <-events:
+				if ev.Type == notif.PeerResponse {
+					hops.response(ev)
+				}
+				notif.PublishQueryEvent(ctx, ev)
+			case <-qctx.Done():
+				q.hops <- hops.max
+				return
+			}
+		}
+	}()
+	return qctx, q
+}
+
+// found records the latency of the lookup, when it found its first result.
+func (q *query) found() {
+	if q.latency == 0 {
+		q.latency = q.stats.now().Sub(q.start)
+	}
+}
+
+// done ends the lookup.
+func (q *query) done(ok bool) {
+	q.found()
+	q.cancel()
+	hops := <-q.hops
+	if !ok && q.ctx.Err() != nil {
+		return
+	}
+	q.stats.record(q.typ, ok, q.latency, hops)
+}
+
+// hopCounter counts the hops of a lookup: the peers first asked come from
+// the routing table, at one hop, and the ones they return as closer to the
+// key are one hop further.
+type hopCounter struct {
+	depth map[peer.ID]int
+	// max is the number of hops to the furthest peer which answered
+	max int
+}
+
+func (h *hopCounter) response(ev *notif.QueryEvent) {
+	if h.depth == nil {
+		h.depth = make(map[peer.ID]int)
+	}
+	d, ok := h.depth[ev.ID]
+	if !ok {
+		d = 1
+		h.depth[ev.ID] = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	for _, pi := range ev.Responses {
+		if _, ok := h.depth[pi.ID]; !ok {
+			h.depth[pi.ID] = d + 1
+		}
+	}
+}
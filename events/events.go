@@ -0,0 +1,216 @@
+// Package events implements a small in-process event bus used to expose
+// node activity (peer connections, block writes, pins, IPNS publishes, GC
+// runs) to monitoring and automation tools.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type identifies the kind of an Event.
+type Type string
+
+const (
+	// PeerConnected is emitted when a new connection to a peer is opened.
+	PeerConnected Type = "peer.connected"
+	// PeerDisconnected is emitted when a connection to a peer is closed.
+	PeerDisconnected Type = "peer.disconnected"
+	// BlockAdded is emitted when a block is written to the blockstore.
+	BlockAdded Type = "block.added"
+	// PinAdded is emitted when a pin operation completed.
+	PinAdded Type = "pin.added"
+	// NamePublished is emitted when an IPNS record has been published.
+	NamePublished Type = "name.published"
+	// GCStarted is emitted when a garbage collection run starts.
+	GCStarted Type = "gc.started"
+	// GCFinished is emitted when a garbage collection run finishes.
+	GCFinished Type = "gc.finished"
+	// ConfigReloaded is emitted when config changes were applied to the
+	// running node.
+	ConfigReloaded Type = "config.reloaded"
+	// PowerSaveChanged is emitted when the node enters or leaves power save.
+	PowerSaveChanged Type = "power.save"
+	// NodeSuspended is emitted when the node is suspended or resumed.
+	NodeSuspended Type = "node.suspended"
+	// FilesPublished is emitted when a new root of the MFS tree was saved.
+	FilesPublished Type = "files.published"
+	// DiskSpaceLow is emitted when the free space of the filesystem of the
+	// repo falls below Webhooks.LowDiskSpace.
+	DiskSpaceLow Type = "disk.low"
+	// PeersLow is emitted when the number of connected peers falls below
+	// Webhooks.LowPeers.
+	PeersLow Type = "peers.low"
+)
+
+// Types lists all event types known to the bus.
+var Types = []Type{
+	PeerConnected,
+	PeerDisconnected,
+	BlockAdded,
+	PinAdded,
+	NamePublished,
+	GCStarted,
+	GCFinished,
+	ConfigReloaded,
+	PowerSaveChanged,
+	NodeSuspended,
+	FilesPublished,
+	DiskSpaceLow,
+	PeersLow,
+}
+
+// DefaultBufferSize is the default number of events buffered for each
+// subscription before events start being dropped.
+const DefaultBufferSize = 128
+
+// Event is a single notification emitted on the bus.
+type Event struct {
+	Type Type
+	Time time.Time
+	Data map[string]string `json:",omitempty"`
+}
+
+// Bus dispatches events to subscribers. Emitting never blocks: if a
+// subscriber can't keep up, events destined to it are dropped and counted.
+//
+// A nil *Bus is valid and silently discards all events.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// NewBus returns a new, empty event bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives the events matching its filter.
+type Subscription struct {
+	bus   *Bus
+	out   chan Event
+	types map[Type]bool
+
+	mu      sync.Mutex
+	closed  bool
+	dropped uint64
+}
+
+// Subscribe registers a new subscription receiving events of the given
+// types, or all events if no types are given. bufSize controls how many
+// events may be queued before dropping; values <= 0 use DefaultBufferSize.
+func (b *Bus) Subscribe(bufSize int, types ...Type) *Subscription {
+	if bufSize <= 0 {
+		bufSize = DefaultBufferSize
+	}
+
+	s := &Subscription{
+		bus: b,
+		out: make(chan Event, bufSize),
+	}
+	if len(types) > 0 {
+		s.types = make(map[Type]bool, len(types))
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+
+	if b == nil {
+		close(s.out)
+		s.closed = true
+		return s
+	}
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// Emit publishes an event of type t with the given data to all interested
+// subscribers.
+func (b *Bus) Emit(t Type, data map[string]string) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.subs) == 0 {
+		return
+	}
+
+	ev := Event{Type: t, Time: time.Now(), Data: data}
+	for s := range b.subs {
+		s.send(ev)
+	}
+}
+
+// Close terminates all subscriptions.
+func (b *Bus) Close() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = make(map[*Subscription]struct{})
+	b.mu.Unlock()
+
+	for s := range subs {
+		s.close()
+	}
+	return nil
+}
+
+// Out returns the channel events are delivered on. It is closed when the
+// subscription is cancelled or the bus is closed.
+func (s *Subscription) Out() <-chan Event {
+	return s.out
+}
+
+// Dropped returns the number of events which were discarded because the
+// subscriber didn't consume them fast enough.
+func (s *Subscription) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Cancel unregisters the subscription and closes its output channel.
+func (s *Subscription) Cancel() {
+	if s.bus != nil {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		s.bus.mu.Unlock()
+	}
+	s.close()
+}
+
+func (s *Subscription) send(ev Event) {
+	if s.types != nil && !s.types[ev.Type] {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.out <- ev:
+	default:
+		s.dropped++
+	}
+}
+
+func (s *Subscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.out)
+}
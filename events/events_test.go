@@ -0,0 +1,77 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestSubscribeFilter(t *testing.T) {
+	b := NewBus()
+	all := b.Subscribe(0)
+	pins := b.Subscribe(0, PinAdded)
+
+	b.Emit(BlockAdded, map[string]string{"cid": "a"})
+	b.Emit(PinAdded, map[string]string{"cid": "b"})
+
+	if ev := <-all.Out(); ev.Type != BlockAdded || ev.Data["cid"] != "a" {
+		t.Fatalf("unexpected event: %v", ev)
+	}
+	if ev := <-all.Out(); ev.Type != PinAdded {
+		t.Fatalf("unexpected event: %v", ev)
+	}
+	if ev := <-pins.Out(); ev.Type != PinAdded || ev.Data["cid"] != "b" {
+		t.Fatalf("unexpected event: %v", ev)
+	}
+
+	select {
+	case ev := <-pins.Out():
+		t.Fatalf("filtered subscription got extra event: %v", ev)
+	default:
+	}
+}
+
+func TestSlowSubscriberDrops(t *testing.T) {
+	b := NewBus()
+	s := b.Subscribe(2)
+
+	for i := 0; i < 5; i++ {
+		b.Emit(BlockAdded, nil)
+	}
+
+	if d := s.Dropped(); d != 3 {
+		t.Fatalf("expected 3 dropped events, got %d", d)
+	}
+}
+
+func TestCancelAndClose(t *testing.T) {
+	b := NewBus()
+	s1 := b.Subscribe(0)
+	s2 := b.Subscribe(0)
+
+	s1.Cancel()
+	if _, ok := <-s1.Out(); ok {
+		t.Fatal("expected cancelled subscription to be closed")
+	}
+
+	// emitting after cancel must not panic
+	b.Emit(GCStarted, nil)
+	s1.Cancel()
+
+	b.Close()
+	if ev, ok := <-s2.Out(); !ok || ev.Type != GCStarted {
+		t.Fatalf("expected buffered event before close, got %v", ev)
+	}
+	if _, ok := <-s2.Out(); ok {
+		t.Fatal("expected subscription to be closed with the bus")
+	}
+}
+
+func TestNilBus(t *testing.T) {
+	var b *Bus
+	b.Emit(PinAdded, nil)
+
+	s := b.Subscribe(0)
+	if _, ok := <-s.Out(); ok {
+		t.Fatal("expected subscription on nil bus to be closed")
+	}
+	s.Cancel()
+}
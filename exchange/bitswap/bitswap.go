@@ -100,6 +100,7 @@ func New(parent context.Context, network bsnet.BitSwapNetwork,
 		provideKeys:   make(chan *cid.Cid, provideKeysBufferSize),
 		wm:            NewWantManager(ctx, network),
 		counters:      new(counters),
+		peerStats:     newPeerStats(),
 
 		dupMetric: dupHist,
 		allMetric: allHist,
@@ -156,6 +157,9 @@ type Bitswap struct {
 	counterLk sync.Mutex
 	counters  *counters
 
+	// peerStats ranks the peers by how fast they send the blocks
+	peerStats *peerStats
+
 	// Metrics interface metrics
 	dupMetric metrics.Histogram
 	allMetric metrics.Histogram
@@ -166,6 +170,40 @@ type Bitswap struct {
 
 	sessID   uint64
 	sessIDLk sync.Mutex
+
+	// fetcher fetches the wanted blocks in parallel with bitswap, if set
+	fetcher Fetcher
+
+	// served holds the func(*cid.Cid) called with the keys of the blocks
+	// sent to peers, if set
+	served atomic.Value
+}
+
+// Fetcher fetches blocks by other means than the bitswap protocol, in
+// parallel with it.
+type Fetcher interface {
+	// Fetch starts fetching the blocks of keys and returns immediately. It
+	// gives the blocks it fetches to bitswap with HasBlock, and gives up
+	// once ctx is done.
+	Fetch(ctx context.Context, keys []*cid.Cid)
+}
+
+// SetFetcher sets a Fetcher for the blocks bitswap wants. It must be called
+// before blocks are requested.
+func (bs *Bitswap) SetFetcher(f Fetcher) {
+	bs.fetcher = f
+}
+
+// SetServeObserver sets a function called with the keys of the blocks
+// bitswap sends to the peers which want them. f must not block.
+func (bs *Bitswap) SetServeObserver(f func(*cid.Cid)) {
+	bs.served.Store(f)
+}
+
+func (bs *Bitswap) fetchInParallel(ctx context.Context, keys []*cid.Cid) {
+	if bs.fetcher != nil {
+		bs.fetcher.Fetch(ctx, keys)
+	}
 }
 
 type counters struct {
@@ -229,6 +267,7 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []*cid.Cid) (<-chan block
 	mses := bs.getNextSessionID()
 
 	bs.wm.WantBlocks(ctx, keys, nil, mses)
+	bs.fetchInParallel(ctx, keys)
 
 	// NB: Optimization. Assumes that providers of key[0] are likely to
 	// be able to provide for all keys. This currently holds true in most
@@ -78,6 +78,11 @@ func (l *ledger) ReceivedBytes(n int) {
 
 func (l *ledger) Wants(k *cid.Cid, priority int) {
 	log.Debugf("peer %s wants %s", l.Partner, k)
+	if e, ok := l.wantList.Contains(k); ok {
+		// the peer changed its priority
+		e.Priority = priority
+		return
+	}
 	l.wantList.Add(k, priority)
 }
 
@@ -0,0 +1,233 @@
+package bitswap
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	lru "gx/ipfs/QmVYxfoJQiZijTgPNHCHgHELvQpbsJNTg6Crmc3dQkj3yy/golang-lru"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+)
+
+const (
+	// maxProviderCandidates is the number of providers looked up for a
+	// block, among which the fastest maxProvidersPerRequest are connected
+	// to.
+	maxProviderCandidates = 10
+	// sessionWantPeers is the number of the fastest active peers of a
+	// session its wants are sent to first.
+	sessionWantPeers = 8
+
+	// maxPeerStats is the number of peers whose history is kept.
+	maxPeerStats = 1024
+	// peerStatsAlpha is the weight of a new sample in the moving averages.
+	peerStatsAlpha = 0.2
+	// unknownPeerCost is the cost of the peers without history, ranked
+	// after the fast ones and before the slow ones.
+	unknownPeerCost = time.Second
+	// connectFailureCost is added to the cost of a peer for each of its
+	// failed connections in a row.
+	connectFailureCost = 5 * time.Second
+	// typicalBlockSize is the size the throughput of the peers is weighed
+	// for.
+	typicalBlockSize = 256 << 10
+)
+
+type peerRecord struct {
+	latency    time.Duration // moving average of the time wanted blocks took
+	throughput float64       // moving average, in bytes per second
+	failures   int           // failed connections in a row
+}
+
+// peerStats keeps the latency and throughput of the blocks received from each
+// peer, to try the fastest providers first.
+type peerStats struct {
+	mu    sync.Mutex
+	peers *lru.Cache // of *peerRecord, the least recently used forgotten
+}
+
+func newPeerStats() *peerStats {
+	cache, _ := lru.New(maxPeerStats)
+	return &peerStats{peers: cache}
+}
+
+// get returns the record of p, nil if there is none. It must be called with
+// the lock held.
+func (ps *peerStats) get(p peer.ID) *peerRecord {
+	r, ok := ps.peers.Get(p)
+	if !ok {
+		return nil
+	}
+	return r.(*peerRecord)
+}
+
+// record returns the record of p, creating it. It must be called with the
+// lock held.
+func (ps *peerStats) record(p peer.ID) *peerRecord {
+	r := ps.get(p)
+	if r == nil {
+		r = new(peerRecord)
+		ps.peers.Add(p, r)
+	}
+	return r
+}
+
+// blockReceived records a wanted block of size bytes received from p after
+// latency.
+func (ps *peerStats) blockReceived(p peer.ID, latency time.Duration, size int) {
+	if p == "" || latency <= 0 {
+		return
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	r := ps.record(p)
+	r.failures = 0
+	throughput := float64(size) / latency.Seconds()
+	if r.latency == 0 {
+		r.latency = latency
+		r.throughput = throughput
+		return
+	}
+	r.latency += time.Duration(peerStatsAlpha * float64(latency-r.latency))
+	r.throughput += peerStatsAlpha * (throughput - r.throughput)
+}
+
+// connectFailed records a failed connection to p.
+func (ps *peerStats) connectFailed(p peer.ID) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.record(p).failures++
+}
+
+// cost returns the expected time to get a typical block from p. It must be
+// called with the lock held.
+func (ps *peerStats) cost(p peer.ID) time.Duration {
+	r := ps.get(p)
+	if r == nil {
+		return unknownPeerCost
+	}
+	cost := unknownPeerCost
+	if r.latency > 0 {
+		cost = r.latency
+		if r.throughput > 0 {
+			cost += time.Duration(typicalBlockSize / r.throughput * float64(time.Second))
+		}
+	}
+	return cost + time.Duration(r.failures)*connectFailureCost
+}
+
+// rank returns peers sorted from the fastest, keeping the order of the ones
+// with the same cost.
+func (ps *peerStats) rank(peers []peer.ID) []peer.ID {
+	ps.mu.Lock()
+	costs := make(map[peer.ID]time.Duration, len(peers))
+	for _, p := range peers {
+		costs[p] = ps.cost(p)
+	}
+	ps.mu.Unlock()
+
+	out := make([]peer.ID, len(peers))
+	copy(out, peers)
+	sort.SliceStable(out, func(i, j int) bool {
+		return costs[out[i]] < costs[out[j]]
+	})
+	return out
+}
+
+// best returns the n fastest of peers.
+func (ps *peerStats) best(peers []peer.ID, n int) []peer.ID {
+	if len(peers) <= n {
+		return peers
+	}
+	return ps.rank(peers)[:n]
+}
+
+// connectToProviders connects to maxProvidersPerRequest of the providers
+// found, the fastest first: the providers found at once, or while the
+// connections to the previous ones are made, are ranked together, and the
+// next ones are only tried when the connections to the best ones fail.
+func (bs *Bitswap) connectToProviders(ctx context.Context, providers <-chan peer.ID) {
+	var candidates []peer.ID
+	connected := 0
+	for connected < maxProvidersPerRequest {
+		var open bool
+		candidates, open = collectProviders(ctx, providers, candidates)
+		if !open {
+			providers = nil
+		}
+		if len(candidates) == 0 || ctx.Err() != nil {
+			return
+		}
+
+		candidates = bs.peerStats.rank(candidates)
+		n := maxProvidersPerRequest - connected
+		if n > len(candidates) {
+			n = len(candidates)
+		}
+		connected += bs.connectAll(ctx, candidates[:n])
+		candidates = candidates[n:]
+	}
+}
+
+// collectProviders adds the providers found to candidates, up to
+// maxProviderCandidates. It waits for the first one when there are no
+// candidates, and takes the other ones already found without waiting. It
+// returns whether more providers may come.
+func collectProviders(ctx context.Context, providers <-chan peer.ID, candidates []peer.ID) ([]peer.ID, bool) {
+	if providers == nil {
+		return candidates, false
+	}
+
+	if len(candidates) == 0 {
+		select {
+		case p, ok := <-providers:
+			if !ok {
+				return candidates, false
+			}
+			candidates = append(candidates, p)
+		case <-ctx.Done():
+			return candidates, false
+		}
+	}
+
+	for len(candidates) < maxProviderCandidates {
+		select {
+		case p, ok := <-providers:
+			if !ok {
+				return candidates, false
+			}
+			candidates = append(candidates, p)
+		default:
+			return candidates, true
+		}
+	}
+	return candidates, true
+}
+
+// connectAll connects to peers in parallel, and returns the number of
+// connections made.
+func (bs *Bitswap) connectAll(ctx context.Context, peers []peer.ID) int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	connected := 0
+	for _, p := range peers {
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			if err := bs.network.ConnectTo(ctx, p); err != nil {
+				log.Debugf("failed to connect to provider %s: %s", p, err)
+				if ctx.Err() == nil {
+					bs.peerStats.connectFailed(p)
+				}
+				return
+			}
+			mu.Lock()
+			connected++
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+	return connected
+}
@@ -0,0 +1,67 @@
+package bitswap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+)
+
+func TestRankPeers(t *testing.T) {
+	ps := newPeerStats()
+	ps.blockReceived("slow", time.Second, 1<<10)
+	ps.blockReceived("fast", 20*time.Millisecond, 256<<10)
+	ps.blockReceived("failing", 20*time.Millisecond, 256<<10)
+	ps.connectFailed("failing")
+
+	out := ps.rank([]peer.ID{"unknown", "slow", "failing", "fast"})
+	expected := []peer.ID{"fast", "unknown", "failing", "slow"}
+	for i := range expected {
+		if out[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, out)
+		}
+	}
+
+	if best := ps.best(out, 2); len(best) != 2 || best[0] != "fast" {
+		t.Fatalf("expected the 2 fastest peers, got %v", best)
+	}
+
+	// a block received clears the failures
+	ps.blockReceived("failing", 20*time.Millisecond, 256<<10)
+	if out := ps.rank([]peer.ID{"unknown", "failing"}); out[0] != "failing" {
+		t.Fatalf("expected the peer to be fast again, got %v", out)
+	}
+}
+
+func TestCollectProviders(t *testing.T) {
+	ctx := context.Background()
+	providers := make(chan peer.ID, 2)
+	providers <- "a"
+	providers <- "b"
+
+	candidates, open := collectProviders(ctx, providers, nil)
+	if len(candidates) != 2 || !open {
+		t.Fatalf("expected the 2 providers found together, got %v", candidates)
+	}
+
+	// the first provider is waited for
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		providers <- "c"
+		close(providers)
+	}()
+	candidates, _ = collectProviders(ctx, providers, nil)
+	if len(candidates) != 1 || candidates[0] != "c" {
+		t.Fatalf("expected the last provider, got %v", candidates)
+	}
+	if _, open = collectProviders(ctx, providers, nil); open {
+		t.Fatal("expected no more providers")
+	}
+
+	// the candidates left are returned without waiting
+	candidates, open = collectProviders(ctx, make(chan peer.ID), []peer.ID{"d"})
+	if len(candidates) != 1 || !open {
+		t.Fatalf("expected the candidate left, got %v", candidates)
+	}
+}
@@ -0,0 +1,64 @@
+package bitswap
+
+import (
+	"context"
+)
+
+// FetchPriority is the class of the fetches made with a context. The blocks
+// of interactive fetches are asked for before the ones of background
+// fetches, and the peers serving them are the last connections to be closed.
+type FetchPriority int
+
+const (
+	// InteractiveFetch is the class of the fetches a user waits on, like
+	// gateway requests or 'ipfs cat'. It is the default.
+	InteractiveFetch FetchPriority = iota
+	// BackgroundFetch is the class of the fetches no one waits on, like the
+	// ones of the reprovider, which give way to the interactive ones.
+	BackgroundFetch
+)
+
+const (
+	// backgroundMaxPriority is the highest wantlist priority of background
+	// fetches, below which no interactive fetch ever goes.
+	backgroundMaxPriority = kMaxPriority / 2
+
+	interactiveTagValue = 10
+	backgroundTagValue  = 2
+
+	// maxBackgroundProviderQueries is the number of provider queries of
+	// background fetches made at the same time.
+	maxBackgroundProviderQueries = 2
+)
+
+type priorityContextKey struct{}
+
+// WithFetchPriority returns a context making the fetches made with it of the
+// given class.
+func WithFetchPriority(ctx context.Context, p FetchPriority) context.Context {
+	return context.WithValue(ctx, priorityContextKey{}, p)
+}
+
+// fetchPriority returns the class of the fetches made with ctx.
+func fetchPriority(ctx context.Context) FetchPriority {
+	p, _ := ctx.Value(priorityContextKey{}).(FetchPriority)
+	return p
+}
+
+// wantPriority returns the wantlist priority of the i-th block of a request
+// made with ctx.
+func wantPriority(ctx context.Context, i int) int {
+	if fetchPriority(ctx) == BackgroundFetch {
+		return backgroundMaxPriority - i
+	}
+	return kMaxPriority - i
+}
+
+// tagValue returns the value of the connections to the peers serving the
+// fetches made with ctx, for the connection manager.
+func tagValue(ctx context.Context) int {
+	if fetchPriority(ctx) == BackgroundFetch {
+		return backgroundTagValue
+	}
+	return interactiveTagValue
+}
@@ -0,0 +1,64 @@
+package bitswap
+
+import (
+	"context"
+	"testing"
+
+	bsmsg "github.com/ipfs/go-ipfs/exchange/bitswap/message"
+	wantlist "github.com/ipfs/go-ipfs/exchange/bitswap/wantlist"
+
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+)
+
+func TestWantPriority(t *testing.T) {
+	ctx := context.Background()
+	bg := WithFetchPriority(ctx, BackgroundFetch)
+
+	if fetchPriority(ctx) != InteractiveFetch {
+		t.Fatal("fetches should be interactive by default")
+	}
+	if wantPriority(bg, 0) >= wantPriority(ctx, 1000) {
+		t.Fatal("background wants should come after interactive ones")
+	}
+	if wantPriority(ctx, 0) <= wantPriority(ctx, 1) {
+		t.Fatal("wants should keep their order within a request")
+	}
+	if tagValue(bg) >= tagValue(ctx) {
+		t.Fatal("peers serving background fetches should be worth less")
+	}
+}
+
+func TestRaisedPriorityResent(t *testing.T) {
+	mq := &msgQueue{wl: wantlist.NewThreadSafe(), work: make(chan struct{}, 1)}
+	c := blocks.NewBlock([]byte("wanted")).Cid()
+
+	want := func(priority int, ses uint64) {
+		mq.addMessage([]*bsmsg.Entry{{Entry: wantlist.NewRefEntry(c, priority)}}, ses)
+	}
+	sent := func() (int, bool) {
+		out := mq.out
+		mq.out = nil
+		if out == nil {
+			return 0, false
+		}
+		for _, e := range out.Wantlist() {
+			if e.Cid.Equals(c) {
+				return e.Priority, true
+			}
+		}
+		return 0, false
+	}
+
+	want(5, 1)
+	if p, ok := sent(); !ok || p != 5 {
+		t.Fatalf("expected the want to be sent with priority 5, got %d (%t)", p, ok)
+	}
+	want(3, 2)
+	if _, ok := sent(); ok {
+		t.Fatal("a lower priority shouldn't be sent")
+	}
+	want(10, 3)
+	if p, ok := sent(); !ok || p != 10 {
+		t.Fatalf("expected the raised priority to be sent, got %d (%t)", p, ok)
+	}
+}
@@ -73,6 +73,10 @@ func (bs *Bitswap) NewSession(ctx context.Context) *Session {
 	cache, _ := lru.New(2048)
 	s.interest = cache
 
+	for _, p := range providersFromContext(ctx) {
+		s.addActivePeer(p)
+	}
+
 	bs.sessLk.Lock()
 	bs.sessions = append(bs.sessions, s)
 	bs.sessLk.Unlock()
@@ -82,6 +86,20 @@ func (bs *Bitswap) NewSession(ctx context.Context) *Session {
 	return s
 }
 
+type providersContextKey struct{}
+
+// WithProviders returns a context making the sessions created with it want
+// their blocks from the peers ps first, known to have them, rather than from
+// the providers found after the blocks don't come from the peers connected.
+func WithProviders(ctx context.Context, ps []peer.ID) context.Context {
+	return context.WithValue(ctx, providersContextKey{}, ps)
+}
+
+func providersFromContext(ctx context.Context) []peer.ID {
+	ps, _ := ctx.Value(providersContextKey{}).([]peer.ID)
+	return ps
+}
+
 func (bs *Bitswap) removeSession(s *Session) {
 	s.notif.Shutdown()
 
@@ -158,7 +176,7 @@ func (s *Session) addActivePeer(p peer.ID) {
 		s.activePeersArr = append(s.activePeersArr, p)
 
 		cmgr := s.bs.network.ConnectionManager()
-		cmgr.TagPeer(p, s.tag, 10)
+		cmgr.TagPeer(p, s.tag, tagValue(s.ctx))
 	}
 }
 
@@ -183,7 +201,7 @@ func (s *Session) run(ctx context.Context) {
 				s.addActivePeer(blk.from)
 			}
 
-			s.receiveBlock(ctx, blk.blk)
+			s.receiveBlock(ctx, blk.from, blk.blk)
 
 			s.resetTick()
 		case keys := <-s.newReqs:
@@ -258,13 +276,15 @@ func (s *Session) cidIsWanted(c *cid.Cid) bool {
 	return ok
 }
 
-func (s *Session) receiveBlock(ctx context.Context, blk blocks.Block) {
+func (s *Session) receiveBlock(ctx context.Context, from peer.ID, blk blocks.Block) {
 	c := blk.Cid()
 	if s.cidIsWanted(c) {
 		ks := c.KeyString()
 		tval, ok := s.liveWants[ks]
 		if ok {
-			s.latTotal += time.Since(tval)
+			lat := time.Since(tval)
+			s.latTotal += lat
+			s.bs.peerStats.blockReceived(from, lat, len(blk.RawData()))
 			delete(s.liveWants, ks)
 		} else {
 			s.tofetch.Remove(c)
@@ -283,7 +303,10 @@ func (s *Session) wantBlocks(ctx context.Context, ks []*cid.Cid) {
 	for _, c := range ks {
 		s.liveWants[c.KeyString()] = now
 	}
-	s.bs.wm.WantBlocks(ctx, ks, s.activePeersArr, s.id)
+	// the wants go to the fastest peers first, and to all the peers when
+	// the blocks don't come
+	s.bs.wm.WantBlocks(ctx, ks, s.bs.peerStats.best(s.activePeersArr, sessionWantPeers), s.id)
+	s.bs.fetchInParallel(ctx, ks)
 }
 
 func (s *Session) cancel(keys []*cid.Cid) {
@@ -8,6 +8,7 @@ import (
 
 	tu "gx/ipfs/QmPdxCaVp4jZ9RbxqZADvKH6kiCR5jHvdR5f2ycjAY6T2a/go-testutil"
 	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
 	blocksutil "gx/ipfs/QmYmE4kxv6uFGaWkeBAFYDuNcxzCn87pzwm6CkBkM9C8BM/go-ipfs-blocksutil"
 	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
 )
@@ -109,6 +110,62 @@ func TestSessionBetweenPeers(t *testing.T) {
 	}
 }
 
+func TestSessionWithProviders(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	vnet := getVirtualNetwork()
+	sesgen := NewTestSessionGenerator(vnet)
+	defer sesgen.Close()
+	bgen := blocksutil.NewBlockGenerator()
+
+	inst := sesgen.Instances(4)
+	a, hinted := inst[0], inst[1]
+
+	// nobody has it yet: the want only goes to the peer hinted
+	block := bgen.Next()
+	ses := a.Exchange.NewSession(WithProviders(ctx, []peer.ID{hinted.Peer}))
+	out := make(chan blocks.Block, 1)
+	go func() {
+		blk, err := ses.GetBlock(ctx, block.Cid())
+		if err != nil {
+			t.Error(err)
+		}
+		out <- blk
+	}()
+
+	wanted := func(i Instance) bool {
+		for _, c := range i.Exchange.WantlistForPeer(a.Peer) {
+			if c.Equals(block.Cid()) {
+				return true
+			}
+		}
+		return false
+	}
+	for start := time.Now(); !wanted(hinted); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("expected the peer hinted to be sent the want")
+		}
+	}
+	for _, i := range inst[2:] {
+		if wanted(i) {
+			t.Fatalf("expected only the peer hinted to be sent the want, %s was too", i.Peer)
+		}
+	}
+
+	if err := hinted.Exchange.HasBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case blk := <-out:
+		if blk == nil || !blk.Cid().Equals(block.Cid()) {
+			t.Fatal("got wrong block")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the block from the peer hinted")
+	}
+}
+
 func TestSessionSplitFetch(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -57,17 +57,28 @@ func New() *Wantlist {
 // by the session ID 'ses'.  if a cid is added under multiple session IDs, then
 // it must be removed by each of those sessions before it is no longer 'in the
 // wantlist'. Calls to Add are idempotent given the same arguments. Subsequent
-// calls with different values for priority will not update the priority
-// TODO: think through priority changes here
+// calls with a higher priority raise the priority, so that a block wanted in
+// the background is fetched sooner once it is wanted interactively.
 // Add returns true if the cid did not exist in the wantlist before this call
 // (even if it was under a different session)
 func (w *ThreadSafe) Add(c *cid.Cid, priority int, ses uint64) bool {
+	added, _ := w.AddOrRaise(c, priority, ses)
+	return added
+}
+
+// AddOrRaise is Add also returning whether the priority of a cid already in
+// the wantlist was raised, for the peers it was sent to to be told.
+func (w *ThreadSafe) AddOrRaise(c *cid.Cid, priority int, ses uint64) (added, raised bool) {
 	w.lk.Lock()
 	defer w.lk.Unlock()
 	k := c.KeyString()
 	if e, ok := w.set[k]; ok {
 		e.SesTrk[ses] = struct{}{}
-		return false
+		if priority > e.Priority {
+			e.Priority = priority
+			return false, true
+		}
+		return false, false
 	}
 
 	w.set[k] = &Entry{
@@ -76,7 +87,7 @@ func (w *ThreadSafe) Add(c *cid.Cid, priority int, ses uint64) bool {
 		SesTrk:   map[uint64]struct{}{ses: struct{}{}},
 	}
 
-	return true
+	return true, false
 }
 
 // AddEntry adds given Entry to the wantlist. For more information see Add method.
@@ -86,6 +97,9 @@ func (w *ThreadSafe) AddEntry(e *Entry, ses uint64) bool {
 	k := e.Cid.KeyString()
 	if ex, ok := w.set[k]; ok {
 		ex.SesTrk[ses] = struct{}{}
+		if e.Priority > ex.Priority {
+			ex.Priority = e.Priority
+		}
 		return false
 	}
 	w.set[k] = e
@@ -102,3 +102,32 @@ func TestSesRefWantlist(t *testing.T) {
 	}
 	assertNotHasCid(t, wl, testcids[0])
 }
+
+func TestRaisePriority(t *testing.T) {
+	wl := NewThreadSafe()
+
+	wl.Add(testcids[0], 5, 1)
+	wl.Add(testcids[0], 10, 2)
+	if e, _ := wl.Contains(testcids[0]); e.Priority != 10 {
+		t.Fatalf("expected priority 10, got %d", e.Priority)
+	}
+
+	wl.AddEntry(NewRefEntry(testcids[0], 3), 3)
+	if e, _ := wl.Contains(testcids[0]); e.Priority != 10 {
+		t.Fatalf("a lower priority shouldn't replace the priority, got %d", e.Priority)
+	}
+}
+
+func TestAddOrRaise(t *testing.T) {
+	wl := NewThreadSafe()
+
+	if added, raised := wl.AddOrRaise(testcids[0], 5, 1); !added || raised {
+		t.Fatalf("expected the cid to be added, got added %t, raised %t", added, raised)
+	}
+	if added, raised := wl.AddOrRaise(testcids[0], 3, 2); added || raised {
+		t.Fatalf("expected nothing to change, got added %t, raised %t", added, raised)
+	}
+	if added, raised := wl.AddOrRaise(testcids[0], 10, 2); added || !raised {
+		t.Fatalf("expected the priority to be raised, got added %t, raised %t", added, raised)
+	}
+}
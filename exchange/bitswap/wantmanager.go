@@ -98,7 +98,7 @@ func (pm *WantManager) addEntries(ctx context.Context, ks []*cid.Cid, targets []
 	for i, k := range ks {
 		entries = append(entries, &bsmsg.Entry{
 			Cancel: cancel,
-			Entry:  wantlist.NewRefEntry(k, kMaxPriority-i),
+			Entry:  wantlist.NewRefEntry(k, wantPriority(ctx, i)),
 		})
 	}
 	select {
@@ -391,9 +391,12 @@ func (mq *msgQueue) addMessage(entries []*bsmsg.Entry, ses uint64) {
 				mq.out.Cancel(e.Cid)
 			}
 		} else {
-			if mq.wl.Add(e.Cid, e.Priority, ses) {
+			// a raised priority is sent again, for the peer to serve
+			// the block sooner
+			if added, raised := mq.wl.AddOrRaise(e.Cid, e.Priority, ses); added || raised {
 				work = true
-				mq.out.AddEntry(e.Cid, e.Priority)
+				ent, _ := mq.wl.Contains(e.Cid)
+				mq.out.AddEntry(e.Cid, ent.Priority)
 			}
 		}
 	}
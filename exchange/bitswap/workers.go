@@ -10,7 +10,6 @@ import (
 
 	process "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
 	procctx "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess/context"
-	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
 	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
 	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
 )
@@ -74,6 +73,9 @@ func (bs *Bitswap) taskWorker(ctx context.Context, id int) {
 				bs.engine.MessageSent(envelope.Peer, outgoing)
 
 				bs.wm.SendBlock(ctx, envelope)
+				if served, ok := bs.served.Load().(func(*cid.Cid)); ok {
+					served(envelope.Block.Cid())
+				}
 				bs.counterLk.Lock()
 				bs.counters.blocksSent++
 				bs.counters.dataSent += uint64(len(envelope.Block.RawData()))
@@ -208,6 +210,10 @@ func (bs *Bitswap) providerQueryManager(ctx context.Context) {
 	var activeLk sync.Mutex
 	kset := cid.NewSet()
 
+	// background queries wait for a slot, so that they don't compete with
+	// the interactive ones for connections
+	background := make(chan struct{}, maxBackgroundProviderQueries)
+
 	for {
 		select {
 		case e := <-bs.findKeys:
@@ -226,24 +232,25 @@ func (bs *Bitswap) providerQueryManager(ctx context.Context) {
 			activeLk.Unlock()
 
 			go func(e *blockRequest) {
+				defer func() {
+					activeLk.Lock()
+					kset.Remove(e.Cid)
+					activeLk.Unlock()
+				}()
+
+				if fetchPriority(e.Ctx) == BackgroundFetch {
+					select {
+					case background <- struct{}{}:
+						defer func() { <-background }()
+					case <-e.Ctx.Done():
+						return
+					}
+				}
+
 				child, cancel := context.WithTimeout(e.Ctx, providerRequestTimeout)
 				defer cancel()
-				providers := bs.network.FindProvidersAsync(child, e.Cid, maxProvidersPerRequest)
-				wg := &sync.WaitGroup{}
-				for p := range providers {
-					wg.Add(1)
-					go func(p peer.ID) {
-						defer wg.Done()
-						err := bs.network.ConnectTo(child, p)
-						if err != nil {
-							log.Debug("failed to connect to provider %s: %s", p, err)
-						}
-					}(p)
-				}
-				wg.Wait()
-				activeLk.Lock()
-				kset.Remove(e.Cid)
-				activeLk.Unlock()
+				providers := bs.network.FindProvidersAsync(child, e.Cid, maxProviderCandidates)
+				bs.connectToProviders(child, providers)
 			}(e)
 
 		case <-ctx.Done():
@@ -0,0 +1,432 @@
+// Package httpfetch fetches blocks over plain HTTP from the providers which
+// advertise an HTTP multiaddr, like /dns4/cdn.example.com/tcp/443/https, in
+// parallel with bitswap.
+//
+// Blocks are requested from the gateway of the provider, as
+// <base>/ipfs/<cid>?format=raw, and their hash is checked against their CID
+// before they are used, so the providers don't need to be trusted. When a
+// single block is wanted, usually the root of a DAG, the whole DAG is first
+// requested as a CAR file, <base>/ipfs/<cid>?format=car, and only its blocks
+// linked from the root are kept.
+//
+// Only the servers on public addresses are used, so that the providers can't
+// have the node make requests to its local network.
+package httpfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-ipfs/car"
+
+	manet "gx/ipfs/QmNqRnejxJxjRroz7buhrjfU8i3yNBLa81hFtmf2pXEffN/go-multiaddr-net"
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	routing "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing"
+	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
+)
+
+var log = logging.Logger("httpfetch")
+
+const (
+	// maxBlockSize is the size of the largest block accepted, the one bitswap
+	// accepts.
+	maxBlockSize = 2 << 20
+	// maxCarSize is the size of the largest CAR file read; the blocks read
+	// before it is reached are kept.
+	maxCarSize = 256 << 20
+
+	maxProviders     = 10
+	providerTimeout  = 10 * time.Second
+	requestTimeout   = 30 * time.Second
+	maxRecentServers = 8
+	defaultWorkers   = 16
+)
+
+// Fetcher fetches blocks from HTTP providers. It implements bitswap.Fetcher.
+type Fetcher struct {
+	routing routing.ContentRouting
+	put     func(blocks.Block) error
+	client  *http.Client
+	// allowPrivate allows the servers on private addresses, for tests
+	allowPrivate bool
+
+	// workers limits the number of requests made at the same time
+	workers chan struct{}
+
+	mu       sync.Mutex
+	inflight map[string]struct{}
+	// recent are the servers which recently served blocks, most recent
+	// first. They are tried before looking for providers, as the blocks of a
+	// DAG are usually provided by the same servers.
+	recent []string
+}
+
+// New returns a Fetcher looking for providers with r, and giving the blocks
+// it fetches to put.
+func New(r routing.ContentRouting, put func(blocks.Block) error) *Fetcher {
+	return &Fetcher{
+		routing:  r,
+		put:      put,
+		client:   newClient(),
+		workers:  make(chan struct{}, defaultWorkers),
+		inflight: make(map[string]struct{}),
+	}
+}
+
+// Fetch starts fetching the blocks of keys which aren't being fetched yet.
+// As with bitswap, the providers of the first key are assumed to provide the
+// others.
+func (f *Fetcher) Fetch(ctx context.Context, keys []*cid.Cid) {
+	var todo []*cid.Cid
+	f.mu.Lock()
+	for _, k := range keys {
+		ks := k.KeyString()
+		if _, ok := f.inflight[ks]; ok {
+			continue
+		}
+		f.inflight[ks] = struct{}{}
+		todo = append(todo, k)
+	}
+	f.mu.Unlock()
+
+	if len(todo) > 0 {
+		go f.fetchAll(ctx, todo)
+	}
+}
+
+func (f *Fetcher) fetchAll(ctx context.Context, keys []*cid.Cid) {
+	defer func() {
+		f.mu.Lock()
+		for _, k := range keys {
+			delete(f.inflight, k.KeyString())
+		}
+		f.mu.Unlock()
+	}()
+
+	var found []string
+	var once sync.Once
+	providers := func() []string {
+		once.Do(func() {
+			found = f.findServers(ctx, keys[0])
+		})
+		return found
+	}
+
+	var wg sync.WaitGroup
+	for _, k := range keys {
+		select {
+		case f.workers <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+
+		wg.Add(1)
+		go func(k *cid.Cid) {
+			defer func() {
+				<-f.workers
+				wg.Done()
+			}()
+
+			fetch := f.fetchFrom
+			if len(keys) == 1 {
+				fetch = f.fetchDAGFrom
+			}
+			if fetch(ctx, k, f.recentServers()) {
+				return
+			}
+			fetch(ctx, k, providers())
+		}(k)
+	}
+	wg.Wait()
+}
+
+// fetchFrom tries to fetch the block of k from each server in turn, and
+// returns whether it did.
+func (f *Fetcher) fetchFrom(ctx context.Context, k *cid.Cid, servers []string) bool {
+	for _, s := range servers {
+		if ctx.Err() != nil {
+			return false
+		}
+
+		blk, err := f.get(ctx, s, k)
+		if err != nil {
+			log.Debugf("failed to fetch %s from %s: %s", k, s, err)
+			continue
+		}
+		f.markRecent(s)
+
+		if err := f.put(blk); err != nil {
+			log.Errorf("failed to store %s fetched from %s: %s", k, s, err)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// fetchDAGFrom tries to fetch the DAG of k as a CAR file from each server in
+// turn, and falls back to fetching the block of k alone. It returns whether
+// it fetched the block of k.
+func (f *Fetcher) fetchDAGFrom(ctx context.Context, k *cid.Cid, servers []string) bool {
+	for _, s := range servers {
+		if ctx.Err() != nil {
+			return false
+		}
+
+		err := f.getCar(ctx, s, k)
+		if err == nil {
+			f.markRecent(s)
+			return true
+		}
+		log.Debugf("failed to fetch the car of %s from %s: %s", k, s, err)
+	}
+	return f.fetchFrom(ctx, k, servers)
+}
+
+// errNoRoot is returned when a CAR file doesn't have the block of its root.
+var errNoRoot = errors.New("car file doesn't have the block of the root")
+
+// getCar fetches the CAR file of the DAG of root from the server and puts the
+// blocks of the DAG it has. Each block must follow a block linking to it, the
+// first one being the block of root, and its hash is checked.
+func (f *Fetcher) getCar(ctx context.Context, server string, root *cid.Cid) error {
+	req, err := http.NewRequest("GET", server+"/ipfs/"+root.String()+"?format=car", nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	cr, err := car.NewReader(io.LimitReader(resp.Body, maxCarSize))
+	if err != nil {
+		return err
+	}
+
+	checker := car.NewChecker()
+	linked := map[string]bool{root.KeyString(): true}
+	gotRoot := false
+	for {
+		b, err := cr.Next()
+		if err != nil {
+			if gotRoot {
+				// the DAG may be incomplete, bitswap fetches the rest
+				return nil
+			}
+			if err == io.EOF {
+				return errNoRoot
+			}
+			return err
+		}
+
+		key := b.Cid().KeyString()
+		if !linked[key] || checker.Has(b.Cid()) {
+			continue
+		}
+		if err := checker.Add(b); err != nil {
+			log.Debugf("invalid block %s in the car of %s from %s: %s", b.Cid(), root, server, err)
+			if !gotRoot {
+				return err
+			}
+			continue
+		}
+		for _, l := range checker.Links(b.Cid()) {
+			linked[l.KeyString()] = true
+		}
+
+		if err := f.put(b); err != nil {
+			return err
+		}
+		gotRoot = true
+	}
+}
+
+// get fetches the block of k from the server and checks its hash.
+func (f *Fetcher) get(ctx context.Context, server string, k *cid.Cid) (blocks.Block, error) {
+	req, err := http.NewRequest("GET", server+"/ipfs/"+k.String()+"?format=raw", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBlockSize {
+		return nil, fmt.Errorf("block larger than %d bytes", maxBlockSize)
+	}
+
+	return verifyBlock(data, k)
+}
+
+// verifyBlock returns the block of data if its hash matches k.
+func verifyBlock(data []byte, k *cid.Cid) (blocks.Block, error) {
+	c, err := k.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !c.Equals(k) {
+		return nil, fmt.Errorf("hash mismatch: got block %s", c)
+	}
+	return blocks.NewBlockWithCid(data, k)
+}
+
+// findServers returns the base URLs of the providers of k which advertise
+// an HTTP multiaddr on a public address or a domain name.
+func (f *Fetcher) findServers(ctx context.Context, k *cid.Cid) []string {
+	ctx, cancel := context.WithTimeout(ctx, providerTimeout)
+	defer cancel()
+
+	var servers []string
+	seen := make(map[string]bool)
+	for pi := range f.routing.FindProvidersAsync(ctx, k, maxProviders) {
+		for _, a := range pi.Addrs {
+			if !f.allowPrivate && isIPAddr(a) && !manet.IsPublicAddr(a) {
+				continue
+			}
+			u, ok := ServerURL(a)
+			if !ok || seen[u] {
+				continue
+			}
+			seen[u] = true
+			servers = append(servers, u)
+		}
+	}
+	return servers
+}
+
+// isIPAddr returns whether a starts with an IP address, rather than a domain
+// name.
+func isIPAddr(a ma.Multiaddr) bool {
+	p := ma.Split(a)[0].Protocols()[0].Code
+	return p == ma.P_IP4 || p == ma.P_IP6
+}
+
+// errPrivateAddr is returned when a server resolves to no public address.
+var errPrivateAddr = errors.New("server has no public address")
+
+// newClient returns the HTTP client of the fetcher, which only connects to
+// public addresses, whatever the domain names of the servers resolve to, and
+// whatever server they redirect to.
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: requestTimeout}
+	return &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				host, port, err := net.SplitHostPort(addr)
+				if err != nil {
+					return nil, err
+				}
+				ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+				if err != nil {
+					return nil, err
+				}
+				for _, ip := range ips {
+					if !isPublicIP(ip.IP) {
+						continue
+					}
+					return dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+				}
+				return nil, errPrivateAddr
+			},
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+func isPublicIP(ip net.IP) bool {
+	a, err := manet.FromIP(ip)
+	if err != nil {
+		return false
+	}
+	return manet.IsPublicAddr(a)
+}
+
+func (f *Fetcher) recentServers() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.recent...)
+}
+
+func (f *Fetcher) markRecent(server string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	recent := []string{server}
+	for _, s := range f.recent {
+		if s != server && len(recent) < maxRecentServers {
+			recent = append(recent, s)
+		}
+	}
+	f.recent = recent
+}
+
+// ServerURL returns the base URL of an HTTP multiaddr, like
+// https://cdn.example.com:443 for /dns4/cdn.example.com/tcp/443/https. It
+// returns false if a isn't an HTTP multiaddr.
+func ServerURL(a ma.Multiaddr) (string, bool) {
+	var host, port, scheme string
+	for _, c := range ma.Split(a) {
+		p := c.Protocols()[0]
+		switch p.Name {
+		case "ip4", "ip6", "dns4", "dns6", "dns":
+			v, err := c.ValueForProtocol(p.Code)
+			if err != nil || host != "" {
+				return "", false
+			}
+			host = v
+		case "tcp":
+			v, err := c.ValueForProtocol(p.Code)
+			if err != nil || port != "" {
+				return "", false
+			}
+			port = v
+		case "http", "https":
+			scheme = p.Name
+		default:
+			return "", false
+		}
+	}
+
+	if host == "" || scheme == "" {
+		return "", false
+	}
+	if port == "" {
+		if scheme == "https" {
+			port = "443"
+		} else {
+			port = "80"
+		}
+	}
+	return scheme + "://" + net.JoinHostPort(host, port), true
+}
@@ -0,0 +1,234 @@
+package httpfetch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-ipfs/car"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	routing "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing"
+	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+type providers []pstore.PeerInfo
+
+func (p providers) Provide(context.Context, *cid.Cid, bool) error {
+	return nil
+}
+
+func (p providers) FindProvidersAsync(ctx context.Context, k *cid.Cid, max int) <-chan pstore.PeerInfo {
+	out := make(chan pstore.PeerInfo, len(p))
+	for _, pi := range p {
+		out <- pi
+	}
+	close(out)
+	return out
+}
+
+func TestServerURL(t *testing.T) {
+	for _, tc := range []struct {
+		addr string
+		url  string
+	}{
+		{"/ip4/1.2.3.4/tcp/8080/http", "http://1.2.3.4:8080"},
+		{"/ip6/::1/tcp/8080/http", "http://[::1]:8080"},
+		{"/dns4/cdn.example.com/tcp/443/https", "https://cdn.example.com:443"},
+		{"/dns4/cdn.example.com/https", "https://cdn.example.com:443"},
+		{"/ip4/1.2.3.4/tcp/4001", ""},
+		{"/ip4/1.2.3.4/tcp/4001/ws", ""},
+	} {
+		a, err := ma.NewMultiaddr(tc.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, ok := ServerURL(a)
+		if ok != (tc.url != "") || u != tc.url {
+			t.Errorf("%s: expected %q, got %q (%t)", tc.addr, tc.url, u, ok)
+		}
+	}
+}
+
+func serve(t *testing.T, blks map[string]blocks.Block) (*httptest.Server, ma.Multiaddr) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "raw" {
+			http.Error(w, "not raw", http.StatusBadRequest)
+			return
+		}
+		blk, ok := blks[strings.TrimPrefix(r.URL.Path, "/ipfs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(blk.RawData())
+	}))
+
+	_, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := ma.NewMultiaddr(fmt.Sprintf("/ip4/127.0.0.1/tcp/%s/http", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts, a
+}
+
+// newTestFetcher returns a Fetcher using the test servers, which listen on
+// the loopback address.
+func newTestFetcher(r routing.ContentRouting, put func(blocks.Block) error) *Fetcher {
+	f := New(r, put)
+	f.allowPrivate = true
+	f.client = &http.Client{Timeout: requestTimeout}
+	return f
+}
+
+func TestFetch(t *testing.T) {
+	good := blocks.NewBlock([]byte("good"))
+	bad := blocks.NewBlock([]byte("bad"))
+
+	ts, a := serve(t, map[string]blocks.Block{
+		good.Cid().String(): good,
+		// served with the data of another block
+		bad.Cid().String(): good,
+	})
+	defer ts.Close()
+
+	got := make(chan blocks.Block, 2)
+	f := newTestFetcher(providers{{Addrs: []ma.Multiaddr{a}}}, func(b blocks.Block) error {
+		got <- b
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	f.Fetch(ctx, []*cid.Cid{good.Cid(), bad.Cid()})
+
+	select {
+	case b := <-got:
+		if !b.Cid().Equals(good.Cid()) {
+			t.Fatalf("got unexpected block %s", b.Cid())
+		}
+	case <-ctx.Done():
+		t.Fatal("block wasn't fetched")
+	}
+
+	select {
+	case b := <-got:
+		t.Fatalf("block %s with the wrong data was accepted", b.Cid())
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if recent := f.recentServers(); len(recent) != 1 || recent[0] != ts.URL {
+		t.Errorf("expected %s to be a recent server, got %v", ts.URL, recent)
+	}
+}
+
+func TestFindServersSkipsPrivateAddrs(t *testing.T) {
+	var addrs []ma.Multiaddr
+	for _, s := range []string{
+		"/ip4/127.0.0.1/tcp/8080/http",
+		"/ip4/192.168.1.1/tcp/80/http",
+		"/ip4/8.8.8.8/tcp/80/http",
+		"/dns4/cdn.example.com/tcp/443/https",
+	} {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, a)
+	}
+
+	f := New(providers{{Addrs: addrs}}, func(blocks.Block) error { return nil })
+	servers := f.findServers(context.Background(), blocks.NewBlock([]byte("foo")).Cid())
+
+	expected := []string{"http://8.8.8.8:80", "https://cdn.example.com:443"}
+	if len(servers) != len(expected) || servers[0] != expected[0] || servers[1] != expected[1] {
+		t.Fatalf("expected the servers %v, got %v", expected, servers)
+	}
+}
+
+func TestClientRefusesPrivateAddrs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	_, err := newClient().Get(ts.URL)
+	if err == nil || !strings.Contains(err.Error(), errPrivateAddr.Error()) {
+		t.Fatalf("expected the request to a loopback address to fail, got %v", err)
+	}
+}
+
+func TestFetchCar(t *testing.T) {
+	child := dag.NodeWithData([]byte("child"))
+	root := dag.NodeWithData([]byte("root"))
+	if err := root.AddNodeLink("child", child); err != nil {
+		t.Fatal(err)
+	}
+	unrelated := blocks.NewBlock([]byte("unrelated"))
+
+	carData := new(bytes.Buffer)
+	cw, err := car.NewWriter(carData, []*cid.Cid{root.Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range []blocks.Block{root, unrelated, child} {
+		if err := cw.Put(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ipfs/"+root.Cid().String() || r.URL.Query().Get("format") != "car" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(carData.Bytes())
+	}))
+	defer ts.Close()
+
+	_, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := ma.NewMultiaddr(fmt.Sprintf("/ip4/127.0.0.1/tcp/%s/http", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan blocks.Block, 3)
+	f := newTestFetcher(providers{{Addrs: []ma.Multiaddr{a}}}, func(b blocks.Block) error {
+		got <- b
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	f.Fetch(ctx, []*cid.Cid{root.Cid()})
+
+	for _, exp := range []*cid.Cid{root.Cid(), child.Cid()} {
+		select {
+		case b := <-got:
+			if !b.Cid().Equals(exp) {
+				t.Fatalf("expected block %s, got %s", exp, b.Cid())
+			}
+		case <-ctx.Done():
+			t.Fatalf("block %s wasn't fetched", exp)
+		}
+	}
+
+	select {
+	case b := <-got:
+		t.Fatalf("block %s not linked from the root was accepted", b.Cid())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
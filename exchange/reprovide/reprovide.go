@@ -2,7 +2,10 @@ package reprovide
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ipfs/go-ipfs/thirdparty/verifcid"
@@ -15,6 +18,9 @@ import (
 
 var log = logging.Logger("reprovider")
 
+// DefaultRecordTTL is how long the DHT keeps provider records.
+const DefaultRecordTTL = 24 * time.Hour
+
 //KeyChanFunc is function streaming CIDs to pass to content routing
 type KeyChanFunc func(context.Context) (<-chan *cid.Cid, error)
 type doneFunc func(error)
@@ -27,6 +33,38 @@ type Reprovider struct {
 	rsys routing.ContentRouting
 
 	keyProvider KeyChanFunc
+
+	// Spread makes Run spread the provides of each reprovide over the
+	// interval rather than making them all at once.
+	Spread bool
+
+	// RecordTTL is how long the routing system keeps provider records. The
+	// spread reprovides end within it, whatever the interval, for the
+	// records not to expire before they're announced again.
+	RecordTTL time.Duration
+
+	reqlk     sync.Mutex
+	requested map[string]struct{}
+
+	// paused is set while the reprovides scheduled by Run are paused
+	paused int32
+}
+
+var errPaused = errors.New("reprovider paused")
+
+// SetPaused pauses or resumes the reprovides scheduled by Run. A reprovide
+// running when paused stops. Reprovides triggered by users still run.
+func (rp *Reprovider) SetPaused(paused bool) {
+	var v int32
+	if paused {
+		v = 1
+	}
+	atomic.StoreInt32(&rp.paused, v)
+}
+
+// Paused returns whether the reprovides scheduled by Run are paused.
+func (rp *Reprovider) Paused() bool {
+	return atomic.LoadInt32(&rp.paused) == 1
 }
 
 // NewReprovider creates new Reprovider instance.
@@ -37,6 +75,9 @@ func NewReprovider(ctx context.Context, rsys routing.ContentRouting, keyProvider
 
 		rsys:        rsys,
 		keyProvider: keyProvider,
+		RecordTTL:   DefaultRecordTTL,
+
+		requested: make(map[string]struct{}),
 	}
 }
 
@@ -46,65 +87,181 @@ func (rp *Reprovider) Run(tick time.Duration) {
 	// may have just started the daemon and shutting it down immediately.
 	// probability( up another minute | uptime ) increases with uptime.
 	after := time.After(time.Minute)
-	var done doneFunc
 	for {
 		if tick == 0 {
 			after = make(chan time.Time)
 		}
 
+		var done doneFunc
 		select {
 		case <-rp.ctx.Done():
 			return
 		case done = <-rp.trigger:
 		case <-after:
+			// checked again every minute, to resume soon after being
+			// unpaused
+			if rp.Paused() {
+				after = time.After(time.Minute)
+				continue
+			}
 		}
+		start := time.Now()
 
-		//'mute' the trigger channel so when `ipfs bitswap reprovide` is called
-		//a 'reprovider is already running' error is returned
-		unmute := rp.muteTrigger()
+		// scheduled reprovides are spread over the window. One triggered
+		// by a user meanwhile stops it, for a full reprovide made at once
+		// which the user waits on.
+		var err error
+		if rp.Spread && done == nil {
+			done, err = rp.reprovideSpread(rp.window(tick))
+		}
+		if done != nil || !rp.Spread {
+			//'mute' the trigger channel so when `ipfs bitswap reprovide` is called
+			//a 'reprovider is already running' error is returned
+			unmute := rp.muteTrigger()
 
-		err := rp.Reprovide()
+			err = rp.reprovide(rp.ctx, 0, done == nil)
+			if done != nil {
+				done(err)
+			}
+
+			unmute()
+		}
 		if err != nil {
 			log.Debug(err)
 		}
 
-		if done != nil {
-			done(err)
+		if err == errPaused {
+			after = time.After(time.Minute)
+		} else if rp.Spread {
+			after = time.After(tick - time.Since(start))
+		} else {
+			after = time.After(tick)
 		}
+	}
+}
 
-		unmute()
+// window returns the window the provides of a scheduled reprovide are spread
+// over: the interval, or the TTL of the records when shorter, ending a tenth
+// of it early so that the next reprovide starts on time.
+func (rp *Reprovider) window(tick time.Duration) time.Duration {
+	w := tick
+	if rp.RecordTTL > 0 && rp.RecordTTL < w {
+		w = rp.RecordTTL
+	}
+	return w - w/10
+}
 
-		after = time.After(tick)
+// reprovideSpread makes a scheduled reprovide spread over window. A reprovide
+// triggered meanwhile stops it, and its doneFunc is returned for the caller
+// to make it at once.
+func (rp *Reprovider) reprovideSpread(window time.Duration) (doneFunc, error) {
+	ctx, cancel := context.WithCancel(rp.ctx)
+	defer cancel()
+
+	var done doneFunc
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		select {
+		case <-ctx.Done():
+		case done = <-rp.trigger:
+			cancel()
+		}
+	}()
+
+	err := rp.reprovide(ctx, window, true)
+	cancel()
+	<-watched
+	if done != nil {
+		return done, nil
 	}
+	return nil, err
 }
 
-// Reprovide registers all keys given by rp.keyProvider to libp2p content routing
+// Reprovide registers all keys given by rp.keyProvider to libp2p content
+// routing, the ones which were requested since the previous reprovide first.
 func (rp *Reprovider) Reprovide() error {
-	keychan, err := rp.keyProvider(rp.ctx)
+	return rp.reprovide(rp.ctx, 0, false)
+}
+
+// reprovide reprovides the keys, spread evenly over window, until ctx is
+// done. Scheduled reprovides stop when the reprovider is paused.
+func (rp *Reprovider) reprovide(ctx context.Context, window time.Duration, scheduled bool) error {
+	requested := rp.takeRequested()
+
+	// the keys are listed a first time to count them, and to find the
+	// requested ones among them
+	var hot []*cid.Cid
+	count := 0
+	if window > 0 || len(requested) > 0 {
+		keychan, err := rp.keyProvider(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get key chan: %s", err)
+		}
+		for c := range keychan {
+			count++
+			if _, ok := requested[c.KeyString()]; ok {
+				hot = append(hot, c)
+			}
+		}
+	}
+
+	p := newPacer(window, count)
+	provide := func(c *cid.Cid) error {
+		if scheduled && rp.Paused() {
+			return errPaused
+		}
+		return rp.provide(ctx, c, p)
+	}
+
+	provided := make(map[string]struct{}, len(hot))
+	for _, c := range hot {
+		if err := provide(c); err != nil {
+			return err
+		}
+		provided[c.KeyString()] = struct{}{}
+	}
+
+	keychan, err := rp.keyProvider(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get key chan: %s", err)
 	}
 	for c := range keychan {
-		// hash security
-		if err := verifcid.ValidateCid(c); err != nil {
-			log.Errorf("insecure hash in reprovider, %s (%s)", c, err)
+		if _, ok := provided[c.KeyString()]; ok {
 			continue
 		}
-		op := func() error {
-			err := rp.rsys.Provide(rp.ctx, c, true)
-			if err != nil {
-				log.Debugf("Failed to provide key: %s", err)
-			}
+		if err := provide(c); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		// TODO: this backoff library does not respect our context, we should
-		// eventually work contexts into it. low priority.
-		err := backoff.Retry(op, backoff.NewExponentialBackOff())
+func (rp *Reprovider) provide(ctx context.Context, c *cid.Cid, p *pacer) error {
+	// hash security
+	if err := verifcid.ValidateCid(c); err != nil {
+		log.Errorf("insecure hash in reprovider, %s (%s)", c, err)
+		return nil
+	}
+
+	if err := p.wait(ctx); err != nil {
+		return err
+	}
+
+	op := func() error {
+		err := rp.rsys.Provide(ctx, c, true)
 		if err != nil {
-			log.Debugf("Providing failed after number of retries: %s", err)
-			return err
+			log.Debugf("Failed to provide key: %s", err)
 		}
+		return err
+	}
+
+	// TODO: this backoff library does not respect our context, we should
+	// eventually work contexts into it. low priority.
+	err := backoff.Retry(op, backoff.NewExponentialBackOff())
+	if err != nil {
+		log.Debugf("Providing failed after number of retries: %s", err)
+		return err
 	}
 	return nil
 }
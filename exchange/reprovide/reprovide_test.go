@@ -2,11 +2,14 @@ package reprovide_test
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 
 	testutil "gx/ipfs/QmPdxCaVp4jZ9RbxqZADvKH6kiCR5jHvdR5f2ycjAY6T2a/go-testutil"
 	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
 	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
 	mock "gx/ipfs/Qmb1N7zdjG2FexpzWNj8T289u9QnQLEiSsTRadDGQxX32D/go-ipfs-routing/mock"
 	blockstore "gx/ipfs/QmbaPGg81pvQiC5vTXtC9Jo8rdrWUjRaugH71WYNsgi6Ev/go-ipfs-blockstore"
 	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
@@ -55,3 +58,72 @@ func TestReprovide(t *testing.T) {
 		t.Fatal("Somehow got the wrong peer back as a provider.")
 	}
 }
+
+type recordingRouting struct {
+	mu       sync.Mutex
+	provided []*cid.Cid
+}
+
+func (r *recordingRouting) Provide(ctx context.Context, c *cid.Cid, announce bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.provided = append(r.provided, c)
+	return nil
+}
+
+func (r *recordingRouting) FindProvidersAsync(ctx context.Context, c *cid.Cid, n int) <-chan pstore.PeerInfo {
+	ch := make(chan pstore.PeerInfo)
+	close(ch)
+	return ch
+}
+
+func TestReprovideRequestedFirst(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var keys []*cid.Cid
+	for i := 0; i < 5; i++ {
+		keys = append(keys, blocks.NewBlock([]byte(fmt.Sprint("block ", i))).Cid())
+	}
+	keyProvider := func(ctx context.Context) (<-chan *cid.Cid, error) {
+		ch := make(chan *cid.Cid, len(keys))
+		for _, k := range keys {
+			ch <- k
+		}
+		close(ch)
+		return ch, nil
+	}
+
+	r := new(recordingRouting)
+	reprov := NewReprovider(ctx, r, keyProvider)
+
+	// a key which isn't one of the reprovider's isn't announced
+	reprov.Requested(blocks.NewBlock([]byte("not ours")).Cid())
+	reprov.Requested(keys[3])
+
+	if err := reprov.Reprovide(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := append([]*cid.Cid{keys[3]}, keys[:3]...)
+	expected = append(expected, keys[4])
+	if len(r.provided) != len(expected) {
+		t.Fatalf("expected %d provides, got %d", len(expected), len(r.provided))
+	}
+	for i, c := range expected {
+		if !r.provided[i].Equals(c) {
+			t.Fatalf("provide %d: expected %s, got %s", i, c, r.provided[i])
+		}
+	}
+
+	// the requested keys are forgotten once reprovided
+	r.provided = nil
+	if err := reprov.Reprovide(); err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range keys {
+		if !r.provided[i].Equals(c) {
+			t.Fatalf("provide %d: expected %s, got %s", i, c, r.provided[i])
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package reprovide
+
+import (
+	"context"
+	"time"
+
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// maxRequested is the number of requested keys remembered between two
+// reprovides. Keys requested once that many are remembered are ignored.
+const maxRequested = 4096
+
+// Requested tells the reprovider that a peer was sent c, so that the next
+// reprovide announces it before the other keys if it is one of them.
+func (rp *Reprovider) Requested(c *cid.Cid) {
+	rp.reqlk.Lock()
+	defer rp.reqlk.Unlock()
+	if len(rp.requested) < maxRequested {
+		rp.requested[c.KeyString()] = struct{}{}
+	}
+}
+
+// takeRequested returns the keys requested since the previous call.
+func (rp *Reprovider) takeRequested() map[string]struct{} {
+	rp.reqlk.Lock()
+	defer rp.reqlk.Unlock()
+	requested := rp.requested
+	rp.requested = make(map[string]struct{})
+	return requested
+}
+
+// pacer spreads a number of provides evenly over a window of time, so that
+// each record is refreshed about one interval after the previous time,
+// rather than all of them at once at the start of the interval.
+type pacer struct {
+	start time.Time
+	step  time.Duration
+	i     int
+}
+
+// newPacer returns a pacer for n provides over window. A zero window makes
+// a pacer which doesn't wait.
+func newPacer(window time.Duration, n int) *pacer {
+	p := &pacer{start: time.Now()}
+	if window > 0 && n > 0 {
+		p.step = window / time.Duration(n)
+	}
+	return p
+}
+
+// wait waits until the next provide is due. Provides which fall behind the
+// schedule, e.g. because the DHT is slow, aren't delayed.
+func (p *pacer) wait(ctx context.Context) error {
+	if p.step == 0 {
+		return nil
+	}
+
+	d := time.Until(p.start.Add(time.Duration(p.i) * p.step))
+	p.i++
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
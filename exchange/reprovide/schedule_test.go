@@ -0,0 +1,113 @@
+package reprovide
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+func TestPacerSpreadsProvides(t *testing.T) {
+	ctx := context.Background()
+	p := newPacer(100*time.Millisecond, 4)
+
+	for i := 0; i < 4; i++ {
+		if err := p.wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(p.start); d < 75*time.Millisecond {
+		t.Fatalf("4 provides over 100ms took %s", d)
+	}
+}
+
+func TestPacerWithoutWindow(t *testing.T) {
+	p := newPacer(0, 1000)
+	for i := 0; i < 1000; i++ {
+		if err := p.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(p.start); d > 50*time.Millisecond {
+		t.Fatalf("a pacer without window waited %s", d)
+	}
+}
+
+func TestPacerCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := newPacer(time.Hour, 2)
+	if err := p.wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := p.wait(ctx); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+type nopRouting struct{}
+
+func (nopRouting) Provide(context.Context, *cid.Cid, bool) error { return nil }
+
+func (nopRouting) FindProvidersAsync(context.Context, *cid.Cid, int) <-chan pstore.PeerInfo {
+	ch := make(chan pstore.PeerInfo)
+	close(ch)
+	return ch
+}
+
+func TestTriggerStopsSpreadReprovide(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	keys := []*cid.Cid{
+		blocks.NewBlock([]byte("a")).Cid(),
+		blocks.NewBlock([]byte("b")).Cid(),
+	}
+	keyProvider := func(ctx context.Context) (<-chan *cid.Cid, error) {
+		ch := make(chan *cid.Cid, len(keys))
+		for _, k := range keys {
+			ch <- k
+		}
+		close(ch)
+		return ch, nil
+	}
+	rp := NewReprovider(ctx, nopRouting{}, keyProvider)
+
+	// the second key would be announced in half an hour
+	spread := make(chan doneFunc)
+	go func() {
+		done, _ := rp.reprovideSpread(time.Hour)
+		spread <- done
+	}()
+
+	triggered := make(chan error)
+	go func() {
+		triggered <- rp.Trigger(ctx)
+	}()
+
+	select {
+	case done := <-spread:
+		if done == nil {
+			t.Fatal("expected the trigger to stop the spread reprovide")
+		}
+		done(nil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the trigger didn't stop the spread reprovide")
+	}
+	if err := <-triggered; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWindowWithinRecordTTL(t *testing.T) {
+	rp := &Reprovider{RecordTTL: 10 * time.Hour}
+	if w := rp.window(time.Hour); w != 54*time.Minute {
+		t.Fatalf("expected a window of 54m, got %s", w)
+	}
+	if w := rp.window(48 * time.Hour); w != 9*time.Hour {
+		t.Fatalf("expected a window of 9h, got %s", w)
+	}
+}
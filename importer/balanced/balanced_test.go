@@ -17,6 +17,7 @@ import (
 	u "gx/ipfs/QmPdKqUcHGFdeSpvjVoaTRPPstGif9GBZb5Q56RVw9o69A/go-ipfs-util"
 	chunker "gx/ipfs/QmR4G4WBNGA5S5pvjFiTkuehstC9769sLAHei8vZernhYR/go-ipfs-chunker"
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
 )
 
 // TODO: extract these tests and more as a generic layout test suite
@@ -77,6 +78,70 @@ func TestBuilderConsistency(t *testing.T) {
 	testFileConsistency(t, 100000, chunker.DefaultBlockSize)
 }
 
+func TestWorkersConsistency(t *testing.T) {
+	data := make([]byte, 1000000)
+	u.NewTimeSeededRand().Read(data)
+
+	for _, rawLeaves := range []bool{false, true} {
+		var roots []*cid.Cid
+		for _, workers := range []int{1, 4} {
+			ds := mdtest.Mock()
+			dbp := h.DagBuilderParams{
+				Dagserv:   ds,
+				Maxlinks:  10,
+				RawLeaves: rawLeaves,
+				Workers:   workers,
+			}
+			nd, err := Layout(dbp.New(chunker.NewSizeSplitter(bytes.NewReader(data), 1000)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := uio.NewDagReader(context.Background(), nd, ds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dagrArrComp(t, r, data)
+			roots = append(roots, nd.Cid())
+		}
+		if !roots[0].Equals(roots[1]) {
+			t.Fatalf("the DAGs built with 1 and 4 workers differ: %s and %s", roots[0], roots[1])
+		}
+	}
+}
+
+func TestBatchLimits(t *testing.T) {
+	data := make([]byte, 100000)
+	u.NewTimeSeededRand().Read(data)
+
+	var roots []*cid.Cid
+	for _, dbp := range []h.DagBuilderParams{
+		{},
+		{BatchMaxNodes: 1, BatchMaxSize: 100},
+		{BatchMaxNodes: 3, SyncBytes: 5000},
+	} {
+		ds := mdtest.Mock()
+		dbp.Dagserv = ds
+		dbp.Maxlinks = 10
+		nd, err := Layout(dbp.New(chunker.NewSizeSplitter(bytes.NewReader(data), 1000)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := uio.NewDagReader(context.Background(), nd, ds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dagrArrComp(t, r, data)
+		roots = append(roots, nd.Cid())
+	}
+	for _, c := range roots[1:] {
+		if !c.Equals(roots[0]) {
+			t.Fatalf("the DAGs built with different batch limits differ: %s and %s", roots[0], c)
+		}
+	}
+}
+
 func TestNoChunking(t *testing.T) {
 	ds := mdtest.Mock()
 
@@ -101,6 +166,44 @@ func TestTwoChunks(t *testing.T) {
 	dagrArrComp(t, r, should)
 }
 
+// cancelingReader cancels its context once n bytes are read.
+type cancelingReader struct {
+	r      io.Reader
+	n      int
+	read   int
+	cancel context.CancelFunc
+}
+
+func (r *cancelingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read += n
+	if r.read >= r.n {
+		r.cancel()
+	}
+	return n, err
+}
+
+func TestLayoutCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data := make([]byte, 1024*1024)
+	u.NewTimeSeededRand().Read(data)
+	r := &cancelingReader{r: bytes.NewReader(data), n: 64 * 1024, cancel: cancel}
+
+	dbp := h.DagBuilderParams{
+		Dagserv:  mdtest.Mock(),
+		Maxlinks: h.DefaultLinksPerBlock,
+		Ctx:      ctx,
+	}
+	if _, err := Layout(dbp.New(chunker.NewSizeSplitter(r, 4096))); err != context.Canceled {
+		t.Fatalf("expected the layout to be canceled, got %v", err)
+	}
+	if r.read > 64*1024+4096 {
+		t.Fatalf("expected no more data to be read once canceled, read %d bytes", r.read)
+	}
+}
+
 func arrComp(a, b []byte) error {
 	if len(a) != len(b) {
 		return fmt.Errorf("arrays differ in length. %d != %d", len(a), len(b))
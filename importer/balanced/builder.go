@@ -19,6 +19,7 @@ import (
 	"errors"
 
 	h "github.com/ipfs/go-ipfs/importer/helpers"
+	ft "github.com/ipfs/go-ipfs/unixfs"
 
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
 )
@@ -27,6 +28,9 @@ import (
 // and depth only increases when the tree is full, that is, when
 // the root node has reached the maximum number of links.
 func Layout(db *h.DagBuilderHelper) (ipld.Node, error) {
+	// the leaves become files, see fillNodeRec
+	db.SetLeafType(ft.TFile)
+
 	var offset uint64
 	var root *h.UnixfsNode
 	for level := 0; !db.Done(); level++ {
@@ -0,0 +1,280 @@
+// Package erasure imports data as erasure-coded shards, for archives kept
+// across unreliable peers, and reads it back when some of the shards are
+// unavailable.
+//
+// The data is cut into stripes of DataShards blocks of BlockSize bytes,
+// from which ParityShards parity blocks are computed with a Reed-Solomon
+// code. Any DataShards of the blocks of a stripe give back its data. The
+// i-th blocks of the stripes make the i-th shard, stored as a unixfs file of
+// raw leaves, its own sub-DAG. The shards are linked from a manifest node,
+// "shard-0" to "shard-<n-1>", the data shards first. The manifest is a
+// dag-pb node whose data is the JSON of its parameters and of the size of
+// the data.
+//
+// The last stripe has shorter blocks, the data being split evenly across
+// them and padded with zeros.
+package erasure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	h "github.com/ipfs/go-ipfs/importer/helpers"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
+)
+
+var log = logging.Logger("erasure")
+
+// Scheme names the code of the shards in the manifests.
+const Scheme = "reed-solomon-gf256-cauchy"
+
+// DefaultBlockSize is the size of the blocks of the shards.
+const DefaultBlockSize = 256 * 1024
+
+// MaxShards is the largest number of shards, data and parity.
+const MaxShards = 256
+
+// ErrNotManifest is returned when reading a node which isn't the manifest of
+// erasure-coded shards.
+var ErrNotManifest = errors.New("not an erasure-coded manifest")
+
+// Params are the parameters of the import.
+type Params struct {
+	DataShards   int
+	ParityShards int
+	// BlockSize defaults to DefaultBlockSize.
+	BlockSize int
+	// Prefix is the CID prefix of the manifest and of the nodes of the
+	// shards, CIDv0 if nil. The leaves are raw either way.
+	Prefix *cid.Prefix
+	// Progress, if set, is called with the progress of the import after
+	// each stripe.
+	Progress func(h.Progress)
+}
+
+// Validate checks the number of shards and the size of the blocks.
+func (p *Params) Validate() error {
+	switch {
+	case p.DataShards < 1:
+		return fmt.Errorf("at least one data shard is needed, got %d", p.DataShards)
+	case p.ParityShards < 0:
+		return fmt.Errorf("invalid number of parity shards %d", p.ParityShards)
+	case p.DataShards+p.ParityShards > MaxShards:
+		return fmt.Errorf("at most %d shards are supported, got %d", MaxShards, p.DataShards+p.ParityShards)
+	case p.BlockSize < 0 || p.BlockSize > h.BlockSizeLimit:
+		return fmt.Errorf("invalid block size %d", p.BlockSize)
+	}
+	return nil
+}
+
+// manifest is the data of a manifest node.
+type manifest struct {
+	Scheme       string
+	DataShards   int
+	ParityShards int
+	BlockSize    int
+	Size         uint64
+}
+
+func shardName(i int) string {
+	return fmt.Sprintf("shard-%d", i)
+}
+
+// IsManifest returns whether nd is the manifest of erasure-coded shards,
+// valid or not.
+func IsManifest(nd ipld.Node) bool {
+	_, err := readManifest(nd)
+	return err != ErrNotManifest
+}
+
+func readManifest(nd ipld.Node) (*manifest, error) {
+	pbn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return nil, ErrNotManifest
+	}
+	var m manifest
+	if err := json.Unmarshal(pbn.Data(), &m); err != nil || m.Scheme != Scheme {
+		return nil, ErrNotManifest
+	}
+	if m.BlockSize == 0 {
+		return nil, errors.New("invalid erasure-coded manifest: no block size")
+	}
+	if err := (&Params{DataShards: m.DataShards, ParityShards: m.ParityShards, BlockSize: m.BlockSize}).Validate(); err != nil {
+		return nil, fmt.Errorf("invalid erasure-coded manifest: %s", err)
+	}
+	if len(pbn.Links()) != m.DataShards+m.ParityShards {
+		return nil, fmt.Errorf("invalid erasure-coded manifest: %d shards, %d links", m.DataShards+m.ParityShards, len(pbn.Links()))
+	}
+	return &m, nil
+}
+
+// stripeBlockSize returns the size of the blocks of the stripe of size
+// bytes of data, the last one, k blocks of which hold it.
+func stripeBlockSize(size uint64, k int) int {
+	return int((size + uint64(k) - 1) / uint64(k))
+}
+
+// Build imports the data of r as erasure-coded shards added to ds, and
+// returns their manifest.
+func Build(ctx context.Context, r io.Reader, ds ipld.DAGService, p Params) (ipld.Node, error) {
+	if p.BlockSize == 0 {
+		p.BlockSize = DefaultBlockSize
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	k, n := p.DataShards, p.DataShards+p.ParityShards
+	parity := encodingMatrix(k, p.ParityShards)[k:]
+
+	batch := ipld.NewBatch(ctx, ds)
+	leaves := make([][]*ipld.Link, n)
+	var progress h.Progress
+	var size uint64
+	for {
+		stripe := make([]byte, k*p.BlockSize)
+		read, err := io.ReadFull(r, stripe)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		size += uint64(read)
+
+		bs := p.BlockSize
+		if read < len(stripe) {
+			bs = stripeBlockSize(uint64(read), k)
+		}
+		shards := make([][]byte, n)
+		for i := 0; i < k; i++ {
+			shards[i] = stripe[i*bs : (i+1)*bs]
+		}
+		for i := k; i < n; i++ {
+			shards[i] = make([]byte, bs)
+		}
+		parity.mulShards(shards[:k], shards[k:])
+
+		for i, data := range shards {
+			leaf, err := newLeaf(data, p.Prefix)
+			if err != nil {
+				return nil, err
+			}
+			if err := batch.Add(leaf); err != nil {
+				return nil, err
+			}
+			leaves[i] = append(leaves[i], &ipld.Link{Cid: leaf.Cid(), Size: uint64(len(data))})
+		}
+
+		if p.Progress != nil {
+			progress.Bytes += uint64(read)
+			progress.PersistedBytes = progress.Bytes
+			progress.Leaves += uint64(n)
+			progress.Nodes += uint64(n)
+			p.Progress(progress)
+		}
+		if read < len(stripe) {
+			break
+		}
+	}
+
+	mnd, err := json.Marshal(&manifest{
+		Scheme:       Scheme,
+		DataShards:   k,
+		ParityShards: p.ParityShards,
+		BlockSize:    p.BlockSize,
+		Size:         size,
+	})
+	if err != nil {
+		return nil, err
+	}
+	root := dag.NodeWithData(mnd)
+	root.SetPrefix(p.Prefix)
+	for i, ls := range leaves {
+		shard, err := buildShard(batch, ls, p.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		if err := root.AddNodeLink(shardName(i), shard); err != nil {
+			return nil, err
+		}
+	}
+	if err := batch.Add(root); err != nil {
+		return nil, err
+	}
+	if err := batch.Commit(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func newLeaf(data []byte, prefix *cid.Prefix) (*dag.RawNode, error) {
+	if prefix == nil {
+		return dag.NewRawNode(data), nil
+	}
+	return dag.NewRawNodeWPrefix(data, *prefix)
+}
+
+// buildShard adds the unixfs file of the leaves of a shard to b, a balanced
+// tree of DefaultLinksPerBlock links per node, and returns its root.
+func buildShard(b *ipld.Batch, leaves []*ipld.Link, prefix *cid.Prefix) (ipld.Node, error) {
+	sizes := make([]uint64, len(leaves))
+	for i, l := range leaves {
+		sizes[i] = l.Size
+	}
+
+	level := leaves
+	for {
+		var next []*ipld.Link
+		var nextSizes []uint64
+		var root ipld.Node
+		for start := 0; start < len(level) || start == 0; start += h.DefaultLinksPerBlock {
+			end := start + h.DefaultLinksPerBlock
+			if end > len(level) {
+				end = len(level)
+			}
+
+			fsn := &ft.FSNode{Type: ft.TFile}
+			nd := new(dag.ProtoNode)
+			nd.SetPrefix(prefix)
+			var fileSize uint64
+			for i := start; i < end; i++ {
+				fsn.AddBlockSize(sizes[i])
+				fileSize += sizes[i]
+				if err := nd.AddRawLink("", level[i]); err != nil {
+					return nil, err
+				}
+			}
+			data, err := fsn.GetBytes()
+			if err != nil {
+				return nil, err
+			}
+			nd.SetData(data)
+			if err := b.Add(nd); err != nil {
+				return nil, err
+			}
+
+			size, err := nd.Size()
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, &ipld.Link{Cid: nd.Cid(), Size: size})
+			nextSizes = append(nextSizes, fileSize)
+			root = nd
+		}
+		if len(next) == 1 {
+			return root, nil
+		}
+		level, sizes = next, nextSizes
+	}
+}
@@ -0,0 +1,155 @@
+package erasure
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+
+	u "gx/ipfs/QmPdKqUcHGFdeSpvjVoaTRPPstGif9GBZb5Q56RVw9o69A/go-ipfs-util"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+var _ uio.DagReader = (*Reader)(nil)
+
+func buildTestShards(t *testing.T, ds ipld.DAGService, size int) (ipld.Node, []byte) {
+	data := make([]byte, size)
+	u.NewTimeSeededRand().Read(data)
+
+	nd, err := Build(context.Background(), bytes.NewReader(data), ds, Params{
+		DataShards:   4,
+		ParityShards: 2,
+		BlockSize:    512,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nd, data
+}
+
+// removeShard removes the blocks of the i-th shard of nd from ds.
+func removeShard(t *testing.T, ds ipld.DAGService, nd ipld.Node, i int, leavesOnly bool) {
+	ctx := context.Background()
+	var walk func(c *cid.Cid)
+	walk = func(c *cid.Cid) {
+		n, err := ds.Get(ctx, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range n.Links() {
+			walk(l.Cid)
+		}
+		if len(n.Links()) == 0 || !leavesOnly {
+			if err := ds.Remove(ctx, c); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	walk(nd.Links()[i].Cid)
+}
+
+func readAll(t *testing.T, ds ipld.DAGService, nd ipld.Node) ([]byte, error) {
+	r, err := NewReader(context.Background(), nd, ds)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func TestBuildAndRead(t *testing.T) {
+	for _, size := range []int{0, 1, 100, 4 * 512, 4*512*3 + 77} {
+		ds := mdtest.Mock()
+		nd, data := buildTestShards(t, ds, size)
+		if !IsManifest(nd) || len(nd.Links()) != 6 {
+			t.Fatalf("expected a manifest of 6 shards, got %v", nd.Links())
+		}
+
+		out, err := readAll(t, ds, nd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatalf("the data of %d bytes read back differs", size)
+		}
+	}
+}
+
+func TestReadMissingShards(t *testing.T) {
+	ds := mdtest.Mock()
+	nd, data := buildTestShards(t, ds, 4*512*200+1000)
+
+	// a whole shard, and the leaves of another, are lost
+	removeShard(t, ds, nd, 1, false)
+	removeShard(t, ds, nd, 3, true)
+
+	out, err := readAll(t, ds, nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatal("the data recovered from the parity shards differs")
+	}
+
+	removeShard(t, ds, nd, 4, true)
+	if _, err := readAll(t, ds, nd); err == nil {
+		t.Fatal("expected the data to be unrecoverable with 3 shards of 4")
+	}
+}
+
+func TestReadOneMissingShard(t *testing.T) {
+	for _, missing := range []int{-1, 0, 5} {
+		ds := mdtest.Mock()
+		nd, data := buildTestShards(t, ds, 4*512*2+10)
+		if missing >= 0 {
+			removeShard(t, ds, nd, missing, false)
+		}
+
+		out, err := readAll(t, ds, nd)
+		if err != nil {
+			t.Fatalf("shard %d missing: %s", missing, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatalf("shard %d missing: the data read back differs", missing)
+		}
+	}
+}
+
+func TestReaderSeek(t *testing.T) {
+	ds := mdtest.Mock()
+	nd, data := buildTestShards(t, ds, 4*512*5+300)
+	removeShard(t, ds, nd, 0, true)
+
+	r, err := NewReader(context.Background(), nd, ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != uint64(len(data)) {
+		t.Fatalf("expected a size of %d, got %d", len(data), r.Size())
+	}
+	for _, off := range []int64{0, 3000, 511, 4 * 512 * 5, int64(len(data)) - 1} {
+		if _, err := r.Seek(off, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 1000)
+		n, err := r.CtxReadFull(context.Background(), buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf[:n], data[off:off+int64(n)]) || off+int64(n) != int64(len(data)) && n != len(buf) {
+			t.Fatalf("unexpected data read at %d", off)
+		}
+	}
+}
+
+func TestNotManifest(t *testing.T) {
+	if IsManifest(dag.NodeWithData([]byte("{}"))) || IsManifest(dag.NewRawNode([]byte("data"))) {
+		t.Fatal("expected the nodes not to be manifests")
+	}
+}
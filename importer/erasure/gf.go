@@ -0,0 +1,129 @@
+package erasure
+
+import "errors"
+
+// The Reed-Solomon code of the shards works in GF(2^8), with the polynomial
+// x^8 + x^4 + x^3 + x^2 + 1. Its encoding matrix is the identity, for the
+// data shards to be the data as it is, over a Cauchy matrix for the parity
+// shards: any k of its rows are independent, so any k shards are enough to
+// recover the data.
+
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+	// gfMul is the multiplication table
+	gfMul [256][256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfExp[i+255] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			gfMul[a][b] = gfExp[int(gfLog[a])+int(gfLog[b])]
+		}
+	}
+}
+
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// matrix is a matrix of GF(2^8), by rows.
+type matrix [][]byte
+
+func newMatrix(rows, cols int) matrix {
+	m := make(matrix, rows)
+	for i := range m {
+		m[i] = make([]byte, cols)
+	}
+	return m
+}
+
+// encodingMatrix returns the (k+m)×k matrix giving the k data shards and m
+// parity shards of k data shards.
+func encodingMatrix(k, m int) matrix {
+	em := newMatrix(k+m, k)
+	for i := 0; i < k; i++ {
+		em[i][i] = 1
+	}
+	// x_i = k+i and y_j = j are distinct, so x_i ^ y_j is never 0
+	for i := 0; i < m; i++ {
+		for j := 0; j < k; j++ {
+			em[k+i][j] = gfInv(byte(k+i) ^ byte(j))
+		}
+	}
+	return em
+}
+
+var errSingular = errors.New("singular matrix")
+
+// invert returns the inverse of the square matrix m, by Gauss-Jordan
+// elimination.
+func (m matrix) invert() (matrix, error) {
+	n := len(m)
+	// m | identity, reduced to identity | inverse
+	work := newMatrix(n, 2*n)
+	for i := range m {
+		copy(work[i], m[i])
+		work[i][n+i] = 1
+	}
+
+	for c := 0; c < n; c++ {
+		p := c
+		for p < n && work[p][c] == 0 {
+			p++
+		}
+		if p == n {
+			return nil, errSingular
+		}
+		work[c], work[p] = work[p], work[c]
+
+		if inv := gfInv(work[c][c]); inv != 1 {
+			for j := range work[c] {
+				work[c][j] = gfMul[inv][work[c][j]]
+			}
+		}
+		for r := 0; r < n; r++ {
+			if f := work[r][c]; r != c && f != 0 {
+				for j := range work[r] {
+					work[r][j] ^= gfMul[f][work[c][j]]
+				}
+			}
+		}
+	}
+
+	inv := newMatrix(n, n)
+	for i := range inv {
+		copy(inv[i], work[i][n:])
+	}
+	return inv, nil
+}
+
+// mulShards sets each out[i] to the sum of the in[j] multiplied by m[i][j].
+// The shards all have the same size.
+func (m matrix) mulShards(in, out [][]byte) {
+	for i, row := range m {
+		o := out[i]
+		for t := range o {
+			o[t] = 0
+		}
+		for j, f := range row {
+			if f == 0 {
+				continue
+			}
+			tbl := &gfMul[f]
+			for t, b := range in[j] {
+				o[t] ^= tbl[b]
+			}
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package erasure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// FetchTimeout is how long the nodes of the shards are waited for before
+// they're taken as unavailable.
+var FetchTimeout = time.Minute
+
+// Reader reads the data of erasure-coded shards, from the data shards when
+// they're available, and recomputing it from the parity shards otherwise.
+// It implements the DagReader interface of unixfs/io.
+type Reader struct {
+	ctx    context.Context
+	cancel func()
+	ng     ipld.NodeGetter
+	m      *manifest
+
+	// leaves are the leaves of each shard, by stripe, and present whether
+	// each shard is available, the shards of empty data having no leaves
+	leaves  [][]*cid.Cid
+	present []bool
+
+	// stripe is the data of the stripe of index cur, -1 if none
+	stripe []byte
+	cur    int64
+	offset int64
+}
+
+// NewReader returns a Reader of the shards of the manifest nd, fetched from
+// ng. The shards whose DAGs can't be fetched are left out, at least
+// DataShards of them being needed.
+func NewReader(ctx context.Context, nd ipld.Node, ng ipld.NodeGetter) (*Reader, error) {
+	m, err := readManifest(nd)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r := &Reader{
+		ctx:     ctx,
+		cancel:  cancel,
+		ng:      ng,
+		m:       m,
+		leaves:  make([][]*cid.Cid, m.DataShards+m.ParityShards),
+		present: make([]bool, m.DataShards+m.ParityShards),
+		cur:     -1,
+	}
+
+	stripes := r.stripes()
+	var wg sync.WaitGroup
+	for i, l := range nd.Links() {
+		wg.Add(1)
+		go func(i int, c *cid.Cid) {
+			defer wg.Done()
+			leaves, err := r.listLeaves(c)
+			if err == nil && int64(len(leaves)) != stripes {
+				err = fmt.Errorf("%d blocks, expected %d", len(leaves), stripes)
+			}
+			if err != nil {
+				log.Warningf("shard %d of %s is unavailable: %s", i, nd.Cid(), err)
+				return
+			}
+			r.leaves[i] = leaves
+			r.present[i] = true
+		}(i, l.Cid)
+	}
+	wg.Wait()
+
+	if available := r.available(); len(available) < m.DataShards {
+		cancel()
+		return nil, fmt.Errorf("only %d of the shards of %s are available, %d are needed", len(available), nd.Cid(), m.DataShards)
+	}
+	return r, nil
+}
+
+func (r *Reader) stripeSize() int64 {
+	return int64(r.m.DataShards) * int64(r.m.BlockSize)
+}
+
+// stripes returns the number of stripes.
+func (r *Reader) stripes() int64 {
+	return (int64(r.m.Size) + r.stripeSize() - 1) / r.stripeSize()
+}
+
+// available returns the indexes of the shards available, data shards first.
+func (r *Reader) available() []int {
+	var out []int
+	for i, ok := range r.present {
+		if ok {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// listLeaves returns the leaves of the shard c, in order.
+func (r *Reader) listLeaves(c *cid.Cid) ([]*cid.Cid, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, FetchTimeout)
+	defer cancel()
+
+	var leaves []*cid.Cid
+	var walk func(c *cid.Cid) error
+	walk = func(c *cid.Cid) error {
+		if c.Type() == cid.Raw {
+			leaves = append(leaves, c)
+			return nil
+		}
+		nd, err := r.ng.Get(ctx, c)
+		if err != nil {
+			return err
+		}
+		for _, l := range nd.Links() {
+			if err := walk(l.Cid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(c); err != nil {
+		return nil, err
+	}
+	return leaves, nil
+}
+
+// loadStripe loads the data of the stripe s, from the first DataShards
+// blocks of it which can be fetched.
+func (r *Reader) loadStripe(ctx context.Context, s int64) error {
+	k := r.m.DataShards
+	size := r.stripeSize()
+	bs := r.m.BlockSize
+	if left := int64(r.m.Size) - s*size; left < size {
+		size = left
+		bs = stripeBlockSize(uint64(left), k)
+	}
+
+	got := make(map[int][]byte, k)
+	candidates := r.available()
+	for len(got) < k && len(candidates) > 0 {
+		batch := candidates
+		if len(batch) > k-len(got) {
+			batch = batch[:k-len(got)]
+		}
+		candidates = candidates[len(batch):]
+
+		blocks := make([][]byte, len(batch))
+		var wg sync.WaitGroup
+		for j, i := range batch {
+			wg.Add(1)
+			go func(j, i int) {
+				defer wg.Done()
+				blocks[j] = r.fetchBlock(ctx, r.leaves[i][s], bs)
+			}(j, i)
+		}
+		wg.Wait()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		for j, i := range batch {
+			if blocks[j] != nil {
+				got[i] = blocks[j]
+			}
+		}
+	}
+	if len(got) < k {
+		return fmt.Errorf("only %d blocks of stripe %d are available, %d are needed", len(got), s, k)
+	}
+
+	data, err := reconstruct(encodingMatrix(k, r.m.ParityShards), got, k, bs)
+	if err != nil {
+		return err
+	}
+	stripe := make([]byte, 0, k*bs)
+	for _, d := range data {
+		stripe = append(stripe, d...)
+	}
+	r.stripe = stripe[:size]
+	r.cur = s
+	return nil
+}
+
+// fetchBlock returns the data of the leaf c, nil if it's unavailable or
+// isn't of size bytes.
+func (r *Reader) fetchBlock(ctx context.Context, c *cid.Cid, size int) []byte {
+	ctx, cancel := context.WithTimeout(ctx, FetchTimeout)
+	defer cancel()
+
+	nd, err := r.ng.Get(ctx, c)
+	if err != nil {
+		log.Debugf("block %s is unavailable: %s", c, err)
+		return nil
+	}
+	if len(nd.RawData()) != size {
+		log.Warningf("block %s is of %d bytes, expected %d", c, len(nd.RawData()), size)
+		return nil
+	}
+	return nd.RawData()
+}
+
+// reconstruct returns the k data blocks of a stripe from k of its blocks,
+// by shard, of bs bytes, em being the encoding matrix.
+func reconstruct(em matrix, blocks map[int][]byte, k, bs int) ([][]byte, error) {
+	rows := make([]int, 0, len(blocks))
+	for i := range blocks {
+		rows = append(rows, i)
+	}
+	sort.Ints(rows)
+	rows = rows[:k]
+
+	data := make([][]byte, k)
+	if rows[k-1] == k-1 {
+		// the data blocks, as they are
+		for i := range data {
+			data[i] = blocks[i]
+		}
+		return data, nil
+	}
+
+	sub := make(matrix, k)
+	in := make([][]byte, k)
+	for j, i := range rows {
+		sub[j] = em[i]
+		in[j] = blocks[i]
+	}
+	dec, err := sub.invert()
+	if err != nil {
+		return nil, err
+	}
+	for i := range data {
+		data[i] = make([]byte, bs)
+	}
+	dec.mulShards(in, data)
+	return data, nil
+}
+
+// Read reads the data at the offset of the reader into p.
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.read(r.ctx, p)
+}
+
+func (r *Reader) read(ctx context.Context, p []byte) (int, error) {
+	if r.offset >= int64(r.m.Size) {
+		return 0, io.EOF
+	}
+	s := r.offset / r.stripeSize()
+	if s != r.cur {
+		if err := r.loadStripe(ctx, s); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.stripe[r.offset-s*r.stripeSize():])
+	r.offset += int64(n)
+	return n, nil
+}
+
+// CtxReadFull reads data into p until it's full or the data ends, fetching
+// the blocks with ctx.
+func (r *Reader) CtxReadFull(ctx context.Context, p []byte) (int, error) {
+	total := 0
+	for total < len(p) {
+		n, err := r.read(ctx, p[total:])
+		total += n
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// WriteTo writes the data from the offset of the reader to w.
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	buf := make([]byte, r.stripeSize())
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			wn, werr := w.Write(buf[:n])
+			total += int64(wn)
+			if werr != nil {
+				return total, werr
+			}
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// Seek sets the offset of the next read.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += int64(r.m.Size)
+	default:
+		return -1, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return -1, errors.New("invalid offset")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Size returns the size of the data.
+func (r *Reader) Size() uint64 {
+	return r.m.Size
+}
+
+// Offset returns the offset of the reader.
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
+
+// Close stops the fetches of the reader.
+func (r *Reader) Close() error {
+	r.cancel()
+	return nil
+}
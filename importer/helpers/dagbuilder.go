@@ -7,6 +7,7 @@ import (
 
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	ft "github.com/ipfs/go-ipfs/unixfs"
+	pb "github.com/ipfs/go-ipfs/unixfs/pb"
 
 	chunker "gx/ipfs/QmR4G4WBNGA5S5pvjFiTkuehstC9769sLAHei8vZernhYR/go-ipfs-chunker"
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
@@ -17,6 +18,7 @@ import (
 // DagBuilderHelper wraps together a bunch of objects needed to
 // efficiently create unixfs dag trees
 type DagBuilderHelper struct {
+	ctx       context.Context
 	dserv     ipld.DAGService
 	spl       chunker.Splitter
 	recvdErr  error
@@ -27,6 +29,29 @@ type DagBuilderHelper struct {
 	fullPath  string
 	stat      os.FileInfo
 	prefix    *cid.Prefix
+	progress  *progressTracker
+
+	// workers is the number of leaves built ahead concurrently, pending
+	// the leaves being built, in order, and leafType the unixfs type
+	// they're encoded as
+	workers  int
+	pending  []chan builtLeaf
+	leafType pb.Data_DataType
+
+	// the limits of the batches, and the size of the nodes added to them
+	// since the last commit
+	batchMaxNodes int
+	batchMaxSize  int
+	syncBytes     uint64
+	unsynced      uint64
+	// cancelBatch releases the context of the batch, once closed
+	cancelBatch context.CancelFunc
+}
+
+// builtLeaf is a leaf built ahead by a worker.
+type builtLeaf struct {
+	node *UnixfsNode
+	err  error
 }
 
 // DagBuilderParams wraps configuration options to create a DagBuilderHelper
@@ -48,19 +73,64 @@ type DagBuilderParams struct {
 	// NoCopy signals to the chunker that it should track fileinfo for
 	// filestore adds
 	NoCopy bool
+
+	// Ctx bounds the building of the DAG: once it's done, no more data is
+	// read and the nodes aren't added anymore. Defaults to
+	// context.Background().
+	Ctx context.Context
+
+	// Progress, if set, is called with the progress of the building each
+	// time a leaf is created and each time nodes are added to Dagserv. It's
+	// never called concurrently, but it holds the building and the adding
+	// of the nodes: it shouldn't block.
+	Progress func(Progress)
+
+	// Workers is the number of leaves encoded and hashed concurrently,
+	// ahead of the layout linking them. The DAG built is the same whatever
+	// it is. 0 or 1 builds the leaves one at a time, as they're linked.
+	Workers int
+
+	// BatchMaxNodes and BatchMaxSize are the number and the size in bytes
+	// of the nodes buffered before they're added to Dagserv, in the
+	// background. 0 keeps the defaults of ipld.Batch.
+	BatchMaxNodes int
+	BatchMaxSize  int
+
+	// SyncBytes, if set, has the nodes buffered committed, and waited for,
+	// each time SyncBytes bytes of nodes were buffered, bounding the data
+	// not yet added to Dagserv.
+	SyncBytes uint64
 }
 
 // New generates a new DagBuilderHelper from the given params and a given
 // chunker.Splitter as data source.
 func (dbp *DagBuilderParams) New(spl chunker.Splitter) *DagBuilderHelper {
+	ctx := dbp.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	dserv := dbp.Dagserv
+	var progress *progressTracker
+	if dbp.Progress != nil {
+		progress = &progressTracker{report: dbp.Progress}
+		dserv = &progressDAGService{DAGService: dserv, t: progress}
+	}
 	db := &DagBuilderHelper{
-		dserv:     dbp.Dagserv,
+		ctx:       ctx,
+		dserv:     dserv,
 		spl:       spl,
 		rawLeaves: dbp.RawLeaves,
 		prefix:    dbp.Prefix,
 		maxlinks:  dbp.Maxlinks,
-		batch:     ipld.NewBatch(context.TODO(), dbp.Dagserv),
+		progress:  progress,
+		workers:   dbp.Workers,
+		leafType:  ft.TRaw,
+
+		batchMaxNodes: dbp.BatchMaxNodes,
+		batchMaxSize:  dbp.BatchMaxSize,
+		syncBytes:     dbp.SyncBytes,
 	}
+	db.newBatch()
 	if fi, ok := spl.Reader().(files.FileInfo); dbp.NoCopy && ok {
 		db.fullPath = fi.AbsPath()
 		db.stat = fi.Stat()
@@ -70,13 +140,19 @@ func (dbp *DagBuilderParams) New(spl chunker.Splitter) *DagBuilderHelper {
 
 // prepareNext consumes the next item from the splitter and puts it
 // in the nextData field. it is idempotent-- if nextData is full
-// it will do nothing.
+// it will do nothing. Once the context is done, its error is returned
+// instead.
 func (db *DagBuilderHelper) prepareNext() {
 	// if we already have data waiting to be consumed, we're ready
 	if db.nextData != nil || db.recvdErr != nil {
 		return
 	}
 
+	if err := db.ctx.Err(); err != nil {
+		db.recvdErr = err
+		return
+	}
+
 	db.nextData, db.recvdErr = db.spl.NextBytes()
 	if db.recvdErr == io.EOF {
 		db.recvdErr = nil
@@ -85,6 +161,9 @@ func (db *DagBuilderHelper) prepareNext() {
 
 // Done returns whether or not we're done consuming the incoming data.
 func (db *DagBuilderHelper) Done() bool {
+	if len(db.pending) > 0 {
+		return false
+	}
 	// ensure we have an accurate perspective on data
 	// as `done` this may be called before `next`.
 	db.prepareNext() // idempotent
@@ -96,7 +175,8 @@ func (db *DagBuilderHelper) Done() bool {
 
 // Next returns the next chunk of data to be inserted into the dag
 // if it returns nil, that signifies that the stream is at an end, and
-// that the current building operation should finish.
+// that the current building operation should finish. The chunks of the
+// leaves being built ahead aren't returned again.
 func (db *DagBuilderHelper) Next() ([]byte, error) {
 	db.prepareNext() // idempotent
 	d := db.nextData
@@ -112,6 +192,11 @@ func (db *DagBuilderHelper) GetDagServ() ipld.DAGService {
 	return db.dserv
 }
 
+// Context returns the context bounding the building of the DAG.
+func (db *DagBuilderHelper) Context() context.Context {
+	return db.ctx
+}
+
 // NewUnixfsNode creates a new Unixfs node to represent a file.
 func (db *DagBuilderHelper) NewUnixfsNode() *UnixfsNode {
 	n := &UnixfsNode{
@@ -130,6 +215,9 @@ func (db *DagBuilderHelper) NewLeaf(data []byte) (*UnixfsNode, error) {
 	if len(data) > BlockSizeLimit {
 		return nil, ErrSizeLimitExceeded
 	}
+	if data != nil {
+		db.progress.leaf(len(data))
+	}
 
 	if db.rawLeaves {
 		if db.prefix == nil {
@@ -157,6 +245,14 @@ func (db *DagBuilderHelper) NewLeaf(data []byte) (*UnixfsNode, error) {
 	return blk, nil
 }
 
+// SetLeafType sets the unixfs type the leaves built ahead by the workers
+// are encoded as, ft.TRaw by default. The layouts turning the leaves into
+// nodes of another type set it, for their data not to be encoded and hashed
+// again.
+func (db *DagBuilderHelper) SetLeafType(t pb.Data_DataType) {
+	db.leafType = t
+}
+
 // newUnixfsBlock creates a new Unixfs node to represent a raw data block
 func (db *DagBuilderHelper) newUnixfsBlock() *UnixfsNode {
 	n := &UnixfsNode{
@@ -190,6 +286,10 @@ func (db *DagBuilderHelper) FillNodeLayer(node *UnixfsNode) error {
 // Splitter, given the constraints (BlockSizeLimit, RawLeaves) specified
 // when creating the DagBuilderHelper.
 func (db *DagBuilderHelper) GetNextDataNode() (*UnixfsNode, error) {
+	if db.workers > 1 {
+		return db.nextBuiltLeaf()
+	}
+
 	data, err := db.Next()
 	if err != nil {
 		return nil, err
@@ -202,6 +302,61 @@ func (db *DagBuilderHelper) GetNextDataNode() (*UnixfsNode, error) {
 	return db.NewLeaf(data)
 }
 
+// nextBuiltLeaf returns the next of the leaves built ahead, after starting
+// the building of the following ones, up to db.workers of them.
+func (db *DagBuilderHelper) nextBuiltLeaf() (*UnixfsNode, error) {
+	db.buildAhead()
+	if len(db.pending) == 0 {
+		_, err := db.Next()
+		return nil, err
+	}
+
+	next := db.pending[0]
+	db.pending = db.pending[1:]
+	db.buildAhead()
+
+	leaf := <-next
+	return leaf.node, leaf.err
+}
+
+// buildAhead reads chunks and builds their leaves in new goroutines until
+// db.workers of them are pending or the data ends. The results are
+// buffered, for the goroutines to be done even if they're never received.
+func (db *DagBuilderHelper) buildAhead() {
+	for len(db.pending) < db.workers {
+		db.prepareNext()
+		if db.recvdErr != nil || db.nextData == nil {
+			return
+		}
+		data := db.nextData
+		db.nextData = nil
+
+		out := make(chan builtLeaf, 1)
+		db.pending = append(db.pending, out)
+		go func() {
+			leaf, err := db.buildLeaf(data)
+			out <- builtLeaf{node: leaf, err: err}
+		}()
+	}
+}
+
+// buildLeaf returns the leaf of data, of type db.leafType, encoded and
+// hashed.
+func (db *DagBuilderHelper) buildLeaf(data []byte) (*UnixfsNode, error) {
+	leaf, err := db.NewLeaf(data)
+	if err != nil || leaf.raw {
+		return leaf, err
+	}
+	leaf.ufmt.Type = db.leafType
+
+	nd, err := leaf.GetDagNode()
+	if err != nil {
+		return nil, err
+	}
+	nd.Cid()
+	return leaf, nil
+}
+
 // SetPosInfo sets the offset information of a node using the fullpath and stat
 // from the DagBuilderHelper.
 func (db *DagBuilderHelper) SetPosInfo(node *UnixfsNode, offset uint64) {
@@ -217,7 +372,7 @@ func (db *DagBuilderHelper) Add(node *UnixfsNode) (ipld.Node, error) {
 		return nil, err
 	}
 
-	err = db.dserv.Add(context.TODO(), dn)
+	err = db.dserv.Add(db.ctx, dn)
 	if err != nil {
 		return nil, err
 	}
@@ -225,6 +380,42 @@ func (db *DagBuilderHelper) Add(node *UnixfsNode) (ipld.Node, error) {
 	return dn, nil
 }
 
+// newBatch starts the batch the nodes are added with, used until the
+// DagBuilderHelper is closed.
+func (db *DagBuilderHelper) newBatch() {
+	ctx, cancel := context.WithCancel(db.ctx)
+	db.batch = ipld.NewBatch(ctx, db.dserv)
+	db.cancelBatch = cancel
+	if db.batchMaxNodes > 0 {
+		db.batch.MaxNodes = db.batchMaxNodes
+	}
+	if db.batchMaxSize > 0 {
+		db.batch.MaxSize = db.batchMaxSize
+	}
+}
+
+// addToBatch buffers nd, to be added to the DAGService with the batch. The
+// batch is committed, and waited for, once SyncBytes bytes are buffered, and
+// goes on buffering the next nodes.
+func (db *DagBuilderHelper) addToBatch(nd ipld.Node) error {
+	if err := db.batch.Add(nd); err != nil {
+		return err
+	}
+	if db.syncBytes == 0 {
+		return nil
+	}
+
+	db.unsynced += uint64(len(nd.RawData()))
+	if db.unsynced < db.syncBytes {
+		return nil
+	}
+	if err := db.batch.Commit(); err != nil {
+		return err
+	}
+	db.unsynced = 0
+	return nil
+}
+
 // Maxlinks returns the configured maximum number for links
 // for nodes built with this helper.
 func (db *DagBuilderHelper) Maxlinks() int {
@@ -233,7 +424,12 @@ func (db *DagBuilderHelper) Maxlinks() int {
 
 // Close has the DAGService perform a batch Commit operation.
 // It should be called at the end of the building process to make
-// sure all data is persisted.
+// sure all data is persisted. It fails once the context is done.
 func (db *DagBuilderHelper) Close() error {
-	return db.batch.Commit()
+	defer db.cancelBatch()
+
+	if err := db.batch.Commit(); err != nil {
+		return err
+	}
+	return db.ctx.Err()
 }
@@ -1,6 +1,7 @@
 package helpers
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
@@ -114,9 +115,7 @@ func (n *UnixfsNode) AddChild(child *UnixfsNode, db *DagBuilderHelper) error {
 		return err
 	}
 
-	err = db.batch.Add(childnode)
-
-	return err
+	return db.addToBatch(childnode)
 }
 
 // RemoveChild deletes the child node at the given index.
@@ -179,6 +178,10 @@ func (n *UnixfsNode) getBaseDagNode() (ipld.Node, error) {
 	if err != nil {
 		return nil, err
 	}
-	n.node.SetData(data)
+	// keep the encoding and hash of the node when its data is unchanged,
+	// as for the leaves built ahead
+	if !bytes.Equal(data, n.node.Data()) {
+		n.node.SetData(data)
+	}
 	return n.node, nil
 }
@@ -0,0 +1,125 @@
+package helpers
+
+import (
+	"context"
+	"sync"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+)
+
+// Progress is the progress of the building of a DAG, reported to
+// DagBuilderParams.Progress.
+type Progress struct {
+	// Bytes is the size of the data put in the leaves created so far, and
+	// Leaves their number.
+	Bytes  uint64
+	Leaves uint64
+
+	// PersistedBytes is the size of the data of the leaves added to the
+	// DAGService so far, and Nodes the number of nodes added to it, leaves
+	// or not.
+	PersistedBytes uint64
+	Nodes          uint64
+}
+
+// progressTracker reports the progress of a DagBuilderHelper. Its methods do
+// nothing on a nil tracker.
+type progressTracker struct {
+	// mu guards the progress, the batch adding the nodes concurrently. seq
+	// counts its updates.
+	mu       sync.Mutex
+	progress Progress
+	seq      uint64
+
+	// reportMu serializes the reports, made out of mu for a slow report
+	// not to hold the building. reported is the seq of the last one.
+	reportMu sync.Mutex
+	reported uint64
+	report   func(Progress)
+}
+
+// leaf records a leaf of size bytes of data created.
+func (t *progressTracker) leaf(size int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.progress.Bytes += uint64(size)
+	t.progress.Leaves++
+	t.update()
+}
+
+// added records the nodes added to the DAGService.
+func (t *progressTracker) added(nds ...ipld.Node) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	for _, nd := range nds {
+		t.progress.PersistedBytes += leafDataSize(nd)
+		t.progress.Nodes++
+	}
+	t.update()
+}
+
+// update reports the progress, releasing mu, which it's called with. The
+// updates made concurrently can reach reportMu out of order: the ones older
+// than the last reported are dropped, for the progress to only grow.
+func (t *progressTracker) update() {
+	t.seq++
+	seq, p := t.seq, t.progress
+	t.mu.Unlock()
+
+	t.reportMu.Lock()
+	defer t.reportMu.Unlock()
+	if seq <= t.reported {
+		return
+	}
+	t.reported = seq
+	t.report(p)
+}
+
+// leafDataSize returns the size of the data of nd, if it's a leaf.
+func leafDataSize(nd ipld.Node) uint64 {
+	if len(nd.Links()) > 0 {
+		return 0
+	}
+	switch nd := nd.(type) {
+	case *dag.RawNode:
+		return uint64(len(nd.RawData()))
+	case *dag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			return 0
+		}
+		return uint64(len(fsn.Data))
+	default:
+		return 0
+	}
+}
+
+// progressDAGService records the nodes added to the DAGService it wraps once
+// they are.
+type progressDAGService struct {
+	ipld.DAGService
+	t *progressTracker
+}
+
+func (ds *progressDAGService) Add(ctx context.Context, nd ipld.Node) error {
+	if err := ds.DAGService.Add(ctx, nd); err != nil {
+		return err
+	}
+	ds.t.added(nd)
+	return nil
+}
+
+func (ds *progressDAGService) AddMany(ctx context.Context, nds []ipld.Node) error {
+	if err := ds.DAGService.AddMany(ctx, nds); err != nil {
+		return err
+	}
+	ds.t.added(nds...)
+	return nil
+}
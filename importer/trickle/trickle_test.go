@@ -18,6 +18,7 @@ import (
 	u "gx/ipfs/QmPdKqUcHGFdeSpvjVoaTRPPstGif9GBZb5Q56RVw9o69A/go-ipfs-util"
 	chunker "gx/ipfs/QmR4G4WBNGA5S5pvjFiTkuehstC9769sLAHei8vZernhYR/go-ipfs-chunker"
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
 )
 
 type UseRawLeaves bool
@@ -139,6 +140,46 @@ func testBuilderConsistency(t *testing.T, rawLeaves UseRawLeaves) {
 	}
 }
 
+func TestWorkersConsistency(t *testing.T) {
+	runBothSubtests(t, testWorkersConsistency)
+}
+
+func testWorkersConsistency(t *testing.T, rawLeaves UseRawLeaves) {
+	data := make([]byte, 1000000)
+	u.NewTimeSeededRand().Read(data)
+
+	var roots []*cid.Cid
+	for _, workers := range []int{1, 4} {
+		ds := mdtest.Mock()
+		dbp := h.DagBuilderParams{
+			Dagserv:   ds,
+			Maxlinks:  h.DefaultLinksPerBlock,
+			RawLeaves: bool(rawLeaves),
+			Workers:   workers,
+		}
+		nd, err := Layout(dbp.New(chunker.NewSizeSplitter(bytes.NewReader(data), 1000)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := uio.NewDagReader(context.Background(), nd, ds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := arrComp(out, data); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, nd.Cid())
+	}
+	if !roots[0].Equals(roots[1]) {
+		t.Fatalf("the DAGs built with 1 and 4 workers differ: %s and %s", roots[0], roots[1])
+	}
+}
+
 func arrComp(a, b []byte) error {
 	if len(a) != len(b) {
 		return fmt.Errorf("arrays differ in length. %d != %d", len(a), len(b))
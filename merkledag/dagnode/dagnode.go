@@ -0,0 +1,23 @@
+// Package dagnode implements the nodes of the IPFS Merkle DAG: the dag-pb
+// ProtoNode and the RawNode, and registers their decoders, along with the
+// dag-cbor one.
+//
+// It doesn't depend on the blockservice nor on the network stack of the node,
+// unlike merkledag, which aliases its types, so that the packages reading
+// DAGs through an ipld.NodeGetter build for js/wasm with it. See docs/wasm.md.
+package dagnode
+
+import (
+	ipldcbor "gx/ipfs/QmSF1Ksgn5d7JCTBt4e1yp4wzs6tpYyweCZ4PcDYp3tNeK/go-ipld-cbor"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// TODO: We should move these registrations elsewhere. Really, most of the IPLD
+// functionality should go in a `go-ipld` repo but that will take a lot of work
+// and design.
+func init() {
+	ipld.Register(cid.DagProtobuf, DecodeProtobufBlock)
+	ipld.Register(cid.Raw, DecodeRawBlock)
+	ipld.Register(cid.DagCBOR, ipldcbor.DecodeBlock)
+}
@@ -1,4 +1,4 @@
-package merkledag
+package dagnode
 
 import (
 	"fmt"
@@ -7,22 +7,13 @@ import (
 	"sync"
 
 	bserv "github.com/ipfs/go-ipfs/blockservice"
+	cidset "github.com/ipfs/go-ipfs/thirdparty/cidset"
 
-	ipldcbor "gx/ipfs/QmSF1Ksgn5d7JCTBt4e1yp4wzs6tpYyweCZ4PcDYp3tNeK/go-ipld-cbor"
 	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
 	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
 )
 
-// TODO: We should move these registrations elsewhere. Really, most of the IPLD
-// functionality should go in a `go-ipld` repo but that will take a lot of work
-// and design.
-func init() {
-	ipld.Register(cid.DagProtobuf, DecodeProtobufBlock)
-	ipld.Register(cid.Raw, DecodeRawBlock)
-	ipld.Register(cid.DagCBOR, ipldcbor.DecodeBlock)
-}
-
 // contextKey is a type to use as value for the ProgressTracker contexts.
 type contextKey string
 
@@ -60,6 +51,12 @@ func (n *dagService) AddMany(ctx context.Context, nds []ipld.Node) error {
 	return n.Blocks.AddBlocks(blks)
 }
 
+// refusal is implemented by the errors of block services refusing to return
+// a block, like the ones of the denylist of a node, which callers compare.
+type refusal interface {
+	Refused()
+}
+
 // Get retrieves a node from the dagService, fetching the block in the BlockService
 func (n *dagService) Get(ctx context.Context, c *cid.Cid) (ipld.Node, error) {
 	if n == nil {
@@ -71,9 +68,13 @@ func (n *dagService) Get(ctx context.Context, c *cid.Cid) (ipld.Node, error) {
 
 	b, err := n.Blocks.GetBlock(ctx, c)
 	if err != nil {
-		if err == bserv.ErrNotFound {
+		switch err {
+		case bserv.ErrNotFound:
 			return nil, ipld.ErrNotFound
 		}
+		if _, ok := err.(refusal); ok {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to get block for %s: %v", c, err)
 	}
 
@@ -157,27 +158,36 @@ func (n *dagService) Session(ctx context.Context) ipld.NodeGetter {
 	return &sesGetter{bserv.NewSession(ctx, n.Blocks)}
 }
 
-// FetchGraph fetches all nodes that are children of the given node
+// FetchGraph fetches all nodes that are children of the given node, through
+// the session of the context if it has one (see WithSession). The visited
+// nodes are kept in the set made by the cidset.Factory of the context.
 func FetchGraph(ctx context.Context, root *cid.Cid, serv ipld.DAGService) error {
-	var ng ipld.NodeGetter = serv
-	ds, ok := serv.(*dagService)
-	if ok {
-		ng = &sesGetter{bserv.NewSession(ctx, ds.Blocks)}
+	ng, ok := ctx.Value(sessionContextKey{}).(ipld.NodeGetter)
+	if !ok {
+		ng = NewSession(ctx, serv)
 	}
 
-	v, _ := ctx.Value(progressContextKey).(*ProgressTracker)
-	if v == nil {
-		return EnumerateChildrenAsync(ctx, GetLinksDirect(ng), root, cid.NewSet().Visit)
+	set, err := cidset.New(ctx)
+	if err != nil {
+		return err
 	}
-	set := cid.NewSet()
-	visit := func(c *cid.Cid) bool {
-		if set.Visit(c) {
-			v.Increment()
-			return true
+	defer set.Close()
+
+	visit := set.Visit
+	if v, _ := ctx.Value(progressContextKey).(*ProgressTracker); v != nil {
+		visit = func(c *cid.Cid) bool {
+			if set.Visit(c) {
+				v.Increment()
+				return true
+			}
+			return false
 		}
-		return false
 	}
-	return EnumerateChildrenAsync(ctx, GetLinksDirect(ng), root, visit)
+
+	if err := EnumerateChildrenAsync(ctx, GetLinksDirect(ng), root, visit); err != nil {
+		return err
+	}
+	return set.Err()
 }
 
 // GetMany gets many nodes from the DAG at once.
@@ -301,8 +311,23 @@ func (p *ProgressTracker) Value() int {
 // 'fetchNodes' will start at a time
 var FetchGraphConcurrency = 8
 
+type concurrencyContextKey struct{}
+
+// WithConcurrency returns a context making EnumerateChildrenAsync fetch up to
+// n nodes at a time rather than FetchGraphConcurrency.
+func WithConcurrency(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, concurrencyContextKey{}, n)
+}
+
+func concurrency(ctx context.Context) int {
+	if n, ok := ctx.Value(concurrencyContextKey{}).(int); ok && n > 0 {
+		return n
+	}
+	return FetchGraphConcurrency
+}
+
 // EnumerateChildrenAsync is equivalent to EnumerateChildren *except* that it
-// fetches children in parallel.
+// fetches children in parallel, as many as set by WithConcurrency.
 //
 // NOTE: It *does not* make multiple concurrent calls to the passed `visit` function.
 func EnumerateChildrenAsync(ctx context.Context, getLinks GetLinks, c *cid.Cid, visit func(*cid.Cid) bool) error {
@@ -315,19 +340,28 @@ func EnumerateChildrenAsync(ctx context.Context, getLinks GetLinks, c *cid.Cid,
 	errChan := make(chan error)
 	fetchersCtx, cancel := context.WithCancel(ctx)
 
+	// the fetchers are done before returning, so that visit and getLinks
+	// aren't called once it returned
+	var wg sync.WaitGroup
+	defer wg.Wait()
 	defer cancel()
 
-	for i := 0; i < FetchGraphConcurrency; i++ {
+	for i := 0; i < concurrency(ctx); i++ {
+		wg.Add(1)
 		go func() {
+			defer wg.Done()
 			for ic := range feed {
 				setlk.Lock()
 				shouldVisit := visit(ic)
 				setlk.Unlock()
 
 				if shouldVisit {
-					links, err := getLinks(ctx, ic)
+					links, err := getLinks(fetchersCtx, ic)
 					if err != nil {
-						errChan <- err
+						select {
+						case errChan <- err:
+						case <-fetchersCtx.Done():
+						}
 						return
 					}
 
@@ -625,6 +625,39 @@ func TestEnumerateAsyncFailsNotFound(t *testing.T) {
 	}
 }
 
+func TestEnumerateChildrenAsyncConcurrency(t *testing.T) {
+	ds := dstest.Mock()
+	top, _ := mkDag(ds, 2)
+
+	var lk sync.Mutex
+	var running, max int
+	getLinks := func(ctx context.Context, c *cid.Cid) ([]*ipld.Link, error) {
+		lk.Lock()
+		running++
+		if running > max {
+			max = running
+		}
+		lk.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		lk.Lock()
+		running--
+		lk.Unlock()
+		return GetLinksDirect(ds)(ctx, c)
+	}
+
+	ctx := WithConcurrency(context.Background(), 2)
+	err := EnumerateChildrenAsync(ctx, getLinks, top, cid.NewSet().Visit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if max != 2 {
+		t.Fatalf("expected 2 concurrent fetches, got %d", max)
+	}
+}
+
 func TestProgressIndicator(t *testing.T) {
 	testProgressIndicator(t, 5)
 }
@@ -0,0 +1,86 @@
+package merkledag
+
+import (
+	dagnode "github.com/ipfs/go-ipfs/merkledag/dagnode"
+
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// The nodes live in the dagnode package, free of the blockservice; they're
+// aliased here for the packages storing and fetching them.
+
+// ProtoNode represents a node in the IPFS Merkle DAG.
+type ProtoNode = dagnode.ProtoNode
+
+// RawNode represents a node which only contains data.
+type RawNode = dagnode.RawNode
+
+// LinkSlice is a slice of ipld.Links
+type LinkSlice = dagnode.LinkSlice
+
+// ComboService implements ipld.DAGService, reading and writing with two of
+// them.
+type ComboService = dagnode.ComboService
+
+// ErrorService implements ipld.DAGService, returning 'Err' for every call.
+type ErrorService = dagnode.ErrorService
+
+// Common errors
+var (
+	ErrNotProtobuf  = dagnode.ErrNotProtobuf
+	ErrLinkNotFound = dagnode.ErrLinkNotFound
+	ErrReadOnly     = dagnode.ErrReadOnly
+)
+
+// V0CidPrefix returns a prefix for CIDv0
+func V0CidPrefix() cid.Prefix { return dagnode.V0CidPrefix() }
+
+// V1CidPrefix returns a prefix for CIDv1 with the default settings
+func V1CidPrefix() cid.Prefix { return dagnode.V1CidPrefix() }
+
+// PrefixForCidVersion returns the Protobuf prefix for a given CID version
+func PrefixForCidVersion(version int) (cid.Prefix, error) {
+	return dagnode.PrefixForCidVersion(version)
+}
+
+// NodeWithData builds a new Protonode with the given data.
+func NodeWithData(d []byte) *ProtoNode {
+	return dagnode.NodeWithData(d)
+}
+
+// NewRawNode creates a RawNode using the default sha2-256 hash function.
+func NewRawNode(data []byte) *RawNode {
+	return dagnode.NewRawNode(data)
+}
+
+// NewRawNodeWPrefix creates a RawNode with the hash function specified in
+// prefix.
+func NewRawNodeWPrefix(data []byte, prefix cid.Prefix) (*RawNode, error) {
+	return dagnode.NewRawNodeWPrefix(data, prefix)
+}
+
+// DecodeRawBlock is a block decoder for raw IPLD nodes conforming to
+// `ipld.DecodeBlockFunc`.
+func DecodeRawBlock(block blocks.Block) (ipld.Node, error) {
+	return dagnode.DecodeRawBlock(block)
+}
+
+// DecodeProtobuf decodes raw data and returns a new Node instance.
+func DecodeProtobuf(encoded []byte) (*ProtoNode, error) {
+	return dagnode.DecodeProtobuf(encoded)
+}
+
+// DecodeProtobufBlock is a block decoder for protobuf IPLD nodes conforming
+// to `ipld.DecodeBlockFunc`.
+func DecodeProtobufBlock(b blocks.Block) (ipld.Node, error) {
+	return dagnode.DecodeProtobufBlock(b)
+}
+
+// NewReadOnlyDagService takes a NodeGetter, and returns a full DAGService
+// implementation that returns ErrReadOnly when its 'write' methods are
+// invoked.
+func NewReadOnlyDagService(ng ipld.NodeGetter) ipld.DAGService {
+	return dagnode.NewReadOnlyDagService(ng)
+}
@@ -3,6 +3,8 @@ package merkledag
 import (
 	"context"
 
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
 )
 
@@ -19,3 +21,22 @@ func NewSession(ctx context.Context, g ipld.NodeGetter) ipld.NodeGetter {
 	}
 	return g
 }
+
+type sessionContextKey struct{}
+
+// WithSession returns a context making FetchGraph fetch through ses rather
+// than through a session of its own, so that the fetches of an operation
+// share their providers.
+func WithSession(ctx context.Context, ses ipld.NodeGetter) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, ses)
+}
+
+// SessionStat returns what the NodeGetter returned by NewSession fetched
+// from the network so far, and false if it isn't a session.
+func SessionStat(ng ipld.NodeGetter) (bserv.SessionStat, bool) {
+	sg, ok := ng.(*sesGetter)
+	if !ok {
+		return bserv.SessionStat{}, false
+	}
+	return sg.bs.Stat(), true
+}
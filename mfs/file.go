@@ -2,6 +2,7 @@ package mfs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 
@@ -13,6 +14,10 @@ import (
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
 )
 
+// ErrMetadataReadOnly is returned when opening a file with metadata for
+// writing.
+var ErrMetadataReadOnly = errors.New("files with metadata are read-only")
+
 type File struct {
 	parent childCloser
 
@@ -65,6 +70,17 @@ func (fi *File) Open(flags int, sync bool) (FileDescriptor, error) {
 			return nil, fmt.Errorf("unsupported fsnode type for 'file'")
 		case ft.TSymlink:
 			return nil, fmt.Errorf("symlinks not yet supported")
+		case ft.TMetadata:
+			// the file with metadata is read, not written, as its
+			// metadata would be lost
+			if flags != OpenReadOnly {
+				return nil, ErrMetadataReadOnly
+			}
+			file, err := fi.metadataFile(node)
+			if err != nil {
+				return nil, err
+			}
+			return fi.open(file, flags, sync)
 		case ft.TFile, ft.TRaw:
 			// OK case
 		}
@@ -72,6 +88,10 @@ func (fi *File) Open(flags int, sync bool) (FileDescriptor, error) {
 		// Ok as well.
 	}
 
+	return fi.open(node, flags, sync)
+}
+
+func (fi *File) open(node ipld.Node, flags int, sync bool) (FileDescriptor, error) {
 	switch flags {
 	case OpenReadOnly:
 		fi.desclock.RLock()
@@ -96,16 +116,35 @@ func (fi *File) Open(flags int, sync bool) (FileDescriptor, error) {
 	}, nil
 }
 
+// metadataFile returns the file described by the metadata node nd.
+func (fi *File) metadataFile(nd *dag.ProtoNode) (ipld.Node, error) {
+	if len(nd.Links()) == 0 {
+		return nil, fmt.Errorf("incorrectly formatted metadata object")
+	}
+	return nd.Links()[0].GetNode(context.TODO(), fi.dserv)
+}
+
 // Size returns the size of this file
 func (fi *File) Size() (int64, error) {
 	fi.nodelk.Lock()
 	defer fi.nodelk.Unlock()
-	switch nd := fi.node.(type) {
+	return fi.size(fi.node)
+}
+
+func (fi *File) size(node ipld.Node) (int64, error) {
+	switch nd := node.(type) {
 	case *dag.ProtoNode:
 		pbd, err := ft.FromBytes(nd.Data())
 		if err != nil {
 			return 0, err
 		}
+		if pbd.GetType() == ft.TMetadata {
+			file, err := fi.metadataFile(nd)
+			if err != nil {
+				return 0, err
+			}
+			return fi.size(file)
+		}
 		return int64(pbd.GetFilesize()), nil
 	case *dag.RawNode:
 		return int64(len(nd.RawData())), nil
@@ -122,6 +161,11 @@ func (fi *File) GetNode() (ipld.Node, error) {
 }
 
 func (fi *File) Flush() error {
+	if fi.isMetadata() {
+		// never written, see Open
+		return nil
+	}
+
 	// open the file in fullsync mode
 	fd, err := fi.Open(OpenWriteOnly, true)
 	if err != nil {
@@ -133,6 +177,17 @@ func (fi *File) Flush() error {
 	return fd.Flush()
 }
 
+func (fi *File) isMetadata() bool {
+	fi.nodelk.Lock()
+	defer fi.nodelk.Unlock()
+	pbn, ok := fi.node.(*dag.ProtoNode)
+	if !ok {
+		return false
+	}
+	fsn, err := ft.FSNodeFromBytes(pbn.Data())
+	return err == nil && fsn.Type == ft.TMetadata
+}
+
 func (fi *File) Sync() error {
 	// just being able to take the writelock means the descriptor is synced
 	fi.desclock.Lock()
@@ -220,6 +220,8 @@ func FlushPath(rt *Root, pth string) error {
 		return err
 	}
 
-	rt.repub.WaitPub()
+	if rt.repub != nil {
+		rt.repub.WaitPub()
+	}
 	return nil
 }
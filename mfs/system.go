@@ -127,6 +127,21 @@ func (kr *Root) Flush() error {
 	return nil
 }
 
+// Sync publishes the current value of the root right away, rather than after
+// the delay of the republisher.
+func (kr *Root) Sync(ctx context.Context) error {
+	nd, err := kr.GetValue().GetNode()
+	if err != nil {
+		return err
+	}
+
+	if kr.repub != nil {
+		kr.repub.setVal(nd.Cid())
+		return kr.repub.publish(ctx)
+	}
+	return nil
+}
+
 // FlushMemFree flushes the root directory and then uncaches all of its links.
 // This has the effect of clearing out potentially stale references and allows
 // them to be garbage collected.
@@ -48,6 +48,13 @@ test_go_expensive: test_go_test $$(TEST_GO_BUILD)
 .PHONY: test_go_expensive
 TEST_GO += test_go_expensive
 
+# the packages which must build for browsers, see docs/wasm.md
+GO_WASM_PKGS = blocks/fetcher merkledag/dagnode path path/resolver unixfs unixfs/io
+
+test_go_wasm:
+	GOOS=js GOARCH=wasm $(GOCC) build $(go-flags-with-tags) $(addprefix github.com/ipfs/go-ipfs/,$(GO_WASM_PKGS))
+.PHONY: test_go_wasm
+
 test_go_fmt:
 	bin/test-go-fmt
 .PHONY: test_go_fmt
@@ -0,0 +1,100 @@
+// Package mobile is a small API over an IPFS node for Android and iOS apps,
+// made to be bound with gomobile:
+//
+//	gomobile bind -target=android github.com/ipfs/go-ipfs/mobile
+//
+// It only uses the types gomobile supports. Apps should call Suspend when
+// they go to the background, so that the node stops using the network and
+// saves its state before the OS freezes or kills the app, and Resume when
+// they come back to the foreground.
+package mobile
+
+import (
+	"context"
+	"io/ioutil"
+
+	core "github.com/ipfs/go-ipfs/core"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+)
+
+// keyBits is the size of the RSA key of the repos created by InitRepo.
+const keyBits = 2048
+
+// InitRepo creates a repo at repoPath, with a new identity and the lowpower
+// profile applied, unless there is one already.
+func InitRepo(repoPath string) error {
+	if fsrepo.IsInitialized(repoPath) {
+		return nil
+	}
+
+	conf, err := config.Init(ioutil.Discard, keyBits)
+	if err != nil {
+		return err
+	}
+	if err := config.Profiles["lowpower"].Transform(conf); err != nil {
+		return err
+	}
+	return fsrepo.Init(repoPath, conf)
+}
+
+// Node is a running IPFS node.
+type Node struct {
+	node   *core.IpfsNode
+	cancel context.CancelFunc
+}
+
+// NewNode starts an online node with the repo at repoPath, created
+// beforehand with InitRepo.
+func NewNode(repoPath string) (*Node, error) {
+	r, err := fsrepo.Open(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n, err := core.NewNode(ctx, &core.BuildCfg{
+		Repo:      r,
+		Online:    true,
+		Permanent: true,
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	n.SetLocal(false)
+
+	if err := n.Bootstrap(core.DefaultBootstrapConfig); err != nil {
+		n.Close()
+		cancel()
+		return nil, err
+	}
+	return &Node{node: n, cancel: cancel}, nil
+}
+
+// ID returns the peer ID of the node.
+func (n *Node) ID() string {
+	return n.node.Identity.Pretty()
+}
+
+// Suspend stops all network activity and saves the state of the node. The
+// node keeps its repo open, so resuming is quick.
+func (n *Node) Suspend() error {
+	return n.node.Suspend()
+}
+
+// Resume brings the node back online after Suspend.
+func (n *Node) Resume() error {
+	return n.node.Resume()
+}
+
+// Suspended returns whether the node is suspended.
+func (n *Node) Suspended() bool {
+	return n.node.Suspended()
+}
+
+// Close stops the node and closes its repo.
+func (n *Node) Close() error {
+	defer n.cancel()
+	return n.node.Close()
+}
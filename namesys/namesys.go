@@ -31,13 +31,32 @@ type mpns struct {
 	ipnsPublisher                               Publisher
 
 	cache *lru.Cache
+	// options are the default options of the resolutions.
+	options []opts.ResolveOpt
+}
+
+// ResolvePolicy sets how a NameSystem resolves names by default, trading the
+// freshness of the values for the speed of the resolutions.
+type ResolvePolicy struct {
+	// CacheSize is the number of names whose value is cached, none when
+	// zero.
+	CacheSize int
+	// Options are applied to all the resolutions, before their own
+	// options.
+	Options []opts.ResolveOpt
 }
 
 // NewNameSystem will construct the IPFS naming system based on Routing
 func NewNameSystem(r routing.ValueStore, ds ds.Datastore, cachesize int) NameSystem {
+	return NewNameSystemWithPolicy(r, ds, ResolvePolicy{CacheSize: cachesize})
+}
+
+// NewNameSystemWithPolicy constructs the IPFS naming system based on Routing,
+// resolving the names with policy.
+func NewNameSystemWithPolicy(r routing.ValueStore, ds ds.Datastore, policy ResolvePolicy) NameSystem {
 	var cache *lru.Cache
-	if cachesize > 0 {
-		cache, _ = lru.New(cachesize)
+	if policy.CacheSize > 0 {
+		cache, _ = lru.New(policy.CacheSize)
 	}
 
 	return &mpns{
@@ -46,7 +65,18 @@ func NewNameSystem(r routing.ValueStore, ds ds.Datastore, cachesize int) NameSys
 		ipnsResolver:     NewIpnsResolver(r),
 		ipnsPublisher:    NewIpnsPublisher(r, ds),
 		cache:            cache,
+		options:          policy.Options,
+	}
+}
+
+// withDefaults returns options after the default options of ns.
+func (ns *mpns) withDefaults(options []opts.ResolveOpt) []opts.ResolveOpt {
+	if len(ns.options) == 0 {
+		return options
 	}
+	all := make([]opts.ResolveOpt, 0, len(ns.options)+len(options))
+	all = append(all, ns.options...)
+	return append(all, options...)
 }
 
 const DefaultResolverCacheTTL = time.Minute
@@ -61,7 +91,7 @@ func (ns *mpns) Resolve(ctx context.Context, name string, options ...opts.Resolv
 		return path.ParsePath("/ipfs/" + name)
 	}
 
-	return resolve(ctx, ns, name, opts.ProcessOpts(options), "/ipns/")
+	return resolve(ctx, ns, name, opts.ProcessOpts(ns.withDefaults(options)), "/ipns/")
 }
 
 // resolveOnce implements resolver.
@@ -77,6 +77,23 @@ func TestNamesysResolution(t *testing.T) {
 	testResolution(t, r, "/ipns/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD", 3, "/ipns/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy", ErrResolveRecursion)
 }
 
+func TestResolvePolicyOptions(t *testing.T) {
+	r := &mpns{
+		ipnsResolver: mockResolverOne(),
+		dnsResolver:  mockResolverTwo(),
+		options:      []opts.ResolveOpt{opts.Depth(1)},
+	}
+
+	// the default options of the policy apply
+	p, err := r.Resolve(context.Background(), "/ipns/QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n")
+	if err != ErrResolveRecursion || p.String() != "/ipns/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy" {
+		t.Fatalf("expected the resolution to stop at depth 1, got %s, %v", p, err)
+	}
+
+	// the options of the resolution override them
+	testResolution(t, r, "/ipns/QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n", opts.DefaultDepthLimit, "/ipfs/Qmcqtw8FfrVSBaRmbWwHxt3AuySBhJLcvmFYi3Lbc4xnwj", nil)
+}
+
 func TestPublishWithCache0(t *testing.T) {
 	dst := dssync.MutexWrap(ds.NewMapDatastore())
 	priv, _, err := ci.GenerateKeyPair(ci.RSA, 1024)
@@ -27,6 +27,10 @@ type ResolveOpts struct {
 	// timeout (although there is an implicit timeout due to dial
 	// timeouts within the DHT)
 	DhtTimeout time.Duration
+	// How long the value resolved is cached, instead of the TTL of the
+	// IPNS record it comes from. It is never cached past the EOL of the
+	// record. A zero value uses the TTL of the record.
+	CacheTTL time.Duration
 }
 
 // DefaultResolveOpts returns the default options for resolving
@@ -64,6 +68,14 @@ func DhtTimeout(timeout time.Duration) ResolveOpt {
 	}
 }
 
+// CacheTTL is how long the value resolved is cached, instead of the TTL of
+// the IPNS record it comes from. A zero value uses the TTL of the record
+func CacheTTL(ttl time.Duration) ResolveOpt {
+	return func(o *ResolveOpts) {
+		o.CacheTTL = ttl
+	}
+}
+
 // ProcessOpts converts an array of ResolveOpt into a ResolveOpts object
 func ProcessOpts(opts []ResolveOpt) *ResolveOpts {
 	rsopts := DefaultResolveOpts()
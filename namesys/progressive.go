@@ -0,0 +1,305 @@
+package namesys
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	opts "github.com/ipfs/go-ipfs/namesys/opts"
+	pb "github.com/ipfs/go-ipfs/namesys/pb"
+	path "github.com/ipfs/go-ipfs/path"
+
+	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
+	routing "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing"
+	ropts "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing/options"
+	dht "gx/ipfs/QmagBkuFfySAMouyXeiy8XjV1GyfNAgTCuVYGF9z3Z4Vvc/go-libp2p-kad-dht"
+)
+
+// Progress is a value found while resolving a name progressively.
+type Progress struct {
+	Path path.Path
+	// Sequence is the sequence number of the IPNS record the value comes
+	// from, zero for the names resolved otherwise.
+	Sequence uint64
+	// Final marks the value of the complete resolution, the last one sent.
+	Final bool
+	// Err is the error the resolution failed with, on the final value
+	// only.
+	Err error
+}
+
+// ProgressiveResolver is a Resolver which can tell the values it finds
+// before its resolution completes.
+type ProgressiveResolver interface {
+	Resolver
+
+	// ResolveProgressive resolves name like Resolve, sending the value of
+	// the best IPNS record found so far each time a better one is found,
+	// then the value of the complete resolution. The channel is closed
+	// after the final value.
+	ResolveProgressive(ctx context.Context, name string, options ...opts.ResolveOpt) <-chan Progress
+}
+
+// ResolveProgressive implements ProgressiveResolver.
+func (r *IpnsResolver) ResolveProgressive(ctx context.Context, name string, options ...opts.ResolveOpt) <-chan Progress {
+	o := opts.ProcessOpts(options)
+	records := r.searchRecords(ctx, name, o)
+	return progress(ctx, records, func(p path.Path) (path.Path, error) {
+		return resolveRest(ctx, r, p, o)
+	}, nil)
+}
+
+// ResolveProgressive implements ProgressiveResolver. The IPNS names are
+// resolved progressively, the other names and the cached ones at once.
+func (ns *mpns) ResolveProgressive(ctx context.Context, name string, options ...opts.ResolveOpt) <-chan Progress {
+	options = ns.withDefaults(options)
+	ipns, ok := ns.ipnsResolver.(*IpnsResolver)
+	segments := strings.SplitN(name, "/", 4)
+	if !ok || len(segments) < 3 || segments[0] != "" || segments[1] != "ipns" {
+		return resolvedProgress(ns.Resolve(ctx, name, options...))
+	}
+	key := segments[2]
+	if _, err := mh.FromB58String(key); err != nil {
+		return resolvedProgress(ns.Resolve(ctx, name, options...))
+	}
+	if _, ok := ns.cacheGet(key); ok {
+		return resolvedProgress(ns.Resolve(ctx, name, options...))
+	}
+
+	o := opts.ProcessOpts(options)
+	records := ns.searchRecordsOnce(ctx, ipns, key, o)
+	return progress(ctx, records, func(p path.Path) (path.Path, error) {
+		if len(segments) > 3 {
+			var err error
+			p, err = path.FromSegments("", strings.TrimRight(p.String(), "/"), segments[3])
+			if err != nil {
+				return "", err
+			}
+		}
+		return resolveRest(ctx, ns, p, o)
+	}, func(rec *ipnsRecord) {
+		ns.cacheSet(key, rec.path, rec.ttl(o.CacheTTL))
+	})
+}
+
+// searchRecordsOnce is searchRecords failing like resolveOnce.
+func (ns *mpns) searchRecordsOnce(ctx context.Context, r *IpnsResolver, key string, options *opts.ResolveOpts) <-chan recordResult {
+	out := make(chan recordResult)
+	go func() {
+		defer close(out)
+		for res := range r.searchRecords(ctx, key, options) {
+			if res.err != nil {
+				res.err = ErrResolveFailed
+			}
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// resolvedProgress returns the final Progress of a resolution done at once.
+func resolvedProgress(p path.Path, err error) <-chan Progress {
+	out := make(chan Progress, 1)
+	out <- Progress{Path: p, Final: true, Err: err}
+	close(out)
+	return out
+}
+
+// resolveRest resolves p further with r when it is a name, within the depth
+// left by the record it comes from.
+func resolveRest(ctx context.Context, r Resolver, p path.Path, options *opts.ResolveOpts) (path.Path, error) {
+	if strings.HasPrefix(p.String(), "/ipfs/") {
+		return p, nil
+	}
+	if options.Depth == 1 {
+		return p, ErrResolveRecursion
+	}
+
+	depth := options.Depth
+	if depth > 1 {
+		depth--
+	}
+	return r.Resolve(ctx, p.String(),
+		opts.Depth(depth),
+		opts.DhtRecordCount(options.DhtRecordCount),
+		opts.DhtTimeout(options.DhtTimeout),
+		opts.CacheTTL(options.CacheTTL))
+}
+
+// progress sends the Progress of the records found, their values resolved
+// further by rest. The partial values which fail to resolve are skipped.
+// final is called with the final record, if any.
+func progress(ctx context.Context, records <-chan recordResult, rest func(path.Path) (path.Path, error), final func(*ipnsRecord)) <-chan Progress {
+	out := make(chan Progress)
+	go func() {
+		defer close(out)
+		for res := range records {
+			pr := Progress{Final: res.final, Err: res.err}
+			if res.err == nil {
+				pr.Sequence = res.rec.entry.GetSequence()
+				pr.Path, pr.Err = rest(res.rec.path)
+				if pr.Err != nil && !res.final {
+					log.Debugf("skipping the partial value %s: %s", res.rec.path, pr.Err)
+					continue
+				}
+				if res.final && final != nil {
+					final(res.rec)
+				}
+			}
+
+			select {
+			case out <- pr:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// recordResult is a record found by searchRecords.
+type recordResult struct {
+	rec   *ipnsRecord
+	final bool
+	err   error
+}
+
+// valueSearcher is a routing system streaming the values it finds for a key,
+// each better than the previous ones, such as the DHT.
+type valueSearcher interface {
+	SearchValue(ctx context.Context, key string, opts ...ropts.Option) (<-chan []byte, error)
+}
+
+// searchRecords looks the IPNS record of name up for the quorum of
+// options.DhtRecordCount records, sending each record found which is better
+// than the previous ones, then the best one as final.
+func (r *IpnsResolver) searchRecords(ctx context.Context, name string, options *opts.ResolveOpts) <-chan recordResult {
+	out := make(chan recordResult)
+	go func() {
+		defer close(out)
+		send := func(res recordResult) bool {
+			select {
+			case out <- res:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		qctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		if options.DhtTimeout != 0 {
+			// Resolution must complete within the timeout
+			qctx, cancel = context.WithTimeout(qctx, options.DhtTimeout)
+			defer cancel()
+		}
+
+		ipnsKey, err := r.recordKey(qctx, name)
+		if err != nil {
+			send(recordResult{final: true, err: err})
+			return
+		}
+
+		vals, lookupErr := r.searchValues(qctx, ipnsKey, int(options.DhtRecordCount))
+
+		var best *ipnsRecord
+		for val := range vals {
+			rec, err := parseRecord(val)
+			if err != nil {
+				log.Debugf("RoutingResolver: could not parse value for name %s: %s", name, err)
+				continue
+			}
+			if best != nil && !betterRecord(rec, best) {
+				continue
+			}
+			best = rec
+			if !send(recordResult{rec: best}) {
+				return
+			}
+		}
+
+		if best == nil {
+			err := lookupErr()
+			if err == nil {
+				err = qctx.Err()
+			}
+			if err == nil {
+				err = routing.ErrNotFound
+			}
+			send(recordResult{final: true, err: err})
+			return
+		}
+		send(recordResult{rec: best, final: true})
+	}()
+	return out
+}
+
+// searchValues streams the values found for key, with a quorum of count.
+// The values are sent as they are found when the routing system can stream
+// them. Otherwise, the first value found is looked up along with the quorum,
+// and sent as soon as it is. Once the channel is closed, the returned function
+// returns the error of the lookup, if any.
+func (r *IpnsResolver) searchValues(ctx context.Context, key string, count int) (<-chan []byte, func() error) {
+	if s, ok := r.routing.(valueSearcher); ok {
+		vals, err := s.SearchValue(ctx, key, dht.Quorum(count))
+		if err != nil {
+			out := make(chan []byte)
+			close(out)
+			return out, func() error { return err }
+		}
+		return vals, func() error { return nil }
+	}
+
+	out := make(chan []byte)
+	get := func(quorum int) error {
+		recs, err := r.routing.GetValues(ctx, key, quorum)
+		if err != nil {
+			return err
+		}
+		for _, rec := range recs {
+			// the invalid records are blanked
+			if len(rec.Val) == 0 {
+				continue
+			}
+			select {
+			case out <- rec.Val:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	if count > 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := get(1); err != nil {
+				log.Debugf("RoutingResolver: could not get the first value for %s: %s", key, err)
+			}
+		}()
+	}
+	var err error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err = get(count)
+	}()
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out, func() error { return err }
+}
+
+// betterRecord returns whether a is better than b, as selected by the IPNS
+// validator.
+func betterRecord(a, b *ipnsRecord) bool {
+	i, err := selectRecord([]*pb.IpnsEntry{b.entry, a.entry}, [][]byte{b.val, a.val})
+	return err == nil && i == 1
+}
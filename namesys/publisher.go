@@ -57,6 +57,33 @@ func IpnsDsKey(id peer.ID) ds.Key {
 	return ds.NewKey("/ipns/" + base32.RawStdEncoding.EncodeToString([]byte(id)))
 }
 
+// publishedDsKey is the key of the time the record of id was last published.
+func publishedDsKey(id peer.ID) ds.Key {
+	return ds.NewKey("/local/ipns/published/" + base32.RawStdEncoding.EncodeToString([]byte(id)))
+}
+
+// LastPublished returns when this node last published the record of id to
+// the routing system, the zero time if it never did, or not since the time
+// started to be kept.
+func (p *IpnsPublisher) LastPublished(id peer.ID) (time.Time, error) {
+	var t time.Time
+	val, err := p.ds.Get(publishedDsKey(id))
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return t, nil
+	default:
+		return t, err
+	}
+
+	b, ok := val.([]byte)
+	if !ok {
+		return t, fmt.Errorf("found a publication time that we couldn't convert to a value")
+	}
+	err = t.UnmarshalText(b)
+	return t, err
+}
+
 // PublishedNames returns the latest IPNS records published by this node and
 // their expiration times.
 //
@@ -207,7 +234,22 @@ func (p *IpnsPublisher) PublishWithEOL(ctx context.Context, k ci.PrivKey, value
 		return err
 	}
 
-	return PutRecordToRouting(ctx, p.routing, k.GetPublic(), record)
+	if err := PutRecordToRouting(ctx, p.routing, k.GetPublic(), record); err != nil {
+		return err
+	}
+
+	id, err := peer.IDFromPrivateKey(k)
+	if err != nil {
+		return err
+	}
+	now, err := time.Now().UTC().MarshalText()
+	if err != nil {
+		return err
+	}
+	if err := p.ds.Put(publishedDsKey(id), now); err != nil {
+		log.Warningf("failed to record the publication of %s: %s", id.Pretty(), err)
+	}
+	return nil
 }
 
 // setting the TTL on published records is an experimental feature.
@@ -111,3 +111,40 @@ func TestRSAPublisher(t *testing.T) {
 func TestEd22519Publisher(t *testing.T) {
 	testNamekeyPublisher(t, ci.Ed25519, ds.ErrNotFound, false)
 }
+
+func TestLastPublished(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	r := mockrouting.NewServer().ClientWithDatastore(context.Background(), testutil.RandIdentityOrFatal(t), dstore)
+	publisher := NewIpnsPublisher(r, dstore)
+
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last, err := publisher.LastPublished(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !last.IsZero() {
+		t.Fatalf("expected no publication yet, got %s", last)
+	}
+
+	before := time.Now().Add(-time.Second)
+	err = publisher.Publish(context.Background(), privk, path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last, err = publisher.LastPublished(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Before(before) || last.After(time.Now()) {
+		t.Fatalf("expected the time of the publication, got %s", last)
+	}
+}
@@ -2,7 +2,12 @@ package republisher
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
 	"time"
 
 	keystore "github.com/ipfs/go-ipfs/keystore"
@@ -10,6 +15,7 @@ import (
 	pb "github.com/ipfs/go-ipfs/namesys/pb"
 	path "github.com/ipfs/go-ipfs/path"
 
+	metrics "gx/ipfs/QmRg1gKTHzc3CZXSKzem8aR4E3TubFhbgXwfVuWnSK5CC5/go-metrics-interface"
 	goprocess "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
 	gpctx "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess/context"
 	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
@@ -35,6 +41,39 @@ var FailureRetryInterval = time.Minute * 5
 // DefaultRecordLifetime is the default lifetime for IPNS records
 const DefaultRecordLifetime = time.Hour * 24
 
+// DefaultWorkers is the default number of keys republished at once.
+const DefaultWorkers = 4
+
+// republishTimeout bounds the republish of a key.
+const republishTimeout = 5 * time.Minute
+
+// statusKey is the key of the republish status of the keys in the datastore.
+var statusKey = ds.NewKey("/local/ipns/republisher")
+
+// KeyStatus is the republish status of a key.
+type KeyStatus struct {
+	// Name is the name of the key in the keystore, "self" for the key of
+	// the node.
+	Name string
+	ID   string
+	// LastAttempt and LastSuccess are the times of the last republish and
+	// of the last successful one.
+	LastAttempt time.Time
+	LastSuccess time.Time
+	// LastError is the error of the last republish, when it failed.
+	LastError string `json:",omitempty"`
+	// Failures is the number of republishes which failed in a row.
+	Failures int
+}
+
+// Status is the republish status of the keys.
+type Status struct {
+	// Next is when the keys are republished next, zero when unknown.
+	Next time.Time
+	// Keys are the keys with a record republished, sorted by name.
+	Keys []KeyStatus
+}
+
 type Republisher struct {
 	ns   namesys.Publisher
 	ds   ds.Datastore
@@ -45,10 +84,26 @@ type Republisher struct {
 
 	// how long records that are republished should be valid for
 	RecordLifetime time.Duration
+
+	// Workers is the number of keys republished at once.
+	Workers int
+
+	republished metrics.Counter
+	failed      metrics.Counter
+	failing     metrics.Gauge
+
+	mu   sync.Mutex
+	next time.Time
+	// keys are keyed by base58 ID.
+	keys map[string]*KeyStatus
 }
 
 // NewRepublisher creates a new Republisher
 func NewRepublisher(ns namesys.Publisher, ds ds.Datastore, self ic.PrivKey, ks keystore.Keystore) *Republisher {
+	keys, err := loadStatus(ds)
+	if err != nil {
+		log.Warning("ignoring the republish status: ", err)
+	}
 	return &Republisher{
 		ns:             ns,
 		ds:             ds,
@@ -56,6 +111,14 @@ func NewRepublisher(ns namesys.Publisher, ds ds.Datastore, self ic.PrivKey, ks k
 		ks:             ks,
 		Interval:       DefaultRebroadcastInterval,
 		RecordLifetime: DefaultRecordLifetime,
+		Workers:        DefaultWorkers,
+		republished: metrics.New("ipfs.ipns.republish_total",
+			"Number of IPNS records republished").Counter(),
+		failed: metrics.New("ipfs.ipns.republish_failures_total",
+			"Number of IPNS record republishes which failed").Counter(),
+		failing: metrics.New("ipfs.ipns.republish_failing_keys",
+			"Number of keys whose last IPNS record republish failed").Gauge(),
+		keys: keys,
 	}
 }
 
@@ -65,16 +128,19 @@ func (rp *Republisher) Run(proc goprocess.Process) {
 	if rp.Interval < InitialRebroadcastDelay {
 		timer.Reset(rp.Interval)
 	}
+	rp.setNext(time.Now().Add(InitialRebroadcastDelay))
 
 	for {
 		select {
 		case <-timer.C:
 			timer.Reset(rp.Interval)
+			rp.setNext(time.Now().Add(rp.Interval))
 			err := rp.republishEntries(proc)
 			if err != nil {
 				log.Error("Republisher failed to republish: ", err)
 				if FailureRetryInterval < rp.Interval {
 					timer.Reset(FailureRetryInterval)
+					rp.setNext(time.Now().Add(FailureRetryInterval))
 				}
 			}
 		case <-proc.Closing():
@@ -83,6 +149,21 @@ func (rp *Republisher) Run(proc goprocess.Process) {
 	}
 }
 
+func (rp *Republisher) setNext(t time.Time) {
+	rp.mu.Lock()
+	rp.next = t
+	rp.mu.Unlock()
+}
+
+// namedKey is a key republished.
+type namedKey struct {
+	name string
+	priv ic.PrivKey
+}
+
+// republishEntries republishes the records of all the keys, spread over a
+// tenth of the interval and Workers at once. It fails when one of them
+// failed.
 func (rp *Republisher) republishEntries(p goprocess.Process) error {
 	ctx, cancel := context.WithCancel(gpctx.OnClosingContext(p))
 	defer cancel()
@@ -91,11 +172,7 @@ func (rp *Republisher) republishEntries(p goprocess.Process) error {
 	// because:
 	// 1. There's no way to get keys from the keystore by ID.
 	// 2. We don't actually have access to the IPNS publisher.
-	err := rp.republishEntry(ctx, rp.self)
-	if err != nil {
-		return err
-	}
-
+	keys := []namedKey{{name: "self", priv: rp.self}}
 	if rp.ks != nil {
 		keyNames, err := rp.ks.List()
 		if err != nil {
@@ -106,15 +183,115 @@ func (rp *Republisher) republishEntries(p goprocess.Process) error {
 			if err != nil {
 				return err
 			}
-			err = rp.republishEntry(ctx, priv)
-			if err != nil {
-				return err
-			}
+			keys = append(keys, namedKey{name: name, priv: priv})
+		}
+	}
+	rp.forgetOthers(keys)
 
+	// spread the republishes so that they don't all hit the network at once
+	jitter := int64(rp.Interval / 10)
+	delays := make([]time.Duration, len(keys))
+	for i := range keys {
+		if jitter > 0 {
+			delays[i] = time.Duration(rand.Int63n(jitter))
 		}
 	}
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return delays[order[i]] < delays[order[j]] })
+
+	jobs := make(chan namedKey)
+	go func() {
+		defer close(jobs)
+		start := time.Now()
+		for _, i := range order {
+			select {
+			case <-time.After(time.Until(start.Add(delays[i]))):
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- keys[i]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	workers := rp.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range jobs {
+				if err := rp.republishKey(ctx, k); err != nil {
+					log.Errorf("failed to republish the ipns entry of key %s: %s", k.name, err)
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := rp.saveStatus(); err != nil {
+		log.Error("failed to save the republish status: ", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to republish %d of %d keys", failed, len(keys))
+	}
+	return ctx.Err()
+}
+
+// republishKey republishes the record of k, recording the outcome.
+func (rp *Republisher) republishKey(ctx context.Context, k namedKey) error {
+	id, err := peer.IDFromPrivateKey(k.priv)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	ctx, cancel := context.WithTimeout(ctx, republishTimeout)
+	defer cancel()
+	start := time.Now()
+	err = rp.republishEntry(ctx, k.priv)
+	if err == errNoEntry {
+		return nil
+	}
+	if err != nil && ctx.Err() != nil && ctx.Err() != context.DeadlineExceeded {
+		// shutting down, not a failure of the key
+		return nil
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	st := rp.keys[id.Pretty()]
+	if st == nil {
+		st = &KeyStatus{ID: id.Pretty()}
+		rp.keys[id.Pretty()] = st
+	}
+	st.Name = k.name
+	st.LastAttempt = start.UTC()
+	if err == nil {
+		st.LastSuccess = st.LastAttempt
+		st.LastError = ""
+		st.Failures = 0
+		rp.republished.Inc()
+	} else {
+		st.LastError = err.Error()
+		st.Failures++
+		rp.failed.Inc()
+	}
+	rp.updateFailing()
+	return err
 }
 
 func (rp *Republisher) republishEntry(ctx context.Context, priv ic.PrivKey) error {
@@ -128,9 +305,6 @@ func (rp *Republisher) republishEntry(ctx context.Context, priv ic.PrivKey) erro
 	// Look for it locally only
 	p, err := rp.getLastVal(id)
 	if err != nil {
-		if err == errNoEntry {
-			return nil
-		}
 		return err
 	}
 
@@ -139,6 +313,100 @@ func (rp *Republisher) republishEntry(ctx context.Context, priv ic.PrivKey) erro
 	return rp.ns.PublishWithEOL(ctx, priv, p, eol)
 }
 
+// forgetOthers forgets the status of the keys no longer in keys.
+func (rp *Republisher) forgetOthers(keys []namedKey) {
+	ids := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if id, err := peer.IDFromPrivateKey(k.priv); err == nil {
+			ids[id.Pretty()] = true
+		}
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	for id := range rp.keys {
+		if !ids[id] {
+			delete(rp.keys, id)
+		}
+	}
+	rp.updateFailing()
+}
+
+// updateFailing updates the number of failing keys. It must be called with
+// the lock held.
+func (rp *Republisher) updateFailing() {
+	failing := 0
+	for _, st := range rp.keys {
+		if st.Failures > 0 {
+			failing++
+		}
+	}
+	rp.failing.Set(float64(failing))
+}
+
+// Status returns the republish status of the keys.
+func (rp *Republisher) Status() *Status {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return newStatus(rp.next, rp.keys)
+}
+
+// LoadStatus returns the republish status of the keys saved in d, when the
+// republisher isn't running.
+func LoadStatus(d ds.Datastore) (*Status, error) {
+	keys, err := loadStatus(d)
+	if err != nil {
+		return nil, err
+	}
+	return newStatus(time.Time{}, keys), nil
+}
+
+func newStatus(next time.Time, keys map[string]*KeyStatus) *Status {
+	st := &Status{Next: next, Keys: make([]KeyStatus, 0, len(keys))}
+	for _, k := range keys {
+		st.Keys = append(st.Keys, *k)
+	}
+	sort.Slice(st.Keys, func(i, j int) bool {
+		if st.Keys[i].Name != st.Keys[j].Name {
+			return st.Keys[i].Name < st.Keys[j].Name
+		}
+		return st.Keys[i].ID < st.Keys[j].ID
+	})
+	return st
+}
+
+func loadStatus(d ds.Datastore) (map[string]*KeyStatus, error) {
+	keys := make(map[string]*KeyStatus)
+	val, err := d.Get(statusKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return keys, nil
+	default:
+		return keys, err
+	}
+
+	b, ok := val.([]byte)
+	if !ok {
+		return keys, errors.New("found a republish status that we couldn't convert to a value")
+	}
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return make(map[string]*KeyStatus), err
+	}
+	return keys, nil
+}
+
+// saveStatus saves the republish status of the keys.
+func (rp *Republisher) saveStatus() error {
+	rp.mu.Lock()
+	b, err := json.Marshal(rp.keys)
+	rp.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return rp.ds.Put(statusKey, b)
+}
+
 func (rp *Republisher) getLastVal(id peer.ID) (path.Path, error) {
 	// Look for it locally only
 	vali, err := rp.ds.Get(namesys.IpnsDsKey(id))
@@ -92,6 +92,22 @@ func TestRepublish(t *testing.T) {
 	if err := verifyResolution(nodes, name, p); err != nil {
 		t.Fatal(err)
 	}
+
+	// and the republish should be recorded
+	status, err := LoadStatus(publisher.Repo.Datastore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Keys) != 1 {
+		t.Fatalf("expected the status of one key, got %d", len(status.Keys))
+	}
+	k := status.Keys[0]
+	if k.Name != "self" || k.ID != publisher.Identity.Pretty() {
+		t.Fatalf("expected the status of self, got %s (%s)", k.Name, k.ID)
+	}
+	if k.LastSuccess.IsZero() || k.Failures != 0 || k.LastError != "" {
+		t.Fatalf("expected a successful republish, got %+v", k)
+	}
 }
 
 func verifyResolution(nodes []*core.IpfsNode, key string, exp path.Path) error {
@@ -6,10 +6,14 @@ import (
 	"testing"
 	"time"
 
+	dhtstats "github.com/ipfs/go-ipfs/dhtstats"
 	path "github.com/ipfs/go-ipfs/path"
 
 	testutil "gx/ipfs/QmPdxCaVp4jZ9RbxqZADvKH6kiCR5jHvdR5f2ycjAY6T2a/go-testutil"
+	routing "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing"
+	ropts "gx/ipfs/QmUV9hDAAyjeGbxbXkJ2sYqZ6dTd1DXJ2REhYEkRm178Tg/go-libp2p-routing/options"
 	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
 	mockrouting "gx/ipfs/Qmb1N7zdjG2FexpzWNj8T289u9QnQLEiSsTRadDGQxX32D/go-ipfs-routing/mock"
 	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
 	dssync "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
@@ -145,3 +149,188 @@ func verifyCanResolve(r Resolver, name string, exp path.Path) error {
 
 	return nil
 }
+
+func TestResolveProgressive(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	d := mockrouting.NewServer().ClientWithDatastore(context.Background(), testutil.RandIdentityOrFatal(t), dstore)
+
+	resolver := NewIpnsResolver(d)
+	publisher := NewIpnsPublisher(d, dstore)
+
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a name nothing was published to
+	var results []Progress
+	for pr := range resolver.ResolveProgressive(context.Background(), id.Pretty()) {
+		results = append(results, pr)
+	}
+	if len(results) != 1 || !results[0].Final || results[0].Err == nil {
+		t.Fatalf("expected a single failed final value, got %+v", results)
+	}
+
+	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	err = publisher.Publish(context.Background(), privk, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results = nil
+	for pr := range resolver.ResolveProgressive(context.Background(), id.Pretty()) {
+		results = append(results, pr)
+	}
+	if len(results) == 0 {
+		t.Fatal("expected a final value")
+	}
+	for i, pr := range results {
+		if pr.Final != (i == len(results)-1) {
+			t.Fatalf("expected the last value only to be final, got %+v", results)
+		}
+		if pr.Err != nil || pr.Path != h {
+			t.Fatalf("expected %s, got %+v", h, pr)
+		}
+	}
+}
+
+// searchingRouting streams vals as the values found for any key.
+type searchingRouting struct {
+	routing.IpfsRouting
+	vals [][]byte
+}
+
+func (r *searchingRouting) SearchValue(ctx context.Context, key string, opts ...ropts.Option) (<-chan []byte, error) {
+	out := make(chan []byte, len(r.vals))
+	for _, v := range r.vals {
+		out <- v
+	}
+	close(out)
+	return out, nil
+}
+
+func TestResolveProgressiveStream(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	d := mockrouting.NewServer().ClientWithDatastore(context.Background(), testutil.RandIdentityOrFatal(t), dstore)
+	publisher := NewIpnsPublisher(d, dstore)
+
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// for the public key to be found
+	if err := publisher.Publish(context.Background(), privk, path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []path.Path{
+		path.FromString("/ipfs/QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB"),
+		path.FromString("/ipfs/QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG"),
+		path.FromString("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"),
+	}
+	var vals [][]byte
+	for i, seq := range []uint64{1, 3, 2} {
+		entry, err := CreateRoutingEntryData(privk, paths[i], seq, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		val, err := proto.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vals = append(vals, val)
+	}
+
+	// wrapped like the routing of the nodes
+	stats := dhtstats.New(testutil.RandIdentityOrFatal(t).ID())
+	resolver := NewIpnsResolver(stats.Wrap(&searchingRouting{IpfsRouting: d, vals: vals}))
+	var results []Progress
+	for pr := range resolver.ResolveProgressive(context.Background(), id.Pretty()) {
+		results = append(results, pr)
+	}
+
+	// each better record is sent, the worse one skipped
+	expected := []Progress{
+		{Path: paths[0], Sequence: 1},
+		{Path: paths[1], Sequence: 3},
+		{Path: paths[1], Sequence: 3, Final: true},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, results)
+	}
+	for i, pr := range results {
+		if pr != expected[i] {
+			t.Fatalf("expected %+v, got %+v", expected, results)
+		}
+	}
+}
+
+// slowQuorumRouting only completes the lookups of more than one value once
+// release is closed.
+type slowQuorumRouting struct {
+	routing.IpfsRouting
+	release chan struct{}
+}
+
+func (r *slowQuorumRouting) GetValues(ctx context.Context, key string, count int) ([]routing.RecvdVal, error) {
+	if count > 1 {
+		select {
+		case <-r.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return r.IpfsRouting.GetValues(ctx, key, count)
+}
+
+func TestResolveProgressiveFirstValue(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	d := mockrouting.NewServer().ClientWithDatastore(context.Background(), testutil.RandIdentityOrFatal(t), dstore)
+	publisher := NewIpnsPublisher(d, dstore)
+
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	if err := publisher.Publish(context.Background(), privk, h); err != nil {
+		t.Fatal(err)
+	}
+
+	// the routing of the nodes is wrapped, and can't stream the values
+	r := &slowQuorumRouting{IpfsRouting: d, release: make(chan struct{})}
+	stats := dhtstats.New(testutil.RandIdentityOrFatal(t).ID())
+	resolver := NewIpnsResolver(stats.Wrap(r))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	results := resolver.ResolveProgressive(ctx, id.Pretty())
+
+	// the first value comes before the quorum is reached
+	pr := <-results
+	if pr.Final || pr.Err != nil || pr.Path != h {
+		t.Fatalf("expected the partial value %s, got %+v", h, pr)
+	}
+
+	close(r.release)
+	pr = <-results
+	if !pr.Final || pr.Err != nil || pr.Path != h {
+		t.Fatalf("expected the final value %s, got %+v", h, pr)
+	}
+	if _, ok := <-results; ok {
+		t.Fatal("expected the results to end with the final value")
+	}
+}
@@ -54,18 +54,33 @@ func (r *IpnsResolver) resolveOnce(ctx context.Context, name string, options *op
 		defer cancel()
 	}
 
+	ipnsKey, err := r.recordKey(ctx, name)
+	if err != nil {
+		return "", 0, err
+	}
+
+	rec, err := r.getRecord(ctx, name, ipnsKey, int(options.DhtRecordCount))
+	if err != nil {
+		return "", 0, err
+	}
+	return rec.path, rec.ttl(options.CacheTTL), nil
+}
+
+// recordKey returns the routing key of the IPNS record of name, making sure
+// the public key of name is in the peer store.
+func (r *IpnsResolver) recordKey(ctx context.Context, name string) (string, error) {
 	name = strings.TrimPrefix(name, "/ipns/")
 	hash, err := mh.FromB58String(name)
 	if err != nil {
 		// name should be a multihash. if it isn't, error out here.
 		log.Debugf("RoutingResolver: bad input hash: [%s]\n", name)
-		return "", 0, err
+		return "", err
 	}
 
 	pid, err := peer.IDFromBytes(hash)
 	if err != nil {
 		log.Debugf("RoutingResolver: could not convert public key hash %s to peer ID: %s\n", name, err)
-		return "", 0, err
+		return "", err
 	}
 
 	// Name should be the hash of a public key retrievable from ipfs.
@@ -76,24 +91,46 @@ func (r *IpnsResolver) resolveOnce(ctx context.Context, name string, options *op
 	_, err = routing.GetPublicKey(r.routing, ctx, pid)
 	if err != nil {
 		log.Debugf("RoutingResolver: could not retrieve public key %s: %s\n", name, err)
-		return "", 0, err
+		return "", err
 	}
 
+	_, ipnsKey := IpnsKeysForID(pid)
+	return ipnsKey, nil
+}
+
+// ipnsRecord is an IPNS record found in the routing system.
+type ipnsRecord struct {
+	val   []byte
+	entry *pb.IpnsEntry
+	path  path.Path
+}
+
+// getRecord gets the best of the quorum first IPNS records of name found in
+// the routing system.
+func (r *IpnsResolver) getRecord(ctx context.Context, name, ipnsKey string, quorum int) (*ipnsRecord, error) {
 	// Use the routing system to get the name.
 	// Note that the DHT will call the ipns validator when retrieving
 	// the value, which in turn verifies the ipns record signature
-	_, ipnsKey := IpnsKeysForID(pid)
-	val, err := r.routing.GetValue(ctx, ipnsKey, dht.Quorum(int(options.DhtRecordCount)))
+	val, err := r.routing.GetValue(ctx, ipnsKey, dht.Quorum(quorum))
 	if err != nil {
 		log.Debugf("RoutingResolver: dht get for name %s failed: %s", name, err)
-		return "", 0, err
+		return nil, err
 	}
 
+	rec, err := parseRecord(val)
+	if err != nil {
+		log.Debugf("RoutingResolver: could not parse value for name %s: %s", name, err)
+		return nil, err
+	}
+	return rec, nil
+}
+
+// parseRecord parses the IPNS record val.
+func parseRecord(val []byte) (*ipnsRecord, error) {
 	entry := new(pb.IpnsEntry)
-	err = proto.Unmarshal(val, entry)
+	err := proto.Unmarshal(val, entry)
 	if err != nil {
-		log.Debugf("RoutingResolver: could not unmarshal value for name %s: %s", name, err)
-		return "", 0, err
+		return nil, err
 	}
 
 	var p path.Path
@@ -106,15 +143,23 @@ func (r *IpnsResolver) resolveOnce(ctx context.Context, name string, options *op
 		// Not a multihash, probably a new record
 		p, err = path.ParsePath(string(entry.GetValue()))
 		if err != nil {
-			return "", 0, err
+			return nil, err
 		}
 	}
 
+	return &ipnsRecord{val: val, entry: entry, path: p}, nil
+}
+
+// ttl returns how long the value of the record may be cached: cacheTTL when
+// set, the TTL of the record otherwise, never past the EOL of the record.
+func (rec *ipnsRecord) ttl(cacheTTL time.Duration) time.Duration {
 	ttl := DefaultResolverCacheTTL
-	if entry.Ttl != nil {
-		ttl = time.Duration(*entry.Ttl)
+	if cacheTTL > 0 {
+		ttl = cacheTTL
+	} else if rec.entry.Ttl != nil {
+		ttl = time.Duration(*rec.entry.Ttl)
 	}
-	if eol, ok := checkEOL(entry); ok {
+	if eol, ok := checkEOL(rec.entry); ok {
 		ttEol := eol.Sub(time.Now())
 		if ttEol < 0 {
 			// It *was* valid when we first resolved it.
@@ -123,8 +168,7 @@ func (r *IpnsResolver) resolveOnce(ctx context.Context, name string, options *op
 			ttl = ttEol
 		}
 	}
-
-	return p, ttl, nil
+	return ttl
 }
 
 func checkEOL(e *pb.IpnsEntry) (time.Time, bool) {
@@ -0,0 +1,70 @@
+package p2p
+
+import (
+	gonet "net"
+
+	manet "gx/ipfs/QmNqRnejxJxjRroz7buhrjfU8i3yNBLa81hFtmf2pXEffN/go-multiaddr-net"
+	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+)
+
+// unixPath returns the path of the unix socket of addr, if it's one.
+func unixPath(addr ma.Multiaddr) (string, bool) {
+	p, err := addr.ValueForProtocol(ma.P_UNIX)
+	if err != nil {
+		return "", false
+	}
+	return p, true
+}
+
+// dialLocal connects to the local service at addr, a TCP or unix socket
+// address.
+func dialLocal(addr ma.Multiaddr) (manet.Conn, error) {
+	path, ok := unixPath(addr)
+	if !ok {
+		return manet.Dial(addr)
+	}
+	c, err := gonet.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &unixConn{Conn: c, addr: addr}, nil
+}
+
+// listenLocal listens for the connections of local applications at addr, a
+// TCP or unix socket address.
+func listenLocal(addr ma.Multiaddr) (manet.Listener, error) {
+	path, ok := unixPath(addr)
+	if !ok {
+		return manet.Listen(addr)
+	}
+	l, err := gonet.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &unixListener{Listener: l, addr: addr}, nil
+}
+
+// unixConn is a connection to a unix socket, with its multiaddr.
+type unixConn struct {
+	gonet.Conn
+	addr ma.Multiaddr
+}
+
+func (c *unixConn) LocalMultiaddr() ma.Multiaddr  { return c.addr }
+func (c *unixConn) RemoteMultiaddr() ma.Multiaddr { return c.addr }
+
+// unixListener listens on a unix socket, with its multiaddr.
+type unixListener struct {
+	gonet.Listener
+	addr ma.Multiaddr
+}
+
+func (l *unixListener) Accept() (manet.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &unixConn{Conn: c, addr: l.addr}, nil
+}
+
+func (l *unixListener) Multiaddr() ma.Multiaddr { return l.addr }
@@ -2,7 +2,7 @@ package p2p
 
 import (
 	"context"
-	"errors"
+	"sync/atomic"
 	"time"
 
 	manet "gx/ipfs/QmNqRnejxJxjRroz7buhrjfU8i3yNBLa81hFtmf2pXEffN/go-multiaddr-net"
@@ -12,8 +12,15 @@ import (
 	net "gx/ipfs/QmXdgNhVEgjLxjUoMs5ViQL7pboAt3Y7V7eGHRiE4qrmTE/go-libp2p-net"
 	pro "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
 	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
 )
 
+var log = logging.Logger("p2p-mount")
+
+// helloTimeout is how long the TLS client hello of a stream is waited for,
+// to route it by its server name.
+const helloTimeout = 10 * time.Second
+
 // P2P structure holds information on currently running streams/listeners
 type P2P struct {
 	Listeners ListenerRegistry
@@ -45,11 +52,6 @@ func (p2p *P2P) newStreamTo(ctx2 context.Context, p peer.ID, protocol string) (n
 
 // Dial creates new P2P stream to a remote listener
 func (p2p *P2P) Dial(ctx context.Context, addr ma.Multiaddr, peer peer.ID, proto string, bindAddr ma.Multiaddr) (*ListenerInfo, error) {
-	lnet, _, err := manet.DialArgs(bindAddr)
-	if err != nil {
-		return nil, err
-	}
-
 	listenerInfo := ListenerInfo{
 		Identity: p2p.identity,
 		Protocol: proto,
@@ -60,25 +62,19 @@ func (p2p *P2P) Dial(ctx context.Context, addr ma.Multiaddr, peer peer.ID, proto
 		return nil, err
 	}
 
-	switch lnet {
-	case "tcp", "tcp4", "tcp6":
-		listener, err := manet.Listen(bindAddr)
-		if err != nil {
-			if err2 := remote.Reset(); err2 != nil {
-				return nil, err2
-			}
-			return nil, err
+	listener, err := listenLocal(bindAddr)
+	if err != nil {
+		if err2 := remote.Reset(); err2 != nil {
+			return nil, err2
 		}
+		return nil, err
+	}
 
-		listenerInfo.Address = listener.Multiaddr()
-		listenerInfo.Closer = listener
-		listenerInfo.Running = true
-
-		go p2p.doAccept(&listenerInfo, remote, listener)
+	listenerInfo.Address = listener.Multiaddr()
+	listenerInfo.Closer = listener
+	listenerInfo.Running = true
 
-	default:
-		return nil, errors.New("unsupported protocol: " + lnet)
-	}
+	go p2p.doAccept(&listenerInfo, remote, listener)
 
 	return &listenerInfo, nil
 }
@@ -165,20 +161,47 @@ func (p2p *P2P) registerStreamHandler(ctx2 context.Context, protocol string) (*P
 	return list, nil
 }
 
+// ListenerOptions limit the streams a listener accepts.
+type ListenerOptions struct {
+	// Allowed are the peers allowed to connect, all of them if empty.
+	Allowed []peer.ID
+
+	// MaxStreams is the number of streams open at a time, unlimited if 0.
+	MaxStreams int
+
+	// IdleTimeout closes the streams carrying no data for that long, if set.
+	IdleTimeout time.Duration
+
+	// SNIRoutes are the addresses the TLS connections are forwarded to, by
+	// the server name of their client hello. The others, and the streams
+	// which aren't TLS, are forwarded to the address of the listener. TLS
+	// isn't terminated: the client hello is forwarded as is.
+	SNIRoutes map[string]ma.Multiaddr
+}
+
 // NewListener creates new p2p listener
-func (p2p *P2P) NewListener(ctx context.Context, proto string, addr ma.Multiaddr) (*ListenerInfo, error) {
+func (p2p *P2P) NewListener(ctx context.Context, proto string, addr ma.Multiaddr, opts ListenerOptions) (*ListenerInfo, error) {
 	listener, err := p2p.registerStreamHandler(ctx, proto)
 	if err != nil {
 		return nil, err
 	}
 
 	listenerInfo := ListenerInfo{
-		Identity: p2p.identity,
-		Protocol: proto,
-		Address:  addr,
-		Closer:   listener,
-		Running:  true,
-		Registry: &p2p.Listeners,
+		Identity:    p2p.identity,
+		Protocol:    proto,
+		Address:     addr,
+		Closer:      listener,
+		Running:     true,
+		MaxStreams:  opts.MaxStreams,
+		IdleTimeout: opts.IdleTimeout,
+		SNIRoutes:   opts.SNIRoutes,
+		Registry:    &p2p.Listeners,
+	}
+	if len(opts.Allowed) > 0 {
+		listenerInfo.Allowed = make(map[peer.ID]bool, len(opts.Allowed))
+		for _, p := range opts.Allowed {
+			listenerInfo.Allowed[p] = true
+		}
 	}
 
 	go p2p.acceptStreams(&listenerInfo, listener)
@@ -196,31 +219,83 @@ func (p2p *P2P) acceptStreams(listenerInfo *ListenerInfo, listener Listener) {
 			break
 		}
 
-		local, err := manet.Dial(listenerInfo.Address)
-		if err != nil {
+		remotePeer := remote.Conn().RemotePeer()
+		if !listenerInfo.allows(remotePeer) {
+			log.Warningf("refused a %s stream from %s, which isn't allowed", listenerInfo.Protocol, remotePeer.Pretty())
+			remote.Reset()
+			continue
+		}
+		if listenerInfo.MaxStreams > 0 && listenerInfo.Streams() >= listenerInfo.MaxStreams {
+			log.Warningf("refused a %s stream from %s, %d streams are open already", listenerInfo.Protocol, remotePeer.Pretty(), listenerInfo.MaxStreams)
 			remote.Reset()
 			continue
 		}
 
-		stream := StreamInfo{
-			Protocol: listenerInfo.Protocol,
+		atomic.AddInt32(&listenerInfo.streams, 1)
+		go p2p.forwardStream(listenerInfo, remote)
+	}
+	p2p.Listeners.Deregister(listenerInfo.Protocol)
+}
 
-			LocalPeer: listenerInfo.Identity,
-			LocalAddr: listenerInfo.Address,
+// forwardStream forwards the stream remote, accepted by the listener, to its
+// local service.
+func (p2p *P2P) forwardStream(listenerInfo *ListenerInfo, remote net.Stream) {
+	addr := listenerInfo.Address
+	var hello []byte
+	if len(listenerInfo.SNIRoutes) > 0 {
+		remote.SetReadDeadline(time.Now().Add(helloTimeout))
+		name, data, err := readClientHello(remote)
+		remote.SetReadDeadline(time.Time{})
+		switch err {
+		case nil:
+			if a, ok := listenerInfo.SNIRoutes[name]; ok {
+				addr = a
+			}
+		case errNotTLS:
+			// forwarded to the address of the listener
+		default:
+			log.Warningf("refused a %s stream from %s: %s", listenerInfo.Protocol, remote.Conn().RemotePeer().Pretty(), err)
+			remote.Reset()
+			atomic.AddInt32(&listenerInfo.streams, -1)
+			return
+		}
+		hello = data
+	}
 
-			RemotePeer: remote.Conn().RemotePeer(),
-			RemoteAddr: remote.Conn().RemoteMultiaddr(),
+	local, err := dialLocal(addr)
+	if err == nil && len(hello) > 0 {
+		_, err = local.Write(hello)
+		if err != nil {
+			local.Close()
+		}
+	}
+	if err != nil {
+		remote.Reset()
+		atomic.AddInt32(&listenerInfo.streams, -1)
+		return
+	}
 
-			Local:  local,
-			Remote: remote,
+	stream := StreamInfo{
+		Protocol: listenerInfo.Protocol,
 
-			Registry: &p2p.Streams,
-		}
+		LocalPeer: listenerInfo.Identity,
+		LocalAddr: addr,
+
+		RemotePeer: remote.Conn().RemotePeer(),
+		RemoteAddr: remote.Conn().RemoteMultiaddr(),
+
+		Local:  local,
+		Remote: remote,
 
-		p2p.Streams.Register(&stream)
-		stream.startStreaming()
+		Registry: &p2p.Streams,
+
+		IdleTimeout: listenerInfo.IdleTimeout,
+		listener:    listenerInfo,
+		bytesIn:     int64(len(hello)),
 	}
-	p2p.Listeners.Deregister(listenerInfo.Protocol)
+
+	p2p.Streams.Register(&stream)
+	stream.startStreaming()
 }
 
 // CheckProtoExists checks whether a protocol handler is registered to
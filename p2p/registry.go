@@ -3,6 +3,9 @@ package p2p
 import (
 	"fmt"
 	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	manet "gx/ipfs/QmNqRnejxJxjRroz7buhrjfU8i3yNBLa81hFtmf2pXEffN/go-multiaddr-net"
 	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
@@ -28,7 +31,33 @@ type ListenerInfo struct {
 	// whether this application listener has been shutdown.
 	Running bool
 
+	// Allowed are the peers allowed to connect, all of them if empty.
+	Allowed map[peer.ID]bool
+
+	// MaxStreams is the number of streams open at a time, unlimited if 0.
+	MaxStreams int
+
+	// IdleTimeout closes the streams carrying no data for that long, if set.
+	IdleTimeout time.Duration
+
+	// SNIRoutes are the addresses TLS streams are forwarded to, by server
+	// name.
+	SNIRoutes map[string]ma.Multiaddr
+
 	Registry *ListenerRegistry
+
+	// number of open streams, accessed atomically
+	streams int32
+}
+
+// Streams returns the number of streams of the listener open.
+func (c *ListenerInfo) Streams() int {
+	return int(atomic.LoadInt32(&c.streams))
+}
+
+// allows returns whether p may open a stream to the listener.
+func (c *ListenerInfo) allows(p peer.ID) bool {
+	return len(c.Allowed) == 0 || c.Allowed[p]
 }
 
 // Close closes the listener. Does not affect child streams
@@ -82,13 +111,48 @@ type StreamInfo struct {
 	Remote net.Stream
 
 	Registry *StreamRegistry
+
+	// Opened is when the stream was opened.
+	Opened time.Time
+
+	// IdleTimeout closes the stream once it carried no data for that long, if
+	// set.
+	IdleTimeout time.Duration
+
+	// the listener the stream was accepted by, if any
+	listener *ListenerInfo
+
+	// bytes received from and sent to the remote peer, and the unix time in
+	// nanoseconds of the last ones, accessed atomically
+	bytesIn    int64
+	bytesOut   int64
+	lastActive int64
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// BytesIn returns the number of bytes received from the remote peer.
+func (s *StreamInfo) BytesIn() int64 {
+	return atomic.LoadInt64(&s.bytesIn)
+}
+
+// BytesOut returns the number of bytes sent to the remote peer.
+func (s *StreamInfo) BytesOut() int64 {
+	return atomic.LoadInt64(&s.bytesOut)
+}
+
+// LastActive returns when the stream last carried data, or when it was
+// opened if it didn't.
+func (s *StreamInfo) LastActive() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.lastActive))
 }
 
 // Close closes stream endpoints and deregisters it
 func (s *StreamInfo) Close() error {
 	s.Local.Close()
 	s.Remote.Close()
-	s.Registry.Deregister(s.HandlerID)
+	s.closed()
 	return nil
 }
 
@@ -96,13 +160,32 @@ func (s *StreamInfo) Close() error {
 func (s *StreamInfo) Reset() error {
 	s.Local.Close()
 	s.Remote.Reset()
-	s.Registry.Deregister(s.HandlerID)
+	s.closed()
 	return nil
 }
 
+// closed deregisters the stream, once for both of its directions.
+func (s *StreamInfo) closed() {
+	s.closeOnce.Do(func() {
+		s.Registry.Deregister(s.HandlerID)
+		if s.listener != nil {
+			atomic.AddInt32(&s.listener.streams, -1)
+		}
+		if s.done != nil {
+			close(s.done)
+		}
+	})
+}
+
 func (s *StreamInfo) startStreaming() {
+	if s.Opened.IsZero() {
+		s.Opened = time.Now()
+	}
+	atomic.StoreInt64(&s.lastActive, s.Opened.UnixNano())
+	s.done = make(chan struct{})
+
 	go func() {
-		_, err := io.Copy(s.Local, s.Remote)
+		_, err := io.Copy(s.Local, &countingReader{r: s.Remote, n: &s.bytesIn, last: &s.lastActive})
 		if err != nil {
 			s.Reset()
 		} else {
@@ -111,24 +194,74 @@ func (s *StreamInfo) startStreaming() {
 	}()
 
 	go func() {
-		_, err := io.Copy(s.Remote, s.Local)
+		_, err := io.Copy(s.Remote, &countingReader{r: s.Local, n: &s.bytesOut, last: &s.lastActive})
 		if err != nil {
 			s.Reset()
 		} else {
 			s.Close()
 		}
 	}()
+
+	if s.IdleTimeout > 0 {
+		go s.closeIdle()
+	}
+}
+
+// closeIdle resets the stream once it's idle for longer than its timeout.
+func (s *StreamInfo) closeIdle() {
+	ticker := time.NewTicker(s.IdleTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if time.Since(s.LastActive()) > s.IdleTimeout {
+				log.Debugf("closing p2p stream %d, idle for more than %s", s.HandlerID, s.IdleTimeout)
+				s.Reset()
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// countingReader counts the bytes read from r in n, and records the time of
+// the last read in last.
+type countingReader struct {
+	r    io.Reader
+	n    *int64
+	last *int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		atomic.AddInt64(r.n, int64(n))
+		atomic.StoreInt64(r.last, time.Now().UnixNano())
+	}
+	return n, err
 }
 
 // StreamRegistry is a collection of active incoming and outgoing protocol app streams.
 type StreamRegistry struct {
 	Streams []*StreamInfo
 
+	mu     sync.Mutex
 	nextID uint64
 }
 
+// List returns the streams of the registry.
+func (c *StreamRegistry) List() []*StreamInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*StreamInfo(nil), c.Streams...)
+}
+
 // Register registers a stream to the registry
 func (c *StreamRegistry) Register(streamInfo *StreamInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	streamInfo.HandlerID = c.nextID
 	c.Streams = append(c.Streams, streamInfo)
 	c.nextID++
@@ -136,6 +269,8 @@ func (c *StreamRegistry) Register(streamInfo *StreamInfo) {
 
 // Deregister deregisters stream from the registry
 func (c *StreamRegistry) Deregister(handlerID uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	foundAt := -1
 	for i, s := range c.Streams {
 		if s.HandlerID == handlerID {
@@ -0,0 +1,123 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+// errNotTLS is returned when a stream doesn't start with a TLS ClientHello.
+var errNotTLS = errors.New("the stream doesn't start with a TLS client hello")
+
+// readClientHello reads the TLS ClientHello starting r, and returns the
+// server name it asks for, if any, and the bytes read, to be replayed to the
+// service. The ClientHello must fit in its first record, like the ones of
+// the usual clients. The streams which don't start with a TLS record, or
+// which send nothing, as the ones of the protocols where the service speaks
+// first, get errNotTLS.
+func readClientHello(r io.Reader) (string, []byte, error) {
+	// record header: content type, version and length
+	hdr := make([]byte, 5)
+	if _, err := io.ReadFull(r, hdr[:1]); err != nil {
+		if err == io.EOF || isTimeout(err) {
+			return "", nil, errNotTLS
+		}
+		return "", nil, err
+	}
+	if hdr[0] != 0x16 {
+		return "", hdr[:1], errNotTLS
+	}
+	if _, err := io.ReadFull(r, hdr[1:]); err != nil {
+		return "", nil, err
+	}
+	if hdr[1] != 3 {
+		return "", hdr, errNotTLS
+	}
+	n := int(binary.BigEndian.Uint16(hdr[3:5]))
+	data := make([]byte, 5+n)
+	copy(data, hdr)
+	if _, err := io.ReadFull(r, data[5:]); err != nil {
+		return "", nil, err
+	}
+
+	name, err := serverName(data[5:])
+	return name, data, err
+}
+
+func isTimeout(err error) bool {
+	t, ok := err.(interface {
+		Timeout() bool
+	})
+	return ok && t.Timeout()
+}
+
+// serverName returns the server name of the server_name extension of the
+// handshake message msg, a ClientHello.
+func serverName(msg []byte) (string, error) {
+	p := &parser{b: msg}
+
+	// handshake type and length
+	if typ := p.next(1); len(typ) == 0 || typ[0] != 1 {
+		return "", errNotTLS
+	}
+	if l := p.uint(3); l > len(p.b) {
+		return "", errors.New("the TLS client hello spans several records")
+	}
+	// client version and random
+	p.next(2 + 32)
+	// session id, cipher suites and compression methods
+	p.next(p.uint(1))
+	p.next(p.uint(2))
+	p.next(p.uint(1))
+	if p.err {
+		return "", errors.New("truncated TLS client hello")
+	}
+
+	exts := &parser{b: p.next(p.uint(2))}
+	for len(exts.b) > 0 && !exts.err {
+		typ := exts.uint(2)
+		ext := &parser{b: exts.next(exts.uint(2))}
+		if typ != 0 {
+			continue
+		}
+		names := &parser{b: ext.next(ext.uint(2))}
+		for len(names.b) > 0 && !names.err {
+			nameType := names.uint(1)
+			name := names.next(names.uint(2))
+			if nameType == 0 && !names.err {
+				return strings.ToLower(strings.TrimSuffix(string(name), ".")), nil
+			}
+		}
+	}
+	if exts.err {
+		return "", errors.New("truncated TLS client hello")
+	}
+	return "", nil
+}
+
+// parser reads the fields of a TLS message. Once it runs out of data, err is
+// set and it returns empty fields.
+type parser struct {
+	b   []byte
+	err bool
+}
+
+func (p *parser) next(n int) []byte {
+	if p.err || n > len(p.b) {
+		p.err = true
+		return nil
+	}
+	v := p.b[:n]
+	p.b = p.b[n:]
+	return v
+}
+
+// uint reads a big endian integer of n bytes.
+func (p *parser) uint(n int) int {
+	v := 0
+	for _, b := range p.next(n) {
+		v = v<<8 | int(b)
+	}
+	return v
+}
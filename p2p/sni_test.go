@@ -0,0 +1,80 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/tls"
+	gonet "net"
+	"testing"
+	"time"
+)
+
+// clientHello returns the client hello of a TLS client connecting to
+// serverName.
+func clientHello(t *testing.T, serverName string) []byte {
+	client, server := gonet.Pipe()
+	defer server.Close()
+
+	go func() {
+		c := tls.Client(client, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+		c.Handshake()
+		client.Close()
+	}()
+
+	_, data, err := readClientHello(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestReadClientHello(t *testing.T) {
+	data := clientHello(t, "A.Example.com")
+
+	// the bytes read are returned as is, followed by the rest of the stream
+	r := bytes.NewReader(append(append([]byte(nil), data...), "rest"...))
+	name, read, err := readClientHello(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "a.example.com" {
+		t.Fatalf("expected server name a.example.com, got %q", name)
+	}
+	if !bytes.Equal(read, data) || r.Len() != len("rest") {
+		t.Fatal("expected only the client hello to be read")
+	}
+}
+
+func TestReadClientHelloWithoutName(t *testing.T) {
+	name, _, err := readClientHello(bytes.NewReader(clientHello(t, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "" {
+		t.Fatalf("expected no server name, got %q", name)
+	}
+}
+
+func TestReadNotTLS(t *testing.T) {
+	for _, data := range []string{"GET / HTTP/1.1\r\n\r\n", "\x16\x01\x00\x00\x00", ""} {
+		_, read, err := readClientHello(bytes.NewReader([]byte(data)))
+		if err != errNotTLS {
+			t.Fatalf("%q: expected errNotTLS, got %v", data, err)
+		}
+		// the bytes read are replayed to the service
+		if !bytes.HasPrefix([]byte(data), read) {
+			t.Fatalf("%q: read %q", data, read)
+		}
+	}
+}
+
+func TestReadSilentStream(t *testing.T) {
+	client, server := gonet.Pipe()
+	defer client.Close()
+
+	// the client waits for the service to speak first
+	server.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	_, read, err := readClientHello(server)
+	if err != errNotTLS || len(read) != 0 {
+		t.Fatalf("expected errNotTLS and nothing read, got %v and %q", err, read)
+	}
+}
@@ -3,6 +3,7 @@ package path
 
 import (
 	"errors"
+	"net/url"
 	"path"
 	"strings"
 
@@ -147,6 +148,23 @@ func (p *Path) IsValid() error {
 	return err
 }
 
+// segmentEscaper escapes the characters which can't be in a segment as
+// they are.
+var segmentEscaper = strings.NewReplacer("%", "%25", "/", "%2F")
+
+// EscapeSegment escapes the percent signs and the slashes of s, for it to be
+// a single segment of a path. The map keys of the nodes of codecs like
+// dag-cbor are addressed by their escaped segment: the key "a/b" by "a%2Fb".
+func EscapeSegment(s string) string {
+	return segmentEscaper.Replace(s)
+}
+
+// UnescapeSegment decodes the percent-encoded bytes of the segment s, as
+// escaped by EscapeSegment or any percent-encoding.
+func UnescapeSegment(s string) (string, error) {
+	return url.PathUnescape(s)
+}
+
 // Join joins strings slices using /
 func Join(pths []string) string {
 	return strings.Join(pths, "/")
@@ -77,3 +77,33 @@ func TestPopLastSegment(t *testing.T) {
 		}
 	}
 }
+
+func TestEscapeSegment(t *testing.T) {
+	cases := map[string]string{
+		"foo":   "foo",
+		"a/b":   "a%2Fb",
+		"100%":  "100%25",
+		"a%2Fb": "a%252Fb",
+		"/x/ y": "%2Fx%2F y",
+		"":      "",
+		"日本/語":  "日本%2F語",
+	}
+
+	for s, expected := range cases {
+		escaped := EscapeSegment(s)
+		if escaped != expected {
+			t.Fatalf("expected EscapeSegment(%q) to return %q, not %q", s, expected, escaped)
+		}
+		unescaped, err := UnescapeSegment(escaped)
+		if err != nil {
+			t.Fatalf("UnescapeSegment(%q) failed, but should have succeeded: %s", escaped, err)
+		}
+		if unescaped != s {
+			t.Fatalf("expected UnescapeSegment(%q) to return %q, not %q", escaped, s, unescaped)
+		}
+	}
+
+	if _, err := UnescapeSegment("a%zzb"); err == nil {
+		t.Fatal("UnescapeSegment succeeded on an invalid escape, but should have failed")
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	gopath "path"
+	"strings"
 	"time"
 
-	dag "github.com/ipfs/go-ipfs/merkledag"
+	dag "github.com/ipfs/go-ipfs/merkledag/dagnode"
 	path "github.com/ipfs/go-ipfs/path"
 
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
@@ -34,6 +36,21 @@ func (e ErrNoLink) Error() string {
 	return fmt.Sprintf("no link named %q under %s", e.Name, e.Node.String())
 }
 
+// MaxSymlinks is the number of symlinks a path resolution follows at most.
+const MaxSymlinks = 32
+
+var (
+	// ErrTooManySymlinks is returned when resolving a path follows more than
+	// MaxSymlinks symlinks.
+	ErrTooManySymlinks = errors.New("too many levels of symbolic links")
+	// ErrSymlinkCycle is returned when the symlinks followed resolving a path
+	// form a cycle.
+	ErrSymlinkCycle = errors.New("cycle of symbolic links")
+	// ErrSymlinkOutsideRoot is returned when a symlink targets a path above the
+	// root of the path resolved.
+	ErrSymlinkOutsideRoot = errors.New("symbolic link target outside of the root of the path")
+)
+
 // Resolver provides path resolution to IPFS
 // It has a pointer to a DAGService, which is uses to resolve nodes.
 // TODO: now that this is more modular, try to unify this code with the
@@ -42,6 +59,18 @@ type Resolver struct {
 	DAG ipld.NodeGetter
 
 	ResolveOnce func(ctx context.Context, ds ipld.NodeGetter, nd ipld.Node, names []string) (*ipld.Link, []string, error)
+
+	// Symlink returns the target of nd if it's a symlink, which
+	// ResolveLinks then follows. The targets are relative to the directory
+	// of the symlink, to the root of the path when they start with a slash,
+	// or IPFS paths. Symlinks aren't followed when it's nil.
+	Symlink func(nd ipld.Node) (string, bool)
+
+	// EscapedSegments makes the segments of the paths resolved within the
+	// nodes of the codecs escaping them, see EscapesSegments, be unescaped
+	// with path.UnescapeSegment first. It's for the paths given to the dag
+	// commands: the others, such as the gateway's, are taken as they are.
+	EscapedSegments bool
 }
 
 // NewBasicResolver constructs a new basic resolver.
@@ -52,39 +81,93 @@ func NewBasicResolver(ds ipld.DAGService) *Resolver {
 	}
 }
 
+// Step is a node a path was resolved through.
+type Step struct {
+	Cid *cid.Cid
+	// Path are the segments of the path resolved within the node, escaped
+	// as in the path.
+	Path []string
+}
+
+// EscapesSegments returns whether the segments of the paths resolved within
+// the nodes of the codec of c are escaped, with path.EscapeSegment, when the
+// resolver has EscapedSegments set. They are for all the codecs but dag-pb
+// and raw, whose link names are taken as they are.
+func EscapesSegments(c *cid.Cid) bool {
+	switch c.Type() {
+	case cid.DagProtobuf, cid.Raw:
+		return false
+	default:
+		return true
+	}
+}
+
+// nodeSegments returns the segments of a path to resolve within nd,
+// unescaped if r has EscapedSegments set and its codec escapes them.
+func (r *Resolver) nodeSegments(nd ipld.Node, names []string) ([]string, error) {
+	if !r.EscapedSegments || !EscapesSegments(nd.Cid()) {
+		return names, nil
+	}
+	out := make([]string, len(names))
+	for i, name := range names {
+		var err error
+		out[i], err = path.UnescapeSegment(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path segment %q: %s", name, err)
+		}
+	}
+	return out, nil
+}
+
 // ResolveToLastNode walks the given path and returns the ipld.Node
 // referenced by the last element in it.
 func (r *Resolver) ResolveToLastNode(ctx context.Context, fpath path.Path) (ipld.Node, []string, error) {
+	nd, rest, _, err := r.ResolveSteps(ctx, fpath)
+	return nd, rest, err
+}
+
+// ResolveSteps is ResolveToLastNode also returning the nodes the path was
+// resolved through, the last node included along with the rest of the path
+// within it.
+func (r *Resolver) ResolveSteps(ctx context.Context, fpath path.Path) (ipld.Node, []string, []Step, error) {
 	c, p, err := path.SplitAbsPath(fpath)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	nd, err := r.DAG.Get(ctx, c)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
+	var steps []Step
 	for len(p) > 0 {
-		val, rest, err := nd.Resolve(p)
+		names, err := r.nodeSegments(nd, p)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
+		}
+		val, rest, err := nd.Resolve(names)
+		if err != nil {
+			return nil, nil, nil, err
 		}
 
 		switch val := val.(type) {
 		case *ipld.Link:
 			next, err := val.GetNode(ctx, r.DAG)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
+			steps = append(steps, Step{Cid: nd.Cid(), Path: p[:len(p)-len(rest)]})
 			nd = next
-			p = rest
+			p = p[len(p)-len(rest):]
 		default:
-			return nd, p, nil
+			steps = append(steps, Step{Cid: nd.Cid(), Path: p})
+			return nd, names, steps, nil
 		}
 	}
 
-	return nd, nil, nil
+	steps = append(steps, Step{Cid: nd.Cid()})
+	return nd, nil, steps, nil
 }
 
 // ResolvePath fetches the node for given path. It returns the last item
@@ -135,6 +218,8 @@ func (r *Resolver) ResolvePathComponents(ctx context.Context, fpath path.Path) (
 // Every node is fetched from the DAGService, resolving the next name.
 // Returns the list of nodes forming the path, starting with ndd. This list is
 // guaranteed never to be empty.
+// The symlinks are followed when r.Symlink is set, the nodes then forming the
+// path to their targets.
 //
 // ResolveLinks(nd, []string{"foo", "bar", "baz"})
 // would retrieve "baz" in ("bar" in ("foo" in nd.Links).Links).Links
@@ -146,13 +231,39 @@ func (r *Resolver) ResolveLinks(ctx context.Context, ndd ipld.Node, names []stri
 	result = append(result, ndd)
 	nd := ndd // dup arg workaround
 
+	// the symlinks followed, by the nodes leading to them and the names
+	// left to resolve
+	var followed map[string]bool
+
 	// for each of the path components
-	for len(names) > 0 {
+	for {
+		if target, ok := r.symlinkTarget(nd); ok {
+			if followed == nil {
+				followed = make(map[string]bool)
+			}
+			var err error
+			result, names, err = r.followSymlink(ctx, result, target, names, followed)
+			if err != nil {
+				evt.Append(logging.LoggableMap{"error": err.Error()})
+				return result, err
+			}
+			nd = result[len(result)-1]
+			continue
+		}
+		if len(names) == 0 {
+			break
+		}
+
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, time.Minute)
 		defer cancel()
 
-		lnk, rest, err := r.ResolveOnce(ctx, r.DAG, nd, names)
+		segments, err := r.nodeSegments(nd, names)
+		if err != nil {
+			evt.Append(logging.LoggableMap{"error": err.Error()})
+			return result, err
+		}
+		lnk, rest, err := r.ResolveOnce(ctx, r.DAG, nd, segments)
 		if err == dag.ErrLinkNotFound {
 			evt.Append(logging.LoggableMap{"error": err.Error()})
 			return result, ErrNoLink{Name: names[0], Node: nd.Cid()}
@@ -169,7 +280,83 @@ func (r *Resolver) ResolveLinks(ctx context.Context, ndd ipld.Node, names []stri
 
 		nd = nextnode
 		result = append(result, nextnode)
-		names = rest
+		names = names[len(names)-len(rest):]
 	}
 	return result, nil
 }
+
+// symlinkTarget returns the target of nd if it's a symlink to follow.
+func (r *Resolver) symlinkTarget(nd ipld.Node) (string, bool) {
+	if r.Symlink == nil {
+		return "", false
+	}
+	return r.Symlink(nd)
+}
+
+// followSymlink replaces the symlink ending nodes, the nodes the path was
+// resolved through, with the nodes leading to its target. It returns them
+// with the names left to resolve from there. followed are the symlinks
+// already followed.
+func (r *Resolver) followSymlink(ctx context.Context, nodes []ipld.Node, target string, names []string, followed map[string]bool) ([]ipld.Node, []string, error) {
+	if len(followed) >= MaxSymlinks {
+		return nodes, nil, ErrTooManySymlinks
+	}
+	key := make([]string, 0, len(nodes)+len(names))
+	for _, nd := range nodes {
+		key = append(key, nd.Cid().String())
+	}
+	key = append(key, names...)
+	if followed[path.Join(key)] {
+		return nodes, nil, ErrSymlinkCycle
+	}
+	followed[path.Join(key)] = true
+
+	// the directories leading to the symlink
+	dirs := nodes[:len(nodes)-1]
+	var segments []string
+	switch {
+	case strings.HasPrefix(target, "/ipfs/"):
+		c, rest, err := path.SplitAbsPath(path.Path(target))
+		if err != nil {
+			return nodes, nil, fmt.Errorf("invalid symlink target %q: %s", target, err)
+		}
+		root, err := r.DAG.Get(ctx, c)
+		if err != nil {
+			return nodes, nil, err
+		}
+		dirs = []ipld.Node{root}
+		segments = rest
+	case strings.HasPrefix(target, "/ipns/"):
+		return nodes, nil, fmt.Errorf("cannot follow the symlink to %s: /ipns/ targets aren't supported", target)
+	case strings.HasPrefix(target, "/"):
+		// relative to the root of the path
+		if len(dirs) > 0 {
+			dirs = dirs[:1]
+		}
+		segments = splitTarget(strings.TrimPrefix(gopath.Clean(target), "/"))
+	default:
+		segments = splitTarget(gopath.Clean(target))
+	}
+
+	out := make([]ipld.Node, len(dirs), len(dirs)+len(segments)+len(names))
+	copy(out, dirs)
+	for len(segments) > 0 && segments[0] == ".." {
+		if len(out) <= 1 {
+			return nodes, nil, ErrSymlinkOutsideRoot
+		}
+		out = out[:len(out)-1]
+		segments = segments[1:]
+	}
+	if len(out) == 0 {
+		return nodes, nil, ErrSymlinkOutsideRoot
+	}
+	return out, append(segments, names...), nil
+}
+
+// splitTarget splits the cleaned relative target of a symlink.
+func splitTarget(target string) []string {
+	if target == "" || target == "." {
+		return nil
+	}
+	return strings.Split(target, "/")
+}
@@ -9,8 +9,12 @@ import (
 	dagmock "github.com/ipfs/go-ipfs/merkledag/test"
 	path "github.com/ipfs/go-ipfs/path"
 	"github.com/ipfs/go-ipfs/path/resolver"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
 	util "gx/ipfs/QmPdKqUcHGFdeSpvjVoaTRPPstGif9GBZb5Q56RVw9o69A/go-ipfs-util"
+	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
+	cbor "gx/ipfs/QmSF1Ksgn5d7JCTBt4e1yp4wzs6tpYyweCZ4PcDYp3tNeK/go-ipld-cbor"
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
 )
 
@@ -68,3 +72,197 @@ func TestRecurivePathResolution(t *testing.T) {
 			p.String(), key.String(), cKey.String()))
 	}
 }
+
+func TestEscapedSegmentResolution(t *testing.T) {
+	ctx := context.Background()
+	dagService := dagmock.Mock()
+
+	a := randNode()
+	b := randNode()
+	err := a.AddNodeLink("50%", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cbor.WrapObject(map[string]interface{}{
+		"a/b": map[string]interface{}{
+			"link":  a.Cid(),
+			"50%":   "half",
+			"plain": "value",
+		},
+	}, mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []ipld.Node{a, b, c} {
+		err = dagService.Add(ctx, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the segments are taken as they are by default
+	r := resolver.NewBasicResolver(dagService)
+	p := path.FromString("/ipfs/" + c.Cid().String() + "/a%2Fb")
+	if _, _, err := r.ResolveToLastNode(ctx, p); err == nil {
+		t.Fatalf("expected %s not to be unescaped by default", p)
+	}
+
+	r.EscapedSegments = true
+
+	// the dag-pb link names are taken as they are
+	p = path.FromString("/ipfs/" + c.Cid().String() + "/a%2Fb/link/50%")
+	nd, rest, steps, err := r.ResolveSteps(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(b.Cid()) || len(rest) != 0 {
+		t.Fatalf("expected %s resolved to %s, got %s with %v left", p, b.Cid(), nd.Cid(), rest)
+	}
+	expected := []resolver.Step{
+		{Cid: c.Cid(), Path: []string{"a%2Fb", "link"}},
+		{Cid: a.Cid(), Path: []string{"50%"}},
+		{Cid: b.Cid()},
+	}
+	if len(steps) != len(expected) {
+		t.Fatalf("expected %d steps, got %d", len(expected), len(steps))
+	}
+	for i, step := range steps {
+		if !step.Cid.Equals(expected[i].Cid) || path.Join(step.Path) != path.Join(expected[i].Path) {
+			t.Fatalf("expected step %d to be %s /%s, got %s /%s", i, expected[i].Cid, path.Join(expected[i].Path), step.Cid, path.Join(step.Path))
+		}
+	}
+
+	// the rest of the path within the dag-cbor node is unescaped
+	p = path.FromString("/ipfs/" + c.Cid().String() + "/a%2Fb/50%25")
+	nd, rest, err = r.ResolveToLastNode(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(c.Cid()) || path.Join(rest) != "a/b/50%" {
+		t.Fatalf("expected %s resolved to %s with a/b/50%% left, got %s with %v left", p, c.Cid(), nd.Cid(), rest)
+	}
+	val, _, err := nd.Resolve(rest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != "half" {
+		t.Fatalf("expected %s to be half, got %v", p, val)
+	}
+
+	// the links within dag-cbor nodes are unescaped too
+	p = path.FromString("/ipfs/" + c.Cid().String() + "/a%2Fb/link")
+	nd, err = r.ResolvePath(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(a.Cid()) {
+		t.Fatalf("expected %s resolved to %s, got %s", p, a.Cid(), nd.Cid())
+	}
+
+	if _, _, err := r.ResolveToLastNode(ctx, path.FromString("/ipfs/"+c.Cid().String()+"/a%zz")); err == nil {
+		t.Fatal("expected an invalid escape to fail")
+	}
+}
+
+func symlinkNode(t *testing.T, target string) *merkledag.ProtoNode {
+	data, err := ft.SymlinkData(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return merkledag.NodeWithData(data)
+}
+
+func dirNode(t *testing.T, links map[string]ipld.Node) *merkledag.ProtoNode {
+	dir := merkledag.NodeWithData(ft.FolderPBData())
+	for name, nd := range links {
+		if err := dir.AddNodeLink(name, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestSymlinkResolution(t *testing.T) {
+	ctx := context.Background()
+	dagService := dagmock.Mock()
+
+	f := randNode()
+	g := randNode()
+	up := symlinkNode(t, "../b")
+	self := symlinkNode(t, "./f")
+	b := dirNode(t, map[string]ipld.Node{"g": g})
+	a := dirNode(t, map[string]ipld.Node{"f": f, "up": up, "self": self})
+	links := map[string]ipld.Node{
+		"a":      a,
+		"b":      b,
+		"abs":    symlinkNode(t, "/a/f"),
+		"ipfs":   symlinkNode(t, "/ipfs/"+b.Cid().String()),
+		"loop1":  symlinkNode(t, "loop2"),
+		"loop2":  symlinkNode(t, "loop1"),
+		"escape": symlinkNode(t, "../b"),
+		"ipns":   symlinkNode(t, "/ipns/example.com"),
+	}
+	// a chain of symlinks longer than followed
+	for i := 0; i <= resolver.MaxSymlinks; i++ {
+		links[fmt.Sprintf("s%d", i)] = symlinkNode(t, fmt.Sprintf("s%d", i+1))
+	}
+	links[fmt.Sprintf("s%d", resolver.MaxSymlinks+1)] = f
+	root := dirNode(t, links)
+
+	nodes := []ipld.Node{up, self, root}
+	for _, nd := range links {
+		nodes = append(nodes, nd)
+	}
+	for _, nd := range nodes {
+		if err := dagService.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := resolver.NewBasicResolver(dagService)
+	r.ResolveOnce = uio.ResolveUnixfsOnce
+	resolve := func(p string) (ipld.Node, error) {
+		return r.ResolvePath(ctx, path.FromString("/ipfs/"+root.Cid().String()+"/"+p))
+	}
+
+	// the symlinks aren't followed by default
+	if _, err := resolve("a/up/g"); err == nil {
+		t.Fatal("expected the symlink not to be followed")
+	}
+
+	r.Symlink = uio.SymlinkTarget
+	for p, expected := range map[string]ipld.Node{
+		"a/up/g": g,
+		"a/self": f,
+		"abs":    f,
+		"ipfs/g": g,
+		"s0":     nil,
+		"s1":     f,
+	} {
+		nd, err := resolve(p)
+		if expected == nil {
+			if err != resolver.ErrTooManySymlinks {
+				t.Fatalf("expected %s to follow too many symlinks, got %v", p, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("failed to resolve %s: %s", p, err)
+		}
+		if !nd.Cid().Equals(expected.Cid()) {
+			t.Fatalf("expected %s resolved to %s, got %s", p, expected.Cid(), nd.Cid())
+		}
+	}
+
+	if _, err := resolve("loop1"); err != resolver.ErrSymlinkCycle {
+		t.Fatalf("expected a cycle, got %v", err)
+	}
+	if _, err := resolve("escape"); err != resolver.ErrSymlinkOutsideRoot {
+		t.Fatalf("expected the target to be outside of the root, got %v", err)
+	}
+	if _, err := resolve("ipns"); err == nil {
+		t.Fatal("expected the /ipns/ target to fail")
+	}
+}
@@ -0,0 +1,247 @@
+// Package peerstore persists the addresses and protocols of the peers a node
+// learns about, so that it can reach them again right after a restart instead
+// of waiting for the routing system to find them.
+//
+// Each peer is saved as a JSON record under /peers/addrs/<peer id> in the
+// datastore, along with the time it was saved. Records saved more than TTL
+// ago are ignored and removed by GC.
+package peerstore
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	goprocess "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
+	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dsq "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/query"
+)
+
+var log = logging.Logger("peerstore")
+
+// DefaultInterval is how often the peerstore is saved by default.
+const DefaultInterval = 15 * time.Minute
+
+// DefaultTTL is how long saved peers are kept by default.
+const DefaultTTL = 24 * time.Hour
+
+var keyPrefix = ds.NewKey("/peers/addrs")
+
+type record struct {
+	Addrs     []string
+	Protocols []string `json:",omitempty"`
+	Saved     time.Time
+}
+
+// Persister saves the peers of a peerstore to a datastore and restores them.
+type Persister struct {
+	ps   pstore.Peerstore
+	ds   ds.Datastore
+	self peer.ID
+
+	// Interval is how often Run saves the peerstore.
+	Interval time.Duration
+	// TTL is how long saved peers are kept. Restored addresses expire when
+	// their record would.
+	TTL time.Duration
+
+	now func() time.Time
+}
+
+// NewPersister returns a Persister saving the peers of ps, other than self,
+// to d.
+func NewPersister(ps pstore.Peerstore, d ds.Datastore, self peer.ID) *Persister {
+	return &Persister{
+		ps:       ps,
+		ds:       d,
+		self:     self,
+		Interval: DefaultInterval,
+		TTL:      DefaultTTL,
+		now:      time.Now,
+	}
+}
+
+func peerKey(p peer.ID) ds.Key {
+	return keyPrefix.ChildString(peer.IDB58Encode(p))
+}
+
+// Save writes the peers which have addresses to the datastore and returns
+// how many it wrote.
+func (p *Persister) Save() (int, error) {
+	now := p.now()
+	count := 0
+	for _, id := range p.ps.PeersWithAddrs() {
+		if id == p.self {
+			continue
+		}
+
+		addrs := p.ps.Addrs(id)
+		if len(addrs) == 0 {
+			continue
+		}
+
+		rec := record{Saved: now}
+		for _, a := range addrs {
+			rec.Addrs = append(rec.Addrs, a.String())
+		}
+		protos, err := p.ps.GetProtocols(id)
+		if err == nil {
+			rec.Protocols = protos
+		}
+
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return count, err
+		}
+		if err := p.ds.Put(peerKey(id), b); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Load adds the saved peers which didn't expire to the peerstore and returns
+// them, the most recently saved first.
+func (p *Persister) Load() ([]pstore.PeerInfo, error) {
+	var pis []pstore.PeerInfo
+	var ttls []time.Duration
+	err := p.forEach(func(id peer.ID, rec *record, ttl time.Duration) error {
+		if ttl <= 0 || id == p.self {
+			return nil
+		}
+
+		pi := pstore.PeerInfo{ID: id}
+		for _, s := range rec.Addrs {
+			a, err := ma.NewMultiaddr(s)
+			if err != nil {
+				log.Debugf("ignoring invalid saved address %q of %s: %s", s, id, err)
+				continue
+			}
+			pi.Addrs = append(pi.Addrs, a)
+		}
+		if len(pi.Addrs) == 0 {
+			return nil
+		}
+
+		p.ps.AddAddrs(id, pi.Addrs, ttl)
+		if len(rec.Protocols) > 0 {
+			if err := p.ps.AddProtocols(id, rec.Protocols...); err != nil {
+				return err
+			}
+		}
+		pis = append(pis, pi)
+		ttls = append(ttls, ttl)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(byTTL{pis, ttls})
+	return pis, nil
+}
+
+// byTTL sorts peers by decreasing TTL left, the most recently saved first.
+type byTTL struct {
+	pis  []pstore.PeerInfo
+	ttls []time.Duration
+}
+
+func (s byTTL) Len() int           { return len(s.pis) }
+func (s byTTL) Less(i, j int) bool { return s.ttls[i] > s.ttls[j] }
+func (s byTTL) Swap(i, j int) {
+	s.pis[i], s.pis[j] = s.pis[j], s.pis[i]
+	s.ttls[i], s.ttls[j] = s.ttls[j], s.ttls[i]
+}
+
+// GC removes the saved peers which expired, or whose record can't be read,
+// and returns how many it removed.
+func (p *Persister) GC() (int, error) {
+	var expired []ds.Key
+	err := p.forEach(func(id peer.ID, rec *record, ttl time.Duration) error {
+		if ttl <= 0 {
+			expired = append(expired, peerKey(id))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i, k := range expired {
+		if err := p.ds.Delete(k); err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
+
+// forEach calls f with each saved peer and the time left before it expires.
+// Records which can't be read are passed as expired, with a nil record.
+func (p *Persister) forEach(f func(id peer.ID, rec *record, ttl time.Duration) error) error {
+	res, err := p.ds.Query(dsq.Query{Prefix: keyPrefix.String()})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	now := p.now()
+	for e := range res.Next() {
+		if e.Error != nil {
+			return e.Error
+		}
+
+		k := ds.RawKey(e.Key)
+		id, err := peer.IDB58Decode(k.BaseNamespace())
+		if err != nil {
+			log.Debugf("ignoring invalid peerstore key %s: %s", k, err)
+			continue
+		}
+
+		var rec *record
+		ttl := time.Duration(0)
+		if b, ok := e.Value.([]byte); ok {
+			rec = new(record)
+			if err := json.Unmarshal(b, rec); err != nil {
+				rec = nil
+			} else {
+				ttl = rec.Saved.Add(p.TTL).Sub(now)
+			}
+		}
+
+		if err := f(id, rec, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run saves the peerstore and removes the expired peers every Interval, and
+// saves it one last time when proc closes.
+func (p *Persister) Run(proc goprocess.Process) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := p.Save(); err != nil {
+				log.Error("failed to save the peerstore: ", err)
+				continue
+			}
+			if _, err := p.GC(); err != nil {
+				log.Error("failed to remove expired peers: ", err)
+			}
+		case <-proc.Closing():
+			if _, err := p.Save(); err != nil {
+				log.Error("failed to save the peerstore: ", err)
+			}
+			return
+		}
+	}
+}
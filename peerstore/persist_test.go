@@ -0,0 +1,151 @@
+package peerstore
+
+import (
+	"testing"
+	"time"
+
+	ma "gx/ipfs/QmUxSEGbv2nmYNnfXi7839wwQqTN3kwQeUxe8dTjZWZs7J/go-multiaddr"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dssync "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
+)
+
+func mustPeer(t *testing.T, s string) peer.ID {
+	id, err := peer.IDB58Decode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func mustAddr(t *testing.T, s string) ma.Multiaddr {
+	a, err := ma.NewMultiaddr(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestSaveLoad(t *testing.T) {
+	self := mustPeer(t, "QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ")
+	other := mustPeer(t, "QmSoLPppuBtQSGwKDZT2M73ULpjvfd3aZ6ha4oFGL1KrGM")
+	addr := mustAddr(t, "/ip4/104.131.131.82/tcp/4001")
+
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+
+	ps := pstore.NewPeerstore()
+	ps.AddAddr(self, mustAddr(t, "/ip4/127.0.0.1/tcp/4001"), time.Hour)
+	ps.AddAddr(other, addr, time.Hour)
+	if err := ps.AddProtocols(other, "/ipfs/kad/1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewPersister(ps, d, self).Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected to save 1 peer, saved %d", n)
+	}
+
+	restored := pstore.NewPeerstore()
+	pis, err := NewPersister(restored, d, self).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pis) != 1 || pis[0].ID != other {
+		t.Fatalf("expected to restore %s, got %v", other, pis)
+	}
+
+	addrs := restored.Addrs(other)
+	if len(addrs) != 1 || !addrs[0].Equal(addr) {
+		t.Fatalf("expected the address %s, got %v", addr, addrs)
+	}
+	protos, err := restored.SupportsProtocols(other, "/ipfs/kad/1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(protos) != 1 {
+		t.Fatal("expected the protocols to be restored")
+	}
+	if len(restored.Addrs(self)) != 0 {
+		t.Fatal("expected the addresses of self not to be restored")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	self := mustPeer(t, "QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ")
+	other := mustPeer(t, "QmSoLPppuBtQSGwKDZT2M73ULpjvfd3aZ6ha4oFGL1KrGM")
+
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+
+	ps := pstore.NewPeerstore()
+	ps.AddAddr(other, mustAddr(t, "/ip4/104.131.131.82/tcp/4001"), time.Hour)
+
+	p := NewPersister(ps, d, self)
+	p.TTL = time.Hour
+	if _, err := p.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(2 * time.Hour)
+	restorer := NewPersister(pstore.NewPeerstore(), d, self)
+	restorer.TTL = time.Hour
+	restorer.now = func() time.Time { return later }
+
+	pis, err := restorer.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pis) != 0 {
+		t.Fatalf("expected expired peers not to be restored, got %v", pis)
+	}
+
+	removed, err := restorer.GC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Fatalf("expected to remove 1 peer, removed %d", removed)
+	}
+	if has, _ := d.Has(peerKey(other)); has {
+		t.Fatal("expected the expired peer to be removed from the datastore")
+	}
+
+	removed, err = restorer.GC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 0 {
+		t.Fatalf("expected nothing left to remove, removed %d", removed)
+	}
+}
+
+func TestLoadOrder(t *testing.T) {
+	self := mustPeer(t, "QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ")
+	older := mustPeer(t, "QmSoLPppuBtQSGwKDZT2M73ULpjvfd3aZ6ha4oFGL1KrGM")
+	newer := mustPeer(t, "QmSoLueR4xBeUbY9WZ9xGUUxunbKWcrNFTDAadQJmocnWm")
+
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	now := time.Now()
+	for i, id := range []peer.ID{newer, older} {
+		ps := pstore.NewPeerstore()
+		ps.AddAddr(id, mustAddr(t, "/ip4/104.131.131.82/tcp/4001"), time.Hour)
+
+		p := NewPersister(ps, d, self)
+		saved := now.Add(-time.Duration(i) * time.Minute)
+		p.now = func() time.Time { return saved }
+		if _, err := p.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pis, err := NewPersister(pstore.NewPeerstore(), d, self).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pis) != 2 || pis[0].ID != newer || pis[1].ID != older {
+		t.Fatalf("expected %s then %s, got %v", newer, older, pis)
+	}
+}
@@ -6,10 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	bserv "github.com/ipfs/go-ipfs/blockservice"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	pin "github.com/ipfs/go-ipfs/pin"
+	cidset "github.com/ipfs/go-ipfs/thirdparty/cidset"
 	"github.com/ipfs/go-ipfs/thirdparty/verifcid"
 
 	offline "gx/ipfs/QmPf114DXfa6TqGKYhBGR7EtXRho4rCJgwyA1xkuMY5vwF/go-ipfs-exchange-offline"
@@ -61,6 +63,7 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 			output <- Result{Error: err}
 			return
 		}
+		defer gcs.Close()
 		emark.Append(logging.LoggableMap{
 			"blackSetSize": fmt.Sprintf("%d", gcs.Len()),
 		})
@@ -128,9 +131,9 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 }
 
 // Descendants recursively finds all the descendants of the given roots and
-// adds them to the given cid.Set, using the provided dag.GetLinks function
+// adds them to the given set, using the provided dag.GetLinks function
 // to walk the tree.
-func Descendants(ctx context.Context, getLinks dag.GetLinks, set *cid.Set, roots []*cid.Cid) error {
+func Descendants(ctx context.Context, getLinks dag.GetLinks, set cidset.Set, roots []*cid.Cid) error {
 	verifyGetLinks := func(ctx context.Context, c *cid.Cid) ([]*ipld.Link, error) {
 		err := verifcid.ValidateCid(c)
 		if err != nil {
@@ -152,10 +155,10 @@ func Descendants(ctx context.Context, getLinks dag.GetLinks, set *cid.Set, roots
 	}
 
 	for _, c := range roots {
-		set.Add(c)
-
-		// EnumerateChildren recursively walks the dag and adds the keys to the given set
-		err := dag.EnumerateChildren(ctx, verifyGetLinks, c, set.Visit)
+		// the roots are added to the set as the walk visits them, which skips
+		// the ones visited by a previous walk along with their descendants
+		// EnumerateChildrenAsync recursively walks the dag and adds the keys to the given set
+		err := dag.EnumerateChildrenAsync(ctx, verifyGetLinks, c, set.Visit)
 
 		if err != nil {
 			err = verboseCidError(err)
@@ -167,51 +170,65 @@ func Descendants(ctx context.Context, getLinks dag.GetLinks, set *cid.Set, roots
 }
 
 // ColoredSet computes the set of nodes in the graph that are pinned by the
-// pins in the given pinner.
-func ColoredSet(ctx context.Context, pn pin.Pinner, ng ipld.NodeGetter, bestEffortRoots []*cid.Cid, output chan<- Result) (*cid.Set, error) {
-	// KeySet currently implemented in memory, in the future, may be bloom filter or
-	// disk backed to conserve memory.
+// pins in the given pinner. The set is made by the cidset.Factory of the
+// context, in memory by default, and must be closed by the caller.
+func ColoredSet(ctx context.Context, pn pin.Pinner, ng ipld.NodeGetter, bestEffortRoots []*cid.Cid, output chan<- Result) (cidset.Set, error) {
+	gcs, err := cidset.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the links are fetched concurrently
+	var errlk sync.Mutex
 	errors := false
-	gcs := cid.NewSet()
+	fail := func(err error) {
+		errlk.Lock()
+		errors = true
+		errlk.Unlock()
+		output <- Result{Error: err}
+	}
+
 	getLinks := func(ctx context.Context, cid *cid.Cid) ([]*ipld.Link, error) {
 		links, err := ipld.GetLinks(ctx, ng, cid)
 		if err != nil {
-			errors = true
-			output <- Result{Error: &CannotFetchLinksError{cid, err}}
+			fail(&CannotFetchLinksError{cid, err})
 		}
 		return links, nil
 	}
-	err := Descendants(ctx, getLinks, gcs, pn.RecursiveKeys())
+	err = Descendants(ctx, getLinks, gcs, pn.RecursiveKeys())
 	if err != nil {
-		errors = true
-		output <- Result{Error: err}
+		fail(err)
 	}
 
 	bestEffortGetLinks := func(ctx context.Context, cid *cid.Cid) ([]*ipld.Link, error) {
 		links, err := ipld.GetLinks(ctx, ng, cid)
 		if err != nil && err != ipld.ErrNotFound {
-			errors = true
-			output <- Result{Error: &CannotFetchLinksError{cid, err}}
+			fail(&CannotFetchLinksError{cid, err})
 		}
 		return links, nil
 	}
 	err = Descendants(ctx, bestEffortGetLinks, gcs, bestEffortRoots)
 	if err != nil {
-		errors = true
-		output <- Result{Error: err}
+		fail(err)
+	}
+
+	err = Descendants(ctx, getLinks, gcs, pn.InternalPins())
+	if err != nil {
+		fail(err)
 	}
 
+	// added last, as the walks skip the blocks already in the set
 	for _, k := range pn.DirectKeys() {
 		gcs.Add(k)
 	}
 
-	err = Descendants(ctx, getLinks, gcs, pn.InternalPins())
-	if err != nil {
-		errors = true
-		output <- Result{Error: err}
+	// a set which lost some of the marked blocks would have them deleted
+	if err := gcs.Err(); err != nil {
+		fail(err)
 	}
 
 	if errors {
+		gcs.Close()
 		return nil, ErrCannotFetchAllLinks
 	}
 
@@ -0,0 +1,20 @@
+package plugin
+
+import (
+	"time"
+
+	cmds "gx/ipfs/QmaFrNcnXHp579hUixbcTH1TNtNwsMogtBCwUUUwzBwYoM/go-ipfs-cmds"
+)
+
+// PluginCommandObserver is an interface that can be implemented to observe
+// the execution of commands, wherever they run: in the daemon for the
+// requests to the HTTP API, or in the ipfs process otherwise
+type PluginCommandObserver interface {
+	Plugin
+
+	// CommandStarted is called before the command of the request runs
+	CommandStarted(req *cmds.Request)
+
+	// CommandFinished is called once the command of the request returned
+	CommandFinished(req *cmds.Request, duration time.Duration)
+}
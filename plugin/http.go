@@ -0,0 +1,17 @@
+package plugin
+
+import (
+	"net/http"
+)
+
+// PluginHTTP is an interface that can be implemented to wrap the handlers of
+// the HTTP servers of the daemon, e.g. to authenticate, meter or log
+// requests
+type PluginHTTP interface {
+	Plugin
+
+	// WrapHandler returns the handler to serve the requests of the server,
+	// "api" or "gateway", with. It usually calls next for the requests it
+	// lets through
+	WrapHandler(server string, next http.Handler) (http.Handler, error)
+}
@@ -14,3 +14,13 @@ type PluginIPLD interface {
 	RegisterBlockDecoders(dec ipld.BlockDecoder) error
 	RegisterInputEncParsers(iec coredag.InputEncParsers) error
 }
+
+// PluginIPLDCodecs is an interface that can be implemented by IPLD plugins
+// whose formats use multicodecs go-cid doesn't know, like dag-jose, to name
+// them
+type PluginIPLDCodecs interface {
+	PluginIPLD
+
+	// Codecs returns the codes of the codecs by name
+	Codecs() map[string]uint64
+}
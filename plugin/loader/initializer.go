@@ -1,11 +1,15 @@
 package loader
 
 import (
+	"github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/core/commands"
 	"github.com/ipfs/go-ipfs/core/coredag"
+	"github.com/ipfs/go-ipfs/core/corehttp"
 	"github.com/ipfs/go-ipfs/plugin"
 	"gx/ipfs/QmWLWmRVSiagqP15jczsGME1qpob6HDbtbHAY2he9W5iUo/opentracing-go"
 
 	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	prometheus "gx/ipfs/QmX3QZ5jHEPidwUrymXV1iSCSUhdGxj15sm2gP4jKMef7B/client_golang/prometheus"
 )
 
 func initialize(plugins []plugin.Plugin) error {
@@ -32,6 +36,21 @@ func run(plugins []plugin.Plugin) error {
 			if err != nil {
 				return err
 			}
+		case plugin.PluginMetrics:
+			err := pl.InitMetricsExporter(prometheus.DefaultGatherer)
+			if err != nil {
+				return err
+			}
+		case plugin.PluginHTTP:
+			corehttp.AddMiddleware(pl.WrapHandler)
+		case plugin.PluginCommandObserver:
+			commands.AddCommandObserver(pl)
+		case plugin.PluginValidator:
+			for ns, v := range pl.Validators() {
+				if err := core.RegisterValidator(ns, v); err != nil {
+					return err
+				}
+			}
 		default:
 			panic(pl)
 		}
@@ -40,6 +59,14 @@ func run(plugins []plugin.Plugin) error {
 }
 
 func runIPLDPlugin(pl plugin.PluginIPLD) error {
+	if cpl, ok := pl.(plugin.PluginIPLDCodecs); ok {
+		for name, code := range cpl.Codecs() {
+			if err := coredag.RegisterCodec(name, code); err != nil {
+				return err
+			}
+		}
+	}
+
 	err := pl.RegisterBlockDecoders(ipld.DefaultBlockDecoder)
 	if err != nil {
 		return err
@@ -0,0 +1,18 @@
+package plugin
+
+import (
+	prometheus "gx/ipfs/QmX3QZ5jHEPidwUrymXV1iSCSUhdGxj15sm2gP4jKMef7B/client_golang/prometheus"
+)
+
+// PluginMetrics is an interface that can be implemented to export the
+// metrics of the node, the ones served at /debug/metrics/prometheus, to a
+// backend
+type PluginMetrics interface {
+	Plugin
+
+	// InitMetricsExporter starts exporting the metrics collected by the
+	// gatherer. It is called in every ipfs process loading the plugins, not
+	// only in the daemon, so exporters should not hold short-lived commands
+	// back when they exit
+	InitMetricsExporter(gatherer prometheus.Gatherer) error
+}
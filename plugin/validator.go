@@ -0,0 +1,16 @@
+package plugin
+
+import (
+	record "gx/ipfs/QmPWjVzxHeJdrjp4Jr2R2sPxBrMbBgGPWQtKwCKHHCBF7x/go-libp2p-record"
+)
+
+// PluginValidator is an interface that can be implemented to validate the
+// records of new namespaces of the routing system, so that applications can
+// store their own signed records in the DHT, under keys like /myapp/<name>
+type PluginValidator interface {
+	Plugin
+
+	// Validators returns the validators of the record namespaces by name,
+	// like "myapp" for the keys /myapp/<name>
+	Validators() map[string]record.Validator
+}
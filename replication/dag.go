@@ -0,0 +1,232 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	car "github.com/ipfs/go-ipfs/car"
+	pin "github.com/ipfs/go-ipfs/pin"
+	pininfo "github.com/ipfs/go-ipfs/repo/pininfo"
+
+	p2phost "gx/ipfs/QmQQGtcp6nVUrQjNsnU53YWV1q8fK1Kd9S7FEkYbRZzxry/go-libp2p-host"
+	blocks "gx/ipfs/QmTRCUvZLiir12Qr6MV3HKfKMHX8Nf1Vddn6t2g5nsQSb9/go-block-format"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	net "gx/ipfs/QmXdgNhVEgjLxjUoMs5ViQL7pboAt3Y7V7eGHRiE4qrmTE/go-libp2p-net"
+	pro "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	bstore "gx/ipfs/QmbaPGg81pvQiC5vTXtC9Jo8rdrWUjRaugH71WYNsgi6Ev/go-ipfs-blockstore"
+)
+
+// DAGProtocolID is the protocol DAGs are pushed over.
+const DAGProtocolID pro.ID = "/ipfs/dag-push/1.0.0"
+
+// dagBatch is the number of pushed blocks added at a time.
+const dagBatch = 128
+
+// ErrNotAllowed is replied to the nodes pushing DAGs which aren't allowed to.
+var ErrNotAllowed = errors.New("not allowed to push to this node")
+
+// PushDAG writes the DAG of root, got from ng, to the node id, and waits for
+// it to add it, and pin it if pin is set.
+func PushDAG(ctx context.Context, h p2phost.Host, ng ipld.NodeGetter, id peer.ID, root *cid.Cid, pin bool) error {
+	if err := h.Connect(ctx, pstore.PeerInfo{ID: id}); err != nil {
+		return err
+	}
+	s, err := h.NewStream(ctx, id, DAGProtocolID)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// unblock the writes and the read below when ctx is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Reset()
+		case <-done:
+		}
+	}()
+
+	opt := "nopin"
+	if pin {
+		opt = "pin"
+	}
+	w := &streamWriter{w: s}
+	_, err = fmt.Fprintln(w, opt)
+	if err == nil {
+		err = car.WriteDAG(ctx, ng, root, w)
+	}
+	if err != nil && w.err == nil {
+		// the DAG couldn't be read, the node would wait for the rest of it
+		s.Reset()
+		return err
+	}
+
+	// the node replies why it stopped reading, if it did
+	line, rerr := readLine(s)
+	if rerr != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		return rerr
+	}
+	if rerr := parseReply(line); rerr != nil {
+		return rerr
+	}
+	return err
+}
+
+// streamWriter keeps the error writing to a stream, to tell it from the
+// errors reading the DAG.
+type streamWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// DAGReceiver adds the DAGs pushed by the allowed nodes.
+type DAGReceiver struct {
+	ctx      context.Context
+	allowed  map[peer.ID]bool
+	blocks   bserv.BlockService
+	dag      ipld.DAGService
+	pinning  pin.Pinner
+	pinInfo  *pininfo.Store
+	gcLocker bstore.GCLocker
+}
+
+// NewDAGReceiver returns a DAGReceiver adding the DAGs pushed by the nodes
+// allowed to bs, and pinning them with pinning when asked to.
+func NewDAGReceiver(ctx context.Context, allowed []peer.ID, bs bserv.BlockService, ds ipld.DAGService, pinning pin.Pinner, info *pininfo.Store, gcl bstore.GCLocker) *DAGReceiver {
+	r := &DAGReceiver{
+		ctx:      ctx,
+		allowed:  make(map[peer.ID]bool, len(allowed)),
+		blocks:   bs,
+		dag:      ds,
+		pinning:  pinning,
+		pinInfo:  info,
+		gcLocker: gcl,
+	}
+	for _, id := range allowed {
+		r.allowed[id] = true
+	}
+	return r
+}
+
+// HandleStream is the stream handler of DAGProtocolID.
+func (r *DAGReceiver) HandleStream(s net.Stream) {
+	defer s.Close()
+
+	from := s.Conn().RemotePeer()
+	if !r.allowed[from] {
+		log.Warningf("refused a DAG pushed by %s, which isn't allowed to", from.Pretty())
+		writeReply(s, ErrNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, PushTimeout)
+	defer cancel()
+
+	root, n, err := r.receive(ctx, s)
+	if err != nil {
+		log.Errorf("failed to receive the DAG pushed by %s: %s", from.Pretty(), err)
+	} else {
+		log.Infof("received %s (%d blocks) from %s", root, n, from.Pretty())
+	}
+	writeReply(s, err)
+}
+
+// receive reads a pushed DAG from rd, and returns its root and the number of
+// its blocks. The blocks are read until the DAG is complete, so the pushing
+// node can wait for the reply without closing the stream.
+func (r *DAGReceiver) receive(ctx context.Context, rd io.Reader) (*cid.Cid, int, error) {
+	opt, err := readLine(rd)
+	if err != nil {
+		return nil, 0, err
+	}
+	if opt != "pin" && opt != "nopin" {
+		return nil, 0, fmt.Errorf("unexpected option %q", opt)
+	}
+
+	cr, err := car.NewReader(rd)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(cr.Header.Roots) != 1 {
+		return nil, 0, fmt.Errorf("expected a single root, got %d", len(cr.Header.Roots))
+	}
+	root := cr.Header.Roots[0]
+
+	// keep the blocks from GC until they are pinned
+	defer r.gcLocker.PinLock().Unlock()
+
+	ck := car.NewChecker()
+	want := map[string]bool{root.KeyString(): true}
+	count := 0
+	batch := make([]blocks.Block, 0, dagBatch)
+	for len(want) > 0 {
+		b, err := cr.Next()
+		if err == io.EOF {
+			return nil, 0, fmt.Errorf("the DAG is missing %d blocks", len(want))
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := ck.Add(b); err != nil {
+			return nil, 0, fmt.Errorf("invalid block %s: %s", b.Cid(), err)
+		}
+		count++
+
+		delete(want, b.Cid().KeyString())
+		for _, l := range ck.Links(b.Cid()) {
+			if !ck.Has(l) {
+				want[l.KeyString()] = true
+			}
+		}
+
+		batch = append(batch, b)
+		if len(batch) == dagBatch {
+			if err := r.blocks.AddBlocks(batch); err != nil {
+				return nil, 0, err
+			}
+			batch = make([]blocks.Block, 0, dagBatch)
+		}
+	}
+	if err := r.blocks.AddBlocks(batch); err != nil {
+		return nil, 0, err
+	}
+
+	if opt == "pin" {
+		nd, err := r.dag.Get(ctx, root)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := r.pinning.Pin(ctx, nd, true); err != nil {
+			return nil, 0, err
+		}
+		if err := r.pinning.Flush(); err != nil {
+			return nil, 0, err
+		}
+		if err := r.pinInfo.Record(root, pininfo.Info{Origin: pininfo.OriginPush}); err != nil {
+			log.Error("failed to record the pin: ", err)
+		}
+	}
+	return root, count, nil
+}
@@ -0,0 +1,102 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	car "github.com/ipfs/go-ipfs/car"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	dagtest "github.com/ipfs/go-ipfs/merkledag/test"
+	pin "github.com/ipfs/go-ipfs/pin"
+	pininfo "github.com/ipfs/go-ipfs/repo/pininfo"
+
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	bstore "gx/ipfs/QmbaPGg81pvQiC5vTXtC9Jo8rdrWUjRaugH71WYNsgi6Ev/go-ipfs-blockstore"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dssync "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
+)
+
+func TestReceiveDAG(t *testing.T) {
+	ctx := context.Background()
+
+	a := dag.NewRawNode([]byte("a"))
+	b := dag.NewRawNode([]byte("b"))
+	root := dag.NodeWithData([]byte("root"))
+	if err := root.AddNodeLink("a", a); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.AddNodeLink("b", b); err != nil {
+		t.Fatal(err)
+	}
+	src := dagtest.Mock()
+	if err := src.AddMany(ctx, []ipld.Node{root, a, b}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("pin\n")
+	if err := car.WriteDAG(ctx, src, root.Cid(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	// the reply of the pushing node would follow, the DAG must end before
+	buf.WriteString("trailing")
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := dagtest.Bserv()
+	dst := dag.NewDAGService(bs)
+	pinning := pin.NewPinner(dstore, dst, dst)
+	info := pininfo.NewStore(dstore)
+	r := NewDAGReceiver(ctx, nil, bs, dst, pinning, info, bstore.NewGCLocker())
+
+	c, n, err := r.receive(ctx, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Equals(root.Cid()) || n != 3 {
+		t.Fatalf("received %s (%d blocks), expected %s (3 blocks)", c, n, root.Cid())
+	}
+	if buf.String() != "trailing" {
+		t.Fatalf("expected the receiver to stop after the DAG, %q left", buf.String())
+	}
+
+	for _, nd := range []ipld.Node{root, a, b} {
+		if _, err := dst.Get(ctx, nd.Cid()); err != nil {
+			t.Fatalf("block %s wasn't added: %s", nd.Cid(), err)
+		}
+	}
+	if _, pinned, _ := pinning.IsPinned(root.Cid()); !pinned {
+		t.Fatal("expected the root to be pinned")
+	}
+	if i, _ := info.Get(root.Cid()); i == nil || i.Origin != pininfo.OriginPush {
+		t.Fatalf("unexpected pin record %+v", i)
+	}
+}
+
+func TestReceiveIncompleteDAG(t *testing.T) {
+	ctx := context.Background()
+
+	a := dag.NewRawNode([]byte("a"))
+	root := dag.NodeWithData([]byte("root"))
+	if err := root.AddNodeLink("a", a); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("nopin\n")
+	cw, err := car.NewWriter(&buf, []*cid.Cid{root.Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.Put(root); err != nil {
+		t.Fatal(err)
+	}
+
+	bs := dagtest.Bserv()
+	dst := dag.NewDAGService(bs)
+	r := NewDAGReceiver(ctx, nil, bs, dst, nil, nil, bstore.NewGCLocker())
+	if _, _, err := r.receive(ctx, &buf); err == nil {
+		t.Fatal("expected the incomplete DAG to be refused")
+	}
+}
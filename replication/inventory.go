@@ -0,0 +1,148 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	p2phost "gx/ipfs/QmQQGtcp6nVUrQjNsnU53YWV1q8fK1Kd9S7FEkYbRZzxry/go-libp2p-host"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	net "gx/ipfs/QmXdgNhVEgjLxjUoMs5ViQL7pboAt3Y7V7eGHRiE4qrmTE/go-libp2p-net"
+	pro "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// InventoryProtocolID is the protocol the inventories of pinned roots are
+// exchanged over.
+const InventoryProtocolID pro.ID = "/ipfs/pin-inventory/1.0.0"
+
+// ErrNotTrusted is replied to the nodes asking for the inventory of a node
+// which doesn't trust them.
+var ErrNotTrusted = errors.New("not trusted by this node")
+
+// PinnedRoot is a root pinned by a node, recursively or directly.
+type PinnedRoot struct {
+	Cid  *cid.Cid
+	Type string
+}
+
+// InventoryServer sends the roots pinned by a node to the nodes it trusts.
+type InventoryServer struct {
+	trusted map[peer.ID]bool
+	pinning pin.Pinner
+}
+
+// NewInventoryServer returns an InventoryServer sending the roots pinned
+// with pinning to the nodes trusted.
+func NewInventoryServer(trusted []peer.ID, pinning pin.Pinner) *InventoryServer {
+	s := &InventoryServer{
+		trusted: make(map[peer.ID]bool, len(trusted)),
+		pinning: pinning,
+	}
+	for _, id := range trusted {
+		s.trusted[id] = true
+	}
+	return s
+}
+
+// HandleStream is the stream handler of InventoryProtocolID.
+func (s *InventoryServer) HandleStream(st net.Stream) {
+	defer st.Close()
+
+	from := st.Conn().RemotePeer()
+	if !s.trusted[from] {
+		log.Warningf("refused to send the pinned roots to %s, which isn't trusted", from.Pretty())
+		writeReply(st, ErrNotTrusted)
+		return
+	}
+
+	if err := writeInventory(st, s.pinning); err != nil {
+		log.Debugf("failed to send the pinned roots to %s: %s", from.Pretty(), err)
+		st.Reset()
+	}
+}
+
+// writeInventory writes the roots pinned with pinning to w, a line each,
+// followed by the reply.
+func writeInventory(w io.Writer, pinning pin.Pinner) error {
+	for _, p := range []struct {
+		typ  string
+		keys []*cid.Cid
+	}{
+		{"recursive", pinning.RecursiveKeys()},
+		{"direct", pinning.DirectKeys()},
+	} {
+		for _, c := range p.keys {
+			if _, err := fmt.Fprintf(w, "%s %s\n", c, p.typ); err != nil {
+				return err
+			}
+		}
+	}
+	return writeReply(w, nil)
+}
+
+// readInventory reads the roots written by writeInventory from r.
+func readInventory(r io.Reader) ([]PinnedRoot, error) {
+	var roots []PinnedRoot
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if line == replyOK || strings.HasPrefix(line, "error: ") {
+			if err := parseReply(line); err != nil {
+				return nil, err
+			}
+			return roots, nil
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid pinned root %q", line)
+		}
+		c, err := cid.Decode(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pinned root %q: %s", fields[0], err)
+		}
+		switch fields[1] {
+		case "recursive", "direct":
+		default:
+			return nil, fmt.Errorf("invalid type %q of the pinned root %s", fields[1], c)
+		}
+		roots = append(roots, PinnedRoot{Cid: c, Type: fields[1]})
+	}
+}
+
+// FetchInventory asks the node id for the roots it pins, which it only sends
+// if it trusts this node.
+func FetchInventory(ctx context.Context, h p2phost.Host, id peer.ID) ([]PinnedRoot, error) {
+	if err := h.Connect(ctx, pstore.PeerInfo{ID: id}); err != nil {
+		return nil, err
+	}
+	s, err := h.NewStream(ctx, id, InventoryProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	// unblock the reads below when ctx is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Reset()
+		case <-done:
+		}
+	}()
+
+	roots, err := readInventory(s)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return roots, err
+}
@@ -0,0 +1,64 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	dagtest "github.com/ipfs/go-ipfs/merkledag/test"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dssync "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
+)
+
+func TestInventory(t *testing.T) {
+	ctx := context.Background()
+
+	a := dag.NewRawNode([]byte("a"))
+	b := dag.NewRawNode([]byte("b"))
+	dst := dagtest.Mock()
+	pinning := pin.NewPinner(dssync.MutexWrap(ds.NewMapDatastore()), dst, dst)
+	for _, nd := range []*dag.RawNode{a, b} {
+		if err := dst.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pinning.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := pinning.Pin(ctx, b, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeInventory(&buf, pinning); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := readInventory(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 2 ||
+		!roots[0].Cid.Equals(a.Cid()) || roots[0].Type != "recursive" ||
+		!roots[1].Cid.Equals(b.Cid()) || roots[1].Type != "direct" {
+		t.Fatalf("unexpected inventory %v", roots)
+	}
+}
+
+func TestInventoryRefused(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeReply(&buf, ErrNotTrusted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readInventory(&buf); err == nil || err.Error() != ErrNotTrusted.Error() {
+		t.Fatalf("expected %q, got %v", ErrNotTrusted, err)
+	}
+
+	// an inventory cut short isn't taken for a complete one
+	if _, err := readInventory(strings.NewReader(dag.NewRawNode([]byte("a")).Cid().String() + " recursive\n")); err == nil {
+		t.Fatal("expected the truncated inventory to fail")
+	}
+}
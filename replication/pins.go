@@ -0,0 +1,163 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+	pininfo "github.com/ipfs/go-ipfs/repo/pininfo"
+
+	p2phost "gx/ipfs/QmQQGtcp6nVUrQjNsnU53YWV1q8fK1Kd9S7FEkYbRZzxry/go-libp2p-host"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	net "gx/ipfs/QmXdgNhVEgjLxjUoMs5ViQL7pboAt3Y7V7eGHRiE4qrmTE/go-libp2p-net"
+	pro "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	bstore "gx/ipfs/QmbaPGg81pvQiC5vTXtC9Jo8rdrWUjRaugH71WYNsgi6Ev/go-ipfs-blockstore"
+)
+
+// PinProtocolID is the protocol nodes are asked to pin DAGs over.
+const PinProtocolID pro.ID = "/ipfs/pin-replication/1.0.0"
+
+// ErrPinNotAllowed is replied to the nodes asking to pin which aren't allowed
+// to.
+var ErrPinNotAllowed = errors.New("not allowed to ask this node to pin")
+
+// PinReplica is the outcome of asking a node to pin a DAG.
+type PinReplica struct {
+	Cid  string
+	Peer string
+	// Error is why the node didn't pin the DAG, if it didn't.
+	Error string `json:",omitempty"`
+}
+
+// PinReceiver pins the DAGs the allowed nodes ask to.
+type PinReceiver struct {
+	ctx      context.Context
+	allowed  map[peer.ID]bool
+	dag      ipld.DAGService
+	pinning  pin.Pinner
+	pinInfo  *pininfo.Store
+	gcLocker bstore.GCLocker
+}
+
+// NewPinReceiver returns a PinReceiver fetching the DAGs the nodes allowed
+// ask to pin through ds, and pinning them with pinning.
+func NewPinReceiver(ctx context.Context, allowed []peer.ID, ds ipld.DAGService, pinning pin.Pinner, info *pininfo.Store, gcl bstore.GCLocker) *PinReceiver {
+	r := &PinReceiver{
+		ctx:      ctx,
+		allowed:  make(map[peer.ID]bool, len(allowed)),
+		dag:      ds,
+		pinning:  pinning,
+		pinInfo:  info,
+		gcLocker: gcl,
+	}
+	for _, id := range allowed {
+		r.allowed[id] = true
+	}
+	return r
+}
+
+// HandleStream is the stream handler of PinProtocolID.
+func (r *PinReceiver) HandleStream(s net.Stream) {
+	defer s.Close()
+
+	from := s.Conn().RemotePeer()
+	if !r.allowed[from] {
+		log.Warningf("refused to pin for %s, which isn't allowed to ask", from.Pretty())
+		writeReply(s, ErrPinNotAllowed)
+		return
+	}
+
+	line, err := readLine(s)
+	if err != nil {
+		log.Debugf("failed to read the DAG %s asks to pin: %s", from.Pretty(), err)
+		s.Reset()
+		return
+	}
+	c, err := cid.Decode(line)
+	if err != nil {
+		writeReply(s, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, PushTimeout)
+	defer cancel()
+
+	err = r.Pin(ctx, c)
+	if err != nil {
+		log.Errorf("failed to pin %s for %s: %s", c, from.Pretty(), err)
+	} else {
+		log.Infof("pinned %s for %s", c, from.Pretty())
+	}
+	writeReply(s, err)
+}
+
+// Pin fetches the DAG of c and pins it recursively.
+func (r *PinReceiver) Pin(ctx context.Context, c *cid.Cid) error {
+	// keep the fetched blocks from GC until they are pinned
+	defer r.gcLocker.PinLock().Unlock()
+
+	if err := dag.FetchGraph(ctx, c, r.dag); err != nil {
+		return err
+	}
+	nd, err := r.dag.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+	if err := r.pinning.Pin(ctx, nd, true); err != nil {
+		return err
+	}
+	if err := r.pinning.Flush(); err != nil {
+		return err
+	}
+	if err := r.pinInfo.Record(c, pininfo.Info{Origin: pininfo.OriginReplicate}); err != nil {
+		log.Error("failed to record the pin: ", err)
+	}
+	return nil
+}
+
+// ReplicatePin asks the peers, in order, to pin the DAG of c until n of them
+// did, n at a time, and returns the outcome for each peer asked. The peers
+// fetch the DAG from this node, which must have it.
+func ReplicatePin(ctx context.Context, h p2phost.Host, peers []peer.ID, c *cid.Cid, n int) []PinReplica {
+	return replicatePin(ctx, peers, c, n, func(ctx context.Context, id peer.ID) error {
+		return sendCid(ctx, h, id, PinProtocolID, c)
+	})
+}
+
+func replicatePin(ctx context.Context, peers []peer.ID, c *cid.Cid, n int, ask func(ctx context.Context, id peer.ID) error) []PinReplica {
+	var out []PinReplica
+	for pinned := 0; pinned < n && len(peers) > 0 && ctx.Err() == nil; {
+		batch := peers
+		if len(batch) > n-pinned {
+			batch = batch[:n-pinned]
+		}
+		peers = peers[len(batch):]
+
+		replicas := make([]PinReplica, len(batch))
+		var wg sync.WaitGroup
+		for i, id := range batch {
+			wg.Add(1)
+			go func(i int, id peer.ID) {
+				defer wg.Done()
+				replicas[i] = PinReplica{Cid: c.String(), Peer: id.Pretty()}
+				if err := ask(ctx, id); err != nil {
+					log.Warningf("failed to replicate the pin of %s to %s: %s", c, id.Pretty(), err)
+					replicas[i].Error = err.Error()
+				}
+			}(i, id)
+		}
+		wg.Wait()
+
+		for _, r := range replicas {
+			if r.Error == "" {
+				pinned++
+			}
+		}
+		out = append(out, replicas...)
+	}
+	return out
+}
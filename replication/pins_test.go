@@ -0,0 +1,40 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	u "gx/ipfs/QmPdKqUcHGFdeSpvjVoaTRPPstGif9GBZb5Q56RVw9o69A/go-ipfs-util"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+func TestReplicatePin(t *testing.T) {
+	c := cid.NewCidV0(u.Hash([]byte("root")))
+	peers := []peer.ID{"a", "b", "c", "d"}
+
+	var mu sync.Mutex
+	var asked []peer.ID
+	replicas := replicatePin(context.Background(), peers, c, 2, func(ctx context.Context, id peer.ID) error {
+		mu.Lock()
+		asked = append(asked, id)
+		mu.Unlock()
+		if id == "b" {
+			return errors.New("not allowed")
+		}
+		return nil
+	})
+
+	// b failing, c is asked in its place, and d isn't
+	if len(asked) != 3 || len(replicas) != 3 {
+		t.Fatalf("expected a, b and c to be asked, got %v", replicas)
+	}
+	for i, id := range []peer.ID{"a", "b", "c"} {
+		r := replicas[i]
+		if r.Peer != id.Pretty() || r.Cid != c.String() || (r.Error != "") != (id == "b") {
+			t.Fatalf("unexpected outcome %+v for %s", r, id.Pretty())
+		}
+	}
+}
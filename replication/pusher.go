@@ -0,0 +1,202 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	p2phost "gx/ipfs/QmQQGtcp6nVUrQjNsnU53YWV1q8fK1Kd9S7FEkYbRZzxry/go-libp2p-host"
+	goprocess "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	pro "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	pstore "gx/ipfs/QmZhsmorLpD9kmQ4ynbAu4vbKv2goMUnXazwGA4gnWHDjB/go-libp2p-peerstore"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// RetryInterval is how long a failed push waits before being retried.
+const RetryInterval = time.Minute
+
+// PushTimeout is how long a replica is given to fetch a root.
+const PushTimeout = time.Hour
+
+// Status is the state of the replication to a replica.
+type Status struct {
+	Peer string
+	// Root is the last root the replica copied, and Updated when.
+	Root    string `json:",omitempty"`
+	Updated time.Time
+	// Pending is the root being pushed to the replica, if any.
+	Pending string `json:",omitempty"`
+	// LastError is why the last push failed, if it did.
+	LastError string `json:",omitempty"`
+}
+
+type replica struct {
+	id   peer.ID
+	wake chan struct{}
+
+	// guarded by Pusher.mu
+	pending *cid.Cid
+	status  Status
+}
+
+// Pusher pushes the MFS roots of a primary to its replicas.
+type Pusher struct {
+	replicas []*replica
+
+	mu sync.Mutex
+
+	push          func(ctx context.Context, id peer.ID, c *cid.Cid) error
+	retryInterval time.Duration
+}
+
+// NewPusher returns a Pusher pushing the roots to the replicas through h.
+func NewPusher(h p2phost.Host, replicas []peer.ID) *Pusher {
+	p := &Pusher{
+		push:          func(ctx context.Context, id peer.ID, c *cid.Cid) error { return pushRoot(ctx, h, id, c) },
+		retryInterval: RetryInterval,
+	}
+	for _, id := range replicas {
+		p.replicas = append(p.replicas, &replica{
+			id:     id,
+			wake:   make(chan struct{}, 1),
+			status: Status{Peer: id.Pretty()},
+		})
+	}
+	return p
+}
+
+// Push queues c to be pushed to the replicas, in place of the roots not
+// pushed yet.
+func (p *Pusher) Push(c *cid.Cid) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, r := range p.replicas {
+		r.pending = c
+		r.status.Pending = c.String()
+		select {
+		case r.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Status returns the state of the replication to each replica.
+func (p *Pusher) Status() []Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make([]Status, 0, len(p.replicas))
+	for _, r := range p.replicas {
+		out = append(out, r.status)
+	}
+	return out
+}
+
+// Run pushes the queued roots until proc closes.
+func (p *Pusher) Run(proc goprocess.Process) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, r := range p.replicas {
+		wg.Add(1)
+		go func(r *replica) {
+			defer wg.Done()
+			p.run(ctx, r)
+		}(r)
+	}
+
+	<-proc.Closing()
+	cancel()
+	wg.Wait()
+}
+
+func (p *Pusher) run(ctx context.Context, r *replica) {
+	for {
+		select {
+		case <-r.wake:
+		case <-ctx.Done():
+			return
+		}
+
+		for {
+			p.mu.Lock()
+			c := r.pending
+			p.mu.Unlock()
+			if c == nil {
+				break
+			}
+
+			err := p.push(ctx, r.id, c)
+
+			p.mu.Lock()
+			if err != nil {
+				r.status.LastError = err.Error()
+			} else {
+				r.status.Root = c.String()
+				r.status.Updated = time.Now().UTC().Truncate(time.Second)
+				r.status.LastError = ""
+				if r.pending.Equals(c) {
+					r.pending = nil
+					r.status.Pending = ""
+				}
+			}
+			p.mu.Unlock()
+
+			if err != nil {
+				log.Warningf("failed to push %s to replica %s: %s", c, r.id.Pretty(), err)
+				select {
+				case <-time.After(p.retryInterval):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// pushRoot sends c to the replica id and waits for it to copy it.
+func pushRoot(ctx context.Context, h p2phost.Host, id peer.ID, c *cid.Cid) error {
+	return sendCid(ctx, h, id, ProtocolID, c)
+}
+
+// sendCid sends c to the node id over proto and waits for its reply.
+func sendCid(ctx context.Context, h p2phost.Host, id peer.ID, proto pro.ID, c *cid.Cid) error {
+	ctx, cancel := context.WithTimeout(ctx, PushTimeout)
+	defer cancel()
+
+	if err := h.Connect(ctx, pstore.PeerInfo{ID: id}); err != nil {
+		return err
+	}
+	s, err := h.NewStream(ctx, id, proto)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// unblock the read below when ctx is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Reset()
+		case <-done:
+		}
+	}()
+
+	if _, err := fmt.Fprintln(s, c.String()); err != nil {
+		return err
+	}
+	line, err := readLine(s)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return parseReply(line)
+}
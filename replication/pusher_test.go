@@ -0,0 +1,69 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	u "gx/ipfs/QmPdKqUcHGFdeSpvjVoaTRPPstGif9GBZb5Q56RVw9o69A/go-ipfs-util"
+	goprocess "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+func TestPusherRetries(t *testing.T) {
+	id := peer.ID("replica")
+	a, b := cid.NewCidV0(u.Hash([]byte("a"))), cid.NewCidV0(u.Hash([]byte("b")))
+
+	var mu sync.Mutex
+	var pushed []*cid.Cid
+	fail := true
+	p := NewPusher(nil, []peer.ID{id})
+	p.retryInterval = 10 * time.Millisecond
+	p.push = func(ctx context.Context, to peer.ID, c *cid.Cid) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if to != id {
+			t.Errorf("pushed to %s", to)
+		}
+		if fail {
+			fail = false
+			return errors.New("unreachable")
+		}
+		pushed = append(pushed, c)
+		return nil
+	}
+
+	proc := goprocess.WithParent(goprocess.Background())
+	defer proc.Close()
+
+	mu.Lock()
+	p.Push(a)
+	go p.Run(proc)
+	p.Push(b)
+	mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st := p.Status()[0]
+		if st.Root == b.String() && st.Pending == "" {
+			if st.LastError != "" {
+				t.Fatalf("expected the error to be cleared, got %q", st.LastError)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out, status: %+v", st)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// the failed push of a is retried with the latest root
+	if len(pushed) != 1 || !pushed[0].Equals(b) {
+		t.Fatalf("expected only %s to be pushed, got %v", b, pushed)
+	}
+}
@@ -0,0 +1,126 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+
+	peer "gx/ipfs/QmVf8hTAsLLFtn4WPCRNdnaF2Eag2qTBS6uR8AiHPZARXy/go-libp2p-peer"
+	ipld "gx/ipfs/QmWi2BYBL5gJ3CiAiQchg6rn1A8iBsrWy51EYxvHVjFvLb/go-ipld-format"
+	net "gx/ipfs/QmXdgNhVEgjLxjUoMs5ViQL7pboAt3Y7V7eGHRiE4qrmTE/go-libp2p-net"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	bstore "gx/ipfs/QmbaPGg81pvQiC5vTXtC9Jo8rdrWUjRaugH71WYNsgi6Ev/go-ipfs-blockstore"
+)
+
+// ErrNotPrimary is replied to the nodes pushing roots which aren't primaries
+// of the replica.
+var ErrNotPrimary = errors.New("not a primary of this node")
+
+// Receiver copies the roots pushed by the primaries of a replica into its
+// MFS tree.
+type Receiver struct {
+	ctx       context.Context
+	primaries map[peer.ID]bool
+	dag       ipld.DAGService
+	root      *mfs.Root
+	gcLocker  bstore.GCLocker
+
+	// mu serializes the updates of the tree
+	mu sync.Mutex
+}
+
+// NewReceiver returns a Receiver accepting the roots of primaries, fetched
+// through ds and put in root.
+func NewReceiver(ctx context.Context, primaries []peer.ID, ds ipld.DAGService, root *mfs.Root, gcl bstore.GCLocker) *Receiver {
+	r := &Receiver{
+		ctx:       ctx,
+		primaries: make(map[peer.ID]bool, len(primaries)),
+		dag:       ds,
+		root:      root,
+		gcLocker:  gcl,
+	}
+	for _, id := range primaries {
+		r.primaries[id] = true
+	}
+	return r
+}
+
+// HandleStream is the stream handler of ProtocolID.
+func (r *Receiver) HandleStream(s net.Stream) {
+	defer s.Close()
+
+	from := s.Conn().RemotePeer()
+	if !r.primaries[from] {
+		log.Warningf("refused a root pushed by %s, which isn't a primary", from.Pretty())
+		writeReply(s, ErrNotPrimary)
+		return
+	}
+
+	line, err := readLine(s)
+	if err != nil {
+		log.Debugf("failed to read the root pushed by %s: %s", from.Pretty(), err)
+		s.Reset()
+		return
+	}
+	c, err := cid.Decode(line)
+	if err != nil {
+		writeReply(s, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, PushTimeout)
+	defer cancel()
+
+	err = r.Replicate(ctx, from, c)
+	if err != nil {
+		log.Errorf("failed to replicate %s from %s: %s", c, from.Pretty(), err)
+	}
+	writeReply(s, err)
+}
+
+// Replicate fetches the DAG of c, the root of the primary from, and puts it
+// at /replicas/<from> in the MFS tree, in place of the previous root.
+func (r *Receiver) Replicate(ctx context.Context, from peer.ID, c *cid.Cid) error {
+	// keep the fetched blocks from GC until they are in the tree
+	defer r.gcLocker.PinLock().Unlock()
+
+	if err := dag.FetchGraph(ctx, c, r.dag); err != nil {
+		return err
+	}
+	nd, err := r.dag.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := mfs.Mkdir(r.root, Dir, mfs.MkdirOpts{Mkparents: true}); err != nil {
+		return err
+	}
+	fsn, err := mfs.Lookup(r.root, Dir)
+	if err != nil {
+		return err
+	}
+	dir, ok := fsn.(*mfs.Directory)
+	if !ok {
+		return errors.New(Dir + " is not a directory")
+	}
+
+	name := from.Pretty()
+	if _, err := dir.Child(name); err == nil {
+		if err := dir.Unlink(name); err != nil {
+			return err
+		}
+	} else if err != os.ErrNotExist {
+		return err
+	}
+	if err := dir.AddChild(name, nd); err != nil {
+		return err
+	}
+	return mfs.FlushPath(r.root, Dir)
+}
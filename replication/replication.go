@@ -0,0 +1,91 @@
+// Package replication copies DAGs between nodes, without a cluster.
+//
+// The MFS tree of a node, the primary, is copied to other nodes, its
+// replicas, for a simple primary/replica setup. Each time the primary
+// publishes a new MFS root, it sends its CID to every replica over the
+// /ipfs/mfs-replication/1.0.0 protocol. The replica fetches the DAG of the
+// root, from the primary over bitswap, puts it at /replicas/<primary peer ID>
+// in its own MFS tree and then replies. Failed pushes are retried with the
+// latest root, so a replica that was offline catches up once it's back.
+//
+// The protocol is line based: the primary writes the CID of the root, the
+// replica replies "ok", or "error: " followed by the reason.
+//
+// A DAG is pushed to another node with PushDAG, over the /ipfs/dag-push/1.0.0
+// protocol. The pushing node writes "pin" or "nopin", then the CAR file of
+// the DAG. The receiving node checks its blocks, adds them, pins the root if
+// asked to and replies like above.
+//
+// Trusted nodes can also tell each other the roots they pin, for followers to
+// discover what to replicate, over the /ipfs/pin-inventory/1.0.0 protocol.
+// The asked node writes "<cid> recursive" or "<cid> direct" for each root,
+// then replies like above, if it trusts the asking node.
+//
+// A node pinning a DAG can ask others to pin it too with ReplicatePin, over
+// the /ipfs/pin-replication/1.0.0 protocol. The asking node writes the CID
+// of the root, the asked node fetches its DAG, pins it and replies like
+// above.
+package replication
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	pro "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
+)
+
+var log = logging.Logger("replication")
+
+// ProtocolID is the protocol roots are pushed over.
+const ProtocolID pro.ID = "/ipfs/mfs-replication/1.0.0"
+
+// Dir is the MFS directory of the replicas the roots of each primary are
+// put in, under the ID of the primary.
+const Dir = "/replicas"
+
+// maxLine is the size of the longest line read from a stream.
+const maxLine = 1024
+
+const replyOK = "ok"
+
+// readLine reads a line of r, a byte at a time to leave what follows it in
+// r.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	var b [1]byte
+	for len(line) < maxLine {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		if b[0] == '\n' {
+			return string(line), nil
+		}
+		line = append(line, b[0])
+	}
+	return "", errors.New("line too long")
+}
+
+func writeReply(w io.Writer, err error) error {
+	if err != nil {
+		_, werr := fmt.Fprintf(w, "error: %s\n", strings.Replace(err.Error(), "\n", " ", -1))
+		return werr
+	}
+	_, werr := fmt.Fprintln(w, replyOK)
+	return werr
+}
+
+func parseReply(line string) error {
+	if line == replyOK {
+		return nil
+	}
+	if strings.HasPrefix(line, "error: ") {
+		return errors.New(strings.TrimPrefix(line, "error: "))
+	}
+	return fmt.Errorf("unexpected reply %q", line)
+}
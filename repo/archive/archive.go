@@ -0,0 +1,188 @@
+// Package archive reads and writes repo archives, the tar streams made by
+// 'ipfs repo backup'. An archive holds files, like the config of the repo, and
+// datastore entries, stored under datastore/ and named after their key: the
+// entry of the key /local/pins is the file datastore/local/pins. The
+// segments of the keys are escaped as in URL paths.
+package archive
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dsq "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/query"
+)
+
+// DatastoreDir is the directory of the datastore entries in an archive.
+const DatastoreDir = "datastore"
+
+// maxFileSize is the size of the largest file or entry read from an
+// archive.
+const maxFileSize = 64 << 20
+
+// Writer writes an archive.
+type Writer struct {
+	tw  *tar.Writer
+	now time.Time
+}
+
+// NewWriter returns a Writer writing an archive to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		tw:  tar.NewWriter(w),
+		now: time.Now(),
+	}
+}
+
+// WriteFile adds a file to the archive.
+func (w *Writer) WriteFile(name string, data []byte) error {
+	err := w.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: w.now,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.tw.Write(data)
+	return err
+}
+
+// WriteEntry adds the datastore entry of k to the archive.
+func (w *Writer) WriteEntry(k ds.Key, value []byte) error {
+	return w.WriteFile(entryName(k), value)
+}
+
+// WriteDatastore adds the entries of d under prefix to the archive, except
+// those under one of the skipped keys, and returns their number. Only the keys
+// are listed, and the value of each entry is read when it is written; the
+// entries removed in between are left out.
+func (w *Writer) WriteDatastore(d ds.Datastore, prefix ds.Key, skip ...ds.Key) (int, error) {
+	res, err := d.Query(dsq.Query{Prefix: prefix.String(), KeysOnly: true})
+	if err != nil {
+		return 0, err
+	}
+	defer res.Close()
+
+	n := 0
+	for e := range res.Next() {
+		if e.Error != nil {
+			return n, e.Error
+		}
+
+		k := ds.RawKey(e.Key)
+		if under(k, skip) {
+			continue
+		}
+		v, err := d.Get(k)
+		if err == ds.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		value, ok := v.([]byte)
+		if !ok {
+			return n, fmt.Errorf("value of %s is not bytes", k)
+		}
+		if err := w.WriteEntry(k, value); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// under returns whether k is one of keys or under one of them.
+func under(k ds.Key, keys []ds.Key) bool {
+	for _, p := range keys {
+		if k.Equal(p) || p.IsAncestorOf(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close finishes the archive. It doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	return w.tw.Close()
+}
+
+// Item is a file or a datastore entry of an archive.
+type Item struct {
+	// Name is the name of the file in the archive.
+	Name string
+	// Key is the key of the datastore entry, if the item is one.
+	Key *ds.Key
+	// Data is the content of the file, or the value of the entry.
+	Data []byte
+}
+
+// Reader reads an archive.
+type Reader struct {
+	tr *tar.Reader
+}
+
+// NewReader returns a Reader reading the archive of r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{tr: tar.NewReader(r)}
+}
+
+// Next returns the next item of the archive, or io.EOF at its end.
+func (r *Reader) Next() (*Item, error) {
+	for {
+		hdr, err := r.tr.Next()
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if hdr.Size > maxFileSize {
+			return nil, fmt.Errorf("%s is too large: %d bytes", hdr.Name, hdr.Size)
+		}
+
+		data, err := ioutil.ReadAll(r.tr)
+		if err != nil {
+			return nil, err
+		}
+
+		it := &Item{Name: hdr.Name, Data: data}
+		if strings.HasPrefix(hdr.Name, DatastoreDir+"/") {
+			k, err := entryKey(hdr.Name)
+			if err != nil {
+				return nil, err
+			}
+			it.Key = &k
+		}
+		return it, nil
+	}
+}
+
+// entryName returns the name of the entry of k in an archive.
+func entryName(k ds.Key) string {
+	segments := k.List()
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return DatastoreDir + "/" + strings.Join(segments, "/")
+}
+
+// entryKey returns the key of the entry of an archive named name.
+func entryKey(name string) (ds.Key, error) {
+	segments := strings.Split(strings.TrimPrefix(name, DatastoreDir+"/"), "/")
+	for i, s := range segments {
+		u, err := url.PathUnescape(s)
+		if err != nil {
+			return ds.Key{}, fmt.Errorf("invalid entry name %s: %s", name, err)
+		}
+		segments[i] = u
+	}
+	return ds.KeyWithNamespaces(segments), nil
+}
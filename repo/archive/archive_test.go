@@ -0,0 +1,76 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+)
+
+func TestRoundTrip(t *testing.T) {
+	d := ds.NewMapDatastore()
+	entries := map[ds.Key]string{
+		ds.NewKey("/local/pins"):        "pins",
+		ds.NewKey("/blocks/CIQA"):       "block",
+		ds.NewKey("/odd/a b%2F/c?d#e"):  "escaped",
+		ds.NewKey("/blocks/CIQB/extra"): "nested block",
+	}
+	for k, v := range entries {
+		if err := d.Put(k, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteFile("config", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	n, err := w.WriteDatastore(d, ds.NewKey("/"), ds.NewKey("/blocks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("wrote %d entries, expected 2 without the blocks", n)
+	}
+	if _, err := w.WriteDatastore(d, ds.NewKey("/blocks")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(&buf)
+	it, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it.Name != "config" || it.Key != nil || string(it.Data) != "{}" {
+		t.Fatalf("unexpected first item: %+v", it)
+	}
+
+	read := make(map[ds.Key]string)
+	for {
+		it, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if it.Key == nil {
+			t.Fatalf("%s is not an entry", it.Name)
+		}
+		read[*it.Key] = string(it.Data)
+	}
+
+	if len(read) != len(entries) {
+		t.Fatalf("read %d entries, expected %d", len(read), len(entries))
+	}
+	for k, v := range entries {
+		if read[k] != v {
+			t.Fatalf("entry %s is %q, expected %q", k, read[k], v)
+		}
+	}
+}
@@ -7,4 +7,8 @@ type Addresses struct {
 	NoAnnounce []string // swarm addresses not to announce to the network
 	API        string   // address for the local API (RPC)
 	Gateway    string   // address to listen on for IPFS HTTP object gateway
+
+	// SocketMode is the file mode (e.g. "0660") of the unix sockets the API
+	// and the gateway listen on.
+	SocketMode string `json:",omitempty"`
 }
@@ -2,4 +2,49 @@ package config
 
 type API struct {
 	HTTPHeaders map[string][]string // HTTP headers to return with the API.
+
+	// Tokens maps token names to the tokens allowed to access the API. When
+	// empty, the API doesn't require authentication.
+	Tokens map[string]APIToken `json:",omitempty"`
+
+	// Authorizations maps identities (token names, or "*" for every caller)
+	// to the commands they may or may not invoke.
+	Authorizations map[string]APIAuthorization `json:",omitempty"`
+
+	// Quotas maps identities (token names, or "*" for every caller) to the
+	// size of the DAGs each of them may add and pin, in B, kB, kiB, MB, ...
+	// The callers without a token are told apart by their address.
+	Quotas map[string]string `json:",omitempty"`
+
+	// Origins lists the browser origins allowed to make requests to the
+	// API, in addition to those allowed by the
+	// Access-Control-Allow-Origin header in HTTPHeaders.
+	Origins []APIOrigin `json:",omitempty"`
+}
+
+// APIToken is an API access token. Only the hash of the token secret is
+// stored.
+type APIToken struct {
+	Hash   string   // hex encoded sha256 of the token secret
+	Scopes []string // scopes limiting which commands the token may invoke
+
+	// Quota is the size of the DAGs the token may add and pin, in B, kB,
+	// kiB, MB, ... Unlimited when empty.
+	Quota string `json:",omitempty"`
+}
+
+// APIAuthorization restricts the commands an identity may invoke over the
+// API. Denied commands take precedence over allowed ones, and an empty Allow
+// list allows every command not denied.
+type APIAuthorization struct {
+	Allow []string `json:",omitempty"`
+	Deny  []string `json:",omitempty"`
+}
+
+// APIOrigin allows browser requests to the API from the origins matching
+// Origin, e.g. "https://webui.example.com" or "chrome-extension://*".
+type APIOrigin struct {
+	Origin           string
+	AllowCredentials bool `json:",omitempty"`
+	ReadOnly         bool `json:",omitempty"` // only allow read-only commands
 }
@@ -0,0 +1,15 @@
+package config
+
+// BootstrapCheck configures the health checks of the bootstrap peers.
+type BootstrapCheck struct {
+	// Interval is how often the daemon probes the bootstrap peers. "0"
+	// disables the probes. Default: 1h.
+	Interval string `json:",omitempty"`
+	// DeadAfter is the number of consecutive failed probes after which a
+	// bootstrap peer is only dialed when the other ones aren't enough.
+	// Default: 5.
+	DeadAfter int `json:",omitempty"`
+	// DNSAddrTTL is how long the addresses /dnsaddr entries resolve to are
+	// cached. Default: 1h.
+	DNSAddrTTL string `json:",omitempty"`
+}
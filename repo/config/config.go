@@ -25,6 +25,15 @@ type Config struct {
 	Gateway   Gateway   // local node's gateway server options
 	API       API       // local node's API settings
 	Swarm     SwarmConfig
+	Denylist  Denylist  // content blocking settings
+	Shutdown  Shutdown  // daemon shutdown settings
+	Traversal Traversal // DAG walk settings
+	PowerSave PowerSave // power save mode settings
+
+	Replication Replication // MFS replication settings
+	Webhooks    Webhooks    // event notification settings
+
+	BootstrapCheck BootstrapCheck // bootstrap peer health check settings
 
 	Reprovider   Reprovider
 	Experimental Experiments
@@ -23,8 +23,57 @@ type Datastore struct {
 
 	HashOnRead      bool
 	BloomFilterSize int
+
+	// Sync is when the writes to the datastores are synced to disk, one of
+	// the Sync constants. Empty leaves it to the settings of each datastore.
+	Sync string `json:",omitempty"`
+
+	// DiskWatchdog keeps the repo from filling its filesystem.
+	DiskWatchdog DiskWatchdog
+
+	// ImportBatch bounds the blocks of the files added buffered before
+	// they're written.
+	ImportBatch ImportBatch
+}
+
+// ImportBatch configures the batches of blocks written when adding files.
+// Unset fields keep the defaults.
+type ImportBatch struct {
+	// MaxNodes is the number of blocks of a batch.
+	MaxNodes int `json:",omitempty"`
+	// MaxSize is the size of the blocks of a batch, like "8MB".
+	MaxSize string `json:",omitempty"`
+	// SyncEvery, like "256MB", has the batches committed and waited for
+	// each time that much data was added, 32MB by default.
+	SyncEvery string `json:",omitempty"`
 }
 
+// DiskWatchdog configures the thresholds of free space of the filesystem of
+// the repo, like "5GB", below which the daemon acts. Unset thresholds are
+// disabled.
+type DiskWatchdog struct {
+	// WarnFreeSpace is the free space below which warnings are logged.
+	WarnFreeSpace string `json:",omitempty"`
+	// GCFreeSpace is the free space below which the daemon runs the garbage
+	// collection.
+	GCFreeSpace string `json:",omitempty"`
+	// MinFreeSpace is the free space below which new blocks are refused.
+	MinFreeSpace string `json:",omitempty"`
+	// CheckInterval is how often the free space is checked. Default: 10s.
+	CheckInterval string `json:",omitempty"`
+}
+
+// The values of Datastore.Sync.
+const (
+	// SyncAlways syncs every write.
+	SyncAlways = "always"
+	// SyncOnBatchCommit syncs the batches of writes, like the blocks of the
+	// files added, when they are committed, and not the single writes.
+	SyncOnBatchCommit = "on-batch-commit"
+	// SyncNever doesn't sync the writes, leaving it to the OS.
+	SyncNever = "never"
+)
+
 // DataStorePath returns the default data store path given a configuration root
 // (set an empty string to have the default configuration root)
 func DataStorePath(configroot string) (string, error) {
@@ -0,0 +1,11 @@
+package config
+
+// Denylist configures content blocking.
+type Denylist struct {
+	// Files lists denylist files. Relative paths are relative to the repo
+	// root.
+	Files []string
+
+	// ReloadInterval is how often the files are checked for changes.
+	ReloadInterval string
+}
@@ -4,4 +4,5 @@ type Experiments struct {
 	FilestoreEnabled     bool
 	ShardingEnabled      bool
 	Libp2pStreamMounting bool
+	HTTPProvidersEnabled bool
 }
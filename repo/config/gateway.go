@@ -6,4 +6,77 @@ type Gateway struct {
 	RootRedirect string
 	Writable     bool
 	PathPrefixes []string
+
+	// Precompressed enables serving the pre-compressed variants of files,
+	// foo.js.br and foo.js.gz next to foo.js, to the clients accepting
+	// them. It maps the hostnames of the requests to whether it's enabled
+	// for them, "*" for the other hostnames.
+	Precompressed map[string]bool `json:",omitempty"`
+
+	// SignResponses adds a header to the responses, signed with the key of
+	// the node, binding the requested path to the CIDs it resolved to.
+	SignResponses bool `json:",omitempty"`
+
+	// Compression compresses the responses on the fly.
+	Compression GatewayCompression
+
+	// RetrievalBudget bounds the time the requests wait for their content.
+	RetrievalBudget GatewayRetrievalBudget
+
+	// StaleWhileRevalidate serves the last known value of the IPNS names
+	// slow to resolve while they are resolved again. It maps the hostnames
+	// of the requests to its settings, "*" for the other hostnames.
+	StaleWhileRevalidate map[string]GatewayStaleWhileRevalidate `json:",omitempty"`
+
+	// FollowSymlinks follows the unixfs symlinks of the paths requested to
+	// their targets, instead of failing to serve them.
+	FollowSymlinks bool `json:",omitempty"`
+}
+
+// GatewayStaleWhileRevalidate configures the serving of the last known value
+// of the IPNS names while they are resolved again. The durations are strings
+// like "1m30s".
+type GatewayStaleWhileRevalidate struct {
+	Enabled bool
+	// Wait is how long the resolution is waited for before serving the last
+	// known value. Default: 500ms.
+	Wait string `json:",omitempty"`
+	// MaxStale is the age beyond which the last known value isn't served
+	// anymore. Default: 24h.
+	MaxStale string `json:",omitempty"`
+}
+
+// GatewayRetrievalBudget bounds the time a gateway request waits for its
+// content, after which it fails with a 504 Gateway Timeout. The durations
+// are strings like "1m30s", "0" disabling the bound.
+type GatewayRetrievalBudget struct {
+	// Routing is the longest time a request may spend looking up its
+	// content in the routing system without receiving a block.
+	// Default: 1m.
+	Routing string `json:",omitempty"`
+	// NoProgress is the longest time a request may go without receiving a
+	// block. Default: 2m.
+	NoProgress string `json:",omitempty"`
+}
+
+// GatewayCompression configures the compression of the gateway responses on
+// the fly.
+type GatewayCompression struct {
+	Enabled bool
+	// MinSize is the size in bytes of the smallest response compressed.
+	MinSize int64 `json:",omitempty"`
+	// MaxSize is the size in bytes of the largest response compressed, no
+	// limit when zero.
+	MaxSize int64 `json:",omitempty"`
+	// Level is the gzip compression level, from 1 (fastest) to 9 (smallest).
+	Level int `json:",omitempty"`
+	// Encodings are the content encodings used, "gzip" and "zstd", in order
+	// of preference, the first the client accepts being used.
+	Encodings []string `json:",omitempty"`
+	// MaxConcurrent is the number of responses compressed at once, beyond
+	// which the responses are sent uncompressed to bound the CPU used.
+	MaxConcurrent int `json:",omitempty"`
+	// Types are the content types of the responses compressed; "text/*"
+	// matches all the text types.
+	Types []string `json:",omitempty"`
 }
@@ -5,4 +5,15 @@ type Ipns struct {
 	RecordLifetime  string
 
 	ResolveCacheSize int
+
+	// ResolveCacheTTL is how long the resolved names are cached, instead of
+	// the TTL of their IPNS records, never past the EOL of the records.
+	// Default: the TTL of the records.
+	ResolveCacheTTL string `json:",omitempty"`
+	// ResolveRecordCount is the number of IPNS records a resolution waits
+	// for before selecting the best one. Default: 16.
+	ResolveRecordCount int `json:",omitempty"`
+	// ResolveTimeout is the longest time a resolution waits for the IPNS
+	// records, "0" for no timeout. Default: 1m.
+	ResolveTimeout string `json:",omitempty"`
 }
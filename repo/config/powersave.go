@@ -0,0 +1,17 @@
+package config
+
+// PowerSave configures the power save mode of the daemon. In power save, the
+// daemon pauses reproviding, stops serving the DHT to other peers and keeps
+// fewer connections.
+type PowerSave struct {
+	// Auto switches power save on while the machine runs on battery, on the
+	// platforms where the daemon can tell.
+	Auto bool `json:",omitempty"`
+	// CheckInterval is how often the power source is checked when Auto is
+	// set. Default: 1m.
+	CheckInterval string `json:",omitempty"`
+	// LowWater and HighWater are the limits of the connection manager in
+	// power save. Default: 10 and 20.
+	LowWater  int `json:",omitempty"`
+	HighWater int `json:",omitempty"`
+}
@@ -1,6 +1,16 @@
 package config
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-ipfs/thirdparty/jsonconf"
+)
 
 // Transformer is a function which takes configuration and applies some filter to it
 type Transformer func(c *Config) error
@@ -79,6 +89,23 @@ is useful when using the daemon in test environments.`,
 			return nil
 		},
 	},
+	"randomports": {
+		Description: `Uses a random free port for the swarm instead of 4001.
+The port is picked when the profile is applied and written
+to the config, it doesn't change when the daemon restarts.`,
+
+		Transform: func(c *Config) error {
+			port, err := freePort()
+			if err != nil {
+				return err
+			}
+			c.Addresses.Swarm = []string{
+				fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", port),
+				fmt.Sprintf("/ip6/::/tcp/%d", port),
+			}
+			return nil
+		},
+	},
 	"default-networking": {
 		Description: `Restores default network settings.
 Inverse profile of the test profile.`,
@@ -147,7 +174,8 @@ $ ipfs-ds-convert convert --help
 	"lowpower": {
 		Description: `Reduces daemon overhead on the system. May affect node
 functionality - performance of content discovery and data
-fetching may be degraded.
+fetching may be degraded. The daemon also switches to power
+save while the machine runs on battery.
 `,
 		Transform: func(c *Config) error {
 			c.Routing.Type = "dhtclient"
@@ -156,11 +184,99 @@ fetching may be degraded.
 			c.Swarm.ConnMgr.LowWater = 20
 			c.Swarm.ConnMgr.HighWater = 40
 			c.Swarm.ConnMgr.GracePeriod = time.Minute.String()
+
+			c.PowerSave.Auto = true
 			return nil
 		},
 	},
 }
 
+// ParseProfiles splits a list of profiles given to 'ipfs init --profile' or
+// 'ipfs config profile apply'. Profiles are separated by ',' or '+', e.g.
+// "server+badgerds+lowpower", and are applied in the given order.
+func ParseProfiles(s string) []string {
+	var out []string
+	for _, name := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '+'
+	}) {
+		if name = strings.TrimSpace(name); name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// GetProfile returns the built-in profile with the given name. Names which
+// contain a path separator or end in ".json" refer to a user-defined profile
+// and are loaded with LoadProfile.
+func GetProfile(name string) (Profile, error) {
+	if strings.ContainsRune(name, filepath.Separator) || strings.ContainsRune(name, '/') ||
+		strings.HasSuffix(name, ".json") {
+		return LoadProfile(name)
+	}
+
+	p, ok := Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("invalid configuration profile: %s", name)
+	}
+	return p, nil
+}
+
+// LoadProfile reads a user-defined profile from a file. The file holds a JSON
+// merge patch (RFC 7386) which is applied to the config, e.g.
+//
+//	{"Swarm": {"ConnMgr": {"HighWater": 100}}, "Bootstrap": []}
+//
+// The patch is checked against the config structure when it is loaded, and
+// the same patch gives the same config every time it is applied.
+func LoadProfile(path string) (Profile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Profile{}, fmt.Errorf("failed to read profile: %s", err)
+	}
+	if err := Validate(data); err != nil {
+		return Profile{}, fmt.Errorf("invalid profile %s:\n%s", path, err)
+	}
+
+	var patch map[string]interface{}
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return Profile{}, fmt.Errorf("invalid profile %s: %s", path, err)
+	}
+	if _, ok := patch["Identity"]; ok {
+		return Profile{}, fmt.Errorf("invalid profile %s: profiles can't change the identity", path)
+	}
+
+	return Profile{
+		Description: fmt.Sprintf("User-defined profile loaded from %s.", path),
+		Transform: func(c *Config) error {
+			m, err := ToMap(c)
+			if err != nil {
+				return err
+			}
+			m, ok := jsonconf.MergeValue(m, patch).(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("profile %s doesn't give a config object", path)
+			}
+			nc, err := FromMap(m)
+			if err != nil {
+				return err
+			}
+			*c = *nc
+			return nil
+		},
+	}, nil
+}
+
+// freePort returns a TCP port which nothing listens on at the moment.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "0.0.0.0:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
 func appendSingle(a []string, b []string) []string {
 	out := make([]string, 0, len(a)+len(b))
 	m := map[string]bool{}
@@ -0,0 +1,27 @@
+package config
+
+// Replication configures the copies of DAGs between nodes: the replication
+// of the MFS tree of a primary to each of its replicas when it changes, and
+// the DAGs pushed with 'ipfs dag push', the exchange of the roots pinned by
+// trusted nodes and the replication of the pins with 'ipfs pin add
+// --replicate'.
+type Replication struct {
+	// Replicas are the peer IDs of the nodes the MFS tree of this node is
+	// copied to.
+	Replicas []string `json:",omitempty"`
+	// Primaries are the peer IDs of the nodes allowed to copy their MFS tree
+	// to this node, under /replicas/<peer ID>.
+	Primaries []string `json:",omitempty"`
+	// AllowPush are the peer IDs of the nodes allowed to push DAGs to this
+	// node with 'ipfs dag push'.
+	AllowPush []string `json:",omitempty"`
+	// Inventory are the peer IDs of the trusted nodes this node tells the
+	// roots it pins, with 'ipfs pin inventory'.
+	Inventory []string `json:",omitempty"`
+	// PinReplicas are the peer IDs of the nodes asked to pin the DAGs pinned
+	// with 'ipfs pin add --replicate', in order.
+	PinReplicas []string `json:",omitempty"`
+	// AllowPin are the peer IDs of the nodes allowed to ask this node to pin
+	// DAGs with 'ipfs pin add --replicate'.
+	AllowPin []string `json:",omitempty"`
+}
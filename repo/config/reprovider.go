@@ -3,4 +3,5 @@ package config
 type Reprovider struct {
 	Interval string // Time period to reprovide locally stored objects to the network
 	Strategy string // Which keys to announce
+	Schedule string // How to schedule the announcements within an interval
 }
@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+// DefaultShutdownGracePeriod is how long in-flight requests are given to
+// complete when the daemon shuts down, when Shutdown.GracePeriod isn't set.
+const DefaultShutdownGracePeriod = 30 * time.Second
+
+// Shutdown configures how the daemon shuts down.
+type Shutdown struct {
+	// GracePeriod is how long API and gateway requests in flight are given
+	// to complete once the daemon is asked to stop, e.g. "1m".
+	GracePeriod string `json:",omitempty"`
+}
@@ -7,7 +7,9 @@ type SwarmConfig struct {
 	DisableRelay            bool
 	EnableRelayHop          bool
 
-	ConnMgr ConnMgr
+	ConnMgr   ConnMgr
+	Peerstore Peerstore
+	Bandwidth Bandwidth
 }
 
 // ConnMgr defines configuration options for the libp2p connection manager
@@ -17,3 +19,28 @@ type ConnMgr struct {
 	HighWater   int
 	GracePeriod string
 }
+
+// Peerstore defines how the addresses of known peers are saved across
+// restarts
+type Peerstore struct {
+	DisablePersistence bool
+	SaveInterval       string
+	AddrTTL            string
+}
+
+// Bandwidth defines the rate limits of the traffic of the node, in bytes per
+// second, e.g. "1MB". Empty rates are unlimited
+type Bandwidth struct {
+	RateIn  string
+	RateOut string
+
+	// Transports limits the traffic over each transport, named after the
+	// multiaddr protocol, e.g. "tcp" or "ws", within the global limits
+	Transports map[string]BandwidthLimit `json:",omitempty"`
+}
+
+// BandwidthLimit defines the rate limits of the traffic over a transport
+type BandwidthLimit struct {
+	RateIn  string
+	RateOut string
+}
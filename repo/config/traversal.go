@@ -0,0 +1,15 @@
+package config
+
+// Traversal configures the walks of large DAGs, like the ones of
+// 'ipfs refs -r --unique', 'ipfs pin add' and garbage collection.
+type Traversal struct {
+	// DiskBackedSets keeps the sets of the blocks visited by a walk in the
+	// datastore, behind a bloom filter, rather than in memory.
+	DiskBackedSets bool `json:",omitempty"`
+	// BloomFilterSize is the size in bytes up to which the bloom filter of
+	// each disk backed set grows. Default: 64MiB.
+	BloomFilterSize int `json:",omitempty"`
+	// Concurrency is the number of blocks fetched at a time by the walks.
+	// Default: 8.
+	Concurrency int `json:",omitempty"`
+}
@@ -0,0 +1,14 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/ipfs/go-ipfs/thirdparty/jsonconf"
+)
+
+// Validate strictly checks a serialized config against the Config structure.
+// Unknown keys and values of the wrong type are reported with the line and
+// column they were found at, see jsonconf.Errors.
+func Validate(data []byte) error {
+	return jsonconf.Check(data, reflect.TypeOf(Config{}))
+}
@@ -0,0 +1,32 @@
+package config
+
+// Webhooks configures the HTTP requests the daemon sends when events happen,
+// to notify other tools, and the thresholds of the disk.low and peers.low
+// events.
+type Webhooks struct {
+	Hooks []Webhook `json:",omitempty"`
+
+	// LowDiskSpace is the free space of the filesystem of the repo, like
+	// "1GB", below which the disk.low event is emitted. Unset disables it.
+	LowDiskSpace string `json:",omitempty"`
+	// LowPeers is the number of connected peers below which the peers.low
+	// event is emitted. Zero disables it.
+	LowPeers int `json:",omitempty"`
+	// CheckInterval is how often the free space and the peers are checked.
+	// Default: 1m.
+	CheckInterval string `json:",omitempty"`
+}
+
+// Webhook is a URL the events are posted to.
+type Webhook struct {
+	URL string
+	// Events are the types of the events posted, all of them when empty.
+	Events []string `json:",omitempty"`
+	// Secret is the key of the HMAC-SHA256 signature of the requests, in
+	// the X-Ipfs-Webhook-Signature header. The requests aren't signed when
+	// it's empty.
+	Secret string `json:",omitempty"`
+	// Retries is the number of times a failed request is retried, none
+	// when negative. Default: 3.
+	Retries int `json:",omitempty"`
+}
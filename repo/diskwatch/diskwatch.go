@@ -0,0 +1,289 @@
+// Package diskwatch keeps a node from filling the filesystem of its repo,
+// which could leave the datastore corrupted. It checks the free space of the
+// filesystem periodically: below the thresholds, it logs warnings, runs the
+// garbage collection and refuses new blocks with a *LowSpaceError.
+//
+// Between checks, the free space is estimated from the size of the blocks
+// written, so a large add is stopped before the next check.
+package diskwatch
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	goprocess "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
+	sysi "gx/ipfs/QmZRjKbHa6DenStpQJFiaPcEwkZqrx7TH6xTf342LDU3qM/go-sysinfo"
+	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
+)
+
+var log = logging.Logger("diskwatch")
+
+// DefaultInterval is how often the free space is checked by default.
+const DefaultInterval = 10 * time.Second
+
+// The garbage collection is run by Run when the free space gets below the GC
+// threshold or the minimum. While it stays there, it's run again after
+// minGCBackoff, then less and less often, up to every maxGCBackoff.
+var (
+	minGCBackoff = time.Minute
+	maxGCBackoff = time.Hour
+)
+
+// Level tells how low the free space is.
+type Level int32
+
+const (
+	// OK is above all the thresholds.
+	OK Level = iota
+	// Warn is below the warning threshold.
+	Warn
+	// Collect is below the garbage collection threshold.
+	Collect
+	// Full is below the minimum free space, where new blocks are refused.
+	Full
+)
+
+func (l Level) String() string {
+	switch l {
+	case OK:
+		return "ok"
+	case Warn:
+		return "warn"
+	case Collect:
+		return "gc"
+	case Full:
+		return "full"
+	default:
+		return fmt.Sprintf("level(%d)", int32(l))
+	}
+}
+
+// Thresholds are the free space in bytes below which each level starts, zero
+// for the ones disabled.
+type Thresholds struct {
+	Warn uint64
+	GC   uint64
+	Min  uint64
+}
+
+// LowSpaceError is returned when new blocks are refused.
+type LowSpaceError struct {
+	Free uint64
+	Min  uint64
+}
+
+func (e *LowSpaceError) Error() string {
+	return fmt.Sprintf("not enough free disk space for the repo: %s left, the minimum is %s",
+		humanize.Bytes(e.Free), humanize.Bytes(e.Min))
+}
+
+// Watchdog watches the free space of the filesystem of a repo. A nil
+// *Watchdog accepts all the writes.
+type Watchdog struct {
+	path string
+	th   Thresholds
+
+	// Interval is how often Run checks the free space.
+	Interval time.Duration
+	// GC runs the garbage collection, for Run, if set.
+	GC func(ctx context.Context) error
+
+	usage func(path string) (uint64, error)
+
+	free       int64 // estimated, updated atomically
+	level      int32 // the level of the last check, updated atomically
+	collecting int32 // set while GC runs
+}
+
+// New returns a Watchdog of the filesystem of path.
+func New(path string, th Thresholds) *Watchdog {
+	return &Watchdog{
+		path:     path,
+		th:       th,
+		Interval: DefaultInterval,
+		usage:    diskFree,
+		free:     -1,
+	}
+}
+
+func diskFree(path string) (uint64, error) {
+	du, err := sysi.DiskUsage(path)
+	if err != nil {
+		return 0, err
+	}
+	return du.Free, nil
+}
+
+// Check returns a *LowSpaceError when the free space is below the minimum.
+func (w *Watchdog) Check() error {
+	if w == nil || w.th.Min == 0 {
+		return nil
+	}
+	free := atomic.LoadInt64(&w.free)
+	if free < 0 {
+		// not checked yet
+		return nil
+	}
+	if uint64(free) < w.th.Min {
+		return &LowSpaceError{Free: uint64(free), Min: w.th.Min}
+	}
+	return nil
+}
+
+// Wrote records that n bytes were written to the repo.
+func (w *Watchdog) Wrote(n int) {
+	if w == nil {
+		return
+	}
+	for {
+		free := atomic.LoadInt64(&w.free)
+		if free < 0 {
+			return
+		}
+		left := free - int64(n)
+		if left < 0 {
+			left = 0
+		}
+		if atomic.CompareAndSwapInt64(&w.free, free, left) {
+			return
+		}
+	}
+}
+
+// Free returns the estimated free space, and whether it was checked yet.
+func (w *Watchdog) Free() (uint64, bool) {
+	free := atomic.LoadInt64(&w.free)
+	if free < 0 {
+		return 0, false
+	}
+	return uint64(free), true
+}
+
+// Level returns the level of the free space at the last check.
+func (w *Watchdog) Level() Level {
+	return Level(atomic.LoadInt32(&w.level))
+}
+
+func (w *Watchdog) levelOf(free uint64) Level {
+	switch {
+	case free < w.th.Min:
+		return Full
+	case free < w.th.GC:
+		return Collect
+	case free < w.th.Warn:
+		return Warn
+	default:
+		return OK
+	}
+}
+
+// Update checks the free space, and logs when its level changed.
+func (w *Watchdog) Update() error {
+	free, err := w.usage(w.path)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt64(&w.free, int64(free))
+
+	level := w.levelOf(free)
+	prev := Level(atomic.SwapInt32(&w.level, int32(level)))
+	if level == prev {
+		return nil
+	}
+	switch level {
+	case Full:
+		log.Errorf("only %s of free disk space left for the repo, new blocks are refused", humanize.Bytes(free))
+	case Collect:
+		log.Warningf("only %s of free disk space left for the repo, below the GC threshold", humanize.Bytes(free))
+	case Warn:
+		log.Warningf("only %s of free disk space left for the repo", humanize.Bytes(free))
+	case OK:
+		log.Infof("%s of free disk space left for the repo", humanize.Bytes(free))
+	}
+	return nil
+}
+
+// Run checks the free space until proc closes, and runs the garbage
+// collection when it gets below the GC threshold or the minimum, backing off
+// while it stays there.
+func (w *Watchdog) Run(proc goprocess.Process) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-proc.Closing()
+		cancel()
+	}()
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	var gc gcSchedule
+	for {
+		if err := w.Update(); err != nil {
+			log.Warning("failed to check the free disk space: ", err)
+		} else if level := w.Level(); w.GC != nil && gc.due(level, time.Now()) {
+			if w.collect(ctx) {
+				gc.ran(time.Now())
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-proc.Closing():
+			return
+		}
+	}
+}
+
+// gcSchedule tells when Run runs the garbage collection: as soon as the
+// level gets to Collect or Full, then with a backoff while it stays there.
+type gcSchedule struct {
+	level   Level
+	next    time.Time
+	backoff time.Duration
+}
+
+// due returns whether the garbage collection is due, at level.
+func (s *gcSchedule) due(level Level, now time.Time) bool {
+	if level != s.level {
+		s.level = level
+		s.next = time.Time{}
+		s.backoff = 0
+	}
+	return level >= Collect && !now.Before(s.next)
+}
+
+// ran records that the garbage collection was started at now.
+func (s *gcSchedule) ran(now time.Time) {
+	switch {
+	case s.backoff == 0:
+		s.backoff = minGCBackoff
+	case s.backoff < maxGCBackoff:
+		s.backoff *= 2
+		if s.backoff > maxGCBackoff {
+			s.backoff = maxGCBackoff
+		}
+	}
+	s.next = now.Add(s.backoff)
+}
+
+// collect starts the garbage collection, unless it's already running, and
+// returns whether it did.
+func (w *Watchdog) collect(ctx context.Context) bool {
+	if !atomic.CompareAndSwapInt32(&w.collecting, 0, 1) {
+		return false
+	}
+	go func() {
+		defer atomic.StoreInt32(&w.collecting, 0)
+		if err := w.GC(ctx); err != nil {
+			log.Error("garbage collection failed: ", err)
+			return
+		}
+		if err := w.Update(); err != nil {
+			log.Warning("failed to check the free disk space: ", err)
+		}
+	}()
+	return true
+}
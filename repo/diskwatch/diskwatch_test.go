@@ -0,0 +1,94 @@
+package diskwatch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	free := uint64(100)
+	w := New("", Thresholds{Warn: 80, GC: 50, Min: 20})
+	w.usage = func(string) (uint64, error) { return free, nil }
+
+	// nothing is refused before the first check
+	if err := w.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		free  uint64
+		level Level
+	}{
+		{100, OK},
+		{70, Warn},
+		{30, Collect},
+		{10, Full},
+		{90, OK},
+	} {
+		free = test.free
+		if err := w.Update(); err != nil {
+			t.Fatal(err)
+		}
+		if l := w.Level(); l != test.level {
+			t.Fatalf("%d bytes free: expected level %s, got %s", test.free, test.level, l)
+		}
+		err := w.Check()
+		if test.level == Full {
+			if e, ok := err.(*LowSpaceError); !ok || e.Free != test.free || e.Min != 20 {
+				t.Fatalf("%d bytes free: expected a LowSpaceError, got %v", test.free, err)
+			}
+		} else if err != nil {
+			t.Fatalf("%d bytes free: %s", test.free, err)
+		}
+	}
+
+	// the writes are refused once they used the space left
+	w.Wrote(50)
+	if err := w.Check(); err != nil {
+		t.Fatal(err)
+	}
+	w.Wrote(30)
+	if _, ok := w.Check().(*LowSpaceError); !ok {
+		t.Fatal("expected a LowSpaceError")
+	}
+	if f, _ := w.Free(); f != 10 {
+		t.Fatalf("expected 10 bytes free, got %d", f)
+	}
+
+	var nilw *Watchdog
+	nilw.Wrote(10)
+	if err := nilw.Check(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGCSchedule(t *testing.T) {
+	var s gcSchedule
+	now := time.Now()
+
+	if s.due(OK, now) || s.due(Warn, now) {
+		t.Fatal("expected no garbage collection above the GC threshold")
+	}
+	if !s.due(Collect, now) {
+		t.Fatal("expected a garbage collection below the GC threshold")
+	}
+	s.ran(now)
+
+	// it's run again less and less often while the level stays the same
+	if s.due(Collect, now.Add(DefaultInterval)) {
+		t.Fatal("expected the garbage collection not to run again at the next check")
+	}
+	now = now.Add(minGCBackoff)
+	if !s.due(Collect, now) {
+		t.Fatal("expected the garbage collection to run again after the backoff")
+	}
+	s.ran(now)
+	if s.due(Collect, now.Add(minGCBackoff)) {
+		t.Fatal("expected the backoff to grow")
+	}
+
+	// and at once when the level changes
+	if !s.due(Full, now.Add(DefaultInterval)) {
+		t.Fatal("expected a garbage collection below the minimum")
+	}
+}
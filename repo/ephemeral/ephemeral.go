@@ -0,0 +1,126 @@
+// Package ephemeral keeps the DAGs added with 'ipfs add --ephemeral' from
+// garbage collection until they expire, without pinning them.
+//
+// The root of each DAG is recorded under /local/ephemeral/<cid> in the
+// datastore, with its expiry time. The garbage collector keeps the DAGs of the
+// roots not expired yet, as it does with the MFS root, and the expired records
+// are dropped when the roots are listed.
+package ephemeral
+
+import (
+	"sync"
+	"time"
+
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dsq "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/query"
+)
+
+var log = logging.Logger("ephemeral")
+
+var keyPrefix = ds.NewKey("/local/ephemeral")
+
+// Store records the ephemeral DAGs and when they expire.
+type Store struct {
+	ds ds.Datastore
+
+	// mu makes reading and updating an expiry time atomic
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewStore returns a Store saving its records to d.
+func NewStore(d ds.Datastore) *Store {
+	return &Store{ds: d, now: time.Now}
+}
+
+func rootKey(c *cid.Cid) ds.Key {
+	return keyPrefix.ChildString(c.String())
+}
+
+// Keep keeps the DAG of c from garbage collection for ttl. A DAG kept already
+// expires at the latest of both times.
+func (s *Store) Keep(c *cid.Cid, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry := s.now().Add(ttl)
+	k := rootKey(c)
+	v, err := s.ds.Get(k)
+	switch err {
+	case nil:
+		if old, err := parseExpiry(v); err == nil && old.After(expiry) {
+			return nil
+		}
+	case ds.ErrNotFound:
+	default:
+		return err
+	}
+
+	b, err := expiry.UTC().MarshalText()
+	if err != nil {
+		return err
+	}
+	return s.ds.Put(k, b)
+}
+
+// Forget stops keeping the DAG of c, before it expires.
+func (s *Store) Forget(c *cid.Cid) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.ds.Delete(rootKey(c))
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// Roots returns the roots of the DAGs not expired yet, and forgets the
+// others.
+func (s *Store) Roots() ([]*cid.Cid, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, err := s.ds.Query(dsq.Query{Prefix: keyPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	var roots []*cid.Cid
+	for _, e := range entries {
+		k := ds.RawKey(e.Key)
+		c, err := cid.Decode(k.BaseNamespace())
+		if err != nil {
+			log.Debugf("ignoring invalid ephemeral record %s: %s", k, err)
+			continue
+		}
+
+		expiry, err := parseExpiry(e.Value)
+		if err != nil {
+			log.Debugf("ignoring invalid ephemeral record %s: %s", k, err)
+			continue
+		}
+		if !now.Before(expiry) {
+			if err := s.ds.Delete(k); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		roots = append(roots, c)
+	}
+	return roots, nil
+}
+
+func parseExpiry(v interface{}) (time.Time, error) {
+	b, _ := v.([]byte)
+	var t time.Time
+	err := t.UnmarshalText(b)
+	return t, err
+}
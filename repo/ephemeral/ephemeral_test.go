@@ -0,0 +1,64 @@
+package ephemeral
+
+import (
+	"testing"
+	"time"
+
+	u "gx/ipfs/QmPdKqUcHGFdeSpvjVoaTRPPstGif9GBZb5Q56RVw9o69A/go-ipfs-util"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dssync "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
+)
+
+func testCid(s string) *cid.Cid {
+	return cid.NewCidV0(u.Hash([]byte(s)))
+}
+
+func TestRootsExpire(t *testing.T) {
+	now := time.Date(2018, 6, 21, 12, 0, 0, 0, time.UTC)
+	s := NewStore(dssync.MutexWrap(ds.NewMapDatastore()))
+	s.now = func() time.Time { return now }
+
+	a, b := testCid("a"), testCid("b")
+	if err := s.Keep(a, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Keep(b, 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// a shorter ttl doesn't shorten the time b is kept
+	if err := s.Keep(b, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := s.Roots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 2 {
+		t.Fatalf("expected 2 roots, got %v", roots)
+	}
+
+	now = now.Add(90 * time.Minute)
+	roots, err = s.Roots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || !roots[0].Equals(b) {
+		t.Fatalf("expected only %s to be kept, got %v", b, roots)
+	}
+	if has, _ := s.ds.Has(rootKey(a)); has {
+		t.Fatal("expected the expired record to be forgotten")
+	}
+
+	if err := s.Forget(b); err != nil {
+		t.Fatal(err)
+	}
+	roots, err = s.Roots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 0 {
+		t.Fatalf("expected no roots, got %v", roots)
+	}
+}
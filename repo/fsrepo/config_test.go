@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	config "github.com/ipfs/go-ipfs/repo/config"
+
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
 )
 
 // note: to test sorting of the mountpoints in the disk spec they are
@@ -217,3 +219,104 @@ func TestMeasureConfig(t *testing.T) {
 		t.Errorf("expected '*measure.measure' got '%s'", typ)
 	}
 }
+
+func TestFlatfsSyncPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipfs-datastore-config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // clean up
+
+	spec := make(map[string]interface{})
+	err = json.Unmarshal(measureConfig, &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dsc, err := AnyDatastoreConfig(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetSyncPolicy(dsc, "sometimes"); err == nil {
+		t.Fatal("expected an unknown sync policy to be rejected")
+	}
+
+	for policy, expected := range map[string]string{
+		config.SyncAlways:        "*flatfs.Datastore",
+		config.SyncOnBatchCommit: "*fsrepo.batchSyncDatastore",
+		config.SyncNever:         "*flatfs.Datastore",
+	} {
+		if err := SetSyncPolicy(dsc, policy); err != nil {
+			t.Fatal(err)
+		}
+		fc := dsc.(*measureDatastoreConfig).child.(*flatfsDatastoreConfig)
+		if fc.syncField != (policy == config.SyncAlways) {
+			t.Errorf("%s: flatfs sync is %t", policy, fc.syncField)
+		}
+
+		ds, err := fc.Create(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ := reflect.TypeOf(ds).String(); typ != expected {
+			t.Errorf("%s: expected '%s' got '%s'", policy, expected, typ)
+		}
+		ds.Close()
+	}
+}
+
+func TestFlatfsBatchSync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipfs-datastore-config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // clean up
+
+	spec := make(map[string]interface{})
+	err = json.Unmarshal(measureConfig, &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsc, err := AnyDatastoreConfig(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetSyncPolicy(dsc, config.SyncOnBatchCommit); err != nil {
+		t.Fatal(err)
+	}
+	fc := dsc.(*measureDatastoreConfig).child.(*flatfsDatastoreConfig)
+	d, err := fc.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	b, err := d.(ds.Batching).Batch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := ds.NewKey("CIQSYNCEDBLOCK")
+	if err := b.Put(key, []byte("synced")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := d.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v.([]byte)) != "synced" {
+		t.Fatalf("read %q back", v)
+	}
+
+	// the batches are written by the same instance, accounting for them
+	du, err := d.(ds.PersistentDatastore).DiskUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if du == 0 {
+		t.Fatal("the data written by the batch isn't accounted for")
+	}
+}
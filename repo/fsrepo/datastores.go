@@ -9,6 +9,7 @@ import (
 	"sort"
 
 	repo "github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
 
 	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	flatfs "gx/ipfs/QmWHKYGzexrw2H135CR2fKtFzMphVC3AcNBzUSWnnEAERM/go-ds-flatfs"
@@ -82,6 +83,35 @@ func AnyDatastoreConfig(params map[string]interface{}) (DatastoreConfig, error)
 	return fun(params)
 }
 
+// SetSyncPolicy sets when the datastores of dsc sync their writes to disk,
+// overriding their settings, from one of the config.Sync constants. It
+// applies to the flatfs datastores, the others keep their settings.
+func SetSyncPolicy(dsc DatastoreConfig, policy string) error {
+	switch policy {
+	case config.SyncAlways, config.SyncOnBatchCommit, config.SyncNever:
+	default:
+		return fmt.Errorf("unknown datastore sync policy %q", policy)
+	}
+	setSyncPolicy(dsc, policy)
+	return nil
+}
+
+func setSyncPolicy(dsc DatastoreConfig, policy string) {
+	switch c := dsc.(type) {
+	case *mountDatastoreConfig:
+		for _, m := range c.mounts {
+			setSyncPolicy(m.ds, policy)
+		}
+	case *logDatastoreConfig:
+		setSyncPolicy(c.child, policy)
+	case *measureDatastoreConfig:
+		setSyncPolicy(c.child, policy)
+	case *flatfsDatastoreConfig:
+		c.syncField = policy == config.SyncAlways
+		c.syncBatches = policy == config.SyncOnBatchCommit
+	}
+}
+
 type mountDatastoreConfig struct {
 	mounts []premount
 }
@@ -159,6 +189,8 @@ type flatfsDatastoreConfig struct {
 	path      string
 	shardFun  *flatfs.ShardIdV1
 	syncField bool
+	// syncBatches syncs the batches of writes, but not the single ones
+	syncBatches bool
 }
 
 // FlatfsDatastoreConfig returns a flatfs DatastoreConfig from a spec
@@ -202,7 +234,102 @@ func (c *flatfsDatastoreConfig) Create(path string) (repo.Datastore, error) {
 		p = filepath.Join(path, p)
 	}
 
-	return flatfs.CreateOrOpen(p, c.shardFun, c.syncField)
+	d, err := flatfs.CreateOrOpen(p, c.shardFun, c.syncField)
+	if err != nil || !c.syncBatches {
+		return d, err
+	}
+	return &batchSyncDatastore{Datastore: d, path: p, getDir: c.shardFun.Func()}, nil
+}
+
+// batchSyncDatastore is a flatfs datastore, not syncing its writes, whose
+// batches sync the files they wrote, and their directories, once committed.
+type batchSyncDatastore struct {
+	*flatfs.Datastore
+	path   string
+	getDir flatfs.ShardFunc
+}
+
+func (d *batchSyncDatastore) Batch() (ds.Batch, error) {
+	b, err := d.Datastore.Batch()
+	if err != nil {
+		return nil, err
+	}
+	return &syncBatch{Batch: b, d: d}, nil
+}
+
+// syncBatch syncs the files written by a flatfs batch when it's committed,
+// and the directories it wrote or removed files in.
+type syncBatch struct {
+	ds.Batch
+	d *batchSyncDatastore
+	// keys holds the keys the batch writes, true, or deletes, false
+	keys map[ds.Key]bool
+}
+
+func (b *syncBatch) Put(key ds.Key, value interface{}) error {
+	if err := b.Batch.Put(key, value); err != nil {
+		return err
+	}
+	b.record(key, true)
+	return nil
+}
+
+func (b *syncBatch) Delete(key ds.Key) error {
+	if err := b.Batch.Delete(key); err != nil {
+		return err
+	}
+	b.record(key, false)
+	return nil
+}
+
+func (b *syncBatch) record(key ds.Key, put bool) {
+	if b.keys == nil {
+		b.keys = make(map[ds.Key]bool)
+	}
+	b.keys[key] = put
+}
+
+func (b *syncBatch) Commit() error {
+	if err := b.Batch.Commit(); err != nil {
+		return err
+	}
+
+	dirs := make(map[string]struct{})
+	for k, put := range b.keys {
+		// the layout of flatfs: <path>/<shard dir>/<key>.data
+		name := k.String()[1:]
+		dir := filepath.Join(b.d.path, b.d.getDir(name))
+		if put {
+			if err := syncPath(filepath.Join(dir, name+".data")); err != nil {
+				return err
+			}
+		}
+		dirs[dir] = struct{}{}
+	}
+	for dir := range dirs {
+		// the directory of a key deleted which wasn't there may not exist
+		if err := syncPath(dir); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	// the new shard directories
+	if len(dirs) > 0 {
+		if err := syncPath(b.d.path); err != nil {
+			return err
+		}
+	}
+	b.keys = nil
+	return nil
+}
+
+// syncPath syncs the file or directory at p to disk.
+func syncPath(p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
 }
 
 type leveldsDatastoreConfig struct {
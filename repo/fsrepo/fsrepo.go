@@ -19,6 +19,7 @@ import (
 	mfsr "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
 	serialize "github.com/ipfs/go-ipfs/repo/fsrepo/serialize"
 	dir "github.com/ipfs/go-ipfs/thirdparty/dir"
+	jsonconf "github.com/ipfs/go-ipfs/thirdparty/jsonconf"
 
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/mitchellh/go-homedir"
 
@@ -132,16 +133,21 @@ func open(repoPath string) (repo.Repo, error) {
 
 	r.lockfile, err = lockfile.Lock(r.path, LockFile)
 	if err != nil {
-		return nil, err
+		return nil, lockError(r.path, err)
 	}
 	keepLocked := false
 	defer func() {
 		// unlock on error, leave it locked on success
 		if !keepLocked {
+			removeLockOwner(r.path)
 			r.lockfile.Close()
 		}
 	}()
 
+	if err := writeLockOwner(r.path); err != nil {
+		log.Warningf("failed to record the owner of the repo lock: %s", err)
+	}
+
 	// Check version, and error out if not matching
 	ver, err := mfsr.RepoPath(r.path).Version()
 	if err != nil {
@@ -408,6 +414,11 @@ func (r *FSRepo) openDatastore() error {
 	if err != nil {
 		return err
 	}
+	if policy := r.config.Datastore.Sync; policy != "" {
+		if err := SetSyncPolicy(dsc, policy); err != nil {
+			return err
+		}
+	}
 	spec := dsc.DiskSpec()
 
 	oldSpec, err := r.readSpec()
@@ -471,6 +482,9 @@ func (r *FSRepo) Close() error {
 	// logging.Configure(logging.Output(os.Stderr))
 
 	r.closed = true
+	if err := removeLockOwner(r.path); err != nil {
+		log.Warningf("failed to remove the owner of the repo lock: %s", err)
+	}
 	return r.lockfile.Close()
 }
 
@@ -491,6 +505,39 @@ func (r *FSRepo) Config() (*config.Config, error) {
 	return r.config, nil
 }
 
+// ReloadConfig re-reads the config file to pick up the changes made to it
+// since the repo was opened. The current config is kept when the file holds
+// values of the wrong type or can't be read.
+func (r *FSRepo) ReloadConfig() error {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	if r.closed {
+		return errors.New("cannot reload config, repo not open")
+	}
+
+	configFilename, err := config.Filename(r.path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(configFilename)
+	if err != nil {
+		return err
+	}
+	if errs, ok := config.Validate(data).(jsonconf.Errors); ok {
+		if m := errs.Mismatches(); len(m) > 0 {
+			return fmt.Errorf("invalid config:\n%s", m)
+		}
+	}
+
+	conf, err := serialize.Load(configFilename)
+	if err != nil {
+		return err
+	}
+	r.config = conf
+	return nil
+}
+
 func (r *FSRepo) FileManager() *filestore.FileManager {
 	return r.filemgr
 }
@@ -0,0 +1,160 @@
+package fsrepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	lockfile "gx/ipfs/QmQ7rzxiZbjc9tnBag9g89cHAwditrS9FUicRYR55dUzd1/go-fs-lock"
+)
+
+// LockOwnerFile is the filename of the description of the process holding
+// the repo lock, relative to config dir.
+const LockOwnerFile = "repo.lock.owner"
+
+// LockOwner describes the process holding the repo lock.
+type LockOwner struct {
+	PID      int
+	Hostname string
+	Since    time.Time
+}
+
+func (o *LockOwner) String() string {
+	return fmt.Sprintf("process %d on %s, since %s", o.PID, o.Hostname, o.Since.Format(time.RFC3339))
+}
+
+// LockStatus is the state of the repo lock.
+type LockStatus struct {
+	// Locked is whether a process holds the lock.
+	Locked bool
+	// Owner describes the process which last held the lock, if it's known.
+	Owner *LockOwner `json:",omitempty"`
+	// Stale is whether the lock was left by a process of this host which
+	// isn't running anymore. A stale lock can be stolen.
+	Stale bool
+}
+
+// writeLockOwner records this process as the owner of the lock of the repo.
+func writeLockOwner(repoPath string) error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(&LockOwner{
+		PID:      os.Getpid(),
+		Hostname: hostname,
+		Since:    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(repoPath, LockOwnerFile), b, 0644)
+}
+
+func removeLockOwner(repoPath string) error {
+	err := os.Remove(filepath.Join(repoPath, LockOwnerFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// ReadLockOwner returns the owner of the lock of the repo at repoPath, or nil
+// if it isn't known: the repo isn't locked or was locked by an older version.
+func ReadLockOwner(repoPath string) (*LockOwner, error) {
+	b, err := ioutil.ReadFile(filepath.Join(filepath.Clean(repoPath), LockOwnerFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var o LockOwner
+	if err := json.Unmarshal(b, &o); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", LockOwnerFile, err)
+	}
+	return &o, nil
+}
+
+// GetLockStatus returns the state of the lock of the repo at repoPath.
+func GetLockStatus(repoPath string) (*LockStatus, error) {
+	repoPath = filepath.Clean(repoPath)
+	locked, err := lockfile.Locked(repoPath, LockFile)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := ReadLockOwner(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &LockStatus{Locked: locked, Owner: owner}
+	if owner != nil {
+		st.Stale = stale(owner)
+	}
+	return st, nil
+}
+
+// stale returns whether the lock of owner was left by a process of this host
+// which isn't running anymore.
+func stale(owner *LockOwner) bool {
+	hostname, err := os.Hostname()
+	if err != nil || hostname != owner.Hostname {
+		return false
+	}
+	return owner.PID != os.Getpid() && !processRunning(owner.PID)
+}
+
+// StealLock removes the lock of the repo at repoPath, left by a process which
+// crashed, so that the repo can be opened again. It only removes locks known
+// to be stale: those of processes of this host which aren't running anymore,
+// and which no process holds anymore. A lock still held, by a child of its
+// owner which inherited it or by a process which took it over, is never
+// stolen.
+func StealLock(repoPath string) error {
+	repoPath = filepath.Clean(repoPath)
+
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	owner, err := ReadLockOwner(repoPath)
+	if err != nil {
+		return err
+	}
+	if owner == nil {
+		return fmt.Errorf("the owner of the lock of %s is unknown, not stealing it", repoPath)
+	}
+	if !stale(owner) {
+		return fmt.Errorf("the lock of %s is held by %s, which may be running, not stealing it", repoPath, owner)
+	}
+
+	l, err := lockfile.Lock(repoPath, LockFile)
+	if err != nil {
+		return fmt.Errorf("the lock of %s is still held, by a child of process %d or by another process, not stealing it", repoPath, owner.PID)
+	}
+	defer l.Close()
+
+	// the lock was released with its owner, only its files are left
+	log.Warningf("stealing the lock of %s from %s, which isn't running", repoPath, owner)
+	if err := os.Remove(filepath.Join(repoPath, LockFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return removeLockOwner(repoPath)
+}
+
+// lockError adds the owner of the lock of the repo at repoPath, if it's known,
+// to err, the error of locking it.
+func lockError(repoPath string, err error) error {
+	owner, _ := ReadLockOwner(repoPath)
+	if owner == nil {
+		return err
+	}
+	hint := "see 'ipfs repo lock status'"
+	if stale(owner) {
+		hint = "it isn't running anymore, use 'ipfs daemon --steal-lock' to take the lock over"
+	}
+	return fmt.Errorf("%s: the lock is held by %s (%s)", err, owner, hint)
+}
@@ -0,0 +1,150 @@
+package fsrepo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-ipfs/repo/config"
+)
+
+func TestLockOwner(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("lock", t)
+	defer Remove(path)
+	if err := Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner, err := ReadLockOwner(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner == nil || owner.PID != os.Getpid() {
+		t.Fatalf("expected this process to own the lock, got %v", owner)
+	}
+
+	st, err := GetLockStatus(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Stale {
+		t.Fatal("the lock of a running process isn't stale")
+	}
+	if err := StealLock(path); err == nil {
+		t.Fatal("stole the lock of a running process")
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	owner, err = ReadLockOwner(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner != nil {
+		t.Fatalf("expected no owner once the repo is closed, got %s", owner)
+	}
+}
+
+func TestStealStaleLock(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("steal", t)
+	defer Remove(path)
+	if err := Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := StealLock(path); err == nil {
+		t.Fatal("stole a lock with an unknown owner")
+	}
+
+	// the PID of a process which exited
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(&LockOwner{
+		PID:      cmd.Process.Pid,
+		Hostname: hostname,
+		Since:    time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, LockOwnerFile), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, LockFile), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := StealLock(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(path, LockFile)); !os.IsNotExist(err) {
+		t.Fatal("expected the lock file to be removed")
+	}
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+}
+
+func TestNoStealHeldLock(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("stealheld", t)
+	defer Remove(path)
+	if err := Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// the recorded owner exited, but the lock is still held, like by a
+	// child which inherited it
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(&LockOwner{
+		PID:      cmd.Process.Pid,
+		Hostname: hostname,
+		Since:    time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, LockOwnerFile), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := StealLock(path); err == nil {
+		t.Fatal("stole a lock still held")
+	}
+	if _, err := os.Stat(filepath.Join(path, LockFile)); err != nil {
+		t.Fatal("expected the lock file to be kept")
+	}
+}
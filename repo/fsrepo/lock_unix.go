@@ -0,0 +1,13 @@
+// +build !windows
+
+package fsrepo
+
+import (
+	"syscall"
+)
+
+// processRunning returns whether the process pid of this host is running.
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,15 @@
+package fsrepo
+
+import (
+	"os"
+)
+
+// processRunning returns whether the process pid of this host is running.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
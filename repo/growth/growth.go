@@ -0,0 +1,335 @@
+// Package growth keeps daily statistics on a repo for capacity planning: the
+// number of blocks added to and removed from it each day, and a daily sample
+// of its pins and size.
+//
+// Each day is saved as a JSON record under /local/stats/growth/<date> in the
+// datastore, where <date> is the UTC date, like 2018-06-21. Days older than
+// MaxDays are removed.
+package growth
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	goprocess "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
+	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dsq "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/query"
+)
+
+var log = logging.Logger("growth")
+
+// DefaultInterval is how often the counters are saved by default.
+const DefaultInterval = time.Hour
+
+// MaxDays is the number of days kept.
+const MaxDays = 366
+
+const dateFormat = "2006-01-02"
+
+var keyPrefix = ds.NewKey("/local/stats/growth")
+
+// Sample is a measure of the state of the repo.
+type Sample struct {
+	RecursivePins uint64
+	DirectPins    uint64
+	RepoSize      uint64
+}
+
+// Day holds the statistics of a day.
+type Day struct {
+	Date          string
+	BlocksAdded   uint64
+	BytesAdded    uint64
+	BlocksRemoved uint64
+	// Sample is the first sample taken that day, if any.
+	Sample *Sample `json:",omitempty"`
+}
+
+func (d *Day) empty() bool {
+	return d.BlocksAdded == 0 && d.BytesAdded == 0 && d.BlocksRemoved == 0
+}
+
+// Tracker counts the blocks added to and removed from a repo, and saves the
+// counts in its datastore.
+type Tracker struct {
+	ds ds.Datastore
+
+	// Interval is how often Run saves the counters and, once a day, takes a
+	// sample.
+	Interval time.Duration
+	// Sampler measures the repo for Run, if set.
+	Sampler func() (Sample, error)
+
+	now func() time.Time
+
+	mu sync.Mutex
+	// pending are the counts which weren't saved yet
+	pending Day
+}
+
+// NewTracker returns a Tracker saving its counts to d.
+func NewTracker(d ds.Datastore) *Tracker {
+	t := &Tracker{
+		ds:       d,
+		Interval: DefaultInterval,
+		now:      time.Now,
+	}
+	t.pending.Date = t.today()
+	return t
+}
+
+func (t *Tracker) today() string {
+	return t.now().UTC().Format(dateFormat)
+}
+
+func dayKey(date string) ds.Key {
+	return keyPrefix.ChildString(date)
+}
+
+// Added counts a block of size bytes added to the repo.
+func (t *Tracker) Added(size int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+	t.pending.BlocksAdded++
+	t.pending.BytesAdded += uint64(size)
+}
+
+// Removed counts a block removed from the repo.
+func (t *Tracker) Removed() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+	t.pending.BlocksRemoved++
+}
+
+// rollover saves the pending counts when the day changed. It must be called
+// with the lock held.
+func (t *Tracker) rollover() {
+	today := t.today()
+	if t.pending.Date == today {
+		return
+	}
+
+	if err := t.flush(); err != nil {
+		log.Error("failed to save the repo growth counters: ", err)
+	}
+	t.pending = Day{Date: today}
+
+	if err := t.prune(); err != nil {
+		log.Error("failed to remove old repo growth counters: ", err)
+	}
+}
+
+// Flush saves the pending counts.
+func (t *Tracker) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover()
+	return t.flush()
+}
+
+// Close saves the pending counts.
+func (t *Tracker) Close() error {
+	return t.Flush()
+}
+
+// flush must be called with the lock held.
+func (t *Tracker) flush() error {
+	if t.pending.empty() {
+		return nil
+	}
+
+	d, err := t.load(t.pending.Date)
+	if err != nil {
+		return err
+	}
+	d.BlocksAdded += t.pending.BlocksAdded
+	d.BytesAdded += t.pending.BytesAdded
+	d.BlocksRemoved += t.pending.BlocksRemoved
+	if err := t.save(d); err != nil {
+		return err
+	}
+
+	t.pending = Day{Date: t.pending.Date}
+	return nil
+}
+
+// load returns the saved day of date, or an empty one if there is none.
+func (t *Tracker) load(date string) (*Day, error) {
+	b, err := t.ds.Get(dayKey(date))
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return &Day{Date: date}, nil
+	default:
+		return nil, err
+	}
+
+	d := new(Day)
+	if data, ok := b.([]byte); ok {
+		if err := json.Unmarshal(data, d); err != nil {
+			log.Debugf("ignoring invalid repo growth record of %s: %s", date, err)
+		}
+	}
+	d.Date = date
+	return d, nil
+}
+
+func (t *Tracker) save(d *Day) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return t.ds.Put(dayKey(d.Date), b)
+}
+
+// prune removes the days older than MaxDays. It must be called with the lock
+// held.
+func (t *Tracker) prune() error {
+	days, err := t.saved()
+	if err != nil {
+		return err
+	}
+
+	oldest := t.now().UTC().AddDate(0, 0, -MaxDays).Format(dateFormat)
+	for _, d := range days {
+		if d.Date >= oldest {
+			break
+		}
+		if err := t.ds.Delete(dayKey(d.Date)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saved returns the saved days, oldest first.
+func (t *Tracker) saved() ([]*Day, error) {
+	res, err := t.ds.Query(dsq.Query{Prefix: keyPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var days []*Day
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+
+		date := ds.RawKey(e.Key).BaseNamespace()
+		d := &Day{Date: date}
+		if b, ok := e.Value.([]byte); ok {
+			if err := json.Unmarshal(b, d); err != nil {
+				log.Debugf("ignoring invalid repo growth record of %s: %s", date, err)
+				continue
+			}
+		}
+		d.Date = date
+		days = append(days, d)
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Date < days[j].Date
+	})
+	return days, nil
+}
+
+// Days returns the statistics of the last n days with any, oldest first,
+// including the counts which weren't saved yet.
+func (t *Tracker) Days(n int) ([]*Day, error) {
+	if err := t.Flush(); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	days, err := t.saved()
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(days) > n {
+		days = days[len(days)-n:]
+	}
+	return days, nil
+}
+
+// Record saves s as the sample of the day, unless the day already has one.
+func (t *Tracker) Record(s Sample) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	d, err := t.load(t.today())
+	if err != nil {
+		return err
+	}
+	if d.Sample != nil {
+		return nil
+	}
+	d.Sample = &s
+	return t.save(d)
+}
+
+// sampled returns whether the day has a sample.
+func (t *Tracker) sampled() (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	d, err := t.load(t.today())
+	if err != nil {
+		return false, err
+	}
+	return d.Sample != nil, nil
+}
+
+// sample takes the sample of the day with Sampler, if it wasn't taken yet.
+func (t *Tracker) sample() error {
+	if t.Sampler == nil {
+		return nil
+	}
+
+	done, err := t.sampled()
+	if err != nil || done {
+		return err
+	}
+
+	s, err := t.Sampler()
+	if err != nil {
+		return err
+	}
+	return t.Record(s)
+}
+
+// Run takes the sample of the day, and then saves the counters every
+// Interval, taking the sample of each new day, until proc closes. The counters
+// are saved one last time when proc closes.
+func (t *Tracker) Run(proc goprocess.Process) {
+	if err := t.sample(); err != nil {
+		log.Error("failed to sample the repo: ", err)
+	}
+
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				log.Error("failed to save the repo growth counters: ", err)
+			}
+			if err := t.sample(); err != nil {
+				log.Error("failed to sample the repo: ", err)
+			}
+		case <-proc.Closing():
+			if err := t.Flush(); err != nil {
+				log.Error("failed to save the repo growth counters: ", err)
+			}
+			return
+		}
+	}
+}
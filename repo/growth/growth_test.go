@@ -0,0 +1,114 @@
+package growth
+
+import (
+	"testing"
+	"time"
+
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dssync "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
+)
+
+func newTestTracker(now *time.Time) *Tracker {
+	t := NewTracker(dssync.MutexWrap(ds.NewMapDatastore()))
+	t.now = func() time.Time { return *now }
+	t.pending.Date = t.today()
+	return t
+}
+
+func TestCountsPerDay(t *testing.T) {
+	now := time.Date(2018, 6, 21, 23, 0, 0, 0, time.UTC)
+	tr := newTestTracker(&now)
+
+	tr.Added(100)
+	tr.Added(50)
+	tr.Removed()
+	if err := tr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	tr.Added(10)
+
+	now = now.Add(2 * time.Hour)
+	tr.Removed()
+
+	days, err := tr.Days(30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 {
+		t.Fatalf("expected 2 days, got %d", len(days))
+	}
+
+	d := days[0]
+	if d.Date != "2018-06-21" || d.BlocksAdded != 3 || d.BytesAdded != 160 || d.BlocksRemoved != 1 {
+		t.Fatalf("wrong first day: %+v", d)
+	}
+	d = days[1]
+	if d.Date != "2018-06-22" || d.BlocksAdded != 0 || d.BlocksRemoved != 1 {
+		t.Fatalf("wrong second day: %+v", d)
+	}
+
+	days, err = tr.Days(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 || days[0].Date != "2018-06-22" {
+		t.Fatalf("expected the last day only, got %v", days)
+	}
+}
+
+func TestRecordSampleOncePerDay(t *testing.T) {
+	now := time.Date(2018, 6, 21, 12, 0, 0, 0, time.UTC)
+	tr := newTestTracker(&now)
+
+	calls := 0
+	tr.Sampler = func() (Sample, error) {
+		calls++
+		return Sample{RecursivePins: uint64(calls), RepoSize: 1000}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := tr.sample(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected the repo to be sampled once, got %d", calls)
+	}
+
+	now = now.Add(24 * time.Hour)
+	if err := tr.sample(); err != nil {
+		t.Fatal(err)
+	}
+
+	days, err := tr.Days(30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 {
+		t.Fatalf("expected 2 days, got %d", len(days))
+	}
+	for i, d := range days {
+		if d.Sample == nil || d.Sample.RecursivePins != uint64(i+1) {
+			t.Fatalf("wrong sample on day %d: %+v", i, d.Sample)
+		}
+	}
+}
+
+func TestPruneOldDays(t *testing.T) {
+	now := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+	tr := newTestTracker(&now)
+
+	tr.Added(1)
+	now = now.AddDate(0, 0, MaxDays-1)
+	tr.Added(1)
+	now = now.AddDate(0, 0, 2)
+	tr.Added(1)
+
+	days, err := tr.Days(MaxDays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 || days[0].Date != "2018-01-01" {
+		t.Fatalf("expected the first day to be removed, got %v", days)
+	}
+}
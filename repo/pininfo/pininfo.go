@@ -0,0 +1,141 @@
+// Package pininfo records when, and through which interface, the pins of a
+// node were created, for 'ipfs pin ls --long'.
+//
+// The record of each pin is kept under /local/pininfo/<cid> in the datastore,
+// as JSON. Only the first record of a pin is kept until it's unpinned, so
+// pinning again what is pinned already doesn't change when it was created.
+package pininfo
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+)
+
+var keyPrefix = ds.NewKey("/local/pininfo")
+
+// The interfaces pins are created through.
+const (
+	// OriginCLI is the ipfs command, run on the repo or through the API.
+	OriginCLI = "cli"
+	// OriginAPI is any other client of the HTTP API.
+	OriginAPI = "api"
+	// OriginPush is another node, pushing a DAG with 'ipfs dag push'.
+	OriginPush = "push"
+	// OriginReplicate is another node, replicating its pin with 'ipfs pin
+	// add --replicate'.
+	OriginReplicate = "replicate"
+)
+
+type originKey struct{}
+
+type nameKey struct{}
+
+// WithOrigin returns a context of the requests made through origin.
+func WithOrigin(ctx context.Context, origin string) context.Context {
+	return context.WithValue(ctx, originKey{}, origin)
+}
+
+// OriginFromContext returns the interface the request of ctx was made
+// through, OriginCLI by default.
+func OriginFromContext(ctx context.Context) string {
+	if o, ok := ctx.Value(originKey{}).(string); ok {
+		return o
+	}
+	return OriginCLI
+}
+
+// WithName returns a context of the requests creating pins named name.
+func WithName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, nameKey{}, name)
+}
+
+// NameFromContext returns the name of the pins created by the request of ctx,
+// if any.
+func NameFromContext(ctx context.Context) string {
+	n, _ := ctx.Value(nameKey{}).(string)
+	return n
+}
+
+// Info is the record of a pin.
+type Info struct {
+	Created time.Time
+	Origin  string
+	// Token is the name of the API token the pin was created with, if any.
+	Token string `json:",omitempty"`
+	// Name is the name given to the pin when created, if any.
+	Name string `json:",omitempty"`
+}
+
+// Store records the creation of the pins.
+type Store struct {
+	ds ds.Datastore
+
+	// mu makes checking for a record and adding it atomic
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewStore returns a Store saving its records to d.
+func NewStore(d ds.Datastore) *Store {
+	return &Store{ds: d, now: time.Now}
+}
+
+func pinKey(c *cid.Cid) ds.Key {
+	return keyPrefix.ChildString(c.String())
+}
+
+// Record records that c was pinned now, as told by info, unless it has a
+// record already. The creation time of info is ignored.
+func (s *Store) Record(c *cid.Cid, info Info) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := pinKey(c)
+	has, err := s.ds.Has(k)
+	if err != nil || has {
+		return err
+	}
+
+	info.Created = s.now().UTC().Truncate(time.Second)
+	b, err := json.Marshal(&info)
+	if err != nil {
+		return err
+	}
+	return s.ds.Put(k, b)
+}
+
+// Get returns the record of c, or nil if it has none, like the pins created
+// before the records were kept.
+func (s *Store) Get(c *cid.Cid) (*Info, error) {
+	v, err := s.ds.Get(pinKey(c))
+	if err == ds.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b, _ := v.([]byte)
+	info := new(Info)
+	if err := json.Unmarshal(b, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Forget forgets the record of c, once unpinned.
+func (s *Store) Forget(c *cid.Cid) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.ds.Delete(pinKey(c))
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package pininfo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	u "gx/ipfs/QmPdKqUcHGFdeSpvjVoaTRPPstGif9GBZb5Q56RVw9o69A/go-ipfs-util"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dssync "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
+)
+
+func TestRecordKeepsFirst(t *testing.T) {
+	now := time.Date(2018, 6, 21, 12, 0, 0, 0, time.UTC)
+	s := NewStore(dssync.MutexWrap(ds.NewMapDatastore()))
+	s.now = func() time.Time { return now }
+	c := cid.NewCidV0(u.Hash([]byte("a")))
+
+	if err := s.Record(c, Info{Origin: OriginAPI, Token: "tenant", Name: "site"}); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Hour)
+	if err := s.Record(c, Info{Origin: OriginCLI}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := s.Get(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info == nil || !info.Created.Equal(now.Add(-time.Hour)) || info.Origin != OriginAPI || info.Token != "tenant" || info.Name != "site" {
+		t.Fatalf("unexpected record %+v", info)
+	}
+
+	if err := s.Forget(c); err != nil {
+		t.Fatal(err)
+	}
+	info, err = s.Get(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info != nil {
+		t.Fatalf("expected no record, got %+v", info)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	ctx := context.Background()
+	if o := OriginFromContext(ctx); o != OriginCLI {
+		t.Fatalf("expected the default origin to be %q, got %q", OriginCLI, o)
+	}
+	if o := OriginFromContext(WithOrigin(ctx, OriginAPI)); o != OriginAPI {
+		t.Fatalf("expected origin %q, got %q", OriginAPI, o)
+	}
+	if n := NameFromContext(ctx); n != "" {
+		t.Fatalf("expected no name by default, got %q", n)
+	}
+	if n := NameFromContext(WithName(ctx, "site")); n != "site" {
+		t.Fatalf("expected name %q, got %q", "site", n)
+	}
+}
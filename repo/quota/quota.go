@@ -0,0 +1,236 @@
+// Package quota enforces the storage quotas of the API tokens of a node
+// serving several tenants.
+//
+// The DAGs each token pins, by adding or pinning them, are recorded under
+// /local/quota/<token>/<cid> in the datastore, with their size. The usage of
+// a token is the size of its DAGs still pinned: the records of the DAGs
+// unpinned since are dropped when it's computed. The data being added, before
+// its DAG is pinned, is counted too, as it's reserved.
+package quota
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dsq "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/query"
+)
+
+var log = logging.Logger("quota")
+
+var keyPrefix = ds.NewKey("/local/quota")
+
+// ExceededError is returned when storing a DAG would exceed the quota of an
+// identity.
+type ExceededError struct {
+	Identity string
+	Quota    uint64
+	Used     uint64
+	// Size is the size of the DAG, or for adds, the size of the files read
+	// when the quota was exceeded. It's 0 when the quota is used up.
+	Size uint64
+}
+
+func (e *ExceededError) Error() string {
+	msg := fmt.Sprintf("storage quota of %q exceeded: %s of %s used",
+		e.Identity, humanize.Bytes(e.Used), humanize.Bytes(e.Quota))
+	if e.Size > 0 {
+		msg += fmt.Sprintf(", %s more needed", humanize.Bytes(e.Size))
+	}
+	return msg
+}
+
+// PinnedFunc returns whether c is still pinned.
+type PinnedFunc func(c *cid.Cid) (bool, error)
+
+// Store records the DAGs pinned by each identity.
+type Store struct {
+	ds ds.Datastore
+
+	// mu makes checking the quota and recording a DAG atomic
+	mu sync.Mutex
+	// reserved are the keys of the DAGs being pinned, kept by usage
+	reserved map[ds.Key]bool
+	// pending are the bytes reserved by each identity for the data being
+	// added
+	pending map[string]uint64
+}
+
+// NewStore returns a Store saving its records to d.
+func NewStore(d ds.Datastore) *Store {
+	return &Store{
+		ds:       d,
+		reserved: make(map[ds.Key]bool),
+		pending:  make(map[string]uint64),
+	}
+}
+
+// Pinned returns the PinnedFunc of the DAGs pinned recursively or directly by
+// p, the DAGs pinned indirectly having been pinned by someone else.
+func Pinned(p pin.Pinner) PinnedFunc {
+	return func(c *cid.Cid) (bool, error) {
+		_, ok, err := p.IsPinnedWithType(c, pin.Recursive)
+		if err != nil || ok {
+			return ok, err
+		}
+		_, ok, err = p.IsPinnedWithType(c, pin.Direct)
+		return ok, err
+	}
+}
+
+func identityKey(identity string) ds.Key {
+	// token names may hold any character
+	return keyPrefix.ChildString(hex.EncodeToString([]byte(identity)))
+}
+
+// Usage returns the size of the DAGs recorded for identity which are still
+// pinned, and forgets the others, plus the bytes it reserved.
+func (s *Store) Usage(identity string, pinned PinnedFunc) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used, err := s.usage(identity, pinned)
+	return used + s.pending[identity], err
+}
+
+func (s *Store) usage(identity string, pinned PinnedFunc) (uint64, error) {
+	res, err := s.ds.Query(dsq.Query{Prefix: identityKey(identity).String()})
+	if err != nil {
+		return 0, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return 0, err
+	}
+
+	var used uint64
+	for _, e := range entries {
+		k := ds.RawKey(e.Key)
+		c, err := cid.Decode(k.BaseNamespace())
+		if err != nil {
+			log.Debugf("ignoring invalid quota record %s: %s", k, err)
+			continue
+		}
+
+		ok, err := pinned(c)
+		if err != nil {
+			return 0, err
+		}
+		if !ok && !s.reserved[k] {
+			if err := s.ds.Delete(k); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		b, _ := e.Value.([]byte)
+		size, err := strconv.ParseUint(string(b), 10, 64)
+		if err != nil {
+			log.Debugf("ignoring invalid quota record %s: %s", k, err)
+			continue
+		}
+		used += size
+	}
+	return used, nil
+}
+
+// Reserve records that identity pins c, of size bytes, unless it would
+// exceed its quota, in which case it returns an *ExceededError. The record is
+// kept while c is pinned, until Release is called.
+func (s *Store) Reserve(identity string, quota uint64, c *cid.Cid, size uint64, pinned PinnedFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := identityKey(identity).ChildString(c.String())
+	has, err := s.ds.Has(k)
+	if err != nil {
+		return err
+	}
+	if !has {
+		if err := s.check(identity, quota, size, pinned); err != nil {
+			return err
+		}
+		if err := s.record(identity, c, size); err != nil {
+			return err
+		}
+	}
+	s.reserved[k] = true
+	return nil
+}
+
+// check returns an *ExceededError if identity storing size more bytes would
+// exceed its quota.
+func (s *Store) check(identity string, quota, size uint64, pinned PinnedFunc) error {
+	used, err := s.usage(identity, pinned)
+	if err != nil {
+		return err
+	}
+	used += s.pending[identity]
+	if used+size > quota {
+		return &ExceededError{Identity: identity, Quota: quota, Used: used, Size: size}
+	}
+	return nil
+}
+
+// ReserveBytes reserves size bytes of the quota of identity for data being
+// added, unless it would exceed its quota, in which case it returns an
+// *ExceededError. The bytes are reserved until ReleaseBytes is called, once
+// the DAG of the data is recorded or the add failed.
+func (s *Store) ReserveBytes(identity string, quota, size uint64, pinned PinnedFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.check(identity, quota, size, pinned); err != nil {
+		return err
+	}
+	s.pending[identity] += size
+	return nil
+}
+
+// ReleaseBytes ends the reservation of size bytes by identity.
+func (s *Store) ReleaseBytes(identity string, size uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if size >= s.pending[identity] {
+		delete(s.pending, identity)
+		return
+	}
+	s.pending[identity] -= size
+}
+
+// Release ends the reservation of c for identity. The record is forgotten
+// when c isn't pinned.
+func (s *Store) Release(identity string, c *cid.Cid, pinned PinnedFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := identityKey(identity).ChildString(c.String())
+	delete(s.reserved, k)
+	ok, err := pinned(c)
+	if err != nil || ok {
+		return err
+	}
+	err = s.ds.Delete(k)
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// Record records that identity pins c, of size bytes, without checking its
+// quota.
+func (s *Store) Record(identity string, c *cid.Cid, size uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.record(identity, c, size)
+}
+
+func (s *Store) record(identity string, c *cid.Cid, size uint64) error {
+	k := identityKey(identity).ChildString(c.String())
+	return s.ds.Put(k, []byte(strconv.FormatUint(size, 10)))
+}
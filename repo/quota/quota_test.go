@@ -0,0 +1,127 @@
+package quota
+
+import (
+	"testing"
+
+	u "gx/ipfs/QmPdKqUcHGFdeSpvjVoaTRPPstGif9GBZb5Q56RVw9o69A/go-ipfs-util"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dssync "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
+)
+
+func testCid(s string) *cid.Cid {
+	return cid.NewCidV0(u.Hash([]byte(s)))
+}
+
+func pinnedSet(pins map[string]bool) PinnedFunc {
+	return func(c *cid.Cid) (bool, error) {
+		return pins[c.KeyString()], nil
+	}
+}
+
+func TestReserve(t *testing.T) {
+	s := NewStore(dssync.MutexWrap(ds.NewMapDatastore()))
+	pins := make(map[string]bool)
+	pinned := pinnedSet(pins)
+	a, b := testCid("a"), testCid("b")
+
+	if err := s.Reserve("tenant", 100, a, 60, pinned); err != nil {
+		t.Fatal(err)
+	}
+	pins[a.KeyString()] = true
+	if err := s.Release("tenant", a, pinned); err != nil {
+		t.Fatal(err)
+	}
+
+	err := s.Reserve("tenant", 100, b, 50, pinned)
+	qerr, ok := err.(*ExceededError)
+	if !ok {
+		t.Fatalf("expected an ExceededError, got %v", err)
+	}
+	if qerr.Used != 60 || qerr.Size != 50 {
+		t.Fatalf("unexpected error %s", qerr)
+	}
+
+	// pinning a again doesn't count twice
+	if err := s.Reserve("tenant", 100, a, 60, pinned); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Release("tenant", a, pinned); err != nil {
+		t.Fatal(err)
+	}
+
+	// other identities have their own quota
+	if err := s.Reserve("other", 100, b, 50, pinned); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUsageForgetsUnpinned(t *testing.T) {
+	s := NewStore(dssync.MutexWrap(ds.NewMapDatastore()))
+	pins := make(map[string]bool)
+	pinned := pinnedSet(pins)
+	a, b := testCid("a"), testCid("b")
+
+	if err := s.Record("tenant", a, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reserve("tenant", 100, b, 20, pinned); err != nil {
+		t.Fatal(err)
+	}
+	pins[a.KeyString()] = true
+
+	// b isn't pinned yet, but is still being pinned
+	used, err := s.Usage("tenant", pinned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != 30 {
+		t.Fatalf("expected 30 bytes used, got %d", used)
+	}
+
+	// pinning b failed
+	if err := s.Release("tenant", b, pinned); err != nil {
+		t.Fatal(err)
+	}
+	delete(pins, a.KeyString())
+
+	used, err = s.Usage("tenant", pinned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != 0 {
+		t.Fatalf("expected no bytes used, got %d", used)
+	}
+	if has, _ := s.ds.Has(identityKey("tenant").ChildString(a.String())); has {
+		t.Fatal("expected the record of the unpinned DAG to be forgotten")
+	}
+}
+
+func TestReserveBytes(t *testing.T) {
+	s := NewStore(dssync.MutexWrap(ds.NewMapDatastore()))
+	pinned := pinnedSet(make(map[string]bool))
+
+	// the bytes of two adds at once
+	if err := s.ReserveBytes("tenant", 100, 60, pinned); err != nil {
+		t.Fatal(err)
+	}
+	err := s.ReserveBytes("tenant", 100, 60, pinned)
+	if qerr, ok := err.(*ExceededError); !ok || qerr.Used != 60 {
+		t.Fatalf("expected an ExceededError with 60 bytes used, got %v", err)
+	}
+	if err := s.Reserve("tenant", 100, testCid("a"), 50, pinned); err == nil {
+		t.Fatal("expected the pin to count the bytes reserved")
+	}
+
+	s.ReleaseBytes("tenant", 60)
+	if err := s.ReserveBytes("tenant", 100, 60, pinned); err != nil {
+		t.Fatal(err)
+	}
+	used, err := s.Usage("tenant", pinned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != 60 {
+		t.Fatalf("expected 60 bytes used, got %d", used)
+	}
+}
@@ -0,0 +1,335 @@
+// Package usage accounts for the space used by a repo by category: the
+// blocks of the pinned DAGs, the blocks only referenced by the MFS tree, the
+// other blocks (the cache), the files referenced by the filestore and the
+// overhead of the datastore.
+//
+// The blocks stored are counted as they are added and removed, so their
+// total is always up to date without scanning the blockstore. The counts are
+// saved under /local/usage in the datastore, and recounted from the
+// blockstore when the node didn't close cleanly. The pinned and MFS blocks
+// are counted by walking their DAGs in the background, when the pins or the
+// MFS root changed, at most once every RefreshInterval; the cache is the rest
+// of the blocks.
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	goprocess "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
+	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+)
+
+var log = logging.Logger("usage")
+
+var key = ds.NewKey("/local/usage")
+
+// RefreshInterval is how often the pinned and MFS blocks are counted again
+// at most, by default.
+const RefreshInterval = 10 * time.Minute
+
+// checkInterval is how often Run saves the counts and checks whether the
+// pins or the MFS root changed.
+const checkInterval = time.Minute
+
+// Walk is a count of the pinned and MFS blocks.
+type Walk struct {
+	// Roots identifies the pins and MFS root counted, as returned by
+	// Tracker.Roots.
+	Roots string
+	Time  time.Time
+	// Pinned is the size of the blocks of the pinned DAGs, and MFS of the
+	// other blocks of the MFS tree.
+	Pinned uint64
+	MFS    uint64
+	// Filestore is the size of the data referenced by the filestore.
+	Filestore uint64
+}
+
+type record struct {
+	Blocks uint64
+	Bytes  uint64
+	// Clean is set when the node closed cleanly, the counts being exact.
+	Clean bool
+	Walk  *Walk `json:",omitempty"`
+}
+
+// Report is the usage of a repo by category, in bytes.
+type Report struct {
+	Pinned    uint64
+	MFS       uint64
+	Cache     uint64
+	Filestore uint64
+	Overhead  uint64
+	// Updated is when the pinned and MFS blocks were counted, and Stale is
+	// set when the pins or the MFS root changed since.
+	Updated time.Time
+	Stale   bool
+	// Counting is set while the blocks are counted, the report being
+	// empty until then.
+	Counting bool `json:",omitempty"`
+}
+
+// Tracker counts the blocks of a repo and keeps the last count of its pinned
+// and MFS blocks.
+type Tracker struct {
+	ds ds.Datastore
+
+	// Roots returns a value identifying the pins and the MFS root, which
+	// changes when they do.
+	Roots func() (string, error)
+	// Walk counts the pinned and MFS blocks.
+	Walk func(ctx context.Context) (*Walk, error)
+	// Scan counts the blocks of the blockstore and their size.
+	Scan func(ctx context.Context) (blocks uint64, size uint64, err error)
+	// RefreshInterval is how often Run counts the pinned and MFS blocks
+	// again at most.
+	RefreshInterval time.Duration
+
+	mu      sync.Mutex
+	rec     record
+	counted bool // whether rec holds the counts of the blocks
+	// drifted is set when the counts may be off, the blocks added or
+	// removed during a scan possibly counted by it or not
+	drifted bool
+	// scanning is set during a scan, and the blocks and bytes added and
+	// removed meanwhile are kept apart, for the counts of the scan
+	scanning   bool
+	scanBlocks int64
+	scanBytes  int64
+	updating   sync.Mutex // serializes Update
+}
+
+// NewTracker returns a Tracker saving its counts to d. The counts are marked
+// as not clean until Close, to be recounted if the node crashes.
+func NewTracker(d ds.Datastore) (*Tracker, error) {
+	t := &Tracker{
+		ds:              d,
+		RefreshInterval: RefreshInterval,
+	}
+
+	b, err := d.Get(key)
+	switch err {
+	case nil:
+		if data, ok := b.([]byte); ok {
+			if err := json.Unmarshal(data, &t.rec); err != nil {
+				log.Warning("ignoring the invalid repo usage record: ", err)
+				t.rec = record{}
+			}
+		}
+	case ds.ErrNotFound:
+	default:
+		return nil, err
+	}
+	t.counted = t.rec.Clean
+
+	t.rec.Clean = false
+	if err := t.save(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Added counts a block of size bytes added to the repo.
+func (t *Tracker) Added(size int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.scanning {
+		t.scanBlocks++
+		t.scanBytes += int64(size)
+	}
+	t.rec.Blocks++
+	t.rec.Bytes += uint64(size)
+}
+
+// Removed counts a block of size bytes removed from the repo.
+func (t *Tracker) Removed(size int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.scanning {
+		t.scanBlocks--
+		t.scanBytes -= int64(size)
+	}
+	if t.rec.Blocks == 0 || t.rec.Bytes < uint64(size) {
+		t.drifted = t.counted
+	}
+	if t.rec.Blocks > 0 {
+		t.rec.Blocks--
+	}
+	t.rec.Bytes = sub(t.rec.Bytes, uint64(size))
+}
+
+// Blocks returns the number of blocks of the repo, and whether they were
+// counted yet.
+func (t *Tracker) Blocks() (uint64, bool) {
+	if t == nil {
+		return 0, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rec.Blocks, t.counted
+}
+
+// Report returns the usage of the repo, whose datastore takes repoSize
+// bytes.
+func (t *Tracker) Report(repoSize uint64) (*Report, error) {
+	roots, err := t.Roots()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.counted {
+		return &Report{Counting: true, Stale: true}, nil
+	}
+
+	w := t.rec.Walk
+	if w == nil {
+		w = &Walk{}
+	}
+	r := &Report{
+		Pinned:    w.Pinned,
+		MFS:       w.MFS,
+		Filestore: w.Filestore,
+		Updated:   w.Time,
+		Stale:     t.rec.Walk == nil || w.Roots != roots,
+	}
+
+	// the blocks of the filestore are counted in the blocks of the repo
+	stored := sub(t.rec.Bytes, w.Filestore)
+	r.Cache = sub(stored, w.Pinned+w.MFS)
+	r.Overhead = sub(repoSize, stored)
+	return r, nil
+}
+
+func sub(a, b uint64) uint64 {
+	if a < b {
+		return 0
+	}
+	return a - b
+}
+
+// Update counts the blocks if they weren't, and the pinned and MFS blocks
+// when they changed, unless they were counted less than minAge ago.
+func (t *Tracker) Update(ctx context.Context, minAge time.Duration) error {
+	t.updating.Lock()
+	defer t.updating.Unlock()
+
+	t.mu.Lock()
+	last := t.rec.Walk
+	scanned := t.counted
+	t.mu.Unlock()
+
+	if !scanned {
+		if err := t.scan(ctx); err != nil {
+			return err
+		}
+	}
+
+	roots, err := t.Roots()
+	if err != nil {
+		return err
+	}
+	if last != nil && (last.Roots == roots || time.Since(last.Time) < minAge) {
+		return nil
+	}
+
+	w, err := t.Walk(ctx)
+	if err != nil {
+		return err
+	}
+	w.Roots = roots
+	w.Time = time.Now().UTC().Truncate(time.Second)
+
+	t.mu.Lock()
+	t.rec.Walk = w
+	t.mu.Unlock()
+	return t.Flush()
+}
+
+// scan counts the blocks, plus the ones added and removed meanwhile. As the
+// scan may have counted them already, or not, the counts are then marked as
+// drifted, to be counted again the next time the node starts.
+func (t *Tracker) scan(ctx context.Context) error {
+	t.mu.Lock()
+	t.scanning = true
+	t.scanBlocks, t.scanBytes = 0, 0
+	t.mu.Unlock()
+
+	blocks, size, err := t.Scan(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.scanning = false
+	if err != nil {
+		return err
+	}
+	t.rec.Blocks = uint64(max(int64(blocks)+t.scanBlocks, 0))
+	t.rec.Bytes = uint64(max(int64(size)+t.scanBytes, 0))
+	t.drifted = t.scanBlocks != 0 || t.scanBytes != 0
+	t.counted = true
+	return nil
+}
+
+func max(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Run keeps the counts up to date until proc closes.
+func (t *Tracker) Run(proc goprocess.Process) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-proc.Closing()
+		cancel()
+	}()
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		if err := t.Update(ctx, t.RefreshInterval); err != nil && ctx.Err() == nil {
+			log.Error("failed to count the repo usage: ", err)
+		}
+		if err := t.Flush(); err != nil {
+			log.Error("failed to save the repo usage: ", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-proc.Closing():
+			return
+		}
+	}
+}
+
+// Flush saves the counts.
+func (t *Tracker) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.save()
+}
+
+// Close saves the counts, marked as clean if they are exact.
+func (t *Tracker) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rec.Clean = t.counted && !t.drifted
+	return t.save()
+}
+
+// save must be called with the lock held.
+func (t *Tracker) save() error {
+	b, err := json.Marshal(&t.rec)
+	if err != nil {
+		return err
+	}
+	return t.ds.Put(key, b)
+}
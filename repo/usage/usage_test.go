@@ -0,0 +1,110 @@
+package usage
+
+import (
+	"context"
+	"testing"
+
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+)
+
+func newTracker(t *testing.T, d ds.Datastore, roots *string) *Tracker {
+	tr, err := NewTracker(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Roots = func() (string, error) { return *roots, nil }
+	tr.Scan = func(context.Context) (uint64, uint64, error) { return 3, 1000, nil }
+	tr.Walk = func(context.Context) (*Walk, error) {
+		return &Walk{Pinned: 400, MFS: 100, Filestore: 200}, nil
+	}
+	return tr
+}
+
+func TestTracker(t *testing.T) {
+	d := ds.NewMapDatastore()
+	roots := "a"
+	tr := newTracker(t, d, &roots)
+
+	r, err := tr.Report(2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Counting {
+		t.Fatal("expected the blocks to be counted first")
+	}
+
+	if err := tr.Update(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	tr.Added(50)
+	tr.Added(50)
+	tr.Removed(100)
+	tr.Added(100)
+
+	r, err = tr.Report(2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Report{Pinned: 400, MFS: 100, Cache: 400, Filestore: 200, Overhead: 1100, Updated: r.Updated}
+	if *r != expected {
+		t.Fatalf("expected %+v, got %+v", expected, *r)
+	}
+	if n, ok := tr.Blocks(); !ok || n != 5 {
+		t.Fatalf("expected 5 counted blocks, got %d (%t)", n, ok)
+	}
+
+	roots = "b"
+	if r, err = tr.Report(2000); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Stale {
+		t.Fatal("expected the report to be stale once the roots changed")
+	}
+
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the counts are kept after a clean close
+	tr = newTracker(t, d, &roots)
+	if n, ok := tr.Blocks(); !ok || n != 5 {
+		t.Fatalf("expected 5 counted blocks, got %d (%t)", n, ok)
+	}
+
+	// and recounted after a crash, the tracker not being closed
+	tr = newTracker(t, d, &roots)
+	if _, ok := tr.Blocks(); ok {
+		t.Fatal("expected the blocks to be recounted after a crash")
+	}
+}
+
+func TestTrackerAddedDuringScan(t *testing.T) {
+	d := ds.NewMapDatastore()
+	roots := "a"
+	tr := newTracker(t, d, &roots)
+
+	// a block added while the blocks are counted, without being counted
+	tr.Scan = func(context.Context) (uint64, uint64, error) {
+		tr.Added(100)
+		return 3, 1000, nil
+	}
+	if err := tr.Update(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	r, err := tr.Report(2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Cache != 400 {
+		t.Fatalf("expected the block added to be counted, got %+v", *r)
+	}
+
+	// the counts may be off, and are counted again at the next start
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tr = newTracker(t, d, &roots)
+	if _, ok := tr.Blocks(); ok {
+		t.Fatal("expected the blocks to be recounted after a drift")
+	}
+}
@@ -127,6 +127,45 @@ test_expect_success "'ipfs commands --flags' output looks good" '
   grep "ipfs repo gc --quiet / ipfs repo gc -q" commands.txt
 '
 
+test_expect_success "'ipfs commands completion bash' succeeds" '
+  ipfs commands completion bash >completion.bash
+'
+
+test_expect_success "the bash completion script is valid and complete" '
+  bash -n completion.bash &&
+  grep "\"files ls\") echo mfs ;;" completion.bash &&
+  grep "\"key rm\") echo key ;;" completion.bash &&
+  grep "\"pin rm\") echo pin ;;" completion.bash &&
+  grep "\"name publish --key\") echo key ;;" completion.bash
+'
+
+test_expect_success "'ipfs commands completion' completes subcommands" '
+  bash -c "source completion.bash && COMP_WORDS=(ipfs fil) && COMP_CWORD=1 && _ipfs && echo \${COMPREPLY[*]}" >actual &&
+  echo file files filestore >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "'ipfs commands completion' knows zsh and fish" '
+  ipfs commands completion zsh >completion.zsh &&
+  grep bashcompinit completion.zsh &&
+  ipfs commands completion fish >completion.fish &&
+  grep "__ipfs_path_is \"files ls\"" completion.fish
+'
+
+test_expect_success "'ipfs commands completion' fails for other shells" '
+  test_must_fail ipfs commands completion tcsh
+'
 
+test_expect_success "'ipfs --quiet-errors' prints errors as JSON" '
+  test_must_fail ipfs --quiet-errors cat QmInvalid 2>quiet_err &&
+  grep "^{\"Message\":\".*\",\"Code\":0" quiet_err &&
+  test $(wc -l <quiet_err) = 1
+'
+
+test_expect_success "'ipfs --quiet-errors' reports usage errors as client errors" '
+  test_must_fail ipfs --quiet-errors cat 2>quiet_err &&
+  grep "\"Code\":1" quiet_err &&
+  test $(wc -l <quiet_err) = 1
+'
 
 test_done
@@ -177,10 +177,83 @@ test_expect_success "'ipfs config Discovery.Routing' looks good" '
   test $(cat actual_config) = "dhtclient"
 '
 
+test_expect_success "'ipfs config PowerSave.Auto' looks good" '
+  ipfs config PowerSave.Auto > actual_config &&
+  test $(cat actual_config) = "true"
+'
+
+test_expect_success "clean up ipfs dir" '
+  rm -rf "$IPFS_PATH"
+'
+
+test_expect_success "'ipfs init --profile' combines profiles with '+'" '
+  BITS="1024" &&
+  ipfs init --bits="$BITS" --profile=test+lowpower
+'
+
+test_expect_success "all the combined profiles were applied" '
+  ipfs config Bootstrap > actual_config &&
+  test $(cat actual_config) = "[]" &&
+  ipfs config Routing.Type > actual_config &&
+  test $(cat actual_config) = "dhtclient"
+'
+
+test_expect_success "clean up ipfs dir" '
+  rm -rf "$IPFS_PATH"
+'
+
+test_expect_success "'ipfs init --profile' with randomports succeeds" '
+  BITS="1024" &&
+  ipfs init --bits="$BITS" --profile=test,randomports
+'
+
+test_expect_success "randomports wrote a fixed swarm port" '
+  ipfs config Addresses.Swarm > actual_config &&
+  grep "/ip4/0.0.0.0/tcp/" actual_config &&
+  test_must_fail grep "/tcp/0\"" actual_config &&
+  test_must_fail grep "/tcp/4001\"" actual_config
+'
+
+test_expect_success "clean up ipfs dir" '
+  rm -rf "$IPFS_PATH"
+'
+
+test_expect_success "write a user-defined profile" '
+  echo "{\"Swarm\": {\"ConnMgr\": {\"HighWater\": 123}}, \"Gateway\": {\"Writable\": true}}" > myprofile.json
+'
+
+test_expect_success "'ipfs init --profile' with a profile file succeeds" '
+  BITS="1024" &&
+  ipfs init --bits="$BITS" --profile=test+./myprofile.json
+'
+
+test_expect_success "the profile file was applied" '
+  ipfs config Swarm.ConnMgr.HighWater > actual_config &&
+  test $(cat actual_config) = "123" &&
+  ipfs config Gateway.Writable > actual_config &&
+  test $(cat actual_config) = "true" &&
+  ipfs config Bootstrap > actual_config &&
+  test $(cat actual_config) = "[]"
+'
+
+test_expect_success "'ipfs config profile apply' takes a profile file" '
+  echo "{\"Swarm\": {\"ConnMgr\": {\"HighWater\": 456}}}" > other.json &&
+  ipfs config profile apply ./other.json+lowpower &&
+  ipfs config Swarm.ConnMgr.HighWater > actual_config &&
+  test $(cat actual_config) = "40"
+'
+
 test_expect_success "clean up ipfs dir" '
   rm -rf "$IPFS_PATH"
 '
 
+test_expect_success "'ipfs init' rejects a profile file with a wrong type" '
+  echo "{\"Swarm\": {\"ConnMgr\": {\"HighWater\": \"lots\"}}}" > bad.json &&
+  test_must_fail ipfs init --bits=1024 --profile=./bad.json 2> bad_profile_out &&
+  grep "invalid profile ./bad.json" bad_profile_out &&
+  test_must_fail test -e "$IPFS_PATH/config"
+'
+
 test_init_ipfs
 
 test_launch_ipfs_daemon
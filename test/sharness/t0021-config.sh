@@ -179,6 +179,41 @@ test_config_cmd() {
     test_cmp replace_out replace_expected
   '
 
+  test_expect_success "'ipfs config patch' works" '
+    ipfs config patch "{\"Gateway\": {\"Writable\": true}}" &&
+    echo true >expected &&
+    ipfs config Gateway.Writable >actual &&
+    test_cmp expected actual
+  '
+
+  test_expect_success "'ipfs config patch' with null resets a value" '
+    ipfs config patch "{\"Gateway\": {\"Writable\": null}}" &&
+    echo false >expected &&
+    ipfs config Gateway.Writable >actual &&
+    test_cmp expected actual
+  '
+
+  test_expect_success "'ipfs config patch' with an unknown key errors out" '
+    test_expect_code 1 ipfs config patch "{\"Gateway\": {\"Writeable\": true}}" 2>patch_out &&
+    grep "line 1, column 14: Gateway.Writeable: unknown key" patch_out
+  '
+
+  test_expect_success "'ipfs config patch' with a wrong type errors out" '
+    test_expect_code 1 ipfs config patch "{\"Gateway\": {\"Writable\": \"yes\"}}" 2>patch_out &&
+    grep "Gateway.Writable: expected boolean, got string" patch_out
+  '
+
+  test_expect_success "'ipfs config patch' with privkey errors out" '
+    test_expect_code 1 ipfs config patch "{\"Identity\": {\"PrivKey\": \"abc\"}}" 2>patch_out &&
+    echo "Error: setting private key with API is not supported" >patch_expected &&
+    test_cmp patch_expected patch_out
+  '
+
+  test_expect_success "'ipfs config check' reports unknown keys" '
+    test_expect_code 1 ipfs config check 2>check_out &&
+    grep "beep: unknown key" check_out
+  '
+
   test_expect_success "'ipfs config Swarm.AddrFilters' looks good" '
     ipfs config Swarm.AddrFilters > actual_config &&
     test $(cat actual_config | wc -l) = 1
@@ -239,5 +274,27 @@ test_launch_ipfs_daemon
 test_config_cmd
 test_kill_ipfs_daemon
 
+test_expect_success "start over without the unknown keys set above" '
+  rm -rf "$IPFS_PATH" &&
+  ipfs init --profile=test -b=1024 >/dev/null
+'
+
+test_expect_success "'ipfs config check' succeeds" '
+  ipfs config check
+'
+
+test_expect_success "introduce a wrong type" '
+  sed -i"~" -e "s/\"Writable\": false/\"Writable\": \"no\"/" "$IPFS_PATH/config"
+'
+
+test_expect_success "'ipfs config check' reports the wrong type" '
+  test_expect_code 1 ipfs config check 2>check_out &&
+  grep "Gateway.Writable: expected boolean, got string" check_out
+'
+
+test_expect_success "daemon refuses to start with an invalid config" '
+  test_expect_code 1 ipfs daemon 2>daemon_out &&
+  grep "Gateway.Writable: expected boolean, got string" daemon_out
+'
 
 test_done
@@ -37,4 +37,37 @@ test_expect_success "daemon no longer running" '
     ! kill -0 $IPFS_PID 2>/dev/null && return
   done
 '
+
+test_launch_ipfs_daemon
+
+test_expect_success "start a slow add" '
+  (echo first; go-sleep 2s; echo second) | ipfs add -q >add_out 2>add_err &
+  ADD_PID=$! &&
+  go-sleep 500ms
+'
+
+test_expect_success "interrupt the daemon during the add" '
+  kill -INT $IPFS_PID
+'
+
+test_expect_success "the add in flight completes" '
+  wait $ADD_PID
+'
+
+test_expect_success "daemon no longer running" '
+  for i in $(test_seq 1 100)
+  do
+    go-sleep 100ms
+    ! kill -0 $IPFS_PID 2>/dev/null && return
+  done
+'
+
+test_expect_success "the added content was persisted" '
+  printf "first\nsecond\n" | ipfs add -q --only-hash >expected_hash &&
+  test_cmp expected_hash add_out &&
+  ipfs cat $(cat add_out) >actual &&
+  printf "first\nsecond\n" >expected &&
+  test_cmp expected actual
+'
+
 test_done
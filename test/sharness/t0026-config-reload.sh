@@ -0,0 +1,74 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2018 The IPFS Authors
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test reloading the config of a running daemon"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "add test content" '
+  echo "blocked" >blocked.txt &&
+  BLOCKED=$(ipfs add -q blocked.txt)
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "'ipfs config reload' without changes works" '
+  ipfs config reload >actual &&
+  test_must_be_empty actual
+'
+
+test_expect_success "gateway headers are applied on reload" '
+  ipfs config --json Gateway.HTTPHeaders.X-Reload-Test "[\"yes\"]" &&
+  ipfs config reload >actual &&
+  grep "applied Gateway.HTTPHeaders" actual &&
+  curl -sI "http://$GWAY_ADDR/ipfs/$BLOCKED" >headers &&
+  grep "X-Reload-Test: yes" headers
+'
+
+test_expect_success "denylist files are applied on reload" '
+  echo "$BLOCKED" >"$IPFS_PATH/denylist.txt" &&
+  ipfs config --json Denylist.Files "[\"denylist.txt\"]" &&
+  ipfs config reload >actual &&
+  grep "applied Denylist.Files" actual &&
+  test_curl_resp_http_code "http://$GWAY_ADDR/ipfs/$BLOCKED" "HTTP/1.1 410 Gone"
+'
+
+test_expect_success "the blockstore cache sizes are applied on reload" '
+  ipfs config --json Datastore.BloomFilterSize 1048576 &&
+  ipfs config reload >actual &&
+  grep "applied Datastore.BloomFilterSize" actual &&
+  echo "cached" >cached.txt &&
+  CACHED=$(ipfs add -q cached.txt) &&
+  ipfs cat "$CACHED" >actual &&
+  test_cmp cached.txt actual
+'
+
+test_expect_success "other changes require a restart" '
+  ipfs config Gateway.RootRedirect /ipfs/$BLOCKED &&
+  ipfs config reload >actual &&
+  grep "restart required for Gateway.RootRedirect" actual
+'
+
+test_expect_success "SIGHUP reloads the config" '
+  ipfs config --json Denylist.Files "[]" &&
+  kill -HUP $IPFS_PID &&
+  go-sleep 500ms &&
+  kill -0 $IPFS_PID &&
+  test_curl_resp_http_code "http://$GWAY_ADDR/ipfs/$BLOCKED" "HTTP/1.1 200 OK"
+'
+
+test_expect_success "an invalid config is not applied" '
+  sed -i"~" -e "s/\"HashOnRead\": false/\"HashOnRead\": \"no\"/" "$IPFS_PATH/config" &&
+  test_must_fail ipfs config reload 2>reload_err &&
+  grep "Datastore.HashOnRead: expected boolean, got string" reload_err &&
+  mv "$IPFS_PATH/config~" "$IPFS_PATH/config"
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -619,10 +619,81 @@ test_expect_success "ipfs cat file fails" '
   test_must_fail ipfs cat $(cat oh_hash)
 '
 
+test_expect_success "ipfs add --only-hash gives the hashes of ipfs add" '
+  mkdir -p parity/sub &&
+  echo "parity a" >parity/a.txt &&
+  echo "parity b" >parity/sub/b.html &&
+  for opts in "" "--raw-leaves" "--cid-version=1" "--mime-type" "--trickle" "--hash=blake2b-256 -w"
+  do
+    ipfs add -r -q $opts parity >parity_added &&
+    ipfs add -r -q -n $opts parity >parity_hashed &&
+    test_cmp parity_added parity_hashed || return 1
+  done
+'
+
+test_expect_success "ipfs add --only-hash shards the directories like ipfs add" '
+  mkdir large &&
+  for i in `seq 1001`
+  do
+    echo $i >large/file$i || return 1
+  done &&
+  ipfs config --json Experimental.ShardingEnabled true &&
+  ipfs add -r -Q large >large_added &&
+  ipfs add -r -Q -n large >large_hashed &&
+  ipfs config --json Experimental.ShardingEnabled false &&
+  test_cmp large_added large_hashed &&
+  ipfs add -r -Q -n large >large_unsharded &&
+  ! test_cmp large_added large_unsharded
+'
+
+test_expect_success "ipfs add --manifest writes the objects added" '
+  ipfs add -r -n --manifest=parity.json parity >parity_out &&
+  PARITY_ROOT=$(tail -n1 parity_out | cut -d" " -f2) &&
+  PARITY_B=$(grep "parity/sub/b.html" parity_out | cut -d" " -f2) &&
+  grep "\"Root\": \"$PARITY_ROOT\"" parity.json &&
+  grep -A1 "\"Name\": \"parity/sub/b.html\"" parity.json >parity_b &&
+  grep "\"Hash\": \"$PARITY_B\"" parity_b
+'
+
+test_expect_success "ipfs add --manifest isn't written when the add fails" '
+  test_must_fail ipfs add -n --manifest=missing.json parity/missing &&
+  test ! -e missing.json
+'
+
+test_expect_success "ipfs add --prev-manifest reuses the files which didn't change" '
+  ipfs add -r -q --manifest=prev.json parity >prev_added &&
+  OTHER=$(echo "other content" | ipfs add -q) &&
+  A=$(grep -A1 "\"Name\": \"parity/a.txt\"" prev.json | sed -n "s/.*\"Hash\": \"\(.*\)\".*/\1/p") &&
+  sed "s/$A/$OTHER/" prev.json >prev_other.json &&
+  ipfs add -r --prev-manifest=prev_other.json parity >reused_out &&
+  grep "added $OTHER parity/a.txt" reused_out
+'
+
+test_expect_success "ipfs add --prev-manifest chunks the files which changed" '
+  touch -d "2001-01-01" parity/a.txt &&
+  ipfs add -r --prev-manifest=prev_other.json parity >changed_out &&
+  grep "added $A parity/a.txt" changed_out
+'
+
+test_expect_success "ipfs add --prev-manifest doesn't reuse with other options" '
+  ipfs add -r --raw-leaves parity >raw_out &&
+  ipfs add -r --raw-leaves --prev-manifest=prev_other.json parity >raw_reused_out &&
+  test_cmp raw_out raw_reused_out
+'
+
 test_add_named_pipe ""
 
 test_add_pwd_is_symlink
 
+test_expect_success "'ipfs --enc=json' streams one document per line" '
+  mkdir json_dir &&
+  echo a >json_dir/a &&
+  echo b >json_dir/b &&
+  ipfs add --enc=json -r json_dir >add_json &&
+  grep "^{\"Name\":\"json_dir/a\",\"Hash\":\"Qm" add_json &&
+  grep "^{\"Name\":\"json_dir\",\"Hash\":\"Qm" add_json
+'
+
 # Test daemon in offline mode
 test_launch_ipfs_daemon --offline
 
@@ -23,6 +23,25 @@ test_expect_success "test ping other" '
   ipfsi 1 ping -n2 -- "$PEERID_0"
 '
 
+test_expect_success "test ping with options prints stats" '
+  ipfsi 0 ping -n2 --interval=100ms --size=64 -- "$PEERID_1" > ping_out &&
+  grep "PING $PEERID_1 (64 bytes)." ping_out &&
+  grep "2 pings sent, 2 received, 0% loss, min/avg/max/stddev = " ping_out
+'
+
+test_expect_success "test ping with JSON stats" '
+  ipfsi 0 ping -n1 --enc=json -- "$PEERID_1" > ping_json &&
+  grep "\"Received\":1" ping_json
+'
+
+test_expect_success "test ping over tcp" '
+  ipfsi 0 ping -n1 --protocol=tcp -- "$PEERID_1"
+'
+
+test_expect_success "test ping with invalid size" '
+  ! ipfsi 0 ping -n1 --size=33 -- "$PEERID_1"
+'
+
 test_expect_success "test ping self" '
   ! ipfsi 0 ping -n2 -- "$PEERID_0" &&
   ! ipfsi 1 ping -n2 -- "$PEERID_1"
@@ -154,6 +154,18 @@ test_expect_success "'ipfs ls' fails" '
   test_must_fail ipfs ls $DIR
 '
 
+test_expect_success "'ipfs ls --resolve-type=false --resolve-size=false' ok" '
+  ipfs ls --resolve-type=false $DIR >expected_ls_fast &&
+  ipfs ls --resolve-type=false --resolve-size=false $DIR >actual_ls_fast &&
+  test_cmp expected_ls_fast actual_ls_fast
+'
+
+test_expect_success "'ipfs ls --resolve-type=false --resolve-size=false' uses the link sizes" '
+  ipfs object links $DIR | awk "{print \$2}" >expected_sizes &&
+  awk "{print \$2}" actual_ls_fast >actual_sizes &&
+  test_cmp expected_sizes actual_sizes
+'
+
 test_launch_ipfs_daemon --offline
 
 test_expect_success "'ipfs ls --resolve-type=false' ok" '
@@ -177,6 +189,10 @@ test_expect_success "'ipfs ls --resolve-type=false' ok and does not hang" '
   go-timeout 2 ipfs ls --resolve-type=false $DIR
 '
 
+test_expect_success "'ipfs ls --resolve-type=false --resolve-size=false' does not hang" '
+  go-timeout 2 ipfs ls --resolve-type=false --resolve-size=false $DIR
+'
+
 test_kill_ipfs_daemon
 
 test_done
@@ -221,4 +221,23 @@ test_expect_success "put with sha3 and cidv0 fails" '
   echo "foooo" | test_must_fail ipfs block put --mhtype=sha3 --mhlen=20 --format=v0
 '
 
+test_expect_success "'ipfs block put' stores several blocks at once" '
+  echo "Hello Mars!" >mars &&
+  echo "Hello Venus!" >venus &&
+  echo "Hello Pluto!" >pluto &&
+  for f in mars venus pluto; do ipfs block put <$f || return 1; done >expected_many &&
+  ipfs block rm $(cat expected_many) &&
+  ipfs block put mars venus pluto >actual_many &&
+  test_cmp expected_many actual_many &&
+  ipfs block get $(sed -n 2p actual_many) >actual_venus &&
+  test_cmp venus actual_venus
+'
+
+test_expect_success "'ipfs block put --stream' stores several blocks" '
+  ipfs block rm $(cat expected_many) &&
+  ipfs block put --stream mars venus pluto >actual_stream &&
+  test_cmp expected_many actual_stream &&
+  ipfs block stat $(sed -n 3p actual_stream)
+'
+
 test_done
@@ -206,6 +206,72 @@ test_dag_cmd() {
     test_cmp resolve_obj_exp resolve_obj &&
     test_cmp resolve_data_exp resolve_data
   '
+
+  test_expect_success "dag resolve escaped map keys" '
+    ESCAPED_HASH=$(echo "{\"a/b\":{\"50%\":{\"/\":\"${NESTED_HASH}\"}}}" | ipfs dag put) &&
+    ipfs dag resolve ${ESCAPED_HASH}/a%2Fb/50%25 > resolve_escaped &&
+    ipfs dag resolve ${ESCAPED_HASH}/a%2Fb > resolve_escaped_rem &&
+    printf $NESTED_HASH > resolve_escaped_exp &&
+    printf $ESCAPED_HASH/a%%2Fb > resolve_escaped_rem_exp &&
+    test_cmp resolve_escaped_exp resolve_escaped &&
+    test_cmp resolve_escaped_rem_exp resolve_escaped_rem
+  '
+
+  test_expect_success "dag resolve --verbose prints the steps" '
+    ipfs dag resolve --verbose ${HASH}/obj/data > resolve_verbose &&
+    printf "$HASH\t/obj\n$NESTED_HASH\t/data\n$NESTED_HASH/data" > resolve_verbose_exp &&
+    test_cmp resolve_verbose_exp resolve_verbose
+  '
+
+  test_expect_success "dag patch set-field through a link works" '
+    PATCHED=$(ipfs dag patch set-field $HASH obj/data 456) &&
+    ipfs dag get $PATCHED/obj/data > patch_field_out &&
+    grep -x 456 patch_field_out
+  '
+
+  test_expect_success "dag patch set-field leaves the original untouched" '
+    ipfs dag get $HASH/obj/data > patch_orig_out &&
+    grep -x 123 patch_orig_out
+  '
+
+  test_expect_success "dag patch set-field needs --create for new maps" '
+    test_must_fail ipfs dag patch set-field $HASH new/field "\"x\"" &&
+    PATCHED=$(ipfs dag patch --create set-field $HASH new/field "\"x\"") &&
+    ipfs dag get $PATCHED/new/field > patch_create_out &&
+    grep -x "\"x\"" patch_create_out
+  '
+
+  test_expect_success "dag patch rm-link works on dag-cbor" '
+    PATCHED=$(ipfs dag patch rm-link $HASH obj) &&
+    test_must_fail ipfs dag resolve $PATCHED/obj
+  '
+
+  test_expect_success "dag patch set-link creates unixfs directories" '
+    EMPTY_DIR=$(ipfs object new unixfs-dir) &&
+    PATCHED_DIR=$(ipfs dag patch --create set-link $EMPTY_DIR a/b $HASH1) &&
+    ipfs cat $PATCHED_DIR/a/b > patch_link_out &&
+    test_cmp file1 patch_link_out
+  '
+
+  test_expect_success "dag patch append-data works" '
+    PATCHED_DIR=$(echo "more" | ipfs dag patch append-data $PATCHED_DIR a/b) &&
+    ipfs cat $PATCHED_DIR/a/b > patch_append_out &&
+    printf "foo\nmore\n" > patch_append_exp &&
+    test_cmp patch_append_exp patch_append_out
+  '
+
+  test_expect_success "dag patch keeps the large integers and bytes of dag-cbor" '
+    printf "\242\141x\001\143big\033\200\000\000\000\000\000\000\001" > patch_big_in &&
+    printf "\242\141x\002\143big\033\200\000\000\000\000\000\000\001" > patch_big_exp &&
+    BIG=$(ipfs dag put --input-enc=raw < patch_big_in) &&
+    PATCHED_BIG=$(ipfs dag patch set-field $BIG x 2) &&
+    ipfs block get $PATCHED_BIG > patch_big_out &&
+    test_cmp patch_big_exp patch_big_out
+  '
+
+  test_expect_success "dag patch set-field fails on dag-pb" '
+    test_must_fail ipfs dag patch set-field $PATCHED_DIR a 1
+  '
 }
 
 # should work offline
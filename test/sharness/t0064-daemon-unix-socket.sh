@@ -0,0 +1,57 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2018 The IPFS Authors
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test serving the API on a unix domain socket"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+SOCK="$(pwd)/api.sock"
+
+test_expect_success "configure API on a unix socket" '
+  ipfs config Addresses.API "/unix$SOCK" &&
+  ipfs config Addresses.SocketMode 0640
+'
+
+test_expect_success "'ipfs daemon' succeeds" '
+  ipfs daemon >actual_daemon 2>daemon_err &
+  IPFS_PID=$!
+'
+
+test_expect_success "api file shows up" '
+  test_wait_for_file 50 100ms "$IPFS_PATH/api"
+'
+
+test_expect_success "api file contains the socket address" '
+  echo "/unix$SOCK" >expected_api &&
+  test_cmp expected_api "$IPFS_PATH/api"
+'
+
+test_expect_success "socket has the configured permissions" '
+  stat -c %a "$SOCK" >actual_mode &&
+  echo 640 >expected_mode &&
+  test_cmp expected_mode actual_mode
+'
+
+test_expect_success "cli talks to the daemon over the socket" '
+  ipfs id -f="<id>\n" >actual_id &&
+  ipfs config Identity.PeerID >expected_id &&
+  test_cmp expected_id actual_id
+'
+
+test_expect_success "curl can reach the API over the socket" '
+  curl -s --unix-socket "$SOCK" -X POST "http://localhost/api/v0/version" >version_out &&
+  grep "Version" version_out
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success "socket is removed on shutdown" '
+  test_must_fail test -e "$SOCK"
+'
+
+test_done
@@ -0,0 +1,51 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2018 The IPFS Authors
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test daemon liveness and readiness endpoints"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_launch_ipfs_daemon --wait-ready
+
+test_expect_success "daemon reports being ready" '
+  for i in $(test_seq 1 600); do
+    grep -q "Daemon is ready" actual_daemon && break
+    go-sleep 100ms
+  done &&
+  grep "Daemon is ready" actual_daemon
+'
+
+test_expect_success "API /livez answers 200" '
+  curl -sf "http://$API_ADDR/livez" >livez &&
+  grep "livez check passed" livez
+'
+
+test_expect_success "API /readyz answers 200" '
+  curl -sf "http://$API_ADDR/readyz" >readyz &&
+  grep "\[+\]repo ok" readyz &&
+  grep "\[+\]swarm ok" readyz &&
+  grep "\[+\]bootstrap ok" readyz &&
+  grep "readyz check passed" readyz
+'
+
+test_expect_success "gateway /readyz answers 200" '
+  test_curl_resp_http_code "http://$GWAY_ADDR/readyz" "HTTP/1.1 200 OK"
+'
+
+test_kill_ipfs_daemon
+
+test_launch_ipfs_daemon --offline
+
+test_expect_success "offline daemon is ready" '
+  curl -sf "http://$API_ADDR/readyz" >readyz &&
+  grep "\[+\]swarm ok: offline" readyz
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,51 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2018 The IPFS Authors
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs power save"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "'ipfs power save' fails offline" '
+  test_must_fail ipfs power save 2> err &&
+  grep "must be run in online mode" err
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "power save is off by default" '
+  echo "power save: off (mode: auto)" >expected &&
+  ipfs power save >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "'ipfs power save on' enables power save" '
+  echo "power save: on (mode: on)" >expected &&
+  ipfs power save on >actual &&
+  test_cmp expected actual &&
+  ipfs power save >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "'ipfs bitswap reprovide' still works in power save" '
+  ipfs bitswap reprovide
+'
+
+test_expect_success "'ipfs power save off' disables power save" '
+  echo "power save: off (mode: off)" >expected &&
+  ipfs power save off >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "'ipfs power save' with an unknown mode fails" '
+  test_must_fail ipfs power save sometimes 2> err &&
+  grep "unknown power save mode" err
+'
+
+test_kill_ipfs_daemon
+
+test_done
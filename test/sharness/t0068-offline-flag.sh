@@ -0,0 +1,85 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2018 The IPFS Authors
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test running commands with --offline"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "add some content" '
+  echo "offline content" >afile &&
+  HASH=$(ipfs add -q afile) &&
+  MFS_HASH=$(echo "mfs content" | ipfs add -q) &&
+  ipfs files cp /ipfs/$MFS_HASH /mfsfile &&
+  MISSING=$(echo "never added" | ipfs add -q --only-hash)
+'
+
+test_launch_ipfs_daemon
+
+test_offline_commands() {
+  test_expect_success "'ipfs --offline cat' reads local blocks ($1)" '
+    ipfs --offline cat $HASH >actual &&
+    test_cmp afile actual
+  '
+
+  test_expect_success "'ipfs --offline dag get' reads local blocks ($1)" '
+    ipfs --offline dag get $HASH >/dev/null
+  '
+
+  test_expect_success "'ipfs --offline cat' fails on a missing block ($1)" '
+    test_must_fail ipfs --offline cat $MISSING
+  '
+
+  test_expect_success "'ipfs --offline pin ls' works ($1)" '
+    ipfs --offline pin ls --type=recursive >pins &&
+    grep $HASH pins
+  '
+
+  test_expect_success "'ipfs --offline files' works ($1)" '
+    ipfs --offline files ls / >files_out &&
+    grep mfsfile files_out &&
+    ipfs --offline files read /mfsfile >actual &&
+    echo "mfs content" >expected &&
+    test_cmp expected actual
+  '
+
+  test_expect_success "'ipfs --offline key list' works ($1)" '
+    ipfs --offline key list >keys &&
+    grep self keys
+  '
+}
+
+test_offline_commands "daemon running"
+
+test_expect_success "'ipfs --offline swarm peers' needs the network" '
+  test_must_fail ipfs --offline swarm peers 2>swarm_err &&
+  grep "must be run in online mode" swarm_err
+'
+
+test_expect_success "the daemon still answers online requests" '
+  ipfs swarm peers
+'
+
+test_kill_ipfs_daemon
+
+test_offline_commands "daemon stopped"
+
+test_expect_success "leave an api file without a daemon" '
+  echo "/ip4/127.0.0.1/tcp/1" >"$IPFS_PATH/api"
+'
+
+test_expect_success "commands without --offline fail on the stale api file" '
+  test_must_fail ipfs pin ls
+'
+
+test_offline_commands "stale api file"
+
+test_expect_success "remove the stale api file" '
+  rm "$IPFS_PATH/api"
+'
+
+test_done
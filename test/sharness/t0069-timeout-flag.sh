@@ -0,0 +1,48 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2018 The IPFS Authors
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the global --timeout option"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "add some content" '
+  echo "some content" >afile &&
+  HASH=$(ipfs add -q afile) &&
+  MISSING=$(echo "never added" | ipfs add -q --only-hash)
+'
+
+test_expect_success "'ipfs --timeout' fails on invalid durations" '
+  test_must_fail ipfs --timeout=forever cat $HASH 2>err &&
+  grep "invalid timeout" err
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "'ipfs --timeout cat' works on local content" '
+  ipfs --timeout=10s cat $HASH >actual &&
+  test_cmp afile actual
+'
+
+test_expect_success "'ipfs --timeout cat' gives up on a missing block" '
+  test_must_fail ipfs --timeout=1s cat $MISSING 2>err &&
+  grep "context deadline exceeded" err
+'
+
+test_expect_success "'ipfs --timeout' applies to the HTTP API" '
+  curl -s "http://$API_ADDR/api/v0/cat?arg=$MISSING&timeout=1s" >curl_out &&
+  grep "context deadline exceeded" curl_out
+'
+
+test_expect_success "'ipfs --timeout' applies to legacy commands" '
+  test_must_fail ipfs --timeout=1s dag get $MISSING 2>err &&
+  grep "context deadline exceeded" err
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,63 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2018 The IPFS Authors
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test running the daemon under a service manager"
+
+. lib/test-lib.sh
+
+type socat >/dev/null 2>&1 && test_set_prereq SOCAT
+
+test_init_ipfs
+
+test_launch_ipfs_daemon --supervised --log-file="$(pwd)/daemon.log"
+
+CTL="$IPFS_PATH/control.sock"
+
+test_expect_success "the control socket is served" '
+  grep "Control socket listening on $CTL" actual_daemon &&
+  test -S "$CTL"
+'
+
+test_expect_success "the log file is created" '
+  test -f daemon.log
+'
+
+test_expect_success "SIGUSR1 reopens the log file" '
+  mv daemon.log daemon.log.1 &&
+  kill -USR1 $IPFS_PID &&
+  test_wait_for_file 20 100ms daemon.log
+'
+
+test_expect_success SOCAT "the control socket lists its commands" '
+  echo help | socat - "UNIX-CONNECT:$CTL" >help_out &&
+  echo "[\"help\",\"reload\",\"reopen-logs\",\"stats\"]" >help_exp &&
+  test_cmp help_exp help_out
+'
+
+test_expect_success SOCAT "the control socket returns statistics" '
+  echo stats | socat - "UNIX-CONNECT:$CTL" >stats_out &&
+  grep "\"Uptime\":" stats_out &&
+  grep "\"RepoSize\":" stats_out
+'
+
+test_expect_success SOCAT "the control socket reloads the config" '
+  echo reload | socat - "UNIX-CONNECT:$CTL" >reload_out &&
+  grep "\"Applied\":" reload_out &&
+  test_must_fail grep "\"Error\":" reload_out
+'
+
+test_expect_success SOCAT "the control socket rejects unknown commands" '
+  echo frobnicate | socat - "UNIX-CONNECT:$CTL" >unknown_out &&
+  grep "unknown command" unknown_out
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success "the control socket is removed" '
+  test ! -e "$CTL"
+'
+
+test_done
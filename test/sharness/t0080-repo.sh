@@ -245,6 +245,19 @@ test_expect_success "repo stats are updated correctly" '
   test $(get_field_num "RepoSize" repo-stats-2) -ge $(get_field_num "RepoSize" repo-stats)
 '
 
+test_expect_success "'ipfs stats growth' counts the blocks added today" '
+  echo "growth" | ipfs add -q >/dev/null &&
+  ipfs stats growth > growth-stats &&
+  grep "^Date" growth-stats &&
+  today=$(date -u +%Y-%m-%d) &&
+  test $(grep "^$today" growth-stats | awk "{ print \$2 }") -ge 1
+'
+
+test_expect_success "'ipfs stats growth --days=0' fails" '
+  test_must_fail ipfs stats growth --days=0 2> err &&
+  grep "days must be positive" err
+'
+
 test_expect_success "'ipfs repo version' succeeds" '
   ipfs repo version > repo-version
 '
@@ -260,8 +273,25 @@ test_expect_success "repo version came out correct" '
   egrep "^fs-repo@[0-9]+" repo-version-q >/dev/null
 '
 
+test_expect_success "'ipfs repo lock status' shows the daemon holding the lock" '
+  ipfs repo lock status > lock_status &&
+  grep "Locked: yes" lock_status &&
+  grep "Owner: process $IPFS_PID on " lock_status
+'
+
+test_expect_success "'ipfs daemon --steal-lock' doesn't steal the lock of a running daemon" '
+  test_must_fail ipfs daemon --steal-lock 2> steal_err &&
+  grep "daemon is running\|may be running" steal_err
+'
+
 test_kill_ipfs_daemon
 
+test_expect_success "'ipfs repo lock status' shows the repo unlocked" '
+  ipfs repo lock status > lock_status &&
+  grep "Locked: no" lock_status &&
+  test_must_fail grep "Owner:" lock_status
+'
+
 test_expect_success "remove Datastore.StorageMax from config" '
   ipfs config Datastore.StorageMax ""
 '
@@ -59,6 +59,31 @@ test_pins_error_reporting() {
     test_must_fail ipfs pin add $EXTRA_ARGS $RANDOM_HASH 2> err &&
     grep -q "not found" err
   '
+
+  test_expect_success "'ipfs pin add $EXTRA_ARGS' with an unknown fetch priority should fail" '
+    HASH=$(echo "fetch priority" | ipfs add -q --pin=false) &&
+    test_must_fail ipfs pin add $EXTRA_ARGS --fetch-priority=urgent $HASH 2> err &&
+    grep -q "unknown fetch priority" err
+  '
+
+  test_expect_success "'ipfs pin add $EXTRA_ARGS --fetch-priority=background' succeeds" '
+    echo "pinned $HASH recursively" >expected &&
+    ipfs pin add $EXTRA_ARGS --fetch-priority=background $HASH >actual &&
+    test_cmp expected actual &&
+    ipfs pin rm $HASH
+  '
+
+  test_expect_success "'ipfs pin add $EXTRA_ARGS --concurrency=2' succeeds" '
+    echo "pinned $HASH recursively" >expected &&
+    ipfs pin add $EXTRA_ARGS --concurrency=2 $HASH >actual &&
+    test_cmp expected actual &&
+    ipfs pin rm $HASH
+  '
+
+  test_expect_success "'ipfs pin add $EXTRA_ARGS --concurrency=0' fails" '
+    test_must_fail ipfs pin add $EXTRA_ARGS --concurrency=0 $HASH 2> err &&
+    grep -q "concurrency must be positive" err
+  '
 }
 
 test_pin_dag_init() {
@@ -106,6 +131,80 @@ test_pin_progress() {
   '
 }
 
+test_pin_ls_filter() {
+  test_expect_success "add files with different codecs" '
+    PB_HASH=$(echo "filter pb" | ipfs add -q) &&
+    RAW_HASH=$(echo "filter raw" | ipfs add -q --raw-leaves --cid-version=1)
+  '
+
+  test_expect_success "'ipfs pin ls --codec' lists the pins of the codec" '
+    ipfs pin ls --type=recursive --codec=raw -q >raw_pins &&
+    grep -q "$RAW_HASH" raw_pins &&
+    test_must_fail grep -q "$PB_HASH" raw_pins
+  '
+
+  test_expect_success "'ipfs pin ls --cid-prefix' lists the pins starting with it" '
+    ipfs pin ls --type=recursive --cid-prefix=$(echo $PB_HASH | cut -c1-8) -q >prefix_pins &&
+    grep -q "$PB_HASH" prefix_pins &&
+    test_must_fail grep -q "$RAW_HASH" prefix_pins
+  '
+
+  test_expect_success "'ipfs pin ls' fails with an unknown codec" '
+    test_must_fail ipfs pin ls --codec=nope 2>codec_err &&
+    grep -q "unknown codec" codec_err
+  '
+
+  test_expect_success "'ipfs pin ls' fails with filters and arguments" '
+    test_must_fail ipfs pin ls --codec=raw $RAW_HASH
+  '
+}
+
+test_pin_ls_long() {
+  test_expect_success "'ipfs pin ls --long' lists when and how pins were created" '
+    LONG_HASH=$(echo "pin ls long $1" | ipfs add -q) &&
+    ipfs pin ls --long $LONG_HASH >long_out &&
+    grep -E "^$LONG_HASH recursive [0-9-]+T[0-9:]+Z cli$" long_out
+  '
+
+  test_expect_success "the record of an unpinned pin is forgotten" '
+    ipfs pin rm $LONG_HASH &&
+    ipfs pin add --recursive=false $LONG_HASH &&
+    ipfs pin ls --long $LONG_HASH >long_out &&
+    grep -E "^$LONG_HASH direct [0-9-]+T[0-9:]+Z cli$" long_out
+  '
+
+  test_expect_success "'ipfs pin ls --name' lists the pins whose name matches" '
+    NAMED_HASH=$(echo "pin ls name $1" | ipfs add -q --pin=false) &&
+    ipfs pin add --name="site-$1" $NAMED_HASH &&
+    ipfs pin ls --long $NAMED_HASH >long_out &&
+    grep -E "^$NAMED_HASH recursive [0-9-]+T[0-9:]+Z cli name=site-$1$" long_out &&
+    ipfs pin ls -q --name="site-*" >name_pins &&
+    grep -q "$NAMED_HASH" name_pins &&
+    test_must_fail grep -q "$LONG_HASH" name_pins
+  '
+
+  test_expect_success "'ipfs pin ls --created-after' lists the pins created after the time" '
+    ipfs pin ls -q --created-after=2000-01-01T00:00:00Z >after_pins &&
+    grep -q "$NAMED_HASH" after_pins &&
+    ipfs pin ls -q --created-after=2100-01-01T00:00:00Z >after_pins &&
+    test_must_be_empty after_pins
+  '
+
+  test_expect_success "'ipfs pin ls' fails with an invalid creation time" '
+    test_must_fail ipfs pin ls --created-after=yesterday 2>after_err &&
+    grep -q "invalid creation time" after_err
+  '
+
+  if test "$1" = daemon; then
+    test_expect_success "the pins of other clients of the API aren't recorded as the ipfs command" '
+      API_HASH=$(echo "pin ls long api" | ipfs add -q --pin=false) &&
+      curl -sf -A "/go-ipfs/$(ipfs version -n)/" "http://$API_ADDR/api/v0/pin/add?arg=$API_HASH" &&
+      ipfs pin ls --long $API_HASH >long_out &&
+      grep -E "^$API_HASH recursive [0-9-]+T[0-9:]+Z api$" long_out
+    '
+  fi
+}
+
 test_init_ipfs
 
 test_pins
@@ -119,6 +218,9 @@ test_pin_dag --raw-leaves
 
 test_pin_progress
 
+test_pin_ls_filter
+test_pin_ls_long
+
 test_launch_ipfs_daemon --offline
 
 test_pins
@@ -132,6 +234,9 @@ test_pin_dag --raw-leaves
 
 test_pin_progress
 
+test_pin_ls_filter
+test_pin_ls_long daemon
+
 test_kill_ipfs_daemon
 
 test_done
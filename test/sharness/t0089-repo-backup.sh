@@ -0,0 +1,105 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2018 Protocol Labs
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test 'ipfs repo backup', 'ipfs repo restore' and 'ipfs repo export', 'ipfs repo import'"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_launch_ipfs_daemon
+
+test_expect_success "add and pin some files" '
+  echo "pinned content" > pinned &&
+  echo "unpinned content" > unpinned &&
+  PINNED=$(ipfs add -q pinned) &&
+  UNPINNED=$(ipfs add -q --pin=false unpinned)
+'
+
+test_expect_success "'ipfs repo backup' succeeds while the daemon runs" '
+  ipfs repo backup full.backup > backup_out &&
+  echo "Saved the backup to full.backup" > backup_exp &&
+  test_cmp backup_exp backup_out
+'
+
+test_expect_success "'ipfs repo backup --keys --pinned-only' succeeds" '
+  ipfs repo backup --keys --pinned-only pinned.backup
+'
+
+test_expect_success "the backup without --keys holds no private key" '
+  test_must_fail grep -qF "$(ipfs config Identity.PrivKey)" full.backup
+'
+
+test_expect_success "'ipfs repo restore' can't run on the daemon" '
+  test_must_fail ipfs repo restore full.backup 2> restore_err &&
+  grep "daemon is running" restore_err
+'
+
+test_expect_success "'ipfs repo export' succeeds while the daemon runs" '
+  ipfs repo export pins.export /local/pins > export_out &&
+  echo "Saved the export to pins.export" > export_exp &&
+  test_cmp export_exp export_out
+'
+
+test_expect_success "'ipfs repo export' rejects invalid prefixes" '
+  test_must_fail ipfs repo export bad.export local 2> export_err &&
+  grep "prefixes start with /" export_err
+'
+
+test_kill_ipfs_daemon
+
+PEERID=$(ipfs config Identity.PeerID)
+
+test_expect_success "restore the full backup into a new repo" '
+  export IPFS_PATH="$(pwd)/restored" &&
+  ipfs init -b 1024 > /dev/null &&
+  ipfs repo restore full.backup
+'
+
+test_expect_success "the restored repo has the pins and the blocks" '
+  ipfs pin ls --type=recursive | grep "$PINNED" &&
+  ipfs cat "$PINNED" > pinned_out &&
+  test_cmp pinned pinned_out &&
+  ipfs cat "$UNPINNED" > unpinned_out &&
+  test_cmp unpinned unpinned_out
+'
+
+test_expect_success "the restored repo kept its own identity" '
+  test "$(ipfs config Identity.PeerID)" != "$PEERID"
+'
+
+test_expect_success "restore the pinned-only backup into a new repo" '
+  export IPFS_PATH="$(pwd)/restored-pinned" &&
+  ipfs init -b 1024 > /dev/null &&
+  ipfs repo restore pinned.backup > restore_out &&
+  grep "Restored the identity of the node" restore_out
+'
+
+test_expect_success "the restored repo has the identity and only the pinned blocks" '
+  test "$(ipfs config Identity.PeerID)" = "$PEERID" &&
+  ipfs cat "$PINNED" > pinned_out &&
+  test_cmp pinned pinned_out &&
+  test_must_fail ipfs cat --offline "$UNPINNED"
+'
+
+test_expect_success "import the pins into a new repo" '
+  export IPFS_PATH="$(pwd)/imported" &&
+  ipfs init -b 1024 > /dev/null &&
+  ipfs repo import pins.export > import_out &&
+  grep "Imported [0-9]* datastore entries" import_out
+'
+
+test_expect_success "the new repo has the pins but not their blocks" '
+  ipfs pin ls --type=recursive | grep "$PINNED" &&
+  test_must_fail ipfs cat --offline "$PINNED"
+'
+
+test_expect_success "'ipfs repo import' rejects backups" '
+  test_must_fail ipfs repo import full.backup 2> import_err &&
+  grep "not an export" import_err
+'
+
+test_done
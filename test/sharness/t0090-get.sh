@@ -112,6 +112,37 @@ test_get_cmd() {
     rm -r "$HASH2"
   '
 
+  test_expect_success "ipfs get without --continue records no progress" '
+    ipfs get "$HASH2" &&
+    test ! -e "$HASH2".ipfs-get &&
+    rm -r "$HASH2"
+  '
+
+  test_expect_success "ipfs get --continue continues an interrupted download" '
+    mkdir -p "$HASH2"/b &&
+    printf "Hello" >"$HASH2"/b/c &&
+    printf "{\"Path\":\"%s\",\"Files\":{\"b/c\":{\"Offset\":5,\"Hash\":\"%s\"}}}" "$HASH2" \
+      185f8db32271fe25f561a6fc938b2e264306ec304eda518007d1764826381969 >"$HASH2".ipfs-get &&
+    ipfs get --continue "$HASH2" >actual &&
+    printf "%s\n" "Continuing to save file(s) to $HASH2" >expected &&
+    test_cmp expected actual &&
+    test_cmp dir/a "$HASH2"/a &&
+    test_cmp dir/b/c "$HASH2"/b/c &&
+    test ! -e "$HASH2".ipfs-get &&
+    rm -r "$HASH2"
+  '
+
+  test_expect_success "ipfs get --continue refuses the download of another path" '
+    printf "{\"Path\":\"/ipfs/QmOther\",\"Files\":{}}" >"$HASH2".ipfs-get &&
+    test_must_fail ipfs get --continue "$HASH2" 2>actual &&
+    grep "is of /ipfs/QmOther" actual &&
+    rm -f "$HASH2".ipfs-get
+  '
+
+  test_expect_success "ipfs get --continue can't be used with --archive" '
+    test_must_fail ipfs get --continue -a "$HASH2"
+  '
+
   test_expect_success "ipfs get -a -C succeeds (directory)" '
     ipfs get "$HASH2" -a -C >actual
   '
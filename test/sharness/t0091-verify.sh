@@ -0,0 +1,56 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs verify"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_verify_cmd() {
+  test_expect_success "make a directory" '
+    rm -rf dir &&
+    mkdir -p dir/sub &&
+    echo "hello" >dir/a &&
+    random 300000 7 >dir/sub/big &&
+    echo "world" >dir/sub/b &&
+    HASH=$(ipfs add -r -Q dir)
+  '
+
+  test_expect_success "ipfs verify succeeds on the same files" '
+    ipfs verify -r "$HASH" dir >actual &&
+    test_must_be_empty actual
+  '
+
+  test_expect_success "ipfs verify succeeds on a file" '
+    ipfs verify "$HASH/a" dir/a
+  '
+
+  test_expect_success "change the directory" '
+    echo "changed" >dir/a &&
+    rm dir/sub/b &&
+    echo "extra" >dir/extra
+  '
+
+  test_expect_success "ipfs verify reports the paths differing" '
+    test_expect_code 1 ipfs verify -r "$HASH" dir >actual 2>errors &&
+    printf "%-8s %s\n" content a extra extra missing sub/b >expected &&
+    test_cmp expected actual &&
+    grep "3 paths differ" errors
+  '
+
+  test_expect_success "ipfs verify --enc=json works" '
+    test_expect_code 1 ipfs verify --enc=json -r "$HASH" dir >actual &&
+    grep "\"Path\":\"sub/b\",\"Reason\":\"missing\"" actual
+  '
+}
+
+test_verify_cmd
+
+test_launch_ipfs_daemon
+test_verify_cmd
+test_kill_ipfs_daemon
+
+test_done
@@ -90,6 +90,37 @@ test_expect_success "publish an explicit node ID as key name looks good" '
   test_cmp expected_node_id_publish actual_node_id_publish
 '
 
+# publish under several keys
+
+test_expect_success "'ipfs name publish --key=self,keyname <hash>' succeeds" '
+  ipfs name publish --key=self,keyname "/ipfs/$HASH_WELCOME_DOCS" | sort >actual_multi_publish
+'
+
+test_expect_success "publish under several keys looks good" '
+  {
+    echo "Published to ${PEERID}: /ipfs/$HASH_WELCOME_DOCS" &&
+    echo "Published to ${NEWID}: /ipfs/$HASH_WELCOME_DOCS"
+  } | sort >expected_multi_publish &&
+  test_cmp expected_multi_publish actual_multi_publish
+'
+
+test_expect_success "'ipfs name resolve' of the other key succeeds" '
+  ipfs name resolve "${NEWID}" >output &&
+  printf "/ipfs/%s\n" "$HASH_WELCOME_DOCS" >expected2 &&
+  test_cmp expected2 output
+'
+
+test_expect_success "publish under several keys fails with an unknown key" '
+  test_expect_code 1 ipfs name publish --key=self,nosuchkey "/ipfs/$HASH_WELCOME_DOCS" 2>multi_err &&
+  grep "nosuchkey" multi_err
+'
+
+test_expect_success "publish under the same key twice publishes once" '
+  ipfs name publish --key=self,${PEERID} "/ipfs/$HASH_WELCOME_DOCS" >actual_dup_publish &&
+  echo "Published to ${PEERID}: /ipfs/$HASH_WELCOME_DOCS" >expected_dup_publish &&
+  test_cmp expected_dup_publish actual_dup_publish
+'
+
 
 # test publishing nothing
 
@@ -0,0 +1,70 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs publish-site"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "make a site and a key" '
+  mkdir site &&
+  echo "hello" > site/index.html &&
+  SITE_KEY=$(ipfs key gen --type=rsa --size=2048 site)
+'
+
+test_expect_success "'ipfs publish-site' succeeds" '
+  SITE1=$(ipfs add -r -Q -n site) &&
+  ipfs publish-site -r site --key=site > publish_out
+'
+
+test_expect_success "publish-site output looks good" '
+  echo "Published /ipfs/$SITE1 to $SITE_KEY" > expected &&
+  test_cmp expected publish_out
+'
+
+test_expect_success "the site is pinned, in MFS and published" '
+  ipfs pin ls --type=recursive -q | grep $SITE1 &&
+  echo $SITE1 > expected &&
+  ipfs files stat --hash /sites/site > mfs_out &&
+  test_cmp expected mfs_out &&
+  echo /ipfs/$SITE1 > expected &&
+  ipfs name resolve $SITE_KEY > resolve_out &&
+  test_cmp expected resolve_out
+'
+
+test_expect_success "publishing a new version succeeds" '
+  echo "hello again" > site/index.html &&
+  SITE2=$(ipfs add -r -Q -n site) &&
+  ipfs publish-site -r site --key=site > publish_out
+'
+
+test_expect_success "new version output looks good" '
+  echo "Published /ipfs/$SITE2 to $SITE_KEY" > expected &&
+  echo "Unpinned previous version /ipfs/$SITE1" >> expected &&
+  test_cmp expected publish_out
+'
+
+test_expect_success "the previous version is unpinned" '
+  ipfs pin ls --type=recursive -q > pins &&
+  grep $SITE2 pins &&
+  test_expect_code 1 grep $SITE1 pins &&
+  echo /ipfs/$SITE2 > expected &&
+  ipfs name resolve $SITE_KEY > resolve_out &&
+  test_cmp expected resolve_out
+'
+
+test_expect_success "publish-site keeps the site at --mfs-path" '
+  ipfs publish-site -r site --key=site --mfs-path=/www/blog &&
+  echo $SITE2 > expected &&
+  ipfs files stat --hash /www/blog > mfs_out &&
+  test_cmp expected mfs_out
+'
+
+test_expect_success "publish-site fails on files" '
+  test_must_fail ipfs publish-site site/index.html --key=site
+'
+
+test_done
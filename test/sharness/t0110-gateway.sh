@@ -52,6 +52,39 @@ test_expect_success "GET IPFS directory file output looks good" '
   test_cmp dir/test actual
 '
 
+test_expect_success "GET IPFS path with format=raw succeeds" '
+  curl -sfo actual "http://127.0.0.1:$port/ipfs/$HASH2?format=raw" &&
+  ipfs block get "$HASH2" >expected_block
+'
+
+test_expect_success "GET IPFS path with format=raw returns the block" '
+  test_cmp expected_block actual
+'
+
+test_expect_success "add a file with its MIME type" '
+  echo "body { color: red; }" >style.css &&
+  HASH_MIME=$(ipfs add -q --mime-type style.css)
+'
+
+test_expect_success "GET a file added with its MIME type uses it" '
+  curl -sfD headers -o actual "http://127.0.0.1:$port/ipfs/$HASH_MIME" &&
+  grep -i "Content-Type: text/css" headers &&
+  test_cmp style.css actual
+'
+
+test_expect_success "ipfs get a file added with its MIME type succeeds" '
+  ipfs get -o got.css "$HASH_MIME" &&
+  test_cmp style.css got.css
+'
+
+test_expect_success "add a directory of files with their MIME type" '
+  mkdir -p mimedir &&
+  cp style.css mimedir/ &&
+  HASH_MIMEDIR=$(ipfs add -rQ --mime-type mimedir) &&
+  ipfs cat "$HASH_MIMEDIR/style.css" >actual &&
+  test_cmp style.css actual
+'
+
 test_expect_success "GET IPFS non existent file returns code expected (404)" '
   test_curl_resp_http_code "http://127.0.0.1:$port/ipfs/$HASH2/pleaseDontAddMe" "HTTP/1.1 404 Not Found"
 '
@@ -0,0 +1,58 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2018 The IPFS Authors
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test content blocking with denylists"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "add test content" '
+  mkdir -p dir/sub &&
+  echo "blocked" >blocked.txt &&
+  echo "allowed" >dir/allowed.txt &&
+  echo "secret" >dir/sub/secret.txt &&
+  BLOCKED=$(ipfs add -q blocked.txt) &&
+  DIR=$(ipfs add -r -Q dir)
+'
+
+test_expect_success "configure denylist" '
+  ipfs config --json Denylist.Files "[\"denylist.txt\"]" &&
+  ipfs config Denylist.ReloadInterval 100ms &&
+  echo "$BLOCKED" >"$IPFS_PATH/denylist.txt"
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "blocked CID returns 410" '
+  test_curl_resp_http_code "http://$GWAY_ADDR/ipfs/$BLOCKED" "HTTP/1.1 410 Gone"
+'
+
+test_expect_success "ipfs cat refuses blocked CID" '
+  test_must_fail ipfs cat "$BLOCKED" 2>cat_err &&
+  grep "blocked" cat_err
+'
+
+test_expect_success "unrelated content is served" '
+  test_curl_resp_http_code "http://$GWAY_ADDR/ipfs/$DIR/allowed.txt" "HTTP/1.1 200 OK"
+'
+
+test_expect_success "denylist is reloaded when it changes" '
+  echo "/ipfs/$DIR/sub" >>"$IPFS_PATH/denylist.txt" &&
+  go-sleep 500ms &&
+  test_curl_resp_http_code "http://$GWAY_ADDR/ipfs/$DIR/sub/secret.txt" "HTTP/1.1 410 Gone" &&
+  test_curl_resp_http_code "http://$GWAY_ADDR/ipfs/$DIR/allowed.txt" "HTTP/1.1 200 OK"
+'
+
+test_expect_success "removing the entry unblocks content" '
+  echo >"$IPFS_PATH/denylist.txt" &&
+  go-sleep 500ms &&
+  test_curl_resp_http_code "http://$GWAY_ADDR/ipfs/$BLOCKED" "HTTP/1.1 200 OK"
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -47,6 +47,21 @@ test_expect_success "cant trigger a dial backoff with swarm connect" '
   test_expect_code 1 grep "backoff" connect_out
 '
 
+test_expect_success "swarm connect --dial-errors reports each address" '
+  test_expect_code 1 ipfs swarm connect --dial-timeout=5s --dial-errors $addr 2> connect_out &&
+  grep "/ip4/127.0.0.1/tcp/9898:" connect_out
+'
+
+test_expect_success "swarm connect --transport fails without matching addresses" '
+  test_expect_code 1 ipfs swarm connect --transport=quic $addr 2> connect_out &&
+  grep "no address using the transports quic" connect_out
+'
+
+test_expect_success "swarm connect rejects unknown transports" '
+  test_expect_code 1 ipfs swarm connect --transport=foo $addr 2> connect_out &&
+  grep "unknown transport \"foo\"" connect_out
+'
+
 test_kill_ipfs_daemon
 
 announceCfg='["/ip4/127.0.0.1/tcp/4001", "/ip4/1.2.3.4/tcp/1234"]'
@@ -97,4 +112,10 @@ test_expect_success "Addresses.NoAnnounce with /ipcidr affects addresses" '
 
 test_kill_ipfs_daemon
 
+test_expect_success "swarm peerstore gc succeeds" '
+  ipfs swarm peerstore gc > gc_out &&
+  echo "removed 0 expired peers" > gc_exp &&
+  test_cmp gc_exp gc_out
+'
+
 test_done
@@ -159,6 +159,67 @@ test_expect_success "'ipfs p2p listener close' closes app numeric handlers" '
   test_must_be_empty actual
 '
 
+test_expect_success "'ipfs p2p listener open --allow' refuses other peers" '
+  ma-pipe-unidir --listen --pidFile=listener.pid recv /ip4/127.0.0.1/tcp/10101 &
+
+  test_wait_for_file 30 100ms listener.pid &&
+  ipfsi 0 p2p listener open --allow=$PEERID_0 p2p-acl /ip4/127.0.0.1/tcp/10101 &&
+  ipfsi 0 p2p listener ls --enc=json >actual &&
+  grep "\"Allowed\":\[\"$PEERID_0\"\]" actual &&
+  ipfsi 1 p2p stream dial $PEERID_0 p2p-acl /ip4/127.0.0.1/tcp/10102 &&
+  (ma-pipe-unidir send /ip4/127.0.0.1/tcp/10102 < test1.bin || true) &&
+  go-sleep 250ms &&
+  ipfsi 0 p2p stream ls > actual &&
+  test_must_be_empty actual &&
+  kill -0 $(cat listener.pid) &&
+  kill $(cat listener.pid) &&
+  rm -f listener.pid &&
+  ipfsi 0 p2p listener close p2p-acl
+'
+
+test_expect_success "'ipfs p2p listener open' checks its options" '
+  test_must_fail ipfsi 0 p2p listener open --allow=notapeer p2p-bad /ip4/127.0.0.1/tcp/10101 &&
+  test_must_fail ipfsi 0 p2p listener open --idle-timeout=soon p2p-bad /ip4/127.0.0.1/tcp/10101 &&
+  test_must_fail ipfsi 0 p2p listener open --max-streams=-1 p2p-bad /ip4/127.0.0.1/tcp/10101
+'
+
+test_expect_success "Setup: stream with an idle timeout" '
+  ma-pipe-unidir --listen --pidFile=listener.pid recv /ip4/127.0.0.1/tcp/10101 &
+
+  ipfsi 0 p2p listener open --idle-timeout=2s --max-streams=1 p2p-idle /ip4/127.0.0.1/tcp/10101 &&
+  ipfsi 1 p2p stream dial $PEERID_0 p2p-idle /ip4/127.0.0.1/tcp/10102 &&
+  ma-pipe-unidir --pidFile=client.pid recv /ip4/127.0.0.1/tcp/10102 &
+
+  test_wait_for_file 30 100ms listener.pid &&
+  test_wait_for_file 30 100ms client.pid
+'
+
+test_expect_success "'ipfs p2p stream ls --stats' prints the traffic" '
+  ipfsi 0 p2p stream ls --stats > actual &&
+  grep "/p2p/p2p-idle .* $PEERID_1 0 0 " actual
+'
+
+test_expect_success "idle streams are closed" '
+  go-sleep 4s &&
+  ipfsi 0 p2p stream ls > actual &&
+  test_must_be_empty actual &&
+  ipfsi 0 p2p listener close p2p-idle
+'
+
+test_expect_success "'ipfs p2p listener open' forwards to unix sockets and by server name" '
+  ipfsi 0 p2p listener open --sni=a.example.com=/unix/tmp/a.sock p2p-tls "/unix$(pwd)/default.sock" &&
+  ipfsi 0 p2p listener ls > actual &&
+  grep "^/unix$(pwd)/default.sock /p2p/p2p-tls" actual &&
+  ipfsi 0 p2p listener ls --enc=json > actual &&
+  grep "\"SNIRoutes\":{\"a.example.com\":\"/unix/tmp/a.sock\"}" actual &&
+  ipfsi 0 p2p listener close p2p-tls
+'
+
+test_expect_success "'ipfs p2p listener open --sni' checks the routes" '
+  test_must_fail ipfsi 0 p2p listener open --sni=a.example.com p2p-bad /ip4/127.0.0.1/tcp/10101 &&
+  test_must_fail ipfsi 0 p2p listener open --sni=a.example.com=notanaddr p2p-bad /ip4/127.0.0.1/tcp/10101
+'
+
 test_expect_success 'stop iptb' '
   iptb stop
 '
@@ -468,7 +468,31 @@ test_files_api() {
   '
 
   test_expect_success "flush root succeeds $EXTRA" '
-    ipfs files flush /
+    ipfs files flush / >flush_out
+  '
+
+  test_expect_success "flush outputs the root hash $EXTRA" '
+    ipfs files stat --hash / >root_hash &&
+    test_cmp root_hash flush_out
+  '
+
+  test_expect_success "flush of a path outputs the root hash $EXTRA" '
+    ipfs files flush /cats >flush_cats_out &&
+    test_cmp root_hash flush_cats_out
+  '
+
+  test_expect_success "write --flush=false is durable after flush $EXTRA" '
+    echo "unflushed" | ipfs files write --create --flush=false /cats/unflushed &&
+    FLUSHED_ROOT=$(ipfs files flush /cats) &&
+    ipfs ls $FLUSHED_ROOT/cats | grep -q unflushed &&
+    ipfs files rm /cats/unflushed
+  '
+
+  test_expect_success "write --flush=false to the root is durable after flush $EXTRA" '
+    echo "unflushed" | ipfs files write --create --flush=false /unflushed &&
+    FLUSHED_ROOT=$(ipfs files flush /) &&
+    ipfs ls $FLUSHED_ROOT | grep -q unflushed &&
+    ipfs files rm /unflushed
   '
 
   # test mv
@@ -73,4 +73,23 @@ test_expect_success "make sure directory contents are not removed" '
   ipfs cat $FILE_UNPINNED
 '
 
+test_expect_success "ephemeral add is kept by gc" '
+  echo "ephemeral" > ephemeral.txt &&
+  EPHEMERAL_HASH=$(ipfs add -q --ephemeral=2s ephemeral.txt) &&
+  test_must_fail ipfs pin ls $EPHEMERAL_HASH &&
+  ipfs repo gc &&
+  ipfs cat $EPHEMERAL_HASH
+'
+
+test_expect_success "ephemeral add is removed by gc once expired" '
+  sleep 3 &&
+  ipfs repo gc &&
+  test_must_fail ipfs cat $EPHEMERAL_HASH
+'
+
+test_expect_success "invalid ephemeral duration fails" '
+  test_must_fail ipfs add --ephemeral=soon ephemeral.txt 2>ephemeral_err &&
+  grep "invalid ephemeral duration" ephemeral_err
+'
+
 test_done
@@ -0,0 +1,60 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs files export and import"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create an MFS tree" '
+  ipfs files mkdir -p /photos/2018 &&
+  echo "cat" | ipfs files write --create /photos/2018/cat.txt &&
+  echo "notes" | ipfs files write --create /notes.txt &&
+  ipfs files stat --hash / >root_hash &&
+  ipfs files stat --hash /photos >photos_hash
+'
+
+test_expect_success "'ipfs files export' writes the tree" '
+  ipfs files export / >files.car &&
+  ipfs files export /photos >photos.car
+'
+
+test_expect_success "the exported files are complete" '
+  ipfs car verify files.car &&
+  ipfs car stat files.car >car_stat &&
+  grep "$(cat root_hash)" car_stat
+'
+
+test_expect_success "'ipfs files import' to an existing path fails" '
+  test_must_fail ipfs files import /photos photos.car &&
+  test_must_fail ipfs files import / files.car
+'
+
+test_expect_success "'ipfs files import' restores the tree on a new repo" '
+  export IPFS_PATH="$(pwd)/.ipfs-import" &&
+  ipfs init --bits=1024 --profile=test >/dev/null &&
+  ipfs files import / files.car &&
+  ipfs files stat --hash / >imported_hash &&
+  test_cmp root_hash imported_hash &&
+  ipfs files read /photos/2018/cat.txt >cat_out &&
+  echo "cat" >cat_exp &&
+  test_cmp cat_exp cat_out
+'
+
+test_expect_success "'ipfs files import' puts a subtree at a path" '
+  ipfs files import /restored photos.car >import_out &&
+  grep "imported $(cat photos_hash)" import_out &&
+  ipfs files stat --hash /restored >restored_hash &&
+  test_cmp photos_hash restored_hash
+'
+
+test_expect_success "'ipfs files import' fails on incomplete car files" '
+  head -c 100 files.car >truncated.car &&
+  test_must_fail ipfs files import /truncated truncated.car &&
+  test_must_fail ipfs files stat /truncated
+'
+
+test_done
@@ -0,0 +1,68 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the replication of the MFS tree between nodes"
+
+. lib/test-lib.sh
+
+num_nodes=3
+
+test_expect_success "set up an iptb cluster" '
+  iptb init -n $num_nodes -p 0 -f --bootstrap=none
+'
+
+test_expect_success "node 0 replicates to nodes 1 and 2, only 1 accepts it" '
+  PRIMARY_ID=$(iptb get id 0) &&
+  REPLICA_ID=$(iptb get id 1) &&
+  OTHER_ID=$(iptb get id 2) &&
+  ipfsi 0 config --json Replication.Replicas "[\"$REPLICA_ID\", \"$OTHER_ID\"]" &&
+  ipfsi 1 config --json Replication.Primaries "[\"$PRIMARY_ID\"]"
+'
+
+startup_cluster $num_nodes
+
+wait_for_replica() {
+  for i in $(test_seq 1 30); do
+    ipfsi 1 files read "/replicas/$PRIMARY_ID/$1" >replica_out 2>/dev/null &&
+      test_cmp "$2" replica_out >/dev/null && return 0
+    sleep 1
+  done
+  return 1
+}
+
+test_expect_success "write a file on the primary" '
+  echo "first" >first &&
+  ipfsi 0 files write --create /notes.txt <first
+'
+
+test_expect_success "the replica gets the tree of the primary" '
+  wait_for_replica notes.txt first
+'
+
+test_expect_success "the replica gets the changes of the primary" '
+  echo "second" >second &&
+  ipfsi 0 files write --truncate /notes.txt <second &&
+  wait_for_replica notes.txt second
+'
+
+test_expect_success "'ipfs files replicas' shows the state of the replicas" '
+  ipfsi 0 files replicas >replicas_out &&
+  grep "^$REPLICA_ID  *Qm" replicas_out &&
+  grep "^$OTHER_ID .*not a primary of this node" replicas_out
+'
+
+test_expect_success "the node which isn't a replica has no copy" '
+  test_must_fail ipfsi 2 files stat /replicas
+'
+
+test_expect_success "'ipfs files replicas' fails without replicas" '
+  test_must_fail ipfsi 1 files replicas
+'
+
+test_expect_success "shut down iptb" '
+  iptb stop
+'
+
+test_done
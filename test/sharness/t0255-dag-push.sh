@@ -0,0 +1,60 @@
+#!/usr/bin/env bash
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs dag push"
+
+. lib/test-lib.sh
+
+num_nodes=3
+
+test_expect_success "set up an iptb cluster" '
+  iptb init -n $num_nodes -p 0 -f --bootstrap=none
+'
+
+test_expect_success "node 1 accepts the DAGs of node 0" '
+  PUSHER_ID=$(iptb get id 0) &&
+  TARGET_ID=$(iptb get id 1) &&
+  OTHER_ID=$(iptb get id 2) &&
+  ipfsi 1 config --json Replication.AllowPush "[\"$PUSHER_ID\"]"
+'
+
+startup_cluster $num_nodes
+
+test_expect_success "add a directory on node 0" '
+  mkdir -p site/css &&
+  echo "index" >site/index.html &&
+  echo "body {}" >site/css/main.css &&
+  SITE_HASH=$(ipfsi 0 add -r -Q site)
+'
+
+test_expect_success "'ipfs dag push --pin' pushes and pins the DAG" '
+  ipfsi 0 dag push --pin $SITE_HASH $TARGET_ID >push_out &&
+  echo "pushed and pinned $SITE_HASH to $TARGET_ID" >push_exp &&
+  test_cmp push_exp push_out &&
+  ipfsi 1 pin ls --type=recursive $SITE_HASH
+'
+
+test_expect_success "'ipfs dag push' without --pin doesn't pin" '
+  echo "other" | ipfsi 0 add -Q >other_hash &&
+  ipfsi 0 dag push $(cat other_hash) $TARGET_ID >push_out &&
+  grep "^pushed $(cat other_hash)" push_out &&
+  test_must_fail ipfsi 1 pin ls $(cat other_hash)
+'
+
+test_expect_success "nodes refuse the DAGs of the nodes not allowed" '
+  echo "refused" | ipfsi 2 add -Q >refused_hash &&
+  test_must_fail ipfsi 2 dag push $(cat refused_hash) $TARGET_ID 2>push_err &&
+  grep "not allowed to push to this node" push_err
+'
+
+test_expect_success "nodes without allowed pushers don't accept pushes" '
+  test_must_fail ipfsi 0 dag push $SITE_HASH $OTHER_ID
+'
+
+test_expect_success "shut down iptb" '
+  iptb stop
+'
+
+test_done
@@ -59,6 +59,19 @@ test_expect_success "sharded and unsharded output look the same" '
   test_cmp sharded_out unsharded_out
 '
 
+test_expect_success "ipfs dir lookup finds the same entry in both" '
+  ipfs dir lookup "$SHARDED" file42 > sharded_lookup &&
+  ipfs dir lookup "$UNSHARDED" file42 > unsharded_lookup &&
+  test_cmp sharded_lookup unsharded_lookup &&
+  grep "file42$" unsharded_out > lookup_exp &&
+  test_cmp lookup_exp sharded_lookup
+'
+
+test_expect_success "ipfs dir lookup fails on a missing entry" '
+  test_expect_code 1 ipfs dir lookup "$SHARDED" file2001 2> lookup_err &&
+  grep "no entry named \"file2001\"" lookup_err
+'
+
 test_expect_success "ipfs cat error output the same" '
   test_expect_code 1 ipfs cat "$SHARDED" 2> sharded_err &&
   test_expect_code 1 ipfs cat "$UNSHARDED" 2> unsharded_err &&
@@ -0,0 +1,80 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2018 The IPFS Authors
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test cid commands"
+
+. lib/test-lib.sh
+
+# note: all "ipfs cid" commands should work without requiring a repo
+
+CIDv0="QmUJPTFZnR2CPGAzmfdYPghgrFtYFB6pf1BqMvqfiPDam8"
+CIDv1="bafybeicysg23kiwv34eg2d7qweipxwosdo2py4ldv42nbauguluen5v6am"
+CIDb16="f017012205891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+
+test_expect_success "cid base32 works" '
+  echo $CIDv1 >expected &&
+  ipfs cid base32 $CIDv0 >actual &&
+  test_cmp actual expected
+'
+
+test_expect_success "cid base32 works from stdin" '
+  echo $CIDv0 | ipfs cid base32 >actual &&
+  test_cmp actual expected
+'
+
+test_expect_success "cid format -v 1 -b base16 works" '
+  echo $CIDb16 >expected &&
+  ipfs cid format -v 1 -b base16 $CIDv0 >actual &&
+  test_cmp actual expected
+'
+
+test_expect_success "cid format -v 0 converts back to CIDv0" '
+  echo $CIDv0 >expected &&
+  ipfs cid format -v 0 $CIDb16 >actual &&
+  test_cmp actual expected
+'
+
+test_expect_success "cid format -f works" '
+  echo "cidv1 protobuf sha2-256 32 base32" >expected &&
+  ipfs cid format -f "%v %c %h %L %b" -b base32 $CIDv0 >actual &&
+  test_cmp actual expected
+'
+
+test_expect_success "cid format -f %P works" '
+  echo "cidv0-protobuf-sha2-256-32" >expected &&
+  ipfs cid format -f "%P" $CIDv0 >actual &&
+  test_cmp actual expected
+'
+
+test_expect_success "cid format fails on -v 0 with a multibase" '
+  test_must_fail ipfs cid format -v 0 -b base32 $CIDv0
+'
+
+test_expect_success "cid format reports invalid CIDs" '
+  echo $CIDv0 >expected &&
+  test_must_fail ipfs cid format $CIDv0 invalid >actual 2>errors &&
+  test_cmp actual expected &&
+  grep "^invalid: " errors
+'
+
+test_expect_success "cid bases lists base32" '
+  ipfs cid bases >bases &&
+  grep "^base32$" bases &&
+  ipfs cid bases --prefix >bases &&
+  grep "^b  base32$" bases
+'
+
+test_expect_success "cid codecs lists protobuf" '
+  ipfs cid codecs --numeric >codecs &&
+  grep "^  112  protobuf$" codecs
+'
+
+test_expect_success "cid hashes lists sha2-256" '
+  ipfs cid hashes --numeric >hashes &&
+  grep "^   18  sha2-256$" hashes
+'
+
+test_done
@@ -0,0 +1,62 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2018 The IPFS Authors
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test multibase commands"
+
+. lib/test-lib.sh
+
+# note: "ipfs multibase" commands should work without requiring a repo
+
+test_expect_success "multibase encode defaults to base64url" '
+  echo uaGVsbG8 >expected &&
+  printf hello | ipfs multibase encode >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "multibase encode -b base16 works" '
+  echo f68656c6c6f >expected &&
+  printf hello | ipfs multibase encode -b base16 >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "multibase encode accepts base prefixes" '
+  printf hello | ipfs multibase encode -b f >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "multibase encode reads files" '
+  printf hello >hello.txt &&
+  ipfs multibase encode -b base16 hello.txt >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "multibase encode fails on unknown bases" '
+  test_must_fail ipfs multibase encode -b base99 hello.txt 2>err &&
+  grep "unknown multibase" err
+'
+
+test_expect_success "multibase decode works" '
+  echo f68656c6c6f | ipfs multibase decode >actual &&
+  test_cmp hello.txt actual
+'
+
+test_expect_success "multibase decode fails on invalid input" '
+  echo "?nope" | test_must_fail ipfs multibase decode
+'
+
+test_expect_success "multibase transcode works" '
+  echo bnbswy3dp >expected &&
+  echo f68656c6c6f | ipfs multibase transcode -b base32 >actual &&
+  test_cmp expected actual
+'
+
+test_expect_success "multibase round trips binary data" '
+  printf "\000\001\377\n" >binary &&
+  ipfs multibase encode -b base58btc binary | ipfs multibase decode >actual &&
+  test_cmp binary actual
+'
+
+test_done
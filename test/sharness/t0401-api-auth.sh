@@ -0,0 +1,91 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2018 The IPFS Authors
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test API token authentication"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create tokens offline" '
+  ipfs auth token create --scope=read reader >reader_out &&
+  ipfs auth token create --scope=admin root >root_out &&
+  READ_TOKEN=$(tail -n1 reader_out) &&
+  ADMIN_TOKEN=$(tail -n1 root_out)
+'
+
+test_expect_success "token ls lists tokens without secrets" '
+  printf "reader: read\nroot: admin\n" >expected_ls &&
+  ipfs auth token ls >actual_ls &&
+  test_cmp expected_ls actual_ls
+'
+
+test_expect_success "only hashes are stored in the config" '
+  test_must_fail grep "$READ_TOKEN" "$IPFS_PATH/config"
+'
+
+test_expect_success "token create rejects unknown scopes" '
+  test_must_fail ipfs auth token create --scope=root bad
+'
+
+test_expect_success "remove tokens so the daemon can start" '
+  ipfs auth token rm reader &&
+  ipfs auth token rm root
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "create tokens on a running daemon" '
+  ADMIN_TOKEN=$(ipfs auth token create --scope=admin root | tail -n1) &&
+  READ_TOKEN=$(IPFS_API_TOKEN=$ADMIN_TOKEN ipfs auth token create --scope=read reader | tail -n1)
+'
+
+test_expect_success "requests without a token are rejected" '
+  curl -s -o /dev/null -w "%{http_code}" -X POST "http://$API_ADDR/api/v0/id" >actual &&
+  echo -n 401 >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "read token can run read commands" '
+  curl -s -o /dev/null -w "%{http_code}" -X POST -H "Authorization: Bearer $READ_TOKEN" "http://$API_ADDR/api/v0/id" >actual &&
+  echo -n 200 >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "read token can't change the config" '
+  curl -s -o /dev/null -w "%{http_code}" -X POST -H "Authorization: Bearer $READ_TOKEN" "http://$API_ADDR/api/v0/config?arg=Foo&arg=bar" >actual &&
+  echo -n 403 >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "cli uses IPFS_API_TOKEN" '
+  test_must_fail ipfs id &&
+  IPFS_API_TOKEN=$ADMIN_TOKEN ipfs id
+'
+
+test_expect_success "revoked tokens are rejected" '
+  IPFS_API_TOKEN=$ADMIN_TOKEN ipfs auth token rm reader &&
+  curl -s -o /dev/null -w "%{http_code}" -X POST -H "Authorization: Bearer $READ_TOKEN" "http://$API_ADDR/api/v0/id" >actual &&
+  echo -n 401 >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "API.Authorizations denies commands to every caller" '
+  IPFS_API_TOKEN=$ADMIN_TOKEN ipfs config --json API.Authorizations "{\"*\": {\"Deny\": [\"repo/gc\"]}}" &&
+  curl -s -o /dev/null -w "%{http_code}" -X POST -H "Authorization: Bearer $ADMIN_TOKEN" "http://$API_ADDR/api/v0/repo/gc" >actual &&
+  echo -n 403 >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "API.Authorizations leaves other commands alone" '
+  curl -s -o /dev/null -w "%{http_code}" -X POST -H "Authorization: Bearer $ADMIN_TOKEN" "http://$API_ADDR/api/v0/repo/stat" >actual &&
+  echo -n 200 >expected &&
+  test_cmp expected actual
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,70 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2018 The IPFS Authors
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test API origin verification"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "configure allowed origins" '
+  ipfs config --json API.Origins "[
+    {\"Origin\": \"https://webui.example.com\", \"AllowCredentials\": true},
+    {\"Origin\": \"chrome-extension://*\", \"ReadOnly\": true}
+  ]"
+'
+
+test_launch_ipfs_daemon
+
+api_code() {
+  curl -s -o /dev/null -w "%{http_code}" -X POST "$@"
+}
+
+test_expect_success "unknown origins are rejected" '
+  api_code -H "Origin: https://evil.com" "http://$API_ADDR/api/v0/version" >actual &&
+  echo -n 403 >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "unknown referers are rejected" '
+  api_code -H "Referer: https://evil.com/page" "http://$API_ADDR/api/v0/version" >actual &&
+  echo -n 403 >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "configured origin is allowed with credentials" '
+  curl -s -D headers -o /dev/null -X POST -H "Origin: https://webui.example.com" "http://$API_ADDR/api/v0/version" &&
+  grep "Access-Control-Allow-Origin: https://webui.example.com" headers &&
+  grep "Access-Control-Allow-Credentials: true" headers
+'
+
+test_expect_success "read-only origin can run read-only commands" '
+  api_code -H "Origin: chrome-extension://abcdef" "http://$API_ADDR/api/v0/version" >actual &&
+  echo -n 200 >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "read-only origin can't run state-changing commands" '
+  api_code -H "Origin: chrome-extension://abcdef" "http://$API_ADDR/api/v0/config?arg=Foo&arg=bar" >actual &&
+  echo -n 403 >expected &&
+  test_cmp expected actual
+'
+
+test_expect_success "preflight for a configured origin succeeds" '
+  curl -s -D headers -o /dev/null -X OPTIONS -H "Origin: https://webui.example.com" -H "Access-Control-Request-Headers: X-Foo" "http://$API_ADDR/api/v0/add" &&
+  grep "HTTP/1.1 200 OK" headers &&
+  grep "Access-Control-Allow-Headers: X-Foo" headers
+'
+
+test_expect_success "requests without origin are unaffected" '
+  api_code "http://$API_ADDR/api/v0/version" >actual &&
+  echo -n 200 >expected &&
+  test_cmp expected actual
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,64 @@
+// Package cidset provides the sets of visited CIDs of DAG walks, kept either
+// in memory or, for DAGs too large for that, on disk.
+//
+// The set of a walk is chosen through its context: walks call New with their
+// context, which returns an in-memory set unless WithFactory set another
+// Factory on the context.
+package cidset
+
+import (
+	"context"
+
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+)
+
+// Set is a set of CIDs.
+type Set interface {
+	// Add adds c to the set.
+	Add(c *cid.Cid)
+	// Has returns whether c is in the set.
+	Has(c *cid.Cid) bool
+	// Visit adds c to the set and returns true if it wasn't in it yet.
+	Visit(c *cid.Cid) bool
+	// Len returns the number of CIDs in the set.
+	Len() int
+
+	// Err returns the first error the set ran into. A set which ran into an
+	// error may have lost CIDs added to it, and reports the CIDs it can't
+	// look up as members.
+	Err() error
+	// Close releases the resources of the set.
+	Close() error
+}
+
+// Factory creates a set.
+type Factory func() (Set, error)
+
+// Memory is the Factory of sets kept in memory.
+func Memory() (Set, error) {
+	return memorySet{cid.NewSet()}, nil
+}
+
+type memorySet struct {
+	*cid.Set
+}
+
+func (memorySet) Err() error   { return nil }
+func (memorySet) Close() error { return nil }
+
+type factoryContextKey struct{}
+
+// WithFactory returns a context making the walks made with it keep their
+// visited CIDs in the sets made by f.
+func WithFactory(ctx context.Context, f Factory) context.Context {
+	return context.WithValue(ctx, factoryContextKey{}, f)
+}
+
+// New returns a set for a walk made with ctx.
+func New(ctx context.Context) (Set, error) {
+	f, ok := ctx.Value(factoryContextKey{}).(Factory)
+	if !ok {
+		return Memory()
+	}
+	return f()
+}
@@ -0,0 +1,176 @@
+package cidset
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	mh "gx/ipfs/QmPnFwZ2JXKnXgMw8CdBPxn7FWh6LLdjUjxV1fKHuJnkr8/go-multihash"
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dsq "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/query"
+	dssync "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/sync"
+)
+
+func testCids(t *testing.T, n int) []*cid.Cid {
+	cids := make([]*cid.Cid, n)
+	for i := range cids {
+		h, err := mh.Sum([]byte(fmt.Sprint("block ", i)), mh.SHA2_256, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cids[i] = cid.NewCidV0(h)
+	}
+	return cids
+}
+
+func countKeys(t *testing.T, d ds.Datastore) int {
+	res, err := d.Query(dsq.Query{Prefix: diskSetsKey.String(), KeysOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestDiskSet(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	s, err := OnDisk(d, 1024)()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// more than a batch, so that some are read back from the datastore
+	cids := testCids(t, 3*batchSize)
+	added, missing := cids[:2*batchSize], cids[2*batchSize:]
+	for _, c := range added {
+		if !s.Visit(c) {
+			t.Fatalf("%s visited before being added", c)
+		}
+	}
+
+	for _, c := range added {
+		if !s.Has(c) {
+			t.Fatalf("%s missing from the set", c)
+		}
+		if s.Visit(c) {
+			t.Fatalf("%s visited twice", c)
+		}
+	}
+	for _, c := range missing {
+		if s.Has(c) {
+			t.Fatalf("%s unexpectedly in the set", c)
+		}
+	}
+	if s.Len() != len(added) {
+		t.Fatalf("expected %d cids, got %d", len(added), s.Len())
+	}
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+
+	if countKeys(t, d) == 0 {
+		t.Fatal("expected the set to be written to the datastore")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countKeys(t, d); n != 0 {
+		t.Fatalf("expected the set to be removed, %d keys left", n)
+	}
+}
+
+func TestDiskSetGrowsBloom(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	s, err := newDiskSet(d, 64, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	cids := testCids(t, 4*batchSize)
+	added, missing := cids[:3*batchSize], cids[3*batchSize:]
+	for _, c := range added {
+		if !s.Visit(c) {
+			t.Fatalf("%s visited before being added", c)
+		}
+	}
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+	if len(s.bloom) != 4096 {
+		t.Fatalf("expected the bloom filter to grow to 4096 bytes, got %d", len(s.bloom))
+	}
+
+	for _, c := range added {
+		if !s.bloomHas(c) || !s.Has(c) {
+			t.Fatalf("%s missing from the set after the bloom filter grew", c)
+		}
+	}
+	for _, c := range missing {
+		if s.Has(c) {
+			t.Fatalf("%s unexpectedly in the set", c)
+		}
+	}
+}
+
+func TestOnDiskBloomSize(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	for _, test := range []struct {
+		size, initial int
+	}{
+		{0, initialBloomSize},
+		{1024, 1024},
+		{1 << 30, initialBloomSize},
+	} {
+		s, err := OnDisk(d, test.size)()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(s.(*diskSet).bloom); n != test.initial {
+			t.Errorf("bloom size %d: expected a filter of %d bytes, got %d", test.size, test.initial, n)
+		}
+		s.Close()
+	}
+}
+
+func TestRemoveDiskSets(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	s, err := OnDisk(d, 1024)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range testCids(t, 2*batchSize) {
+		s.Add(c)
+	}
+
+	if err := RemoveDiskSets(d); err != nil {
+		t.Fatal(err)
+	}
+	if n := countKeys(t, d); n != 0 {
+		t.Fatalf("expected the sets to be removed, %d keys left", n)
+	}
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(memorySet); !ok {
+		t.Fatalf("expected a memory set by default, got %T", s)
+	}
+
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	s, err = New(WithFactory(ctx, OnDisk(d, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*diskSet); !ok {
+		t.Fatalf("expected a disk set, got %T", s)
+	}
+}
@@ -0,0 +1,287 @@
+package cidset
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/fnv"
+	"sync"
+
+	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
+	ds "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore"
+	dsq "gx/ipfs/QmeiCcJfDW1GJnWUArudsv5rQsihpi4oyddPhdqo3CfX6i/go-datastore/query"
+)
+
+// DefaultBloomSize is the default size in bytes up to which the bloom filter
+// in front of a disk set grows.
+const DefaultBloomSize = 64 << 20
+
+// initialBloomSize is the size in bytes of the bloom filter of a new disk
+// set. It's doubled as the set grows, for the walks of small DAGs not to
+// allocate the filter a large one needs.
+const initialBloomSize = 64 << 10
+
+// bloomBitsPerCid is the number of bits of the bloom filter per CID of the
+// set, which keeps the false positives around 1%, over which it's grown.
+const bloomBitsPerCid = 10
+
+// bloomHashes is the number of hashes of a CID set in the bloom filter.
+const bloomHashes = 4
+
+// batchSize is the number of CIDs added before they are written.
+const batchSize = 1024
+
+// diskSetsKey is the key under which the disk sets are kept in the datastore.
+var diskSetsKey = ds.NewKey("/local/traversal")
+
+// diskSet keeps its CIDs in a datastore. A bloom filter kept in memory
+// answers most lookups of CIDs which aren't in the set, without reading the
+// datastore.
+type diskSet struct {
+	mu sync.Mutex
+
+	d        ds.Batching
+	prefix   ds.Key
+	bloom    []byte
+	maxBloom int
+
+	pending map[string]struct{}
+	n       int
+	err     error
+}
+
+// OnDisk returns the Factory of sets kept in d, each behind a bloom filter
+// growing with the set up to bloomSize bytes.
+func OnDisk(d ds.Batching, bloomSize int) Factory {
+	if bloomSize <= 0 {
+		bloomSize = DefaultBloomSize
+	}
+	initial := initialBloomSize
+	if initial > bloomSize {
+		initial = bloomSize
+	}
+	return func() (Set, error) {
+		return newDiskSet(d, initial, bloomSize)
+	}
+}
+
+func newDiskSet(d ds.Batching, bloomSize, maxBloom int) (*diskSet, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &diskSet{
+		d:        d,
+		prefix:   diskSetsKey.ChildString(hex.EncodeToString(id)),
+		bloom:    make([]byte, bloomSize),
+		maxBloom: maxBloom,
+		pending:  make(map[string]struct{}),
+	}, nil
+}
+
+// RemoveDiskSets removes the disk sets left in d by walks which didn't
+// complete, e.g. because the node crashed. No walk may be running.
+func RemoveDiskSets(d ds.Datastore) error {
+	return removePrefix(d, diskSetsKey)
+}
+
+func removePrefix(d ds.Datastore, prefix ds.Key) error {
+	res, err := d.Query(dsq.Query{Prefix: prefix.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	for e := range res.Next() {
+		if e.Error != nil {
+			return e.Error
+		}
+		if err := d.Delete(ds.RawKey(e.Key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *diskSet) key(c *cid.Cid) ds.Key {
+	return s.prefix.ChildString(c.String())
+}
+
+// bloomBits returns the bits c sets in a bloom filter of bloom, derived from
+// two hashes of its bytes.
+func bloomBits(bloom []byte, c *cid.Cid) [bloomHashes]uint64 {
+	h := fnv.New64a()
+	h.Write(c.Bytes())
+	sum := h.Sum(nil)
+	h1 := binary.BigEndian.Uint64(sum)
+	h2 := h1>>32 | h1<<32 | 1
+
+	var bits [bloomHashes]uint64
+	nbits := uint64(len(bloom)) * 8
+	for i := range bits {
+		bits[i] = (h1 + uint64(i)*h2) % nbits
+	}
+	return bits
+}
+
+func bloomAdd(bloom []byte, c *cid.Cid) {
+	for _, b := range bloomBits(bloom, c) {
+		bloom[b/8] |= 1 << (b % 8)
+	}
+}
+
+func (s *diskSet) bloomHas(c *cid.Cid) bool {
+	for _, b := range bloomBits(s.bloom, c) {
+		if s.bloom[b/8]&(1<<(b%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// growBloom doubles the bloom filter, up to maxBloom, adding the CIDs of the
+// set to it again. It must be called with the lock held. The filter is left
+// as it is on errors: it only answers fewer lookups.
+func (s *diskSet) growBloom() {
+	size := 2 * len(s.bloom)
+	if size > s.maxBloom {
+		size = s.maxBloom
+	}
+
+	s.flush()
+	if s.err != nil {
+		return
+	}
+	res, err := s.d.Query(dsq.Query{Prefix: s.prefix.String(), KeysOnly: true})
+	if err != nil {
+		s.setErr(err)
+		return
+	}
+	defer res.Close()
+
+	bloom := make([]byte, size)
+	for e := range res.Next() {
+		if e.Error != nil {
+			s.setErr(e.Error)
+			return
+		}
+		c, err := cid.Decode(ds.RawKey(e.Key).Name())
+		if err != nil {
+			s.setErr(err)
+			return
+		}
+		bloomAdd(bloom, c)
+	}
+	s.bloom = bloom
+}
+
+func (s *diskSet) setErr(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// has must be called with the lock held.
+func (s *diskSet) has(c *cid.Cid) bool {
+	if !s.bloomHas(c) {
+		return false
+	}
+	if _, ok := s.pending[c.KeyString()]; ok {
+		return true
+	}
+
+	has, err := s.d.Has(s.key(c))
+	if err != nil {
+		s.setErr(err)
+		return true
+	}
+	return has
+}
+
+// add must be called with the lock held, for a CID which isn't in the set.
+func (s *diskSet) add(c *cid.Cid) {
+	bloomAdd(s.bloom, c)
+	s.pending[c.KeyString()] = struct{}{}
+	s.n++
+
+	if len(s.pending) >= batchSize {
+		s.flush()
+	}
+	if s.n*bloomBitsPerCid > len(s.bloom)*8 && len(s.bloom) < s.maxBloom && s.err == nil {
+		s.growBloom()
+	}
+}
+
+// flush writes the pending CIDs. It must be called with the lock held.
+func (s *diskSet) flush() {
+	if len(s.pending) == 0 {
+		return
+	}
+
+	b, err := s.d.Batch()
+	if err != nil {
+		s.setErr(err)
+		return
+	}
+	for k := range s.pending {
+		c, err := cid.Cast([]byte(k))
+		if err != nil {
+			s.setErr(err)
+			return
+		}
+		if err := b.Put(s.key(c), []byte{}); err != nil {
+			s.setErr(err)
+			return
+		}
+	}
+	if err := b.Commit(); err != nil {
+		s.setErr(err)
+		return
+	}
+	s.pending = make(map[string]struct{})
+}
+
+func (s *diskSet) Add(c *cid.Cid) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.has(c) {
+		s.add(c)
+	}
+}
+
+func (s *diskSet) Has(c *cid.Cid) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.has(c)
+}
+
+func (s *diskSet) Visit(c *cid.Cid) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.has(c) {
+		return false
+	}
+	s.add(c)
+	return true
+}
+
+func (s *diskSet) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.n
+}
+
+func (s *diskSet) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close removes the CIDs of the set from the datastore.
+func (s *diskSet) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = nil
+	s.bloom = nil
+	return removePrefix(s.d, s.prefix)
+}
@@ -0,0 +1,237 @@
+package jsonconf
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Error describes a problem found at a position of a document.
+type Error struct {
+	Pos  Pos
+	Path string // dotted path of the offending key, e.g. "API.HTTPHeaders"
+	Msg  string
+
+	// UnknownKey is set when the key doesn't correspond to any field of the
+	// type, as opposed to a value of the wrong type.
+	UnknownKey bool
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("line %d, column %d: %s", e.Pos.Line, e.Pos.Column, e.Msg)
+	}
+	return fmt.Sprintf("line %d, column %d: %s: %s", e.Pos.Line, e.Pos.Column, e.Path, e.Msg)
+}
+
+// Errors is the list of problems found in a document.
+type Errors []*Error
+
+func (es Errors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Mismatches returns the errors which aren't about unknown keys.
+func (es Errors) Mismatches() Errors {
+	var out Errors
+	for _, e := range es {
+		if !e.UnknownKey {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Check verifies that data is valid JSON which can be decoded into a value
+// of type t without ignoring any key and without type mismatches. Null
+// values are accepted anywhere. It returns a *SyntaxError for malformed
+// documents, and Errors listing every problem otherwise.
+func Check(data []byte, t reflect.Type) error {
+	v, err := parse(data)
+	if err != nil {
+		return err
+	}
+
+	var errs Errors
+	check(v, t, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func check(v *value, t reflect.Type, path string, errs *Errors) {
+	if v.kind == kindNull {
+		return
+	}
+
+	mismatch := func(expected string) {
+		*errs = append(*errs, &Error{
+			Pos:  v.pos,
+			Path: path,
+			Msg:  fmt.Sprintf("expected %s, got %s", expected, v.kind),
+		})
+	}
+
+	pt := reflect.PtrTo(t)
+	if t.Implements(jsonUnmarshalerType) || pt.Implements(jsonUnmarshalerType) {
+		return
+	}
+	if t.Implements(textUnmarshalerType) || pt.Implements(textUnmarshalerType) {
+		if v.kind != kindString {
+			mismatch("string")
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		check(v, t.Elem(), path, errs)
+	case reflect.Interface:
+		// anything goes
+	case reflect.Struct:
+		if v.kind != kindObject {
+			mismatch("object")
+			return
+		}
+		fields := structFields(t)
+		for _, m := range v.members {
+			f, ok := lookupField(fields, m.key)
+			if !ok {
+				*errs = append(*errs, &Error{
+					Pos:        m.keyPos,
+					Path:       joinKey(path, m.key),
+					Msg:        "unknown key",
+					UnknownKey: true,
+				})
+				continue
+			}
+			check(m.val, f.typ, joinKey(path, m.key), errs)
+		}
+	case reflect.Map:
+		if v.kind != kindObject {
+			mismatch("object")
+			return
+		}
+		for _, m := range v.members {
+			check(m.val, t.Elem(), joinKey(path, m.key), errs)
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			if v.kind != kindString {
+				mismatch("base64 string")
+			}
+			return
+		}
+		if v.kind != kindArray {
+			mismatch("array")
+			return
+		}
+		for i, el := range v.elems {
+			check(el, t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.String:
+		if v.kind != kindString {
+			mismatch("string")
+		}
+	case reflect.Bool:
+		if v.kind != kindBool {
+			mismatch("boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.kind != kindNumber {
+			mismatch("integer")
+		} else if _, err := strconv.ParseInt(v.raw, 10, t.Bits()); err != nil {
+			mismatch("integer")
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.kind != kindNumber {
+			mismatch("non-negative integer")
+		} else if _, err := strconv.ParseUint(v.raw, 10, t.Bits()); err != nil {
+			mismatch("non-negative integer")
+		}
+	case reflect.Float32, reflect.Float64:
+		if v.kind != kindNumber {
+			mismatch("number")
+		}
+	}
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+type field struct {
+	name string
+	typ  reflect.Type
+}
+
+// structFields returns the JSON fields of a struct type, following the
+// rules of encoding/json for tags and embedded structs.
+func structFields(t reflect.Type) []field {
+	var out []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := tag
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			name = tag[:i]
+		}
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			out = append(out, structFields(ft)...)
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		out = append(out, field{name: name, typ: sf.Type})
+	}
+	return out
+}
+
+// lookupField finds the field a key decodes into. Like encoding/json, an
+// exact match is preferred over a case-insensitive one.
+func lookupField(fields []field, key string) (field, bool) {
+	for _, f := range fields {
+		if f.name == key {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, key) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
@@ -0,0 +1,120 @@
+package jsonconf
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type testEmbedded struct {
+	Embedded string
+}
+
+type testConfig struct {
+	testEmbedded
+	Name    string
+	Count   int
+	Size    uint64
+	Enabled bool
+	Tags    []string
+	Headers map[string][]string
+	Renamed string `json:"other"`
+	Skipped string `json:"-"`
+	Any     interface{}
+	Nested  *testNested
+}
+
+type testNested struct {
+	Ratio float64
+}
+
+func TestCheckValid(t *testing.T) {
+	doc := `{
+  "Embedded": "e",
+  "Name": "n",
+  "count": 3,
+  "Size": 10,
+  "Enabled": true,
+  "Tags": ["a", "b"],
+  "Headers": {"X": ["y"]},
+  "other": "o",
+  "Any": {"whatever": [1, "x"]},
+  "Nested": {"Ratio": 0.5},
+  "Tags": null
+}`
+	if err := Check([]byte(doc), reflect.TypeOf(testConfig{})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	doc := `{
+  "Name": 1,
+  "Unknown": true,
+  "Count": 1.5,
+  "Size": -1,
+  "Tags": ["a", 2],
+  "Skipped": "x",
+  "Nested": {
+    "Ratio": "high"
+  }
+}`
+	err := Check([]byte(doc), reflect.TypeOf(testConfig{}))
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expected Errors, got %v", err)
+	}
+
+	expected := []struct {
+		line int
+		path string
+	}{
+		{2, "Name"},
+		{3, "Unknown"},
+		{4, "Count"},
+		{5, "Size"},
+		{6, "Tags[1]"},
+		{7, "Skipped"},
+		{9, "Nested.Ratio"},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d:\n%s", len(expected), len(errs), errs)
+	}
+	for i, e := range expected {
+		if errs[i].Pos.Line != e.line || errs[i].Path != e.path {
+			t.Errorf("error %d: expected %s on line %d, got %s", i, e.path, e.line, errs[i])
+		}
+	}
+
+	if n := len(errs.Mismatches()); n != len(expected)-2 {
+		t.Errorf("expected %d type mismatches, got %d", len(expected)-2, n)
+	}
+}
+
+func TestCheckSyntax(t *testing.T) {
+	err := Check([]byte("{\n  \"Name\": \"x\",\n}"), reflect.TypeOf(testConfig{}))
+	se, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("expected syntax error, got %v", err)
+	}
+	if se.Pos.Line != 3 {
+		t.Fatalf("expected error on line 3, got %s", se)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	doc := `{"a": "b", "c": {"d": "e", "f": "g"}, "l": [1, 2]}`
+	patch := `{"a": "z", "c": {"f": null, "h": "i"}, "l": [3], "n": {"o": null}}`
+
+	out, err := MergePatch([]byte(doc), []byte(patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got, want interface{}
+	json.Unmarshal(out, &got)
+	json.Unmarshal([]byte(`{"a": "z", "c": {"d": "e", "h": "i"}, "l": [3], "n": {}}`), &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result: %s", out)
+	}
+}
@@ -0,0 +1,266 @@
+// Package jsonconf implements helpers for JSON configuration files: strict
+// checking of a document against the Go type it is decoded into, with errors
+// pointing at the offending line, and JSON merge patches (RFC 7386).
+package jsonconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Pos is a position in a JSON document.
+type Pos struct {
+	Line   int
+	Column int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+type kind int
+
+const (
+	kindNull kind = iota
+	kindBool
+	kindNumber
+	kindString
+	kindArray
+	kindObject
+)
+
+func (k kind) String() string {
+	return [...]string{"null", "boolean", "number", "string", "array", "object"}[k]
+}
+
+type member struct {
+	key    string
+	keyPos Pos
+	val    *value
+}
+
+// value is a parsed JSON value which remembers where it was found.
+type value struct {
+	kind    kind
+	pos     Pos
+	raw     string // numbers
+	members []member
+	elems   []*value
+}
+
+type parser struct {
+	data []byte
+	off  int
+	line int
+	col  int
+}
+
+// SyntaxError is returned for malformed JSON documents.
+type SyntaxError struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Pos.Line, e.Pos.Column, e.Msg)
+}
+
+func parse(data []byte) (*value, error) {
+	p := &parser{data: data, line: 1, col: 1}
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.off < len(p.data) {
+		return nil, p.errorf("unexpected %q after top-level value", p.data[p.off])
+	}
+	return v, nil
+}
+
+func (p *parser) pos() Pos {
+	return Pos{Line: p.line, Column: p.col}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Pos: p.pos(), Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) advance(n int) {
+	for i := 0; i < n && p.off < len(p.data); i++ {
+		if p.data[p.off] == '\n' {
+			p.line++
+			p.col = 1
+		} else if p.data[p.off] < utf8.RuneSelf || utf8.RuneStart(p.data[p.off]) {
+			p.col++
+		}
+		p.off++
+	}
+}
+
+func (p *parser) skipSpace() {
+	for p.off < len(p.data) {
+		switch p.data[p.off] {
+		case ' ', '\t', '\r', '\n':
+			p.advance(1)
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) value() (*value, error) {
+	p.skipSpace()
+	if p.off >= len(p.data) {
+		return nil, p.errorf("unexpected end of input")
+	}
+
+	start := p.pos()
+	switch c := p.data[p.off]; {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"':
+		if _, err := p.str(); err != nil {
+			return nil, err
+		}
+		return &value{kind: kindString, pos: start}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.number()
+	default:
+		for _, lit := range []struct {
+			s string
+			k kind
+		}{{"true", kindBool}, {"false", kindBool}, {"null", kindNull}} {
+			if len(p.data)-p.off >= len(lit.s) && string(p.data[p.off:p.off+len(lit.s)]) == lit.s {
+				p.advance(len(lit.s))
+				return &value{kind: lit.k, pos: start}, nil
+			}
+		}
+		return nil, p.errorf("invalid character %q", c)
+	}
+}
+
+func (p *parser) object() (*value, error) {
+	v := &value{kind: kindObject, pos: p.pos()}
+	p.advance(1)
+	p.skipSpace()
+	if p.off < len(p.data) && p.data[p.off] == '}' {
+		p.advance(1)
+		return v, nil
+	}
+
+	for {
+		p.skipSpace()
+		if p.off >= len(p.data) || p.data[p.off] != '"' {
+			return nil, p.errorf("expected object key")
+		}
+		keyPos := p.pos()
+		key, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpace()
+		if p.off >= len(p.data) || p.data[p.off] != ':' {
+			return nil, p.errorf("expected ':' after object key")
+		}
+		p.advance(1)
+
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		v.members = append(v.members, member{key: key, keyPos: keyPos, val: val})
+
+		p.skipSpace()
+		if p.off >= len(p.data) {
+			return nil, p.errorf("unexpected end of input in object")
+		}
+		switch p.data[p.off] {
+		case ',':
+			p.advance(1)
+		case '}':
+			p.advance(1)
+			return v, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in object")
+		}
+	}
+}
+
+func (p *parser) array() (*value, error) {
+	v := &value{kind: kindArray, pos: p.pos()}
+	p.advance(1)
+	p.skipSpace()
+	if p.off < len(p.data) && p.data[p.off] == ']' {
+		p.advance(1)
+		return v, nil
+	}
+
+	for {
+		el, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		v.elems = append(v.elems, el)
+
+		p.skipSpace()
+		if p.off >= len(p.data) {
+			return nil, p.errorf("unexpected end of input in array")
+		}
+		switch p.data[p.off] {
+		case ',':
+			p.advance(1)
+		case ']':
+			p.advance(1)
+			return v, nil
+		default:
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+func (p *parser) str() (string, error) {
+	start := p.off
+	p.advance(1)
+	for p.off < len(p.data) {
+		switch c := p.data[p.off]; {
+		case c == '"':
+			p.advance(1)
+			var s string
+			if err := json.Unmarshal(p.data[start:p.off], &s); err != nil {
+				return "", p.errorf("invalid string: %s", err)
+			}
+			return s, nil
+		case c == '\\':
+			p.advance(2)
+		case c < 0x20:
+			return "", p.errorf("invalid control character in string")
+		default:
+			p.advance(1)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) number() (*value, error) {
+	v := &value{kind: kindNumber, pos: p.pos()}
+	start := p.off
+	for p.off < len(p.data) {
+		c := p.data[p.off]
+		if (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E' {
+			p.advance(1)
+			continue
+		}
+		break
+	}
+	v.raw = string(p.data[start:p.off])
+	if _, err := strconv.ParseFloat(v.raw, 64); err != nil {
+		return nil, &SyntaxError{Pos: v.pos, Msg: fmt.Sprintf("invalid number %q", v.raw)}
+	}
+	return v, nil
+}
@@ -0,0 +1,41 @@
+package jsonconf
+
+import (
+	"encoding/json"
+)
+
+// MergePatch applies a JSON merge patch (RFC 7386) to doc and returns the
+// patched document. Object members of the patch are merged recursively into
+// the document, null members delete the corresponding key, and any other
+// value replaces the original one.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var d, p interface{}
+	if err := json.Unmarshal(doc, &d); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+	return json.Marshal(MergeValue(d, p))
+}
+
+// MergeValue applies a decoded JSON merge patch to a decoded document.
+func MergeValue(doc, patch interface{}) interface{} {
+	pm, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	dm, ok := doc.(map[string]interface{})
+	if !ok {
+		dm = make(map[string]interface{})
+	}
+	for k, pv := range pm {
+		if pv == nil {
+			delete(dm, k)
+			continue
+		}
+		dm[k] = MergeValue(dm[k], pv)
+	}
+	return dm
+}
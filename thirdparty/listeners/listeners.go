@@ -0,0 +1,131 @@
+// Package listeners creates the network listeners of the HTTP servers: unix
+// domain sockets with restricted permissions, and listeners passed by systemd
+// socket activation.
+package listeners
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultSocketMode is the file mode of unix sockets when none is
+// configured. Only the owner of the repo may connect.
+const DefaultSocketMode os.FileMode = 0600
+
+// ParseMode parses an octal file mode such as "0660". An empty string
+// returns DefaultSocketMode.
+func ParseMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return DefaultSocketMode, nil
+	}
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || m > 0777 {
+		return 0, fmt.Errorf("invalid socket mode %q, expected an octal mode like 0660", s)
+	}
+	return os.FileMode(m), nil
+}
+
+// ListenUnix listens on the unix domain socket at path, and sets its file
+// mode. A stale socket file left behind by a previous process is removed,
+// but a socket somebody is still listening on is not.
+func ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if st, err := os.Lstat(path); err == nil {
+		if st.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// systemd passes the activated sockets starting at this file descriptor.
+const listenFdsStart = 3
+
+var (
+	activatedOnce sync.Once
+	activated     map[string][]net.Listener
+	activatedErr  error
+)
+
+// Activated returns the listeners passed by systemd socket activation,
+// indexed by their FileDescriptorName. Sockets without a name are listed
+// under "unknown". The environment variables are consumed on the first call
+// so that child processes don't inherit them.
+func Activated() (map[string][]net.Listener, error) {
+	activatedOnce.Do(func() {
+		activated, activatedErr = activatedFromEnv()
+	})
+	return activated, activatedErr
+}
+
+// Take removes and returns the activated listeners with the given name.
+func Take(name string) ([]net.Listener, error) {
+	ls, err := Activated()
+	if err != nil {
+		return nil, err
+	}
+	out := ls[name]
+	delete(ls, name)
+	return out, nil
+}
+
+func activatedFromEnv() (map[string][]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	out := make(map[string][]net.Listener)
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return out, nil
+	}
+
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds <= 0 {
+		return out, nil
+	}
+
+	var names []string
+	if s := os.Getenv("LISTEN_FDNAMES"); s != "" {
+		names = strings.Split(s, ":")
+	}
+
+	for i := 0; i < nfds; i++ {
+		fd := listenFdsStart + i
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("socket activation: fd %d (%s): %s", fd, name, err)
+		}
+		out[name] = append(out[name], l)
+	}
+	return out, nil
+}
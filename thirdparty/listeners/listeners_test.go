@@ -0,0 +1,80 @@
+package listeners
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	m, err := ParseMode("")
+	if err != nil || m != DefaultSocketMode {
+		t.Fatalf("expected default mode, got %o %v", m, err)
+	}
+
+	m, err = ParseMode("0660")
+	if err != nil || m != 0660 {
+		t.Fatalf("expected 0660, got %o %v", m, err)
+	}
+
+	for _, s := range []string{"rw", "0999", "01777"} {
+		if _, err := ParseMode(s); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listeners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "api.sock")
+	l, err := ListenUnix(p, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode().Perm() != 0640 {
+		t.Fatalf("expected mode 0640, got %o", st.Mode().Perm())
+	}
+
+	if _, err := ListenUnix(p, 0600); err == nil {
+		t.Fatal("expected error listening on a socket in use")
+	}
+	l.Close()
+
+	// regular files are never replaced
+	os.Remove(p)
+	if err := ioutil.WriteFile(p, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListenUnix(p, 0600); err == nil {
+		t.Fatal("expected error for a regular file")
+	}
+	os.Remove(p)
+
+	l, err = ListenUnix(p, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+}
+
+func TestActivatedWithoutEnv(t *testing.T) {
+	os.Unsetenv("LISTEN_PID")
+	ls, err := Take("api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls) != 0 {
+		t.Fatal("expected no activated listeners")
+	}
+}
@@ -0,0 +1,71 @@
+// Package sdnotify implements the sd_notify protocol, through which the
+// services run by systemd report their state, like being ready or reloading
+// their config, and ping the service watchdog.
+package sdnotify
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The states reported to systemd.
+const (
+	Ready     = "READY=1"
+	Reloading = "RELOADING=1"
+	Stopping  = "STOPPING=1"
+	Watchdog  = "WATCHDOG=1"
+)
+
+// Status returns the state describing the service, shown by
+// 'systemctl status'.
+func Status(s string) string {
+	return "STATUS=" + s
+}
+
+// Enabled returns whether the process runs under systemd with sd_notify, in a
+// unit of Type=notify.
+func Enabled() bool {
+	return os.Getenv("NOTIFY_SOCKET") != ""
+}
+
+// Notify reports states to systemd. It does nothing when the process doesn't
+// run under systemd with sd_notify.
+func Notify(states ...string) error {
+	sock := os.Getenv("NOTIFY_SOCKET")
+	if sock == "" {
+		return nil
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: sock, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("sd_notify: %s", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return fmt.Errorf("sd_notify: %s", err)
+	}
+	return nil
+}
+
+// WatchdogInterval returns the interval at which the service watchdog of
+// systemd expects to be pinged, or 0 when it isn't enabled for this process.
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseUint(usec, 10, 63)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+	}
+	return time.Duration(n) * time.Microsecond, nil
+}
@@ -0,0 +1,80 @@
+package sdnotify
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sdnotify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sock := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sock, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", sock)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	if err := Notify(Ready, Status("Daemon is ready")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFromUnix(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := string(buf[:n]), "READY=1\nSTATUS=Daemon is ready"; got != exp {
+		t.Fatalf("got %q, expected %q", got, exp)
+	}
+}
+
+func TestNotifyWithoutSystemd(t *testing.T) {
+	os.Unsetenv("NOTIFY_SOCKET")
+	if Enabled() {
+		t.Fatal("expected sd_notify to be disabled")
+	}
+	if err := Notify(Ready); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+
+	os.Setenv("WATCHDOG_USEC", "30000000")
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	d, err := WatchdogInterval()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 30*time.Second {
+		t.Fatalf("got %s, expected 30s", d)
+	}
+
+	// the watchdog of another process
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if d, err := WatchdogInterval(); err != nil || d != 0 {
+		t.Fatalf("got %s, %v, expected no watchdog", d, err)
+	}
+
+	os.Unsetenv("WATCHDOG_PID")
+	os.Setenv("WATCHDOG_USEC", "soon")
+	if _, err := WatchdogInterval(); err == nil {
+		t.Fatal("expected an invalid WATCHDOG_USEC to be rejected")
+	}
+}
@@ -0,0 +1,175 @@
+package zstd
+
+import "sort"
+
+// The predefined distributions of the literal length, match length and offset
+// codes, -1 standing for the probabilities lower than 1.
+var (
+	llNorm = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	mlNorm = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+	ofNorm = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+)
+
+var (
+	llTable = newFSETable(llNorm, 6)
+	mlTable = newFSETable(mlNorm, 6)
+	ofTable = newFSETable(ofNorm, 5)
+)
+
+// The baselines and numbers of extra bits of the literal length and match
+// length codes.
+var (
+	llBase = []uint32{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536,
+	}
+	llBits = []uint{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+	mlBase = []uint32{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539,
+	}
+	mlBits = []uint{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16,
+	}
+)
+
+// code returns the code of v, the last of the baselines base lower than v.
+func code(base []uint32, v uint32) uint {
+	return uint(sort.Search(len(base), func(i int) bool { return base[i] > v }) - 1)
+}
+
+func llCode(litLen uint32) uint {
+	if litLen < 16 {
+		return uint(litLen)
+	}
+	return code(llBase, litLen)
+}
+
+func mlCode(matchLen uint32) uint {
+	if matchLen < 35 {
+		return uint(matchLen - 3)
+	}
+	return code(mlBase, matchLen)
+}
+
+// fseTable is the table coding symbols of a distribution with FSE.
+type fseTable struct {
+	log     uint
+	states  []uint16
+	symbols []fseSymbol
+}
+
+type fseSymbol struct {
+	deltaNbBits    uint32
+	deltaFindState int32
+}
+
+// newFSETable returns the table of the normalized distribution norm, of
+// accuracy log, spreading the symbols over the states as the decoders do.
+func newFSETable(norm []int16, log uint) *fseTable {
+	size := 1 << log
+	high := size - 1
+
+	// the symbols of probability lower than 1 get the last states, the
+	// others are spread over the rest
+	symbolAt := make([]byte, size)
+	cumul := make([]int, len(norm)+1)
+	for s, c := range norm {
+		if c == -1 {
+			cumul[s+1] = cumul[s] + 1
+			symbolAt[high] = byte(s)
+			high--
+		} else {
+			cumul[s+1] = cumul[s] + int(c)
+		}
+	}
+	step := size>>1 + size>>3 + 3
+	pos := 0
+	for s, c := range norm {
+		for i := 0; i < int(c); i++ {
+			symbolAt[pos] = byte(s)
+			pos = (pos + step) & (size - 1)
+			for pos > high {
+				pos = (pos + step) & (size - 1)
+			}
+		}
+	}
+
+	t := &fseTable{
+		log:     log,
+		states:  make([]uint16, size),
+		symbols: make([]fseSymbol, len(norm)),
+	}
+	for u := 0; u < size; u++ {
+		s := symbolAt[u]
+		t.states[cumul[s]] = uint16(size + u)
+		cumul[s]++
+	}
+
+	total := 0
+	for s, c := range norm {
+		sym := &t.symbols[s]
+		switch c {
+		case 0:
+			sym.deltaNbBits = uint32(log+1)<<16 - uint32(size)
+		case -1, 1:
+			sym.deltaNbBits = uint32(log)<<16 - uint32(size)
+			sym.deltaFindState = int32(total - 1)
+			total++
+		default:
+			maxBitsOut := log - highBit(uint32(c-1))
+			minStatePlus := uint32(c) << maxBitsOut
+			sym.deltaNbBits = uint32(maxBitsOut)<<16 - minStatePlus
+			sym.deltaFindState = int32(total - int(c))
+			total += int(c)
+		}
+	}
+	return t
+}
+
+// fseState is the state of the coding of the symbols of a table.
+type fseState struct {
+	value uint32
+}
+
+// init starts the coding with the symbol s, the last the decoder reads.
+func (st *fseState) init(t *fseTable, s uint) {
+	sym := t.symbols[s]
+	nbBits := (sym.deltaNbBits + 1<<15) >> 16
+	v := nbBits<<16 - sym.deltaNbBits
+	st.value = uint32(t.states[int32(v>>nbBits)+sym.deltaFindState])
+}
+
+func (st *fseState) encode(bw *bitWriter, t *fseTable, s uint) {
+	sym := t.symbols[s]
+	nbBits := (st.value + sym.deltaNbBits) >> 16
+	bw.add(st.value, uint(nbBits))
+	st.value = uint32(t.states[int32(st.value>>nbBits)+sym.deltaFindState])
+}
+
+// flush writes the state, the first thing the decoder reads.
+func (st *fseState) flush(bw *bitWriter, t *fseTable) {
+	bw.add(st.value, t.log)
+}
@@ -0,0 +1,298 @@
+// Package zstd implements a zstd compressor, as specified by RFC 8878.
+//
+// It's built for speed and simplicity rather than ratio: the matches are found
+// with a single hash table, the sequences are coded with the predefined FSE
+// tables and the literals are left uncompressed. Its output is a standard
+// zstd frame, which any zstd decoder reads.
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	frameMagic = 0xFD2FB528
+
+	// windowLog is the log of the window size, which is also the size of
+	// the largest blocks. The matches never cross the blocks.
+	windowLog = 17
+	blockSize = 1 << windowLog
+
+	minMatch = 4
+	hashLog  = 14
+)
+
+// The types of the blocks.
+const (
+	blockRaw        = 0
+	blockCompressed = 2
+)
+
+// ErrClosed is returned when writing to a closed Writer.
+var ErrClosed = errors.New("zstd: writer is closed")
+
+// Writer compresses what's written to it into a zstd frame.
+type Writer struct {
+	w   io.Writer
+	err error
+
+	wroteHeader bool
+	closed      bool
+
+	// buf is the content of the next block, out the next blocks written
+	buf []byte
+	out []byte
+
+	// table maps the hashes of 4 bytes to their last position in the
+	// block, plus one
+	table [1 << hashLog]int32
+	seqs  []sequence
+	lits  []byte
+}
+
+// NewWriter returns a Writer writing the frame to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, buf: make([]byte, 0, blockSize)}
+}
+
+// Write compresses p, writing the blocks filled.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.closed {
+		return 0, ErrClosed
+	}
+	if z.err != nil {
+		return 0, z.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		m := blockSize - len(z.buf)
+		if m > len(p) {
+			m = len(p)
+		}
+		z.buf = append(z.buf, p[:m]...)
+		p = p[m:]
+		n += m
+
+		if len(z.buf) == blockSize {
+			if err := z.writeBlock(false); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Flush writes what was written so far as a block, for the reader to be able
+// to decompress it.
+func (z *Writer) Flush() error {
+	if z.closed {
+		return ErrClosed
+	}
+	if z.err != nil {
+		return z.err
+	}
+	if len(z.buf) == 0 {
+		return nil
+	}
+	return z.writeBlock(false)
+}
+
+// Close writes the last block, ending the frame. It doesn't close the
+// underlying writer.
+func (z *Writer) Close() error {
+	if z.closed {
+		return z.err
+	}
+	if z.err == nil {
+		z.writeBlock(true)
+	}
+	z.closed = true
+	return z.err
+}
+
+// writeBlock writes the content of buf as a block, compressed unless it
+// doesn't gain anything.
+func (z *Writer) writeBlock(last bool) error {
+	z.out = z.out[:0]
+	if !z.wroteHeader {
+		// no content size, checksum nor dictionary: only the window
+		// descriptor follows the frame header descriptor
+		z.out = append(z.out, 0, 0, 0, 0, 0, (windowLog-10)<<3)
+		binary.LittleEndian.PutUint32(z.out, frameMagic)
+		z.wroteHeader = true
+	}
+
+	hdr := len(z.out)
+	z.out = append(z.out, 0, 0, 0)
+	typ := blockCompressed
+	z.out = z.compressBlock(z.out, z.buf)
+	if size := len(z.out) - hdr - 3; size == 0 || size >= len(z.buf) {
+		typ = blockRaw
+		z.out = append(z.out[:hdr+3], z.buf...)
+	}
+
+	h := uint32(len(z.out)-hdr-3)<<3 | uint32(typ)<<1
+	if last {
+		h |= 1
+	}
+	z.out[hdr] = byte(h)
+	z.out[hdr+1] = byte(h >> 8)
+	z.out[hdr+2] = byte(h >> 16)
+
+	z.buf = z.buf[:0]
+	_, z.err = z.w.Write(z.out)
+	return z.err
+}
+
+// sequence is a match, preceded by literals.
+type sequence struct {
+	litLen   uint32
+	matchLen uint32
+	offset   uint32
+}
+
+func load32(b []byte, i int) uint32 {
+	return binary.LittleEndian.Uint32(b[i:])
+}
+
+func hash32(v uint32) uint32 {
+	return (v * 2654435761) >> (32 - hashLog)
+}
+
+// compressBlock appends the compressed block of src to dst, or returns dst as
+// it is when no match is found.
+func (z *Writer) compressBlock(dst, src []byte) []byte {
+	for i := range z.table {
+		z.table[i] = 0
+	}
+	z.seqs = z.seqs[:0]
+	z.lits = z.lits[:0]
+
+	anchor := 0
+	for i := 0; i+8 <= len(src); {
+		v := load32(src, i)
+		h := hash32(v)
+		cand := int(z.table[h]) - 1
+		z.table[h] = int32(i + 1)
+		if cand < 0 || load32(src, cand) != v {
+			// skip faster through the incompressible parts
+			i += 1 + (i-anchor)>>6
+			continue
+		}
+
+		m := minMatch
+		for i+m < len(src) && src[cand+m] == src[i+m] {
+			m++
+		}
+		z.lits = append(z.lits, src[anchor:i]...)
+		z.seqs = append(z.seqs, sequence{
+			litLen:   uint32(i - anchor),
+			matchLen: uint32(m),
+			offset:   uint32(i - cand),
+		})
+		i += m
+		anchor = i
+	}
+	if len(z.seqs) == 0 {
+		return dst
+	}
+	z.lits = append(z.lits, src[anchor:]...)
+
+	// the literals, raw
+	switch n := len(z.lits); {
+	case n < 1<<5:
+		dst = append(dst, byte(n<<3))
+	case n < 1<<12:
+		dst = append(dst, byte(1<<2|n<<4), byte(n>>4))
+	default:
+		dst = append(dst, byte(3<<2|n<<4), byte(n>>4), byte(n>>12))
+	}
+	dst = append(dst, z.lits...)
+
+	// the sequences, with the predefined tables
+	switch n := len(z.seqs); {
+	case n < 0x80:
+		dst = append(dst, byte(n))
+	case n < 0x7F00:
+		dst = append(dst, byte(n>>8)+0x80, byte(n))
+	default:
+		n -= 0x7F00
+		dst = append(dst, 0xFF, byte(n), byte(n>>8))
+	}
+	dst = append(dst, 0)
+	return encodeSequences(dst, z.seqs)
+}
+
+// encodeSequences appends the bitstream of seqs to dst. The bitstream is read
+// backwards, so the sequences are written from the last.
+func encodeSequences(dst []byte, seqs []sequence) []byte {
+	bw := bitWriter{out: dst}
+	var ll, ml, of fseState
+
+	last := len(seqs) - 1
+	for n := last; n >= 0; n-- {
+		s := seqs[n]
+		llc := llCode(s.litLen)
+		mlc := mlCode(s.matchLen)
+		offBase := s.offset + 3
+		ofc := highBit(offBase)
+
+		if n == last {
+			ml.init(mlTable, mlc)
+			of.init(ofTable, ofc)
+			ll.init(llTable, llc)
+		} else {
+			of.encode(&bw, ofTable, ofc)
+			ml.encode(&bw, mlTable, mlc)
+			ll.encode(&bw, llTable, llc)
+		}
+		bw.add(s.litLen-llBase[llc], llBits[llc])
+		bw.add(s.matchLen-mlBase[mlc], mlBits[mlc])
+		bw.add(offBase-1<<ofc, ofc)
+	}
+
+	ml.flush(&bw, mlTable)
+	of.flush(&bw, ofTable)
+	ll.flush(&bw, llTable)
+	return bw.close()
+}
+
+// bitWriter writes a bitstream, the first bits in the lowest bits of the
+// first bytes.
+type bitWriter struct {
+	out   []byte
+	bits  uint64
+	nbits uint
+}
+
+func (b *bitWriter) add(v uint32, n uint) {
+	b.bits |= uint64(v&(1<<n-1)) << b.nbits
+	b.nbits += n
+	for b.nbits >= 8 {
+		b.out = append(b.out, byte(b.bits))
+		b.bits >>= 8
+		b.nbits -= 8
+	}
+}
+
+// close ends the bitstream with a 1 bit, for the reader to find its end, and
+// returns it.
+func (b *bitWriter) close() []byte {
+	b.add(1, 1)
+	if b.nbits > 0 {
+		b.out = append(b.out, byte(b.bits))
+	}
+	return b.out
+}
+
+func highBit(v uint32) uint {
+	n := uint(0)
+	for v > 1 {
+		v >>= 1
+		n++
+	}
+	return n
+}
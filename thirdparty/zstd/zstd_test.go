@@ -0,0 +1,133 @@
+package zstd
+
+import (
+	"bytes"
+	"math/rand"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestNormalizedDistributions(t *testing.T) {
+	for _, d := range []struct {
+		name string
+		norm []int16
+		log  uint
+	}{
+		{"literal length", llNorm, 6},
+		{"match length", mlNorm, 6},
+		{"offset", ofNorm, 5},
+	} {
+		total := 0
+		for _, c := range d.norm {
+			if c == -1 {
+				c = 1
+			}
+			total += int(c)
+		}
+		if total != 1<<d.log {
+			t.Errorf("%s: the probabilities sum to %d, expected %d", d.name, total, 1<<d.log)
+		}
+	}
+}
+
+func TestCodes(t *testing.T) {
+	for _, c := range []struct {
+		v, code uint32
+		ml      bool
+	}{
+		{0, 0, false},
+		{15, 15, false},
+		{16, 16, false},
+		{17, 16, false},
+		{65, 25, false},
+		{131071, 35, false},
+		{3, 0, true},
+		{34, 31, true},
+		{36, 32, true},
+		{131074, 52, true},
+	} {
+		var got uint
+		if c.ml {
+			got = mlCode(c.v)
+		} else {
+			got = llCode(c.v)
+		}
+		if got != uint(c.code) {
+			t.Errorf("code of %d (match: %t): got %d, expected %d", c.v, c.ml, got, c.code)
+		}
+	}
+}
+
+func compress(t *testing.T, data []byte, flushEvery int) []byte {
+	var buf bytes.Buffer
+	z := NewWriter(&buf)
+	for len(data) > 0 {
+		n := flushEvery
+		if n > len(data) {
+			n = len(data)
+		}
+		if _, err := z.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		if err := z.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriter(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 200000)
+	rnd.Read(random)
+	words := strings.Fields("the gateway serves the content of ipfs paths to http clients")
+	var text bytes.Buffer
+	for text.Len() < 400000 {
+		text.WriteString(words[rnd.Intn(len(words))])
+		text.WriteByte(' ')
+	}
+
+	zstd, err := exec.LookPath("zstd")
+	if err != nil {
+		t.Log("zstd not found, the frames aren't decompressed")
+	}
+
+	for _, test := range []struct {
+		name       string
+		data       []byte
+		flushEvery int
+	}{
+		{"empty", nil, 1},
+		{"short", []byte("hello"), 1024},
+		{"text", text.Bytes(), len(text.Bytes())},
+		{"flushed text", text.Bytes(), 1000},
+		{"random", random, len(random)},
+		{"runs", bytes.Repeat([]byte{'a'}, 300000), 300000},
+	} {
+		frame := compress(t, test.data, test.flushEvery)
+		if test.name == "text" && len(frame) > len(test.data)/2 {
+			t.Errorf("%s: compressed %d bytes into %d", test.name, len(test.data), len(frame))
+		}
+		if zstd == "" {
+			continue
+		}
+
+		cmd := exec.Command(zstd, "-d", "-c")
+		cmd.Stdin = bytes.NewReader(frame)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			t.Errorf("%s: decompressing: %s: %s", test.name, err, stderr.String())
+			continue
+		}
+		if !bytes.Equal(out, test.data) {
+			t.Errorf("%s: decompressed %d bytes, differing from the %d compressed", test.name, len(out), len(test.data))
+		}
+	}
+}
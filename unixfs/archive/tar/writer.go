@@ -5,6 +5,7 @@ package tar
 import (
 	"archive/tar"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"path"
@@ -70,6 +71,18 @@ func (w *Writer) writeFile(nd *mdag.ProtoNode, pb *upb.Data, fpath string) error
 	return nil
 }
 
+// writeMetadata writes the file described by the metadata node nd.
+func (w *Writer) writeMetadata(nd *mdag.ProtoNode, fpath string) error {
+	if len(nd.Links()) == 0 {
+		return errors.New("incorrectly formatted metadata object")
+	}
+	child, err := nd.Links()[0].GetNode(w.ctx, w.Dag)
+	if err != nil {
+		return err
+	}
+	return w.WriteNode(child, fpath)
+}
+
 // WriteNode adds a node to the archive.
 func (w *Writer) WriteNode(nd ipld.Node, fpath string) error {
 	switch nd := nd.(type) {
@@ -81,7 +94,7 @@ func (w *Writer) WriteNode(nd ipld.Node, fpath string) error {
 
 		switch pb.GetType() {
 		case upb.Data_Metadata:
-			fallthrough
+			return w.writeMetadata(nd, fpath)
 		case upb.Data_Directory, upb.Data_HAMTShard:
 			return w.writeDir(nd, fpath)
 		case upb.Data_Raw:
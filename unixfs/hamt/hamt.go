@@ -21,11 +21,12 @@
 package hamt
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
 
-	dag "github.com/ipfs/go-ipfs/merkledag"
+	dag "github.com/ipfs/go-ipfs/merkledag/dagnode"
 	format "github.com/ipfs/go-ipfs/unixfs"
 	upb "github.com/ipfs/go-ipfs/unixfs/pb"
 
@@ -408,8 +409,75 @@ func (ds *Shard) ForEachLink(ctx context.Context, f func(*ipld.Link) error) erro
 	})
 }
 
+// ForEachLinkAfter walks the links of the Shard following the link named
+// after, in the order ForEachLink walks them: the order of the hashes of the
+// names. The link named after needn't be in the Shard, and the subshards
+// before it aren't loaded, which makes it the cursor to page through the
+// links. All the links are walked when after is empty.
+func (ds *Shard) ForEachLinkAfter(ctx context.Context, after string, f func(*ipld.Link) error) error {
+	if after == "" {
+		return ds.ForEachLink(ctx, f)
+	}
+
+	hv := &hashBits{b: hash([]byte(after))}
+	return ds.walkTrieAfter(ctx, hv, after, func(sv *shardValue) error {
+		lnk := sv.val
+		lnk.Name = sv.key
+
+		return f(lnk)
+	})
+}
+
+// walkTrieAfter walks the values following the key after, of hash hv.
+func (ds *Shard) walkTrieAfter(ctx context.Context, hv *hashBits, after string, cb func(*shardValue) error) error {
+	idx := hv.Next(ds.tableSizeLg2)
+	next := ds.indexForBitPos(idx)
+	if ds.bitfield.Bit(idx) {
+		c, err := ds.getChild(ctx, next)
+		if err != nil {
+			return err
+		}
+
+		switch c := c.(type) {
+		case *shardValue:
+			// the value in the slot of after follows it if its hash does
+			if keyFollows(c.key, after) {
+				if err := cb(c); err != nil {
+					return err
+				}
+			}
+		case *Shard:
+			if err := c.walkTrieAfter(ctx, hv, after, cb); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected child type: %#v", c)
+		}
+		next++
+	}
+
+	return ds.walkChildren(ctx, next, cb)
+}
+
+// keyFollows returns whether key follows after in the order of the walks.
+func keyFollows(key, after string) bool {
+	switch bytes.Compare(hash([]byte(key)), hash([]byte(after))) {
+	case 1:
+		return true
+	case 0:
+		return key > after
+	default:
+		return false
+	}
+}
+
 func (ds *Shard) walkTrie(ctx context.Context, cb func(*shardValue) error) error {
-	for idx := range ds.children {
+	return ds.walkChildren(ctx, 0, cb)
+}
+
+// walkChildren walks the values of the children from the index from on.
+func (ds *Shard) walkChildren(ctx context.Context, from int, cb func(*shardValue) error) error {
+	for idx := from; idx < len(ds.children); idx++ {
 		c, err := ds.getChild(ctx, idx)
 		if err != nil {
 			return err
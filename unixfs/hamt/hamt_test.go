@@ -1,6 +1,7 @@
 package hamt
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"math/rand"
@@ -331,6 +332,83 @@ func TestDuplicateAddShard(t *testing.T) {
 	}
 }
 
+func TestForEachLinkAfter(t *testing.T) {
+	ds := mdtest.Mock()
+	_, s, err := makeDirWidth(ds, 300, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	nd, err := s.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nds, err := NewHamtFromDag(ds, nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := nds.EnumLinks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errPageFull := fmt.Errorf("page full")
+	page := func(after string, limit int) []string {
+		var names []string
+		err := nds.ForEachLinkAfter(ctx, after, func(l *ipld.Link) error {
+			names = append(names, l.Name)
+			if len(names) == limit {
+				return errPageFull
+			}
+			return nil
+		})
+		if err != nil && err != errPageFull {
+			t.Fatal(err)
+		}
+		return names
+	}
+
+	// paging through the shard lists every link once, in order
+	var names []string
+	for after := ""; ; {
+		p := page(after, 7)
+		if len(p) == 0 {
+			break
+		}
+		names = append(names, p...)
+		after = p[len(p)-1]
+	}
+	if len(names) != len(all) {
+		t.Fatalf("expected %d links, got %d", len(all), len(names))
+	}
+	for i, l := range all {
+		if names[i] != l.Name {
+			t.Fatalf("expected %s at %d, got %s", l.Name, i, names[i])
+		}
+	}
+
+	// a cursor which isn't in the shard resumes where it would be
+	const missing = "not in the shard"
+	var expected []string
+	for _, l := range all {
+		c := bytes.Compare(hash([]byte(l.Name)), hash([]byte(missing)))
+		if c > 0 || (c == 0 && l.Name > missing) {
+			expected = append(expected, l.Name)
+		}
+	}
+	names = page(missing, 0)
+	if len(names) != len(expected) {
+		t.Fatalf("expected %d links after %q, got %d", len(expected), missing, len(names))
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("expected %s at %d, got %s", expected[i], i, names[i])
+		}
+	}
+}
+
 func TestLoadFailsFromNonShard(t *testing.T) {
 	ds := mdtest.Mock()
 	nd := ft.EmptyDirNode()
@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"io"
 
-	mdag "github.com/ipfs/go-ipfs/merkledag"
+	mdag "github.com/ipfs/go-ipfs/merkledag/dagnode"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 	ftpb "github.com/ipfs/go-ipfs/unixfs/pb"
 
@@ -64,12 +64,7 @@ func NewDagReader(ctx context.Context, n ipld.Node, serv ipld.NodeGetter) (DagRe
 			if err != nil {
 				return nil, err
 			}
-
-			childpb, ok := child.(*mdag.ProtoNode)
-			if !ok {
-				return nil, mdag.ErrNotProtobuf
-			}
-			return NewDagReader(ctx, childpb, serv)
+			return NewDagReader(ctx, child, serv)
 		case ftpb.Data_Symlink:
 			return nil, ErrCantReadSymlinks
 		default:
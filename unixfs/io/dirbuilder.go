@@ -2,10 +2,11 @@ package io
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
-	mdag "github.com/ipfs/go-ipfs/merkledag"
+	mdag "github.com/ipfs/go-ipfs/merkledag/dagnode"
 	format "github.com/ipfs/go-ipfs/unixfs"
 	hamt "github.com/ipfs/go-ipfs/unixfs/hamt"
 
@@ -13,6 +14,13 @@ import (
 	cid "gx/ipfs/QmapdYm1b22Frv3k17fqrBYTFRxwiaVJkB299Mfn33edeB/go-cid"
 )
 
+// ErrCursorNotFound is returned when the link a listing starts after isn't
+// in the directory.
+var ErrCursorNotFound = errors.New("the link to list the directory after was not found")
+
+// errEnoughLinks stops the walk of the links once there are enough.
+var errEnoughLinks = errors.New("enough links")
+
 // ShardSplitThreshold specifies how large of an unsharded directory
 // the Directory code will generate. Adding entries over this value will
 // result in the node being restructured into a sharded object.
@@ -153,6 +161,71 @@ func (d *Directory) ForEachLink(ctx context.Context, f func(*ipld.Link) error) e
 	return d.shard.ForEachLink(ctx, f)
 }
 
+// ForEachLinkAfter applies the given function to the Links in the directory
+// following the one named after, all of them when after is empty. The links
+// of sharded directories are in the order of the hashes of their names, and
+// the one named after needn't be in them.
+func (d *Directory) ForEachLinkAfter(ctx context.Context, after string, f func(*ipld.Link) error) error {
+	if d.shard == nil {
+		links, err := linksAfter(d.dirnode.Links(), after)
+		if err != nil {
+			return err
+		}
+		for _, l := range links {
+			if err := f(l); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return d.shard.ForEachLinkAfter(ctx, after, f)
+}
+
+// LinksAfter returns the links in the directory following the one named
+// after, as ForEachLinkAfter walks them, at most limit of them when limit is
+// positive. The next links follow the last one returned.
+func (d *Directory) LinksAfter(ctx context.Context, after string, limit int) ([]*ipld.Link, error) {
+	var links []*ipld.Link
+	err := d.ForEachLinkAfter(ctx, after, func(l *ipld.Link) error {
+		links = append(links, l)
+		if limit > 0 && len(links) == limit {
+			return errEnoughLinks
+		}
+		return nil
+	})
+	if err != nil && err != errEnoughLinks {
+		return nil, err
+	}
+	return links, nil
+}
+
+// LinksAfter returns the links following the one named after, at most limit
+// of them when limit is positive, for the links of nodes which aren't
+// directories.
+func LinksAfter(links []*ipld.Link, after string, limit int) ([]*ipld.Link, error) {
+	links, err := linksAfter(links, after)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(links) > limit {
+		links = links[:limit]
+	}
+	return links, nil
+}
+
+func linksAfter(links []*ipld.Link, after string) ([]*ipld.Link, error) {
+	if after == "" {
+		return links, nil
+	}
+	for i, l := range links {
+		if l.Name == after {
+			return links[i+1:], nil
+		}
+	}
+	return nil, ErrCursorNotFound
+}
+
 // Links returns the all the links in the directory node.
 func (d *Directory) Links(ctx context.Context) ([]*ipld.Link, error) {
 	if d.shard == nil {
@@ -165,6 +238,18 @@ func (d *Directory) Links(ctx context.Context) ([]*ipld.Link, error) {
 // Find returns the root node of the file named 'name' within this directory.
 // In the case of HAMT-directories, it will traverse the tree.
 func (d *Directory) Find(ctx context.Context, name string) (ipld.Node, error) {
+	lnk, err := d.FindLink(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.dserv.Get(ctx, lnk.Cid)
+}
+
+// FindLink returns the link to the child with the given name, without
+// fetching the child. Only the shards on the path to the child are fetched
+// from a sharded directory.
+func (d *Directory) FindLink(ctx context.Context, name string) (*ipld.Link, error) {
 	if d.shard == nil {
 		lnk, err := d.dirnode.GetNodeLink(name)
 		switch err {
@@ -175,7 +260,7 @@ func (d *Directory) Find(ctx context.Context, name string) (ipld.Node, error) {
 		case nil:
 		}
 
-		return d.dserv.Get(ctx, lnk.Cid)
+		return lnk, nil
 	}
 
 	lnk, err := d.shard.Find(ctx, name)
@@ -183,7 +268,7 @@ func (d *Directory) Find(ctx context.Context, name string) (ipld.Node, error) {
 		return nil, err
 	}
 
-	return lnk.GetNode(ctx, d.dserv)
+	return &ipld.Link{Name: name, Size: lnk.Size, Cid: lnk.Cid}, nil
 }
 
 // RemoveChild removes the child with the given name.
@@ -3,6 +3,7 @@ package io
 import (
 	"context"
 	"fmt"
+	"os"
 	"testing"
 
 	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
@@ -70,6 +71,51 @@ func TestDirectoryGrowth(t *testing.T) {
 	}
 }
 
+func TestDirectoryFindLink(t *testing.T) {
+	defer func(v bool) { UseHAMTSharding = v }(UseHAMTSharding)
+	ctx := context.Background()
+
+	for _, sharded := range []bool{false, true} {
+		UseHAMTSharding = sharded
+		ds := mdtest.Mock()
+		dir := NewDirectory(ds)
+
+		child := ft.EmptyDirNode()
+		err := ds.Add(ctx, child)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 500; i++ {
+			err := dir.AddChild(ctx, fmt.Sprintf("entry %d", i), child)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		dirnd, err := dir.GetNode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		adir, err := NewDirectoryFromNode(ds, dirnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lnk, err := adir.FindLink(ctx, "entry 123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lnk.Name != "entry 123" || !lnk.Cid.Equals(child.Cid()) {
+			t.Fatalf("expected the link to entry 123, got %s %s (sharded: %t)", lnk.Name, lnk.Cid, sharded)
+		}
+
+		_, err = adir.FindLink(ctx, "entry 500")
+		if err != os.ErrNotExist {
+			t.Fatalf("expected os.ErrNotExist for a missing entry, got %v (sharded: %t)", err, sharded)
+		}
+	}
+}
+
 func TestDuplicateAddDir(t *testing.T) {
 	ds := mdtest.Mock()
 	dir := NewDirectory(ds)
@@ -3,7 +3,7 @@ package io
 import (
 	"context"
 
-	dag "github.com/ipfs/go-ipfs/merkledag"
+	dag "github.com/ipfs/go-ipfs/merkledag/dagnode"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 	hamt "github.com/ipfs/go-ipfs/unixfs/hamt"
 
@@ -56,3 +56,17 @@ func ResolveUnixfsOnce(ctx context.Context, ds ipld.NodeGetter, nd ipld.Node, na
 		return lnk, rest, nil
 	}
 }
+
+// SymlinkTarget returns the target of nd if it's a unixfs symlink. It is
+// meant for resolver.Resolver.Symlink, to follow the symlinks.
+func SymlinkTarget(nd ipld.Node) (string, bool) {
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return "", false
+	}
+	upb, err := ft.FromBytes(pn.Data())
+	if err != nil || upb.GetType() != ft.TSymlink {
+		return "", false
+	}
+	return string(upb.GetData()), true
+}
@@ -349,6 +349,7 @@ func (dm *DagModifier) appendData(nd ipld.Node, spl chunker.Splitter) (ipld.Node
 			Maxlinks:  help.DefaultLinksPerBlock,
 			Prefix:    &dm.Prefix,
 			RawLeaves: dm.RawLeaves,
+			Ctx:       dm.ctx,
 		}
 		return trickle.Append(dm.ctx, nd, dbp.New(spl))
 	default:
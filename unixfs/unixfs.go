@@ -8,7 +8,7 @@ import (
 
 	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
 
-	dag "github.com/ipfs/go-ipfs/merkledag"
+	dag "github.com/ipfs/go-ipfs/merkledag/dagnode"
 	pb "github.com/ipfs/go-ipfs/unixfs/pb"
 )
 
@@ -202,6 +202,11 @@ func (n *FSNode) NumChildren() int {
 	return len(n.blocksizes)
 }
 
+// BlockSize returns the total data size of the i-th child block.
+func (n *FSNode) BlockSize(i int) uint64 {
+	return n.blocksizes[i]
+}
+
 // Metadata is used to store additional FSNode information.
 type Metadata struct {
 	MimeType string
@@ -0,0 +1,198 @@
+// Package webhooks posts the events of a node to HTTP endpoints, to plug the
+// node into other tools.
+//
+// Each event is posted as a JSON object, with the peer ID of the node, the
+// type, time and data of the event, and its type in the X-Ipfs-Event header.
+// When the hook has a secret, the X-Ipfs-Webhook-Signature header holds
+// "sha256=" followed by the hex HMAC-SHA256 of the body with the secret. The
+// requests failing, or answered with another status than 2xx, are retried
+// with an exponential backoff.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	events "github.com/ipfs/go-ipfs/events"
+
+	goprocess "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
+	logging "gx/ipfs/Qmbi1CTJsbnBZjCEgc2otwu8cUFPsGpzWXG7edVCLZ7Gvk/go-log"
+)
+
+var log = logging.Logger("webhooks")
+
+const (
+	// EventHeader is the header holding the type of the event posted.
+	EventHeader = "X-Ipfs-Event"
+	// SignatureHeader is the header holding the signature of the body.
+	SignatureHeader = "X-Ipfs-Webhook-Signature"
+)
+
+const (
+	// DefaultRetries is the number of times a failed request is retried,
+	// when the hook doesn't set it.
+	DefaultRetries = 3
+	// RetryDelay is how long the first retry waits, doubled for the next
+	// ones.
+	RetryDelay = time.Second
+	// RequestTimeout is how long a request may take.
+	RequestTimeout = 10 * time.Second
+)
+
+// queueSize is the number of events waiting to be posted to a hook before
+// the next ones are dropped.
+const queueSize = 128
+
+// Hook is an endpoint the events are posted to.
+type Hook struct {
+	URL string
+	// Types are the types of the events posted, all of them when empty.
+	Types []events.Type
+	// Secret is the key of the signature of the requests, which aren't
+	// signed when it's empty.
+	Secret string
+	// Retries is the number of times a failed request is retried,
+	// DefaultRetries when zero and none when negative.
+	Retries int
+}
+
+// Payload is the body of the requests.
+type Payload struct {
+	Node string
+	Type events.Type
+	Time time.Time
+	Data map[string]string `json:",omitempty"`
+}
+
+// Sign returns the value of the SignatureHeader of body, signed with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher posts the events of a bus to hooks.
+type Dispatcher struct {
+	node  string
+	bus   *events.Bus
+	hooks []Hook
+
+	client     *http.Client
+	retryDelay time.Duration
+}
+
+// NewDispatcher returns a Dispatcher posting the events of bus, the bus of
+// the node, to hooks.
+func NewDispatcher(node string, bus *events.Bus, hooks []Hook) *Dispatcher {
+	return &Dispatcher{
+		node:       node,
+		bus:        bus,
+		hooks:      hooks,
+		client:     &http.Client{Timeout: RequestTimeout},
+		retryDelay: RetryDelay,
+	}
+}
+
+// Run posts the events until proc closes.
+func (d *Dispatcher) Run(proc goprocess.Process) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, h := range d.hooks {
+		sub := d.bus.Subscribe(queueSize, h.Types...)
+		wg.Add(1)
+		go func(h Hook) {
+			defer wg.Done()
+			defer sub.Cancel()
+			d.run(ctx, h, sub)
+		}(h)
+	}
+
+	<-proc.Closing()
+	cancel()
+	wg.Wait()
+}
+
+// run posts the events of sub to h, one at a time.
+func (d *Dispatcher) run(ctx context.Context, h Hook, sub *events.Subscription) {
+	for {
+		select {
+		case ev, ok := <-sub.Out():
+			if !ok {
+				return
+			}
+			if err := d.post(ctx, h, ev); err != nil && ctx.Err() == nil {
+				log.Warningf("failed to post the %s event to %s: %s", ev.Type, h.URL, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// post posts ev to h, retrying on failures.
+func (d *Dispatcher) post(ctx context.Context, h Hook, ev events.Event) error {
+	body, err := json.Marshal(Payload{
+		Node: d.node,
+		Type: ev.Type,
+		Time: ev.Time.UTC(),
+		Data: ev.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	retries := h.Retries
+	switch {
+	case retries == 0:
+		retries = DefaultRetries
+	case retries < 0:
+		retries = 0
+	}
+	delay := d.retryDelay
+	for i := 0; ; i++ {
+		err = d.send(ctx, h, ev.Type, body)
+		if err == nil || i == retries {
+			return err
+		}
+		log.Debugf("retrying the %s event to %s in %s: %s", ev.Type, h.URL, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, h Hook, typ events.Type, body []byte) error {
+	req, err := http.NewRequest("POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(typ))
+	if h.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(h.Secret, body))
+	}
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return nil
+}
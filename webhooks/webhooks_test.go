@@ -0,0 +1,66 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	events "github.com/ipfs/go-ipfs/events"
+)
+
+func TestPostRetries(t *testing.T) {
+	var calls, failures int
+	var got Payload
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= failures {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if sig := r.Header.Get(SignatureHeader); sig != Sign("secret", body) {
+			t.Errorf("unexpected signature %q", sig)
+		}
+		if typ := r.Header.Get(EventHeader); typ != string(events.PinAdded) {
+			t.Errorf("unexpected event type %q", typ)
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer ts.Close()
+
+	d := NewDispatcher("QmNode", nil, nil)
+	d.retryDelay = time.Millisecond
+
+	ev := events.Event{Type: events.PinAdded, Time: time.Now(), Data: map[string]string{"cid": "QmFoo"}}
+	h := Hook{URL: ts.URL, Secret: "secret"}
+	failures = 2
+	if err := d.post(context.Background(), h, ev); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 requests, got %d", calls)
+	}
+	if got.Node != "QmNode" || got.Type != events.PinAdded || got.Data["cid"] != "QmFoo" {
+		t.Fatalf("unexpected payload %+v", got)
+	}
+
+	// fails once the retries are exhausted
+	calls, failures = 0, 10
+	h.Retries = 2
+	if err := d.post(context.Background(), h, ev); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 requests, got %d", calls)
+	}
+}